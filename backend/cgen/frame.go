@@ -0,0 +1,267 @@
+// Package cgen is the C target: the frames of the functions, described to the garbage collector of the runtime, and
+// the translation of the IR to C.
+package cgen
+
+import (
+	"fmt"
+	"strings"
+
+	"tiger/ir"
+)
+
+const (
+	// WordSize is the size of the word type of the generated C, an int64_t so that pointers fit on any host
+	WordSize = 8
+
+	// the first words of a frame array are read by the collector of the runtime: the address of the frame of the
+	// caller, which chains the frames of all the active functions, and the pointer map of the call being made
+	frameLink   = 0
+	frameMap    = 1
+	frameHeader = 2
+)
+
+const (
+	// address of the frame array of the current function
+	fp ir.Temp = iota + 1
+
+	// the value that the function returns
+	rv
+)
+
+var (
+	tempMap = map[ir.Temp]string{
+		fp: "fp",
+		rv: "rv",
+	}
+)
+
+func tempName(t ir.Temp) string {
+	v, ok := tempMap[t]
+	if ok {
+		return v
+	}
+
+	return t.String()
+}
+
+// funcName is the C name of a Tiger function. The prefix keeps Tiger names away from C keywords and the C library.
+func funcName(tm *ir.TempManagement, label ir.Label) string {
+	return "tig_" + tm.LabelString(label)
+}
+
+type InFrameAccess struct {
+	offset int32
+}
+
+// Exp converts InFrameAccess into ExpIr. The argument is the address of the frame array that the access lives in.
+func (a *InFrameAccess) Exp(frameAddress ir.ExpIr) ir.ExpIr {
+	return &ir.MemExpIr{Mem: &ir.BinOpExpIr{
+		Binop: ir.PlusIr,
+		Left:  frameAddress,
+		Right: &ir.ConstExpIr{Value: a.offset},
+	}}
+}
+
+type InRegAccess struct {
+	temp ir.Temp
+}
+
+func (a *InRegAccess) Exp(_ ir.ExpIr) ir.ExpIr {
+	return &ir.TempExpIr{Temp: a.temp}
+}
+
+// Frame is the frame of a function of the C backend. Every formal is a parameter of the C function and every temp
+// a local variable, the C compiler allocates registers. Escaping formals and locals live in a word array on the C
+// stack instead, whose address is the frame pointer, so that nested functions can reach them through static links.
+// The collector cannot see the locals of the C function, so emitC moves the heap pointers that are live across calls
+// into the frame array too:
+//
+//	8*0(fp)     frame of the caller
+//	8*1(fp)     pointer map of the current call
+//	8*k(fp)     escaping formals and locals, heap pointers live across calls
+type Frame struct {
+	tm         *ir.TempManagement
+	name       ir.Label
+	params     []ir.Temp
+	accesses   []ir.FrameAccess
+	shiftInsts []ir.StmIr
+	locals     int32
+
+	// the variables that hold heap pointers, in temps and in words of the frame array, and whether the function
+	// returns one
+	ptrTemps  ir.TempSet
+	ptrWords  []int32
+	ptrResult bool
+}
+
+func NewFrame(tm *ir.TempManagement, name ir.Label, escapes []bool) ir.Frame {
+	frame := Frame{
+		tm:       tm,
+		name:     name,
+		ptrTemps: ir.NewTempSet(),
+	}
+
+	frame.createAccesses(escapes)
+	return &frame
+}
+
+func (f *Frame) createAccesses(escapes []bool) {
+	for _, escape := range escapes {
+		param := f.tm.NewTemp()
+		f.params = append(f.params, param)
+		if !escape {
+			f.accesses = append(f.accesses, &InRegAccess{temp: param})
+			continue
+		}
+
+		acc := f.AllocLocal(true)
+		f.accesses = append(f.accesses, acc)
+		f.shiftInsts = append(f.shiftInsts, &ir.MoveStmIr{
+			Dst: acc.Exp(&ir.TempExpIr{Temp: fp}),
+			Src: &ir.TempExpIr{Temp: param},
+		})
+	}
+}
+
+func (f *Frame) TempMap() map[ir.Temp]string {
+	return tempMap
+}
+
+func (f *Frame) TempName(t ir.Temp) string {
+	return tempName(t)
+}
+
+func (f *Frame) Name() ir.Label {
+	return f.name
+}
+
+func (f *Frame) Formals() []ir.FrameAccess {
+	return f.accesses
+}
+
+func (f *Frame) AllocLocal(escape bool) ir.FrameAccess {
+	if escape {
+		f.locals++
+		return &InFrameAccess{offset: WordSize * (frameHeader + f.locals - 1)}
+	}
+
+	return &InRegAccess{f.tm.NewTemp()}
+}
+
+func (f *Frame) ParamTemps() []ir.Temp {
+	return f.params
+}
+
+func (f *Frame) FrameWords() int32 {
+	return frameHeader + f.locals
+}
+
+func (f *Frame) Pointer(acc ir.FrameAccess) {
+	switch v := acc.(type) {
+	case *InRegAccess:
+		f.ptrTemps.Add(v.temp)
+	case *InFrameAccess:
+		f.ptrWords = append(f.ptrWords, v.offset/WordSize)
+	}
+}
+
+func (f *Frame) PointerResult() {
+	f.ptrResult = true
+}
+
+func (f *Frame) FP() ir.Temp {
+	return fp
+}
+
+func (f *Frame) RV() ir.Temp {
+	return rv
+}
+
+func (f *Frame) CodeGen(tm *ir.TempManagement, stm ir.StmIr) []ir.Instr {
+	return NewCodeGenerator(tm).GenCode(stm)
+}
+
+// ProcEntryExit1 copies the escaping parameters into the frame array. There are no registers to save.
+func (f *Frame) ProcEntryExit1(body ir.StmIr) ir.StmIr {
+	return ir.SeqStm(append(append([]ir.StmIr{}, f.shiftInsts...), body)...)
+}
+
+// ProcEntryExit2 has nothing to do since the C backend skips register allocation.
+func (f *Frame) ProcEntryExit2(body []ir.Instr) []ir.Instr {
+	return body
+}
+
+// ProcEntryExit3 opens the C function, declares the frame array, the frame pointer and the return value, and pushes
+// the frame on the chain that the collector walks. The array is cleared so that the collector never finds stale
+// words in it. The temps of the body are declared by emitC, which knows them only after instruction selection.
+func (f *Frame) ProcEntryExit3() (string, string) {
+	prolog := fmt.Sprintf("%s\n{\n\tword frame[%d] = {0};\n\tword fp = WORD(frame);\n\tword rv = 0;\n\n"+
+		"\tframe[%d] = tig_frames;\n\ttig_frames = fp;\n", f.signature(), f.FrameWords(), frameLink)
+
+	epilog := fmt.Sprintf("\ttig_frames = frame[%d];\n\treturn rv;\n}\n\n", frameLink)
+	return prolog, epilog
+}
+
+func (f *Frame) signature() string {
+	params := make([]string, 0, len(f.params))
+	for _, param := range f.params {
+		params = append(params, "word "+tempName(param))
+	}
+
+	if len(params) == 0 {
+		params = append(params, "void")
+	}
+
+	return fmt.Sprintf("word %s(%s)", funcName(f.tm, f.name), strings.Join(params, ", "))
+}
+
+// RuntimeFuncs lists the functions that the source of the C runtime defines, the names of which start with the prefix
+// of funcName.
+func RuntimeFuncs(runtime string) map[string]bool {
+	funcs := make(map[string]bool)
+	for _, line := range strings.Split(runtime, "\n") {
+		if !strings.HasPrefix(line, "word tig_") || strings.HasSuffix(line, ";") {
+			continue
+		}
+
+		if i := strings.IndexByte(line, '('); i > 0 {
+			funcs[line[len("word tig_"):i]] = true
+		}
+	}
+
+	return funcs
+}
+
+// StringFrag writes a string fragment as a null-terminated C array.
+func StringFrag(tm *ir.TempManagement, sb *strings.Builder, frag *ir.StrFrag) string {
+	sb.WriteString("static char ")
+	sb.WriteString(tm.LabelString(frag.Label))
+	sb.WriteString("[] = \"")
+	for i := 0; i < len(frag.Str); i++ {
+		c := frag.Str[i]
+		switch {
+		case c == '"' || c == '\\' || c == '?':
+			// escaping ? rules out trigraphs
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c >= ' ' && c < 127:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(sb, "\\%03o", c)
+		}
+	}
+
+	sb.WriteString("\";\n")
+	return sb.String()
+}
+
+// tableFrag writes a table fragment as a constant word array of the addresses of the functions.
+func tableFrag(tm *ir.TempManagement, sb *strings.Builder, frag *ir.TableFrag) string {
+	elems := make([]string, 0, len(frag.Labels))
+	for _, label := range frag.Labels {
+		elems = append(elems, "WORD("+funcName(tm, label)+")")
+	}
+
+	sb.WriteString(fmt.Sprintf("static const word %s[] = {%s};\n", tm.LabelString(frag.Label), strings.Join(elems, ", ")))
+	return sb.String()
+}
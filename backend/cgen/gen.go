@@ -0,0 +1,420 @@
+package cgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"tiger/ir"
+)
+
+// prelude starts every generated C file. MEM turns a word holding an address into the word it points to, tig_frames
+// is the innermost frame of the chain that the collector walks.
+const prelude = `#include <stdint.h>
+
+typedef int64_t word;
+
+#define MEM(a) (*(word *)(intptr_t)(a))
+#define WORD(p) ((word)(intptr_t)(p))
+
+extern word tig_frames;
+
+`
+
+// CodeGenerator turns every canonical statement into one C statement. Temps are printed with their own names since
+// the C backend has no register allocation; the instructions still list the temps they read and write, which is how
+// emitC finds the locals to declare.
+type CodeGenerator struct {
+	tm           *ir.TempManagement
+	instructions []ir.Instr
+	src          []ir.Temp
+}
+
+func NewCodeGenerator(tm *ir.TempManagement) *CodeGenerator {
+	return &CodeGenerator{tm: tm}
+}
+
+func (c *CodeGenerator) GenCode(stm ir.StmIr) []ir.Instr {
+	c.munchStm(stm)
+	return c.instructions
+}
+
+func (c *CodeGenerator) emit(instr ir.Instr) {
+	c.instructions = append(c.instructions, instr)
+}
+
+// emitOper emits assem along with the temps read by the expressions rendered since the previous instruction.
+func (c *CodeGenerator) emitOper(assem string, dst []ir.Temp, jumps []ir.Label) {
+	c.emit(&ir.OperInstr{
+		Assem: assem,
+		Dst:   dst,
+		Src:   c.src,
+		Jumps: jumps,
+	})
+
+	c.src = nil
+}
+
+func (c *CodeGenerator) munchStm(s ir.StmIr) {
+	switch v := s.(type) {
+	case *ir.SeqStmIr:
+		c.munchStm(v.First)
+		c.munchStm(v.Second)
+
+	case *ir.LabelStmIr:
+		// a label must be followed by a statement, even at the end of a function
+		c.emit(&ir.LabelInstr{
+			Assem: c.tm.LabelString(v.Label) + ":;",
+			Lab:   v.Label,
+		})
+
+	case *ir.MoveStmIr:
+		switch v1 := v.Dst.(type) {
+		case *ir.MemExpIr:
+			addr, src := c.munchTopExp(v1.Mem), c.munchTopExp(v.Src)
+			c.emitOper(fmt.Sprintf("MEM(%s) = %s;", addr, src), nil, nil)
+
+		case *ir.TempExpIr:
+			src := c.munchTopExp(v.Src)
+			c.emitOper(fmt.Sprintf("%s = %s;", tempName(v1.Temp), src), []ir.Temp{v1.Temp}, nil)
+
+		default:
+			panic("invalid instruction arguments")
+		}
+
+	case *ir.JumpStmIr:
+		v1, ok := v.Exp.(*ir.NameExpIr)
+		if !ok {
+			panic("the C backend only supports jumps to labels")
+		}
+
+		c.emitOper("goto "+c.tm.LabelString(v1.Label)+";", nil, []ir.Label{v1.Label})
+
+	case *ir.CJumpStmIr:
+		left, right := c.munchExp(v.Left), c.munchExp(v.Right)
+		c.emitOper(fmt.Sprintf("if (%s %s %s) goto %s; else goto %s;", left, relOp(v.Relop), right,
+			c.tm.LabelString(v.TrueLabel), c.tm.LabelString(v.FalseLabel)), nil, []ir.Label{v.TrueLabel, v.FalseLabel})
+
+	case *ir.ExpStmIr:
+		// only calls have side effects once the tree is canonical
+		if _, ok := v.Exp.(*ir.CallExpIr); ok {
+			c.emitOper(c.munchTopExp(v.Exp)+";", nil, nil)
+		}
+	}
+}
+
+func relOp(relop ir.RelOpIr) string {
+	switch relop {
+	case ir.EqIr:
+		return "=="
+	case ir.NeIr:
+		return "!="
+	case ir.LtIr:
+		return "<"
+	case ir.GtIr:
+		return ">"
+	case ir.LeIr:
+		return "<="
+	case ir.GeIr:
+		return ">="
+	}
+
+	panic("invalid relational operator")
+}
+
+func binOp(binop ir.BinOpIr) string {
+	switch binop {
+	case ir.PlusIr:
+		return "+"
+	case ir.MinusIr:
+		return "-"
+	case ir.MulIr:
+		return "*"
+	case ir.DivIr:
+		return "/"
+	}
+
+	panic("invalid binary operator")
+}
+
+// munchTopExp renders an expression that is not an operand of a binary operator and needs no parentheses.
+func (c *CodeGenerator) munchTopExp(exp ir.ExpIr) string {
+	if v, ok := exp.(*ir.BinOpExpIr); ok {
+		return fmt.Sprintf("%s %s %s", c.munchExp(v.Left), binOp(v.Binop), c.munchExp(v.Right))
+	}
+
+	return c.munchExp(exp)
+}
+
+func (c *CodeGenerator) munchExp(exp ir.ExpIr) string {
+	switch t := exp.(type) {
+	case *ir.BinOpExpIr:
+		return "(" + c.munchTopExp(t) + ")"
+
+	case *ir.MemExpIr:
+		return "MEM(" + c.munchTopExp(t.Mem) + ")"
+
+	case *ir.TempExpIr:
+		c.src = append(c.src, t.Temp)
+		return tempName(t.Temp)
+
+	case *ir.ConstExpIr:
+		return fmt.Sprintf("%d", t.Value)
+
+	case *ir.NameExpIr:
+		return "WORD(" + c.tm.LabelString(t.Label) + ")"
+
+	case *ir.CallExpIr:
+		args := make([]string, 0, len(t.Args))
+		for _, arg := range t.Args {
+			args = append(args, c.munchTopExp(arg))
+		}
+
+		if name, ok := t.Exp.(*ir.NameExpIr); ok {
+			return fmt.Sprintf("%s(%s)", funcName(c.tm, name.Label), strings.Join(args, ", "))
+		}
+
+		// the word holds the address of a function, e.g. a method read from a vtable
+		return fmt.Sprintf("((word (*)(%s))(intptr_t)%s)(%s)", paramTypes(len(args)), c.munchExp(t.Exp),
+			strings.Join(args, ", "))
+	}
+
+	sb := strings.Builder{}
+	exp.PrintExpIr(&sb, c.tm.Strings(), 0)
+	panic("invalid IR exp " + sb.String())
+}
+
+// paramTypes lists the types of the n parameters of a function.
+func paramTypes(n int) string {
+	if n == 0 {
+		return "void"
+	}
+
+	return strings.TrimSuffix(strings.Repeat("word, ", n), ", ")
+}
+
+// Emit writes the whole program as one C translation unit: prototypes for every function it defines or calls, the
+// string and table fragments, the pointer maps and then the functions. The runtime functions are defined in
+// runtime/src/runtime.c.
+func Emit(tm *ir.TempManagement, dumper *ir.Dumper, frags []ir.Frag) string {
+	var (
+		procs  []*ir.ProcFrag
+		strs   []*ir.StrFrag
+		tables []*ir.TableFrag
+	)
+
+	for _, frag := range frags {
+		switch f := frag.(type) {
+		case *ir.ProcFrag:
+			procs = append(procs, f)
+		case *ir.TableFrag:
+			tables = append(tables, f)
+		default:
+			strs = append(strs, frag.(*ir.StrFrag))
+		}
+	}
+
+	results := make(map[ir.Label]bool)
+	for _, proc := range procs {
+		results[proc.Frame.Name()] = proc.Frame.(*Frame).ptrResult
+	}
+
+	bodies := make([][]ir.StmIr, len(procs))
+	callees := make(map[ir.Label]int)
+	maps := pointerMaps{tm: tm, labels: make(map[string]ir.Label), calls: make(map[ir.StmIr]ir.Label)}
+	for i, proc := range procs {
+		bodies[i] = maps.spill(proc.Frame.(*Frame), ir.Canonicalize(tm, dumper, proc), results)
+		for _, stm := range bodies[i] {
+			ir.CollectCallees(stm, callees)
+		}
+	}
+
+	for _, proc := range procs {
+		delete(callees, proc.Frame.Name())
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(prelude)
+
+	externs := make([]string, 0, len(callees))
+	for label, nargs := range callees {
+		externs = append(externs, fmt.Sprintf("word %s(%s);\n", funcName(tm, label), paramTypes(nargs)))
+	}
+
+	// the map gives a random order, keep the output stable
+	sort.Strings(externs)
+	for _, extern := range externs {
+		sb.WriteString(extern)
+	}
+
+	for _, proc := range procs {
+		sb.WriteString(proc.Frame.(*Frame).signature() + ";\n")
+	}
+
+	sb.WriteString("\n")
+	for _, str := range strs {
+		StringFrag(tm, &sb, str)
+	}
+
+	for _, table := range tables {
+		tableFrag(tm, &sb, table)
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(maps.tables.String())
+	sb.WriteString("\n")
+	for i, proc := range procs {
+		instrs := make([]ir.Instr, 0)
+		for _, stm := range bodies[i] {
+			if label, ok := maps.calls[stm]; ok {
+				instrs = append(instrs, maps.set(label))
+			}
+
+			instrs = append(instrs, proc.Frame.CodeGen(tm, stm)...)
+		}
+
+		instrs = proc.Frame.ProcEntryExit2(instrs)
+		prolog, epilog := proc.Frame.ProcEntryExit3()
+		sb.WriteString(prolog)
+		if locals := localNames(proc.Frame.(*Frame), instrs); len(locals) > 0 {
+			sb.WriteString("\tword " + strings.Join(locals, ", ") + ";\n")
+		}
+
+		sb.WriteString("\n")
+		for _, instr := range instrs {
+			if _, ok := instr.(*ir.LabelInstr); !ok {
+				sb.WriteString("\t")
+			}
+
+			sb.WriteString(instr.AssemStr() + "\n")
+		}
+
+		sb.WriteString(epilog)
+	}
+
+	return sb.String()
+}
+
+// localNames lists the names of the temps that the instructions use, except the parameters of the frame and the locals
+// that ProcEntryExit3 declares.
+func localNames(frame *Frame, instrs []ir.Instr) []string {
+	declared := ir.NewTempSet(append([]ir.Temp{fp, rv}, frame.params...)...)
+	var locals []string
+	for _, instr := range instrs {
+		for _, temp := range append(instr.DstRegs(), instr.SrcRegs()...) {
+			if declared.Has(temp) {
+				continue
+			}
+
+			declared.Add(temp)
+			locals = append(locals, tempName(temp))
+		}
+	}
+
+	return locals
+}
+
+// pointerMaps builds the pointer maps of the calls of a program. A map is a word array with the number of words of
+// the frame array that hold heap pointers during the call, followed by their indices. Calls during which no word
+// holds a pointer have no map.
+type pointerMaps struct {
+	tm *ir.TempManagement
+
+	// the tables of the maps, labels of the tables by their content and of the maps of the calls
+	tables strings.Builder
+	labels map[string]ir.Label
+	calls  map[ir.StmIr]ir.Label
+}
+
+// spill moves the heap pointers that are live across calls in the canonical body into new words of the frame array,
+// where the collector can find and update them, and records the pointer map of every call of the body it returns.
+// results lists the functions that return heap pointers.
+func (m *pointerMaps) spill(frame *Frame, body []ir.StmIr, results map[ir.Label]bool) []ir.StmIr {
+	pointers := ir.PointerTemps(m.tm, body, frame.ptrTemps, results)
+	across := ir.LiveAcrossCalls(body)
+
+	words := make(map[ir.Temp]int32)
+	exps := make(map[ir.Temp]ir.ExpIr)
+	for i := range body {
+		// the map gives a random order, keep the frame layout stable
+		temps := make([]ir.Temp, 0, len(across[i]))
+		for temp := range across[i] {
+			temps = append(temps, temp)
+		}
+
+		sort.Slice(temps, func(i, j int) bool { return temps[i] < temps[j] })
+		for _, temp := range temps {
+			if _, ok := exps[temp]; ok || !pointers.Has(temp) || temp == fp || temp == rv {
+				continue
+			}
+
+			acc := frame.AllocLocal(true).(*InFrameAccess)
+			words[temp] = acc.offset / WordSize
+			exps[temp] = &ir.MemExpIr{Mem: &ir.BinOpExpIr{
+				Binop: ir.PlusIr,
+				Left:  &ir.TempExpIr{Temp: fp},
+				Right: &ir.ConstExpIr{Value: acc.offset},
+			}, Ptr: true}
+		}
+	}
+
+	var spilled []ir.StmIr
+
+	// parameters arrive in temps
+	for _, param := range frame.params {
+		if exp, ok := exps[param]; ok {
+			spilled = append(spilled, &ir.MoveStmIr{Dst: exp, Src: &ir.TempExpIr{Temp: param}})
+		}
+	}
+
+	for i, stm := range body {
+		stm = ir.ReplaceTemps(stm, exps)
+		spilled = append(spilled, stm)
+		if !ir.IsCall(stm) {
+			continue
+		}
+
+		live := append([]int32{}, frame.ptrWords...)
+		for temp := range across[i] {
+			if word, ok := words[temp]; ok {
+				live = append(live, word)
+			}
+		}
+
+		m.calls[stm] = m.table(live)
+	}
+
+	return spilled
+}
+
+// table returns the label of the table of the map of words, 0 for an empty map.
+func (m *pointerMaps) table(words []int32) ir.Label {
+	if len(words) == 0 {
+		return 0
+	}
+
+	sort.Slice(words, func(i, j int) bool { return words[i] < words[j] })
+	elems := []string{fmt.Sprintf("%d", len(words))}
+	for _, word := range words {
+		elems = append(elems, fmt.Sprintf("%d", word))
+	}
+
+	key := strings.Join(elems, ", ")
+	if label, ok := m.labels[key]; ok {
+		return label
+	}
+
+	label := m.tm.NewLabel()
+	m.labels[key] = label
+	m.tables.WriteString(fmt.Sprintf("static const word %s[] = {%s};\n", m.tm.LabelString(label), key))
+	return label
+}
+
+// set returns the instruction that stores the map of a call in the frame array before the call.
+func (m *pointerMaps) set(label ir.Label) ir.Instr {
+	if label == 0 {
+		return &ir.OperInstr{Assem: fmt.Sprintf("frame[%d] = 0;", frameMap)}
+	}
+
+	return &ir.OperInstr{Assem: fmt.Sprintf("frame[%d] = WORD(%s);", frameMap, m.tm.LabelString(label))}
+}
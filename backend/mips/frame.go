@@ -0,0 +1,378 @@
+// Package mips is the MIPS target: its frames and the selection of its instructions.
+package mips
+
+import (
+	"fmt"
+	"strings"
+
+	"tiger/ir"
+)
+
+const (
+	WordSize = 4
+)
+
+const (
+	zero ir.Temp = iota + 1 // $0
+
+	// expression evaluation and results of a function
+	v0 // $2
+	v1 // $3
+
+	// function arguments registers
+	a0 // $4
+	a1 // $5
+	a2 // $6
+	a3 // $7
+
+	// temporaries - not preserved across call
+	t0 // $8
+	t1 // $9
+	t2 // $10
+	t3 // $11
+	t4 // $12
+	t5 // $13
+	t6 // $14
+	t7 // $15
+
+	// save values - preserved across calls
+	s0 // $16
+	s1 // $17
+	s2 // $18
+	s3 // $19
+	s4 // $20
+	s5 // $21
+	s6 // $22
+	s7 // $23
+
+	// temporaries - not preserved across call
+	t8 // $24
+	t9 // $25
+
+	// pointer to the global area
+	gp // $28
+
+	// stack pointer
+	sp // $29
+
+	// frame pointer
+	fp // $30
+
+	// return address
+	ra // $31
+	rv = v0
+)
+
+var (
+	specialArgs = []ir.Temp{rv, fp, sp, ra}
+
+	// registers to save arguments
+	argRegs = []ir.Temp{a0, a1, a2, a3}
+
+	// callee-saved registers
+	calleeSaves = []ir.Temp{s0, s1, s2, s3, s4, s5, s6, s7}
+
+	// caller-saved registers
+	callerSaves = []ir.Temp{t0, t1, t2, t3, t4, t5, t6, t7}
+
+	tempMap = map[ir.Temp]string{
+		a0: "$a0",
+		a1: "$a1",
+		a2: "$a2",
+		a3: "$a3",
+		t0: "$t0",
+		t1: "$t1",
+		t2: "$t2",
+		t3: "$t3",
+		t4: "$t4",
+		t5: "$t5",
+		t6: "$t6",
+		t7: "$t7",
+		s0: "$s0",
+		s1: "$s1",
+		s2: "$s2",
+		s3: "$s3",
+		s4: "$s4",
+		s5: "$s5",
+		s6: "$s6",
+		s7: "$s7",
+		fp: "$fp",
+		sp: "$sp",
+		ra: "$ra",
+		rv: "$v0",
+	}
+)
+
+func tempName(t ir.Temp) string {
+	v, ok := tempMap[t]
+	if ok {
+		return v
+	}
+
+	return t.String()
+}
+
+type InFrameAccess struct {
+	offset int32
+}
+
+// Exp converts InFrameAccess into ExpIr. The argument is the address of the stack frame that the access lives in.
+func (a *InFrameAccess) Exp(frameAddress ir.ExpIr) ir.ExpIr {
+	return &ir.MemExpIr{Mem: &ir.BinOpExpIr{
+		Binop: ir.PlusIr,
+		Left:  frameAddress,
+		Right: &ir.ConstExpIr{Value: a.offset},
+	}}
+}
+
+type InRegAccess struct {
+	temp ir.Temp
+}
+
+func (a *InRegAccess) Exp(_ ir.ExpIr) ir.ExpIr {
+	return &ir.TempExpIr{Temp: a.temp}
+}
+
+// Frame is the frame of a function. Its first word, at -4($fp), holds the pointer map of the function, which the
+// collector of runtime.s reads when it walks the frames through the $fp they save.
+type Frame struct {
+	tm         *ir.TempManagement
+	name       ir.Label
+	accesses   []ir.FrameAccess
+	shiftInsts ir.StmIr
+	locals     int32
+
+	// the temps and the offsets from $fp of the words that hold heap pointers
+	ptrTemps   ir.TempSet
+	ptrOffsets []int32
+}
+
+func NewFrame(tm *ir.TempManagement, name ir.Label, escapes []bool) ir.Frame {
+	frame := Frame{
+		tm:       tm,
+		name:     name,
+		locals:   1,
+		ptrTemps: ir.NewTempSet(),
+	}
+
+	frame.createAccesses(0, escapes)
+	return &frame
+}
+
+func (f *Frame) TempMap() map[ir.Temp]string {
+	return tempMap
+}
+
+func (f *Frame) TempName(t ir.Temp) string {
+	return tempName(t)
+}
+
+func (f *Frame) createAccesses(i int32, escapes []bool) {
+	if int(i) >= len(escapes) {
+		return
+	}
+
+	// after the first 4 arguments, always escape
+	if int(i) >= len(argRegs) {
+		f.accesses = append(f.accesses, &InFrameAccess{offset: (i + 1) * WordSize})
+		f.createAccesses(i+1, escapes)
+		return
+	}
+
+	var acc ir.FrameAccess
+	// the first 4 arguments are passed in 4 registers [$a0, $a1, $a2, $a3]
+	if escapes[i] {
+		acc = &InFrameAccess{offset: (i + 1) * WordSize}
+	} else {
+		acc = &InRegAccess{f.tm.NewTemp()}
+	}
+
+	f.accesses = append(f.accesses, acc)
+	if f.shiftInsts == nil {
+		f.shiftInsts = &ir.MoveStmIr{
+			Dst: acc.Exp(&ir.TempExpIr{Temp: fp}),
+			Src: &ir.TempExpIr{Temp: argRegs[i]},
+		}
+	} else {
+		f.shiftInsts = &ir.SeqStmIr{
+			First: f.shiftInsts,
+			Second: &ir.MoveStmIr{
+				Dst: acc.Exp(&ir.TempExpIr{Temp: fp}),
+				Src: &ir.TempExpIr{Temp: argRegs[i]},
+			},
+		}
+	}
+
+	f.createAccesses(i+1, escapes)
+}
+
+func (f *Frame) Name() ir.Label {
+	return f.name
+}
+
+func (f *Frame) Formals() []ir.FrameAccess {
+	return f.accesses
+}
+
+func (f *Frame) AllocLocal(escape bool) ir.FrameAccess {
+	if escape {
+		f.locals++
+		return &InFrameAccess{offset: -WordSize * f.locals}
+	}
+
+	return &InRegAccess{f.tm.NewTemp()}
+}
+
+// Location writes the words of the frame as offsets of $fp, and the temps as their registers.
+func (f *Frame) Location(acc ir.FrameAccess, colored map[ir.Temp]string,
+	spilled map[ir.Temp]ir.FrameAccess) (string, bool) {
+	switch v := acc.(type) {
+	case *InFrameAccess:
+		return fmt.Sprintf("%d($fp)", v.offset), true
+	case *InRegAccess:
+		if reg, ok := colored[v.temp]; ok {
+			return reg, true
+		}
+
+		if spill, ok := spilled[v.temp]; ok {
+			return f.Location(spill, colored, spilled)
+		}
+	}
+
+	return "", false
+}
+
+func (f *Frame) Pointer(acc ir.FrameAccess) {
+	switch v := acc.(type) {
+	case *InRegAccess:
+		f.ptrTemps.Add(v.temp)
+	case *InFrameAccess:
+		f.ptrOffsets = append(f.ptrOffsets, v.offset)
+	}
+}
+
+// PointerResult has nothing to record, the calls that return heap pointers are marked where they are made.
+func (f *Frame) PointerResult() {}
+
+func (f *Frame) PointerTemps() ir.TempSet {
+	return f.ptrTemps
+}
+
+func (f *Frame) FP() ir.Temp {
+	return fp
+}
+
+func (f *Frame) RV() ir.Temp {
+	return rv
+}
+
+func (f *Frame) CodeGen(tm *ir.TempManagement, stm ir.StmIr) []ir.Instr {
+	return NewCodeGenerator(tm).GenCode(stm)
+}
+
+// ProcEntryExit1 is procedure entry and exit statement
+// 4. save "escaping" arguments (including static link) into the frame, move nonescaping arguments into fresh temporary registers.
+// 5. store instructions to save any calle-save registers - including the return address register - used within the function.
+// 8. load instructions to restore the calle-save registers
+func (f *Frame) ProcEntryExit1(body ir.StmIr) ir.StmIr {
+	shifts := f.shiftInsts
+
+	calleeSaveRegs := append(calleeSaves, ra)
+	accesses := make([]ir.FrameAccess, len(calleeSaveRegs))
+	for i := 0; i < len(calleeSaveRegs); i++ {
+		accesses[i] = f.AllocLocal(false)
+	}
+
+	saves := make([]ir.StmIr, 0, len(calleeSaveRegs))
+	for i, reg := range calleeSaveRegs {
+		saves = append(saves, &ir.MoveStmIr{
+			Dst: accesses[i].Exp(&ir.TempExpIr{Temp: fp}),
+			Src: &ir.TempExpIr{Temp: reg},
+		})
+	}
+
+	restores := make([]ir.StmIr, 0, len(calleeSaveRegs))
+	for i := len(calleeSaveRegs) - 1; i >= 0; i-- {
+		restores = append(restores, &ir.MoveStmIr{
+			Src: accesses[i].Exp(&ir.TempExpIr{Temp: fp}),
+			Dst: &ir.TempExpIr{Temp: calleeSaveRegs[i]},
+		})
+	}
+
+	if shifts == nil {
+		return ir.SeqStm(append(append(saves, body), restores...)...)
+	}
+
+	return &ir.SeqStmIr{
+		First:  shifts,
+		Second: ir.SeqStm(append(append(saves, body), restores...)...),
+	}
+}
+
+// ProcEntryExit3 reserves the locals of the frame, and below them a word for the $fp that a callee saves and the
+// words it saves its register arguments to. The prologue stores the pointer map, and clears the locals it lists so
+// that the collector never finds stale words in them. The map follows the function, as the number of the words that
+// hold heap pointers and their offsets from $fp.
+func (f *Frame) ProcEntryExit3() (string, string) {
+	name := f.tm.LabelString(f.Name())
+	offset := (int(f.locals) + 1 + len(argRegs)) * WordSize
+	prolog := fmt.Sprintf("%s:\n\tsw\t$fp\t0($sp)\n\tmove\t$fp\t$sp\n\taddiu\t$sp\t$sp\t-%d\n", name, offset)
+	epilog := fmt.Sprintf("\tmove\t$sp\t$fp\n\tlw\t$fp\t0($sp)\n\tjr\t$ra\n\n")
+	if len(f.ptrOffsets) == 0 {
+		return prolog + "\tsw\t$zero\t-4($fp)\n", epilog
+	}
+
+	// $v1 is given to no temp
+	prolog += fmt.Sprintf("\tla\t$v1\t_map_%s\n\tsw\t$v1\t-4($fp)\n", name)
+	words := []string{fmt.Sprintf("%d", len(f.ptrOffsets))}
+	for _, offset := range f.ptrOffsets {
+		words = append(words, fmt.Sprintf("%d", offset))
+		if offset < 0 {
+			prolog += fmt.Sprintf("\tsw\t$zero\t%d($fp)\n", offset)
+		}
+	}
+
+	epilog += fmt.Sprintf("\t.data\n\t.align\t2\n_map_%s:\t.word\t%s\n\t.text\n\n", name, strings.Join(words, ", "))
+	return prolog, epilog
+}
+
+// ProcEntryExit2 notifies the register allocation that rv, ra, sp, fp and calleSaves are live out at the end of the
+// function, so that none of them is given to a temp that is live at the same time.
+func (f *Frame) ProcEntryExit2(body []ir.Instr) []ir.Instr {
+	return append(body, &ir.OperInstr{
+		Src: append([]ir.Temp{rv, ra, sp, fp}, calleeSaves...),
+	})
+}
+
+func StringFrag(tm *ir.TempManagement, sb *strings.Builder, frag *ir.StrFrag) string {
+	sb.WriteString(tm.LabelString(frag.Label))
+	sb.WriteString(":\t.asciiz\t\"")
+	for _, c := range frag.Str {
+		switch c {
+		case '\n':
+			sb.WriteString("\\n")
+		case '\t':
+			sb.WriteString("\\t")
+		case 0:
+			sb.WriteString("\\0")
+		case '"':
+			sb.WriteString("\\\"")
+		case '\'':
+			sb.WriteString("\\'")
+		case '\\':
+			sb.WriteString("\\\\")
+		default:
+			if c >= ' ' || c < 127 {
+				sb.WriteByte(byte(c))
+			} else {
+				sb.WriteByte('\\')
+				sb.WriteByte('0' + (byte(c) >> 6))
+				sb.WriteByte('0' + ((byte(c) >> 3) & 8))
+				sb.WriteByte('0' + (byte(c) & 8))
+			}
+		}
+	}
+
+	sb.WriteString("\"\n")
+	return sb.String()
+}
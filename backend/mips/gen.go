@@ -0,0 +1,435 @@
+package mips
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tiger/ir"
+)
+
+type CodeGenerator struct {
+	tm           *ir.TempManagement
+	instructions []ir.Instr
+	callDefs     []ir.Temp
+}
+
+func NewCodeGenerator(tm *ir.TempManagement) *CodeGenerator {
+	return &CodeGenerator{
+		tm:       tm,
+		callDefs: append(append([]ir.Temp{rv, ra}, argRegs...), callerSaves...),
+	}
+}
+
+func (c *CodeGenerator) GenCode(stm ir.StmIr) []ir.Instr {
+	c.munchStm(stm)
+	return c.instructions
+}
+
+func (c *CodeGenerator) munchStm(s ir.StmIr) {
+	switch v := s.(type) {
+	case *ir.SeqStmIr:
+		c.munchStm(v.First)
+		c.munchStm(v.Second)
+
+	case *ir.LabelStmIr:
+		c.instructions = append(c.instructions, &ir.LabelInstr{
+			Assem: c.tm.LabelString(v.Label) + ":",
+			Lab:   v.Label,
+		})
+
+	case *ir.LineStmIr:
+		// the instructions up to the next .loc are those of the statement at Pos
+		c.instructions = append(c.instructions, &ir.OperInstr{
+			Assem: fmt.Sprintf(".loc\t%d\t%d", v.Pos.Line, v.Pos.Col),
+		})
+
+	case *ir.MoveStmIr:
+		switch v1 := v.Dst.(type) {
+
+		// Move to memory
+		case *ir.MemExpIr:
+			src := c.munchExp(v.Src)
+			offset, base := c.address(v1.Mem)
+			c.instructions = append(c.instructions, &ir.OperInstr{
+				Assem: "sw `s0, " + strconv.FormatInt(int64(offset), 10) + "(`s1)",
+				Src:   []ir.Temp{src, base},
+			})
+
+		// Load to register
+		case *ir.TempExpIr:
+			switch v2 := v.Src.(type) {
+			case *ir.ConstExpIr:
+				instr := &ir.OperInstr{
+					Assem: "li `d0, " + strconv.FormatInt(int64(v2.Value), 10),
+					Dst:   []ir.Temp{c.munchExp(v.Dst)},
+				}
+				c.instructions = append(c.instructions, instr)
+
+			// move register to register
+			default:
+				instr := &ir.MoveInstr{
+					Assem: "move `d0, `s0",
+					Dst:   v1.Temp,
+					Src:   c.munchExp(v.Src),
+				}
+
+				c.instructions = append(c.instructions, instr)
+			}
+
+		default:
+			panic("invalid instruction arguments")
+		}
+
+	case *ir.JumpStmIr:
+		switch v1 := v.Exp.(type) {
+		case *ir.NameExpIr:
+			instr := &ir.OperInstr{
+				Assem: "b `j0",
+				Jumps: []ir.Label{v1.Label},
+			}
+
+			c.instructions = append(c.instructions, instr)
+
+		default:
+			instr := &ir.OperInstr{
+				Assem: "jr `s0",
+				Src:   []ir.Temp{c.munchExp(v.Exp)},
+				Jumps: v.Labels,
+			}
+
+			c.instructions = append(c.instructions, instr)
+		}
+
+	case *ir.CJumpStmIr:
+		if v1, ok := v.Right.(*ir.ConstExpIr); ok && v1.Value == 0 {
+			switch v.Relop {
+			case ir.EqIr:
+				instr := &ir.OperInstr{
+					Assem: "beqz `s0, `j0\nb `j1",
+					Src:   []ir.Temp{c.munchExp(v.Left)},
+					Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+				}
+
+				c.instructions = append(c.instructions, instr)
+
+			case ir.NeIr:
+				instr := &ir.OperInstr{
+					Assem: "bnez `s0, `j0\nb `j1",
+					Src:   []ir.Temp{c.munchExp(v.Left)},
+					Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+				}
+
+				c.instructions = append(c.instructions, instr)
+
+			case ir.GeIr:
+				instr := &ir.OperInstr{
+					Assem: "bgez `s0, `j0\nb `j1",
+					Src:   []ir.Temp{c.munchExp(v.Left)},
+					Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+				}
+
+				c.instructions = append(c.instructions, instr)
+
+			case ir.GtIr:
+				instr := &ir.OperInstr{
+					Assem: "bgtz `s0, `j0\nb `j1",
+					Src:   []ir.Temp{c.munchExp(v.Left)},
+					Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+				}
+
+				c.instructions = append(c.instructions, instr)
+
+			case ir.LtIr:
+				instr := &ir.OperInstr{
+					Assem: "bltz `s0, `j0\nb `j1",
+					Src:   []ir.Temp{c.munchExp(v.Left)},
+					Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+				}
+
+				c.instructions = append(c.instructions, instr)
+
+			case ir.LeIr:
+				instr := &ir.OperInstr{
+					Assem: "blez `s0, `j0\nb `j1",
+					Src:   []ir.Temp{c.munchExp(v.Left)},
+					Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+				}
+
+				c.instructions = append(c.instructions, instr)
+			}
+
+			return
+		}
+
+		switch v.Relop {
+		case ir.LeIr:
+			instr := &ir.OperInstr{
+				Assem: "ble `s0, `s1, `j0\nb `j1",
+				Src:   []ir.Temp{c.munchExp(v.Left), c.munchExp(v.Right)},
+				Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+			}
+
+			c.instructions = append(c.instructions, instr)
+
+		case ir.LtIr:
+			instr := &ir.OperInstr{
+				Assem: "blt `s0, `s1, `j0\nb `j1",
+				Src:   []ir.Temp{c.munchExp(v.Left), c.munchExp(v.Right)},
+				Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+			}
+
+			c.instructions = append(c.instructions, instr)
+
+		case ir.GeIr:
+			instr := &ir.OperInstr{
+				Assem: "bge `s0, `s1, `j0\nb `j1",
+				Src:   []ir.Temp{c.munchExp(v.Left), c.munchExp(v.Right)},
+				Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+			}
+
+			c.instructions = append(c.instructions, instr)
+
+		case ir.GtIr:
+			instr := &ir.OperInstr{
+				Assem: "bgt `s0, `s1, `j0\nb `j1",
+				Src:   []ir.Temp{c.munchExp(v.Left), c.munchExp(v.Right)},
+				Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+			}
+
+			c.instructions = append(c.instructions, instr)
+
+		case ir.EqIr:
+			instr := &ir.OperInstr{
+				Assem: "beq `s0, `s1, `j0\nb `j1",
+				Src:   []ir.Temp{c.munchExp(v.Left), c.munchExp(v.Right)},
+				Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+			}
+
+			c.instructions = append(c.instructions, instr)
+
+		case ir.NeIr:
+			instr := &ir.OperInstr{
+				Assem: "bne `s0, `s1, `j0\nb `j1",
+				Src:   []ir.Temp{c.munchExp(v.Left), c.munchExp(v.Right)},
+				Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+			}
+
+			c.instructions = append(c.instructions, instr)
+
+		default:
+			panic("invalid binary operator")
+		}
+
+	case *ir.ExpStmIr:
+		c.munchExp(v.Exp)
+	}
+}
+
+func (c *CodeGenerator) munchExp(exp ir.ExpIr) ir.Temp {
+	switch t := exp.(type) {
+	case *ir.CallExpIr:
+		fun := c.munchExp(t.Exp)
+		args, stackSize := c.buildArgs(argRegs, t.Args)
+		c.instructions = append(c.instructions, &ir.OperInstr{
+			Assem: "jalr `s0",
+			Dst:   c.callDefs,
+			Src:   append([]ir.Temp{fun}, args...),
+		})
+		c.popArgs(stackSize)
+		return rv
+
+	case *ir.MemExpIr:
+		if t1, ok := t.Mem.(*ir.ConstExpIr); ok {
+			return c.gen(func(t ir.Temp) {
+				c.instructions = append(c.instructions, &ir.OperInstr{
+					Assem: "lw `d0, " + strconv.FormatInt(int64(t1.Value), 10) + "($zero)",
+					Dst:   []ir.Temp{t},
+				})
+			})
+		}
+
+		offset, base := c.address(t.Mem)
+		return c.gen(func(temp ir.Temp) {
+			c.instructions = append(c.instructions, &ir.OperInstr{
+				Assem: "lw `d0, " + strconv.FormatInt(int64(offset), 10) + "(`s0)",
+				Dst:   []ir.Temp{temp},
+				Src:   []ir.Temp{base},
+			})
+		})
+
+	case *ir.BinOpExpIr:
+		switch t.Binop {
+		case ir.PlusIr:
+			if t1, ok := t.Left.(*ir.ConstExpIr); ok {
+				return c.gen(func(temp ir.Temp) {
+					c.instructions = append(c.instructions, &ir.OperInstr{
+						Assem: "addi `d0, `s0, " + strconv.FormatInt(int64(t1.Value), 10),
+						Dst:   []ir.Temp{temp},
+						Src:   []ir.Temp{c.munchExp(t.Right)},
+					})
+				})
+			}
+
+			if t1, ok := t.Right.(*ir.ConstExpIr); ok {
+				return c.gen(func(temp ir.Temp) {
+					c.instructions = append(c.instructions, &ir.OperInstr{
+						Assem: "addi `d0, `s0, " + strconv.FormatInt(int64(t1.Value), 10),
+						Dst:   []ir.Temp{temp},
+						Src:   []ir.Temp{c.munchExp(t.Left)},
+					})
+				})
+			}
+
+			return c.gen(func(temp ir.Temp) {
+				c.instructions = append(c.instructions, &ir.OperInstr{
+					Assem: "add `d0, `s0, `s1",
+					Dst:   []ir.Temp{temp},
+					Src:   []ir.Temp{c.munchExp(t.Left), c.munchExp(t.Right)},
+				})
+			})
+
+		case ir.MinusIr:
+			if t1, ok := t.Right.(*ir.ConstExpIr); ok {
+				return c.gen(func(temp ir.Temp) {
+					c.instructions = append(c.instructions, &ir.OperInstr{
+						Assem: "addiu `d0, `s0, " + strconv.FormatInt(int64(-t1.Value), 10),
+						Dst:   []ir.Temp{temp},
+						Src:   []ir.Temp{c.munchExp(t.Left)},
+					})
+				})
+			}
+
+			return c.gen(func(temp ir.Temp) {
+				c.instructions = append(c.instructions, &ir.OperInstr{
+					Assem: "sub `d0, `s0, `s1",
+					Dst:   []ir.Temp{temp},
+					Src:   []ir.Temp{c.munchExp(t.Left), c.munchExp(t.Right)},
+				})
+			})
+
+		case ir.MulIr:
+			return c.gen(func(temp ir.Temp) {
+				c.instructions = append(c.instructions, &ir.OperInstr{
+					Assem: "mul `d0, `s0, `s1",
+					Dst:   []ir.Temp{temp},
+					Src:   []ir.Temp{c.munchExp(t.Left), c.munchExp(t.Right)},
+				})
+			})
+
+		case ir.DivIr:
+			return c.gen(func(temp ir.Temp) {
+				c.instructions = append(c.instructions, &ir.OperInstr{
+					Assem: "div `d0, `s0, `s1",
+					Dst:   []ir.Temp{temp},
+					Src:   []ir.Temp{c.munchExp(t.Left), c.munchExp(t.Right)},
+				})
+			})
+		}
+
+	case *ir.TempExpIr:
+		return t.Temp
+
+	case *ir.ConstExpIr:
+		return c.gen(func(temp ir.Temp) {
+			c.instructions = append(c.instructions, &ir.OperInstr{
+				Assem: fmt.Sprintf("li `d0, %d", t.Value),
+				Dst:   []ir.Temp{temp},
+			})
+		})
+
+	case *ir.NameExpIr:
+		return c.gen(func(temp ir.Temp) {
+			c.instructions = append(c.instructions, &ir.OperInstr{
+				Assem: "la `d0, " + c.tm.LabelString(t.Label),
+				Dst:   []ir.Temp{temp},
+			})
+		})
+	}
+
+	sb := strings.Builder{}
+	exp.PrintExpIr(&sb, c.tm.Strings(), 0)
+	panic("invalid IR exp " + sb.String())
+}
+
+// buildArgs passes the first four arguments in argsRegisters and returns them. The others are stored above the four
+// words that the callee saves its register arguments to, in an area pushed on the stack that popArgs pops after the
+// call, so that the callee finds argument i at (i+1)*4($fp) like those it saves.
+func (c *CodeGenerator) buildArgs(argsRegisters []ir.Temp, args []ir.ExpIr) ([]ir.Temp, int32) {
+	values := make([]ir.Temp, 0, len(args))
+	for _, exp := range args {
+		values = append(values, c.munchExp(exp))
+	}
+
+	stackSize := int32(0)
+	if len(values) > len(argsRegisters) {
+		stackSize = int32(len(values)+1) * WordSize
+		c.instructions = append(c.instructions, &ir.OperInstr{
+			Assem: fmt.Sprintf("addiu $sp, $sp, -%d", stackSize),
+		})
+	}
+
+	for i := len(argsRegisters); i < len(values); i++ {
+		c.instructions = append(c.instructions, &ir.OperInstr{
+			Assem: fmt.Sprintf("sw `s0, %d($sp)", (i+1)*WordSize),
+			Src:   []ir.Temp{values[i]},
+		})
+	}
+
+	temps := make([]ir.Temp, 0, len(argsRegisters))
+	for i := 0; i < len(values) && i < len(argsRegisters); i++ {
+		c.instructions = append(c.instructions, &ir.MoveInstr{
+			Assem: "move `d0, `s0",
+			Dst:   argsRegisters[i],
+			Src:   values[i],
+		})
+
+		temps = append(temps, argsRegisters[i])
+	}
+
+	return temps, stackSize
+}
+
+// popArgs pops the area of the arguments that buildArgs pushed.
+func (c *CodeGenerator) popArgs(stackSize int32) {
+	if stackSize > 0 {
+		c.instructions = append(c.instructions, &ir.OperInstr{
+			Assem: fmt.Sprintf("addiu $sp, $sp, %d", stackSize),
+		})
+	}
+}
+
+// address splits the address of a word of memory into the offset and the base register of an off(reg) operand. An
+// address that is not a register plus or minus a constant is computed into the base register.
+func (c *CodeGenerator) address(addr ir.ExpIr) (int32, ir.Temp) {
+	if v, ok := addr.(*ir.BinOpExpIr); ok {
+		switch v.Binop {
+		case ir.PlusIr:
+			if n, ok := ir.ConstValue(v.Right); ok && fitsImm16(n) {
+				return n, c.munchExp(v.Left)
+			}
+
+			if n, ok := ir.ConstValue(v.Left); ok && fitsImm16(n) {
+				return n, c.munchExp(v.Right)
+			}
+
+		case ir.MinusIr:
+			if n, ok := ir.ConstValue(v.Right); ok && fitsImm16(-n) {
+				return -n, c.munchExp(v.Left)
+			}
+		}
+	}
+
+	return 0, c.munchExp(addr)
+}
+
+// fitsImm16 reports whether n fits the signed 16-bit offset of lw and sw.
+func fitsImm16(n int32) bool {
+	return n >= -32768 && n < 32768
+}
+
+func (c *CodeGenerator) gen(f func(t ir.Temp)) ir.Temp {
+	t := c.tm.NewTemp()
+	f(t)
+	return t
+}
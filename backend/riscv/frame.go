@@ -0,0 +1,299 @@
+// Package riscv is the RV32IM target: its frames and the selection of its instructions.
+package riscv
+
+import (
+	"fmt"
+	"strings"
+
+	"tiger/ir"
+)
+
+const (
+	WordSize = 4
+)
+
+const (
+	// return address
+	x1 ir.Temp = iota + 1
+
+	// stack pointer
+	x2
+
+	// temporaries t0-t2 - not preserved across call
+	x5
+	x6
+	x7
+
+	// s0, the frame pointer
+	x8
+
+	// s1 - preserved across calls
+	x9
+
+	// function arguments a0-a7, a0 also holds the result of a function
+	x10
+	x11
+	x12
+	x13
+	x14
+	x15
+	x16
+	x17
+
+	// save values s2-s11 - preserved across calls
+	x18
+	x19
+	x20
+	x21
+	x22
+	x23
+	x24
+	x25
+	x26
+	x27
+
+	// temporaries t3-t6 - not preserved across call
+	x28
+	x29
+	x30
+	x31
+)
+
+var (
+	// registers to pass the first eight arguments
+	argRegs = []ir.Temp{x10, x11, x12, x13, x14, x15, x16, x17}
+
+	// callee-saved registers, s0 is saved by the prologue since it is the frame pointer
+	calleeSaves = []ir.Temp{x9, x18, x19, x20, x21, x22, x23, x24, x25, x26, x27}
+
+	// registers trashed by a call
+	callDefs = []ir.Temp{x1, x5, x6, x7, x10, x11, x12, x13, x14, x15, x16, x17, x28, x29, x30, x31}
+
+	tempMap = map[ir.Temp]string{
+		x1:  "ra",
+		x2:  "sp",
+		x5:  "t0",
+		x6:  "t1",
+		x7:  "t2",
+		x8:  "s0",
+		x9:  "s1",
+		x10: "a0",
+		x11: "a1",
+		x12: "a2",
+		x13: "a3",
+		x14: "a4",
+		x15: "a5",
+		x16: "a6",
+		x17: "a7",
+		x18: "s2",
+		x19: "s3",
+		x20: "s4",
+		x21: "s5",
+		x22: "s6",
+		x23: "s7",
+		x24: "s8",
+		x25: "s9",
+		x26: "s10",
+		x27: "s11",
+		x28: "t3",
+		x29: "t4",
+		x30: "t5",
+		x31: "t6",
+	}
+)
+
+func tempName(t ir.Temp) string {
+	v, ok := tempMap[t]
+	if ok {
+		return v
+	}
+
+	return t.String()
+}
+
+type InFrameAccess struct {
+	offset int32
+}
+
+// Exp converts InFrameAccess into ExpIr. The argument is the address of the stack frame that the access lives in.
+func (a *InFrameAccess) Exp(frameAddress ir.ExpIr) ir.ExpIr {
+	return &ir.MemExpIr{Mem: &ir.BinOpExpIr{
+		Binop: ir.PlusIr,
+		Left:  frameAddress,
+		Right: &ir.ConstExpIr{Value: a.offset},
+	}}
+}
+
+type InRegAccess struct {
+	temp ir.Temp
+}
+
+func (a *InRegAccess) Exp(_ ir.ExpIr) ir.ExpIr {
+	return &ir.TempExpIr{Temp: a.temp}
+}
+
+// Frame lays out an RV32 frame, s0 being the value of sp on entry:
+//
+//	4*k(s0)     stack arguments, from the ninth one
+//	-4(s0)      saved s0
+//	-8(s0)      pointer map of the function, which the collector of runtime_riscv.s reads
+//	-4*k(s0)    escaping register arguments, locals and spills
+type Frame struct {
+	tm         *ir.TempManagement
+	name       ir.Label
+	accesses   []ir.FrameAccess
+	shiftInsts []ir.StmIr
+	locals     int32
+
+	// the temps and the offsets from s0 of the words that hold heap pointers
+	ptrTemps   ir.TempSet
+	ptrOffsets []int32
+}
+
+func NewFrame(tm *ir.TempManagement, name ir.Label, escapes []bool) ir.Frame {
+	frame := Frame{
+		tm:       tm,
+		name:     name,
+		locals:   1,
+		ptrTemps: ir.NewTempSet(),
+	}
+
+	frame.createAccesses(escapes)
+	return &frame
+}
+
+func (f *Frame) createAccesses(escapes []bool) {
+	for i, escape := range escapes {
+		// the caller stores the arguments after the eighth one at the bottom of its frame
+		if i >= len(argRegs) {
+			offset := int32(i-len(argRegs)) * WordSize
+			f.accesses = append(f.accesses, &InFrameAccess{offset: offset})
+			continue
+		}
+
+		// the first 8 arguments are passed in registers [a0-a7] and copied to the frame only when they escape
+		acc := f.AllocLocal(escape)
+		f.accesses = append(f.accesses, acc)
+		f.shiftInsts = append(f.shiftInsts, &ir.MoveStmIr{
+			Dst: acc.Exp(&ir.TempExpIr{Temp: x8}),
+			Src: &ir.TempExpIr{Temp: argRegs[i]},
+		})
+	}
+}
+
+func (f *Frame) TempMap() map[ir.Temp]string {
+	return tempMap
+}
+
+func (f *Frame) TempName(t ir.Temp) string {
+	return tempName(t)
+}
+
+func (f *Frame) Name() ir.Label {
+	return f.name
+}
+
+func (f *Frame) Formals() []ir.FrameAccess {
+	return f.accesses
+}
+
+func (f *Frame) AllocLocal(escape bool) ir.FrameAccess {
+	if escape {
+		f.locals++
+		// -4(s0) holds the saved frame pointer
+		return &InFrameAccess{offset: -WordSize * (f.locals + 1)}
+	}
+
+	return &InRegAccess{f.tm.NewTemp()}
+}
+
+func (f *Frame) Pointer(acc ir.FrameAccess) {
+	switch v := acc.(type) {
+	case *InRegAccess:
+		f.ptrTemps.Add(v.temp)
+	case *InFrameAccess:
+		f.ptrOffsets = append(f.ptrOffsets, v.offset)
+	}
+}
+
+// PointerResult has nothing to record, the calls that return heap pointers are marked where they are made.
+func (f *Frame) PointerResult() {}
+
+func (f *Frame) PointerTemps() ir.TempSet {
+	return f.ptrTemps
+}
+
+func (f *Frame) FP() ir.Temp {
+	return x8
+}
+
+func (f *Frame) RV() ir.Temp {
+	return x10
+}
+
+func (f *Frame) CodeGen(tm *ir.TempManagement, stm ir.StmIr) []ir.Instr {
+	return NewCodeGenerator(tm).GenCode(stm)
+}
+
+// ProcEntryExit1 moves the register arguments to where the body expects them and keeps the callee-saved registers,
+// including the return address, in fresh temporaries that are moved back at the end of the body.
+func (f *Frame) ProcEntryExit1(body ir.StmIr) ir.StmIr {
+	calleeSaveRegs := append([]ir.Temp{x1}, calleeSaves...)
+	saved := make([]ir.Temp, len(calleeSaveRegs))
+	saves := make([]ir.StmIr, 0, len(calleeSaveRegs))
+	for i, reg := range calleeSaveRegs {
+		saved[i] = f.tm.NewTemp()
+		saves = append(saves, &ir.MoveStmIr{
+			Dst: &ir.TempExpIr{Temp: saved[i]},
+			Src: &ir.TempExpIr{Temp: reg},
+		})
+	}
+
+	restores := make([]ir.StmIr, 0, len(calleeSaveRegs))
+	for i := len(calleeSaveRegs) - 1; i >= 0; i-- {
+		restores = append(restores, &ir.MoveStmIr{
+			Dst: &ir.TempExpIr{Temp: calleeSaveRegs[i]},
+			Src: &ir.TempExpIr{Temp: saved[i]},
+		})
+	}
+
+	stms := append(append([]ir.StmIr{}, f.shiftInsts...), saves...)
+	stms = append(append(stms, body), restores...)
+	return ir.SeqStm(stms...)
+}
+
+// ProcEntryExit2 notifies the register allocation that a0, ra, sp, s0 and the callee-saved registers are live out at
+// the end of the function.
+func (f *Frame) ProcEntryExit2(body []ir.Instr) []ir.Instr {
+	return append(body, &ir.OperInstr{
+		Src: append([]ir.Temp{x10, x1, x2, x8}, calleeSaves...),
+	})
+}
+
+// ProcEntryExit3 stores the pointer map in the prologue, and clears the locals it lists so that the collector never
+// finds stale words in them. The map follows the function, as the number of the words that hold heap pointers and
+// their offsets from s0.
+func (f *Frame) ProcEntryExit3() (string, string) {
+	// the ABI keeps sp 16-byte aligned
+	name := f.tm.LabelString(f.Name())
+	size := ((f.locals+1)*WordSize + 15) / 16 * 16
+	prolog := fmt.Sprintf("%s:\n\tsw\ts0, -4(sp)\n\tmv\ts0, sp\n\taddi\tsp, sp, -%d\n", name, size)
+	epilog := "\tmv\tsp, s0\n\tlw\ts0, -4(sp)\n\tret\n\n"
+	if len(f.ptrOffsets) == 0 {
+		return prolog + "\tsw\tzero, -8(s0)\n", epilog
+	}
+
+	// t0 holds nothing yet
+	prolog += fmt.Sprintf("\tla\tt0, _map_%s\n\tsw\tt0, -8(s0)\n", name)
+	words := []string{fmt.Sprintf("%d", len(f.ptrOffsets))}
+	for _, offset := range f.ptrOffsets {
+		words = append(words, fmt.Sprintf("%d", offset))
+		if offset < 0 {
+			prolog += fmt.Sprintf("\tsw\tzero, %d(s0)\n", offset)
+		}
+	}
+
+	epilog += fmt.Sprintf("\t.data\n\t.align\t2\n_map_%s:\n\t.word\t%s\n\t.text\n\n", name,
+		strings.Join(words, ", "))
+	return prolog, epilog
+}
@@ -0,0 +1,341 @@
+package riscv
+
+import (
+	"fmt"
+	"strings"
+
+	"tiger/ir"
+)
+
+// CodeGenerator selects RV32IM instructions in the syntax of the GNU assembler. Pseudo instructions such as li, la,
+// mv, call and the branches against zero are used freely; the simulator implements them directly.
+type CodeGenerator struct {
+	tm           *ir.TempManagement
+	instructions []ir.Instr
+}
+
+func NewCodeGenerator(tm *ir.TempManagement) *CodeGenerator {
+	return &CodeGenerator{tm: tm}
+}
+
+func (c *CodeGenerator) GenCode(stm ir.StmIr) []ir.Instr {
+	c.munchStm(stm)
+	return c.instructions
+}
+
+func (c *CodeGenerator) emit(instr ir.Instr) {
+	c.instructions = append(c.instructions, instr)
+}
+
+func (c *CodeGenerator) munchStm(s ir.StmIr) {
+	switch v := s.(type) {
+	case *ir.SeqStmIr:
+		c.munchStm(v.First)
+		c.munchStm(v.Second)
+
+	case *ir.LabelStmIr:
+		c.emit(&ir.LabelInstr{
+			Assem: c.tm.LabelString(v.Label) + ":",
+			Lab:   v.Label,
+		})
+
+	case *ir.MoveStmIr:
+		switch v1 := v.Dst.(type) {
+
+		// Move to memory
+		case *ir.MemExpIr:
+			value := c.munchExp(v.Src)
+			offset, base := c.munchAddr(v1.Mem)
+			c.emit(&ir.OperInstr{
+				Assem: fmt.Sprintf("sw `s0, %d(`s1)", offset),
+				Src:   []ir.Temp{value, base},
+			})
+
+		// Load to register
+		case *ir.TempExpIr:
+			switch v2 := v.Src.(type) {
+			case *ir.ConstExpIr:
+				c.emit(&ir.OperInstr{
+					Assem: fmt.Sprintf("li `d0, %d", v2.Value),
+					Dst:   []ir.Temp{v1.Temp},
+				})
+
+			case *ir.NameExpIr:
+				c.emit(&ir.OperInstr{
+					Assem: "la `d0, " + c.tm.LabelString(v2.Label),
+					Dst:   []ir.Temp{v1.Temp},
+				})
+
+			case *ir.MemExpIr:
+				offset, base := c.munchAddr(v2.Mem)
+				c.emit(&ir.OperInstr{
+					Assem: fmt.Sprintf("lw `d0, %d(`s0)", offset),
+					Dst:   []ir.Temp{v1.Temp},
+					Src:   []ir.Temp{base},
+				})
+
+			case *ir.CallExpIr:
+				c.munchCall(v2)
+				c.emit(&ir.MoveInstr{
+					Assem: "mv `d0, `s0",
+					Dst:   v1.Temp,
+					Src:   x10,
+				})
+
+			// move register to register
+			default:
+				c.emit(&ir.MoveInstr{
+					Assem: "mv `d0, `s0",
+					Dst:   v1.Temp,
+					Src:   c.munchExp(v.Src),
+				})
+			}
+
+		default:
+			panic("invalid instruction arguments")
+		}
+
+	case *ir.JumpStmIr:
+		switch v1 := v.Exp.(type) {
+		case *ir.NameExpIr:
+			c.emit(&ir.OperInstr{
+				Assem: "j `j0",
+				Jumps: []ir.Label{v1.Label},
+			})
+
+		default:
+			c.emit(&ir.OperInstr{
+				Assem: "jr `s0",
+				Src:   []ir.Temp{c.munchExp(v.Exp)},
+				Jumps: v.Labels,
+			})
+		}
+
+	case *ir.CJumpStmIr:
+		relop, left, right := v.Relop, v.Left, v.Right
+		if _, ok := left.(*ir.ConstExpIr); ok {
+			relop, left, right = relop.Commute(), right, left
+		}
+
+		if v1, ok := right.(*ir.ConstExpIr); ok && v1.Value == 0 {
+			c.emit(&ir.OperInstr{
+				Assem: branch(relop) + "z `s0, `j0\n\tj `j1",
+				Src:   []ir.Temp{c.munchExp(left)},
+				Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+			})
+
+			return
+		}
+
+		c.emit(&ir.OperInstr{
+			Assem: branch(relop) + " `s0, `s1, `j0\n\tj `j1",
+			Src:   []ir.Temp{c.munchExp(left), c.munchExp(right)},
+			Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+		})
+
+	case *ir.ExpStmIr:
+		if v1, ok := v.Exp.(*ir.CallExpIr); ok {
+			c.munchCall(v1)
+			return
+		}
+
+		c.munchExp(v.Exp)
+	}
+}
+
+func branch(relop ir.RelOpIr) string {
+	switch relop {
+	case ir.EqIr:
+		return "beq"
+	case ir.NeIr:
+		return "bne"
+	case ir.LtIr:
+		return "blt"
+	case ir.GtIr:
+		return "bgt"
+	case ir.LeIr:
+		return "ble"
+	case ir.GeIr:
+		return "bge"
+	}
+
+	panic("invalid relational operator")
+}
+
+// fitsImm12 reports whether n fits the signed 12-bit immediate of addi, lw and sw.
+func fitsImm12(n int32) bool {
+	return n >= -2048 && n < 2048
+}
+
+// munchAddr splits the address of a MemExpIr into the offset and base register of an off(reg) operand.
+func (c *CodeGenerator) munchAddr(addr ir.ExpIr) (int32, ir.Temp) {
+	if v, ok := addr.(*ir.BinOpExpIr); ok {
+		switch v.Binop {
+		case ir.PlusIr:
+			if n, ok := ir.ConstValue(v.Right); ok && fitsImm12(n) {
+				return n, c.munchExp(v.Left)
+			}
+
+			if n, ok := ir.ConstValue(v.Left); ok && fitsImm12(n) {
+				return n, c.munchExp(v.Right)
+			}
+
+		case ir.MinusIr:
+			if n, ok := ir.ConstValue(v.Right); ok && fitsImm12(-n) {
+				return -n, c.munchExp(v.Left)
+			}
+		}
+	}
+
+	return 0, c.munchExp(addr)
+}
+
+// munchCall passes the first eight arguments in a0-a7 and stores the others at the bottom of a 16-byte aligned area
+// that is popped after the call. The result is left in a0.
+func (c *CodeGenerator) munchCall(call *ir.CallExpIr) {
+	args := make([]ir.Temp, 0, len(call.Args))
+	for _, arg := range call.Args {
+		args = append(args, c.munchExp(arg))
+	}
+
+	stackSize := int32(0)
+	if len(args) > len(argRegs) {
+		stackSize = (int32(len(args)-len(argRegs))*WordSize + 15) / 16 * 16
+		c.emit(&ir.OperInstr{Assem: fmt.Sprintf("addi sp, sp, -%d", stackSize)})
+	}
+
+	for i := len(argRegs); i < len(args); i++ {
+		c.emit(&ir.OperInstr{
+			Assem: fmt.Sprintf("sw `s0, %d(sp)", int32(i-len(argRegs))*WordSize),
+			Src:   []ir.Temp{args[i]},
+		})
+	}
+
+	src := make([]ir.Temp, 0, len(argRegs)+1)
+	for i := 0; i < len(args) && i < len(argRegs); i++ {
+		c.emit(&ir.MoveInstr{
+			Assem: "mv `d0, `s0",
+			Dst:   argRegs[i],
+			Src:   args[i],
+		})
+
+		src = append(src, argRegs[i])
+	}
+
+	if name, ok := call.Exp.(*ir.NameExpIr); ok {
+		c.emit(&ir.OperInstr{
+			Assem: "call " + c.tm.LabelString(name.Label),
+			Dst:   callDefs,
+			Src:   src,
+		})
+	} else {
+		c.emit(&ir.OperInstr{
+			Assem: "jalr `s0",
+			Dst:   callDefs,
+			Src:   append([]ir.Temp{c.munchExp(call.Exp)}, src...),
+		})
+	}
+
+	if stackSize > 0 {
+		c.emit(&ir.OperInstr{Assem: fmt.Sprintf("addi sp, sp, %d", stackSize)})
+	}
+}
+
+func (c *CodeGenerator) munchExp(exp ir.ExpIr) ir.Temp {
+	switch t := exp.(type) {
+	case *ir.CallExpIr:
+		c.munchCall(t)
+		return c.gen(func(temp ir.Temp) {
+			c.emit(&ir.MoveInstr{
+				Assem: "mv `d0, `s0",
+				Dst:   temp,
+				Src:   x10,
+			})
+		})
+
+	case *ir.MemExpIr:
+		return c.gen(func(temp ir.Temp) {
+			offset, base := c.munchAddr(t.Mem)
+			c.emit(&ir.OperInstr{
+				Assem: fmt.Sprintf("lw `d0, %d(`s0)", offset),
+				Dst:   []ir.Temp{temp},
+				Src:   []ir.Temp{base},
+			})
+		})
+
+	case *ir.BinOpExpIr:
+		left, right := t.Left, t.Right
+		if _, ok := left.(*ir.ConstExpIr); ok && t.Binop == ir.PlusIr {
+			left, right = right, left
+		}
+
+		// addi is the only arithmetic instruction with an immediate form in RV32IM
+		if v, ok := right.(*ir.ConstExpIr); ok && (t.Binop == ir.PlusIr || t.Binop == ir.MinusIr) {
+			n := v.Value
+			if t.Binop == ir.MinusIr {
+				n = -n
+			}
+
+			if fitsImm12(n) {
+				return c.gen(func(temp ir.Temp) {
+					c.emit(&ir.OperInstr{
+						Assem: fmt.Sprintf("addi `d0, `s0, %d", n),
+						Dst:   []ir.Temp{temp},
+						Src:   []ir.Temp{c.munchExp(left)},
+					})
+				})
+			}
+		}
+
+		var op string
+		switch t.Binop {
+		case ir.PlusIr:
+			op = "add"
+		case ir.MinusIr:
+			op = "sub"
+		case ir.MulIr:
+			op = "mul"
+		case ir.DivIr:
+			op = "div"
+		default:
+			panic("invalid binary operator")
+		}
+
+		return c.gen(func(temp ir.Temp) {
+			c.emit(&ir.OperInstr{
+				Assem: op + " `d0, `s0, `s1",
+				Dst:   []ir.Temp{temp},
+				Src:   []ir.Temp{c.munchExp(left), c.munchExp(right)},
+			})
+		})
+
+	case *ir.TempExpIr:
+		return t.Temp
+
+	case *ir.ConstExpIr:
+		return c.gen(func(temp ir.Temp) {
+			c.emit(&ir.OperInstr{
+				Assem: fmt.Sprintf("li `d0, %d", t.Value),
+				Dst:   []ir.Temp{temp},
+			})
+		})
+
+	case *ir.NameExpIr:
+		return c.gen(func(temp ir.Temp) {
+			c.emit(&ir.OperInstr{
+				Assem: "la `d0, " + c.tm.LabelString(t.Label),
+				Dst:   []ir.Temp{temp},
+			})
+		})
+	}
+
+	sb := strings.Builder{}
+	exp.PrintExpIr(&sb, c.tm.Strings(), 0)
+	panic("invalid IR exp " + sb.String())
+}
+
+func (c *CodeGenerator) gen(f func(t ir.Temp)) ir.Temp {
+	t := c.tm.NewTemp()
+	f(t)
+	return t
+}
@@ -0,0 +1,38 @@
+package wasm
+
+import (
+	"fmt"
+)
+
+// WebAssembly errors
+func invalidWasmErr(msg string, offset int) error {
+	return fmt.Errorf("invalid wasm module: %s at offset %d", msg, offset)
+}
+
+func unknownWasmImportErr(module, name string) error {
+	return fmt.Errorf("unknown import %s.%s", module, name)
+}
+
+func incompatibleWasmImportErr(module, name string) error {
+	return fmt.Errorf("incompatible import type for %s.%s", module, name)
+}
+
+func undefinedWasmExportErr(name string) error {
+	return fmt.Errorf("undefined export %s", name)
+}
+
+func wasmArgCountErr(name string, expected, actual int) error {
+	return fmt.Errorf("%s takes %d arguments but got %d", name, expected, actual)
+}
+
+func wasmTrapErr(msg string) error {
+	return fmt.Errorf("wasm trap: %s", msg)
+}
+
+func divisionByZeroErr() error {
+	return fmt.Errorf("division by zero")
+}
+
+func stepLimitErr(steps int) error {
+	return fmt.Errorf("program did not terminate after %d instructions", steps)
+}
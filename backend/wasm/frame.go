@@ -0,0 +1,279 @@
+package wasm
+
+import (
+	"fmt"
+	"strings"
+
+	"tiger/ir"
+)
+
+const (
+	WordSize = 4
+)
+
+const (
+	// global 0 is the shadow stack pointer, global 1 the first free byte of the memory, which the host takes the spaces
+	// of the heap from
+	globalSp   = 0
+	globalHeap = 1
+)
+
+const (
+	// base address of the shadow stack frame of the current function
+	fp ir.Temp = iota + 1
+
+	// the value that the function returns
+	rv
+
+	// the id of the block to run next, used by the relooper to dispatch branches
+	dispatch
+)
+
+var (
+	tempMap = map[ir.Temp]string{
+		fp:       "$fp",
+		rv:       "$rv",
+		dispatch: "$label",
+	}
+)
+
+func tempName(t ir.Temp) string {
+	v, ok := tempMap[t]
+	if ok {
+		return v
+	}
+
+	return "$" + t.String()
+}
+
+type InFrameAccess struct {
+	offset int32
+}
+
+// Exp converts InFrameAccess into ExpIr. The argument is the address of the shadow stack frame that the access
+// lives in.
+func (a *InFrameAccess) Exp(frameAddress ir.ExpIr) ir.ExpIr {
+	return &ir.MemExpIr{Mem: &ir.BinOpExpIr{
+		Binop: ir.PlusIr,
+		Left:  frameAddress,
+		Right: &ir.ConstExpIr{Value: a.offset},
+	}}
+}
+
+type InRegAccess struct {
+	temp ir.Temp
+}
+
+func (a *InRegAccess) Exp(_ ir.ExpIr) ir.ExpIr {
+	return &ir.TempExpIr{Temp: a.temp}
+}
+
+// Frame is the frame of a WebAssembly function. Formals are parameters and temps are locals of the function, the
+// engine allocates registers. WebAssembly locals cannot be addressed, so escaping formals and locals live in a frame
+// on a shadow stack in linear memory, growing down from the global $sp. The frames follow each other on the shadow
+// stack, the collector of the host walks them from $sp with their sizes:
+//
+//	0($fp)      size of the frame
+//	4($fp)      pointer map of the function, which the collector reads
+//	4*k($fp)    escaping formals and locals
+type Frame struct {
+	tm         *ir.TempManagement
+	name       ir.Label
+	params     []ir.Temp
+	accesses   []ir.FrameAccess
+	shiftInsts []ir.StmIr
+	locals     int32
+
+	// the temps and the offsets from $fp of the words that hold heap pointers
+	ptrTemps   ir.TempSet
+	ptrOffsets []int32
+
+	// the data of the pointer map, set by Emit when the frame has words that hold heap pointers
+	mapLabel ir.Label
+}
+
+func NewFrame(tm *ir.TempManagement, name ir.Label, escapes []bool) ir.Frame {
+	frame := Frame{
+		tm:       tm,
+		name:     name,
+		locals:   2,
+		ptrTemps: ir.NewTempSet(),
+	}
+
+	frame.createAccesses(escapes)
+	return &frame
+}
+
+func (f *Frame) createAccesses(escapes []bool) {
+	for _, escape := range escapes {
+		param := f.tm.NewTemp()
+		f.params = append(f.params, param)
+		if !escape {
+			f.accesses = append(f.accesses, &InRegAccess{temp: param})
+			continue
+		}
+
+		acc := f.AllocLocal(true)
+		f.accesses = append(f.accesses, acc)
+		f.shiftInsts = append(f.shiftInsts, &ir.MoveStmIr{
+			Dst: acc.Exp(&ir.TempExpIr{Temp: fp}),
+			Src: &ir.TempExpIr{Temp: param},
+		})
+	}
+}
+
+func (f *Frame) TempMap() map[ir.Temp]string {
+	return tempMap
+}
+
+func (f *Frame) TempName(t ir.Temp) string {
+	return tempName(t)
+}
+
+func (f *Frame) Name() ir.Label {
+	return f.name
+}
+
+func (f *Frame) Formals() []ir.FrameAccess {
+	return f.accesses
+}
+
+func (f *Frame) AllocLocal(escape bool) ir.FrameAccess {
+	if escape {
+		f.locals++
+		return &InFrameAccess{offset: WordSize * (f.locals - 1)}
+	}
+
+	return &InRegAccess{f.tm.NewTemp()}
+}
+
+func (f *Frame) Pointer(acc ir.FrameAccess) {
+	switch v := acc.(type) {
+	case *InRegAccess:
+		f.ptrTemps.Add(v.temp)
+	case *InFrameAccess:
+		f.ptrOffsets = append(f.ptrOffsets, v.offset)
+	}
+}
+
+// PointerResult has nothing to record, the calls that return heap pointers are marked where they are made.
+func (f *Frame) PointerResult() {}
+
+func (f *Frame) PointerTemps() ir.TempSet {
+	return f.ptrTemps
+}
+
+func (f *Frame) ParamTemps() []ir.Temp {
+	return f.params
+}
+
+func (f *Frame) FrameWords() int32 {
+	return f.locals
+}
+
+func (f *Frame) FP() ir.Temp {
+	return fp
+}
+
+func (f *Frame) RV() ir.Temp {
+	return rv
+}
+
+// CodeGen returns the instructions of stm as text. Branches are only placed by the relooper when the module is
+// emitted, so labels and jumps are kept as comments.
+func (f *Frame) CodeGen(tm *ir.TempManagement, stm ir.StmIr) []ir.Instr {
+	return NewCodeGenerator(tm).GenCode(stm)
+}
+
+// ProcEntryExit1 copies the escaping parameters into the shadow stack frame. There are no registers to save.
+func (f *Frame) ProcEntryExit1(body ir.StmIr) ir.StmIr {
+	return ir.SeqStm(append(append([]ir.StmIr{}, f.shiftInsts...), body)...)
+}
+
+// ProcEntryExit2 has nothing to do since WebAssembly has no registers to allocate.
+func (f *Frame) ProcEntryExit2(body []ir.Instr) []ir.Instr {
+	return body
+}
+
+func (f *Frame) ProcEntryExit3() (string, string) {
+	sb := strings.Builder{}
+	sb.WriteString("(func $" + f.tm.LabelString(f.name))
+	for _, param := range f.params {
+		sb.WriteString(" (param " + tempName(param) + " i32)")
+	}
+
+	sb.WriteString(" (result i32)\n")
+	for _, instr := range f.prolog() {
+		sb.WriteString("\t" + instr.format(f.tm) + "\n")
+	}
+
+	prolog := sb.String()
+	sb.Reset()
+	for _, instr := range f.epilog() {
+		sb.WriteString("\t" + instr.format(f.tm) + "\n")
+	}
+
+	sb.WriteString(")\n\n")
+	return prolog, sb.String()
+}
+
+// frameSize keeps $sp 16-byte aligned.
+func (f *Frame) frameSize() int64 {
+	return int64((f.locals*WordSize + 15) / 16 * 16)
+}
+
+// prolog pushes the frame on the shadow stack: $fp = $sp = $sp - size. It stores the size and the pointer map, and
+// clears the words the map lists so that the collector never finds stale words in them.
+func (f *Frame) prolog() []*wasmInstr {
+	instrs := []*wasmInstr{
+		{op: wasmGlobalGet, imm: globalSp},
+		{op: wasmI32Const, imm: f.frameSize()},
+		{op: wasmI32Sub},
+		{op: wasmLocalTee, temp: fp},
+		{op: wasmGlobalSet, imm: globalSp},
+		{op: wasmLocalGet, temp: fp},
+		{op: wasmI32Const, imm: f.frameSize()},
+		{op: wasmI32Store},
+		{op: wasmLocalGet, temp: fp},
+		{op: wasmI32Const, label: f.mapLabel},
+		{op: wasmI32Store, imm: WordSize},
+	}
+
+	for _, offset := range f.ptrOffsets {
+		instrs = append(instrs,
+			&wasmInstr{op: wasmLocalGet, temp: fp},
+			&wasmInstr{op: wasmI32Const},
+			&wasmInstr{op: wasmI32Store, imm: int64(offset)},
+		)
+	}
+
+	return instrs
+}
+
+// epilog pops the frame and leaves the return value on the stack.
+func (f *Frame) epilog() []*wasmInstr {
+	return []*wasmInstr{
+		{op: wasmLocalGet, temp: fp},
+		{op: wasmI32Const, imm: f.frameSize()},
+		{op: wasmI32Add},
+		{op: wasmGlobalSet, imm: globalSp},
+		{op: wasmLocalGet, temp: rv},
+	}
+}
+
+// StringFrag writes a string fragment as a WebAssembly text data segment. The binary module places the strings
+// itself, this is only used to print the module.
+func StringFrag(tm *ir.TempManagement, sb *strings.Builder, frag *ir.StrFrag) string {
+	sb.WriteString("(data $" + tm.LabelString(frag.Label) + " \"")
+	for i := 0; i < len(frag.Str); i++ {
+		c := frag.Str[i]
+		if c >= ' ' && c < 127 && c != '"' && c != '\\' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(sb, "\\%02x", c)
+		}
+	}
+
+	sb.WriteString("\\00\")\n")
+	return sb.String()
+}
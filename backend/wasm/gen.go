@@ -0,0 +1,522 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"tiger/ir"
+)
+
+// CodeGenerator turns canonical statements into WebAssembly stack code. Temps become locals of the function, so
+// there is nothing to allocate. Labels and jumps are not translated here: the relooper places the blocks and
+// emits their branches.
+type CodeGenerator struct {
+	tm     *ir.TempManagement
+	instrs []*wasmInstr
+}
+
+func NewCodeGenerator(tm *ir.TempManagement) *CodeGenerator {
+	return &CodeGenerator{tm: tm}
+}
+
+// GenCode returns the instructions of stm as text, one OperInstr per WebAssembly instruction.
+func (c *CodeGenerator) GenCode(stm ir.StmIr) []ir.Instr {
+	var instrs []ir.Instr
+	switch v := stm.(type) {
+	case *ir.LabelStmIr:
+		return []ir.Instr{&ir.LabelInstr{Assem: ";; " + c.tm.LabelString(v.Label) + ":", Lab: v.Label}}
+	case *ir.JumpStmIr:
+		label := wasmJumpLabel(v)
+		return []ir.Instr{&ir.OperInstr{Assem: ";; goto " + c.tm.LabelString(label), Jumps: []ir.Label{label}}}
+	case *ir.CJumpStmIr:
+		c.munchCond(v)
+		for _, instr := range c.instrs {
+			instrs = append(instrs, &ir.OperInstr{Assem: instr.format(c.tm)})
+		}
+
+		return append(instrs, &ir.OperInstr{
+			Assem: fmt.Sprintf(";; if goto %s else goto %s", c.tm.LabelString(v.TrueLabel),
+				c.tm.LabelString(v.FalseLabel)),
+			Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+		})
+	}
+
+	c.munchStm(stm)
+	for _, instr := range c.instrs {
+		instrs = append(instrs, &ir.OperInstr{Assem: instr.format(c.tm)})
+	}
+
+	return instrs
+}
+
+func (c *CodeGenerator) emit(instrs ...*wasmInstr) {
+	c.instrs = append(c.instrs, instrs...)
+}
+
+// munchStm translates a statement that does not branch.
+func (c *CodeGenerator) munchStm(s ir.StmIr) {
+	switch v := s.(type) {
+	case *ir.SeqStmIr:
+		c.munchStm(v.First)
+		c.munchStm(v.Second)
+
+	case *ir.MoveStmIr:
+		switch v1 := v.Dst.(type) {
+		case *ir.MemExpIr:
+			offset := c.munchAddr(v1.Mem)
+			c.munchExp(v.Src)
+			c.emit(&wasmInstr{op: wasmI32Store, imm: offset})
+
+		case *ir.TempExpIr:
+			c.munchExp(v.Src)
+			c.emit(&wasmInstr{op: wasmLocalSet, temp: v1.Temp})
+
+		default:
+			panic("invalid instruction arguments")
+		}
+
+	case *ir.ExpStmIr:
+		// only calls have side effects once the tree is canonical
+		if _, ok := v.Exp.(*ir.CallExpIr); ok {
+			c.munchExp(v.Exp)
+			c.emit(&wasmInstr{op: wasmDrop})
+		}
+
+	default:
+		panic("the relooper places labels and jumps")
+	}
+}
+
+// munchCond pushes 1 if the condition of a conditional jump holds and 0 otherwise.
+func (c *CodeGenerator) munchCond(s *ir.CJumpStmIr) {
+	c.munchExp(s.Left)
+	if v, ok := s.Right.(*ir.ConstExpIr); ok && v.Value == 0 && (s.Relop == ir.EqIr || s.Relop == ir.NeIr) {
+		c.emit(&wasmInstr{op: wasmI32Eqz})
+		if s.Relop == ir.NeIr {
+			c.emit(&wasmInstr{op: wasmI32Eqz})
+		}
+
+		return
+	}
+
+	c.munchExp(s.Right)
+	c.emit(&wasmInstr{op: wasmRelOp(s.Relop)})
+}
+
+func wasmRelOp(relop ir.RelOpIr) byte {
+	switch relop {
+	case ir.EqIr:
+		return wasmI32Eq
+	case ir.NeIr:
+		return wasmI32Ne
+	case ir.LtIr:
+		return wasmI32LtS
+	case ir.GtIr:
+		return wasmI32GtS
+	case ir.LeIr:
+		return wasmI32LeS
+	case ir.GeIr:
+		return wasmI32GeS
+	}
+
+	panic("invalid relational operator")
+}
+
+func wasmBinOp(binop ir.BinOpIr) byte {
+	switch binop {
+	case ir.PlusIr:
+		return wasmI32Add
+	case ir.MinusIr:
+		return wasmI32Sub
+	case ir.MulIr:
+		return wasmI32Mul
+	case ir.DivIr:
+		return wasmI32DivS
+	}
+
+	panic("invalid binary operator")
+}
+
+// munchAddr pushes the address of a memory access and returns the constant offset that the load or store adds to it.
+// The offset of a WebAssembly memory access is unsigned, so only non-negative constants are folded into it.
+func (c *CodeGenerator) munchAddr(exp ir.ExpIr) int64 {
+	if v, ok := exp.(*ir.BinOpExpIr); ok && v.Binop == ir.PlusIr {
+		if k, ok := v.Right.(*ir.ConstExpIr); ok && k.Value >= 0 {
+			c.munchExp(v.Left)
+			return int64(k.Value)
+		}
+
+		if k, ok := v.Left.(*ir.ConstExpIr); ok && k.Value >= 0 {
+			c.munchExp(v.Right)
+			return int64(k.Value)
+		}
+	}
+
+	c.munchExp(exp)
+	return 0
+}
+
+func (c *CodeGenerator) munchExp(exp ir.ExpIr) {
+	switch t := exp.(type) {
+	case *ir.BinOpExpIr:
+		c.munchExp(t.Left)
+		c.munchExp(t.Right)
+		c.emit(&wasmInstr{op: wasmBinOp(t.Binop)})
+
+	case *ir.MemExpIr:
+		offset := c.munchAddr(t.Mem)
+		c.emit(&wasmInstr{op: wasmI32Load, imm: offset})
+
+	case *ir.TempExpIr:
+		c.emit(&wasmInstr{op: wasmLocalGet, temp: t.Temp})
+
+	case *ir.ConstExpIr:
+		c.emit(&wasmInstr{op: wasmI32Const, imm: int64(t.Value)})
+
+	case *ir.NameExpIr:
+		c.emit(&wasmInstr{op: wasmI32Const, label: t.Label})
+
+	case *ir.CallExpIr:
+		for _, arg := range t.Args {
+			c.munchExp(arg)
+		}
+
+		if name, ok := t.Exp.(*ir.NameExpIr); ok {
+			c.emit(&wasmInstr{op: wasmCall, label: name.Label})
+			return
+		}
+
+		// the callee is the index of a function in the table, e.g. a method read from a vtable
+		c.munchExp(t.Exp)
+		c.emit(&wasmInstr{op: wasmCallIndirect, imm: int64(len(t.Args))})
+
+	default:
+		sb := strings.Builder{}
+		exp.PrintExpIr(&sb, c.tm.Strings(), 0)
+		panic("invalid IR exp " + sb.String())
+	}
+}
+
+func wasmJumpLabel(s *ir.JumpStmIr) ir.Label {
+	v, ok := s.Exp.(*ir.NameExpIr)
+	if !ok {
+		panic("the wasm backend only supports jumps to labels")
+	}
+
+	return v.Label
+}
+
+const (
+	// the strings start here, which keeps nil pointers away from them
+	wasmDataBase = 1024
+
+	// the shadow stack is placed after the strings and grows down, the heap starts at its top
+	wasmStackSize = 1 << 20
+)
+
+// Emit writes the whole program as a binary WebAssembly module. The runtime functions are imported from the
+// "tiger" module of the host, see runtime/src/runtime_wasm.js and VM. The module exports its memory, the heap
+// global that the host takes the spaces of its heap from, the shadow stack pointer and main. The functions of the
+// table fragments are placed in the table of the module, from index 1 so that a call through a nil vtable traps, and
+// the table fragments hold their indices. The host collects garbage, so the heap pointers that are live across calls
+// are moved into the shadow stack frames, where it finds them with the pointer maps that follow the tables.
+func Emit(tm *ir.TempManagement, frags []ir.Frag) []byte {
+	var (
+		procs  []*ir.ProcFrag
+		strs   []*ir.StrFrag
+		tables []*ir.TableFrag
+	)
+
+	for _, frag := range frags {
+		switch f := frag.(type) {
+		case *ir.ProcFrag:
+			procs = append(procs, f)
+		case *ir.TableFrag:
+			tables = append(tables, f)
+		default:
+			strs = append(strs, frag.(*ir.StrFrag))
+		}
+	}
+
+	bodies := make([][]*wasmInstr, len(procs))
+	callees := make(map[ir.Label]int)
+	for i, proc := range procs {
+		canon := ir.NewCanon(tm)
+		stms, _ := canon.Linearize(proc.Body)
+		stms = ir.SpillPointers(tm, proc.Frame.(*Frame), stms)
+		blocks, done := canon.BasicBlocks(stms)
+		for _, block := range blocks {
+			for _, stm := range block {
+				ir.CollectCallees(stm, callees)
+			}
+		}
+
+		bodies[i] = reloopFunction(tm, blocks, done)
+	}
+
+	for _, proc := range procs {
+		delete(callees, proc.Frame.Name())
+	}
+
+	imports := make([]ir.Label, 0, len(callees))
+	for label := range callees {
+		imports = append(imports, label)
+	}
+
+	// the map gives a random order, keep the output stable
+	sort.Slice(imports, func(i, j int) bool {
+		return tm.LabelString(imports[i]) < tm.LabelString(imports[j])
+	})
+
+	// every function takes and returns i32 only, so a type is identified by its number of parameters
+	var arities []int
+	typeOf := func(arity int) uint32 {
+		for i, v := range arities {
+			if v == arity {
+				return uint32(i)
+			}
+		}
+
+		arities = append(arities, arity)
+		return uint32(len(arities) - 1)
+	}
+
+	linker := &wasmLinker{
+		funcs:  make(map[ir.Label]uint32),
+		data:   make(map[ir.Label]int32),
+		typeOf: typeOf,
+	}
+
+	importTypes := make([]uint32, len(imports))
+	for i, label := range imports {
+		linker.funcs[label] = uint32(i)
+		importTypes[i] = typeOf(callees[label])
+	}
+
+	procTypes := make([]uint32, len(procs))
+	for i, proc := range procs {
+		linker.funcs[proc.Frame.Name()] = uint32(len(imports) + i)
+		procTypes[i] = typeOf(len(proc.Frame.(*Frame).params))
+	}
+
+	// the types of the indirect calls must be known before the type section is written
+	for _, body := range bodies {
+		for _, instr := range body {
+			if instr.op == wasmCallIndirect {
+				typeOf(int(instr.imm))
+			}
+		}
+	}
+
+	var elems []ir.Label
+	elemIndices := make(map[ir.Label]int32)
+	for _, table := range tables {
+		for _, label := range table.Labels {
+			if _, ok := elemIndices[label]; !ok {
+				elems = append(elems, label)
+				elemIndices[label] = int32(len(elems))
+			}
+		}
+	}
+
+	data := bytes.Buffer{}
+	addr := int32(wasmDataBase)
+	for _, str := range strs {
+		linker.data[str.Label] = addr
+		writeULEB(&data, 0)
+		data.WriteByte(wasmI32Const)
+		writeSLEB(&data, int64(addr))
+		data.WriteByte(wasmEnd)
+		writeULEB(&data, uint64(len(str.Str)+1))
+		data.WriteString(str.Str)
+		data.WriteByte(0)
+		addr += (int32(len(str.Str)) + 1 + 3) / 4 * 4
+	}
+
+	for _, table := range tables {
+		linker.data[table.Label] = addr
+		writeULEB(&data, 0)
+		data.WriteByte(wasmI32Const)
+		writeSLEB(&data, int64(addr))
+		data.WriteByte(wasmEnd)
+		writeULEB(&data, uint64(len(table.Labels)*WordSize))
+		for _, label := range table.Labels {
+			index := elemIndices[label]
+			data.Write([]byte{byte(index), byte(index >> 8), byte(index >> 16), byte(index >> 24)})
+		}
+
+		addr += int32(len(table.Labels)) * WordSize
+	}
+
+	maps := 0
+	for _, proc := range procs {
+		frame := proc.Frame.(*Frame)
+		if len(frame.ptrOffsets) == 0 {
+			continue
+		}
+
+		frame.mapLabel = tm.NamedLabel("_map_" + tm.LabelString(frame.name))
+		linker.data[frame.mapLabel] = addr
+		writeULEB(&data, 0)
+		data.WriteByte(wasmI32Const)
+		writeSLEB(&data, int64(addr))
+		data.WriteByte(wasmEnd)
+		words := append([]int32{int32(len(frame.ptrOffsets))}, frame.ptrOffsets...)
+		writeULEB(&data, uint64(len(words)*WordSize))
+		for _, word := range words {
+			data.Write([]byte{byte(word), byte(word >> 8), byte(word >> 16), byte(word >> 24)})
+		}
+
+		addr += int32(len(words)) * WordSize
+		maps++
+	}
+
+	stackTop := (addr+15)/16*16 + wasmStackSize
+	out := bytes.Buffer{}
+	out.WriteString(wasmMagic)
+	out.Write([]byte{wasmVersion, 0, 0, 0})
+
+	sec := bytes.Buffer{}
+	writeULEB(&sec, uint64(len(arities)))
+	for _, arity := range arities {
+		sec.WriteByte(wasmFuncType)
+		writeULEB(&sec, uint64(arity))
+		for i := 0; i < arity; i++ {
+			sec.WriteByte(wasmI32)
+		}
+
+		writeULEB(&sec, 1)
+		sec.WriteByte(wasmI32)
+	}
+
+	writeWasmSection(&out, wasmSectionType, &sec)
+
+	sec.Reset()
+	writeULEB(&sec, uint64(len(imports)))
+	for i, label := range imports {
+		writeWasmName(&sec, "tiger")
+		writeWasmName(&sec, tm.LabelString(label))
+		sec.WriteByte(wasmExternFunc)
+		writeULEB(&sec, uint64(importTypes[i]))
+	}
+
+	writeWasmSection(&out, wasmSectionImport, &sec)
+
+	sec.Reset()
+	writeULEB(&sec, uint64(len(procs)))
+	for _, typ := range procTypes {
+		writeULEB(&sec, uint64(typ))
+	}
+
+	writeWasmSection(&out, wasmSectionFunc, &sec)
+
+	if len(elems) > 0 {
+		sec.Reset()
+		writeULEB(&sec, 1)
+		sec.Write([]byte{wasmFuncRef, 0})
+		writeULEB(&sec, uint64(len(elems)+1))
+		writeWasmSection(&out, wasmSectionTable, &sec)
+	}
+
+	sec.Reset()
+	writeULEB(&sec, 1)
+	sec.WriteByte(0)
+	writeULEB(&sec, uint64((stackTop+wasmPageSize-1)/wasmPageSize))
+	writeWasmSection(&out, wasmSectionMemory, &sec)
+
+	sec.Reset()
+	writeULEB(&sec, 2)
+	for range []int{globalSp, globalHeap} {
+		sec.Write([]byte{wasmI32, 1, wasmI32Const})
+		writeSLEB(&sec, int64(stackTop))
+		sec.WriteByte(wasmEnd)
+	}
+
+	writeWasmSection(&out, wasmSectionGlobal, &sec)
+
+	sec.Reset()
+	writeULEB(&sec, 4)
+	writeWasmName(&sec, "memory")
+	sec.WriteByte(wasmExternMemory)
+	writeULEB(&sec, 0)
+	writeWasmName(&sec, "heap")
+	sec.WriteByte(wasmExternGlobal)
+	writeULEB(&sec, globalHeap)
+	writeWasmName(&sec, "sp")
+	sec.WriteByte(wasmExternGlobal)
+	writeULEB(&sec, globalSp)
+	writeWasmName(&sec, "main")
+	sec.WriteByte(wasmExternFunc)
+	writeULEB(&sec, uint64(linker.funcs[tm.NamedLabel("main")]))
+	writeWasmSection(&out, wasmSectionExport, &sec)
+
+	if len(elems) > 0 {
+		sec.Reset()
+		writeULEB(&sec, 1)
+		writeULEB(&sec, 0)
+		sec.Write([]byte{wasmI32Const, 1, wasmEnd})
+		writeULEB(&sec, uint64(len(elems)))
+		for _, label := range elems {
+			writeULEB(&sec, uint64(linker.funcs[label]))
+		}
+
+		writeWasmSection(&out, wasmSectionElem, &sec)
+	}
+
+	sec.Reset()
+	writeULEB(&sec, uint64(len(procs)))
+	for i, proc := range procs {
+		frame := proc.Frame.(*Frame)
+		instrs := append(append(frame.prolog(), bodies[i]...), frame.epilog()...)
+		locals := wasmLocals(frame, instrs)
+		linker.locals = make(map[ir.Temp]uint32)
+		for j, temp := range frame.params {
+			linker.locals[temp] = uint32(j)
+		}
+
+		for j, temp := range locals {
+			linker.locals[temp] = uint32(len(frame.params) + j)
+		}
+
+		code := bytes.Buffer{}
+		if len(locals) > 0 {
+			writeULEB(&code, 1)
+			writeULEB(&code, uint64(len(locals)))
+			code.WriteByte(wasmI32)
+		} else {
+			writeULEB(&code, 0)
+		}
+
+		linker.encode(&code, instrs)
+		code.WriteByte(wasmEnd)
+		writeULEB(&sec, uint64(code.Len()))
+		sec.Write(code.Bytes())
+	}
+
+	writeWasmSection(&out, wasmSectionCode, &sec)
+
+	sec.Reset()
+	writeULEB(&sec, uint64(len(strs)+len(tables)+maps))
+	sec.Write(data.Bytes())
+	writeWasmSection(&out, wasmSectionData, &sec)
+	return out.Bytes()
+}
+
+// wasmLocals lists the temps that the instructions use, except the parameters of the frame.
+func wasmLocals(frame *Frame, instrs []*wasmInstr) []ir.Temp {
+	declared := ir.NewTempSet(frame.params...)
+	var locals []ir.Temp
+	for _, instr := range instrs {
+		switch instr.op {
+		case wasmLocalGet, wasmLocalSet, wasmLocalTee:
+			if !declared.Has(instr.temp) {
+				declared.Add(instr.temp)
+				locals = append(locals, instr.temp)
+			}
+		}
+	}
+
+	return locals
+}
@@ -0,0 +1,463 @@
+package wasm
+
+import (
+	"sort"
+
+	"tiger/ir"
+)
+
+// WebAssembly has no goto: a branch can only leave a block or restart a loop that encloses it. The relooper
+// (Zakai, "Emscripten: an LLVM-to-JavaScript compiler", 2011) rebuilds structured control flow from the basic blocks
+// of a function as a tree of shapes:
+//
+//   - a simple shape runs one block and then the shape after it,
+//   - a loop shape repeats its inner shape, whose entries are reached again with a continue,
+//   - a multiple shape picks one of several independent shapes by the value of the $label local.
+//
+// Every branch stores the id of its target in $label. It then either falls through, when the code that follows is
+// the target, or breaks out of (or continues) the enclosing shape whose entry is the target.
+
+// wasmBasicBlock is a basic block of the canonical tree: the statements after the label, without the final jump.
+type wasmBasicBlock struct {
+	label ir.Label
+	id    int
+	body  []ir.StmIr
+	jump  ir.StmIr
+
+	// the branches that the relooper has not turned into breaks or continues yet
+	in, out map[*wasmBasicBlock]bool
+}
+
+type wasmBlockSet map[*wasmBasicBlock]bool
+
+// sorted returns the blocks in the order they appear in the function, so that the output does not depend on the
+// order of the map.
+func (s wasmBlockSet) sorted() []*wasmBasicBlock {
+	blocks := make([]*wasmBasicBlock, 0, len(s))
+	for b := range s {
+		blocks = append(blocks, b)
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].id < blocks[j].id
+	})
+
+	return blocks
+}
+
+type wasmShape interface {
+	entries() []*wasmBasicBlock
+}
+
+type wasmSimpleShape struct {
+	block *wasmBasicBlock
+	next  wasmShape
+}
+
+func (s *wasmSimpleShape) entries() []*wasmBasicBlock {
+	return []*wasmBasicBlock{s.block}
+}
+
+type wasmLoopShape struct {
+	entry []*wasmBasicBlock
+	inner wasmShape
+	next  wasmShape
+}
+
+func (s *wasmLoopShape) entries() []*wasmBasicBlock {
+	return s.entry
+}
+
+type wasmMultipleShape struct {
+	entry   []*wasmBasicBlock
+	handled []wasmShape
+	next    wasmShape
+}
+
+func (s *wasmMultipleShape) entries() []*wasmBasicBlock {
+	return s.entry
+}
+
+// wasmBlocks splits the statements of a function into blocks, canon.BasicBlocks puts a label at the start of each
+// one and a jump at the end. It returns the blocks by label along with the entry block.
+func wasmBlocks(stms [][]ir.StmIr) (map[ir.Label]*wasmBasicBlock, *wasmBasicBlock) {
+	blocks := make(map[ir.Label]*wasmBasicBlock)
+	var all []*wasmBasicBlock
+	for i, stms := range stms {
+		b := &wasmBasicBlock{
+			label: stms[0].(*ir.LabelStmIr).Label,
+			id:    i + 1,
+			body:  stms[1 : len(stms)-1],
+			jump:  stms[len(stms)-1],
+			in:    make(map[*wasmBasicBlock]bool),
+			out:   make(map[*wasmBasicBlock]bool),
+		}
+
+		blocks[b.label] = b
+		all = append(all, b)
+	}
+
+	for _, b := range all {
+		for _, label := range wasmJumpTargets(b.jump) {
+			if target, ok := blocks[label]; ok {
+				b.out[target] = true
+				target.in[b] = true
+			}
+		}
+	}
+
+	return blocks, all[0]
+}
+
+func wasmJumpTargets(s ir.StmIr) []ir.Label {
+	switch v := s.(type) {
+	case *ir.JumpStmIr:
+		return []ir.Label{wasmJumpLabel(v)}
+	case *ir.CJumpStmIr:
+		return []ir.Label{v.TrueLabel, v.FalseLabel}
+	}
+
+	panic("a basic block must end with a jump")
+}
+
+// removeBranch records that the branch from b to target is handled by a break or a continue.
+func removeBranch(b, target *wasmBasicBlock) {
+	delete(b.out, target)
+	delete(target.in, b)
+}
+
+// reach returns the blocks of set that can be reached from the entries through the remaining branches.
+func reach(set wasmBlockSet, entries ...*wasmBasicBlock) wasmBlockSet {
+	seen := make(wasmBlockSet)
+	stack := append([]*wasmBasicBlock{}, entries...)
+	for len(stack) > 0 {
+		b := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[b] || !set[b] {
+			continue
+		}
+
+		seen[b] = true
+		for s := range b.out {
+			stack = append(stack, s)
+		}
+	}
+
+	return seen
+}
+
+// reloop builds the shape of the blocks of set, which control enters at entries.
+func reloop(set wasmBlockSet, entries []*wasmBasicBlock) wasmShape {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// blocks that cannot be reached anymore are dead code
+	set = reach(set, entries...)
+
+	if len(entries) == 1 {
+		entry := entries[0]
+		if !wasmHasBranchFrom(entry, set) {
+			rest := make(wasmBlockSet)
+			for b := range set {
+				if b != entry {
+					rest[b] = true
+				}
+			}
+
+			var next []*wasmBasicBlock
+			for _, s := range wasmBlockSet(entry.out).sorted() {
+				removeBranch(entry, s)
+				next = append(next, s)
+			}
+
+			return &wasmSimpleShape{block: entry, next: reloop(rest, next)}
+		}
+	}
+
+	if len(entries) > 1 {
+		if shape := reloopMultiple(set, entries); shape != nil {
+			return shape
+		}
+	}
+
+	return reloopLoop(set, entries)
+}
+
+func wasmHasBranchFrom(b *wasmBasicBlock, set wasmBlockSet) bool {
+	for pred := range b.in {
+		if set[pred] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reloopMultiple finds the entries that own a group of blocks, those that no other entry reaches. It returns nil
+// when no entry owns one.
+func reloopMultiple(set wasmBlockSet, entries []*wasmBasicBlock) wasmShape {
+	reached := make([]wasmBlockSet, len(entries))
+	for i, entry := range entries {
+		reached[i] = reach(set, entry)
+	}
+
+	groups := make([]wasmBlockSet, len(entries))
+	found := false
+	for i := range entries {
+		group := make(wasmBlockSet)
+		for b := range reached[i] {
+			shared := false
+			for j := range entries {
+				if j != i && reached[j][b] {
+					shared = true
+					break
+				}
+			}
+
+			if !shared {
+				group[b] = true
+			}
+		}
+
+		if group[entries[i]] {
+			groups[i] = group
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	shape := &wasmMultipleShape{entry: entries}
+	rest := make(wasmBlockSet)
+	for b := range set {
+		rest[b] = true
+	}
+
+	var next []*wasmBasicBlock
+	for i, entry := range entries {
+		group := groups[i]
+		if group == nil {
+			next = appendBlock(next, entry)
+			continue
+		}
+
+		for _, b := range group.sorted() {
+			delete(rest, b)
+			for _, s := range wasmBlockSet(b.out).sorted() {
+				if !group[s] {
+					removeBranch(b, s)
+					next = appendBlock(next, s)
+				}
+			}
+		}
+	}
+
+	for i, entry := range entries {
+		if groups[i] != nil {
+			shape.handled = append(shape.handled, reloop(groups[i], []*wasmBasicBlock{entry}))
+		}
+	}
+
+	shape.next = reloop(rest, next)
+	return shape
+}
+
+// reloopLoop puts the blocks that can go back to an entry in a loop. The branches to the entries become
+// continues and the branches leaving the loop become breaks.
+func reloopLoop(set wasmBlockSet, entries []*wasmBasicBlock) wasmShape {
+	inner := make(wasmBlockSet)
+	for _, b := range set.sorted() {
+		for _, entry := range entries {
+			if reach(set, b)[entry] {
+				inner[b] = true
+				break
+			}
+		}
+	}
+
+	isEntry := make(wasmBlockSet)
+	for _, entry := range entries {
+		isEntry[entry] = true
+	}
+
+	outer := make(wasmBlockSet)
+	for b := range set {
+		if !inner[b] {
+			outer[b] = true
+		}
+	}
+
+	var next []*wasmBasicBlock
+	for _, b := range inner.sorted() {
+		for _, s := range wasmBlockSet(b.out).sorted() {
+			if isEntry[s] {
+				removeBranch(b, s)
+			} else if !inner[s] {
+				removeBranch(b, s)
+				next = appendBlock(next, s)
+			}
+		}
+	}
+
+	return &wasmLoopShape{
+		entry: entries,
+		inner: reloop(inner, entries),
+		next:  reloop(outer, next),
+	}
+}
+
+func appendBlock(blocks []*wasmBasicBlock, b *wasmBasicBlock) []*wasmBasicBlock {
+	for _, v := range blocks {
+		if v == b {
+			return blocks
+		}
+	}
+
+	return append(blocks, b)
+}
+
+// wasmContext is a block or loop that encloses the code being emitted, along with the blocks that a br to it
+// reaches: the shape after a block, the entries of a loop.
+type wasmContext struct {
+	targets map[ir.Label]bool
+	depth   int
+}
+
+// wasmReloopEmitter writes the shapes of one function.
+type wasmReloopEmitter struct {
+	gen    *CodeGenerator
+	blocks map[ir.Label]*wasmBasicBlock
+	ctxs   []wasmContext
+	depth  int
+}
+
+func wasmEntryLabels(shape wasmShape, follow map[ir.Label]bool) map[ir.Label]bool {
+	if shape == nil {
+		return follow
+	}
+
+	labels := make(map[ir.Label]bool)
+	for _, b := range shape.entries() {
+		labels[b.label] = true
+	}
+
+	return labels
+}
+
+// open starts a block or a loop that branches to targets.
+func (e *wasmReloopEmitter) open(op byte, targets map[ir.Label]bool) {
+	e.gen.emit(&wasmInstr{op: op})
+	e.depth++
+	e.ctxs = append(e.ctxs, wasmContext{targets: targets, depth: e.depth})
+}
+
+func (e *wasmReloopEmitter) close() {
+	e.gen.emit(&wasmInstr{op: wasmEnd})
+	e.depth--
+	e.ctxs = e.ctxs[:len(e.ctxs)-1]
+}
+
+// emitShape writes shape, after which control goes on to one of the blocks of follow.
+func (e *wasmReloopEmitter) emitShape(shape wasmShape, follow map[ir.Label]bool) {
+	switch s := shape.(type) {
+	case nil:
+		return
+
+	case *wasmSimpleShape:
+		for _, stm := range s.block.body {
+			e.gen.munchStm(stm)
+		}
+
+		next := wasmEntryLabels(s.next, follow)
+		switch v := s.block.jump.(type) {
+		case *ir.JumpStmIr:
+			e.emitBranch(wasmJumpLabel(v), next)
+		case *ir.CJumpStmIr:
+			e.gen.munchCond(v)
+			e.gen.emit(&wasmInstr{op: wasmIf})
+			e.depth++
+			e.emitBranch(v.TrueLabel, next)
+			e.gen.emit(&wasmInstr{op: wasmElse})
+			e.emitBranch(v.FalseLabel, next)
+			e.gen.emit(&wasmInstr{op: wasmEnd})
+			e.depth--
+		}
+
+		e.emitShape(s.next, follow)
+
+	case *wasmLoopShape:
+		next := wasmEntryLabels(s.next, follow)
+		e.open(wasmBlock, next)
+		e.open(wasmLoop, wasmEntryLabels(s, nil))
+		e.emitShape(s.inner, next)
+		e.close()
+		e.close()
+		e.emitShape(s.next, follow)
+
+	case *wasmMultipleShape:
+		next := wasmEntryLabels(s.next, follow)
+		e.open(wasmBlock, next)
+		for _, handled := range s.handled {
+			// skip the shapes that the branch is not meant for
+			e.gen.emit(&wasmInstr{op: wasmBlock})
+			e.depth++
+			e.gen.emit(
+				&wasmInstr{op: wasmLocalGet, temp: dispatch},
+				&wasmInstr{op: wasmI32Const, imm: int64(handled.entries()[0].id)},
+				&wasmInstr{op: wasmI32Ne},
+				&wasmInstr{op: wasmBrIf, imm: 0},
+			)
+
+			e.emitShape(handled, next)
+			e.gen.emit(&wasmInstr{op: wasmEnd})
+			e.depth--
+		}
+
+		e.close()
+		e.emitShape(s.next, follow)
+	}
+}
+
+// emitBranch sets $label to the target and goes there: by falling through when the code after the current shape
+// is the target, and otherwise through the innermost block or loop that reaches it.
+func (e *wasmReloopEmitter) emitBranch(target ir.Label, follow map[ir.Label]bool) {
+	if b, ok := e.blocks[target]; ok {
+		e.gen.emit(
+			&wasmInstr{op: wasmI32Const, imm: int64(b.id)},
+			&wasmInstr{op: wasmLocalSet, temp: dispatch},
+		)
+	}
+
+	if follow[target] {
+		return
+	}
+
+	for i := len(e.ctxs) - 1; i >= 0; i-- {
+		if e.ctxs[i].targets[target] {
+			e.gen.emit(&wasmInstr{op: wasmBr, imm: int64(e.depth - e.ctxs[i].depth)})
+			return
+		}
+	}
+
+	panic("no enclosing shape reaches " + e.gen.tm.LabelString(target))
+}
+
+// reloopFunction lays out the canonical statements of a function as structured control flow.
+func reloopFunction(tm *ir.TempManagement, stms [][]ir.StmIr, done ir.Label) []*wasmInstr {
+	blocks, entry := wasmBlocks(stms)
+	set := make(wasmBlockSet)
+	for _, b := range blocks {
+		set[b] = true
+	}
+
+	shape := reloop(set, []*wasmBasicBlock{entry})
+	exit := map[ir.Label]bool{done: true}
+	e := &wasmReloopEmitter{gen: NewCodeGenerator(tm), blocks: blocks}
+	e.open(wasmBlock, exit)
+	e.emitShape(shape, exit)
+	e.close()
+	return e.gen.instrs
+}
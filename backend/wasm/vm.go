@@ -0,0 +1,1754 @@
+package wasm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// The decoder and the interpreter below implement the part of WebAssembly that emitWasm produces: i32 values only,
+// blocks without results, one memory and the "tiger" host imports. That is enough to validate and run the output of
+// the wasm target without a browser.
+
+type wasmFuncSig struct {
+	params, results int
+}
+
+type wasmImport struct {
+	module, name string
+	typ          uint32
+}
+
+type wasmGlobalDef struct {
+	mutable bool
+	init    int32
+}
+
+type wasmExport struct {
+	kind  byte
+	index uint32
+}
+
+type wasmDataSegment struct {
+	offset int32
+	bytes  []byte
+}
+
+type wasmElemSegment struct {
+	offset int32
+	funcs  []uint32
+}
+
+// wasmOp is a decoded instruction. Block instructions know where they end, so that branches do not have to search
+// for the matching end.
+type wasmOp struct {
+	op  byte
+	imm int64
+
+	// the index of the matching end of block, loop, if and else, and of the else of an if (-1 without one)
+	end, els int
+}
+
+type wasmCode struct {
+	typ    uint32
+	locals int
+	body   []wasmOp
+}
+
+// Module is a decoded and validated module.
+type Module struct {
+	types     []wasmFuncSig
+	imports   []wasmImport
+	funcTypes []uint32
+	funcs     []*wasmCode
+	hasMemory bool
+	memPages  uint32
+	hasTable  bool
+	tableSize uint32
+	globals   []wasmGlobalDef
+	exports   map[string]wasmExport
+	elems     []wasmElemSegment
+	data      []wasmDataSegment
+}
+
+type wasmReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *wasmReader) byte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, invalidWasmErr("unexpected end", r.pos)
+	}
+
+	r.pos++
+	return r.b[r.pos-1], nil
+}
+
+func (r *wasmReader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.b) {
+		return nil, invalidWasmErr("unexpected end", r.pos)
+	}
+
+	r.pos += n
+	return r.b[r.pos-n : r.pos], nil
+}
+
+func (r *wasmReader) uleb() (uint32, error) {
+	var v uint64
+	for shift := 0; shift < 35; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			if v > math.MaxUint32 {
+				return 0, invalidWasmErr("integer too large", r.pos)
+			}
+
+			return uint32(v), nil
+		}
+	}
+
+	return 0, invalidWasmErr("integer representation too long", r.pos)
+}
+
+func (r *wasmReader) sleb() (int32, error) {
+	var v int64
+	shift := 0
+	for ; shift < 35; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+
+		v |= int64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			if b&0x40 != 0 {
+				v |= -1 << (shift + 7)
+			}
+
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return 0, invalidWasmErr("integer too large", r.pos)
+			}
+
+			return int32(v), nil
+		}
+	}
+
+	return 0, invalidWasmErr("integer representation too long", r.pos)
+}
+
+func (r *wasmReader) name() (string, error) {
+	n, err := r.uleb()
+	if err != nil {
+		return "", err
+	}
+
+	b, err := r.bytes(int(n))
+	return string(b), err
+}
+
+// expect reads one byte and fails unless it is want.
+func (r *wasmReader) expect(want byte, what string) error {
+	b, err := r.byte()
+	if err != nil {
+		return err
+	}
+
+	if b != want {
+		return invalidWasmErr(fmt.Sprintf("expected %s", what), r.pos-1)
+	}
+
+	return nil
+}
+
+// constExpr reads the i32.const initializer of a global or a data segment.
+func (r *wasmReader) constExpr() (int32, error) {
+	if err := r.expect(wasmI32Const, "i32.const"); err != nil {
+		return 0, err
+	}
+
+	v, err := r.sleb()
+	if err != nil {
+		return 0, err
+	}
+
+	return v, r.expect(wasmEnd, "end")
+}
+
+// Decode parses and validates a binary module.
+func Decode(b []byte) (*Module, error) {
+	r := &wasmReader{b: b}
+	header, err := r.bytes(8)
+	if err != nil || string(header[:4]) != wasmMagic {
+		return nil, invalidWasmErr("bad magic number", 0)
+	}
+
+	if header[4] != wasmVersion || header[5] != 0 || header[6] != 0 || header[7] != 0 {
+		return nil, invalidWasmErr("unsupported version", 4)
+	}
+
+	m := &Module{exports: make(map[string]wasmExport)}
+	last := byte(0)
+	for r.pos < len(r.b) {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := r.uleb()
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := r.bytes(int(size))
+		if err != nil {
+			return nil, err
+		}
+
+		// custom sections carry no semantics
+		if id == 0 {
+			continue
+		}
+
+		if id <= last {
+			return nil, invalidWasmErr("section out of order", r.pos-int(size))
+		}
+
+		last = id
+		sec := &wasmReader{b: b[:r.pos], pos: r.pos - len(content)}
+		switch id {
+		case wasmSectionType:
+			err = m.decodeTypes(sec)
+		case wasmSectionImport:
+			err = m.decodeImports(sec)
+		case wasmSectionFunc:
+			m.funcTypes, err = m.decodeFuncs(sec)
+		case wasmSectionTable:
+			err = m.decodeTable(sec)
+		case wasmSectionMemory:
+			err = m.decodeMemory(sec)
+		case wasmSectionGlobal:
+			err = m.decodeGlobals(sec)
+		case wasmSectionExport:
+			err = m.decodeExports(sec)
+		case wasmSectionElem:
+			err = m.decodeElems(sec)
+		case wasmSectionCode:
+			err = m.decodeCode(sec)
+		case wasmSectionData:
+			err = m.decodeData(sec)
+		default:
+			err = invalidWasmErr("unsupported section "+strconv.Itoa(int(id)), sec.pos)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if sec.pos != r.pos {
+			return nil, invalidWasmErr("section size mismatch", sec.pos)
+		}
+	}
+
+	if len(m.funcTypes) != len(m.funcs) {
+		return nil, invalidWasmErr("function and code section have inconsistent lengths", r.pos)
+	}
+
+	return m, nil
+}
+
+// vector reads the length of a vector and calls read for every element.
+func (r *wasmReader) vector(read func() error) error {
+	n, err := r.uleb()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < n; i++ {
+		if err := read(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *wasmReader) i32s() (int, error) {
+	count := 0
+	err := r.vector(func() error {
+		count++
+		return r.expect(wasmI32, "i32")
+	})
+
+	return count, err
+}
+
+func (m *Module) decodeTypes(r *wasmReader) error {
+	return r.vector(func() error {
+		if err := r.expect(wasmFuncType, "function type"); err != nil {
+			return err
+		}
+
+		params, err := r.i32s()
+		if err != nil {
+			return err
+		}
+
+		results, err := r.i32s()
+		if err != nil {
+			return err
+		}
+
+		if results > 1 {
+			return invalidWasmErr("multiple results are not supported", r.pos)
+		}
+
+		m.types = append(m.types, wasmFuncSig{params: params, results: results})
+		return nil
+	})
+}
+
+func (m *Module) decodeImports(r *wasmReader) error {
+	return r.vector(func() error {
+		module, err := r.name()
+		if err != nil {
+			return err
+		}
+
+		name, err := r.name()
+		if err != nil {
+			return err
+		}
+
+		if err := r.expect(wasmExternFunc, "function import"); err != nil {
+			return err
+		}
+
+		typ, err := r.uleb()
+		if err != nil {
+			return err
+		}
+
+		if int(typ) >= len(m.types) {
+			return invalidWasmErr("unknown type", r.pos)
+		}
+
+		m.imports = append(m.imports, wasmImport{module: module, name: name, typ: typ})
+		return nil
+	})
+}
+
+func (m *Module) decodeFuncs(r *wasmReader) ([]uint32, error) {
+	var types []uint32
+	err := r.vector(func() error {
+		typ, err := r.uleb()
+		if err != nil {
+			return err
+		}
+
+		if int(typ) >= len(m.types) {
+			return invalidWasmErr("unknown type", r.pos)
+		}
+
+		types = append(types, typ)
+		return nil
+	})
+
+	return types, err
+}
+
+func (m *Module) decodeTable(r *wasmReader) error {
+	return r.vector(func() error {
+		if m.hasTable {
+			return invalidWasmErr("multiple tables", r.pos)
+		}
+
+		if err := r.expect(wasmFuncRef, "funcref"); err != nil {
+			return err
+		}
+
+		// only tables without a maximum size
+		if err := r.expect(0, "table limits"); err != nil {
+			return err
+		}
+
+		size, err := r.uleb()
+		if err != nil {
+			return err
+		}
+
+		m.hasTable, m.tableSize = true, size
+		return nil
+	})
+}
+
+func (m *Module) decodeMemory(r *wasmReader) error {
+	return r.vector(func() error {
+		if m.hasMemory {
+			return invalidWasmErr("multiple memories", r.pos)
+		}
+
+		// only memories without a maximum size
+		if err := r.expect(0, "memory limits"); err != nil {
+			return err
+		}
+
+		pages, err := r.uleb()
+		if err != nil {
+			return err
+		}
+
+		if pages > wasmMaxPages {
+			return invalidWasmErr("memory size must be at most 65536 pages", r.pos)
+		}
+
+		m.hasMemory, m.memPages = true, pages
+		return nil
+	})
+}
+
+func (m *Module) decodeGlobals(r *wasmReader) error {
+	return r.vector(func() error {
+		if err := r.expect(wasmI32, "i32"); err != nil {
+			return err
+		}
+
+		mutable, err := r.byte()
+		if err != nil {
+			return err
+		}
+
+		if mutable > 1 {
+			return invalidWasmErr("invalid mutability", r.pos-1)
+		}
+
+		init, err := r.constExpr()
+		if err != nil {
+			return err
+		}
+
+		m.globals = append(m.globals, wasmGlobalDef{mutable: mutable == 1, init: init})
+		return nil
+	})
+}
+
+func (m *Module) decodeExports(r *wasmReader) error {
+	return r.vector(func() error {
+		name, err := r.name()
+		if err != nil {
+			return err
+		}
+
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+
+		index, err := r.uleb()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case kind == wasmExternFunc && int(index) < len(m.imports)+len(m.funcTypes):
+		case kind == wasmExternMemory && index == 0 && m.hasMemory:
+		case kind == wasmExternGlobal && int(index) < len(m.globals):
+		default:
+			return invalidWasmErr("invalid export "+name, r.pos)
+		}
+
+		if _, ok := m.exports[name]; ok {
+			return invalidWasmErr("duplicate export "+name, r.pos)
+		}
+
+		m.exports[name] = wasmExport{kind: kind, index: index}
+		return nil
+	})
+}
+
+func (m *Module) decodeElems(r *wasmReader) error {
+	return r.vector(func() error {
+		if err := r.expect(0, "active element segment"); err != nil {
+			return err
+		}
+
+		if !m.hasTable {
+			return invalidWasmErr("element segment without table", r.pos)
+		}
+
+		offset, err := r.constExpr()
+		if err != nil {
+			return err
+		}
+
+		var funcs []uint32
+		err = r.vector(func() error {
+			f, err := r.uleb()
+			if err != nil {
+				return err
+			}
+
+			if int(f) >= len(m.imports)+len(m.funcTypes) {
+				return invalidWasmErr("unknown function", r.pos)
+			}
+
+			funcs = append(funcs, f)
+			return nil
+		})
+
+		m.elems = append(m.elems, wasmElemSegment{offset: offset, funcs: funcs})
+		return err
+	})
+}
+
+func (m *Module) decodeData(r *wasmReader) error {
+	return r.vector(func() error {
+		if err := r.expect(0, "active data segment"); err != nil {
+			return err
+		}
+
+		if !m.hasMemory {
+			return invalidWasmErr("data segment without memory", r.pos)
+		}
+
+		offset, err := r.constExpr()
+		if err != nil {
+			return err
+		}
+
+		n, err := r.uleb()
+		if err != nil {
+			return err
+		}
+
+		b, err := r.bytes(int(n))
+		if err != nil {
+			return err
+		}
+
+		m.data = append(m.data, wasmDataSegment{offset: offset, bytes: b})
+		return nil
+	})
+}
+
+// sig returns the signature of a function of the function index space, imports first.
+func (m *Module) sig(f uint32) wasmFuncSig {
+	if int(f) < len(m.imports) {
+		return m.types[m.imports[f].typ]
+	}
+
+	return m.types[m.funcs[int(f)-len(m.imports)].typ]
+}
+
+func (m *Module) decodeCode(r *wasmReader) error {
+	// the code of a function may call the functions after it, so every function is known before any body is read
+	for _, typ := range m.funcTypes {
+		m.funcs = append(m.funcs, &wasmCode{typ: typ})
+	}
+
+	i := 0
+	return r.vector(func() error {
+		if i >= len(m.funcs) {
+			return invalidWasmErr("function and code section have inconsistent lengths", r.pos)
+		}
+
+		size, err := r.uleb()
+		if err != nil {
+			return err
+		}
+
+		end := r.pos + int(size)
+		if end > len(r.b) {
+			return invalidWasmErr("unexpected end", r.pos)
+		}
+
+		body := &wasmReader{b: r.b[:end], pos: r.pos}
+		if err := m.decodeBody(body, m.funcs[i]); err != nil {
+			return err
+		}
+
+		if body.pos != end {
+			return invalidWasmErr("code size mismatch", body.pos)
+		}
+
+		r.pos = end
+		i++
+		return nil
+	})
+}
+
+// wasmCtrl is a block being validated.
+type wasmCtrl struct {
+	op          byte
+	start       int
+	height      int
+	arity       int
+	unreachable bool
+}
+
+// wasmValidator checks the operand stack of a function body. Every value is an i32, so the stack is only a height.
+type wasmValidator struct {
+	r      *wasmReader
+	ctrls  []wasmCtrl
+	height int
+}
+
+func (v *wasmValidator) pop(n int) error {
+	ctrl := &v.ctrls[len(v.ctrls)-1]
+	for ; n > 0; n-- {
+		if v.height == ctrl.height {
+			// the stack is polymorphic after an unconditional branch
+			if ctrl.unreachable {
+				continue
+			}
+
+			return invalidWasmErr("type mismatch: operand stack underflow", v.r.pos)
+		}
+
+		v.height--
+	}
+
+	return nil
+}
+
+func (v *wasmValidator) unreachable() {
+	ctrl := &v.ctrls[len(v.ctrls)-1]
+	v.height = ctrl.height
+	ctrl.unreachable = true
+}
+
+// label returns the block that a branch of the given depth targets.
+func (v *wasmValidator) label(depth uint32) (*wasmCtrl, error) {
+	if int(depth) >= len(v.ctrls) {
+		return nil, invalidWasmErr("unknown label", v.r.pos)
+	}
+
+	return &v.ctrls[len(v.ctrls)-1-int(depth)], nil
+}
+
+// decodeBody reads the locals and the instructions of a function and validates them.
+func (m *Module) decodeBody(r *wasmReader, code *wasmCode) error {
+	err := r.vector(func() error {
+		n, err := r.uleb()
+		if err != nil {
+			return err
+		}
+
+		if err := r.expect(wasmI32, "i32"); err != nil {
+			return err
+		}
+
+		code.locals += int(n)
+		if code.locals > wasmMaxLocals {
+			return invalidWasmErr("too many locals", r.pos)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sig := m.types[code.typ]
+	nlocals := sig.params + code.locals
+	v := &wasmValidator{r: r, ctrls: []wasmCtrl{{op: wasmBlock, arity: sig.results}}}
+	for len(v.ctrls) > 0 {
+		op, err := r.byte()
+		if err != nil {
+			return err
+		}
+
+		idx := len(code.body)
+		instr := wasmOp{op: op, end: -1, els: -1}
+		switch op {
+		case wasmUnreachable:
+			v.unreachable()
+
+		case wasmNop:
+
+		case wasmBlock, wasmLoop, wasmIf:
+			if err := r.expect(wasmBlockVoid, "an empty block type"); err != nil {
+				return err
+			}
+
+			if op == wasmIf {
+				if err := v.pop(1); err != nil {
+					return err
+				}
+			}
+
+			v.ctrls = append(v.ctrls, wasmCtrl{op: op, start: idx, height: v.height})
+
+		case wasmElse:
+			ctrl := &v.ctrls[len(v.ctrls)-1]
+			if ctrl.op != wasmIf {
+				return invalidWasmErr("else without if", r.pos)
+			}
+
+			if !ctrl.unreachable && v.height != ctrl.height {
+				return invalidWasmErr("type mismatch: values remaining on the stack at the end of a block", r.pos)
+			}
+
+			code.body[ctrl.start].els = idx
+			ctrl.op, ctrl.unreachable, v.height = wasmElse, false, ctrl.height
+
+		case wasmEnd:
+			ctrl := v.ctrls[len(v.ctrls)-1]
+			if err := v.pop(ctrl.arity); err != nil {
+				return err
+			}
+
+			if v.height != ctrl.height {
+				return invalidWasmErr("type mismatch: values remaining on the stack at the end of a block", r.pos)
+			}
+
+			v.ctrls = v.ctrls[:len(v.ctrls)-1]
+			v.height += ctrl.arity
+			if len(v.ctrls) > 0 {
+				code.body[ctrl.start].end = idx
+				if els := code.body[ctrl.start].els; els >= 0 {
+					code.body[els].end = idx
+				}
+			}
+
+		case wasmBr, wasmBrIf:
+			depth, err := r.uleb()
+			if err != nil {
+				return err
+			}
+
+			target, err := v.label(depth)
+			if err != nil {
+				return err
+			}
+
+			// a branch to a loop restarts it and carries no values
+			arity := target.arity
+			if target.op == wasmLoop {
+				arity = 0
+			}
+
+			if op == wasmBrIf {
+				if err := v.pop(1 + arity); err != nil {
+					return err
+				}
+
+				v.height += arity
+			} else {
+				if err := v.pop(arity); err != nil {
+					return err
+				}
+
+				v.unreachable()
+			}
+
+			instr.imm = int64(depth)
+
+		case wasmReturn:
+			if err := v.pop(sig.results); err != nil {
+				return err
+			}
+
+			v.unreachable()
+
+		case wasmCall:
+			f, err := r.uleb()
+			if err != nil {
+				return err
+			}
+
+			if int(f) >= len(m.imports)+len(m.funcs) {
+				return invalidWasmErr("unknown function", r.pos)
+			}
+
+			callee := m.sig(f)
+			if err := v.pop(callee.params); err != nil {
+				return err
+			}
+
+			v.height += callee.results
+			instr.imm = int64(f)
+
+		case wasmCallIndirect:
+			typ, err := r.uleb()
+			if err != nil {
+				return err
+			}
+
+			if err := r.expect(0, "table index"); err != nil {
+				return err
+			}
+
+			if !m.hasTable {
+				return invalidWasmErr("call_indirect without table", r.pos)
+			}
+
+			if int(typ) >= len(m.types) {
+				return invalidWasmErr("unknown type", r.pos)
+			}
+
+			// the index of the callee comes after the arguments
+			if err := v.pop(m.types[typ].params + 1); err != nil {
+				return err
+			}
+
+			v.height += m.types[typ].results
+			instr.imm = int64(typ)
+
+		case wasmDrop:
+			if err := v.pop(1); err != nil {
+				return err
+			}
+
+		case wasmLocalGet, wasmLocalSet, wasmLocalTee:
+			local, err := r.uleb()
+			if err != nil {
+				return err
+			}
+
+			if int(local) >= nlocals {
+				return invalidWasmErr("unknown local", r.pos)
+			}
+
+			if op != wasmLocalGet {
+				if err := v.pop(1); err != nil {
+					return err
+				}
+			}
+
+			if op != wasmLocalSet {
+				v.height++
+			}
+
+			instr.imm = int64(local)
+
+		case wasmGlobalGet, wasmGlobalSet:
+			global, err := r.uleb()
+			if err != nil {
+				return err
+			}
+
+			if int(global) >= len(m.globals) {
+				return invalidWasmErr("unknown global", r.pos)
+			}
+
+			if op == wasmGlobalSet {
+				if !m.globals[global].mutable {
+					return invalidWasmErr("global is immutable", r.pos)
+				}
+
+				if err := v.pop(1); err != nil {
+					return err
+				}
+			} else {
+				v.height++
+			}
+
+			instr.imm = int64(global)
+
+		case wasmI32Load, wasmI32Load8U, wasmI32Store, wasmI32Store8:
+			if !m.hasMemory {
+				return invalidWasmErr("unknown memory", r.pos)
+			}
+
+			align, err := r.uleb()
+			if err != nil {
+				return err
+			}
+
+			if (op == wasmI32Load || op == wasmI32Store) && align > 2 || (op == wasmI32Load8U || op == wasmI32Store8) && align > 0 {
+				return invalidWasmErr("alignment must not be larger than natural", r.pos)
+			}
+
+			offset, err := r.uleb()
+			if err != nil {
+				return err
+			}
+
+			n := 1
+			if op == wasmI32Store || op == wasmI32Store8 {
+				n = 2
+			}
+
+			if err := v.pop(n); err != nil {
+				return err
+			}
+
+			if n == 1 {
+				v.height++
+			}
+
+			instr.imm = int64(offset)
+
+		case wasmMemorySize, wasmMemoryGrow:
+			if !m.hasMemory {
+				return invalidWasmErr("unknown memory", r.pos)
+			}
+
+			if err := r.expect(0, "memory index 0"); err != nil {
+				return err
+			}
+
+			if op == wasmMemoryGrow {
+				if err := v.pop(1); err != nil {
+					return err
+				}
+			}
+
+			v.height++
+
+		case wasmI32Const:
+			c, err := r.sleb()
+			if err != nil {
+				return err
+			}
+
+			instr.imm = int64(c)
+			v.height++
+
+		case wasmI32Eqz:
+			if err := v.pop(1); err != nil {
+				return err
+			}
+
+			v.height++
+
+		default:
+			if (op < wasmI32Eq || op > wasmI32GeU) && (op < wasmI32Add || op > wasmI32ShrU) {
+				return invalidWasmErr(fmt.Sprintf("unsupported opcode 0x%02x", op), r.pos-1)
+			}
+
+			if err := v.pop(2); err != nil {
+				return err
+			}
+
+			v.height++
+		}
+
+		code.body = append(code.body, instr)
+	}
+
+	return nil
+}
+
+const (
+	wasmMaxPages  = 65536
+	wasmMaxLocals = 50000
+
+	// wasmMaxCallDepth bounds the recursion of the interpreter, engines have a similar limit
+	wasmMaxCallDepth = 10000
+)
+
+// wasmHostFunc is a function that the host provides to the module.
+type wasmHostFunc struct {
+	params int
+	call   func(vm *VM, args []int32) (int32, error)
+}
+
+// wasmExit unwinds the interpreter when the program calls exit.
+type wasmExit struct {
+	code int
+}
+
+func (e *wasmExit) Error() string {
+	return fmt.Sprintf("exit %d", e.code)
+}
+
+// VM instantiates a module and interprets it, implementing the runtime functions that the module imports from
+// the "tiger" host module the same way runtime/src/runtime_wasm.js does.
+type VM struct {
+	mod     *Module
+	mem     []byte
+	globals []int32
+	host    []wasmHostFunc
+
+	// table holds the function indices of the elements of the table, -1 for the elements that are not initialized
+	table []int64
+
+	stdin  *bufio.Reader
+	stdout io.Writer
+
+	// heap is where the runtime functions allocate
+	heap wasmHeap
+
+	// MaxSteps stops runaway programs. Zero means no limit.
+	MaxSteps int
+	steps    int
+	depth    int
+}
+
+const (
+	// the kinds of the objects of the heap, in the low bits of their headers
+	wasmRecord       = 1
+	wasmArray        = 2
+	wasmPointerArray = 3
+	wasmString       = 4
+
+	// wasmHeapSize is the size of the first space of the heap
+	wasmHeapSize = 131072
+)
+
+// wasmHeap is the heap of the runtime, which a copying collector like the one of runtime/src/runtime.c collects.
+// Every object starts with two header words: the descriptor of a record, a string with 'p' for each field that holds
+// a heap pointer and 'n' for the others, or 0 for the other objects, and then length<<3|kind. An object that has been
+// copied has -1 in place of its descriptor and its new address in place of its length and kind. The objects are
+// copied between two spaces taken from the memory, a larger one replacing the space to copy to when the live objects
+// fill more than half of it.
+//
+// The roots are the frames of the shadow stack, from $sp to the top of the stack, which is where the memory of the
+// heap started. Every frame starts with its size and its pointer map, see Frame.
+type wasmHeap struct {
+	stackTop int32
+
+	// the space the objects are allocated in, and the other one
+	space, spaceSize, next, limit int32
+	other, otherSize              int32
+
+	// size is the size of the next space that is taken from the memory
+	size int32
+
+	// the space being evacuated during a collection
+	from, fromNext int32
+}
+
+func NewVM(mod *Module, stdin io.Reader, stdout io.Writer) (*VM, error) {
+	vm := &VM{
+		mod:    mod,
+		mem:    make([]byte, int(mod.memPages)*wasmPageSize),
+		stdin:  bufio.NewReader(stdin),
+		stdout: stdout,
+	}
+
+	for _, imp := range mod.imports {
+		fn, ok := wasmHostFuncs[imp.name]
+		if imp.module != "tiger" || !ok {
+			return nil, unknownWasmImportErr(imp.module, imp.name)
+		}
+
+		if sig := mod.types[imp.typ]; sig.params != fn.params || sig.results != 1 {
+			return nil, incompatibleWasmImportErr(imp.module, imp.name)
+		}
+
+		vm.host = append(vm.host, fn)
+	}
+
+	for _, global := range mod.globals {
+		vm.globals = append(vm.globals, global.init)
+	}
+
+	vm.heap.size = wasmHeapSize
+	if heap, ok := mod.exports["heap"]; ok && heap.kind == wasmExternGlobal && int(heap.index) < len(vm.globals) {
+		vm.heap.stackTop = vm.globals[heap.index]
+	}
+
+	vm.table = make([]int64, mod.tableSize)
+	for i := range vm.table {
+		vm.table[i] = -1
+	}
+
+	for _, seg := range mod.elems {
+		if seg.offset < 0 || int(seg.offset)+len(seg.funcs) > len(vm.table) {
+			return nil, wasmTrapErr("out of bounds table access")
+		}
+
+		for i, f := range seg.funcs {
+			vm.table[int(seg.offset)+i] = int64(f)
+		}
+	}
+
+	for _, seg := range mod.data {
+		if seg.offset < 0 || int(seg.offset)+len(seg.bytes) > len(vm.mem) {
+			return nil, wasmTrapErr("out of bounds memory access")
+		}
+
+		copy(vm.mem[seg.offset:], seg.bytes)
+	}
+
+	return vm, nil
+}
+
+// Run calls the exported main function with a nil static link and returns the exit code of the program.
+func (vm *VM) Run() (int, error) {
+	if _, err := vm.Call("main", 0); err != nil {
+		if exit, ok := err.(*wasmExit); ok {
+			return exit.code, nil
+		}
+
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+// Call calls an exported function.
+func (vm *VM) Call(name string, args ...int32) (int32, error) {
+	export, ok := vm.mod.exports[name]
+	if !ok || export.kind != wasmExternFunc {
+		return 0, undefinedWasmExportErr(name)
+	}
+
+	if sig := vm.mod.sig(export.index); sig.params != len(args) {
+		return 0, wasmArgCountErr(name, sig.params, len(args))
+	}
+
+	return vm.call(export.index, args)
+}
+
+func (vm *VM) call(f uint32, args []int32) (int32, error) {
+	if int(f) < len(vm.host) {
+		return vm.host[f].call(vm, args)
+	}
+
+	vm.depth++
+	defer func() { vm.depth-- }()
+	if vm.depth > wasmMaxCallDepth {
+		return 0, wasmTrapErr("call stack exhausted")
+	}
+
+	code := vm.mod.funcs[int(f)-len(vm.host)]
+	locals := make([]int32, len(args)+code.locals)
+	copy(locals, args)
+	return vm.exec(code, locals)
+}
+
+// wasmLabelFrame is a block being executed: a branch to it truncates the operand stack to height and goes on after the
+// end of the block, or at the start of a loop.
+type wasmLabelFrame struct {
+	height int
+	op     byte
+	pc     int
+}
+
+func (vm *VM) exec(code *wasmCode, locals []int32) (int32, error) {
+	var (
+		stack  []int32
+		labels []wasmLabelFrame
+	)
+
+	pop := func() int32 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return v
+	}
+
+	ret := func() int32 {
+		if vm.mod.types[code.typ].results == 0 {
+			return 0
+		}
+
+		return stack[len(stack)-1]
+	}
+
+	body := code.body
+	for pc := 0; pc < len(body); pc++ {
+		vm.steps++
+		if vm.MaxSteps > 0 && vm.steps > vm.MaxSteps {
+			return 0, stepLimitErr(vm.MaxSteps)
+		}
+
+		instr := &body[pc]
+		switch instr.op {
+		case wasmUnreachable:
+			return 0, wasmTrapErr("unreachable")
+
+		case wasmNop:
+
+		case wasmBlock:
+			labels = append(labels, wasmLabelFrame{height: len(stack), op: wasmBlock, pc: instr.end})
+
+		case wasmLoop:
+			labels = append(labels, wasmLabelFrame{height: len(stack), op: wasmLoop, pc: pc})
+
+		case wasmIf:
+			labels = append(labels, wasmLabelFrame{height: len(stack), op: wasmIf, pc: instr.end})
+			if pop() == 0 {
+				if instr.els >= 0 {
+					pc = instr.els
+				} else {
+					pc = instr.end - 1
+				}
+			}
+
+		case wasmElse:
+			// the then branch is done
+			pc = instr.end - 1
+
+		case wasmEnd:
+			if len(labels) == 0 {
+				return ret(), nil
+			}
+
+			labels = labels[:len(labels)-1]
+
+		case wasmBr, wasmBrIf:
+			if instr.op == wasmBrIf && pop() == 0 {
+				break
+			}
+
+			depth := int(instr.imm)
+			if depth == len(labels) {
+				return ret(), nil
+			}
+
+			target := labels[len(labels)-1-depth]
+			stack = stack[:target.height]
+			if target.op == wasmLoop {
+				labels = labels[:len(labels)-depth]
+				pc = target.pc
+			} else {
+				labels = labels[:len(labels)-1-depth]
+				pc = target.pc
+			}
+
+		case wasmReturn:
+			return ret(), nil
+
+		case wasmCall:
+			f := uint32(instr.imm)
+			n := vm.mod.sig(f).params
+			args := append([]int32{}, stack[len(stack)-n:]...)
+			stack = stack[:len(stack)-n]
+			v, err := vm.call(f, args)
+			if err != nil {
+				return 0, err
+			}
+
+			if vm.mod.sig(f).results > 0 {
+				stack = append(stack, v)
+			}
+
+		case wasmCallIndirect:
+			sig := vm.mod.types[instr.imm]
+			i := uint32(pop())
+			if int64(i) >= int64(len(vm.table)) {
+				return 0, wasmTrapErr("undefined element")
+			}
+
+			if vm.table[i] < 0 {
+				return 0, wasmTrapErr("uninitialized element")
+			}
+
+			f := uint32(vm.table[i])
+			if vm.mod.sig(f) != sig {
+				return 0, wasmTrapErr("indirect call type mismatch")
+			}
+
+			args := append([]int32{}, stack[len(stack)-sig.params:]...)
+			stack = stack[:len(stack)-sig.params]
+			v, err := vm.call(f, args)
+			if err != nil {
+				return 0, err
+			}
+
+			if sig.results > 0 {
+				stack = append(stack, v)
+			}
+
+		case wasmDrop:
+			pop()
+
+		case wasmLocalGet:
+			stack = append(stack, locals[instr.imm])
+
+		case wasmLocalSet:
+			locals[instr.imm] = pop()
+
+		case wasmLocalTee:
+			locals[instr.imm] = stack[len(stack)-1]
+
+		case wasmGlobalGet:
+			stack = append(stack, vm.globals[instr.imm])
+
+		case wasmGlobalSet:
+			vm.globals[instr.imm] = pop()
+
+		case wasmI32Load, wasmI32Load8U:
+			size := uint64(4)
+			if instr.op == wasmI32Load8U {
+				size = 1
+			}
+
+			addr, err := vm.addr(pop(), instr.imm, size)
+			if err != nil {
+				return 0, err
+			}
+
+			if size == 1 {
+				stack = append(stack, int32(vm.mem[addr]))
+			} else {
+				stack = append(stack, vm.loadWord(addr))
+			}
+
+		case wasmI32Store, wasmI32Store8:
+			size := uint64(4)
+			if instr.op == wasmI32Store8 {
+				size = 1
+			}
+
+			v := pop()
+			addr, err := vm.addr(pop(), instr.imm, size)
+			if err != nil {
+				return 0, err
+			}
+
+			if size == 1 {
+				vm.mem[addr] = byte(v)
+			} else {
+				vm.storeWord(addr, v)
+			}
+
+		case wasmMemorySize:
+			stack = append(stack, int32(len(vm.mem)/wasmPageSize))
+
+		case wasmMemoryGrow:
+			stack = append(stack, vm.grow(pop()))
+
+		case wasmI32Const:
+			stack = append(stack, int32(instr.imm))
+
+		case wasmI32Eqz:
+			stack = append(stack, boolToInt32(pop() == 0))
+
+		default:
+			b := pop()
+			a := pop()
+			v, err := wasmBinary(instr.op, a, b)
+			if err != nil {
+				return 0, err
+			}
+
+			stack = append(stack, v)
+		}
+	}
+
+	return ret(), nil
+}
+
+func wasmBinary(op byte, a, b int32) (int32, error) {
+	switch op {
+	case wasmI32Eq:
+		return boolToInt32(a == b), nil
+	case wasmI32Ne:
+		return boolToInt32(a != b), nil
+	case wasmI32LtS:
+		return boolToInt32(a < b), nil
+	case wasmI32LtU:
+		return boolToInt32(uint32(a) < uint32(b)), nil
+	case wasmI32GtS:
+		return boolToInt32(a > b), nil
+	case wasmI32GtU:
+		return boolToInt32(uint32(a) > uint32(b)), nil
+	case wasmI32LeS:
+		return boolToInt32(a <= b), nil
+	case wasmI32LeU:
+		return boolToInt32(uint32(a) <= uint32(b)), nil
+	case wasmI32GeS:
+		return boolToInt32(a >= b), nil
+	case wasmI32GeU:
+		return boolToInt32(uint32(a) >= uint32(b)), nil
+	case wasmI32Add:
+		return a + b, nil
+	case wasmI32Sub:
+		return a - b, nil
+	case wasmI32Mul:
+		return a * b, nil
+	case wasmI32DivS, wasmI32DivU, wasmI32RemS, wasmI32RemU:
+		if b == 0 {
+			return 0, divisionByZeroErr()
+		}
+
+		switch op {
+		case wasmI32DivS:
+			if a == math.MinInt32 && b == -1 {
+				return 0, wasmTrapErr("integer overflow")
+			}
+
+			return a / b, nil
+		case wasmI32DivU:
+			return int32(uint32(a) / uint32(b)), nil
+		case wasmI32RemS:
+			return a % b, nil
+		default:
+			return int32(uint32(a) % uint32(b)), nil
+		}
+	case wasmI32And:
+		return a & b, nil
+	case wasmI32Or:
+		return a | b, nil
+	case wasmI32Xor:
+		return a ^ b, nil
+	case wasmI32Shl:
+		return a << (uint32(b) & 31), nil
+	case wasmI32ShrS:
+		return a >> (uint32(b) & 31), nil
+	case wasmI32ShrU:
+		return int32(uint32(a) >> (uint32(b) & 31)), nil
+	}
+
+	return 0, invalidWasmErr(fmt.Sprintf("unsupported opcode 0x%02x", op), 0)
+}
+
+// addr returns the effective address of a memory access of size bytes, or a trap when it is out of bounds.
+func (vm *VM) addr(base int32, offset int64, size uint64) (uint64, error) {
+	addr := uint64(uint32(base)) + uint64(offset)
+	if addr+size > uint64(len(vm.mem)) {
+		return 0, wasmTrapErr("out of bounds memory access")
+	}
+
+	return addr, nil
+}
+
+func (vm *VM) loadWord(addr uint64) int32 {
+	b := vm.mem[addr : addr+4]
+	return int32(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+}
+
+func (vm *VM) storeWord(addr uint64, v int32) {
+	b := vm.mem[addr : addr+4]
+	b[0], b[1], b[2], b[3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+}
+
+// grow adds pages to the memory and returns its previous size in pages, or -1 when it cannot grow.
+func (vm *VM) grow(pages int32) int32 {
+	old := len(vm.mem) / wasmPageSize
+	if pages < 0 || old+int(pages) > wasmMaxPages {
+		return -1
+	}
+
+	vm.mem = append(vm.mem, make([]byte, int(pages)*wasmPageSize)...)
+	return int32(old)
+}
+
+// alloc takes n zeroed bytes from the memory, whose first free byte the module exports as its heap, and grows the
+// memory when needed.
+func (vm *VM) alloc(n int32) (int32, error) {
+	heap, ok := vm.mod.exports["heap"]
+	if !ok || heap.kind != wasmExternGlobal {
+		return 0, undefinedWasmExportErr("heap")
+	}
+
+	if n < 0 {
+		return 0, wasmTrapErr("negative allocation size")
+	}
+
+	p := (int64(vm.globals[heap.index]) + 3) &^ 3
+	end := p + int64(n)
+	if end > int64(len(vm.mem)) {
+		pages := (end - int64(len(vm.mem)) + wasmPageSize - 1) / wasmPageSize
+		if pages > math.MaxInt32 || vm.grow(int32(pages)) < 0 {
+			return 0, wasmTrapErr("out of memory")
+		}
+	}
+
+	vm.globals[heap.index] = int32(end)
+	return int32(p), nil
+}
+
+// gcAlloc returns a cleared object of n bytes with the header, collecting the heap when it is full. The collector
+// updates the heap pointers in roots.
+func (vm *VM) gcAlloc(n, header int32, roots ...*int32) (int32, error) {
+	h := &vm.heap
+	if int64(h.next)+8+int64(n) > int64(h.limit) {
+		if err := vm.collect(8+int64(n), roots); err != nil {
+			return 0, err
+		}
+	}
+
+	p := h.next
+	h.next += 8 + n
+	vm.storeWord(uint64(p), 0)
+	vm.storeWord(uint64(p+4), header)
+	for i := p + 8; i < h.next; i++ {
+		vm.mem[i] = 0
+	}
+
+	return p + 8, nil
+}
+
+// collect copies the live objects to the other space with room for need more bytes, or to a new one when it is too
+// small.
+func (vm *VM) collect(need int64, roots []*int32) error {
+	sp, ok := vm.mod.exports["sp"]
+	if !ok || sp.kind != wasmExternGlobal {
+		return undefinedWasmExportErr("sp")
+	}
+
+	h := &vm.heap
+	for {
+		h.from, h.fromNext = h.space, h.next
+		to, toSize := h.other, h.otherSize
+		if toSize < h.size {
+			p, err := vm.alloc(h.size)
+			if err != nil {
+				return err
+			}
+
+			to, toSize = p, h.size
+		}
+
+		h.other, h.otherSize = h.space, h.spaceSize
+		h.space, h.spaceSize, h.next, h.limit = to, toSize, to, to+toSize
+
+		for fp := vm.globals[sp.index]; fp < h.stackTop; fp += vm.loadWord(uint64(fp)) {
+			ptrMap := uint64(vm.loadWord(uint64(fp + WordSize)))
+			if ptrMap == 0 {
+				continue
+			}
+
+			for i := uint64(1); i <= uint64(vm.loadWord(ptrMap)); i++ {
+				vm.forwardWord(uint64(fp + vm.loadWord(ptrMap+i*WordSize)))
+			}
+		}
+
+		for _, root := range roots {
+			*root = vm.forward(*root)
+		}
+
+		// the fields of the copied objects, which are copied in turn after them
+		for scan := h.space; scan < h.next; {
+			desc, header := vm.loadWord(uint64(scan)), vm.loadWord(uint64(scan+4))
+			scan += 8
+			length := header >> 3
+			switch header & 7 {
+			case wasmString:
+				length = length/WordSize + 1
+			case wasmRecord:
+				for i := int32(0); i < length; i++ {
+					if vm.mem[desc+i] == 'p' {
+						vm.forwardWord(uint64(scan + i*WordSize))
+					}
+				}
+			case wasmPointerArray:
+				for i := int32(0); i < length; i++ {
+					vm.forwardWord(uint64(scan + i*WordSize))
+				}
+			}
+
+			scan += length * WordSize
+		}
+
+		used := int64(h.next-h.space) + need
+		if used*2 <= int64(h.size) {
+			return nil
+		}
+
+		for used*2 > int64(h.size) {
+			if h.size > math.MaxInt32/2 {
+				return wasmTrapErr("out of memory")
+			}
+
+			h.size *= 2
+		}
+
+		if used <= int64(h.spaceSize) {
+			return nil
+		}
+	}
+}
+
+// forward copies the object p points to, unless it is not an address of the space being evacuated or the object was
+// copied already, and returns its new address.
+func (vm *VM) forward(p int32) int32 {
+	h := &vm.heap
+	if p <= h.from || p > h.fromNext {
+		return p
+	}
+
+	if vm.loadWord(uint64(p-8)) == -1 {
+		return vm.loadWord(uint64(p - 4))
+	}
+
+	header := vm.loadWord(uint64(p - 4))
+	words := header >> 3
+	if header&7 == wasmString {
+		words = words/WordSize + 1
+	}
+
+	size := 8 + words*WordSize
+	copy(vm.mem[h.next:h.next+size], vm.mem[p-8:p-8+size])
+	vm.storeWord(uint64(p-8), -1)
+	vm.storeWord(uint64(p-4), h.next+8)
+	h.next += size
+	return h.next - size + 8
+}
+
+// forwardWord forwards the heap pointer at addr.
+func (vm *VM) forwardWord(addr uint64) {
+	vm.storeWord(addr, vm.forward(vm.loadWord(addr)))
+}
+
+// str reads the null-terminated string at addr.
+func (vm *VM) str(addr int32) ([]byte, error) {
+	for end := uint64(uint32(addr)); end < uint64(len(vm.mem)); end++ {
+		if vm.mem[end] == 0 {
+			return vm.mem[uint32(addr):end], nil
+		}
+	}
+
+	return nil, wasmTrapErr("out of bounds memory access")
+}
+
+// newStr allocates a null-terminated copy of b on the heap.
+func (vm *VM) newStr(b []byte) (int32, error) {
+	n := int32(len(b))
+	p, err := vm.gcAlloc((n/WordSize+1)*WordSize, n<<3|wasmString)
+	if err != nil {
+		return 0, err
+	}
+
+	copy(vm.mem[p:], b)
+	return p, nil
+}
+
+// RuntimeFuncs lists the functions of the host, which are those of VM. runtime/src/runtime_wasm.js provides
+// the same.
+func RuntimeFuncs(string) map[string]bool {
+	funcs := make(map[string]bool)
+	for name := range wasmHostFuncs {
+		funcs[name] = true
+	}
+
+	return funcs
+}
+
+var wasmHostFuncs = map[string]wasmHostFunc{
+	"print": {1, func(vm *VM, args []int32) (int32, error) {
+		s, err := vm.str(args[0])
+		if err != nil {
+			return 0, err
+		}
+
+		vm.stdout.Write(s)
+		return 0, nil
+	}},
+	"printi": {1, func(vm *VM, args []int32) (int32, error) {
+		fmt.Fprint(vm.stdout, args[0])
+		return 0, nil
+	}},
+	"flush": {0, func(vm *VM, args []int32) (int32, error) {
+		return 0, nil
+	}},
+	"getchar": {0, func(vm *VM, args []int32) (int32, error) {
+		c, err := vm.stdin.ReadByte()
+		if err != nil {
+			return vm.newStr(nil)
+		}
+
+		return vm.newStr([]byte{c})
+	}},
+	"ord": {1, func(vm *VM, args []int32) (int32, error) {
+		s, err := vm.str(args[0])
+		if err != nil || len(s) == 0 {
+			return -1, err
+		}
+
+		return int32(s[0]), nil
+	}},
+	"chr": {1, func(vm *VM, args []int32) (int32, error) {
+		return vm.newStr([]byte{byte(args[0])})
+	}},
+	"size": {1, func(vm *VM, args []int32) (int32, error) {
+		s, err := vm.str(args[0])
+		return int32(len(s)), err
+	}},
+	"stringCompare": {2, func(vm *VM, args []int32) (int32, error) {
+		a, err := vm.str(args[0])
+		if err != nil {
+			return 0, err
+		}
+
+		b, err := vm.str(args[1])
+		if err != nil {
+			return 0, err
+		}
+
+		return int32(bytes.Compare(a, b)), nil
+	}},
+	"substring": {3, func(vm *VM, args []int32) (int32, error) {
+		s, err := vm.str(args[0])
+		if err != nil {
+			return 0, err
+		}
+
+		first, n := args[1], args[2]
+		if first < 0 || n < 0 || int(first)+int(n) > len(s) {
+			return 0, wasmTrapErr("substring out of range")
+		}
+
+		// a copy, the collection that newStr may run moves s
+		return vm.newStr(append([]byte{}, s[first:first+n]...))
+	}},
+	"concat": {2, func(vm *VM, args []int32) (int32, error) {
+		a, err := vm.str(args[0])
+		if err != nil {
+			return 0, err
+		}
+
+		b, err := vm.str(args[1])
+		if err != nil {
+			return 0, err
+		}
+
+		return vm.newStr(append(append([]byte{}, a...), b...))
+	}},
+	"not": {1, func(vm *VM, args []int32) (int32, error) {
+		return boolToInt32(args[0] == 0), nil
+	}},
+	"exit": {1, func(vm *VM, args []int32) (int32, error) {
+		return 0, &wasmExit{code: int(args[0])}
+	}},
+	"gcInitArray": {3, func(vm *VM, args []int32) (int32, error) {
+		n, init := args[0], args[1]
+		if n < 0 || n > math.MaxInt32>>3 {
+			return 0, wasmTrapErr("invalid array size")
+		}
+
+		// the collector has to update init only when it is a heap pointer
+		kind, roots := int32(wasmArray), []*int32(nil)
+		if args[2] != 0 {
+			kind, roots = wasmPointerArray, []*int32{&init}
+		}
+
+		p, err := vm.gcAlloc(n*WordSize, n<<3|kind, roots...)
+		if err != nil {
+			return 0, err
+		}
+
+		for i := int32(0); i < n; i++ {
+			vm.storeWord(uint64(p+i*WordSize), init)
+		}
+
+		return p, nil
+	}},
+	"gcAllocRecord": {1, func(vm *VM, args []int32) (int32, error) {
+		desc, err := vm.str(args[0])
+		if err != nil {
+			return 0, err
+		}
+
+		n := int32(len(desc))
+		p, err := vm.gcAlloc(n*WordSize, n<<3|wasmRecord)
+		if err != nil {
+			return 0, err
+		}
+
+		vm.storeWord(uint64(p-8), args[0])
+		return p, nil
+	}},
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,74 @@
+package wasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// wasmFunc builds a module with a function of type [] -> [i32] whose body is code.
+func wasmFunc(code ...byte) []byte {
+	out, sec := bytes.Buffer{}, bytes.Buffer{}
+	out.WriteString(wasmMagic)
+	out.Write([]byte{wasmVersion, 0, 0, 0})
+	sec.Write([]byte{1, wasmFuncType, 0, 1, wasmI32})
+	writeWasmSection(&out, wasmSectionType, &sec)
+	sec.Reset()
+	sec.Write([]byte{1, 0})
+	writeWasmSection(&out, wasmSectionFunc, &sec)
+	sec.Reset()
+	sec.Write([]byte{1, byte(len(code) + 1), 0})
+	sec.Write(code)
+	writeWasmSection(&out, wasmSectionCode, &sec)
+	return out.Bytes()
+}
+
+func TestDecode_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := Decode([]byte("\x00wasm\x01\x00\x00\x00"))
+	require.Error(t, err)
+
+	// i32.const 1; i32.const 2; i32.add; end
+	mod, err := Decode(wasmFunc(wasmI32Const, 1, wasmI32Const, 2, wasmI32Add, wasmEnd))
+	require.NoError(t, err)
+	require.Len(t, mod.funcs, 1)
+
+	for name, code := range map[string][]byte{
+		"stack underflow":    {wasmI32Const, 1, wasmI32Add, wasmEnd},
+		"missing result":     {wasmEnd},
+		"unknown label":      {wasmBlock, wasmBlockVoid, wasmBr, 2, wasmEnd, wasmI32Const, 0, wasmEnd},
+		"else without if":    {wasmElse, wasmEnd},
+		"unknown local":      {wasmLocalGet, 0, wasmEnd},
+		"no memory":          {wasmI32Const, 0, wasmI32Load, 2, 0, wasmEnd},
+		"value left in loop": {wasmLoop, wasmBlockVoid, wasmI32Const, 0, wasmEnd, wasmI32Const, 0, wasmEnd},
+	} {
+		_, err := Decode(wasmFunc(code...))
+		require.Error(t, err, name)
+	}
+}
+
+func TestVM_Traps(t *testing.T) {
+	t.Parallel()
+
+	// loop: br 0 never terminates
+	mod, err := Decode(wasmFunc(wasmLoop, wasmBlockVoid, wasmBr, 0, wasmEnd, wasmI32Const, 0, wasmEnd))
+	require.NoError(t, err)
+	mod.exports["main"] = wasmExport{kind: wasmExternFunc, index: 0}
+	vm, err := NewVM(mod, strings.NewReader(""), &bytes.Buffer{})
+	require.NoError(t, err)
+	vm.MaxSteps = 100
+	_, err = vm.Call("main")
+	require.Error(t, err)
+
+	// 1 / 0
+	mod, err = Decode(wasmFunc(wasmI32Const, 1, wasmI32Const, 0, wasmI32DivS, wasmEnd))
+	require.NoError(t, err)
+	mod.exports["main"] = wasmExport{kind: wasmExternFunc, index: 0}
+	vm, err = NewVM(mod, strings.NewReader(""), &bytes.Buffer{})
+	require.NoError(t, err)
+	_, err = vm.Call("main")
+	require.Error(t, err)
+}
@@ -0,0 +1,234 @@
+// Package wasm is the WebAssembly target: the frames on the shadow stack, the relooper that turns the jumps back into
+// blocks and loops, the encoding of the module, and a virtual machine that runs it.
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+
+	"tiger/ir"
+)
+
+// WebAssembly binary format constants, see https://webassembly.github.io/spec/core/binary/.
+const (
+	wasmMagic   = "\x00asm"
+	wasmVersion = 1
+
+	wasmI32       = 0x7f
+	wasmFuncRef   = 0x70
+	wasmFuncType  = 0x60
+	wasmBlockVoid = 0x40
+
+	wasmPageSize = 65536
+)
+
+const (
+	wasmSectionType   = 1
+	wasmSectionImport = 2
+	wasmSectionFunc   = 3
+	wasmSectionTable  = 4
+	wasmSectionMemory = 5
+	wasmSectionGlobal = 6
+	wasmSectionExport = 7
+	wasmSectionElem   = 9
+	wasmSectionCode   = 10
+	wasmSectionData   = 11
+)
+
+const (
+	wasmExternFunc   = 0
+	wasmExternMemory = 2
+	wasmExternGlobal = 3
+)
+
+const (
+	wasmUnreachable  = 0x00
+	wasmNop          = 0x01
+	wasmBlock        = 0x02
+	wasmLoop         = 0x03
+	wasmIf           = 0x04
+	wasmElse         = 0x05
+	wasmEnd          = 0x0b
+	wasmBr           = 0x0c
+	wasmBrIf         = 0x0d
+	wasmReturn       = 0x0f
+	wasmCall         = 0x10
+	wasmCallIndirect = 0x11
+	wasmDrop         = 0x1a
+	wasmLocalGet     = 0x20
+	wasmLocalSet     = 0x21
+	wasmLocalTee     = 0x22
+	wasmGlobalGet    = 0x23
+	wasmGlobalSet    = 0x24
+	wasmI32Load      = 0x28
+	wasmI32Load8U    = 0x2d
+	wasmI32Store     = 0x36
+	wasmI32Store8    = 0x3a
+	wasmMemorySize   = 0x3f
+	wasmMemoryGrow   = 0x40
+	wasmI32Const     = 0x41
+	wasmI32Eqz       = 0x45
+	wasmI32Eq        = 0x46
+	wasmI32Ne        = 0x47
+	wasmI32LtS       = 0x48
+	wasmI32LtU       = 0x49
+	wasmI32GtS       = 0x4a
+	wasmI32GtU       = 0x4b
+	wasmI32LeS       = 0x4c
+	wasmI32LeU       = 0x4d
+	wasmI32GeS       = 0x4e
+	wasmI32GeU       = 0x4f
+	wasmI32Add       = 0x6a
+	wasmI32Sub       = 0x6b
+	wasmI32Mul       = 0x6c
+	wasmI32DivS      = 0x6d
+	wasmI32DivU      = 0x6e
+	wasmI32RemS      = 0x6f
+	wasmI32RemU      = 0x70
+	wasmI32And       = 0x71
+	wasmI32Or        = 0x72
+	wasmI32Xor       = 0x73
+	wasmI32Shl       = 0x74
+	wasmI32ShrS      = 0x75
+	wasmI32ShrU      = 0x76
+)
+
+var wasmOpNames = map[byte]string{
+	wasmUnreachable: "unreachable", wasmNop: "nop", wasmBlock: "block", wasmLoop: "loop", wasmIf: "if",
+	wasmElse: "else", wasmEnd: "end", wasmBr: "br", wasmBrIf: "br_if", wasmReturn: "return", wasmCall: "call",
+	wasmCallIndirect: "call_indirect",
+	wasmDrop:         "drop", wasmLocalGet: "local.get", wasmLocalSet: "local.set", wasmLocalTee: "local.tee",
+	wasmGlobalGet: "global.get", wasmGlobalSet: "global.set", wasmI32Load: "i32.load", wasmI32Load8U: "i32.load8_u",
+	wasmI32Store: "i32.store", wasmI32Store8: "i32.store8", wasmMemorySize: "memory.size",
+	wasmMemoryGrow: "memory.grow", wasmI32Const: "i32.const", wasmI32Eqz: "i32.eqz", wasmI32Eq: "i32.eq",
+	wasmI32Ne: "i32.ne", wasmI32LtS: "i32.lt_s", wasmI32LtU: "i32.lt_u", wasmI32GtS: "i32.gt_s",
+	wasmI32GtU: "i32.gt_u", wasmI32LeS: "i32.le_s", wasmI32LeU: "i32.le_u", wasmI32GeS: "i32.ge_s",
+	wasmI32GeU: "i32.ge_u", wasmI32Add: "i32.add", wasmI32Sub: "i32.sub", wasmI32Mul: "i32.mul",
+	wasmI32DivS: "i32.div_s", wasmI32DivU: "i32.div_u", wasmI32RemS: "i32.rem_s", wasmI32RemU: "i32.rem_u",
+	wasmI32And: "i32.and", wasmI32Or: "i32.or", wasmI32Xor: "i32.xor", wasmI32Shl: "i32.shl",
+	wasmI32ShrS: "i32.shr_s", wasmI32ShrU: "i32.shr_u",
+}
+
+// wasmInstr is one WebAssembly instruction. Locals, functions and string addresses are referred to by temp and label
+// and only turned into indices when the module is encoded.
+type wasmInstr struct {
+	op byte
+
+	// the constant of i32.const, the depth of br and br_if, the offset of loads and stores, a global index or the
+	// number of parameters of the callee of call_indirect
+	imm int64
+
+	// the local of local.get, local.set and local.tee
+	temp ir.Temp
+
+	// the callee of call, or the string fragment whose address i32.const pushes
+	label ir.Label
+}
+
+// format writes the instruction in the text format, with the names of its temps and labels.
+func (i *wasmInstr) format(tm *ir.TempManagement) string {
+	name := wasmOpNames[i.op]
+	switch i.op {
+	case wasmLocalGet, wasmLocalSet, wasmLocalTee:
+		return name + " " + tempName(i.temp)
+	case wasmCall:
+		return name + " $" + tm.LabelString(i.label)
+	case wasmI32Const:
+		if i.label != 0 {
+			return name + " $" + tm.LabelString(i.label)
+		}
+
+		return fmt.Sprintf("%s %d", name, i.imm)
+	case wasmI32Load, wasmI32Load8U, wasmI32Store, wasmI32Store8:
+		if i.imm != 0 {
+			return fmt.Sprintf("%s offset=%d", name, i.imm)
+		}
+	case wasmBr, wasmBrIf, wasmGlobalGet, wasmGlobalSet:
+		return fmt.Sprintf("%s %d", name, i.imm)
+	case wasmCallIndirect:
+		return fmt.Sprintf("%s (params %d)", name, i.imm)
+	}
+
+	return name
+}
+
+// wasmLinker resolves the symbolic operands of the instructions of one function.
+type wasmLinker struct {
+	locals map[ir.Temp]uint32
+	funcs  map[ir.Label]uint32
+	data   map[ir.Label]int32
+
+	// typeOf returns the index of the type of the functions with the number of parameters
+	typeOf func(arity int) uint32
+}
+
+func (l *wasmLinker) encode(buf *bytes.Buffer, instrs []*wasmInstr) {
+	for _, i := range instrs {
+		buf.WriteByte(i.op)
+		switch i.op {
+		case wasmBlock, wasmLoop, wasmIf:
+			buf.WriteByte(wasmBlockVoid)
+		case wasmBr, wasmBrIf, wasmGlobalGet, wasmGlobalSet:
+			writeULEB(buf, uint64(i.imm))
+		case wasmCall:
+			writeULEB(buf, uint64(l.funcs[i.label]))
+		case wasmCallIndirect:
+			writeULEB(buf, uint64(l.typeOf(int(i.imm))))
+			buf.WriteByte(0)
+		case wasmLocalGet, wasmLocalSet, wasmLocalTee:
+			writeULEB(buf, uint64(l.locals[i.temp]))
+		case wasmI32Load, wasmI32Store:
+			writeULEB(buf, 2)
+			writeULEB(buf, uint64(i.imm))
+		case wasmI32Load8U, wasmI32Store8:
+			writeULEB(buf, 0)
+			writeULEB(buf, uint64(i.imm))
+		case wasmMemorySize, wasmMemoryGrow:
+			buf.WriteByte(0)
+		case wasmI32Const:
+			if i.label != 0 {
+				writeSLEB(buf, int64(l.data[i.label]))
+			} else {
+				writeSLEB(buf, i.imm)
+			}
+		}
+	}
+}
+
+func writeULEB(buf *bytes.Buffer, v uint64) {
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			buf.WriteByte(b)
+			return
+		}
+
+		buf.WriteByte(b | 0x80)
+	}
+}
+
+func writeSLEB(buf *bytes.Buffer, v int64) {
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			buf.WriteByte(b)
+			return
+		}
+
+		buf.WriteByte(b | 0x80)
+	}
+}
+
+func writeWasmName(buf *bytes.Buffer, name string) {
+	writeULEB(buf, uint64(len(name)))
+	buf.WriteString(name)
+}
+
+// writeWasmSection writes a section with its id and the size of its content.
+func writeWasmSection(buf *bytes.Buffer, id byte, content *bytes.Buffer) {
+	buf.WriteByte(id)
+	writeULEB(buf, uint64(content.Len()))
+	buf.Write(content.Bytes())
+}
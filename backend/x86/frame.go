@@ -0,0 +1,278 @@
+// Package x86 is the amd64 target: its frames and the selection of its instructions.
+package x86
+
+import (
+	"fmt"
+	"strings"
+
+	"tiger/ir"
+)
+
+const (
+	WordSize = 8
+)
+
+const (
+	// return value, also the implicit operand of idiv
+	rax ir.Temp = iota + 1
+	rbx
+	rcx
+
+	// high half of the idiv dividend
+	rdx
+	rsi
+	rdi
+
+	// frame pointer
+	rbp
+
+	// stack pointer
+	rsp
+	r8
+	r9
+	r10
+	r11
+	r12
+	r13
+	r14
+	r15
+)
+
+var (
+	// registers to pass the first six arguments, the System V order
+	argRegs = []ir.Temp{rdi, rsi, rdx, rcx, r8, r9}
+
+	// callee-saved registers
+	calleeSaves = []ir.Temp{rbx, r12, r13, r14, r15}
+
+	// caller-saved registers, all of them are trashed by a call
+	callerSaves = []ir.Temp{rax, rcx, rdx, rsi, rdi, r8, r9, r10, r11}
+
+	tempMap = map[ir.Temp]string{
+		rax: "%rax",
+		rbx: "%rbx",
+		rcx: "%rcx",
+		rdx: "%rdx",
+		rsi: "%rsi",
+		rdi: "%rdi",
+		rbp: "%rbp",
+		rsp: "%rsp",
+		r8:  "%r8",
+		r9:  "%r9",
+		r10: "%r10",
+		r11: "%r11",
+		r12: "%r12",
+		r13: "%r13",
+		r14: "%r14",
+		r15: "%r15",
+	}
+)
+
+func tempName(t ir.Temp) string {
+	v, ok := tempMap[t]
+	if ok {
+		return v
+	}
+
+	return t.String()
+}
+
+type InFrameAccess struct {
+	offset int32
+}
+
+// Exp converts InFrameAccess into ExpIr. The argument is the address of the stack frame that the access lives in.
+func (a *InFrameAccess) Exp(frameAddress ir.ExpIr) ir.ExpIr {
+	return &ir.MemExpIr{Mem: &ir.BinOpExpIr{
+		Binop: ir.PlusIr,
+		Left:  frameAddress,
+		Right: &ir.ConstExpIr{Value: a.offset},
+	}}
+}
+
+type InRegAccess struct {
+	temp ir.Temp
+}
+
+func (a *InRegAccess) Exp(_ ir.ExpIr) ir.ExpIr {
+	return &ir.TempExpIr{Temp: a.temp}
+}
+
+// Frame lays out a System V x86-64 frame:
+//
+//	16+8*k(%rbp)  stack arguments, from the seventh one
+//	 8(%rbp)      return address
+//	 0(%rbp)      saved %rbp
+//	-8(%rbp)      pointer map of the function, which the collector of runtime_x86.s reads
+//	-8*k(%rbp)    escaping register arguments, locals and spills
+type Frame struct {
+	tm         *ir.TempManagement
+	name       ir.Label
+	accesses   []ir.FrameAccess
+	shiftInsts []ir.StmIr
+	locals     int32
+
+	// the temps and the offsets from %rbp of the words that hold heap pointers
+	ptrTemps   ir.TempSet
+	ptrOffsets []int32
+}
+
+func NewFrame(tm *ir.TempManagement, name ir.Label, escapes []bool) ir.Frame {
+	frame := Frame{
+		tm:       tm,
+		name:     name,
+		locals:   1,
+		ptrTemps: ir.NewTempSet(),
+	}
+
+	frame.createAccesses(escapes)
+	return &frame
+}
+
+func (f *Frame) createAccesses(escapes []bool) {
+	for i, escape := range escapes {
+		// the caller pushes the arguments after the sixth one on the stack
+		if i >= len(argRegs) {
+			offset := int32(2+i-len(argRegs)) * WordSize
+			f.accesses = append(f.accesses, &InFrameAccess{offset: offset})
+			continue
+		}
+
+		// the first 6 arguments are passed in registers and copied to the frame only when they escape
+		acc := f.AllocLocal(escape)
+		f.accesses = append(f.accesses, acc)
+		f.shiftInsts = append(f.shiftInsts, &ir.MoveStmIr{
+			Dst: acc.Exp(&ir.TempExpIr{Temp: rbp}),
+			Src: &ir.TempExpIr{Temp: argRegs[i]},
+		})
+	}
+}
+
+func (f *Frame) TempMap() map[ir.Temp]string {
+	return tempMap
+}
+
+func (f *Frame) TempName(t ir.Temp) string {
+	return tempName(t)
+}
+
+func (f *Frame) Name() ir.Label {
+	return f.name
+}
+
+func (f *Frame) Formals() []ir.FrameAccess {
+	return f.accesses
+}
+
+func (f *Frame) AllocLocal(escape bool) ir.FrameAccess {
+	if escape {
+		f.locals++
+		return &InFrameAccess{offset: -WordSize * f.locals}
+	}
+
+	return &InRegAccess{f.tm.NewTemp()}
+}
+
+func (f *Frame) Pointer(acc ir.FrameAccess) {
+	switch v := acc.(type) {
+	case *InRegAccess:
+		f.ptrTemps.Add(v.temp)
+	case *InFrameAccess:
+		f.ptrOffsets = append(f.ptrOffsets, v.offset)
+	}
+}
+
+// PointerResult has nothing to record, the calls that return heap pointers are marked where they are made.
+func (f *Frame) PointerResult() {}
+
+func (f *Frame) PointerTemps() ir.TempSet {
+	return f.ptrTemps
+}
+
+func (f *Frame) FP() ir.Temp {
+	return rbp
+}
+
+func (f *Frame) RV() ir.Temp {
+	return rax
+}
+
+func (f *Frame) CodeGen(tm *ir.TempManagement, stm ir.StmIr) []ir.Instr {
+	return NewCodeGenerator(tm).GenCode(stm)
+}
+
+// ProcEntryExit1 moves the register arguments to where the body expects them and keeps the callee-saved registers in
+// fresh temporaries, so that the register allocator only spills them when the body really needs them.
+func (f *Frame) ProcEntryExit1(body ir.StmIr) ir.StmIr {
+	saved := make([]ir.Temp, len(calleeSaves))
+	saves := make([]ir.StmIr, 0, len(calleeSaves))
+	for i, reg := range calleeSaves {
+		saved[i] = f.tm.NewTemp()
+		saves = append(saves, &ir.MoveStmIr{
+			Dst: &ir.TempExpIr{Temp: saved[i]},
+			Src: &ir.TempExpIr{Temp: reg},
+		})
+	}
+
+	restores := make([]ir.StmIr, 0, len(calleeSaves))
+	for i := len(calleeSaves) - 1; i >= 0; i-- {
+		restores = append(restores, &ir.MoveStmIr{
+			Dst: &ir.TempExpIr{Temp: calleeSaves[i]},
+			Src: &ir.TempExpIr{Temp: saved[i]},
+		})
+	}
+
+	stms := append(append([]ir.StmIr{}, f.shiftInsts...), saves...)
+	stms = append(append(stms, body), restores...)
+	return ir.SeqStm(stms...)
+}
+
+// ProcEntryExit2 notifies the register allocation that the return value, the stack and frame pointers and the
+// callee-saved registers are live out at the end of the function. Keeping %rsp and %rbp live everywhere prevents them
+// from being handed out to other temps.
+func (f *Frame) ProcEntryExit2(body []ir.Instr) []ir.Instr {
+	return append(body, &ir.OperInstr{
+		Src: append([]ir.Temp{rax, rsp, rbp}, calleeSaves...),
+	})
+}
+
+// ProcEntryExit3 stores the pointer map in the prologue, and clears the locals it lists so that the collector never
+// finds stale words in them. The map follows the function, as the number of the words that hold heap pointers and
+// their offsets from %rbp.
+func (f *Frame) ProcEntryExit3() (string, string) {
+	// keep %rsp 16-byte aligned as the System V ABI requires
+	name := f.tm.LabelString(f.Name())
+	size := (f.locals*WordSize + 15) / 16 * 16
+	prolog := fmt.Sprintf("%s:\n\tpushq\t%%rbp\n\tmovq\t%%rsp, %%rbp\n\tsubq\t$%d, %%rsp\n", name, size)
+	epilog := "\tmovq\t%rbp, %rsp\n\tpopq\t%rbp\n\tret\n\n"
+	if len(f.ptrOffsets) == 0 {
+		return prolog + "\tmovq\t$0, -8(%rbp)\n", epilog
+	}
+
+	// %r11 is neither an argument register nor callee-saved, it holds nothing yet
+	prolog += fmt.Sprintf("\tleaq\t_map_%s(%%rip), %%r11\n\tmovq\t%%r11, -8(%%rbp)\n", name)
+	words := []string{fmt.Sprintf("%d", len(f.ptrOffsets))}
+	for _, offset := range f.ptrOffsets {
+		words = append(words, fmt.Sprintf("%d", offset))
+		if offset < 0 {
+			prolog += fmt.Sprintf("\tmovq\t$0, %d(%%rbp)\n", offset)
+		}
+	}
+
+	epilog += fmt.Sprintf("\t.data\n\t.align\t8\n_map_%s:\n\t.quad\t%s\n\t.text\n\n", name,
+		strings.Join(words, ", "))
+	return prolog, epilog
+}
+
+// TableFrag writes a table fragment as aligned 64-bit words.
+func TableFrag(tm *ir.TempManagement, sb *strings.Builder, frag *ir.TableFrag) string {
+	labels := make([]string, 0, len(frag.Labels))
+	for _, label := range frag.Labels {
+		labels = append(labels, tm.LabelString(label))
+	}
+
+	sb.WriteString("\t.align\t8\n")
+	sb.WriteString(tm.LabelString(frag.Label))
+	sb.WriteString(":\t.quad\t" + strings.Join(labels, ", ") + "\n")
+	return sb.String()
+}
@@ -0,0 +1,401 @@
+package x86
+
+import (
+	"fmt"
+	"strings"
+
+	"tiger/ir"
+)
+
+// CodeGenerator selects AT&T syntax x86-64 instructions. Arithmetic is two-address, so a BinOp first copies its
+// left operand into the destination and then combines the right operand into it; the destination is listed both as
+// source and destination of the second instruction.
+type CodeGenerator struct {
+	tm           *ir.TempManagement
+	instructions []ir.Instr
+}
+
+func NewCodeGenerator(tm *ir.TempManagement) *CodeGenerator {
+	return &CodeGenerator{tm: tm}
+}
+
+func (c *CodeGenerator) GenCode(stm ir.StmIr) []ir.Instr {
+	c.munchStm(stm)
+	return c.instructions
+}
+
+func (c *CodeGenerator) emit(instr ir.Instr) {
+	c.instructions = append(c.instructions, instr)
+}
+
+func (c *CodeGenerator) munchStm(s ir.StmIr) {
+	switch v := s.(type) {
+	case *ir.SeqStmIr:
+		c.munchStm(v.First)
+		c.munchStm(v.Second)
+
+	case *ir.LabelStmIr:
+		c.emit(&ir.LabelInstr{
+			Assem: c.tm.LabelString(v.Label) + ":",
+			Lab:   v.Label,
+		})
+
+	case *ir.MoveStmIr:
+		switch v1 := v.Dst.(type) {
+
+		// Move to memory
+		case *ir.MemExpIr:
+			if v2, ok := v.Src.(*ir.ConstExpIr); ok {
+				addr, src := c.munchAddr(v1.Mem, 0)
+				c.emit(&ir.OperInstr{
+					Assem: fmt.Sprintf("movq $%d, %s", v2.Value, addr),
+					Src:   src,
+				})
+
+				return
+			}
+
+			value := c.munchExp(v.Src)
+			addr, src := c.munchAddr(v1.Mem, 1)
+			c.emit(&ir.OperInstr{
+				Assem: "movq `s0, " + addr,
+				Src:   append([]ir.Temp{value}, src...),
+			})
+
+		// Load to register
+		case *ir.TempExpIr:
+			switch v2 := v.Src.(type) {
+			case *ir.ConstExpIr:
+				c.emit(&ir.OperInstr{
+					Assem: fmt.Sprintf("movq $%d, `d0", v2.Value),
+					Dst:   []ir.Temp{v1.Temp},
+				})
+
+			case *ir.NameExpIr:
+				c.emit(&ir.OperInstr{
+					Assem: "leaq " + c.tm.LabelString(v2.Label) + "(%rip), `d0",
+					Dst:   []ir.Temp{v1.Temp},
+				})
+
+			case *ir.MemExpIr:
+				addr, src := c.munchAddr(v2.Mem, 0)
+				c.emit(&ir.OperInstr{
+					Assem: "movq " + addr + ", `d0",
+					Dst:   []ir.Temp{v1.Temp},
+					Src:   src,
+				})
+
+			case *ir.CallExpIr:
+				c.munchCall(v2)
+				c.emit(&ir.MoveInstr{
+					Assem: "movq `s0, `d0",
+					Dst:   v1.Temp,
+					Src:   rax,
+				})
+
+			// move register to register
+			default:
+				c.emit(&ir.MoveInstr{
+					Assem: "movq `s0, `d0",
+					Dst:   v1.Temp,
+					Src:   c.munchExp(v.Src),
+				})
+			}
+
+		default:
+			panic("invalid instruction arguments")
+		}
+
+	case *ir.JumpStmIr:
+		switch v1 := v.Exp.(type) {
+		case *ir.NameExpIr:
+			c.emit(&ir.OperInstr{
+				Assem: "jmp `j0",
+				Jumps: []ir.Label{v1.Label},
+			})
+
+		default:
+			c.emit(&ir.OperInstr{
+				Assem: "jmp *`s0",
+				Src:   []ir.Temp{c.munchExp(v.Exp)},
+				Jumps: v.Labels,
+			})
+		}
+
+	case *ir.CJumpStmIr:
+		relop, left, right := v.Relop, v.Left, v.Right
+		if _, ok := left.(*ir.ConstExpIr); ok {
+			relop, left, right = relop.Commute(), right, left
+		}
+
+		if v1, ok := right.(*ir.ConstExpIr); ok {
+			c.emit(&ir.OperInstr{
+				Assem: fmt.Sprintf("cmpq $%d, `s0", v1.Value),
+				Src:   []ir.Temp{c.munchExp(left)},
+			})
+		} else {
+			// cmpq b, a sets the flags of a - b
+			c.emit(&ir.OperInstr{
+				Assem: "cmpq `s1, `s0",
+				Src:   []ir.Temp{c.munchExp(left), c.munchExp(right)},
+			})
+		}
+
+		c.emit(&ir.OperInstr{
+			Assem: jcc(relop) + " `j0\n\tjmp `j1",
+			Jumps: []ir.Label{v.TrueLabel, v.FalseLabel},
+		})
+
+	case *ir.ExpStmIr:
+		if v1, ok := v.Exp.(*ir.CallExpIr); ok {
+			c.munchCall(v1)
+			return
+		}
+
+		c.munchExp(v.Exp)
+	}
+}
+
+func jcc(relop ir.RelOpIr) string {
+	switch relop {
+	case ir.EqIr:
+		return "je"
+	case ir.NeIr:
+		return "jne"
+	case ir.LtIr:
+		return "jl"
+	case ir.GtIr:
+		return "jg"
+	case ir.LeIr:
+		return "jle"
+	case ir.GeIr:
+		return "jge"
+	}
+
+	panic("invalid relational operator")
+}
+
+// munchAddr turns the address of a MemExpIr into an x86 memory operand, using the displacement and scaled index forms
+// when possible. The registers of the operand are referred to as `s<first>, `s<first+1> and returned in that order.
+func (c *CodeGenerator) munchAddr(addr ir.ExpIr, first int) (string, []ir.Temp) {
+	if v, ok := addr.(*ir.BinOpExpIr); ok {
+		switch v.Binop {
+		case ir.PlusIr:
+			if n, ok := ir.ConstValue(v.Right); ok {
+				return fmt.Sprintf("%d(`s%d)", n, first), []ir.Temp{c.munchExp(v.Left)}
+			}
+
+			if n, ok := ir.ConstValue(v.Left); ok {
+				return fmt.Sprintf("%d(`s%d)", n, first), []ir.Temp{c.munchExp(v.Right)}
+			}
+
+			if v1, ok := v.Right.(*ir.BinOpExpIr); ok && v1.Binop == ir.MulIr {
+				if n, ok := ir.ConstValue(v1.Right); ok && (n == 1 || n == 2 || n == 4 || n == 8) {
+					base, index := c.munchExp(v.Left), c.munchExp(v1.Left)
+					return fmt.Sprintf("(`s%d,`s%d,%d)", first, first+1, n), []ir.Temp{base, index}
+				}
+			}
+
+			base, index := c.munchExp(v.Left), c.munchExp(v.Right)
+			return fmt.Sprintf("(`s%d,`s%d)", first, first+1), []ir.Temp{base, index}
+
+		case ir.MinusIr:
+			if n, ok := ir.ConstValue(v.Right); ok {
+				return fmt.Sprintf("%d(`s%d)", -n, first), []ir.Temp{c.munchExp(v.Left)}
+			}
+		}
+	}
+
+	return fmt.Sprintf("(`s%d)", first), []ir.Temp{c.munchExp(addr)}
+}
+
+// munchCall evaluates the arguments, passes the first six in registers and pushes the others from right to left,
+// keeping %rsp 16-byte aligned at the call. The result is left in %rax.
+func (c *CodeGenerator) munchCall(call *ir.CallExpIr) {
+	args := make([]ir.Temp, 0, len(call.Args))
+	for _, arg := range call.Args {
+		args = append(args, c.munchExp(arg))
+	}
+
+	stackArgs := 0
+	if len(args) > len(argRegs) {
+		stackArgs = len(args) - len(argRegs)
+	}
+
+	if stackArgs%2 == 1 {
+		c.emit(&ir.OperInstr{Assem: "subq $8, %rsp"})
+	}
+
+	for i := len(args) - 1; i >= len(argRegs); i-- {
+		c.emit(&ir.OperInstr{
+			Assem: "pushq `s0",
+			Src:   []ir.Temp{args[i]},
+		})
+	}
+
+	src := make([]ir.Temp, 0, len(argRegs)+1)
+	for i := 0; i < len(args) && i < len(argRegs); i++ {
+		c.emit(&ir.MoveInstr{
+			Assem: "movq `s0, `d0",
+			Dst:   argRegs[i],
+			Src:   args[i],
+		})
+
+		src = append(src, argRegs[i])
+	}
+
+	if name, ok := call.Exp.(*ir.NameExpIr); ok {
+		c.emit(&ir.OperInstr{
+			Assem: "call " + c.tm.LabelString(name.Label),
+			Dst:   callerSaves,
+			Src:   src,
+		})
+	} else {
+		c.emit(&ir.OperInstr{
+			Assem: "call *`s0",
+			Dst:   callerSaves,
+			Src:   append([]ir.Temp{c.munchExp(call.Exp)}, src...),
+		})
+	}
+
+	if stackArgs > 0 {
+		c.emit(&ir.OperInstr{Assem: fmt.Sprintf("addq $%d, %%rsp", (stackArgs+stackArgs%2)*WordSize)})
+	}
+}
+
+func (c *CodeGenerator) munchExp(exp ir.ExpIr) ir.Temp {
+	switch t := exp.(type) {
+	case *ir.CallExpIr:
+		c.munchCall(t)
+		return c.gen(func(temp ir.Temp) {
+			c.emit(&ir.MoveInstr{
+				Assem: "movq `s0, `d0",
+				Dst:   temp,
+				Src:   rax,
+			})
+		})
+
+	case *ir.MemExpIr:
+		return c.gen(func(temp ir.Temp) {
+			addr, src := c.munchAddr(t.Mem, 0)
+			c.emit(&ir.OperInstr{
+				Assem: "movq " + addr + ", `d0",
+				Dst:   []ir.Temp{temp},
+				Src:   src,
+			})
+		})
+
+	case *ir.BinOpExpIr:
+		if t.Binop == ir.DivIr {
+			return c.munchDiv(t)
+		}
+
+		left, right := t.Left, t.Right
+		if _, ok := left.(*ir.ConstExpIr); ok && t.Binop != ir.MinusIr {
+			left, right = right, left
+		}
+
+		var op string
+		switch t.Binop {
+		case ir.PlusIr:
+			op = "addq"
+		case ir.MinusIr:
+			op = "subq"
+		case ir.MulIr:
+			op = "imulq"
+		default:
+			panic("invalid binary operator")
+		}
+
+		return c.gen(func(temp ir.Temp) {
+			if v, ok := right.(*ir.ConstExpIr); ok {
+				c.emit(&ir.MoveInstr{
+					Assem: "movq `s0, `d0",
+					Dst:   temp,
+					Src:   c.munchExp(left),
+				})
+
+				c.emit(&ir.OperInstr{
+					Assem: fmt.Sprintf("%s $%d, `d0", op, v.Value),
+					Dst:   []ir.Temp{temp},
+					Src:   []ir.Temp{temp},
+				})
+
+				return
+			}
+
+			l, r := c.munchExp(left), c.munchExp(right)
+			c.emit(&ir.MoveInstr{
+				Assem: "movq `s0, `d0",
+				Dst:   temp,
+				Src:   l,
+			})
+
+			c.emit(&ir.OperInstr{
+				Assem: op + " `s0, `d0",
+				Dst:   []ir.Temp{temp},
+				Src:   []ir.Temp{r, temp},
+			})
+		})
+
+	case *ir.TempExpIr:
+		return t.Temp
+
+	case *ir.ConstExpIr:
+		return c.gen(func(temp ir.Temp) {
+			c.emit(&ir.OperInstr{
+				Assem: fmt.Sprintf("movq $%d, `d0", t.Value),
+				Dst:   []ir.Temp{temp},
+			})
+		})
+
+	case *ir.NameExpIr:
+		return c.gen(func(temp ir.Temp) {
+			c.emit(&ir.OperInstr{
+				Assem: "leaq " + c.tm.LabelString(t.Label) + "(%rip), `d0",
+				Dst:   []ir.Temp{temp},
+			})
+		})
+	}
+
+	sb := strings.Builder{}
+	exp.PrintExpIr(&sb, c.tm.Strings(), 0)
+	panic("invalid IR exp " + sb.String())
+}
+
+// munchDiv uses idivq, which divides %rdx:%rax by its operand and leaves the quotient in %rax. cqto sign-extends %rax
+// into %rdx first.
+func (c *CodeGenerator) munchDiv(exp *ir.BinOpExpIr) ir.Temp {
+	l, r := c.munchExp(exp.Left), c.munchExp(exp.Right)
+	c.emit(&ir.MoveInstr{
+		Assem: "movq `s0, `d0",
+		Dst:   rax,
+		Src:   l,
+	})
+
+	c.emit(&ir.OperInstr{
+		Assem: "cqto",
+		Dst:   []ir.Temp{rdx},
+		Src:   []ir.Temp{rax},
+	})
+
+	c.emit(&ir.OperInstr{
+		Assem: "idivq `s0",
+		Dst:   []ir.Temp{rax, rdx},
+		Src:   []ir.Temp{r, rax, rdx},
+	})
+
+	return c.gen(func(temp ir.Temp) {
+		c.emit(&ir.MoveInstr{
+			Assem: "movq `s0, `d0",
+			Dst:   temp,
+			Src:   rax,
+		})
+	})
+}
+
+func (c *CodeGenerator) gen(f func(t ir.Temp)) ir.Temp {
+	t := c.tm.NewTemp()
+	f(t)
+	return t
+}
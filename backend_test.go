@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The run functions of the backends, runC, runMips, runRiscv, runX86 and runWasm, compile a source for their target,
+// run it and return what it prints. The source is named test.tig, the variants that end in File take the name of the
+// file, which the imports of the source are relative to.
+
+// backendProgram has what every backend has to get right: recursion, more arguments than there are argument
+// registers, records, division by a negative number and the strings of the runtime.
+const backendProgram = `
+let function fact(n: int): int =
+        if n = 0 then 1 else n * fact(n - 1)
+    function sum10(a: int, b: int, c: int, d: int, e: int, f: int, g: int, h: int, i: int, j: int): int =
+        a + b + c + d + e + f + g + h + i - j
+    type list = {head: int, tail: list}
+    var l := list{head = 1, tail = list{head = 2, tail = nil}}
+    var t := l.tail
+    var x := 100
+in (
+    printi(fact(10));
+    print(" ");
+    printi(sum10(1, 2, 3, 4, 5, 6, 7, 8, 9, 10));
+    print(" ");
+    printi(x / -7);
+    print(" ");
+    printi(t.head);
+    print(concat(" tiger", "\n"))
+)
+end
+`
+
+func TestBackends_Program(t *testing.T) {
+	expected := "3628800 35 -14 2 tiger\n"
+	require.Equal(t, expected, runC(t, []byte(backendProgram)))
+	require.Equal(t, expected, runMips(t, []byte(backendProgram)))
+	require.Equal(t, expected, runRiscv(t, backendProgram))
+	require.Equal(t, expected, runX86(t, backendProgram))
+	require.Equal(t, expected, runWasm(t, backendProgram))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+	"tiger/runtime"
+	"tiger/sim"
+)
+
+// runC compiles src to C, builds it with the runtime using the system C compiler and returns what the program prints.
+func runC(t *testing.T, src []byte) string {
+	return runCFile(t, "test.tig", src)
+}
+
+// runCFile is runC for the source of file.
+func runCFile(t *testing.T, file string, src []byte) string {
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("cc is not available")
+	}
+
+	res := compileTest(t, compiler.Options{File: file, Arch: "c"}, src)
+
+	dir := t.TempDir()
+	cFile, runtimeFile, bin := filepath.Join(dir, "prog.c"), filepath.Join(dir, "runtime.c"), filepath.Join(dir, "prog")
+	require.NoError(t, os.WriteFile(cFile, res.Output, 0644))
+	require.NoError(t, os.WriteFile(runtimeFile, []byte(runtime.C), 0644))
+
+	b, err := exec.Command("cc", "-o", bin, cFile, runtimeFile).CombinedOutput()
+	require.NoError(t, err, string(b))
+
+	b, err = exec.Command(bin).Output()
+	require.NoError(t, err)
+	return string(b)
+}
+
+// runMips compiles src for mips and runs it on the simulator.
+func runMips(t *testing.T, src []byte) string {
+	prog, err := sim.AssembleMips(string(compileTest(t, compiler.Options{File: "test.tig", Arch: "mips"}, src).Output))
+	require.NoError(t, err)
+
+	out := bytes.Buffer{}
+	sim := sim.NewMips(prog, strings.NewReader(""), &out)
+	sim.MaxSteps = 10000000
+	_, err = sim.Run()
+	require.NoError(t, err)
+	return out.String()
+}
+
+// TestC_Strings writes literals with the characters that C escapes and a trigraph, which must stay as they are.
+func TestC_Strings(t *testing.T) {
+	require.Equal(t, "ti??\"ger\\\n", runC(t, []byte(`print(concat("ti??\"ger", "\\\n"))`)))
+}
+
+// TestC_MatchesMips uses the C backend as an oracle for the MIPS backend.
+func TestC_MatchesMips(t *testing.T) {
+	for _, name := range []string{"hello2", "vars", "nested", "integers"} {
+		src, err := os.ReadFile("./test_files/" + name + ".tig")
+		require.NoError(t, err)
+		require.Equal(t, runC(t, src), runMips(t, src), name)
+	}
+}
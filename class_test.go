@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+	"tiger/ir"
+)
+
+const classProgram = `
+let var greeting := "hi "
+    class Animal {
+        var name := "animal"
+        var legs: int := 4
+        method speak() = (print(greeting); print(name); print("\n"))
+        method count(n: int): int = legs * n
+    }
+    class Bird extends Animal {
+        var wings := 2
+        method speak() = (print("tweet "); print(name); print("\n"))
+        method count(n: int): int = (legs + wings) * n
+    }
+    class Parrot extends Bird {
+        method rename(s: string) = name := s
+        method twice(): int = self.count(1) + self.count(1)
+    }
+    function show(a: Animal) = (a.speak(); printi(a.count(10)); print("\n"))
+    function total(n: int): int =
+        let var offset := n
+            class Shifted extends Parrot {
+                method count(n: int): int = n + offset
+            }
+            var s := new Shifted
+        in s.twice()
+        end
+    var a: Animal := new Animal
+    var p := new Parrot
+in
+    p.legs := 3;
+    p.rename("polly");
+    show(a);
+    show(p);
+    a := p;
+    a.speak();
+    if a = p then print("same\n");
+    printi(p.twice() + total(100));
+    print("\n")
+end
+`
+
+const classOutput = "hi animal\n40\ntweet polly\n50\ntweet polly\nsame\n212\n"
+
+// checkProgram parses and checks src, returning the error of the semantic analysis.
+func checkProgram(t *testing.T, src string) error {
+	c, err := compiler.NewCompilation(compiler.Options{File: "test.tig", Arch: "wasm"})
+	require.NoError(t, err)
+
+	_, err = c.Translate([]byte(src))
+	return err
+}
+
+func TestClass_Dispatch(t *testing.T) {
+	require.Equal(t, classOutput, runWasm(t, classProgram))
+	require.Equal(t, classOutput, runRiscv(t, classProgram))
+	require.Equal(t, classOutput, runC(t, []byte(classProgram)))
+	for _, archName := range []string{"c", "wasm"} {
+		out, _, err := interpret(t, archName, ir.IrCanon, classProgram)
+		require.NoError(t, err)
+		require.Equal(t, classOutput, out, archName)
+	}
+}
+
+func TestClass_Errors(t *testing.T) {
+	for _, src := range []string{
+		// the super class must be a class
+		`let type r = {a: int} class C extends r {} in () end`,
+		`let class A extends B {} class B extends A {} in () end`,
+		// an override takes and returns the same types
+		`let class A { method m(x: int) = () } class B extends A { method m(x: string) = () } in () end`,
+		`let class A { var a := 1 } class B extends A { var a := 2 } in () end`,
+		`let class A { method m() = () } var a := new A in a.n() end`,
+		`let class A { method m(x: int) = () } var a := new A in a.m("x") end`,
+		`let class A { var a := 1 } var a := new A in a.b end`,
+		// an object of a class is not one of its subclasses
+		`let class A {} class B extends A {} var b: B := new A in () end`,
+		`let class A { var a := nil } in () end`,
+		`let var x := 1 in new x end`,
+	} {
+		require.Error(t, checkProgram(t, src), src)
+	}
+
+	require.NoError(t, checkProgram(t, `let class A {} class B extends A {} var a: A := new B in a := nil end`))
+}
+
+func TestClass_Duplicate(t *testing.T) {
+	for _, src := range []string{
+		`let class A { method get(): int = 1 } class A { method h(): int = 2 } var b := new A in b.get() end`,
+		`let class A { method get(x: int): int = x } class A { method h(): int = 2 } var b := new A in b.get(1) end`,
+	} {
+		require.Equal(t, []string{"E0117"}, diagnosticCodes(t, checkProgram(t, src)), src)
+	}
+}
+
+// TestClass_Scopes declares classes of the same name in nested scopes, whose methods and initializers have their own
+// labels.
+func TestClass_Scopes(t *testing.T) {
+	src := `
+let class A { var n := 1 method get(): int = n }
+    var a := new A
+in
+    printi(a.get());
+    let class A { var n := 2 method get(): int = n }
+        var b := new A
+    in printi(b.get())
+    end
+end
+`
+
+	require.Equal(t, "12", runWasm(t, src))
+	require.Equal(t, "12", runRiscv(t, src))
+	require.Equal(t, "12", runMips(t, []byte(src)))
+	require.Equal(t, "12", runC(t, []byte(src)))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+)
+
+// compileTest compiles src and requires it to have no errors.
+func compileTest(t *testing.T, opts compiler.Options, src []byte) compiler.Result {
+	res, err := compiler.Compile(src, opts)
+	require.NoError(t, err)
+	return res
+}
+
+// TestCompile_Concurrent compiles programs at the same time and expects the same modules as when they are compiled one
+// after the other, since the compilations share no state.
+func TestCompile_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	names := []string{"conditions", "functions", "record", "spill", "nested"}
+	srcs, expected := make([][]byte, len(names)), make([][]byte, len(names))
+	for i, name := range names {
+		src, err := os.ReadFile("./test_files/" + name + ".tig")
+		require.NoError(t, err)
+		srcs[i] = src
+		expected[i] = compileTest(t, compiler.Options{File: name + ".tig", Arch: "wasm"}, src).Output
+	}
+
+	outs := make([][]byte, len(names))
+	errs := make([]error, len(names))
+	wg := sync.WaitGroup{}
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := compiler.Compile(srcs[i], compiler.Options{File: names[i] + ".tig", Arch: "wasm"})
+			outs[i], errs[i] = res.Output, err
+		}(i)
+	}
+
+	wg.Wait()
+	for i, name := range names {
+		require.NoError(t, errs[i], name)
+		require.True(t, bytes.Equal(expected[i], outs[i]), name)
+	}
+}
+
+// TestCompile_OtherDirectory compiles from a directory other than the root of the repository, which works since the
+// runtimes are embedded. It changes the working directory, so it does not run in parallel.
+func TestCompile_OtherDirectory(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	src := []byte(`print("hi")`)
+	for _, arch := range []string{"mips", "riscv", "amd64"} {
+		a, err := compiler.NewArch(arch)
+		require.NoError(t, err)
+		out := compileTest(t, compiler.Options{File: "test.tig", Arch: arch}, src).Output
+		require.True(t, strings.HasPrefix(string(out), a.Runtime), arch)
+	}
+
+	for _, arch := range []string{"c", "wasm"} {
+		compileTest(t, compiler.Options{File: "test.tig", Arch: arch}, src)
+	}
+}
+
+func TestCompile_Diagnostics(t *testing.T) {
+	t.Parallel()
+
+	res, err := compiler.Compile([]byte("let var x := \"a\" in x + 1 end"), compiler.Options{File: "test.tig", Arch: "riscv"})
+	require.Error(t, err)
+	require.Nil(t, res.Output)
+	require.Len(t, res.Diagnostics, 1)
+
+	_, err = compiler.Compile([]byte("1"), compiler.Options{File: "test.tig", Arch: "vax"})
+	require.Error(t, err)
+
+	_, err = compiler.Compile([]byte("function f() = ()"), compiler.Options{File: "lib.tig", Arch: "wasm"})
+	require.Error(t, err)
+}
+
+// TestCompile_Jobs allocates the functions in parallel and expects the same output and the same dumps of the
+// allocation as when they are allocated one after the other, on each target with registers.
+func TestCompile_Jobs(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"functions", "nested", "spill", "hello3"} {
+		src, err := os.ReadFile("./test_files/" + name + ".tig")
+		require.NoError(t, err)
+
+		for _, arch := range []string{"mips", "riscv", "amd64"} {
+			seqDump, parDump := bytes.Buffer{}, bytes.Buffer{}
+			seq := compileTest(t, compiler.Options{File: name + ".tig", Arch: arch, Dump: "flow,igraph,alloc",
+				DumpOut: &seqDump}, src).Output
+			par := compileTest(t, compiler.Options{File: name + ".tig", Arch: arch, Jobs: 8, Dump: "flow,igraph,alloc",
+				DumpOut: &parDump}, src).Output
+			require.Equal(t, string(seq), string(par), "%s %s", name, arch)
+			require.Equal(t, seqDump.String(), parDump.String(), "%s %s", name, arch)
+		}
+	}
+}
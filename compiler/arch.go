@@ -0,0 +1,170 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"tiger/backend/cgen"
+	"tiger/backend/mips"
+	"tiger/backend/riscv"
+	"tiger/backend/wasm"
+	"tiger/backend/x86"
+	"tiger/ir"
+	"tiger/runtime"
+	"tiger/syntax"
+)
+
+func NewFrameFactory(arch string) ir.FrameFactoryFunc {
+	switch arch {
+	case "mips":
+		return mips.NewFrame
+	case "amd64":
+		return x86.NewFrame
+	case "riscv":
+		return riscv.NewFrame
+	case "c":
+		return cgen.NewFrame
+	case "wasm":
+		return wasm.NewFrame
+	default:
+		panic("not supported yet")
+	}
+}
+
+// Arch groups what the driver needs to know about a target besides its frames: the word size used to lay out records
+// and arrays, the source of the runtime that is prepended to the output (linked with it for C, hosting it for wasm),
+// the extension of the output file, how string and table fragments are written and how the functions of the runtime
+// are listed. Every runtime collects garbage. The runtimes are embedded in the compiler, see package runtime.
+type Arch struct {
+	Name         string
+	Ext          string
+	WordSize     int32
+	FrameFactory ir.FrameFactoryFunc
+	Runtime      string
+	stringFrag   func(tm *ir.TempManagement, sb *strings.Builder, frag *ir.StrFrag) string
+	tableFrag    func(tm *ir.TempManagement, sb *strings.Builder, frag *ir.TableFrag) string
+	runtimeFuncs func(runtime string) map[string]bool
+}
+
+func NewArch(arch string) (*Arch, error) {
+	switch arch {
+	case "mips":
+		return &Arch{
+			Name:         arch,
+			Ext:          ".s",
+			WordSize:     mips.WordSize,
+			FrameFactory: NewFrameFactory(arch),
+			Runtime:      runtime.Mips,
+			stringFrag:   mips.StringFrag,
+			tableFrag:    WordTableFrag,
+			runtimeFuncs: asmRuntimeFuncs,
+		}, nil
+	case "amd64":
+		return &Arch{
+			Name:         arch,
+			Ext:          ".s",
+			WordSize:     x86.WordSize,
+			FrameFactory: NewFrameFactory(arch),
+			Runtime:      runtime.X86,
+			stringFrag:   GnuStringFrag,
+			tableFrag:    x86.TableFrag,
+			runtimeFuncs: asmRuntimeFuncs,
+		}, nil
+	case "riscv":
+		return &Arch{
+			Name:         arch,
+			Ext:          ".s",
+			WordSize:     riscv.WordSize,
+			FrameFactory: NewFrameFactory(arch),
+			Runtime:      runtime.Riscv,
+			stringFrag:   GnuStringFrag,
+			tableFrag:    WordTableFrag,
+			runtimeFuncs: asmRuntimeFuncs,
+		}, nil
+	case "c":
+		return &Arch{
+			Name:         arch,
+			Ext:          ".c",
+			WordSize:     cgen.WordSize,
+			FrameFactory: NewFrameFactory(arch),
+			Runtime:      runtime.C,
+			stringFrag:   cgen.StringFrag,
+			runtimeFuncs: cgen.RuntimeFuncs,
+		}, nil
+	case "wasm":
+		return &Arch{
+			Name:         arch,
+			Ext:          ".wasm",
+			WordSize:     wasm.WordSize,
+			FrameFactory: NewFrameFactory(arch),
+			Runtime:      runtime.Wasm,
+			stringFrag:   wasm.StringFrag,
+			runtimeFuncs: wasm.RuntimeFuncs,
+		}, nil
+	}
+
+	return nil, unsupportedArchErr(arch)
+}
+
+// GnuStringFrag writes a string fragment for the GNU assembler, which the amd64 and riscv outputs are meant for.
+func GnuStringFrag(tm *ir.TempManagement, sb *strings.Builder, frag *ir.StrFrag) string {
+	sb.WriteString(tm.LabelString(frag.Label))
+	sb.WriteString(":\t.asciz\t\"")
+	for i := 0; i < len(frag.Str); i++ {
+		c := frag.Str[i]
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c >= ' ' && c < 127:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(sb, "\\%03o", c)
+		}
+	}
+
+	sb.WriteString("\"\n")
+	return sb.String()
+}
+
+// WordTableFrag writes a table fragment as aligned 32-bit words, for the mips and riscv outputs.
+func WordTableFrag(tm *ir.TempManagement, sb *strings.Builder, frag *ir.TableFrag) string {
+	labels := make([]string, 0, len(frag.Labels))
+	for _, label := range frag.Labels {
+		labels = append(labels, tm.LabelString(label))
+	}
+
+	sb.WriteString("\t.align\t2\n")
+	sb.WriteString(tm.LabelString(frag.Label))
+	sb.WriteString(":\t.word\t" + strings.Join(labels, ", ") + "\n")
+	return sb.String()
+}
+
+// asmRuntimeFuncs lists the labels that the source of an assembly runtime defines: the names before a colon at the
+// start of a line. Neither a comment nor a directive starts with a name followed by a colon.
+func asmRuntimeFuncs(runtime string) map[string]bool {
+	funcs := make(map[string]bool)
+	for _, line := range strings.Split(runtime, "\n") {
+		line = strings.TrimSpace(line)
+		if i := strings.IndexByte(line, ':'); i > 0 && isAsmLabel(line[:i]) {
+			funcs[line[:i]] = true
+		}
+	}
+
+	return funcs
+}
+
+func isAsmLabel(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !syntax.IsAlphaNumeric(s[i]) && !syntax.IsUnderscore(s[i]) && s[i] != '.' && s[i] != '$' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RuntimeFuncs lists the functions that the runtime of the target defines.
+func (a *Arch) RuntimeFuncs() map[string]bool {
+	return a.runtimeFuncs(a.Runtime)
+}
@@ -0,0 +1,449 @@
+// Package compiler compiles Tiger sources for a target through the phases of the other packages. Compile is its entry
+// point, and a Compilation holds all the state of a source, so that several sources can be compiled in one process.
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tiger/backend/cgen"
+	"tiger/backend/wasm"
+	"tiger/ir"
+	"tiger/regalloc"
+	"tiger/semant"
+	"tiger/syntax"
+)
+
+// Options are the settings of a compilation.
+type Options struct {
+	// File is the name of the source, which the positions of the diagnostics refer to. A module is told from a program
+	// by its content, and its name is the base name of the file.
+	File string
+
+	// Arch is the target: mips, riscv, amd64, c or wasm.
+	Arch string
+
+	// Unit compiles a program without the runtime and the modules it imports, to be linked with them afterwards.
+	Unit bool
+
+	// Jobs is the number of functions whose registers are allocated at the same time, one when it is not positive.
+	// Only the register allocation runs in parallel: the functions are canonicalized and their instructions selected
+	// one after the other, since the labels are numbered for the whole program. The output is the same whatever the
+	// number, the functions are written in the order of the fragments.
+	Jobs int
+
+	// Debug emits the debug information of a debugger along with the assembly: the line table, which tells the
+	// statement of the source each instruction is part of, and where the variables of each function are. It is only
+	// emitted for mips, as directives that the simulator reads.
+	Debug bool
+
+	// Dump lists the phases to print, separated by commas, among tokens, ast, ast-json, ir, canon, traces, assem, flow,
+	// igraph and alloc. They are written to DumpOut, or to files in DumpDir when it is set.
+	Dump    string
+	DumpDir string
+	DumpOut io.Writer
+}
+
+// Result is what a compilation produces.
+type Result struct {
+	// Output is the assembly or the C source of the program, or its binary WebAssembly module.
+	Output []byte
+
+	// Frags are the fragments that the source is translated to, before the backend.
+	Frags []ir.Frag
+
+	// Diagnostics are the errors and the warnings of the source, in the order they were found.
+	Diagnostics []*syntax.Diagnostic
+}
+
+// Compilation is the state of the compilation of a source: the symbols of its names, the temps and labels made up for
+// it, the diagnostics reported and where the phases are dumped. Compilations share nothing, so that several of them
+// can run in one process.
+type Compilation struct {
+	opts   Options
+	arch   *Arch
+	strs   *syntax.Strings
+	tm     *ir.TempManagement
+	dumper *ir.Dumper
+	diags  *syntax.Diagnostics
+}
+
+func NewCompilation(opts Options) (*Compilation, error) {
+	arch, err := NewArch(opts.Arch)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Debug && arch.Name != "mips" {
+		return nil, unsupportedDebugErr(arch.Name)
+	}
+
+	out := opts.DumpOut
+	if out == nil {
+		out = os.Stdout
+	}
+
+	dumper, err := ir.NewDumper(opts.Dump, opts.DumpDir, out)
+	if err != nil {
+		return nil, err
+	}
+
+	strs := syntax.NewStrings()
+	return &Compilation{
+		opts:   opts,
+		arch:   arch,
+		strs:   strs,
+		tm:     ir.NewTempManagement(strs),
+		dumper: dumper,
+		diags:  syntax.NewDiagnostics(),
+	}, nil
+}
+
+// Compile compiles the source of a program or of a module for the target of opts. The error is the list of the
+// diagnostics when the source has errors, which are also in the result along with the warnings.
+func Compile(src []byte, opts Options) (Result, error) {
+	c, err := NewCompilation(opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	frags, err := c.Translate(src)
+	if err != nil {
+		return Result{Diagnostics: c.diags.List()}, err
+	}
+
+	out, err := c.Emit(src, frags)
+	return Result{Output: out, Frags: frags, Diagnostics: c.diags.List()}, err
+}
+
+func (c *Compilation) Arch() *Arch {
+	return c.arch
+}
+
+func (c *Compilation) TempManagement() *ir.TempManagement {
+	return c.tm
+}
+
+func (c *Compilation) Dumper() *ir.Dumper {
+	return c.dumper
+}
+
+// Diagnostics are those reported so far.
+func (c *Compilation) Diagnostics() *syntax.Diagnostics {
+	return c.diags
+}
+
+// Translate parses and checks the source and returns its fragments. The fragments of a module are its own, a program
+// also has those of the modules it imports unless it is compiled as a unit. The error is the diagnostics when there is
+// an error among them.
+func (c *Compilation) Translate(src []byte) ([]ir.Frag, error) {
+	file := c.opts.File
+	runtime := c.arch.RuntimeFuncs()
+	source := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	c.dumper.Dump("tokens", source, func(sb *strings.Builder) {
+		syntax.DumpTokens(sb, file, src)
+	})
+
+	translate := semant.NewTranslate(c.tm, c.arch.FrameFactory, c.arch.WordSize, runtime)
+	translate.Debug = c.opts.Debug
+	parser := syntax.NewParser(syntax.NewLexer(file, bufio.NewReader(bytes.NewReader(src))), c.strs)
+	parser.Diags = c.diags
+	if syntax.IsModule(file, src) {
+		name, err := semant.ModuleName(file)
+		if err != nil {
+			return nil, c.fail(err)
+		}
+
+		// the declarations that parse are checked, their errors are reported along with the syntax errors
+		decls, err := parser.ParseModule()
+		if decls == nil {
+			return nil, c.fail(err)
+		}
+
+		c.dumper.Dump("ast", source, func(sb *strings.Builder) {
+			for _, decl := range decls {
+				decl.String(c.strs, sb, 0)
+			}
+		})
+		c.dumper.Dump("ast-json", source, func(sb *strings.Builder) {
+			syntax.WriteJsonModule(sb, c.strs, file, decls)
+		})
+
+		c.tm.Module = name
+		if _, err := semant.NewModules(translate, false).Check(translate, name, file, decls); err != nil {
+			c.diags.Report(err)
+		}
+
+		return c.translated(translate.Frags())
+	}
+
+	// the semantic analysis goes on with the partial AST of a program with syntax errors
+	exp, err := parser.Parse()
+	if exp == nil {
+		return nil, c.fail(err)
+	}
+
+	c.dumper.Dump("ast", source, func(sb *strings.Builder) {
+		exp.String(c.strs, sb, 0)
+	})
+	c.dumper.Dump("ast-json", source, func(sb *strings.Builder) {
+		syntax.WriteJsonProgram(sb, c.strs, exp)
+	})
+
+	semant.NewFindEscape().FindEscape(exp)
+	s := semant.NewSemant(translate, semant.InitBaseVarEnv(c.tm), semant.InitBaseTypeEnv(c.strs))
+	s.Modules = semant.NewModules(translate, !c.opts.Unit)
+	s.File = file
+	s.Diags = c.diags
+	frags, err := s.TransProg(exp)
+	if err != nil && err != error(c.diags) {
+		c.diags.Report(err)
+	}
+
+	return c.translated(frags)
+}
+
+// NewSession starts a REPL session whose inputs are translated for the target, with the functions of its runtime.
+func (c *Compilation) NewSession() (*semant.Session, error) {
+	translate := semant.NewTranslate(c.tm, c.arch.FrameFactory, c.arch.WordSize, c.arch.RuntimeFuncs())
+	return semant.NewSession(semant.NewSemant(translate, semant.InitBaseVarEnv(c.tm), semant.InitBaseTypeEnv(c.strs))), nil
+}
+
+// fail reports err, unless it is the diagnostics themselves, and returns the diagnostics.
+func (c *Compilation) fail(err error) error {
+	if err != nil && err != error(c.diags) {
+		c.diags.Report(err)
+	}
+
+	return c.diags
+}
+
+// translated dumps the IR trees of the functions, before they are canonicalized, unless there are errors.
+func (c *Compilation) translated(frags []ir.Frag) ([]ir.Frag, error) {
+	if c.diags.HasErrors() {
+		return nil, c.diags
+	}
+
+	for _, frag := range frags {
+		if proc, ok := frag.(*ir.ProcFrag); ok {
+			c.dumper.Dump("ir", c.tm.LabelString(proc.Frame.Name()), func(sb *strings.Builder) {
+				proc.Body.PrintStm(sb, c.strs, 0)
+			})
+		}
+	}
+
+	return frags, nil
+}
+
+// Emit turns the fragments of the source into the output of the target. The C output is compiled separately and
+// linked with the runtime, the wasm module imports it from its host, and the assembly starts with the runtime unless
+// it is a unit, which gets the runtime when it is linked.
+func (c *Compilation) Emit(src []byte, frags []ir.Frag) ([]byte, error) {
+	unit := c.opts.Unit || syntax.IsModule(c.opts.File, src)
+	switch c.arch.Name {
+	case "c":
+		return []byte(cgen.Emit(c.tm, c.dumper, frags)), nil
+	case "wasm":
+		if unit {
+			return nil, UnsupportedLinkErr(c.arch.Name)
+		}
+
+		return wasm.Emit(c.tm, frags), nil
+	}
+
+	if unit {
+		return []byte(c.emit(frags)), nil
+	}
+
+	return []byte(c.arch.Runtime + "\n" + c.emit(frags)), nil
+}
+
+func addTab(instrs []ir.Instr) {
+	for _, instr := range instrs {
+		switch v := instr.(type) {
+		case *ir.OperInstr:
+			v.Assem = "\t" + v.Assem
+		case *ir.MoveInstr:
+			v.Assem = "\t" + v.Assem
+		}
+	}
+}
+
+// emitProc writes the functions in the order of procs. Their instructions are selected one after the other, so that
+// the labels are numbered the same way whatever the number of jobs, and their registers are allocated by the jobs. Each
+// function makes up its spill temps with its own fork of the temps and dumps into its own buffer, which are written in
+// the order of procs, so the dumps do not depend on the jobs either.
+func (c *Compilation) emitProc(sb *strings.Builder, procs []*ir.ProcFrag) {
+	selected := make([][]ir.Instr, len(procs))
+	for i, proc := range procs {
+		selected[i] = c.selectInstrs(proc)
+	}
+
+	jobs := c.opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	outs := make([]string, len(procs))
+	tms := make([]*ir.TempManagement, len(procs))
+	dumpers := make([]*ir.Dumper, len(procs))
+	for i := range procs {
+		tms[i], dumpers[i] = c.tm.Fork(), c.dumper.Fork()
+	}
+
+	next := make(chan int)
+	wg := sync.WaitGroup{}
+	for j := 0; j < jobs && j < len(procs); j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				outs[i] = c.allocProc(procs[i], selected[i], tms[i], dumpers[i])
+			}
+		}()
+	}
+
+	for i := range procs {
+		next <- i
+	}
+
+	close(next)
+	wg.Wait()
+	for i, out := range outs {
+		c.dumper.Join(dumpers[i])
+		sb.WriteString(out)
+	}
+}
+
+// EmitProc returns the assembly of a function for a target with registers, like that of an input of a REPL.
+func (c *Compilation) EmitProc(proc *ir.ProcFrag) string {
+	return c.allocProc(proc, c.selectInstrs(proc), c.tm, c.dumper)
+}
+
+// selectInstrs canonicalizes the body of proc and selects its instructions. The heap pointers that are live across
+// calls are moved into the frame first, where the collector finds them.
+func (c *Compilation) selectInstrs(proc *ir.ProcFrag) []ir.Instr {
+	stms := ir.Canonicalize(c.tm, c.dumper, proc)
+	if frame, ok := proc.Frame.(ir.PointerFrame); ok {
+		stms = ir.SpillPointers(c.tm, frame, stms)
+	}
+
+	instrs := make([]ir.Instr, 0)
+	for _, stm := range stms {
+		instrs = append(instrs, proc.Frame.CodeGen(c.tm, stm)...)
+	}
+
+	instrs = proc.Frame.ProcEntryExit2(instrs)
+	c.dumper.Dump("assem", c.tm.LabelString(proc.Frame.Name()), func(sb *strings.Builder) {
+		ir.DumpInstrs(sb, c.tm, instrs)
+	})
+
+	return instrs
+}
+
+// allocProc allocates the registers of the instructions of proc and returns its assembly. The phases of the allocation
+// are printed by dumper.
+func (c *Compilation) allocProc(proc *ir.ProcFrag, instrs []ir.Instr, tm *ir.TempManagement, dumper *ir.Dumper) string {
+	instrs, colored, spilled := regalloc.Alloc(tm, dumper, proc.Frame, instrs)
+	addTab(instrs)
+	prolog, epilog := proc.Frame.ProcEntryExit3()
+	sb := strings.Builder{}
+	if frame, ok := proc.Frame.(ir.DebugFrame); ok && c.opts.Debug && proc.Debug != nil {
+		c.debugDirectives(&sb, frame, proc.Debug, colored, spilled)
+	}
+
+	sb.WriteString(prolog)
+	for _, instr := range instrs {
+		sb.WriteString(ir.FormatAssem(c.tm, instr, func(temp ir.Temp) string {
+			return colored[temp]
+		}) + "\n")
+	}
+
+	sb.WriteString(epilog)
+	return sb.String()
+}
+
+// debugDirectives writes what a debugger is told about the function of frame, before its label:
+//
+//	.file	"prog.tig"
+//	.func	f	f	main	4($fp)	3	5
+//	.var	n	"int"	$s1	3	16	3	40
+//
+// .file names the source of the positions of the function and of the .loc of its statements. .func gives the label,
+// the name and the position of the function, the label of the function it is nested in and where its static link is,
+// - when it has none. Each .var gives the name, the type and the location of a variable, and the positions where its
+// scope starts and ends.
+func (c *Compilation) debugDirectives(sb *strings.Builder, frame ir.DebugFrame, debug *ir.ProcDebug,
+	colored map[ir.Temp]string, spilled map[ir.Temp]ir.FrameAccess) {
+	parent, link := "-", "-"
+	if debug.Parent != nil {
+		parent = c.tm.LabelString(debug.Parent.Name())
+		if loc, ok := frame.Location(frame.Formals()[0], colored, spilled); ok {
+			link = loc
+		}
+	}
+
+	fmt.Fprintf(sb, "\t.file\t%s\n", strconv.Quote(debug.Pos.FileName))
+	fmt.Fprintf(sb, "\t.func\t%s\t%s\t%s\t%s\t%d\t%d\n", c.tm.LabelString(frame.Name()), debug.Name, parent, link,
+		debug.Pos.Line, debug.Pos.Col)
+	for _, v := range debug.Vars {
+		// a variable that no instruction uses has no register
+		loc, ok := frame.Location(v.Access, colored, spilled)
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(sb, "\t.var\t%s\t%s\t%s\t%d\t%d\t%d\t%d\n", v.Name, strconv.Quote(v.Type), loc, v.Pos.Line, v.Pos.Col, v.End.Line,
+			v.End.Col)
+	}
+}
+
+func (c *Compilation) emitString(sb *strings.Builder, strs []*ir.StrFrag) {
+	for _, str := range strs {
+		c.arch.stringFrag(c.tm, sb, str)
+	}
+}
+
+func (c *Compilation) emit(frags []ir.Frag) string {
+	var (
+		procs  []*ir.ProcFrag
+		strs   []*ir.StrFrag
+		tables []*ir.TableFrag
+	)
+
+	for _, frag := range frags {
+		switch f := frag.(type) {
+		case *ir.ProcFrag:
+			procs = append(procs, f)
+		case *ir.TableFrag:
+			tables = append(tables, f)
+		default:
+			strs = append(strs, frag.(*ir.StrFrag))
+		}
+	}
+
+	// a module compiled on its own has no main
+	sb := strings.Builder{}
+	for _, proc := range procs {
+		if c.tm.LabelString(proc.Frame.Name()) == "main" {
+			sb.WriteString("\t.globl main\n")
+		}
+	}
+
+	sb.WriteString("\t.data\n")
+	c.emitString(&sb, strs)
+	for _, table := range tables {
+		c.arch.tableFrag(c.tm, &sb, table)
+	}
+
+	sb.WriteString("\n\t.text\n")
+	c.emitProc(&sb, procs)
+	return sb.String()
+}
@@ -0,0 +1,18 @@
+package compiler
+
+import (
+	"fmt"
+)
+
+// Driver errors
+func unsupportedArchErr(arch string) error {
+	return fmt.Errorf("unsupported target architecture %s", arch)
+}
+
+func UnsupportedLinkErr(arch string) error {
+	return fmt.Errorf("units compiled for %s cannot be linked, compile the program from its source", arch)
+}
+
+func unsupportedDebugErr(arch string) error {
+	return fmt.Errorf("debug information is not emitted for %s, only for mips", arch)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"tiger/sim"
+	"tiger/syntax"
+)
+
+// The debugger of "tiger debug" runs a program compiled for mips with the debug information on the simulator. It
+// stops the program at the breakpoints and steps it by the statements of the source, and reads its variables with the
+// line table and the locations that the compiler emits.
+
+// debugContext is how many lines list shows on each side of the statement where the program is stopped.
+const debugContext = 5
+
+const debugHelp = `break LINE, b LINE   stops at a line of the program, or of a module as FILE:LINE
+delete LINE          removes a breakpoint
+run, r               starts the program again
+continue, c          runs up to a breakpoint
+step, s              runs up to the next line, into the functions that it calls
+next, n              runs up to the next line of the function
+finish               runs until the function returns
+backtrace, bt        shows the functions that the program is in
+print NAME, p NAME   shows the value of a variable
+locals               shows the variables of the function
+list, l              shows the source around the statement
+quit, q              ends the session
+`
+
+// Debugger reads the commands from in and writes what they show to out, along with what the program prints.
+type Debugger struct {
+	in      *bufio.Reader
+	out     *replWriter
+	dbg     *sim.Debugger
+	sources map[string][]string
+}
+
+// NewDebugger loads asm, the output of the source src of file compiled with the debug information. The program reads
+// its input from in too.
+func NewDebugger(file string, src, asm []byte, in io.Reader, out io.Writer, maxSteps int) (*Debugger, error) {
+	prog, err := sim.AssembleMips(string(asm))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Debugger{
+		in:      bufio.NewReader(in),
+		out:     &replWriter{w: out, bol: true},
+		sources: map[string][]string{file: strings.Split(string(src), "\n")},
+	}
+
+	d.dbg, err = sim.NewDebugger(prog, d.in, d.out)
+	if err != nil {
+		return nil, err
+	}
+
+	d.dbg.MaxSteps = maxSteps
+	return d, nil
+}
+
+// Run reads and runs the commands until the end of in or quit.
+func (d *Debugger) Run() error {
+	for {
+		d.out.Write([]byte("(tdb) "))
+		d.out.bol = true
+		line, err := d.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "quit" || fields[0] == "q" {
+			return nil
+		}
+
+		d.command(fields[0], fields[1:])
+	}
+}
+
+// command runs a command with its arguments.
+func (d *Debugger) command(cmd string, args []string) {
+	switch cmd {
+	case "break", "b", "delete":
+		if len(args) != 1 {
+			d.out.line(cmd + " takes a line")
+			return
+		}
+
+		file, line, err := debugLocation(args[0])
+		if err != nil {
+			d.out.line(err.Error())
+			return
+		}
+
+		if cmd == "delete" {
+			if !d.dbg.Delete(file, line) {
+				d.out.line(fmt.Sprintf("no breakpoint at %s", args[0]))
+			}
+
+			return
+		}
+
+		file, err = d.dbg.Break(file, line)
+		if err != nil {
+			d.out.line(err.Error())
+			return
+		}
+
+		d.out.line(fmt.Sprintf("breakpoint at %s:%d", file, line))
+	case "run", "r":
+		if err := d.dbg.Restart(); err != nil {
+			d.out.line(err.Error())
+			return
+		}
+
+		d.stop(d.dbg.Continue())
+	case "continue", "c":
+		d.stop(d.dbg.Continue())
+	case "step", "s":
+		d.stop(d.dbg.Step())
+	case "next", "n":
+		d.stop(d.dbg.Next())
+	case "finish":
+		d.stop(d.dbg.Finish())
+	case "backtrace", "bt":
+		frames, err := d.dbg.Backtrace()
+		if err != nil {
+			d.out.line(err.Error())
+			return
+		}
+
+		for i, frame := range frames {
+			d.out.line(fmt.Sprintf("#%d %s at %s:%d", i, frame.Func, frame.Pos.FileName, frame.Pos.Line))
+		}
+	case "print", "p":
+		if len(args) != 1 {
+			d.out.line(cmd + " takes a variable")
+			return
+		}
+
+		value, err := d.dbg.Print(args[0])
+		if err != nil {
+			d.out.line(err.Error())
+			return
+		}
+
+		d.out.line(args[0] + " = " + value)
+	case "locals":
+		locals, err := d.dbg.Locals()
+		if err != nil {
+			d.out.line(err.Error())
+			return
+		}
+
+		for _, local := range locals {
+			d.out.line(local)
+		}
+	case "list", "l":
+		d.list()
+	case "help":
+		d.out.Write([]byte(debugHelp))
+	default:
+		d.out.line(fmt.Sprintf("unknown command %s, help lists them", cmd))
+	}
+}
+
+// debugLocation reads LINE or FILE:LINE. The file is empty for the first.
+func debugLocation(arg string) (string, int, error) {
+	file := ""
+	if i := strings.LastIndexByte(arg, ':'); i >= 0 {
+		file, arg = arg[:i], arg[i+1:]
+	}
+
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		return "", 0, fmt.Errorf("invalid line %s", arg)
+	}
+
+	return file, line, nil
+}
+
+// stop shows where a command has stopped the program: the statement and its line, or the exit code of the program.
+func (d *Debugger) stop(err error) {
+	if err != nil {
+		d.out.line(err.Error())
+		return
+	}
+
+	if code, ok := d.dbg.Exited(); ok {
+		d.out.line(fmt.Sprintf("program exited with code %d", code))
+		return
+	}
+
+	pos := d.dbg.Pos()
+	d.out.line(fmt.Sprintf("%s:%d\t%s", pos.FileName, pos.Line, strings.TrimSpace(d.source(pos, pos.Line))))
+}
+
+// list shows the lines around the statement where the program is stopped, marking its line.
+func (d *Debugger) list() {
+	if _, ok := d.dbg.Exited(); ok || d.dbg.Pos().Line == 0 {
+		d.out.line("the program is not stopped at a statement")
+		return
+	}
+
+	pos := d.dbg.Pos()
+	for line := pos.Line - debugContext; line <= pos.Line+debugContext; line++ {
+		if line < 1 || line > len(d.lines(pos)) {
+			continue
+		}
+
+		mark := "  "
+		if line == pos.Line {
+			mark = "=>"
+		}
+
+		d.out.line(fmt.Sprintf("%s %4d  %s", mark, line, d.source(pos, line)))
+	}
+}
+
+// source is a line of the file of pos.
+func (d *Debugger) source(pos syntax.Pos, line int) string {
+	lines := d.lines(pos)
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	return lines[line-1]
+}
+
+// lines are the lines of the file of pos, read when a module is first stopped in.
+func (d *Debugger) lines(pos syntax.Pos) []string {
+	lines, ok := d.sources[pos.FileName]
+	if !ok {
+		if b, err := os.ReadFile(pos.FileName); err == nil {
+			lines = strings.Split(string(b), "\n")
+		}
+
+		d.sources[pos.FileName] = lines
+	}
+
+	return lines
+}
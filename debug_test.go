@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+)
+
+const debugSrc = `let
+  var total := 0
+  function add(n: int): int =
+    let var twice := n * 2
+    in total := total + twice;
+       twice
+    end
+  var s := "hi"
+in
+  for i := 1 to 3 do
+    (add(i); ());
+  print(s);
+  printi(total)
+end
+`
+
+// debugRun runs the commands of script on the debugger of src and returns what it writes, without the prompts.
+func debugRun(t *testing.T, src, script string) []string {
+	res := compileTest(t, compiler.Options{File: "test.tig", Arch: "mips", Debug: true}, []byte(src))
+	out := bytes.Buffer{}
+	debugger, err := NewDebugger("test.tig", []byte(src), res.Output, strings.NewReader(script), &out, 1000000)
+	require.NoError(t, err)
+	require.NoError(t, debugger.Run())
+
+	var lines []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		line = strings.ReplaceAll(line, "(tdb) ", "")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+func TestDebugger(t *testing.T) {
+	t.Parallel()
+
+	lines := debugRun(t, debugSrc, `break 5
+run
+backtrace
+print n
+print total
+locals
+continue
+print twice
+finish
+print i
+delete 5
+continue
+`)
+
+	require.Equal(t, []string{
+		"breakpoint at test.tig:5",
+		"test.tig:5\tin total := total + twice;",
+		"#0 add at test.tig:5",
+		"#1 main at test.tig:11",
+		"n = 1",
+		"total = 0",
+		"n: int = 1",
+		"twice: int = 2",
+		"test.tig:5\tin total := total + twice;",
+		"twice = 4",
+		"test.tig:10\tfor i := 1 to 3 do",
+		"i = 2",
+		"hi12",
+		"program exited with code 0",
+	}, lines)
+}
+
+func TestDebugger_Step(t *testing.T) {
+	t.Parallel()
+
+	lines := debugRun(t, debugSrc, `break 11
+run
+step
+next
+next
+print s
+finish
+`)
+
+	require.Equal(t, []string{
+		"breakpoint at test.tig:11",
+		"test.tig:11\t(add(i); ());",
+		"test.tig:4\tlet var twice := n * 2",
+		"test.tig:5\tin total := total + twice;",
+		"test.tig:6\ttwice",
+		"no variable s here",
+		"test.tig:10\tfor i := 1 to 3 do",
+	}, lines)
+}
+
+func TestDebugger_Errors(t *testing.T) {
+	t.Parallel()
+
+	lines := debugRun(t, debugSrc, `break 9
+print total
+run
+finish
+`)
+
+	require.Equal(t, []string{
+		"no statement at test.tig:9",
+		"the program is not stopped at a statement",
+		"hi12",
+		"program exited with code 0",
+		"the program is not running",
+	}, lines)
+}
+
+// TestDebugger_Returns stops right after each return, in the caller, instead of at its next statement.
+func TestDebugger_Returns(t *testing.T) {
+	t.Parallel()
+
+	lines := debugRun(t, `let
+  function fact(n: int): int =
+    if n = 0
+    then 1
+    else n * fact(n - 1)
+  function show(n: int) =
+    (printi(n);
+     print("\n"))
+in
+  show(fact(3));
+  show(fact(2))
+end
+`, `break 4
+run
+finish
+print n
+next
+print n
+finish
+finish
+next
+`)
+
+	require.Equal(t, []string{
+		"breakpoint at test.tig:4",
+		"test.tig:4\tthen 1",
+		"test.tig:5\telse n * fact(n - 1)",
+		"n = 1",
+		"test.tig:5\telse n * fact(n - 1)",
+		"n = 2",
+		"test.tig:5\telse n * fact(n - 1)",
+		"test.tig:10\tshow(fact(3));",
+		"6",
+		"test.tig:11\tshow(fact(2))",
+	}, lines)
+}
+
+func TestDebugger_Arrays(t *testing.T) {
+	t.Parallel()
+
+	src, err := os.ReadFile("./test_files/queens.tig")
+	require.NoError(t, err)
+
+	lines := debugRun(t, string(src), "run\n")
+	require.Equal(t, "program exited with code 0", lines[len(lines)-1])
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/syntax"
+)
+
+func diagnosticCodes(t *testing.T, err error) []string {
+	var diags *syntax.Diagnostics
+	require.True(t, errors.As(err, &diags), "%v", err)
+
+	codes := make([]string, 0, len(diags.List()))
+	for _, diag := range diags.List() {
+		codes = append(codes, diag.Code)
+	}
+
+	return codes
+}
+
+func TestDiagnostic_AllTypeErrors(t *testing.T) {
+	err := checkProgram(t, `
+let
+  var x : int := "s"
+  var y := nil
+  function f(n: int) : string = n + 1
+  function g(n: undefty) : int = n
+in
+  x + 1;
+  f(1);
+  g(2);
+  undefinedvar;
+  if "a" = 1 then 0 else 1
+end`)
+
+	// uses of x, f and g do not repeat the errors of their declarations
+	require.Equal(t, []string{"E0116", "E0113", "E0108", "E0115", "E0104", "E0101"}, diagnosticCodes(t, err))
+
+	// a variable keeps the type it is declared with when its initialization is wrong, so its uses are checked
+	err = checkProgram(t, `let var x: int := "a" var y: undefty := 1 in x + "s"; y + "s" end`)
+	require.Equal(t, []string{"E0116", "E0108", "E0101"}, diagnosticCodes(t, err))
+}
+
+func TestDiagnostic_String(t *testing.T) {
+	err := checkProgram(t, `let function f() : int = "s" in f() end`)
+	require.Equal(t, "test.tig:1:26: error[E0115]: function f returns int, but its body has type string\n"+
+		"\ttest.tig:1:5: note: f is declared here", err.Error())
+}
+
+func TestDiagnostic_Span(t *testing.T) {
+	src := `let var s := "a" in s + 1 end`
+	err := checkProgram(t, src)
+
+	var diags *syntax.Diagnostics
+	require.True(t, errors.As(err, &diags))
+	span := diags.List()[0].Span
+	require.Equal(t, "s", src[span.Start.Offset:span.End.Offset])
+
+	src = `let var x: int := if 1 then "a" else "b" in x end`
+	err = checkProgram(t, src)
+	require.True(t, errors.As(err, &diags))
+	span = diags.List()[0].Span
+	require.Equal(t, `if 1 then "a" else "b"`, src[span.Start.Offset:span.End.Offset])
+}
+
+func TestDiagnostic_Render(t *testing.T) {
+	src := "let\n  function f() : int =\n    \"s\"\nin\n  f()\nend"
+	err := checkProgram(t, src)
+
+	var diags *syntax.Diagnostics
+	require.True(t, errors.As(err, &diags))
+
+	out := bytes.Buffer{}
+	renderer := syntax.NewRenderer(&out, false)
+	renderer.AddSource("test.tig", []byte(src))
+	renderer.Render(diags.List()[0])
+	require.Equal(t, `error[E0115]: function f returns int, but its body has type string
+ --> test.tig:3:5
+  |
+2 |   function f() : int =
+  |   -------- f is declared here
+3 |     "s"
+  |     ^^^
+
+`, out.String())
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+	"tiger/ir"
+	"tiger/syntax"
+)
+
+func TestDump_Phases(t *testing.T) {
+	t.Parallel()
+
+	_, err := ir.NewDumper("ir,bogus", "", nil)
+	require.EqualError(t, err, "unknown phase bogus to dump, expected one of tokens, ast, ast-json, ir, canon, "+
+		"traces, assem, flow, igraph, alloc")
+
+	out := bytes.Buffer{}
+	d, err := ir.NewDumper("tokens, ir", "", &out)
+	require.NoError(t, err)
+	require.True(t, d.On("ir"))
+	require.False(t, d.On("canon"))
+
+	d.Dump("tokens", "test", func(sb *strings.Builder) {
+		syntax.DumpTokens(sb, "test.tig", []byte("a := \"s\" /* c */\n+ 1"))
+	})
+	d.Dump("canon", "main", func(sb *strings.Builder) {
+		sb.WriteString("not printed")
+	})
+
+	require.Equal(t, ";; tokens test\n1:1\tident\ta\n1:3\t:=\n1:6\tstr\ts\n2:1\t+\n2:3\tint\t1\n2:4\teof\n",
+		out.String())
+
+	var nilDumper *ir.Dumper
+	require.False(t, nilDumper.On("ir"))
+}
+
+func TestDump_Dir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	d, err := ir.NewDumper("alloc", dir, nil)
+	require.NoError(t, err)
+
+	// the rounds of the same function go to the same file
+	for _, round := range []string{"spill t1\n", "t2: $a0\n"} {
+		d.Dump("alloc", "f", func(sb *strings.Builder) {
+			sb.WriteString(round)
+		})
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "f.alloc"))
+	require.NoError(t, err)
+	require.Equal(t, "spill t1\nt2: $a0\n", string(b))
+}
+
+// TestDump_AstJson dumps the JSON of the AST, which is the whole output and reads back as the tree that the program
+// parses to.
+func TestDump_AstJson(t *testing.T) {
+	t.Parallel()
+
+	file := "./test_files/hello.tig"
+	src, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	out := bytes.Buffer{}
+	compileTest(t, compiler.Options{File: file, Arch: "c", Dump: "ast-json", DumpOut: &out}, src)
+
+	strs := syntax.NewStrings()
+	exp, err := syntax.NewParser(syntax.NewLexer(file, bufio.NewReader(bytes.NewReader(src))), strs).Parse()
+	require.NoError(t, err)
+	decoded, err := syntax.ReadJsonProgram(&out, strs)
+	require.NoError(t, err)
+	require.Equal(t, exp, decoded)
+}
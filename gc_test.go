@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// gcProgram allocates far more than the initial heap of the runtimes while keeping lists, arrays and strings alive
+// across many collections.
+const gcProgram = `
+let type list = {head: int, tail: list}
+    type lists = array of list
+    type strs = array of string
+    function build(n: int, tail: list): list =
+        if n = 0 then tail else list{head = n, tail = build(n - 1, tail)}
+    function sum(l: list): int =
+        if l = nil then 0 else l.head + sum(l.tail)
+    var keep := build(100, nil)
+    var slots := lists [10] of nil
+    var names := strs [3] of "x"
+    var i := 0
+    var total := 0
+in (
+    while i < 500 do (
+        slots[i - i / 10 * 10] := build(50, keep);
+        names[i - i / 3 * 3] := concat(names[i - i / 3 * 3], chr(ord("a") + i - i / 26 * 26));
+        if size(names[i - i / 3 * 3]) > 40 then names[i - i / 3 * 3] := "y";
+        total := total + sum(slots[i - i / 10 * 10]) - sum(keep);
+        i := i + 1
+    );
+    printi(total);
+    print(" ");
+    printi(sum(keep));
+    print(" ");
+    print(names[0])
+)
+end
+`
+
+func TestGarbageCollector(t *testing.T) {
+	expected := "637500 5050 ympsvybe"
+	require.Equal(t, expected, runC(t, []byte(gcProgram)))
+	require.Equal(t, expected, runMips(t, []byte(gcProgram)))
+	require.Equal(t, expected, runRiscv(t, gcProgram))
+	require.Equal(t, expected, runX86(t, gcProgram))
+	require.Equal(t, expected, runWasm(t, gcProgram))
+}
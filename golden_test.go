@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/backend/wasm"
+	"tiger/compiler"
+	"tiger/sim"
+	"tiger/syntax"
+)
+
+var update = flag.Bool("update", false, "rewrite the expectations in the comments of test_files with what the programs do")
+
+// goldenRe matches the comments of a file of test_files that tell what it is expected to do:
+//
+//	/* stdin:
+//	1 2
+//	*/
+//	/* expect stdout:
+//	3
+//	*/
+//	/* expect error: E0101 line 3 */
+//	/* expect runtime error: wasm trap: call stack exhausted */
+//
+// The text of stdin and stdout starts on the line after the colon and ends right before the end of the comment, so a
+// program whose output has no final newline has the end of the comment on the line of its last output.
+var goldenRe = regexp.MustCompile(`(?s)/\* (stdin|expect stdout|expect error|expect runtime error):(.*?)\*/`)
+
+// golden is what a program does: its output and the runtime error that stopped it for the input, or the first error
+// of its compilation.
+type golden struct {
+	Stdin        string
+	Stdout       string
+	Error        string
+	RuntimeError string
+}
+
+// parseGolden reads the expectations of src, ok tells whether there is any besides stdin.
+func parseGolden(src []byte) (g golden, ok bool) {
+	for _, m := range goldenRe.FindAllStringSubmatch(string(src), -1) {
+		switch m[1] {
+		case "stdin":
+			g.Stdin = strings.TrimPrefix(m[2], "\n")
+			continue
+		case "expect stdout":
+			g.Stdout = strings.TrimPrefix(m[2], "\n")
+		case "expect error":
+			g.Error = strings.TrimSpace(m[2])
+		case "expect runtime error":
+			g.RuntimeError = strings.TrimSpace(m[2])
+		}
+
+		ok = true
+	}
+
+	return g, ok
+}
+
+// writeGolden replaces the expectations of src with those of g, at the end of the file. stdin is left where it is.
+func writeGolden(src []byte, g golden) []byte {
+	s := goldenRe.ReplaceAllStringFunc(string(src), func(c string) string {
+		if strings.HasPrefix(c, "/* stdin:") {
+			return c
+		}
+
+		return ""
+	})
+
+	sb := strings.Builder{}
+	sb.WriteString(strings.TrimRight(s, "\n") + "\n")
+	if g.Error != "" {
+		sb.WriteString("\n/* expect error: " + g.Error + " */\n")
+		return []byte(sb.String())
+	}
+
+	sb.WriteString("\n/* expect stdout:\n" + g.Stdout + "*/\n")
+	if g.RuntimeError != "" {
+		sb.WriteString("/* expect runtime error: " + g.RuntimeError + " */\n")
+	}
+
+	return []byte(sb.String())
+}
+
+// runGolden compiles the program of file for arch, wasm or mips, and runs it on their interpreter with stdin. The
+// panics of the compiler are errors of the file, so that the others are still run.
+func runGolden(file string, src []byte, stdin string, arch string) (g golden, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("the compiler panics: %v", r)
+		}
+	}()
+
+	g.Stdin = stdin
+	res, err := compiler.Compile(src, compiler.Options{File: file, Arch: arch})
+	if err != nil {
+		for _, diag := range res.Diagnostics {
+			if diag.Severity == syntax.SeverityError && diag.Span != nil {
+				g.Error = fmt.Sprintf("%s line %d", diag.Code, diag.Span.Start.Line)
+				return g, nil
+			}
+		}
+
+		return g, err
+	}
+
+	out := bytes.Buffer{}
+	var run func() (int, error)
+	switch arch {
+	case "wasm":
+		mod, err := wasm.Decode(res.Output)
+		if err != nil {
+			return g, err
+		}
+
+		vm, err := wasm.NewVM(mod, strings.NewReader(stdin), &out)
+		if err != nil {
+			return g, err
+		}
+
+		vm.MaxSteps = 10000000
+		run = vm.Run
+	case "mips":
+		prog, err := sim.AssembleMips(string(res.Output))
+		if err != nil {
+			return g, err
+		}
+
+		sim := sim.NewMips(prog, strings.NewReader(stdin), &out)
+		sim.MaxSteps = 10000000
+		run = sim.Run
+	}
+
+	if _, err := run(); err != nil {
+		g.RuntimeError = err.Error()
+	}
+
+	g.Stdout = out.String()
+	return g, nil
+}
+
+// TestGolden runs the programs of test_files on wasm and on mips and compares what they do with the expectations in
+// their comments. With -update the expectations are rewritten from wasm instead: go test -run TestGolden -update.
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob("./test_files/*.tig")
+	require.NoError(t, err)
+
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			t.Parallel()
+
+			src, err := os.ReadFile(file)
+			require.NoError(t, err)
+			if syntax.IsModule(file, src) {
+				t.Skip("modules are run by the programs that import them")
+			}
+
+			expected, ok := parseGolden(src)
+			actual, err := runGolden(file, src, expected.Stdin, "wasm")
+			require.NoError(t, err)
+			if *update {
+				require.False(t, strings.Contains(actual.Stdout, "/*") || strings.Contains(actual.Stdout, "*/"),
+					"the output cannot be written in a comment")
+				require.NoError(t, os.WriteFile(file, writeGolden(src, actual), 0644))
+				return
+			}
+
+			require.True(t, ok, "no expectations, run go test -run TestGolden -update")
+			require.Equal(t, expected, actual)
+
+			// mips, the default target, does the same but for the messages of the runtime errors, which are those
+			// of its simulator
+			mips, err := runGolden(file, src, expected.Stdin, "mips")
+			require.NoError(t, err)
+			require.Equal(t, expected.Error, mips.Error, "mips")
+			require.Equal(t, expected.Stdout, mips.Stdout, "mips")
+			require.Equal(t, expected.RuntimeError != "", mips.RuntimeError != "", "mips: %s", mips.RuntimeError)
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package ir
 
 import (
 	"strings"
@@ -7,83 +7,83 @@ import (
 type reg = string
 
 type Instr interface {
-	assemStr() string
-	srcRegs() []Temp
-	dstRegs() []Temp
-	jumpLabels() []Label
+	AssemStr() string
+	SrcRegs() []Temp
+	DstRegs() []Temp
+	JumpLabels() []Label
 }
 
 type OperInstr struct {
-	assem string
-	dst   []Temp
-	src   []Temp
-	jumps []Label
+	Assem string
+	Dst   []Temp
+	Src   []Temp
+	Jumps []Label
 }
 
-func (o *OperInstr) assemStr() string {
-	return o.assem
+func (o *OperInstr) AssemStr() string {
+	return o.Assem
 }
 
-func (o *OperInstr) srcRegs() []Temp {
-	return o.src
+func (o *OperInstr) SrcRegs() []Temp {
+	return o.Src
 }
 
-func (o *OperInstr) dstRegs() []Temp {
-	return o.dst
+func (o *OperInstr) DstRegs() []Temp {
+	return o.Dst
 }
 
-func (o *OperInstr) jumpLabels() []Label {
-	return o.jumps
+func (o *OperInstr) JumpLabels() []Label {
+	return o.Jumps
 }
 
 type LabelInstr struct {
-	assem string
-	lab   Label
+	Assem string
+	Lab   Label
 }
 
-func (l *LabelInstr) assemStr() string {
-	return l.assem
+func (l *LabelInstr) AssemStr() string {
+	return l.Assem
 }
 
-func (l *LabelInstr) srcRegs() []Temp {
+func (l *LabelInstr) SrcRegs() []Temp {
 	return nil
 }
 
-func (l *LabelInstr) dstRegs() []Temp {
+func (l *LabelInstr) DstRegs() []Temp {
 	return nil
 }
 
-func (l *LabelInstr) jumpLabels() []Label {
+func (l *LabelInstr) JumpLabels() []Label {
 	return nil
 }
 
 type MoveInstr struct {
-	assem string
-	dst   Temp
-	src   Temp
+	Assem string
+	Dst   Temp
+	Src   Temp
 }
 
-func (m *MoveInstr) assemStr() string {
-	return m.assem
+func (m *MoveInstr) AssemStr() string {
+	return m.Assem
 }
 
-func (m *MoveInstr) srcRegs() []Temp {
-	return []Temp{m.src}
+func (m *MoveInstr) SrcRegs() []Temp {
+	return []Temp{m.Src}
 }
 
-func (m *MoveInstr) dstRegs() []Temp {
-	return []Temp{m.dst}
+func (m *MoveInstr) DstRegs() []Temp {
+	return []Temp{m.Dst}
 }
 
-func (m *MoveInstr) jumpLabels() []Label {
+func (m *MoveInstr) JumpLabels() []Label {
 	return nil
 }
 
 // in assem, we may have something like "addi `d0, `s0, 3".
 // This function replaces `d0, `s0 with actual registers.
-func formatAssem(i Instr, tempMap func(Temp) string) string {
-	sources, dests, jumpLabels := i.srcRegs(), i.dstRegs(), i.jumpLabels()
-	assem := i.assemStr()
+func FormatAssem(tm *TempManagement, i Instr, tempMap func(Temp) string) string {
+	sources, dests, jumpLabels := i.SrcRegs(), i.DstRegs(), i.JumpLabels()
+	assem := i.AssemStr()
 	sb := strings.Builder{}
 	for i := 0; i < len(assem); i++ {
 		if assem[i] == '`' {
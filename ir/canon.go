@@ -1,6 +1,32 @@
-package main
+package ir
 
-type Canon struct{}
+import "strings"
+
+type Canon struct {
+	tm *TempManagement
+}
+
+func NewCanon(tm *TempManagement) *Canon {
+	return &Canon{tm: tm}
+}
+
+// Canonicalize turns the body of a procedure into the list of statements of its trace schedule.
+func Canonicalize(tm *TempManagement, dumper *Dumper, proc *ProcFrag) []StmIr {
+	canon := NewCanon(tm)
+	name := tm.LabelString(proc.Frame.Name())
+	stms, _ := canon.Linearize(proc.Body)
+	dumper.Dump("canon", name, func(sb *strings.Builder) {
+		dumpStms(sb, tm.Strings(), stms)
+	})
+
+	blocks, doneLabel := canon.BasicBlocks(stms)
+	traces := canon.TraceSchedule(blocks, doneLabel)
+	dumper.Dump("traces", name, func(sb *strings.Builder) {
+		dumpStms(sb, tm.Strings(), traces)
+	})
+
+	return traces
+}
 
 type blockTable = map[Label][]StmIr
 
@@ -11,7 +37,7 @@ func (c *Canon) TraceSchedule(blocks [][]StmIr, done Label) []StmIr {
 	for _, block := range blocks {
 		if len(block) > 0 {
 			if v, ok := block[0].(*LabelStmIr); ok {
-				table[v.label] = block
+				table[v.Label] = block
 			}
 		}
 	}
@@ -26,7 +52,7 @@ func (c *Canon) getNextTrace(table blockTable, blocks [][]StmIr) []StmIr {
 
 	if len(blocks[0]) > 0 {
 		if v, ok := blocks[0][0].(*LabelStmIr); ok {
-			if _, ok := table[v.label]; ok {
+			if _, ok := table[v.Label]; ok {
 				return c.trace(table, blocks[0], blocks[1:])
 			}
 		}
@@ -44,11 +70,11 @@ func (c *Canon) trace(table blockTable, block []StmIr, rest [][]StmIr) []StmIr {
 	if v, ok := block[0].(*LabelStmIr); !ok {
 		panic("head of the block must be a label")
 	} else {
-		delete(table, v.label)
+		delete(table, v.Label)
 		switch v1 := block[len(block)-1].(type) {
 		case *JumpStmIr:
-			if _, ok := v1.exp.(*NameExpIr); ok {
-				if v, ok := table[v1.labels[0]]; ok {
+			if _, ok := v1.Exp.(*NameExpIr); ok {
+				if v, ok := table[v1.Labels[0]]; ok {
 					return append(block[:len(block)-1], c.trace(table, v, rest)...)
 				}
 
@@ -58,33 +84,33 @@ func (c *Canon) trace(table blockTable, block []StmIr, rest [][]StmIr) []StmIr {
 			return append(block, c.getNextTrace(table, rest)...)
 
 		case *CJumpStmIr:
-			if v, ok := table[v1.falseLabel]; ok {
+			if v, ok := table[v1.FalseLabel]; ok {
 				return append(block, c.trace(table, v, rest)...)
 			}
 
-			if v, ok := table[v1.trueLabel]; ok {
+			if v, ok := table[v1.TrueLabel]; ok {
 				return append(block[:len(block)-1], append([]StmIr{&CJumpStmIr{
-					relop:      v1.relop.not(),
-					left:       v1.left,
-					right:      v1.right,
-					trueLabel:  v1.falseLabel,
-					falseLabel: v1.trueLabel,
+					Relop:      v1.Relop.not(),
+					Left:       v1.Left,
+					Right:      v1.Right,
+					TrueLabel:  v1.FalseLabel,
+					FalseLabel: v1.TrueLabel,
 				}}, c.trace(table, v, rest)...)...)
 			}
 
-			f := tm.NewLabel()
+			f := c.tm.NewLabel()
 			return append(block[:len(block)-1], append([]StmIr{
 				&CJumpStmIr{
-					relop:      v1.relop,
-					left:       v1.left,
-					right:      v1.right,
-					trueLabel:  v1.trueLabel,
-					falseLabel: f,
+					Relop:      v1.Relop,
+					Left:       v1.Left,
+					Right:      v1.Right,
+					TrueLabel:  v1.TrueLabel,
+					FalseLabel: f,
 				},
 				&LabelStmIr{f},
 				&JumpStmIr{
-					exp:    &NameExpIr{v1.falseLabel},
-					labels: []Label{v1.falseLabel},
+					Exp:    &NameExpIr{v1.FalseLabel},
+					Labels: []Label{v1.FalseLabel},
 				},
 			}, c.getNextTrace(table, rest)...)...)
 		}
@@ -95,7 +121,7 @@ func (c *Canon) trace(table blockTable, block []StmIr, rest [][]StmIr) []StmIr {
 
 func (c *Canon) BasicBlocks(stms []StmIr) ([][]StmIr, Label) {
 	var blocks [][]StmIr
-	done := tm.NewLabel()
+	done := c.tm.NewLabel()
 	c.blocks(stms, &blocks, done)
 	return blocks, done
 }
@@ -110,15 +136,15 @@ func (c *Canon) blocks(stms []StmIr, blocks *[][]StmIr, done Label) {
 		return
 	}
 
-	c.blocks(append([]StmIr{&LabelStmIr{tm.NewLabel()}}, stms...), blocks, done)
+	c.blocks(append([]StmIr{&LabelStmIr{c.tm.NewLabel()}}, stms...), blocks, done)
 }
 
 func (c *Canon) nextBlock(stms []StmIr, thisBlock []StmIr, blocks *[][]StmIr, done Label) {
 	if len(stms) == 0 {
 		c.nextBlock([]StmIr{
 			&JumpStmIr{
-				exp:    &NameExpIr{done},
-				labels: []Label{done},
+				Exp:    &NameExpIr{done},
+				Labels: []Label{done},
 			},
 		}, thisBlock, blocks, done)
 		return
@@ -131,8 +157,8 @@ func (c *Canon) nextBlock(stms []StmIr, thisBlock []StmIr, blocks *[][]StmIr, do
 	case *LabelStmIr:
 		c.nextBlock(append([]StmIr{
 			&JumpStmIr{
-				exp:    &NameExpIr{v.label},
-				labels: []Label{v.label},
+				Exp:    &NameExpIr{v.Label},
+				Labels: []Label{v.Label},
 			},
 		}, stms...), thisBlock, blocks, done)
 
@@ -154,7 +180,7 @@ func (c *Canon) Linearize(stm StmIr) ([]StmIr, StmIr) {
 func (c *Canon) linear(s1 StmIr, s2 []StmIr) []StmIr {
 	switch v := s1.(type) {
 	case *SeqStmIr:
-		return c.linear(v.first, c.linear(v.second, s2))
+		return c.linear(v.First, c.linear(v.Second, s2))
 
 	default:
 		return append([]StmIr{s1}, s2...)
@@ -163,17 +189,17 @@ func (c *Canon) linear(s1 StmIr, s2 []StmIr) []StmIr {
 
 func (c *Canon) reorder(exps []ExpIr) (StmIr, []ExpIr) {
 	if len(exps) == 0 {
-		return &ExpStmIr{exp: &ConstExpIr{0}}, nil
+		return &ExpStmIr{Exp: &ConstExpIr{0}}, nil
 	}
 
 	if v, ok := exps[0].(*CallExpIr); ok {
-		t := tm.NewTemp()
+		t := c.tm.NewTemp()
 		return c.reorder(append([]ExpIr{&EsEqExpIr{
-			stm: &MoveStmIr{
-				dst: &TempExpIr{t},
-				src: v,
+			Stm: &MoveStmIr{
+				Dst: &TempExpIr{t},
+				Src: v,
 			},
-			exp: &TempExpIr{t},
+			Exp: &TempExpIr{t},
 		}}, exps[1:]...))
 	}
 
@@ -187,11 +213,33 @@ func (c *Canon) reorder(exps []ExpIr) (StmIr, []ExpIr) {
 		return c.concat(s1, s2), append([]ExpIr{e1}, e2...)
 	}
 
-	t := tm.NewTemp()
-	return c.concat(c.concat(s1, &MoveStmIr{
-		dst: &TempExpIr{t},
-		src: e1,
-	}), s2), append([]ExpIr{&TempExpIr{t}}, e2...)
+	s3, e3 := c.hold(e1)
+	return c.concat(c.concat(s1, s3), s2), append([]ExpIr{e3}, e2...)
+}
+
+// hold saves e in temps so that it can be evaluated after statements that do not commute with it. The operands of a
+// binary operator are saved rather than its value: an address like base+offset kept across a call would still point
+// into an object that a copying collector moved during the call, while the base is a pointer the collector updates.
+func (c *Canon) hold(e ExpIr) (StmIr, ExpIr) {
+	switch v := e.(type) {
+	case *ConstExpIr, *NameExpIr:
+		return &ExpStmIr{Exp: &ConstExpIr{0}}, e
+
+	case *BinOpExpIr:
+		s1, left := c.hold(v.Left)
+		s2, right := c.hold(v.Right)
+		return c.concat(s1, s2), &BinOpExpIr{
+			Binop: v.Binop,
+			Left:  left,
+			Right: right,
+		}
+	}
+
+	t := c.tm.NewTemp()
+	return &MoveStmIr{
+		Dst: &TempExpIr{t},
+		Src: e,
+	}, &TempExpIr{t}
 }
 
 func (c *Canon) reorderExp(exps []ExpIr, fn func(e1 []ExpIr) ExpIr) (StmIr, ExpIr) {
@@ -207,29 +255,30 @@ func (c *Canon) reorderStm(exps []ExpIr, fn func(e1 []ExpIr) StmIr) StmIr {
 func (c *Canon) doExp(e ExpIr) (StmIr, ExpIr) {
 	switch v := e.(type) {
 	case *BinOpExpIr:
-		return c.reorderExp([]ExpIr{v.left, v.right}, func(e1 []ExpIr) ExpIr {
+		return c.reorderExp([]ExpIr{v.Left, v.Right}, func(e1 []ExpIr) ExpIr {
 			return &BinOpExpIr{
-				binop: v.binop,
-				left:  e1[0],
-				right: e1[1],
+				Binop: v.Binop,
+				Left:  e1[0],
+				Right: e1[1],
 			}
 		})
 
 	case *MemExpIr:
-		return c.reorderExp([]ExpIr{v.mem}, func(e1 []ExpIr) ExpIr {
-			return &MemExpIr{e1[0]}
+		return c.reorderExp([]ExpIr{v.Mem}, func(e1 []ExpIr) ExpIr {
+			return &MemExpIr{Mem: e1[0], Ptr: v.Ptr}
 		})
 
 	case *EsEqExpIr:
-		stm := c.doStm(v.stm)
-		stm1, e := c.doExp(v.exp)
+		stm := c.doStm(v.Stm)
+		stm1, e := c.doExp(v.Exp)
 		return c.concat(stm, stm1), e
 
 	case *CallExpIr:
-		return c.reorderExp(v.args, func(e1 []ExpIr) ExpIr {
+		return c.reorderExp(v.Args, func(e1 []ExpIr) ExpIr {
 			return &CallExpIr{
-				exp:  v.exp,
-				args: e1,
+				Exp:  v.Exp,
+				Args: e1,
+				Ptr:  v.Ptr,
 			}
 		})
 
@@ -243,82 +292,84 @@ func (c *Canon) doExp(e ExpIr) (StmIr, ExpIr) {
 func (c *Canon) doStm(stm StmIr) StmIr {
 	switch v := stm.(type) {
 	case *SeqStmIr:
-		s1, s2 := c.doStm(v.first), c.doStm(v.second)
+		s1, s2 := c.doStm(v.First), c.doStm(v.Second)
 		return c.concat(s1, s2)
 
 	case *JumpStmIr:
-		return c.reorderStm([]ExpIr{v.exp}, func(e1 []ExpIr) StmIr {
+		return c.reorderStm([]ExpIr{v.Exp}, func(e1 []ExpIr) StmIr {
 			return &JumpStmIr{
-				exp:    e1[0],
-				labels: v.labels,
+				Exp:    e1[0],
+				Labels: v.Labels,
 			}
 		})
 
 	case *CJumpStmIr:
-		return c.reorderStm([]ExpIr{v.left, v.right}, func(e1 []ExpIr) StmIr {
+		return c.reorderStm([]ExpIr{v.Left, v.Right}, func(e1 []ExpIr) StmIr {
 			return &CJumpStmIr{
-				relop:      v.relop,
-				left:       e1[0],
-				right:      e1[1],
-				trueLabel:  v.trueLabel,
-				falseLabel: v.falseLabel,
+				Relop:      v.Relop,
+				Left:       e1[0],
+				Right:      e1[1],
+				TrueLabel:  v.TrueLabel,
+				FalseLabel: v.FalseLabel,
 			}
 		})
 
 	case *MoveStmIr:
-		switch v1 := v.dst.(type) {
+		switch v1 := v.Dst.(type) {
 		case *TempExpIr:
-			if v2, ok := v.src.(*CallExpIr); ok {
-				return c.reorderStm(append([]ExpIr{v2.exp}, v2.args...), func(e1 []ExpIr) StmIr {
+			if v2, ok := v.Src.(*CallExpIr); ok {
+				return c.reorderStm(append([]ExpIr{v2.Exp}, v2.Args...), func(e1 []ExpIr) StmIr {
 					return &MoveStmIr{
-						dst: v1,
-						src: &CallExpIr{
-							exp:  e1[0],
-							args: e1[1:],
+						Dst: v1,
+						Src: &CallExpIr{
+							Exp:  e1[0],
+							Args: e1[1:],
+							Ptr:  v2.Ptr,
 						},
 					}
 				})
 			}
 
-			return c.reorderStm([]ExpIr{v.src}, func(e1 []ExpIr) StmIr {
+			return c.reorderStm([]ExpIr{v.Src}, func(e1 []ExpIr) StmIr {
 				return &MoveStmIr{
-					dst: v.dst,
-					src: e1[0],
+					Dst: v.Dst,
+					Src: e1[0],
 				}
 			})
 
 		case *MemExpIr:
-			return c.reorderStm([]ExpIr{v1.mem, v.src}, func(e1 []ExpIr) StmIr {
+			return c.reorderStm([]ExpIr{v1.Mem, v.Src}, func(e1 []ExpIr) StmIr {
 				return &MoveStmIr{
-					dst: &MemExpIr{e1[0]},
-					src: e1[1],
+					Dst: &MemExpIr{Mem: e1[0], Ptr: v1.Ptr},
+					Src: e1[1],
 				}
 			})
 
 		case *EsEqExpIr:
 			return c.doStm(&SeqStmIr{
-				first: v1.stm,
-				second: &MoveStmIr{
-					dst: v1.exp,
-					src: v.src,
+				First: v1.Stm,
+				Second: &MoveStmIr{
+					Dst: v1.Exp,
+					Src: v.Src,
 				},
 			})
 		}
 
 	case *ExpStmIr:
-		switch v1 := v.exp.(type) {
+		switch v1 := v.Exp.(type) {
 		case *CallExpIr:
-			return c.reorderStm(v1.args, func(e1 []ExpIr) StmIr {
+			return c.reorderStm(v1.Args, func(e1 []ExpIr) StmIr {
 				return &ExpStmIr{
 					&CallExpIr{
-						exp:  v1.exp,
-						args: e1,
+						Exp:  v1.Exp,
+						Args: e1,
+						Ptr:  v1.Ptr,
 					},
 				}
 			})
 
 		default:
-			return c.reorderStm([]ExpIr{v.exp}, func(e1 []ExpIr) StmIr {
+			return c.reorderStm([]ExpIr{v.Exp}, func(e1 []ExpIr) StmIr {
 				return &ExpStmIr{e1[0]}
 			})
 		}
@@ -334,7 +385,7 @@ func (c *Canon) doStm(stm StmIr) StmIr {
 
 func (c *Canon) commute(s StmIr, e ExpIr) bool {
 	if v, ok := s.(*ExpStmIr); ok {
-		if _, ok := v.exp.(*ConstExpIr); ok {
+		if _, ok := v.Exp.(*ConstExpIr); ok {
 			return true
 		}
 	}
@@ -352,19 +403,19 @@ func (c *Canon) commute(s StmIr, e ExpIr) bool {
 
 func (c *Canon) concat(s1, s2 StmIr) StmIr {
 	if v, ok := s1.(*ExpStmIr); ok {
-		if _, ok := v.exp.(*ConstExpIr); ok {
+		if _, ok := v.Exp.(*ConstExpIr); ok {
 			return s2
 		}
 	}
 
 	if v, ok := s2.(*ExpStmIr); ok {
-		if _, ok := v.exp.(*ConstExpIr); ok {
+		if _, ok := v.Exp.(*ConstExpIr); ok {
 			return s1
 		}
 	}
 
 	return &SeqStmIr{
-		first:  s1,
-		second: s2,
+		First:  s1,
+		Second: s2,
 	}
 }
@@ -0,0 +1,140 @@
+package ir
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"tiger/syntax"
+)
+
+// dumpPhases are the phases that -dump prints, in the order of the compiler. The tokens and the AST, as text or as
+// the JSON of syntax/ast_json.go, are those of the source file, the other phases are printed for each function. assem,
+// flow, igraph and alloc are the phases of the targets with registers, flow and igraph are printed at each round of
+// the register allocation.
+var dumpPhases = []string{"tokens", "ast", "ast-json", "ir", "canon", "traces", "assem", "flow", "igraph", "alloc"}
+
+// Dumper prints the intermediate representations of the phases given to -dump, to out under a header, or to files
+// named after the function and the phase in dir. A nil Dumper prints nothing. The functions allocated in parallel
+// dump to forks of the Dumper, which are joined in the order of the program, see Fork.
+type Dumper struct {
+	mu      sync.Mutex
+	phases  map[string]bool
+	dir     string
+	out     io.Writer
+	written map[string]bool
+
+	// buf keeps what a fork prints until it is joined
+	buf *bytes.Buffer
+}
+
+func NewDumper(phases string, dir string, out io.Writer) (*Dumper, error) {
+	d := &Dumper{phases: make(map[string]bool), dir: dir, out: out, written: make(map[string]bool)}
+	for _, phase := range strings.Split(phases, ",") {
+		phase = strings.TrimSpace(phase)
+		if phase == "" {
+			continue
+		}
+
+		known := false
+		for _, p := range dumpPhases {
+			known = known || p == phase
+		}
+
+		if !known {
+			return nil, unknownDumpPhaseErr(phase)
+		}
+
+		d.phases[phase] = true
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// Fork returns a Dumper of the same phases that keeps what it prints until Join writes it out, so that the dumps of
+// the functions allocated in parallel come in the same order whatever the number of jobs. The dumps to files are
+// written as they come, each function has files of its own.
+func (d *Dumper) Fork() *Dumper {
+	if d == nil || d.dir != "" {
+		return d
+	}
+
+	buf := &bytes.Buffer{}
+	return &Dumper{phases: d.phases, out: buf, buf: buf}
+}
+
+// Join writes what fork printed.
+func (d *Dumper) Join(fork *Dumper) {
+	if fork == d {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fork.buf.WriteTo(d.out)
+}
+
+func (d *Dumper) On(phase string) bool {
+	return d != nil && d.phases[phase]
+}
+
+// Dump prints what print writes for the phase of a function. The dumps of the same file are appended to each other,
+// like those of the rounds of the register allocation. The JSON of the AST is printed without a header, so that it
+// can be piped into a JSON parser when it is the only phase dumped.
+func (d *Dumper) Dump(phase, name string, print func(sb *strings.Builder)) {
+	if !d.On(phase) {
+		return
+	}
+
+	sb := strings.Builder{}
+	print(&sb)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dir == "" && phase == "ast-json" {
+		fmt.Fprintf(d.out, "%s\n", strings.TrimRight(sb.String(), "\n"))
+		return
+	}
+
+	if d.dir == "" {
+		fmt.Fprintf(d.out, ";; %s %s\n%s\n", phase, name, strings.TrimRight(sb.String(), "\n"))
+		return
+	}
+
+	file := filepath.Join(d.dir, name+"."+phase)
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !d.written[file] {
+		flags |= os.O_TRUNC
+		d.written[file] = true
+	}
+
+	f, err := os.OpenFile(file, flags, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot write the dump %v\n", err)
+		return
+	}
+
+	defer f.Close()
+	f.WriteString(sb.String())
+}
+
+func dumpStms(sb *strings.Builder, strs *syntax.Strings, stms []StmIr) {
+	for _, stm := range stms {
+		stm.PrintStm(sb, strs, 0)
+	}
+}
+
+func DumpInstrs(sb *strings.Builder, tm *TempManagement, instrs []Instr) {
+	for _, instr := range instrs {
+		sb.WriteString(FormatAssem(tm, instr, Temp.String) + "\n")
+	}
+}
@@ -0,0 +1,48 @@
+package ir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Interpreter errors
+func unknownIrModeErr(mode string) error {
+	return fmt.Errorf("unknown interpreter mode %s, expected tree, linear or canon", mode)
+}
+
+func unsupportedIrFrameErr() error {
+	return fmt.Errorf("the interpreter needs frames that pass arguments in temps, use -arch=c or -arch=wasm")
+}
+
+func undefinedIrFuncErr(name string, nargs int) error {
+	return fmt.Errorf("undefined function %s with %d arguments", name, nargs)
+}
+
+func undefinedIrLabelErr(label string) error {
+	return fmt.Errorf("undefined label %s", label)
+}
+
+func nilDereferenceErr(addr int64) error {
+	return fmt.Errorf("nil dereference at address %d", addr)
+}
+
+func invalidMemoryAccessErr(addr int64) error {
+	return fmt.Errorf("invalid memory access at address %d", addr)
+}
+
+func divisionByZeroErr() error {
+	return fmt.Errorf("division by zero")
+}
+
+func irExitErr(code int) error {
+	return fmt.Errorf("exit with code %d", code)
+}
+
+func stepLimitErr(steps int) error {
+	return fmt.Errorf("program did not terminate after %d instructions", steps)
+}
+
+// Dump errors
+func unknownDumpPhaseErr(phase string) error {
+	return fmt.Errorf("unknown phase %s to dump, expected one of %s", phase, strings.Join(dumpPhases, ", "))
+}
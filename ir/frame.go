@@ -0,0 +1,108 @@
+package ir
+
+import (
+	"tiger/syntax"
+)
+
+type FrameAccess interface {
+	Exp(exp ExpIr) ExpIr
+}
+
+type Frame interface {
+	Name() Label
+	Formals() []FrameAccess
+	AllocLocal(escape bool) FrameAccess
+	TempName(t Temp) string
+	TempMap() map[Temp]string
+	ProcEntryExit1(body StmIr) StmIr
+	ProcEntryExit2(body []Instr) []Instr
+	ProcEntryExit3() (string, string)
+	CodeGen(tm *TempManagement, stm StmIr) []Instr
+	FP() Temp
+	RV() Temp
+}
+
+// GCFrame is a frame that can tell the collector which of its words hold heap pointers.
+type GCFrame interface {
+	Frame
+
+	// Pointer records that the variable at acc holds a heap pointer
+	Pointer(acc FrameAccess)
+
+	// PointerResult records that the function returns a heap pointer
+	PointerResult()
+}
+
+// PointerFrame is a GCFrame of a target with registers, the collector of which finds the heap pointers of a frame
+// through one map for the whole function: the words of the frame that Pointer was told about.
+type PointerFrame interface {
+	GCFrame
+
+	// PointerTemps are the variables kept in temps that hold heap pointers
+	PointerTemps() TempSet
+}
+
+// DebugFrame is a frame that can tell a debugger where its variables are once the registers are allocated.
+type DebugFrame interface {
+	Frame
+
+	// Location is where the variable at acc lives, written as an operand of the assembly: the word of the frame it is
+	// in, or the register in colored or the word of the frame in spilled of its temp. It is false when the temp is
+	// used by no instruction.
+	Location(acc FrameAccess, colored map[Temp]string, spilled map[Temp]FrameAccess) (string, bool)
+}
+
+type FrameFactoryFunc func(tm *TempManagement, name Label, formals []bool) Frame
+
+type Frag interface {
+	IsFragment()
+}
+
+type ProcFrag struct {
+	Body  StmIr
+	Frame Frame
+
+	// Debug tells a debugger about the function, it is nil unless the debug information is emitted
+	Debug *ProcDebug
+}
+
+// ProcDebug is what a debugger knows of a function of the source besides its line table.
+type ProcDebug struct {
+	// Name is the name of the function in the source, Pos where it is declared
+	Name string
+	Pos  syntax.Pos
+
+	// Parent is the frame of the function that the function is nested in, the one its static link points to. It is
+	// nil for the functions at the top.
+	Parent Frame
+
+	// Vars are the parameters and the local variables of the function, in the order of their declarations
+	Vars []*DebugVar
+}
+
+// DebugVar is a variable of a function of the source. It can be seen from its declaration at Pos to the end of its
+// scope at End.
+type DebugVar struct {
+	Name   string
+	Type   string
+	Access FrameAccess
+	Pos    syntax.Pos
+	End    syntax.Pos
+}
+
+func (frag *ProcFrag) IsFragment() {}
+
+type StrFrag struct {
+	Label Label
+	Str   string
+}
+
+func (frag *StrFrag) IsFragment() {}
+
+// TableFrag is a table of the addresses of functions: the vtable of a class, which lists the methods of its objects.
+type TableFrag struct {
+	Label  Label
+	Labels []Label
+}
+
+func (frag *TableFrag) IsFragment() {}
@@ -0,0 +1,265 @@
+package ir
+
+import (
+	"sort"
+)
+
+// The collector of the runtime moves objects, so it has to find every word that holds a heap pointer while a
+// function is in a call: the words of the frames described by pointer maps, and the fields of the objects described
+// by their headers. The frontend knows the types: it marks the variables, loads and function results that are heap
+// pointers. Temps made up later, by canonicalization for instance, are found by following the moves from those. A
+// backend then keeps the pointers that are live across a call in its frame, where the pointer map of the call lists
+// them. The targets with registers have one map for all the calls of a function, see SpillPointers.
+
+// runtimePointers are the runtime functions that return heap pointers.
+var runtimePointers = map[string]bool{
+	"gcAllocRecord": true,
+	"gcInitArray":   true,
+	"getchar":       true,
+	"chr":           true,
+	"substring":     true,
+	"concat":        true,
+}
+
+// PointerTemps returns the temps of the canonical body that hold heap pointers: the variables in temps and those
+// that pointers are moved into. results lists the functions of the program that return heap pointers.
+func PointerTemps(tm *TempManagement, body []StmIr, temps TempSet, results map[Label]bool) TempSet {
+	pointers := temps.Clone()
+	isPointer := func(e ExpIr) bool {
+		switch v := e.(type) {
+		case *TempExpIr:
+			return pointers.Has(v.Temp)
+		case *MemExpIr:
+			return v.Ptr
+		case *NameExpIr:
+			// string literals, which are not on the heap but are pointers all the same
+			return true
+		case *CallExpIr:
+			name, ok := v.Exp.(*NameExpIr)
+			return v.Ptr || ok && (results[name.Label] || runtimePointers[tm.LabelString(name.Label)])
+		default:
+			return false
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, stm := range body {
+			move, ok := stm.(*MoveStmIr)
+			if !ok {
+				continue
+			}
+
+			dst, ok := move.Dst.(*TempExpIr)
+			if ok && !pointers.Has(dst.Temp) && isPointer(move.Src) {
+				pointers.Add(dst.Temp)
+				changed = true
+			}
+		}
+	}
+
+	return pointers
+}
+
+// IsCall tells whether a canonical statement calls a function, which is where a collection can happen.
+func IsCall(stm StmIr) bool {
+	switch v := stm.(type) {
+	case *MoveStmIr:
+		_, ok := v.Src.(*CallExpIr)
+		return ok
+	case *ExpStmIr:
+		_, ok := v.Exp.(*CallExpIr)
+		return ok
+	default:
+		return false
+	}
+}
+
+// LiveAcrossCalls returns the temps that are live across each call of the canonical body, by the index of the call:
+// those live after it but for the one that receives its result.
+func LiveAcrossCalls(body []StmIr) map[int]TempSet {
+	labels := make(map[Label]int)
+	for i, stm := range body {
+		if v, ok := stm.(*LabelStmIr); ok {
+			labels[v.Label] = i
+		}
+	}
+
+	succs := make([][]int, len(body))
+	uses := make([]TempSet, len(body))
+	defs := make([]TempSet, len(body))
+	for i, stm := range body {
+		uses[i], defs[i] = NewTempSet(), NewTempSet()
+		switch v := stm.(type) {
+		case *JumpStmIr:
+			for _, label := range v.Labels {
+				if j, ok := labels[label]; ok {
+					succs[i] = append(succs[i], j)
+				}
+			}
+
+		case *CJumpStmIr:
+			tempsOf(v.Left, uses[i])
+			tempsOf(v.Right, uses[i])
+			for _, label := range []Label{v.TrueLabel, v.FalseLabel} {
+				if j, ok := labels[label]; ok {
+					succs[i] = append(succs[i], j)
+				}
+			}
+
+		case *MoveStmIr:
+			if dst, ok := v.Dst.(*TempExpIr); ok {
+				defs[i].Add(dst.Temp)
+			} else {
+				tempsOf(v.Dst, uses[i])
+			}
+
+			tempsOf(v.Src, uses[i])
+
+		case *ExpStmIr:
+			tempsOf(v.Exp, uses[i])
+		}
+
+		switch stm.(type) {
+		case *JumpStmIr, *CJumpStmIr:
+		default:
+			if i+1 < len(body) {
+				succs[i] = append(succs[i], i+1)
+			}
+		}
+	}
+
+	liveIn, liveOut := make([]TempSet, len(body)), make([]TempSet, len(body))
+	for i := range body {
+		liveIn[i], liveOut[i] = NewTempSet(), NewTempSet()
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i := len(body) - 1; i >= 0; i-- {
+			out := NewTempSet()
+			for _, j := range succs[i] {
+				out = out.Union(liveIn[j])
+			}
+
+			in := uses[i].Union(out.Diff(defs[i]))
+			if !in.Equal(liveIn[i]) || !out.Equal(liveOut[i]) {
+				liveIn[i], liveOut[i] = in, out
+				changed = true
+			}
+		}
+	}
+
+	across := make(map[int]TempSet)
+	for i, stm := range body {
+		if IsCall(stm) {
+			across[i] = liveOut[i].Diff(defs[i])
+		}
+	}
+
+	return across
+}
+
+// tempsOf adds the temps that e reads to temps.
+func tempsOf(e ExpIr, temps TempSet) {
+	switch v := e.(type) {
+	case *TempExpIr:
+		temps.Add(v.Temp)
+	case *BinOpExpIr:
+		tempsOf(v.Left, temps)
+		tempsOf(v.Right, temps)
+	case *MemExpIr:
+		tempsOf(v.Mem, temps)
+	case *CallExpIr:
+		tempsOf(v.Exp, temps)
+		for _, arg := range v.Args {
+			tempsOf(arg, temps)
+		}
+	}
+}
+
+// ReplaceTemps rebuilds a canonical statement with the temps in exps replaced by their expressions.
+func ReplaceTemps(stm StmIr, exps map[Temp]ExpIr) StmIr {
+	var exp func(e ExpIr) ExpIr
+	exp = func(e ExpIr) ExpIr {
+		switch v := e.(type) {
+		case *TempExpIr:
+			if r, ok := exps[v.Temp]; ok {
+				return r
+			}
+
+			return v
+		case *BinOpExpIr:
+			return &BinOpExpIr{Binop: v.Binop, Left: exp(v.Left), Right: exp(v.Right)}
+		case *MemExpIr:
+			return &MemExpIr{Mem: exp(v.Mem), Ptr: v.Ptr}
+		case *CallExpIr:
+			args := make([]ExpIr, 0, len(v.Args))
+			for _, arg := range v.Args {
+				args = append(args, exp(arg))
+			}
+
+			return &CallExpIr{Exp: exp(v.Exp), Args: args, Ptr: v.Ptr}
+		default:
+			return v
+		}
+	}
+
+	switch v := stm.(type) {
+	case *MoveStmIr:
+		return &MoveStmIr{Dst: exp(v.Dst), Src: exp(v.Src)}
+	case *ExpStmIr:
+		return &ExpStmIr{Exp: exp(v.Exp)}
+	case *CJumpStmIr:
+		return &CJumpStmIr{
+			Relop:      v.Relop,
+			Left:       exp(v.Left),
+			Right:      exp(v.Right),
+			TrueLabel:  v.TrueLabel,
+			FalseLabel: v.FalseLabel,
+		}
+	default:
+		return v
+	}
+}
+
+// SpillPointers moves the heap pointers of the canonical body of frame that are live across calls into new words of
+// the frame, which it records as pointers, and returns the body that uses them. No register holds a heap pointer
+// during a call then, and the map of the frame, which is the same for all its calls, lists every word the collector
+// has to update. The calls that return heap pointers are marked by the frontend.
+func SpillPointers(tm *TempManagement, frame PointerFrame, body []StmIr) []StmIr {
+	pointers := PointerTemps(tm, body, frame.PointerTemps(), nil)
+	exps := make(map[Temp]ExpIr)
+	across := LiveAcrossCalls(body)
+	for i := range body {
+		// the map gives a random order, keep the frame layout stable
+		temps := make([]Temp, 0, len(across[i]))
+		for temp := range across[i] {
+			temps = append(temps, temp)
+		}
+
+		sort.Slice(temps, func(i, j int) bool { return temps[i] < temps[j] })
+		for _, temp := range temps {
+			if _, ok := exps[temp]; ok || !pointers.Has(temp) || temp == frame.FP() || temp == frame.RV() {
+				continue
+			}
+
+			acc := frame.AllocLocal(true)
+			frame.Pointer(acc)
+			mem := acc.Exp(&TempExpIr{Temp: frame.FP()}).(*MemExpIr)
+			mem.Ptr = true
+			exps[temp] = mem
+		}
+	}
+
+	if len(exps) == 0 {
+		return body
+	}
+
+	spilled := make([]StmIr, 0, len(body))
+	for _, stm := range body {
+		spilled = append(spilled, ReplaceTemps(stm, exps))
+	}
+
+	return spilled
+}
@@ -0,0 +1,698 @@
+package ir
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// IrMode selects which form of the procedure bodies the interpreter runs.
+type IrMode int
+
+const (
+	// IrTree runs the trees that Semant.TransProg returns, ESEQs and nested SEQs included
+	IrTree IrMode = iota
+
+	// IrLinear runs the statements of Canon.Linearize
+	IrLinear
+
+	// IrCanon runs the trace schedule, the input of instruction selection
+	IrCanon
+)
+
+func ParseIrMode(s string) (IrMode, error) {
+	switch s {
+	case "tree":
+		return IrTree, nil
+	case "linear":
+		return IrLinear, nil
+	case "canon":
+		return IrCanon, nil
+	}
+
+	return 0, unknownIrModeErr(s)
+}
+
+// irFrame is implemented by the frames whose calling convention the interpreter follows: every formal arrives in a
+// temp of its own and the escaping variables live in memory above the frame pointer.
+type irFrame interface {
+	Frame
+	ParamTemps() []Temp
+	FrameWords() int32
+}
+
+const (
+	// addresses below irDataBase are never allocated, loads and stores there dereference nil
+	irDataBase = 1024
+
+	irStackSize    = 1 << 20
+	irMaxCallDepth = 10000
+)
+
+// irBlock is a list of statements along with the position of its labels. A jump to a label that the block does not
+// have leaves it, the enclosing block or the caller of the procedure handles it.
+type irBlock struct {
+	stms   []StmIr
+	labels map[Label]int
+}
+
+func newIrBlock(stms []StmIr) *irBlock {
+	b := &irBlock{stms: stms, labels: make(map[Label]int)}
+	for i, stm := range stms {
+		if v, ok := stm.(*LabelStmIr); ok {
+			b.labels[v.Label] = i
+		}
+	}
+
+	return b
+}
+
+// irJump unwinds the evaluation of an expression or a block until a block that has the label.
+type irJump struct {
+	label Label
+}
+
+// irTrap unwinds the interpreter when the program does something undefined.
+type irTrap struct {
+	err error
+}
+
+// irExit unwinds the interpreter when the program calls exit.
+type irExit struct {
+	code int
+}
+
+type irProc struct {
+	frame irFrame
+	body  *irBlock
+}
+
+// IrInterpreter runs the fragments of a program without a backend. It keeps the memory of the program, laid out as
+// the strings and the tables, then the stack and then the heap, and implements the runtime functions of env.go's baseFuncs along
+// with gcInitArray and gcAllocRecord.
+type IrInterpreter struct {
+	tm     *TempManagement
+	dumper *Dumper
+	mode   IrMode
+	procs  map[Label]*irProc
+	data   map[Label]int64
+
+	// code maps the addresses given to the procedures, which are outside of the memory, to their labels
+	code     map[int64]Label
+	blocks   map[StmIr]*irBlock
+	mem      []byte
+	wordSize int64
+	sp       int64
+	temps    map[Temp]int64
+
+	stdin  *bufio.Reader
+	stdout io.Writer
+
+	// MaxSteps stops runaway programs. Zero means no limit.
+	MaxSteps int
+	steps    int
+	depth    int
+}
+
+func NewIrInterpreter(tm *TempManagement, dumper *Dumper, frags []Frag, mode IrMode, wordSize int32, stdin io.Reader,
+	stdout io.Writer) (*IrInterpreter, error) {
+	in := &IrInterpreter{
+		tm:       tm,
+		dumper:   dumper,
+		mode:     mode,
+		procs:    make(map[Label]*irProc),
+		data:     make(map[Label]int64),
+		code:     make(map[int64]Label),
+		blocks:   make(map[StmIr]*irBlock),
+		mem:      make([]byte, irDataBase),
+		wordSize: int64(wordSize),
+		stdin:    bufio.NewReader(stdin),
+		stdout:   stdout,
+	}
+
+	if err := in.Load(frags); err != nil {
+		return nil, err
+	}
+
+	in.mem = append(in.mem, make([]byte, irStackSize)...)
+	in.sp = int64(len(in.mem))
+	return in, nil
+}
+
+// Load adds fragments to the program, like the functions and the strings of an input of a REPL. Those loaded after
+// the interpreter is made are placed on the heap.
+func (in *IrInterpreter) Load(frags []Frag) error {
+	var tables []*TableFrag
+	for _, frag := range frags {
+		switch v := frag.(type) {
+		case *TableFrag:
+			tables = append(tables, v)
+
+		case *StrFrag:
+			in.data[v.Label] = int64(len(in.mem))
+			in.mem = append(in.mem, v.Str...)
+			in.mem = append(in.mem, 0)
+			for len(in.mem)%int(in.wordSize) != 0 {
+				in.mem = append(in.mem, 0)
+			}
+
+		case *ProcFrag:
+			frame, ok := v.Frame.(irFrame)
+			if !ok {
+				return unsupportedIrFrameErr()
+			}
+
+			in.procs[frame.Name()] = &irProc{frame: frame, body: in.body(v)}
+			addr := -int64(len(in.procs)) * in.wordSize
+			in.data[frame.Name()] = addr
+			in.code[addr] = frame.Name()
+		}
+	}
+
+	// the tables hold the addresses of procedures, which are all known now
+	for _, table := range tables {
+		in.data[table.Label] = int64(len(in.mem))
+		in.mem = append(in.mem, make([]byte, len(table.Labels)*int(in.wordSize))...)
+		for i, label := range table.Labels {
+			addr, ok := in.data[label]
+			if !ok {
+				return undefinedIrLabelErr(in.tm.LabelString(label))
+			}
+
+			in.store(in.data[table.Label]+int64(i)*in.wordSize, addr)
+		}
+	}
+
+	return nil
+}
+
+// body is the body of proc in the form that the interpreter runs.
+func (in *IrInterpreter) body(proc *ProcFrag) *irBlock {
+	var stms []StmIr
+	switch in.mode {
+	case IrTree:
+		stms = []StmIr{proc.Body}
+	case IrLinear:
+		stms, _ = NewCanon(in.tm).Linearize(proc.Body)
+	case IrCanon:
+		stms = Canonicalize(in.tm, in.dumper, proc)
+	}
+
+	return newIrBlock(stms)
+}
+
+// Run calls main with a nil static link and returns the exit code of the program.
+func (in *IrInterpreter) Run() (code int, err error) {
+	defer func() {
+		switch v := recover().(type) {
+		case nil:
+		case *irExit:
+			code = v.code
+		case *irTrap:
+			err = v.err
+		default:
+			panic(v)
+		}
+	}()
+
+	in.call(in.tm.NamedLabel("main"), []int64{0})
+	return 0, nil
+}
+
+// Exec runs proc in the frame at fp instead of a new one on the stack, and returns the value of proc. The frame
+// outlives the run, like that of a REPL session whose inputs are run one after the other. A call to exit is an error.
+func (in *IrInterpreter) Exec(proc *ProcFrag, fp int64) (rv int64, err error) {
+	frame, ok := proc.Frame.(irFrame)
+	if !ok {
+		return 0, unsupportedIrFrameErr()
+	}
+
+	// a trap leaves the stack of the calls it unwinds
+	temps, sp, depth := in.temps, in.sp, in.depth
+	defer func() {
+		in.temps, in.sp, in.depth = temps, sp, depth
+		switch v := recover().(type) {
+		case nil:
+		case *irExit:
+			err = irExitErr(v.code)
+		case *irTrap:
+			err = v.err
+		default:
+			panic(v)
+		}
+	}()
+
+	in.steps = 0
+	in.temps = map[Temp]int64{frame.FP(): fp}
+	// the procedure has no caller, its static link is nil
+	for _, param := range frame.ParamTemps() {
+		in.temps[param] = 0
+	}
+
+	if label, jumped := in.run(in.body(proc)); jumped {
+		in.trap(undefinedIrLabelErr(in.tm.LabelString(label)))
+	}
+
+	return in.temps[frame.RV()], nil
+}
+
+// NewFrame takes the memory of a frame of words from the heap, for Exec.
+func (in *IrInterpreter) NewFrame(words int32) int64 {
+	return in.alloc(int64(words) * in.wordSize)
+}
+
+// Word reads the word at addr, like a field of a record that a REPL shows.
+func (in *IrInterpreter) Word(addr int64) (v int64, err error) {
+	defer catchIrTrap(&err)
+	return in.load(addr), nil
+}
+
+// String reads the string at addr.
+func (in *IrInterpreter) String(addr int64) (s string, err error) {
+	defer catchIrTrap(&err)
+	return string(in.str(addr)), nil
+}
+
+// catchIrTrap turns a trap outside of a run into the error of the function that defers it.
+func catchIrTrap(err *error) {
+	switch v := recover().(type) {
+	case nil:
+	case *irTrap:
+		*err = v.err
+	default:
+		panic(v)
+	}
+}
+
+func (in *IrInterpreter) trap(err error) {
+	panic(&irTrap{err: err})
+}
+
+func (in *IrInterpreter) call(label Label, args []int64) int64 {
+	proc, ok := in.procs[label]
+	if !ok {
+		builtin, ok := irBuiltins[in.tm.LabelString(label)]
+		if !ok || builtin.params != len(args) {
+			in.trap(undefinedIrFuncErr(in.tm.LabelString(label), len(args)))
+		}
+
+		return builtin.call(in, args)
+	}
+
+	params := proc.frame.ParamTemps()
+	if len(params) != len(args) {
+		in.trap(undefinedIrFuncErr(in.tm.LabelString(label), len(args)))
+	}
+
+	in.depth++
+	if in.depth > irMaxCallDepth {
+		in.trap(fmt.Errorf("call stack exhausted"))
+	}
+
+	temps, sp := in.temps, in.sp
+	in.sp -= int64(proc.frame.FrameWords()) * in.wordSize
+	if in.sp < irDataBase {
+		in.trap(fmt.Errorf("stack overflow"))
+	}
+
+	in.temps = map[Temp]int64{proc.frame.FP(): in.sp}
+	for i, param := range params {
+		in.temps[param] = args[i]
+	}
+
+	if label, jumped := in.run(proc.body); jumped {
+		in.trap(undefinedIrLabelErr(in.tm.LabelString(label)))
+	}
+
+	rv := in.temps[proc.frame.RV()]
+	in.temps, in.sp = temps, sp
+	in.depth--
+	return rv
+}
+
+// block returns the statements of a SEQ as a block, flattening nested SEQs.
+func (in *IrInterpreter) block(stm StmIr) *irBlock {
+	if b, ok := in.blocks[stm]; ok {
+		return b
+	}
+
+	var stms []StmIr
+	var flatten func(s StmIr)
+	flatten = func(s StmIr) {
+		if v, ok := s.(*SeqStmIr); ok {
+			flatten(v.First)
+			flatten(v.Second)
+			return
+		}
+
+		if s != nil {
+			stms = append(stms, s)
+		}
+	}
+
+	flatten(stm)
+	b := newIrBlock(stms)
+	in.blocks[stm] = b
+	return b
+}
+
+// run runs a block and returns the label of a jump that b has no label for.
+func (in *IrInterpreter) run(b *irBlock) (Label, bool) {
+	for pc := 0; pc < len(b.stms); pc++ {
+		label, jumped := in.step(b.stms[pc])
+		if !jumped {
+			continue
+		}
+
+		i, ok := b.labels[label]
+		if !ok {
+			return label, true
+		}
+
+		pc = i
+	}
+
+	return 0, false
+}
+
+// step runs one statement, along with the jumps out of the ESEQs in its expressions.
+func (in *IrInterpreter) step(stm StmIr) (target Label, jumped bool) {
+	defer func() {
+		switch v := recover().(type) {
+		case nil:
+		case *irJump:
+			target, jumped = v.label, true
+		default:
+			panic(v)
+		}
+	}()
+
+	return in.exec(stm)
+}
+
+// exec runs one statement and returns the label it jumps to, if any.
+func (in *IrInterpreter) exec(stm StmIr) (Label, bool) {
+	in.steps++
+	if in.MaxSteps > 0 && in.steps > in.MaxSteps {
+		in.trap(stepLimitErr(in.MaxSteps))
+	}
+
+	switch v := stm.(type) {
+	case *SeqStmIr:
+		// a jump out of the SEQ goes on in the enclosing block
+		return in.run(in.block(v))
+
+	case *LabelStmIr:
+
+	case *MoveStmIr:
+		switch dst := v.Dst.(type) {
+		case *TempExpIr:
+			in.temps[dst.Temp] = in.eval(v.Src)
+		case *MemExpIr:
+			addr := in.eval(dst.Mem)
+			in.store(addr, in.eval(v.Src))
+		default:
+			in.trap(fmt.Errorf("invalid destination of a move"))
+		}
+
+	case *ExpStmIr:
+		in.eval(v.Exp)
+
+	case *JumpStmIr:
+		name, ok := v.Exp.(*NameExpIr)
+		if !ok {
+			in.trap(fmt.Errorf("jump to a computed address"))
+		}
+
+		return name.Label, true
+
+	case *CJumpStmIr:
+		left, right := in.eval(v.Left), in.eval(v.Right)
+		if irRelOp(v.Relop, left, right) {
+			return v.TrueLabel, true
+		}
+
+		return v.FalseLabel, true
+
+	default:
+		in.trap(fmt.Errorf("invalid IR statement %T", stm))
+	}
+
+	return 0, false
+}
+
+func (in *IrInterpreter) eval(exp ExpIr) int64 {
+	switch v := exp.(type) {
+	case *ConstExpIr:
+		return int64(v.Value)
+
+	case *NameExpIr:
+		addr, ok := in.data[v.Label]
+		if !ok {
+			in.trap(undefinedIrLabelErr(in.tm.LabelString(v.Label)))
+		}
+
+		return addr
+
+	case *TempExpIr:
+		val, ok := in.temps[v.Temp]
+		if !ok {
+			in.trap(fmt.Errorf("temp %s is read before it is written", v.Temp))
+		}
+
+		return val
+
+	case *BinOpExpIr:
+		left, right := in.eval(v.Left), in.eval(v.Right)
+		return in.wrap(in.binOp(v.Binop, left, right))
+
+	case *MemExpIr:
+		return in.load(in.eval(v.Mem))
+
+	case *CallExpIr:
+		var label Label
+		if name, ok := v.Exp.(*NameExpIr); ok {
+			label = name.Label
+		} else {
+			addr := in.eval(v.Exp)
+			if label, ok = in.code[addr]; !ok {
+				in.trap(fmt.Errorf("call to address %d, which is not a procedure", addr))
+			}
+		}
+
+		args := make([]int64, 0, len(v.Args))
+		for _, arg := range v.Args {
+			args = append(args, in.eval(arg))
+		}
+
+		return in.call(label, args)
+
+	case *EsEqExpIr:
+		if label, jumped := in.run(in.block(v.Stm)); jumped {
+			panic(&irJump{label: label})
+		}
+
+		return in.eval(v.Exp)
+	}
+
+	in.trap(fmt.Errorf("invalid IR expression %T", exp))
+	return 0
+}
+
+func (in *IrInterpreter) binOp(binop BinOpIr, left, right int64) int64 {
+	switch binop {
+	case PlusIr:
+		return left + right
+	case MinusIr:
+		return left - right
+	case MulIr:
+		return left * right
+	case DivIr:
+		if right == 0 {
+			in.trap(divisionByZeroErr())
+		}
+
+		return left / right
+	}
+
+	in.trap(fmt.Errorf("invalid binary operator %d", binop))
+	return 0
+}
+
+func irRelOp(relop RelOpIr, left, right int64) bool {
+	switch relop {
+	case EqIr:
+		return left == right
+	case NeIr:
+		return left != right
+	case LtIr:
+		return left < right
+	case GtIr:
+		return left > right
+	case LeIr:
+		return left <= right
+	case GeIr:
+		return left >= right
+	}
+
+	panic("invalid relational operator")
+}
+
+// wrap truncates a value to the word size of the target.
+func (in *IrInterpreter) wrap(v int64) int64 {
+	if in.wordSize == 4 {
+		return int64(int32(v))
+	}
+
+	return v
+}
+
+func (in *IrInterpreter) check(addr, size int64) {
+	if addr < irDataBase {
+		in.trap(nilDereferenceErr(addr))
+	}
+
+	if addr+size > int64(len(in.mem)) || addr%size != 0 {
+		in.trap(invalidMemoryAccessErr(addr))
+	}
+}
+
+func (in *IrInterpreter) load(addr int64) int64 {
+	in.check(addr, in.wordSize)
+	var v uint64
+	for i := in.wordSize - 1; i >= 0; i-- {
+		v = v<<8 | uint64(in.mem[addr+i])
+	}
+
+	if in.wordSize == 4 {
+		return int64(int32(v))
+	}
+
+	return int64(v)
+}
+
+func (in *IrInterpreter) store(addr, v int64) {
+	in.check(addr, in.wordSize)
+	for i := int64(0); i < in.wordSize; i++ {
+		in.mem[addr+i] = byte(v >> (8 * i))
+	}
+}
+
+// alloc takes n zeroed bytes from the heap.
+func (in *IrInterpreter) alloc(n int64) int64 {
+	if n < 0 || n > math.MaxInt32 {
+		in.trap(fmt.Errorf("invalid allocation of %d bytes", n))
+	}
+
+	p := int64(len(in.mem))
+	in.mem = append(in.mem, make([]byte, (n+in.wordSize-1)/in.wordSize*in.wordSize)...)
+	return p
+}
+
+func (in *IrInterpreter) initArray(n, init int64) int64 {
+	if n < 0 || n > math.MaxInt32/in.wordSize {
+		in.trap(fmt.Errorf("invalid array size %d", n))
+	}
+
+	p := in.alloc(n * in.wordSize)
+	for i := int64(0); i < n; i++ {
+		in.store(p+i*in.wordSize, init)
+	}
+
+	return p
+}
+
+// str reads the null-terminated string at addr.
+func (in *IrInterpreter) str(addr int64) []byte {
+	in.check(addr, 1)
+	for end := addr; end < int64(len(in.mem)); end++ {
+		if in.mem[end] == 0 {
+			return in.mem[addr:end]
+		}
+	}
+
+	in.trap(fmt.Errorf("unterminated string at address %d", addr))
+	return nil
+}
+
+func (in *IrInterpreter) newStr(b []byte) int64 {
+	p := in.alloc(int64(len(b)) + 1)
+	copy(in.mem[p:], b)
+	return p
+}
+
+type irBuiltin struct {
+	params int
+	call   func(in *IrInterpreter, args []int64) int64
+}
+
+var irBuiltins = map[string]irBuiltin{
+	"print": {1, func(in *IrInterpreter, args []int64) int64 {
+		in.stdout.Write(in.str(args[0]))
+		return 0
+	}},
+	"printi": {1, func(in *IrInterpreter, args []int64) int64 {
+		fmt.Fprint(in.stdout, args[0])
+		return 0
+	}},
+	"flush": {0, func(in *IrInterpreter, args []int64) int64 {
+		return 0
+	}},
+	"getchar": {0, func(in *IrInterpreter, args []int64) int64 {
+		c, err := in.stdin.ReadByte()
+		if err != nil {
+			return in.newStr(nil)
+		}
+
+		return in.newStr([]byte{c})
+	}},
+	"ord": {1, func(in *IrInterpreter, args []int64) int64 {
+		s := in.str(args[0])
+		if len(s) == 0 {
+			return -1
+		}
+
+		return int64(s[0])
+	}},
+	"chr": {1, func(in *IrInterpreter, args []int64) int64 {
+		return in.newStr([]byte{byte(args[0])})
+	}},
+	"size": {1, func(in *IrInterpreter, args []int64) int64 {
+		return int64(len(in.str(args[0])))
+	}},
+	"stringCompare": {2, func(in *IrInterpreter, args []int64) int64 {
+		return int64(bytes.Compare(in.str(args[0]), in.str(args[1])))
+	}},
+	"substring": {3, func(in *IrInterpreter, args []int64) int64 {
+		s, first, n := in.str(args[0]), args[1], args[2]
+		if first < 0 || n < 0 || first+n > int64(len(s)) {
+			in.trap(fmt.Errorf("substring(%q, %d, %d) is out of range", s, first, n))
+		}
+
+		return in.newStr(s[first : first+n])
+	}},
+	"concat": {2, func(in *IrInterpreter, args []int64) int64 {
+		a, b := in.str(args[0]), in.str(args[1])
+		return in.newStr(append(append([]byte{}, a...), b...))
+	}},
+	"not": {1, func(in *IrInterpreter, args []int64) int64 {
+		if args[0] == 0 {
+			return 1
+		}
+
+		return 0
+	}},
+	"exit": {1, func(in *IrInterpreter, args []int64) int64 {
+		panic(&irExit{code: int(args[0])})
+	}},
+
+	// the interpreter never collects, it only needs the sizes of the objects
+	"gcInitArray": {3, func(in *IrInterpreter, args []int64) int64 {
+		return in.initArray(args[0], args[1])
+	}},
+	"gcAllocRecord": {1, func(in *IrInterpreter, args []int64) int64 {
+		return in.alloc(int64(len(in.str(args[0]))) * in.wordSize)
+	}},
+}
@@ -0,0 +1,198 @@
+package ir
+
+import (
+	"fmt"
+	"sync"
+
+	"tiger/syntax"
+)
+
+type (
+	// Label for memory address (function body's code)
+	Label syntax.Symbol
+	// Temp for registers (local variables and parameters)
+	Temp syntax.Symbol
+)
+
+// String is the name of a temp that is not a register.
+func (t Temp) String() string {
+	return fmt.Sprintf("t%d", t)
+}
+
+// Registers is the number of temps that are the registers of the targets. The frames declare their registers as the
+// constant temps from 1, the temps that TempManagement makes up come after them.
+const Registers = 64
+
+// TempManagement makes up the temps and the labels of a compilation. The labels are symbols of its strings, along
+// with the names of the program. It is safe for concurrent use, so that the functions can be allocated in parallel.
+type TempManagement struct {
+	mu       sync.Mutex
+	strs     *syntax.Strings
+	tempCnt  int
+	labelCnt int
+
+	// Module prefixes the labels made up while compiling a module on its own, so that they do not clash with those of
+	// the units it is linked with
+	Module string
+}
+
+func NewTempManagement(strs *syntax.Strings) *TempManagement {
+	return &TempManagement{
+		strs:    strs,
+		tempCnt: Registers,
+	}
+}
+
+// Strings are the strings that the labels and the symbols of the compilation are made of.
+func (t *TempManagement) Strings() *syntax.Strings {
+	return t.strs
+}
+
+func (t *TempManagement) NewTemp() Temp {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tempCnt++
+	return Temp(t.tempCnt)
+}
+
+// Fork returns a TempManagement that makes up the temps after those made so far on its own, for a function whose
+// registers are allocated in parallel with the others. The temps of a fork are numbered the same way whatever the
+// number of jobs, and they only have to differ from those of its function. A fork makes no labels.
+func (t *TempManagement) Fork() *TempManagement {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &TempManagement{
+		strs:    t.strs,
+		tempCnt: t.tempCnt,
+		Module:  t.Module,
+	}
+}
+
+func (t *TempManagement) NewLabel() Label {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tempCnt++
+	if t.Module != "" {
+		return Label(t.strs.Symbol(fmt.Sprintf("%sL%d", modulePrefix(t.Module), t.tempCnt)))
+	}
+
+	return Label(t.strs.Symbol(fmt.Sprintf("L%d", t.tempCnt)))
+}
+
+func (t *TempManagement) LabelString(label Label) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.strs.Get(syntax.Symbol(label))
+}
+
+func (t *TempManagement) NamedLabel(s string) Label {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Label(t.strs.Symbol(s))
+}
+
+// FuncLabel is a label of the function name that no other declaration shares: a new label followed by the name. The
+// functions of the same name in different scopes, and those named like the runtime or main, get different labels.
+func (t *TempManagement) FuncLabel(name string) Label {
+	return t.NamedLabel(t.LabelString(t.NewLabel()) + "_" + name)
+}
+
+// ModuleLabel is the label of the function name declared by module.
+func (t *TempManagement) ModuleLabel(module, name string) Label {
+	return t.NamedLabel(modulePrefix(module) + "_" + name)
+}
+
+// modulePrefix starts the labels of a module. Tiger names cannot start with an underscore, and the length tells where
+// the name of the module ends, so the labels of two modules never clash with each other or with those of a program.
+func modulePrefix(module string) string {
+	return fmt.Sprintf("_%d%s", len(module), module)
+}
+
+type TempSet map[Temp]struct{}
+
+func NewTempSet(temps ...Temp) TempSet {
+	ts := make(TempSet)
+	for _, t := range temps {
+		ts.Add(t)
+	}
+
+	return ts
+}
+
+func (s TempSet) Split() (Temp, TempSet) {
+	for k := range s {
+		delete(s, k)
+		return k, s
+	}
+
+	panic("cannot go to there")
+}
+
+func (s TempSet) Remove(temp Temp) {
+	if _, ok := s[temp]; !ok {
+		return
+	}
+
+	delete(s, temp)
+}
+
+func (s TempSet) Add(temp Temp) {
+	s[temp] = struct{}{}
+}
+
+func (s TempSet) Diff(s1 TempSet) TempSet {
+	diff := make(TempSet)
+	for k := range s {
+		if _, ok := s1[k]; !ok {
+			diff[k] = struct{}{}
+		}
+	}
+
+	return diff
+}
+
+func (s TempSet) Intersect(s1 TempSet) TempSet {
+	intersect := make(TempSet)
+	for k := range s {
+		if _, ok := s1[k]; ok {
+			intersect[k] = struct{}{}
+		}
+	}
+
+	return intersect
+}
+
+func (s TempSet) Union(s1 TempSet) TempSet {
+	union := make(TempSet)
+	for k := range s {
+		union[k] = struct{}{}
+	}
+
+	for k := range s1 {
+		union[k] = struct{}{}
+	}
+
+	return union
+}
+
+func (s TempSet) Empty() bool {
+	return len(s) == 0
+}
+
+func (s TempSet) Equal(s1 TempSet) bool {
+	return s.Diff(s1).Empty() && s1.Diff(s).Empty()
+}
+
+func (s TempSet) Clone() TempSet {
+	clone := make(TempSet)
+	for k := range s {
+		clone[k] = struct{}{}
+	}
+
+	return clone
+}
+
+func (s TempSet) Has(tmp Temp) bool {
+	_, ok := s[tmp]
+	return ok
+}
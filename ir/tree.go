@@ -0,0 +1,420 @@
+// Package ir is what the semantic analysis hands to the backends: the trees of the functions with their temps and
+// labels, how they are canonicalized, the instructions selected from them, the frames that the targets implement and
+// an interpreter of the trees.
+package ir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tiger/syntax"
+)
+
+type BinOpIr int
+
+const (
+	PlusIr BinOpIr = iota
+	MinusIr
+	MulIr
+	DivIr
+)
+
+type RelOpIr int
+
+const (
+	EqIr RelOpIr = iota
+	NeIr
+	LtIr
+	GtIr
+	LeIr
+	GeIr
+)
+
+func (r RelOpIr) not() RelOpIr {
+	switch r {
+	case EqIr:
+		return NeIr
+	case NeIr:
+		return EqIr
+	case LtIr:
+		return GeIr
+	case GtIr:
+		return LeIr
+	case LeIr:
+		return GtIr
+	case GeIr:
+		return LtIr
+	}
+
+	panic("unimplemented operator")
+}
+
+// Commute returns the operator to use when the operands of a comparison are swapped.
+func (r RelOpIr) Commute() RelOpIr {
+	switch r {
+	case LtIr:
+		return GtIr
+	case GtIr:
+		return LtIr
+	case LeIr:
+		return GeIr
+	case GeIr:
+		return LeIr
+	}
+
+	return r
+}
+
+func (r RelOpIr) repr() string {
+	switch r {
+	case EqIr:
+		return "eq"
+	case NeIr:
+		return "ne"
+	case LtIr:
+		return "lt"
+	case GtIr:
+		return "gt"
+	case LeIr:
+		return "le"
+	case GeIr:
+		return "ge"
+	}
+
+	panic("unimplemented operator")
+}
+
+type ExpIr interface {
+	PrintExpIr(sb *strings.Builder, strs *syntax.Strings, level int)
+}
+
+type ConstExpIr struct {
+	Value int32
+}
+
+func (e *ConstExpIr) PrintExpIr(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("Const\n")
+	indent(sb, level+1)
+	sb.WriteString(strconv.Itoa(int(e.Value)) + "\n")
+}
+
+// NameExpIr assembly language label
+type NameExpIr struct {
+	Label Label
+}
+
+func (e *NameExpIr) PrintExpIr(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("NameExp\n")
+	indent(sb, level+1)
+	sb.WriteString(strs.Get(syntax.Symbol(e.Label)) + "\n")
+}
+
+// TempExpIr corresponds to registers of machines, but in the abstract language, there are an infinite amount of registers.
+type TempExpIr struct {
+	Temp Temp
+}
+
+func (e *TempExpIr) PrintExpIr(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("Temp\n")
+	indent(sb, level+1)
+	sb.WriteString(e.Temp.String() + "\n")
+}
+
+type BinOpExpIr struct {
+	Binop BinOpIr
+	Left  ExpIr
+	Right ExpIr
+}
+
+func (e *BinOpExpIr) PrintExpIr(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	switch e.Binop {
+	case PlusIr:
+		sb.WriteString("Plus\n")
+	case MinusIr:
+		sb.WriteString("Minus\n")
+	case MulIr:
+		sb.WriteString("Mul\n")
+	case DivIr:
+		sb.WriteString("Div\n")
+	}
+
+	indent(sb, level+1)
+	sb.WriteString("Left\n")
+	e.Left.PrintExpIr(sb, strs, level+2)
+	indent(sb, level+1)
+	sb.WriteString("Right\n")
+	e.Right.PrintExpIr(sb, strs, level+2)
+}
+
+type MemExpIr struct {
+	Mem ExpIr
+
+	// Ptr is set when the word loaded is a heap pointer, which is what pointer maps are computed from
+	Ptr bool
+}
+
+func (e *MemExpIr) PrintExpIr(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("Mem\n")
+	e.Mem.PrintExpIr(sb, strs, level+1)
+}
+
+type CallExpIr struct {
+	Exp  ExpIr
+	Args []ExpIr
+
+	// Ptr is set when the callee, unknown before run time, returns a heap pointer
+	Ptr bool
+}
+
+func (e *CallExpIr) PrintExpIr(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("Call\n")
+	indent(sb, level+1)
+	sb.WriteString("FuncName\n")
+	e.Exp.PrintExpIr(sb, strs, level+2)
+	indent(sb, level+1)
+	sb.WriteString("Arguments\n")
+	for _, arg := range e.Args {
+		arg.PrintExpIr(sb, strs, level+2)
+	}
+}
+
+type EsEqExpIr struct {
+	Stm StmIr
+	Exp ExpIr
+}
+
+func (e *EsEqExpIr) PrintExpIr(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("EsEq\n")
+	indent(sb, level+1)
+	sb.WriteString("Stm\n")
+	e.Stm.PrintStm(sb, strs, level+2)
+	indent(sb, level+1)
+	sb.WriteString("Exp\n")
+	e.Exp.PrintExpIr(sb, strs, level+2)
+}
+
+type StmIr interface {
+	PrintStm(sb *strings.Builder, strs *syntax.Strings, level int)
+}
+
+type MoveStmIr struct {
+	Dst ExpIr
+	Src ExpIr
+}
+
+func (s *MoveStmIr) PrintStm(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("Move\n")
+	indent(sb, level+1)
+	sb.WriteString("Src\n")
+	s.Src.PrintExpIr(sb, strs, level+2)
+	indent(sb, level+1)
+	sb.WriteString("Dest\n")
+	s.Dst.PrintExpIr(sb, strs, level+2)
+}
+
+type ExpStmIr struct {
+	Exp ExpIr
+}
+
+func (s *ExpStmIr) PrintStm(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("ExpStm\n")
+	s.Exp.PrintExpIr(sb, strs, level+1)
+}
+
+type JumpStmIr struct {
+	Exp    ExpIr
+	Labels []Label
+}
+
+func (s *JumpStmIr) PrintStm(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("Jump\n")
+	s.Exp.PrintExpIr(sb, strs, level+1)
+}
+
+type CJumpStmIr struct {
+	Relop      RelOpIr
+	Left       ExpIr
+	Right      ExpIr
+	TrueLabel  Label
+	FalseLabel Label
+}
+
+func (s *CJumpStmIr) PrintStm(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("CJump\n")
+	indent(sb, level+1)
+	sb.WriteString("Op\n")
+	indent(sb, level+2)
+	sb.WriteString(s.Relop.repr() + "\n")
+	indent(sb, level+1)
+	sb.WriteString("Left\n")
+	s.Left.PrintExpIr(sb, strs, level+2)
+	indent(sb, level+1)
+	sb.WriteString("Right\n")
+	s.Right.PrintExpIr(sb, strs, level+2)
+	indent(sb, level+1)
+	sb.WriteString("True Label\n")
+	indent(sb, level+2)
+	sb.WriteString(strs.Get(syntax.Symbol(s.TrueLabel)) + "\n")
+	indent(sb, level+1)
+	sb.WriteString("False Label\n")
+	indent(sb, level+2)
+	sb.WriteString(strs.Get(syntax.Symbol(s.FalseLabel)) + "\n")
+}
+
+type SeqStmIr struct {
+	First  StmIr
+	Second StmIr
+}
+
+func (s *SeqStmIr) PrintStm(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("SeqStm\n")
+	indent(sb, level+1)
+	sb.WriteString("First\n")
+	s.First.PrintStm(sb, strs, level+2)
+	indent(sb, level+1)
+	sb.WriteString("Second\n")
+	s.Second.PrintStm(sb, strs, level+2)
+}
+
+func SeqStm(stms ...StmIr) StmIr {
+	if len(stms) == 0 {
+		return nil
+	}
+
+	if len(stms) == 1 {
+		return stms[0]
+	}
+
+	return &SeqStmIr{
+		First:  stms[0],
+		Second: SeqStm(stms[1:]...),
+	}
+}
+
+type LabelStmIr struct {
+	Label Label
+}
+
+func (s *LabelStmIr) PrintStm(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("Label\n")
+	indent(sb, level+1)
+	sb.WriteString(strs.Get(syntax.Symbol(s.Label)) + "\n")
+}
+
+// LineStmIr marks where the code of the statement of the source at Pos starts, for the line table of a debugger. It
+// does nothing and is only translated when the debug information is emitted.
+type LineStmIr struct {
+	Pos syntax.Pos
+}
+
+func (s *LineStmIr) PrintStm(sb *strings.Builder, strs *syntax.Strings, level int) {
+	indent(sb, level)
+	sb.WriteString("Line\n")
+	indent(sb, level+1)
+	sb.WriteString(fmt.Sprintf("%d:%d\n", s.Pos.Line, s.Pos.Col))
+}
+
+func IsNullStm(s StmIr) bool {
+	if v, ok := s.(*ExpStmIr); ok {
+		if v, ok := v.Exp.(*ConstExpIr); ok {
+			return v.Value == 0
+		}
+	}
+
+	return false
+}
+
+// CollectCallees records the number of arguments of every function that stm calls.
+func CollectCallees(stm StmIr, callees map[Label]int) {
+	var exp func(e ExpIr)
+	exp = func(e ExpIr) {
+		switch v := e.(type) {
+		case *BinOpExpIr:
+			exp(v.Left)
+			exp(v.Right)
+		case *MemExpIr:
+			exp(v.Mem)
+		case *CallExpIr:
+			if name, ok := v.Exp.(*NameExpIr); ok {
+				callees[name.Label] = len(v.Args)
+			}
+
+			for _, arg := range v.Args {
+				exp(arg)
+			}
+		}
+	}
+
+	switch v := stm.(type) {
+	case *MoveStmIr:
+		exp(v.Dst)
+		exp(v.Src)
+	case *ExpStmIr:
+		exp(v.Exp)
+	case *CJumpStmIr:
+		exp(v.Left)
+		exp(v.Right)
+	}
+}
+
+func debugStmIr(strs *syntax.Strings, stm StmIr) {
+	sb := strings.Builder{}
+	stm.PrintStm(&sb, strs, 0)
+	fmt.Println(sb.String())
+}
+
+func debugExpIr(strs *syntax.Strings, exp ExpIr) {
+	sb := strings.Builder{}
+	exp.PrintExpIr(&sb, strs, 0)
+	fmt.Println(sb.String())
+}
+
+// ConstValue folds expressions made only of constants, such as the field offsets that fieldVar builds.
+func ConstValue(exp ExpIr) (int32, bool) {
+	switch v := exp.(type) {
+	case *ConstExpIr:
+		return v.Value, true
+
+	case *BinOpExpIr:
+		left, ok := ConstValue(v.Left)
+		if !ok {
+			return 0, false
+		}
+
+		right, ok := ConstValue(v.Right)
+		if !ok {
+			return 0, false
+		}
+
+		switch v.Binop {
+		case PlusIr:
+			return left + right, true
+		case MinusIr:
+			return left - right, true
+		case MulIr:
+			return left * right, true
+		}
+	}
+
+	return 0, false
+}
+
+func indent(builder *strings.Builder, level int) {
+	for i := 0; i < level; i++ {
+		builder.WriteString("  ")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+	"tiger/ir"
+)
+
+// interpret translates src for the frames of arch and runs it on the interpreter.
+func interpret(t *testing.T, archName string, mode ir.IrMode, src string) (string, int, error) {
+	return interpretFile(t, archName, "test.tig", mode, src)
+}
+
+// interpretFile is interpret for the source of file.
+func interpretFile(t *testing.T, archName string, file string, mode ir.IrMode, src string) (string, int, error) {
+	c, err := compiler.NewCompilation(compiler.Options{File: file, Arch: archName})
+	require.NoError(t, err)
+
+	frags, err := c.Translate([]byte(src))
+	require.NoError(t, err)
+
+	out := bytes.Buffer{}
+	in, err := ir.NewIrInterpreter(c.TempManagement(), c.Dumper(), frags, mode, c.Arch().WordSize, strings.NewReader(""),
+		&out)
+	require.NoError(t, err)
+	in.MaxSteps = 1000000
+	code, err := in.Run()
+	return out.String(), code, err
+}
+
+func TestIrInterpreter_Modes(t *testing.T) {
+	src := `
+let function fact(n: int): int =
+        if n = 0 then 1 else n * fact(n - 1)
+    type list = {head: int, tail: list}
+    var l := list{head = 1, tail = list{head = 2, tail = nil}}
+    var t := l.tail
+    type ints = array of int
+    var a := ints [3] of 7
+    var i := 0
+in (
+    printi(fact(10));
+    print(" ");
+    printi(t.head + a[2]);
+    while 1 do (i := i + 1; if i > 4 then break);
+    print(concat(" ", chr(ord("a") + i)));
+    exit(3)
+)
+end
+`
+
+	for _, archName := range []string{"c", "wasm"} {
+		for _, mode := range []ir.IrMode{ir.IrTree, ir.IrLinear, ir.IrCanon} {
+			out, code, err := interpret(t, archName, mode, src)
+			require.NoError(t, err)
+			require.Equal(t, "3628800 9 f", out, archName)
+			require.Equal(t, 3, code, archName)
+		}
+	}
+}
+
+// TestIrInterpreter_MatchesWasm uses the interpreter as a reference for the wasm backend and canonicalization.
+func TestIrInterpreter_MatchesWasm(t *testing.T) {
+	for _, name := range []string{"conditions", "functions", "record", "spill", "nested", "for_bounds", "strings"} {
+		src, err := os.ReadFile("./test_files/" + name + ".tig")
+		require.NoError(t, err)
+
+		expected := runWasm(t, string(src))
+		for _, mode := range []ir.IrMode{ir.IrTree, ir.IrCanon} {
+			out, _, err := interpret(t, "wasm", mode, string(src))
+			require.NoError(t, err)
+			require.Equal(t, expected, out, name)
+		}
+	}
+}
+
+func TestIrInterpreter_Errors(t *testing.T) {
+	_, _, err := interpret(t, "wasm", ir.IrTree, `
+let type list = {head: int, tail: list}
+    var l: list := nil
+in printi(l.head) end
+`)
+	require.Error(t, err)
+
+	_, _, err = interpret(t, "c", ir.IrCanon, `let var x := 0 in printi(1 / x) end`)
+	require.Error(t, err)
+
+	_, _, err = interpret(t, "wasm", ir.IrCanon, `let function f(): int = f() in printi(f()) end`)
+	require.Error(t, err)
+
+	c, err := compiler.NewCompilation(compiler.Options{File: "test.tig", Arch: "mips"})
+	require.NoError(t, err)
+	frags, err := c.Translate([]byte(`printi(1)`))
+	require.NoError(t, err)
+	_, err = ir.NewIrInterpreter(c.TempManagement(), c.Dumper(), frags, ir.IrTree, c.Arch().WordSize, nil, nil)
+	require.Error(t, err)
+}
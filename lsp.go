@@ -0,0 +1,777 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"tiger/compiler"
+	"tiger/ir"
+	"tiger/semant"
+	"tiger/syntax"
+)
+
+// The language server of "tiger lsp" speaks the Language Server Protocol over stdin and stdout. The editor sends the
+// whole text of a document whenever it changes. The server parses and checks it like the compiler does, then
+// publishes the diagnostics. It answers hover, definition, references and completion requests from the Index of
+// that analysis.
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspRelatedInformation struct {
+	Location lspLocation `json:"location"`
+	Message  string      `json:"message"`
+}
+
+type lspDiagnostic struct {
+	Range              lspRange                `json:"range"`
+	Severity           int                     `json:"severity"`
+	Code               string                  `json:"code"`
+	Source             string                  `json:"source"`
+	Message            string                  `json:"message"`
+	RelatedInformation []lspRelatedInformation `json:"relatedInformation,omitempty"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// the kinds of completion items of the protocol
+const (
+	lspMethodItem = 2
+	lspFieldItem  = 5
+)
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	Position       lspPosition     `json:"position"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type lspMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// LspServer keeps the documents open in the editor, along with the analysis of their current text.
+type LspServer struct {
+	in   *bufio.Reader
+	out  io.Writer
+	arch *compiler.Arch
+	docs map[string]*lspDocument
+}
+
+type lspDocument struct {
+	path     string
+	src      []byte
+	analysis *lspAnalysis
+}
+
+// lspAnalysis is what the server knows of a document: the diagnostics, the index of its names and the tokens of the
+// files the index refers to.
+type lspAnalysis struct {
+	strs   *syntax.Strings
+	diags  []*syntax.Diagnostic
+	index  *semant.Index
+	tokens map[string][]*syntax.Token
+}
+
+func NewLspServer(in io.Reader, out io.Writer, arch *compiler.Arch) *LspServer {
+	return &LspServer{
+		in:   bufio.NewReader(in),
+		out:  out,
+		arch: arch,
+		docs: make(map[string]*lspDocument),
+	}
+}
+
+// Serve answers the messages of the editor until it exits or closes the input.
+func (srv *LspServer) Serve() error {
+	for {
+		msg, err := srv.read()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, rpcErr := srv.handle(msg)
+		// notifications have no id and get no response
+		if msg.ID == nil {
+			continue
+		}
+
+		response := map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID}
+		if rpcErr != nil {
+			response["error"] = rpcErr
+		} else {
+			response["result"] = result
+		}
+
+		if err := srv.write(response); err != nil {
+			return err
+		}
+	}
+}
+
+// read reads a message, which follows a header that gives its length.
+func (srv *LspServer) read() (*lspMessage, error) {
+	length := -1
+	for {
+		line, err := srv.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		if v := strings.TrimPrefix(line, "Content-Length:"); v != line {
+			length, err = strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("invalid header %q", line)
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("message without Content-Length")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(srv.in, body); err != nil {
+		return nil, err
+	}
+
+	msg := &lspMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (srv *LspServer) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(srv.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (srv *LspServer) notify(method string, params interface{}) error {
+	return srv.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (srv *LspServer) handle(msg *lspMessage) (interface{}, *lspError) {
+	params := lspParams{}
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+	}
+
+	doc := srv.docs[params.TextDocument.URI]
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1,
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"referencesProvider": true,
+				"completionProvider": map[string]interface{}{"triggerCharacters": []string{"."}},
+			},
+			"serverInfo": map[string]string{"name": "tiger"},
+		}, nil
+	case "initialized", "shutdown", "$/cancelRequest":
+		return nil, nil
+	case "textDocument/didOpen":
+		srv.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		if len(params.ContentChanges) > 0 {
+			srv.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+
+		return nil, nil
+	case "textDocument/didClose":
+		delete(srv.docs, params.TextDocument.URI)
+		return nil, nil
+	case "textDocument/hover":
+		if doc == nil {
+			return nil, nil
+		}
+
+		return doc.hover(params.Position), nil
+	case "textDocument/definition":
+		if doc == nil {
+			return nil, nil
+		}
+
+		return doc.definition(params.Position), nil
+	case "textDocument/references":
+		if doc == nil {
+			return nil, nil
+		}
+
+		return doc.references(params.Position, params.Context.IncludeDeclaration), nil
+	case "textDocument/completion":
+		if doc == nil {
+			return nil, nil
+		}
+
+		return srv.complete(doc, params.Position), nil
+	default:
+		if msg.ID == nil {
+			return nil, nil
+		}
+
+		return nil, &lspError{Code: -32601, Message: "method not found " + msg.Method}
+	}
+}
+
+// update analyzes the new text of the document and publishes its diagnostics.
+func (srv *LspServer) update(uri, text string) {
+	path := uriPath(uri)
+	doc := &lspDocument{path: path, src: []byte(text)}
+	doc.analysis = srv.analyze(path, doc.src)
+	srv.docs[uri] = doc
+
+	diags := make([]lspDiagnostic, 0, len(doc.analysis.diags))
+	for _, diag := range doc.analysis.diags {
+		diags = append(diags, doc.analysis.lspDiagnostic(path, diag))
+	}
+
+	if err := srv.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diags,
+	}); err != nil {
+		log.Printf("cannot publish diagnostics %v", err)
+	}
+}
+
+// analyze parses and checks src like the compiler does, and records the names it declares and uses.
+func (srv *LspServer) analyze(path string, src []byte) (a *lspAnalysis) {
+	a = &lspAnalysis{strs: syntax.NewStrings(), index: semant.NewIndex(), tokens: map[string][]*syntax.Token{path: lspTokens(path, src)}}
+	diags := syntax.NewDiagnostics()
+	defer func() {
+		// an error of the compiler must not stop the server
+		if r := recover(); r != nil {
+			log.Printf("cannot analyze %s: %v", path, r)
+		}
+
+		a.diags = diags.List()
+	}()
+
+	tm := ir.NewTempManagement(a.strs)
+	translate := semant.NewTranslate(tm, srv.arch.FrameFactory, srv.arch.WordSize, srv.arch.RuntimeFuncs())
+	modules := semant.NewModules(translate, false)
+	modules.Index = a.index
+	parser := syntax.NewParser(syntax.NewLexer(path, bufio.NewReader(bytes.NewReader(src))), a.strs)
+	parser.Diags = diags
+	if syntax.IsModule(path, src) {
+		name, err := semant.ModuleName(path)
+		if err != nil {
+			diags.Report(err)
+			return a
+		}
+
+		decls, err := parser.ParseModule()
+		if decls == nil {
+			if err != nil && err != error(diags) {
+				diags.Report(err)
+			}
+
+			return a
+		}
+
+		if _, err := modules.Check(translate, name, path, decls); err != nil {
+			diags.Report(err)
+		}
+
+		return a
+	}
+
+	exp, err := parser.Parse()
+	if exp == nil {
+		diags.Report(err)
+		return a
+	}
+
+	semant.NewFindEscape().FindEscape(exp)
+	semant := semant.NewSemant(translate, semant.InitBaseVarEnv(tm), semant.InitBaseTypeEnv(a.strs))
+	semant.Modules, semant.File, semant.Diags, semant.Index = modules, path, diags, a.index
+	if _, err := semant.TransProg(exp); err != nil && err != error(diags) {
+		diags.Report(err)
+	}
+
+	return a
+}
+
+// lspTokens returns the tokens of src, skipping those the lexer rejects.
+func lspTokens(path string, src []byte) []*syntax.Token {
+	var tokens []*syntax.Token
+	lexer := syntax.NewLexer(path, bufio.NewReader(bytes.NewReader(src)))
+	for errors := 0; errors < 100; {
+		tok, err := lexer.Token()
+		if err != nil {
+			errors++
+			continue
+		}
+
+		if tok.IsEof() {
+			break
+		}
+
+		tokens = append(tokens, tok)
+	}
+
+	return tokens
+}
+
+// fileTokens returns the tokens of the file, which is read when it is not the document.
+func (a *lspAnalysis) fileTokens(path string) []*syntax.Token {
+	if tokens, ok := a.tokens[path]; ok {
+		return tokens
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("cannot read %s: %v", path, err)
+	}
+
+	a.tokens[path] = lspTokens(path, src)
+	return a.tokens[path]
+}
+
+// lspRangeOf is the range of the token at pos, a character when there is none.
+func (a *lspAnalysis) lspRangeOf(pos syntax.Pos) lspRange {
+	for _, tok := range a.fileTokens(pos.FileName) {
+		if tok.Pos == pos {
+			return lspSpanRange(tok.Span())
+		}
+	}
+
+	end := pos
+	end.Col++
+	return lspSpanRange(syntax.Span{Start: pos, End: end})
+}
+
+func lspSpanRange(span syntax.Span) lspRange {
+	return lspRange{
+		Start: lspPosition{Line: span.Start.Line - 1, Character: span.Start.Col - 1},
+		End:   lspPosition{Line: span.End.Line - 1, Character: span.End.Col - 1},
+	}
+}
+
+func (a *lspAnalysis) lspLocation(pos syntax.Pos) lspLocation {
+	return lspLocation{URI: pathURI(pos.FileName), Range: a.lspRangeOf(pos)}
+}
+
+// lspDiagnostic converts the diagnostic of the document at path. Diagnostics of other files, like the modules it
+// imports, are shown at the start of the document.
+func (a *lspAnalysis) lspDiagnostic(path string, diag *syntax.Diagnostic) lspDiagnostic {
+	d := lspDiagnostic{
+		Severity: int(diag.Severity) + 1,
+		Code:     diag.Code,
+		Source:   "tiger",
+		Message:  diag.Msg,
+	}
+
+	if diag.Span != nil && diag.Span.Start.FileName == path {
+		// the diagnostics of expressions have their spans, the others point at a token
+		d.Range = a.lspRangeOf(diag.Span.Start)
+		if !diag.Span.IsEmpty() {
+			d.Range = lspSpanRange(*diag.Span)
+		}
+	} else {
+		d.Message = diag.String()
+	}
+
+	for _, note := range diag.Notes {
+		if note.Span != nil {
+			d.RelatedInformation = append(d.RelatedInformation, lspRelatedInformation{
+				Location: a.lspLocation(note.Span.Start),
+				Message:  note.Msg,
+			})
+		}
+	}
+
+	return d
+}
+
+// identAt returns the identifier at the position of the editor.
+func (a *lspAnalysis) identAt(path string, position lspPosition) *syntax.Token {
+	for _, tok := range a.fileTokens(path) {
+		if tok.Tok == "ident" && tok.Pos.Line == position.Line+1 && tok.Pos.Col <= position.Character+1 &&
+			position.Character+1 < tok.End.Col {
+			return tok
+		}
+	}
+
+	return nil
+}
+
+// namePos is the position of the name of the definition, which follows the keyword of the declaration.
+func (a *lspAnalysis) namePos(def *semant.Definition) syntax.Pos {
+	tokens := a.fileTokens(def.Pos.FileName)
+	for i, tok := range tokens {
+		if semant.PosBefore(tok.Pos, def.Pos) {
+			continue
+		}
+
+		for j := i; j < i+3 && j < len(tokens); j++ {
+			if tokens[j].Tok == "ident" && strings.EqualFold(tokens[j].String(), def.Name) {
+				return tokens[j].Pos
+			}
+		}
+
+		break
+	}
+
+	return def.Pos
+}
+
+// definitionOf returns the definition that the identifier tok declares or uses.
+func (a *lspAnalysis) definitionOf(tok *syntax.Token) *semant.Definition {
+	if def, ok := a.index.UseAt(tok.Pos); ok {
+		return def
+	}
+
+	for _, def := range a.index.Defs {
+		if a.namePos(def) == tok.Pos {
+			return def
+		}
+	}
+
+	return a.index.TypeAt(tok.String(), tok.Pos)
+}
+
+func (doc *lspDocument) hover(position lspPosition) interface{} {
+	tok := doc.analysis.identAt(doc.path, position)
+	if tok == nil {
+		return nil
+	}
+
+	def := doc.analysis.definitionOf(tok)
+	if def == nil {
+		return nil
+	}
+
+	// symbols ignore the case, so the name is the one spelled by the declaration
+	spelled, namePos := *def, doc.analysis.namePos(def)
+	for _, t := range doc.analysis.fileTokens(def.Pos.FileName) {
+		if t.Pos == namePos {
+			spelled.Name = t.String()
+		}
+	}
+
+	return map[string]interface{}{
+		"contents": map[string]string{"kind": "markdown", "value": "```tiger\n" + spelled.Detail() + "\n```"},
+		"range":    doc.analysis.lspRangeOf(tok.Pos),
+	}
+}
+
+func (doc *lspDocument) definition(position lspPosition) interface{} {
+	tok := doc.analysis.identAt(doc.path, position)
+	if tok == nil {
+		return nil
+	}
+
+	def := doc.analysis.definitionOf(tok)
+	if def == nil {
+		return nil
+	}
+
+	return doc.analysis.lspLocation(doc.analysis.namePos(def))
+}
+
+func (doc *lspDocument) references(position lspPosition, declaration bool) []lspLocation {
+	a := doc.analysis
+	locations := make([]lspLocation, 0)
+	tok := a.identAt(doc.path, position)
+	if tok == nil {
+		return locations
+	}
+
+	def := a.definitionOf(tok)
+	if def == nil {
+		return locations
+	}
+
+	namePos := a.namePos(def)
+	if declaration {
+		locations = append(locations, a.lspLocation(namePos))
+	}
+
+	uses := a.index.Uses(def)
+	if def.Kind == semant.DefType {
+		// the uses of types are not recorded, they are the identifiers that name the type where they are
+		for _, t := range a.fileTokens(doc.path) {
+			if t.Tok == "ident" && t.Pos != namePos && a.definitionOf(t) == def {
+				uses = append(uses, t.Pos)
+			}
+		}
+	}
+
+	for _, pos := range uses {
+		locations = append(locations, a.lspLocation(pos))
+	}
+
+	return locations
+}
+
+// complete returns the fields of the record, or the attributes and methods of the object, before the dot that
+// precedes the position. The document does not parse with the dot, so its type comes from the analysis of the text
+// without it.
+func (srv *LspServer) complete(doc *lspDocument, position lspPosition) []lspCompletionItem {
+	items := make([]lspCompletionItem, 0)
+	src := doc.src
+	offset := lspOffset(src, position)
+	start := offset
+	for start > 0 && syntax.IsIdentChar(src[start-1]) {
+		start--
+	}
+
+	if start == 0 || src[start-1] != '.' {
+		return items
+	}
+
+	// the variable before the dot is an identifier followed by fields and subscripts
+	var steps []string
+	end := start - 1
+	root := -1
+	for root < 0 {
+		if end > 0 && src[end-1] == ']' {
+			depth := 0
+			i := end - 1
+			for ; i >= 0; i-- {
+				if src[i] == ']' {
+					depth++
+				} else if src[i] == '[' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+
+			if i < 0 {
+				return items
+			}
+
+			steps = append(steps, "[]")
+			end = i
+			continue
+		}
+
+		i := end
+		for i > 0 && syntax.IsIdentChar(src[i-1]) {
+			i--
+		}
+
+		if i == end {
+			return items
+		}
+
+		if i > 0 && src[i-1] == '.' {
+			steps = append(steps, string(src[i:end]))
+			end = i - 1
+			continue
+		}
+
+		root = i
+	}
+
+	text := append([]byte{}, src...)
+	for i := start - 1; i < offset; i++ {
+		text[i] = ' '
+	}
+
+	a := srv.analyze(doc.path, text)
+	def, ok := a.index.UseAt(offsetPos(doc.path, src, root))
+	if !ok {
+		return items
+	}
+
+	entry, ok := def.Entry.(*semant.VarEntry)
+	if !ok {
+		return items
+	}
+
+	ty := entry.Ty
+	for i := len(steps) - 1; i >= 0 && ty != nil; i-- {
+		ty = a.memberType(actualType(ty), steps[i], def.Pos)
+	}
+
+	switch v := actualType(ty).(type) {
+	case *semant.RecordSemantTy:
+		for i, field := range v.Symbols {
+			items = append(items, lspCompletionItem{Label: a.strs.Get(field), Kind: lspFieldItem,
+				Detail: a.strs.Get(v.Types[i])})
+		}
+	case *semant.ClassSemantTy:
+		for _, attr := range v.Attrs {
+			detail := ""
+			if attr.Ty != nil {
+				detail = attr.Ty.TypeName()
+			}
+
+			items = append(items, lspCompletionItem{Label: a.strs.Get(attr.Name), Kind: lspFieldItem, Detail: detail})
+		}
+
+		for _, method := range v.Methods {
+			def := &semant.Definition{Name: a.strs.Get(method.Name), Entry: method.Fun}
+			items = append(items, lspCompletionItem{Label: def.Name, Kind: lspMethodItem,
+				Detail: strings.Replace(def.Detail(), "function", "method", 1)})
+		}
+	}
+
+	return items
+}
+
+// memberType returns the type of the field, or of an element when step is "[]", of a value of type ty.
+func (a *lspAnalysis) memberType(ty semant.SemantTy, step string, pos syntax.Pos) semant.SemantTy {
+	switch v := ty.(type) {
+	case *semant.RecordSemantTy:
+		for i, field := range v.Symbols {
+			if strings.EqualFold(a.strs.Get(field), step) {
+				return a.namedType(a.strs.Get(v.Types[i]), pos)
+			}
+		}
+	case *semant.ClassSemantTy:
+		if attr := v.Attr(a.strs.Symbol(step)); attr != nil {
+			return attr.Ty
+		}
+	case *semant.ArrSemantTy:
+		if step == "[]" {
+			return v.BaseTy
+		}
+	}
+
+	return nil
+}
+
+// namedType returns the type named name, as seen from pos.
+func (a *lspAnalysis) namedType(name string, pos syntax.Pos) semant.SemantTy {
+	switch name {
+	case "int":
+		return &semant.IntSemantTy{}
+	case "string":
+		return &semant.StringSemantTy{}
+	}
+
+	if def := a.index.TypeAt(name, pos); def != nil {
+		return def.Entry.(semant.SemantTy)
+	}
+
+	return nil
+}
+
+// actualType follows the aliases of ty.
+func actualType(ty semant.SemantTy) semant.SemantTy {
+	if v, ok := ty.(*semant.NameSemantTy); ok && v.BaseTy != nil {
+		return actualType(v.BaseTy)
+	}
+
+	return ty
+}
+
+// lspOffset is the offset in src of the position of the editor. Tiger sources are ASCII, so characters are bytes.
+func lspOffset(src []byte, position lspPosition) int {
+	offset := 0
+	for line := 0; line < position.Line && offset < len(src); offset++ {
+		if src[offset] == '\n' {
+			line++
+		}
+	}
+
+	offset += position.Character
+	if offset > len(src) {
+		return len(src)
+	}
+
+	return offset
+}
+
+// offsetPos is the position of the lexer for the offset in src.
+func offsetPos(path string, src []byte, offset int) syntax.Pos {
+	pos := syntax.Pos{FileName: path, Line: 1, Col: 1, Offset: offset}
+	for _, c := range src[:offset] {
+		if c == '\n' {
+			pos.Line++
+			pos.Col = 1
+		} else {
+			pos.Col++
+		}
+	}
+
+	return pos
+}
+
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+
+	return filepath.FromSlash(u.Path)
+}
+
+func pathURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
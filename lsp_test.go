@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+)
+
+const lspSource = `let
+  type point = {x: int, y: int}
+  type line = {a: point, b: point}
+  var p := point{x = 1, y = 2}
+  var l := line{a = p, b = p}
+  function f(n: int): int = n + p.x
+in
+  f(p.y);
+  l.a.
+end
+`
+
+// lspExchange sends the messages to a server and returns what it sends back: the results of the requests by id and
+// the params of the notifications by method.
+func lspExchange(t *testing.T, messages ...string) map[string]string {
+	in := bytes.Buffer{}
+	for _, msg := range messages {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+
+	arch, err := compiler.NewArch("wasm")
+	require.NoError(t, err)
+
+	out := bytes.Buffer{}
+	require.NoError(t, NewLspServer(&in, &out, arch).Serve())
+
+	replies := make(map[string]string)
+	reader := bufio.NewReader(&out)
+	for {
+		header, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return replies
+		}
+
+		require.NoError(t, err)
+		length, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "Content-Length:")))
+		require.NoError(t, err)
+		_, err = reader.ReadString('\n')
+		require.NoError(t, err)
+
+		body := make([]byte, length)
+		_, err = io.ReadFull(reader, body)
+		require.NoError(t, err)
+
+		var reply struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(body, &reply))
+		if reply.Method != "" {
+			replies[reply.Method] = string(reply.Params)
+		} else {
+			replies[string(reply.ID)] = string(reply.Result)
+		}
+	}
+}
+
+func lspRequest(id int, method string, line, character int, extra string) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"%s","params":{"textDocument":{"uri":"file:///doc.tig"},`+
+		`"position":{"line":%d,"character":%d}%s}}`, id, method, line, character, extra)
+}
+
+func TestLsp_Requests(t *testing.T) {
+	src, err := json.Marshal(lspSource)
+	require.NoError(t, err)
+
+	replies := lspExchange(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///doc.tig","text":`+
+			string(src)+`}}}`,
+		lspRequest(2, "textDocument/hover", 7, 2, ""),
+		lspRequest(3, "textDocument/definition", 7, 4, ""),
+		lspRequest(4, "textDocument/references", 3, 6, `,"context":{"includeDeclaration":true}`),
+		lspRequest(5, "textDocument/completion", 8, 6, ""),
+		lspRequest(6, "textDocument/hover", 2, 20, ""),
+		`{"jsonrpc":"2.0","id":7,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+
+	require.Contains(t, replies["1"], `"hoverProvider":true`)
+
+	// "l.a." does not parse
+	require.Contains(t, replies["textDocument/publishDiagnostics"], `"code":"E0010"`)
+	require.Contains(t, replies["2"], "function f(int): int")
+	require.Equal(t, `{"uri":"file:///doc.tig","range":{"start":{"line":3,"character":6},"end":{"line":3,"character":7}}}`,
+		replies["3"])
+
+	var refs []lspLocation
+	require.NoError(t, json.Unmarshal([]byte(replies["4"]), &refs))
+	lines := make([]int, 0, len(refs))
+	for _, ref := range refs {
+		lines = append(lines, ref.Range.Start.Line)
+	}
+
+	require.Equal(t, []int{3, 4, 4, 5, 7}, lines)
+	require.Equal(t, `[{"label":"x","kind":5,"detail":"int"},{"label":"y","kind":5,"detail":"int"}]`, replies["5"])
+	require.Contains(t, replies["6"], "type point = record")
+	require.Equal(t, "null", replies["7"])
+}
@@ -1,131 +1,374 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"flag"
-	"io/ioutil"
 	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
-)
 
-var fileName = flag.String("source", "./test_files/hello3.tig", "source file to compile")
+	"tiger/backend/wasm"
+	"tiger/compiler"
+	"tiger/ir"
+	"tiger/sim"
+	"tiger/syntax"
+)
 
 var (
-	strs = NewStrings()
-	tm   = NewTempManagement()
+	fileName = flag.String("source", "./test_files/hello3.tig", "source file to compile")
+	archName = flag.String("arch", "mips", "target architecture: mips, riscv, amd64, c or wasm")
+	maxSteps = flag.Int("max-steps", 0, "stop the simulator after this many instructions, 0 means no limit")
+	interp   = flag.String("interp", "", "run the IR instead of the target: tree, linear or canon")
+	unit     = flag.Bool("unit", false, "compile a program without the runtime and the modules it imports, for link")
+	output   = flag.String("o", "", "output file of link")
+	color    = flag.String("color", "auto", "color the diagnostics: auto, always or never")
+	diagsFmt = flag.String("diagnostics-format", "text", "format of the diagnostics on stderr: text, json or sarif")
+	write    = flag.Bool("w", false, "write the result of fmt to the source file instead of stdout")
+	dump     = flag.String("dump", "", "phases to print: tokens, ast, ast-json, ir, canon, traces, assem, flow, igraph or alloc")
+	dumpDir  = flag.String("dump-dir", "", "write the phases of -dump to files in this directory instead of stdout")
+	jobs     = flag.Int("j", runtime.NumCPU(), "number of functions whose registers are allocated in parallel, their instructions are selected one after the other")
 )
 
-func addTab(instrs []Instr) {
-	for _, instr := range instrs {
-		switch v := instr.(type) {
-		case *OperInstr:
-			v.assem = "\t" + v.assem
-		case *MoveInstr:
-			v.assem = "\t" + v.assem
-		}
+// exitOnDiagnostics reports err to diags, unless it is diags itself, and exits after rendering the diagnostics if
+// there is an error among them.
+func exitOnDiagnostics(renderer *syntax.Renderer, diags *syntax.Diagnostics, err error) {
+	if err != nil && err != error(diags) {
+		diags.Report(err)
 	}
+
+	if !diags.HasErrors() {
+		return
+	}
+
+	writeDiagnostics(renderer, diags)
+	os.Exit(1)
 }
 
-func emitProc(sb *strings.Builder, procs []*ProcFrag) {
-	for _, proc := range procs {
-		canon := &Canon{}
-		stms, _ := canon.Linearize(proc.body)
-		blocks, doneLabel := canon.BasicBlocks(stms)
-		stms = canon.TraceSchedule(blocks, doneLabel)
-
-		instrs := make([]Instr, 0)
-		for _, stm := range stms {
-			codeGen := NewCodeGenerator()
-			instrs = append(instrs, codeGen.GenCode(stm)...)
+// writeDiagnostics writes the diagnostics to stderr in the format of -diagnostics-format. The structured formats are
+// written even without diagnostics, so that the tools that read them know that the source is correct.
+func writeDiagnostics(renderer *syntax.Renderer, diags *syntax.Diagnostics) {
+	var err error
+	switch *diagsFmt {
+	case "json":
+		err = syntax.WriteJsonDiagnostics(os.Stderr, diags)
+	case "sarif":
+		err = syntax.WriteSarif(os.Stderr, diags)
+	default:
+		for _, diag := range diags.List() {
+			renderer.Render(diag)
 		}
+	}
 
-		instrs = ProcEntryExit2(instrs)
+	if err != nil {
+		log.Fatalf("cannot write the diagnostics %v", err)
+	}
+}
 
-		var (
-			colored map[Temp]string
-		)
+// useColor tells whether the diagnostics are colored, which they are by default when stderr is a terminal.
+func useColor() bool {
+	switch *color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
 
-		instrs, colored = Alloc(proc.frame, instrs)
-		addTab(instrs)
-		prolog, epilog := proc.frame.ProcEntryExit3()
-		sb.WriteString(prolog)
-		for _, instr := range instrs {
-			sb.WriteString(formatAssem(instr, func(temp Temp) string {
-				return colored[temp]
-			}) + "\n")
-		}
-		sb.WriteString(epilog)
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb" &&
+		os.Getenv("NO_COLOR") == ""
+}
+
+// format prints the source formatted, or writes it back to the file with -w. The source is only parsed, so that a
+// program with type errors can be formatted too.
+func format(f []byte) {
+	src, err := syntax.Format(*fileName, f)
+	if err != nil {
+		renderer := syntax.NewRenderer(os.Stderr, useColor())
+		renderer.AddSource(*fileName, f)
+		exitOnDiagnostics(renderer, syntax.NewDiagnostics(), err)
+	}
+
+	if !*write {
+		os.Stdout.WriteString(src)
+		return
+	}
+
+	if src == string(f) {
+		return
+	}
+
+	if err := os.WriteFile(*fileName, []byte(src), 0644); err != nil {
+		log.Fatalf("cannot write file %v", err)
 	}
 }
 
-func emitString(sb *strings.Builder, strs []*StrFrag) {
-	for _, str := range strs {
-		StringFrag(sb, str)
+// compile compiles the source file and exits after rendering the diagnostics when it has errors. The warnings are
+// rendered too.
+func compile(c *compiler.Compilation, f []byte) []ir.Frag {
+	renderer := syntax.NewRenderer(os.Stderr, useColor())
+	renderer.AddSource(*fileName, f)
+	frags, err := c.Translate(f)
+	exitOnDiagnostics(renderer, c.Diagnostics(), err)
+	writeDiagnostics(renderer, c.Diagnostics())
+	return frags
+}
+
+// emit compiles the source file into the output of the target.
+func emit(c *compiler.Compilation, f []byte) []byte {
+	out, err := c.Emit(f, compile(c, f))
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
+
+	return out
 }
 
-func emit(frags []Frag) string {
-	var (
-		procs []*ProcFrag
-		strs  []*StrFrag
-	)
+// link writes the assembly of the units that make up a program, a program compiled with -unit and the modules it
+// imports, to a single output along with the runtime. The units of C are linked by the C compiler instead.
+func link(arch *compiler.Arch, units []string) {
+	if arch.Name == "c" || arch.Name == "wasm" {
+		log.Fatalf("%v", compiler.UnsupportedLinkErr(arch.Name))
+	}
 
-	for _, frag := range frags {
-		if f, ok := frag.(*ProcFrag); ok {
-			procs = append(procs, f)
-		} else {
-			strs = append(strs, frag.(*StrFrag))
-		}
+	if *output == "" {
+		log.Fatalf("link needs an output file, given with -o")
 	}
 
 	sb := strings.Builder{}
-	sb.WriteString("\t.globl main\n")
-	sb.WriteString("\t.data\n")
-	emitString(&sb, strs)
-	sb.WriteString("\n\t.text\n")
-	emitProc(&sb, procs)
-	return sb.String()
+	sb.WriteString(arch.Runtime)
+	for _, file := range units {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatalf("cannot open file %v", err)
+		}
+
+		sb.WriteString("\n")
+		sb.Write(b)
+	}
+
+	if err := os.WriteFile(*output, []byte(sb.String()), 0644); err != nil {
+		log.Fatalf("cannot create file %v", err)
+	}
 }
 
-func compile(f []byte) {
-	buf := bufio.NewReader(bytes.NewReader(f))
-	lexer := NewLexer(*fileName, buf)
-	parser := NewParser(lexer, strs)
-	exp, err := parser.Parse()
+// run compiles the source file, or loads it directly when it is already the output of the target, and executes it on
+// the simulator. Native targets are built with the system tools and executed directly instead.
+func run(c *compiler.Compilation, f []byte) int {
+	if *interp != "" {
+		return runInterpreter(c, f)
+	}
+
+	arch := c.Arch()
+	asm := string(f)
+	if !strings.HasSuffix(*fileName, arch.Ext) {
+		asm = string(emit(c, f))
+	}
+
+	switch arch.Name {
+	case "mips":
+		prog, err := sim.AssembleMips(asm)
+		if err != nil {
+			log.Fatalf("assembly error %v", err)
+		}
+
+		sim := sim.NewMips(prog, os.Stdin, os.Stdout)
+		sim.MaxSteps = *maxSteps
+		return runSimulator(sim.Run)
+
+	case "riscv":
+		prog, err := sim.AssembleRiscv(asm)
+		if err != nil {
+			log.Fatalf("assembly error %v", err)
+		}
+
+		sim := sim.NewRiscv(prog, os.Stdin, os.Stdout)
+		sim.MaxSteps = *maxSteps
+		return runSimulator(sim.Run)
+
+	case "wasm":
+		mod, err := wasm.Decode([]byte(asm))
+		if err != nil {
+			log.Fatalf("validation error %v", err)
+		}
+
+		vm, err := wasm.NewVM(mod, os.Stdin, os.Stdout)
+		if err != nil {
+			log.Fatalf("instantiation error %v", err)
+		}
+
+		vm.MaxSteps = *maxSteps
+		return runSimulator(vm.Run)
+	}
+
+	return runNative(arch, asm)
+}
+
+// runInterpreter runs the IR of the source file, as translated for the frames of arch.
+func runInterpreter(c *compiler.Compilation, f []byte) int {
+	mode, err := ir.ParseIrMode(*interp)
 	if err != nil {
-		log.Fatalf("parsing error %v", err)
+		log.Fatalf("%v", err)
 	}
 
-	findEscape := NewFindEscape()
-	findEscape.FindEscape(exp)
-	translate := Translate{frameFactory: NewMipsFrame}
-	venv, tenv := InitBaseVarEnv(), InitBaseTypeEnv()
-	semant := NewSemant(&translate, venv, tenv)
-	frags, err := semant.TransProg(exp)
+	in, err := ir.NewIrInterpreter(c.TempManagement(), c.Dumper(), compile(c, f), mode, c.Arch().WordSize, os.Stdin,
+		os.Stdout)
 	if err != nil {
-		log.Fatalf("semantic error %v", err)
+		log.Fatalf("%v", err)
 	}
 
-	fo, err := os.Create(*fileName + ".s")
+	in.MaxSteps = *maxSteps
+	return runSimulator(in.Run)
+}
+
+func runSimulator(run func() (int, error)) int {
+	code, err := run()
 	if err != nil {
-		log.Fatalf("cannot create file %v", err)
+		log.Fatalf("runtime error %v", err)
 	}
 
-	rb, err := ioutil.ReadFile("./runtime/runtime.s")
+	return code
+}
+
+// runNative builds the output of a native target with the system tools: the assembler and the linker, or the C
+// compiler along with the C runtime.
+func runNative(arch *compiler.Arch, out string) int {
+	dir, err := os.MkdirTemp("", "tigerc")
 	if err != nil {
-		log.Fatalf("cannot open file %v", err)
+		log.Fatalf("cannot create directory %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		src    string
+		bin    = filepath.Join(dir, "prog")
+		builds [][]string
+	)
+
+	if arch.Name == "c" {
+		src = filepath.Join(dir, "prog.c")
+		runtimeFile := filepath.Join(dir, "runtime.c")
+		if err := os.WriteFile(runtimeFile, []byte(arch.Runtime), 0644); err != nil {
+			log.Fatalf("cannot create file %v", err)
+		}
+
+		builds = [][]string{{"cc", "-o", bin, src, runtimeFile}}
+	} else {
+		src = filepath.Join(dir, "prog.s")
+		obj := filepath.Join(dir, "prog.o")
+		builds = [][]string{{"as", "-o", obj, src}, {"ld", "-o", bin, obj}}
 	}
 
-	fo.WriteString(string(rb) + "\n" + emit(frags))
+	if err := os.WriteFile(src, []byte(out), 0644); err != nil {
+		log.Fatalf("cannot create file %v", err)
+	}
+
+	for _, args := range builds {
+		out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+		if err != nil {
+			log.Fatalf("%s error %v\n%s", args[0], err, out)
+		}
+	}
+
+	cmd := exec.Command(bin)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+
+		log.Fatalf("runtime error %v", err)
+	}
+
+	return 0
 }
 
 func main() {
+	var command string
+	if len(os.Args) > 1 && (os.Args[1] == "run" || os.Args[1] == "link" || os.Args[1] == "lsp" ||
+		os.Args[1] == "fmt" || os.Args[1] == "repl" || os.Args[1] == "debug") {
+		command = os.Args[1]
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+
+	// the program to debug follows the command
+	if command == "debug" && flag.NArg() > 0 {
+		*fileName = flag.Arg(0)
+	}
+
+	c, err := compiler.NewCompilation(compiler.Options{File: *fileName, Arch: *archName, Unit: *unit, Dump: *dump, DumpDir: *dumpDir,
+		Jobs: *jobs, Debug: command == "debug"})
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if *diagsFmt != "text" && *diagsFmt != "json" && *diagsFmt != "sarif" {
+		log.Fatalf("%v", unknownDiagnosticsFormatErr(*diagsFmt))
+	}
+
+	arch := c.Arch()
+
+	if command == "link" {
+		link(arch, flag.Args())
+		return
+	}
+
+	// the language server checks the primitives against the runtime of -arch
+	if command == "lsp" {
+		if err := NewLspServer(os.Stdin, os.Stdout, arch).Serve(); err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		return
+	}
+
+	// the REPL runs its inputs on the IR interpreter, whatever -arch is
+	if command == "repl" {
+		repl, err := NewRepl(os.Stdin, os.Stdout, *maxSteps)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		if err := repl.Run(); err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		return
+	}
+
 	f, err := os.ReadFile(*fileName)
 	if err != nil {
-		log.Fatalf("error when reading input file %v", err)
+		exitOnDiagnostics(syntax.NewRenderer(os.Stderr, useColor()), syntax.NewDiagnostics(), err)
+	}
+
+	if command == "run" {
+		os.Exit(run(c, f))
 	}
 
-	compile(f)
+	if command == "fmt" {
+		format(f)
+		return
+	}
+
+	if command == "debug" {
+		debugger, err := NewDebugger(*fileName, f, emit(c, f), os.Stdin, os.Stdout, *maxSteps)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		if err := debugger.Run(); err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		return
+	}
+
+	// the output is written once the program compiles, a failed compilation keeps the previous one
+	out := emit(c, f)
+	if err := os.WriteFile(*fileName+arch.Ext, out, 0644); err != nil {
+		log.Fatalf("cannot create file %v", err)
+	}
 }
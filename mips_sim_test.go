@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/runtime"
+	"tiger/sim"
+)
+
+func runMipsAsm(t *testing.T, asm string, stdin string) (string, int) {
+	prog, err := sim.AssembleMips(runtime.Mips + "\n" + asm)
+	require.NoError(t, err)
+
+	out := bytes.Buffer{}
+	sim := sim.NewMips(prog, strings.NewReader(stdin), &out)
+	sim.MaxSteps = 100000
+	code, err := sim.Run()
+	require.NoError(t, err)
+	return out.String(), code
+}
+
+func TestMipsSimulator_Arithmetic(t *testing.T) {
+	t.Parallel()
+
+	out, code := runMipsAsm(t, `
+	.text
+main:
+	sw	$fp	0($sp)
+	move	$fp	$sp
+	addiu	$sp	$sp	-8
+	sw $ra, -4($fp)
+	li $t0, 7
+	li $t1, -3
+	mul $t2, $t0, $t1
+	div $t3, $t2, $t1
+	sub $a0, $t3, $t1
+	jal printi
+	lw $ra, -4($fp)
+	move	$sp	$fp
+	lw	$fp	0($sp)
+	jr	$ra
+`, "")
+
+	require.Equal(t, "10", out)
+	require.Equal(t, 0, code)
+}
+
+func TestMipsSimulator_Strings(t *testing.T) {
+	t.Parallel()
+
+	out, _ := runMipsAsm(t, `
+	.data
+L1:	.asciiz	"Hello, \"Tiger\"\n"
+L2:	.asciiz	"abc"
+
+	.text
+main:
+	move $s0, $ra
+	la $a0, L1
+	la $a1, L2
+	jal concat
+	move $a0, $v0
+	jal print
+	la $a0, L2
+	jal size
+	move $a0, $v0
+	jal printi
+	jal getchar
+	move $a0, $v0
+	jal print
+	move $ra, $s0
+	jr $ra
+`, "xyz")
+
+	require.Equal(t, "Hello, \"Tiger\"\nabc3x", out)
+}
+
+func TestMipsSimulator_Exit(t *testing.T) {
+	t.Parallel()
+
+	out, code := runMipsAsm(t, `
+	.text
+main:
+	li $a0, 3
+	li $v0, 17
+	syscall
+	li $a0, 4
+	jal printi
+`, "")
+
+	require.Equal(t, "", out)
+	require.Equal(t, 3, code)
+}
+
+func TestMipsSimulator_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := sim.AssembleMips("\t.text\nmain:\n\tfoo $t0, $t1\n")
+	require.Error(t, err)
+
+	_, err = sim.AssembleMips("\t.text\nmain:\n\tb missing\n")
+	require.Error(t, err)
+
+	prog, err := sim.AssembleMips("\t.text\nmain:\n\tli $t0, 0\n\tdiv $t1, $t1, $t0\n")
+	require.NoError(t, err)
+	_, err = sim.NewMips(prog, strings.NewReader(""), &bytes.Buffer{}).Run()
+	require.Error(t, err)
+
+	prog, err = sim.AssembleMips("\t.text\nmain:\n\tb main\n")
+	require.NoError(t, err)
+	sim := sim.NewMips(prog, strings.NewReader(""), &bytes.Buffer{})
+	sim.MaxSteps = 10
+	_, err = sim.Run()
+	require.Error(t, err)
+}
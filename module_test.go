@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+	"tiger/ir"
+)
+
+const importOutput = "Hello, World!\n6\n"
+
+func TestModule_Import(t *testing.T) {
+	t.Parallel()
+
+	file := "./test_files/import.tig"
+	src, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	require.Equal(t, importOutput, runWasmFile(t, file, string(src)))
+	require.Equal(t, importOutput, runRiscvFile(t, file, string(src)))
+	require.Equal(t, importOutput, runCFile(t, file, src))
+
+	out, _, err := interpretFile(t, "c", file, ir.IrCanon, string(src))
+	require.NoError(t, err)
+	require.Equal(t, importOutput, out)
+}
+
+func TestModule_Link(t *testing.T) {
+	t.Parallel()
+
+	compileUnit := func(file string) string {
+		src, err := os.ReadFile(file)
+		require.NoError(t, err)
+		return string(compileTest(t, compiler.Options{File: file, Arch: "riscv", Unit: true}, src).Output)
+	}
+
+	lib := compileUnit("./test_files/lib.tig")
+	require.Contains(t, lib, "_3lib_greet:")
+	require.NotContains(t, lib, "main")
+
+	prog := compileUnit("./test_files/import.tig")
+	require.NotContains(t, prog, "_3lib_greet:")
+
+	out, _ := runRiscvAsm(t, prog+"\n"+lib, "")
+	require.Equal(t, importOutput, out)
+}
+
+func TestModule_Errors(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"m.tig":      `function f(x: int): int = x type t = {a: int}`,
+		"a.tig":      `import "b.tig" function f() = ()`,
+		"b.tig":      `import "a.tig"`,
+		"vars.tig":   `var x := 1`,
+		"class.tig":  `class C {}`,
+		"my-lib.tig": `function f() = ()`,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+
+	imports := func(module, body string) string {
+		return fmt.Sprintf(`let import "%s" in %s end`, filepath.Join(dir, module), body)
+	}
+
+	for _, src := range []string{
+		imports("a.tig", "f()"),
+		imports("vars.tig", "()"),
+		imports("class.tig", "()"),
+		imports("my-lib.tig", "()"),
+		imports("missing.tig", "()"),
+		imports("m.tig", `f("x")`),
+		imports("m.tig", "t{a = nil}"),
+	} {
+		require.Error(t, checkProgram(t, src), src)
+	}
+
+	require.NoError(t, checkProgram(t, imports("m.tig", "(f(1); t{a = 2}; ())")))
+}
+
+// TestModule_FuncLabels declares functions of the same name in different scopes, and functions named like the runtime
+// and the entry point, which all get labels of their own.
+func TestModule_FuncLabels(t *testing.T) {
+	t.Parallel()
+
+	src := `
+let function a(): int = let function h(): int = 1 in h() end
+    function b(): int = let function h(): int = 2 in h() end
+    function main(): int = 7
+in printi(a()); printi(b());
+   let function printi(n: int) = print("x")
+   in printi(main())
+   end
+end
+`
+
+	require.Equal(t, "12x", runWasm(t, src))
+	require.Equal(t, "12x", runRiscv(t, src))
+	require.Equal(t, "12x", runMips(t, []byte(src)))
+	require.Equal(t, "12x", runC(t, []byte(src)))
+
+	out, _, err := interpret(t, "wasm", ir.IrTree, src)
+	require.NoError(t, err)
+	require.Equal(t, "12x", out)
+}
+
+// TestModule_SameName imports two modules of the same name from different directories, whose labels would clash.
+func TestModule_SameName(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	for name, src := range map[string]string{
+		"a.tig":     `function fa(): int = 1`,
+		"sub/a.tig": `function fa(): int = 2`,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+
+	src := fmt.Sprintf(`let import "%s" import "%s" in printi(fa()) end`, filepath.Join(dir, "a.tig"),
+		filepath.Join(dir, "sub", "a.tig"))
+	require.Equal(t, []string{"E0304"}, diagnosticCodes(t, checkProgram(t, src)))
+
+	// nor can a module import a module of its own name
+	c, err := compiler.NewCompilation(compiler.Options{File: filepath.Join(dir, "a.tig"), Arch: "wasm"})
+	require.NoError(t, err)
+	_, err = c.Translate([]byte(`import "sub/a.tig" function fb(): int = fa()`))
+	require.Equal(t, "E0304", diagnosticCodes(t, err)[0])
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+)
+
+func TestPrimitive_Programs(t *testing.T) {
+	src, err := os.ReadFile("./test_files/primitive.tig")
+	require.NoError(t, err)
+
+	require.Equal(t, "10\n", runWasm(t, string(src)))
+	require.Equal(t, "10\n", runRiscv(t, string(src)))
+	require.Equal(t, "10\n", runC(t, src))
+}
+
+func TestPrimitive_RuntimeFuncs(t *testing.T) {
+	for _, name := range []string{"mips", "riscv", "amd64", "c", "wasm"} {
+		arch, err := compiler.NewArch(name)
+		require.NoError(t, err)
+
+		funcs := arch.RuntimeFuncs()
+		for _, f := range []string{"print", "printi", "concat", "substring", "exit"} {
+			require.True(t, funcs[f], "%s %s", name, f)
+		}
+
+		// main is the program's
+		require.False(t, funcs["main"], name)
+	}
+}
+
+func TestPrimitive_Errors(t *testing.T) {
+	for _, src := range []string{
+		`let primitive nope() in nope() end`,
+		`let primitive size(s: str): int in () end`,
+		`let primitive size(s: string): str in () end`,
+		`let primitive size(s: string): int in size(1) end`,
+		`let primitive flush() in flush() + 1 end`,
+	} {
+		require.Error(t, checkProgram(t, src), src)
+	}
+
+	require.NoError(t, checkProgram(t, `let primitive size(s: string): int in size("tiger") + 1 end`))
+}
+
+// TestPrimitive_Module compiles a module that starts with a primitive, which is a declaration like any other.
+func TestPrimitive_Module(t *testing.T) {
+	src := []byte("primitive flush()\nfunction twice(s: string): string = concat(s, s)\n")
+	res := compileTest(t, compiler.Options{File: "plib.tig", Arch: "riscv", Unit: true}, src)
+	require.Contains(t, string(res.Output), "_4plib_twice:")
+}
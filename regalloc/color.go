@@ -1,4 +1,10 @@
-package main
+package regalloc
+
+import (
+	"math"
+
+	"tiger/ir"
+)
 
 type Coloring struct {
 	iGraph IGraph
@@ -6,11 +12,11 @@ type Coloring struct {
 
 	moves *MoveSet
 
-	registers map[Temp]string
+	registers map[ir.Temp]string
 
-	moveList map[Temp]*MoveSet
+	moveList map[ir.Temp]*MoveSet
 
-	colored map[Temp]Temp
+	colored map[ir.Temp]ir.Temp
 
 	precolored *IGraphNodeSet
 
@@ -46,7 +52,7 @@ type Coloring struct {
 	selectStack []*IGraphNode
 
 	coalesceNodes *IGraphNodeSet
-	alias         map[Temp]*IGraphNode
+	alias         map[ir.Temp]*IGraphNode
 
 	spilledNodes *IGraphNodeSet
 	coloredNodes *IGraphNodeSet
@@ -55,15 +61,15 @@ type Coloring struct {
 func NewColoring(iGraph IGraph,
 	fGraph FGraph,
 	moves *MoveSet,
-	registers map[Temp]string,
+	registers map[ir.Temp]string,
 ) *Coloring {
 	coloring := Coloring{
 		iGraph:           iGraph,
 		fGraph:           fGraph,
 		moves:            moves,
 		registers:        registers,
-		moveList:         make(map[Temp]*MoveSet),
-		colored:          make(map[Temp]Temp),
+		moveList:         make(map[ir.Temp]*MoveSet),
+		colored:          make(map[ir.Temp]ir.Temp),
 		precolored:       InitIGraphNodeSet(),
 		initials:         InitIGraphNodeSet(),
 		spillWorklist:    InitIGraphNodeSet(),
@@ -76,7 +82,7 @@ func NewColoring(iGraph IGraph,
 		frozenMoves:      InitMoveSet(),
 		K:                len(registers),
 		coalesceNodes:    InitIGraphNodeSet(),
-		alias:            make(map[Temp]*IGraphNode),
+		alias:            make(map[ir.Temp]*IGraphNode),
 		spilledNodes:     InitIGraphNodeSet(),
 		coloredNodes:     InitIGraphNodeSet(),
 	}
@@ -110,9 +116,19 @@ func (c *Coloring) initMoveList() {
 	}
 }
 
+// initColoredAndPrecolored goes through the temps in order, so that the worklists and thus the registers are the same
+// from one compilation to the other.
 func (c *Coloring) initColoredAndPrecolored() {
-	for tmp, node := range c.iGraph {
+	temps := make(ir.TempSet, len(c.iGraph))
+	for tmp := range c.iGraph {
+		temps.Add(tmp)
+	}
+
+	for _, tmp := range sortedTemps(temps) {
+		node := c.iGraph[tmp]
 		if _, ok := c.registers[tmp]; ok {
+			// precolored nodes can never be simplified or spilled
+			node.degree = math.MaxInt32
 			c.precolored.Add(node)
 			c.colored[tmp] = tmp
 			c.coloredNodes.Add(node)
@@ -205,7 +221,7 @@ func (c *Coloring) coalesce() {
 	if u.temp == v.temp {
 		c.coalescedMoves.Add(mv)
 		c.addWorklist(u)
-	} else if c.precolored.Has(v) || c.adj(u).Has(v) {
+	} else if c.precolored.Has(v) || u.AdjSet().Has(v) || v.AdjSet().Has(u) {
 		// if v is precolored, so in this case, both the dst and src of the move is precolored.
 		c.constrainedMoves.Add(mv)
 		c.addWorklist(u)
@@ -234,8 +250,10 @@ func (c *Coloring) spillCost(iNode *IGraphNode) float64 {
 	return float64(useDefines) / float64(iNode.degree)
 }
 
+// adj returns the neighbours of n that are still in the graph: nodes on the select stack and nodes coalesced into
+// another one are gone. Precolored neighbours always stay.
 func (c *Coloring) adj(n *IGraphNode) *IGraphNodeSet {
-	nodes := c.coloredNodes.Clone()
+	nodes := c.coalesceNodes.Clone()
 	for _, node := range c.selectStack {
 		nodes.Add(node)
 	}
@@ -398,7 +416,7 @@ func (c *Coloring) assignColor() {
 	for len(c.selectStack) > 0 {
 		node := c.selectStack[len(c.selectStack)-1]
 		c.selectStack = c.selectStack[:len(c.selectStack)-1]
-		okColors := NewTempSet()
+		okColors := ir.NewTempSet()
 		for color := range c.registers {
 			okColors.Add(color)
 		}
@@ -415,17 +433,25 @@ func (c *Coloring) assignColor() {
 			continue
 		}
 
+		// the first of the registers left, rather than any of them, keeps the output the same from run to run
 		c.coloredNodes.Add(node)
-		color, _ := okColors.Split()
-		c.colored[node.temp] = color
+		c.colored[node.temp] = sortedTemps(okColors)[0]
 	}
 
+	// the nodes coalesced into a spilled node are spilled as well, otherwise they would be coalesced again with the
+	// new temps of the rewritten program and spilled forever
 	for _, node := range c.coalesceNodes.All() {
-		c.colored[node.temp] = c.colored[c.findAlias(node).temp]
+		alias := c.findAlias(node)
+		if c.spilledNodes.Has(alias) {
+			c.spilledNodes.Add(node)
+			continue
+		}
+
+		c.colored[node.temp] = c.colored[alias.temp]
 	}
 }
 
-func (c *Coloring) Color() (map[Temp]Temp, *IGraphNodeSet) {
+func (c *Coloring) Color() (map[ir.Temp]ir.Temp, *IGraphNodeSet) {
 	c.build()
 	c.makeWorklist()
 	for {
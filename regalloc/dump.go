@@ -0,0 +1,93 @@
+package regalloc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"tiger/ir"
+)
+
+func sortedTemps(temps ir.TempSet) []ir.Temp {
+	list := make([]ir.Temp, 0, len(temps))
+	for temp := range temps {
+		list = append(list, temp)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i] < list[j]
+	})
+
+	return list
+}
+
+func tempNames(temps []ir.Temp) string {
+	names := make([]string, 0, len(temps))
+	for _, temp := range temps {
+		names = append(names, temp.String())
+	}
+
+	return strings.Join(names, " ")
+}
+
+// dumpFlow prints the nodes of the flow graph, numbered in the order of the instructions, with their successors and
+// the temps they use, define, and that are live on entry and on exit.
+func dumpFlow(sb *strings.Builder, tm *ir.TempManagement, fGraph FGraph) {
+	index := make(map[int64]int, len(fGraph))
+	for i, node := range fGraph {
+		index[node.id] = i
+	}
+
+	for i, node := range fGraph {
+		succ := make([]string, 0, len(node.succ))
+		for _, s := range node.succ {
+			succ = append(succ, fmt.Sprint(index[s.Id()]))
+		}
+
+		fmt.Fprintf(sb, "%d: %s\n", i, strings.TrimSpace(ir.FormatAssem(tm, node.instr, ir.Temp.String)))
+		fmt.Fprintf(sb, "\tsucc: %s\n\tuse: %s\n\tdef: %s\n\tin: %s\n\tout: %s\n", strings.Join(succ, " "),
+			tempNames(sortedTemps(node.use)), tempNames(sortedTemps(node.def)),
+			tempNames(sortedTemps(node.liveIn)), tempNames(sortedTemps(node.liveOut)))
+	}
+}
+
+// dumpIGraph prints the temps that interfere with each temp, then the moves that the allocation tries to coalesce.
+func dumpIGraph(sb *strings.Builder, iGraph IGraph, moves *MoveSet) {
+	temps := make(ir.TempSet)
+	for temp := range iGraph {
+		temps.Add(temp)
+	}
+
+	for _, temp := range sortedTemps(temps) {
+		adj := make(ir.TempSet)
+		for _, node := range iGraph[temp].adj.All() {
+			adj.Add(node.temp)
+		}
+
+		fmt.Fprintf(sb, "%s: %s\n", temp, tempNames(sortedTemps(adj)))
+	}
+
+	for _, move := range moves.moves {
+		fmt.Fprintf(sb, "move %s <- %s\n", move.dst.temp, move.src.temp)
+	}
+}
+
+// dumpAlloc prints the register of each temp, or the temps spilled to the frame at a round that spills.
+func dumpAlloc(sb *strings.Builder, frame ir.Frame, colored map[ir.Temp]ir.Temp, spilled *IGraphNodeSet) {
+	if !spilled.Empty() {
+		for _, node := range spilled.All() {
+			fmt.Fprintf(sb, "spill %s\n", node.temp)
+		}
+
+		return
+	}
+
+	temps := make(ir.TempSet)
+	for temp := range colored {
+		temps.Add(temp)
+	}
+
+	for _, temp := range sortedTemps(temps) {
+		fmt.Fprintf(sb, "%s: %s\n", temp, frame.TempName(colored[temp]))
+	}
+}
@@ -1,41 +1,45 @@
-package main
+package regalloc
 
-import "math/rand"
+import (
+	"math/rand"
+
+	"tiger/ir"
+)
 
 type FGraphNode struct {
 	id int64
 
-	instr  Instr
-	use    TempSet
-	def    TempSet
+	instr  ir.Instr
+	use    ir.TempSet
+	def    ir.TempSet
 	isMove bool
 
 	succ, pred, adj          []GraphNode
 	succMap, predMap, adjMap map[int64]int
 
-	liveOut, liveIn TempSet
+	liveOut, liveIn ir.TempSet
 }
 
-func Instr2FGraphNode(instr Instr) *FGraphNode {
+func Instr2FGraphNode(instr ir.Instr) *FGraphNode {
 	var (
-		use, def []Temp
+		use, def []ir.Temp
 		isMove   bool
 	)
 
 	switch v := instr.(type) {
-	case *OperInstr:
-		use, def, isMove = v.src, v.dst, false
-	case *LabelInstr:
+	case *ir.OperInstr:
+		use, def, isMove = v.Src, v.Dst, false
+	case *ir.LabelInstr:
 		use, def, isMove = nil, nil, false
-	case *MoveInstr:
-		use, def, isMove = []Temp{v.src}, []Temp{v.dst}, true
+	case *ir.MoveInstr:
+		use, def, isMove = []ir.Temp{v.Src}, []ir.Temp{v.Dst}, true
 	}
 
 	return &FGraphNode{
 		id:     rand.Int63(),
 		instr:  instr,
-		use:    NewTempSet(use...),
-		def:    NewTempSet(def...),
+		use:    ir.NewTempSet(use...),
+		def:    ir.NewTempSet(def...),
 		isMove: isMove,
 
 		succMap: make(map[int64]int),
@@ -49,7 +53,7 @@ func (node *FGraphNode) Id() int64 {
 }
 
 func (node *FGraphNode) NodeName() string {
-	return node.instr.assemStr()
+	return node.instr.AssemStr()
 }
 
 func (node *FGraphNode) Succ() []GraphNode {
@@ -117,20 +121,20 @@ func (node *FGraphNode) Equal(other GraphNode) bool {
 	return v.id == node.id
 }
 
-func Instrs2FGraph(instrs []Instr) FGraph {
+func Instrs2FGraph(instrs []ir.Instr) FGraph {
 	flowGraph := make(FGraph, 0, len(instrs))
-	label2Node := map[Label]*FGraphNode{}
+	label2Node := map[ir.Label]*FGraphNode{}
 	for _, instr := range instrs {
 		node := Instr2FGraphNode(instr)
 		flowGraph = append(flowGraph, node)
-		if v, ok := instr.(*LabelInstr); ok {
-			label2Node[v.lab] = node
+		if v, ok := instr.(*ir.LabelInstr); ok {
+			label2Node[v.Lab] = node
 		}
 	}
 
 	for i, instr := range instrs {
-		if len(instr.jumpLabels()) > 0 {
-			for _, label := range instr.jumpLabels() {
+		if len(instr.JumpLabels()) > 0 {
+			for _, label := range instr.JumpLabels() {
 				node := label2Node[label]
 				flowGraph.MkEdge(flowGraph[i], node)
 			}
@@ -1,4 +1,4 @@
-package main
+package regalloc
 
 type GraphNode interface {
 	Id() int64
@@ -1,13 +1,17 @@
-package main
+package regalloc
+
+import (
+	"tiger/ir"
+)
 
 type IGraphNodeSet struct {
-	indices map[Temp]int
+	indices map[ir.Temp]int
 	nodes   []*IGraphNode
 }
 
 func InitIGraphNodeSet() *IGraphNodeSet {
 	return &IGraphNodeSet{
-		indices: make(map[Temp]int),
+		indices: make(map[ir.Temp]int),
 	}
 }
 
@@ -56,7 +60,7 @@ func (s *IGraphNodeSet) Split() (*IGraphNode, *IGraphNodeSet) {
 
 func (s *IGraphNodeSet) Reset() {
 	s.nodes = nil
-	s.indices = make(map[Temp]int)
+	s.indices = make(map[ir.Temp]int)
 }
 
 func (s *IGraphNodeSet) Union(s1 *IGraphNodeSet) *IGraphNodeSet {
@@ -108,7 +112,7 @@ func (s *IGraphNodeSet) Remove(node *IGraphNode) {
 }
 
 type IGraphNode struct {
-	temp   Temp
+	temp   ir.Temp
 	adj    *IGraphNodeSet
 	degree int
 }
@@ -118,7 +122,7 @@ func (node *IGraphNode) Id() int64 {
 }
 
 func (node *IGraphNode) NodeName() string {
-	return tm.TempString(node.temp)
+	return node.temp.String()
 }
 
 func (node *IGraphNode) Succ() []GraphNode {
@@ -1,8 +1,12 @@
-package main
+package regalloc
 
-type IGraph map[Temp]*IGraphNode
+import (
+	"tiger/ir"
+)
+
+type IGraph map[ir.Temp]*IGraphNode
 type tempEdge struct {
-	u, v Temp
+	u, v ir.Temp
 }
 
 func computeLiveInOut(fGraph FGraph) {
@@ -12,7 +16,7 @@ func computeLiveInOut(fGraph FGraph) {
 		for _, node := range fGraph {
 			oldLiveIn, oldLiveOut := node.liveIn.Clone(), node.liveOut.Clone()
 			node.liveIn = node.use.Union(node.liveOut.Diff(node.def))
-			node.liveOut = NewTempSet()
+			node.liveOut = ir.NewTempSet()
 			for _, succ := range node.succ {
 				iNode := succ.(*FGraphNode)
 				node.liveOut = node.liveOut.Union(iNode.liveIn)
@@ -27,8 +31,13 @@ func computeLiveInOut(fGraph FGraph) {
 
 func addEdges(node *FGraphNode) []*tempEdge {
 	edges := make([]*tempEdge, 0)
-	for def := range node.def {
-		for liveOut := range node.liveOut {
+	// the temps are in order so that the adjacent nodes, which the coloring goes through, are always in the same order
+	for _, def := range sortedTemps(node.def) {
+		for _, liveOut := range sortedTemps(node.liveOut) {
+			if def == liveOut {
+				continue
+			}
+
 			if node.isMove && !node.use.Has(liveOut) {
 				edges = append(edges, &tempEdge{
 					u: def,
@@ -56,12 +65,12 @@ func allEdges(fGraph FGraph) []*tempEdge {
 }
 
 func convertTempToIGraph(fGraph FGraph) IGraph {
-	allTemps := make(TempSet)
+	allTemps := make(ir.TempSet)
 	for _, node := range fGraph {
 		allTemps = allTemps.Union(node.use.Union(node.def))
 	}
 
-	iNodes := make(map[Temp]*IGraphNode, len(allTemps))
+	iNodes := make(map[ir.Temp]*IGraphNode, len(allTemps))
 	for temp := range allTemps {
 		iNode := &IGraphNode{
 			temp: temp,
@@ -74,14 +83,14 @@ func convertTempToIGraph(fGraph FGraph) IGraph {
 	return iNodes
 }
 
-func allMoves(fGraph FGraph, iNodes map[Temp]*IGraphNode) *MoveSet {
+func allMoves(fGraph FGraph, iNodes map[ir.Temp]*IGraphNode) *MoveSet {
 	pairs := InitMoveSet()
 	for _, node := range fGraph {
 		if !node.isMove {
 			continue
 		}
 
-		var src, dst Temp
+		var src, dst ir.Temp
 		src, node.use = node.use.Split()
 		dst, node.def = node.def.Split()
 		pairs.Add(&Move{
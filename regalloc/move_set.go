@@ -1,17 +1,21 @@
-package main
+package regalloc
+
+import (
+	"tiger/ir"
+)
 
 type Move struct {
 	src, dst *IGraphNode
 }
 
 type MoveSet struct {
-	indices map[Temp]map[Temp]int
+	indices map[ir.Temp]map[ir.Temp]int
 	moves   []*Move
 }
 
 func InitMoveSet() *MoveSet {
 	return &MoveSet{
-		indices: make(map[Temp]map[Temp]int),
+		indices: make(map[ir.Temp]map[ir.Temp]int),
 	}
 }
 
@@ -36,7 +40,7 @@ func (s *MoveSet) Add(mv *Move) {
 	}
 
 	if _, ok := s.indices[mv.src.temp]; !ok {
-		s.indices[mv.src.temp] = make(map[Temp]int)
+		s.indices[mv.src.temp] = make(map[ir.Temp]int)
 	}
 
 	s.indices[mv.src.temp][mv.dst.temp] = len(s.moves)
@@ -0,0 +1,166 @@
+// Package regalloc assigns registers to the temps of the instructions of a function by coloring their interference
+// graph, and spills to the frame the temps that do not fit.
+package regalloc
+
+import (
+	"strings"
+
+	"tiger/ir"
+)
+
+func isRedundantMove(colored map[ir.Temp]ir.Temp, i ir.Instr) bool {
+	i1, ok := i.(*ir.MoveInstr)
+	if !ok {
+		return false
+	}
+
+	dst, src := i1.Dst, i1.Src
+	return colored[dst] == colored[src]
+}
+
+func genInst(tm *ir.TempManagement, frame ir.Frame, isDefine bool, accessExp ir.ExpIr, temp ir.Temp) []ir.Instr {
+	if isDefine {
+		return frame.CodeGen(tm, &ir.MoveStmIr{
+			Dst: accessExp,
+			Src: &ir.TempExpIr{Temp: temp},
+		})
+	}
+
+	return frame.CodeGen(tm, &ir.MoveStmIr{
+		Dst: &ir.TempExpIr{Temp: temp},
+		Src: accessExp,
+	})
+}
+
+func replaceTemp(temp, nt ir.Temp, tempList []ir.Temp) ([]ir.Temp, bool) {
+	found := false
+	newTempList := make([]ir.Temp, 0, len(tempList))
+	for _, t := range tempList {
+		if t == temp {
+			newTempList = append(newTempList, nt)
+			found = true
+		} else {
+			newTempList = append(newTempList, t)
+		}
+	}
+
+	return newTempList, found
+}
+
+// rewriteOne replaces every occurrence of the spilled temp with a new short-lived temp, fetched from the frame at acc
+// before the instruction and stored back after it. Uses and definitions in the same instruction share the new temp
+// because two-address instructions (addq `s0, `d0 on x86) read and write the same register.
+func rewriteOne(tm *ir.TempManagement, frame ir.Frame, temp ir.Temp, acc ir.FrameAccess, instrs []ir.Instr) []ir.Instr {
+	accessExp := acc.Exp(&ir.TempExpIr{Temp: frame.FP()})
+	newInstrs := make([]ir.Instr, 0)
+	for _, instr := range instrs {
+		var (
+			newInstr     ir.Instr
+			isDef, isUse bool
+		)
+
+		nt := tm.NewTemp()
+		switch t := instr.(type) {
+		case *ir.OperInstr:
+			var dst, src []ir.Temp
+			dst, isDef = replaceTemp(temp, nt, t.Dst)
+			src, isUse = replaceTemp(temp, nt, t.Src)
+			newInstr = &ir.OperInstr{
+				Assem: t.Assem,
+				Dst:   dst,
+				Src:   src,
+				Jumps: t.Jumps,
+			}
+
+		case *ir.MoveInstr:
+			var dst, src []ir.Temp
+			dst, isDef = replaceTemp(temp, nt, []ir.Temp{t.Dst})
+			src, isUse = replaceTemp(temp, nt, []ir.Temp{t.Src})
+			newInstr = &ir.MoveInstr{
+				Assem: t.Assem,
+				Dst:   dst[0],
+				Src:   src[0],
+			}
+
+		default:
+			newInstr = t
+		}
+
+		if isUse {
+			newInstrs = append(newInstrs, genInst(tm, frame, false, accessExp, nt)...)
+		}
+
+		newInstrs = append(newInstrs, newInstr)
+		if isDef {
+			newInstrs = append(newInstrs, genInst(tm, frame, true, accessExp, nt)...)
+		}
+	}
+
+	return newInstrs
+}
+
+// rewrite spills the temps of spilledNodes to new words of the frame, which it records in spilled.
+func rewrite(tm *ir.TempManagement, frame ir.Frame, spilledNodes *IGraphNodeSet, instrs []ir.Instr,
+	spilled map[ir.Temp]ir.FrameAccess) []ir.Instr {
+	for _, node := range spilledNodes.All() {
+		acc := frame.AllocLocal(true)
+		spilled[node.temp] = acc
+		instrs = rewriteOne(tm, frame, node.temp, acc, instrs)
+	}
+
+	return instrs
+}
+
+// Alloc allocates the registers of the instructions of a function. It returns the instructions, rewritten for the
+// temps that are spilled, the register of each temp, and the word of the frame of each temp that is spilled.
+func Alloc(tm *ir.TempManagement, dumper *ir.Dumper, frame ir.Frame, instrs []ir.Instr) ([]ir.Instr,
+	map[ir.Temp]string, map[ir.Temp]ir.FrameAccess) {
+	spilled := make(map[ir.Temp]ir.FrameAccess)
+	instrs, colored := alloc(tm, dumper, frame, instrs, spilled)
+	return instrs, colored, spilled
+}
+
+func alloc(tm *ir.TempManagement, dumper *ir.Dumper, frame ir.Frame, instrs []ir.Instr,
+	spilled map[ir.Temp]ir.FrameAccess) ([]ir.Instr, map[ir.Temp]string) {
+	name := tm.LabelString(frame.Name())
+	fGraph := Instrs2FGraph(instrs)
+	iGraph, moves := InitIGraph(fGraph)
+	dumper.Dump("flow", name, func(sb *strings.Builder) {
+		dumpFlow(sb, tm, fGraph)
+	})
+
+	dumper.Dump("igraph", name, func(sb *strings.Builder) {
+		dumpIGraph(sb, iGraph, moves)
+	})
+
+	coloring := NewColoring(
+		iGraph,
+		fGraph,
+		moves,
+		frame.TempMap(),
+	)
+
+	colored, spilledNodes := coloring.Color()
+	dumper.Dump("alloc", name, func(sb *strings.Builder) {
+		dumpAlloc(sb, frame, colored, spilledNodes)
+	})
+
+	if spilledNodes.Empty() {
+		filteredInstrs := make([]ir.Instr, 0, len(instrs))
+		for _, inst := range instrs {
+			if !isRedundantMove(colored, inst) {
+				filteredInstrs = append(filteredInstrs, inst)
+			}
+		}
+
+		res := make(map[ir.Temp]string)
+		for k, v := range colored {
+			res[k] = frame.TempName(v)
+		}
+
+		return filteredInstrs, res
+	}
+
+	rewrittenInstrs := rewrite(tm, frame, spilledNodes, instrs, spilled)
+	return alloc(tm, dumper, frame, rewrittenInstrs, spilled)
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"tiger/backend/wasm"
+	"tiger/compiler"
+	"tiger/ir"
+	"tiger/semant"
+	"tiger/syntax"
+)
+
+// The REPL of "tiger repl" reads declarations and expressions and runs them one after the other on the IR
+// interpreter, each in the scope of the declarations before it. The inputs are translated for the frames of wasm,
+// whose calling convention the interpreter follows, and for those of mips to show their assembly.
+
+const (
+	// replFile names the inputs in the diagnostics
+	replFile = "repl"
+
+	// replFrameWords is the room for the variables of a session
+	replFrameWords = 1 << 16
+
+	// replDepth is how deep the values of records are shown
+	replDepth = 3
+)
+
+const replHelp = `declarations and expressions are run as they are entered, a let is read up to its end
+:type exp  shows the type of exp
+:ir exp    shows the IR tree of exp
+:asm exp   shows the mips assembly of exp
+:quit      ends the session
+`
+
+// replSession is a compilation whose symbol tables are kept across the inputs.
+type replSession struct {
+	c       *compiler.Compilation
+	session *semant.Session
+}
+
+func newReplSession(arch string) (*replSession, error) {
+	c, err := compiler.NewCompilation(compiler.Options{File: replFile, Arch: arch})
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := c.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	return &replSession{c: c, session: session}, nil
+}
+
+// translate parses and translates src, declarations or an expression.
+func (rs *replSession) translate(src string) (*semant.Input, error) {
+	parser := syntax.NewParser(syntax.NewLexer(replFile, bufio.NewReader(strings.NewReader(src))),
+		rs.c.TempManagement().Strings())
+	if syntax.IsModule(replFile, []byte(src)) {
+		decls, err := parser.ParseModule()
+		if err != nil {
+			return nil, err
+		}
+
+		return rs.session.Decls(decls)
+	}
+
+	exp, err := parser.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return rs.session.Exp(exp)
+}
+
+// Repl runs the inputs read from in and writes their values to out, along with what the program prints.
+type Repl struct {
+	in     *bufio.Reader
+	out    *replWriter
+	eval   *replSession
+	asm    *replSession
+	interp *ir.IrInterpreter
+	fp     int64
+}
+
+// replWriter remembers whether the output is at the start of a line, so that the values are shown on lines of their
+// own after what the program prints.
+type replWriter struct {
+	w   io.Writer
+	bol bool
+}
+
+func (w *replWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.bol = p[len(p)-1] == '\n'
+	}
+
+	return w.w.Write(p)
+}
+
+// line writes s on a line of its own.
+func (w *replWriter) line(s string) {
+	if !w.bol {
+		w.Write([]byte("\n"))
+	}
+
+	w.Write([]byte(s + "\n"))
+}
+
+// NewRepl starts a session. The programs read their input from in too, after the line that runs them.
+func NewRepl(in io.Reader, out io.Writer, maxSteps int) (*Repl, error) {
+	eval, err := newReplSession("wasm")
+	if err != nil {
+		return nil, err
+	}
+
+	asm, err := newReplSession("mips")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Repl{in: bufio.NewReader(in), out: &replWriter{w: out, bol: true}, eval: eval, asm: asm}
+	r.interp, err = ir.NewIrInterpreter(eval.c.TempManagement(), nil, nil, ir.IrTree, eval.c.Arch().WordSize, r.in,
+		r.out)
+	if err != nil {
+		return nil, err
+	}
+
+	r.interp.MaxSteps = maxSteps
+	r.fp = r.interp.NewFrame(replFrameWords)
+	return r, nil
+}
+
+// Run reads and runs the inputs until the end of in or :quit.
+func (r *Repl) Run() error {
+	for {
+		src, err := r.read()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		cmd, arg := replCommand(src)
+		switch cmd {
+		case "":
+			if strings.TrimSpace(src) != "" {
+				r.run(src)
+			}
+		case ":type", ":ir", ":asm":
+			r.show(cmd, arg)
+		case ":help":
+			r.out.Write([]byte(replHelp))
+		case ":quit":
+			return nil
+		default:
+			r.out.line(fmt.Sprintf("unknown command %s, :help lists them", cmd))
+		}
+	}
+}
+
+// read reads an input, over as many lines as it takes to balance its lets and its parentheses.
+func (r *Repl) read() (string, error) {
+	prompt := "> "
+	sb := strings.Builder{}
+	for {
+		r.out.Write([]byte(prompt))
+		r.out.bol = true
+		line, err := r.in.ReadString('\n')
+		sb.WriteString(line)
+		if err != nil {
+			if err == io.EOF && sb.Len() > 0 {
+				return sb.String(), nil
+			}
+
+			return "", err
+		}
+
+		if !replOpen(sb.String()) {
+			return sb.String(), nil
+		}
+
+		prompt = "| "
+	}
+}
+
+// replOpen tells whether src goes on on the next line: a let waits for its end, a parenthesis, a bracket or a brace
+// for the one that closes it, and a comment or a string for its end.
+func replOpen(src string) bool {
+	lexer := syntax.NewLexer(replFile, bufio.NewReader(strings.NewReader(src)))
+	depth := 0
+	for {
+		tok, err := lexer.Token()
+		if err != nil {
+			var diag *syntax.Diagnostic
+			return errors.As(err, &diag) && (diag.Code == "E0001" || diag.Code == "E0002")
+		}
+
+		switch tok.Tok {
+		case "let", "(", "[", "{":
+			depth++
+		case "end", ")", "]", "}":
+			depth--
+		}
+
+		if tok.IsEof() {
+			return depth > 0
+		}
+	}
+}
+
+// replCommand splits an input that starts with a colon into the command and its argument.
+func replCommand(src string) (string, string) {
+	src = strings.TrimSpace(src)
+	if !strings.HasPrefix(src, ":") {
+		return "", ""
+	}
+
+	i := strings.IndexAny(src, " \t\n")
+	if i < 0 {
+		return src, ""
+	}
+
+	return src[:i], strings.TrimSpace(src[i:])
+}
+
+// diagnostics writes the diagnostics of an input that has errors.
+func (r *Repl) diagnostics(src string, err error) {
+	renderer := syntax.NewRenderer(r.out, false)
+	renderer.AddSource(replFile, []byte(src))
+	var diags *syntax.Diagnostics
+	if !errors.As(err, &diags) {
+		r.out.line(err.Error())
+		return
+	}
+
+	for _, diag := range diags.List() {
+		renderer.Render(diag)
+	}
+}
+
+// run translates and runs an input, then shows its value or the variables it declares.
+func (r *Repl) run(src string) {
+	input, err := r.eval.translate(src)
+	if err != nil {
+		r.diagnostics(src, err)
+		return
+	}
+
+	// the assembly session declares the same names, it only translates
+	if len(input.Defs) > 0 {
+		if _, err := r.asm.translate(src); err != nil {
+			r.out.line(fmt.Sprintf("cannot translate for mips: %v", err))
+		}
+	}
+
+	v, err := r.exec(input)
+	if err != nil {
+		r.out.line(fmt.Sprintf("runtime error %v", err))
+		return
+	}
+
+	if input.Defs == nil {
+		r.out.line(r.value(v, input.Ty, 0) + " : " + input.Ty.TypeName())
+		return
+	}
+
+	for _, def := range input.Defs {
+		if def.Kind != semant.DefVar {
+			r.out.line(def.Detail())
+			continue
+		}
+
+		// the variable is read by an input of its own, which finds the one just declared
+		value, err := r.eval.translate(def.Name)
+		if err == nil {
+			v, err = r.exec(value)
+		}
+
+		if err != nil {
+			r.out.line(fmt.Sprintf("%s = %v", def.Detail(), err))
+			continue
+		}
+
+		r.out.line(def.Detail() + " = " + r.value(v, value.Ty, 0))
+	}
+}
+
+// exec loads the functions and the strings of an input and runs it in the frame of the session.
+func (r *Repl) exec(input *semant.Input) (int64, error) {
+	if frame, ok := input.Proc.Frame.(*wasm.Frame); ok && frame.FrameWords() > replFrameWords {
+		return 0, fmt.Errorf("the session has more than %d variables", replFrameWords)
+	}
+
+	if err := r.interp.Load(input.Frags); err != nil {
+		return 0, err
+	}
+
+	return r.interp.Exec(input.Proc, r.fp)
+}
+
+// show writes the type, the IR or the assembly of an expression without running it.
+func (r *Repl) show(cmd, src string) {
+	rs := r.eval
+	if cmd == ":asm" {
+		rs = r.asm
+	}
+
+	if syntax.IsModule(replFile, []byte(src)) {
+		r.out.line(cmd + " takes an expression")
+		return
+	}
+
+	input, err := rs.translate(src)
+	if err != nil {
+		r.diagnostics(src, err)
+		return
+	}
+
+	switch cmd {
+	case ":type":
+		r.out.line(input.Ty.TypeName())
+	case ":ir":
+		sb := strings.Builder{}
+		input.Proc.Body.PrintStm(&sb, rs.c.TempManagement().Strings(), 0)
+		r.out.line(strings.TrimRight(sb.String(), "\n"))
+	case ":asm":
+		r.out.line(strings.TrimRight(rs.c.EmitProc(input.Proc), "\n"))
+	}
+}
+
+// value shows the value v of type ty, records down to replDepth.
+func (r *Repl) value(v int64, ty semant.SemantTy, depth int) string {
+	switch t := ty.(type) {
+	case *semant.IntSemantTy:
+		return strconv.FormatInt(v, 10)
+	case *semant.StringSemantTy:
+		s, err := r.interp.String(v)
+		if err != nil {
+			return err.Error()
+		}
+
+		return strconv.Quote(s)
+	case *semant.UnitSemantTy:
+		return "()"
+	case *semant.NilSemantTy:
+		return "nil"
+	case *semant.RecordSemantTy:
+		if v == 0 {
+			return "nil"
+		}
+
+		if depth == replDepth {
+			return "{...}"
+		}
+
+		names, types := r.eval.session.Fields(t)
+		fields := make([]string, 0, len(names))
+		for i, name := range names {
+			field, err := r.interp.Word(v + int64(i)*int64(r.eval.c.Arch().WordSize))
+			if err != nil {
+				return err.Error()
+			}
+
+			fields = append(fields, name+" = "+r.value(field, types[i], depth+1))
+		}
+
+		return "{" + strings.Join(fields, ", ") + "}"
+	case *semant.ClassSemantTy:
+		if v == 0 {
+			return "nil"
+		}
+
+		return "<object>"
+	default:
+		return "<" + ty.TypeName() + ">"
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// replRun runs the inputs of src in a REPL and returns what it writes, without the prompts.
+func replRun(t *testing.T, src string) []string {
+	out := bytes.Buffer{}
+	repl, err := NewRepl(strings.NewReader(src), &out, 1000000)
+	require.NoError(t, err)
+	require.NoError(t, repl.Run())
+
+	var lines []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		line = strings.TrimLeft(line, ">| ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+func TestRepl(t *testing.T) {
+	t.Parallel()
+
+	lines := replRun(t, `var x := 3
+function f(n: int): int = n + x
+f(4)
+let var y := 2
+in y * f(1)
+end
+x := 10
+f(1)
+type point = {x: int, y: int}
+var p := point{x = 1, y = 2}
+:type p.y
+print("hi")
+`)
+
+	require.Equal(t, []string{
+		"var x: int = 3",
+		"function f(int): int",
+		"7 : int",
+		"8 : int",
+		"() : unit",
+		"11 : int",
+		"type point = record",
+		"var p: record = {x = 1, y = 2}",
+		"int",
+		"hi",
+		"() : unit",
+	}, lines)
+}
+
+func TestRepl_Errors(t *testing.T) {
+	t.Parallel()
+
+	lines := replRun(t, `var s: string := 3
+s
+1 / 0
+var n := 1
+n
+`)
+
+	require.Contains(t, lines, "error[E0116]: expected type string, but expression has type int")
+	require.Contains(t, lines, "error[E0104]: undefined variable s")
+	require.Contains(t, lines, "runtime error division by zero")
+	require.Equal(t, "1 : int", lines[len(lines)-1])
+}
+
+func TestRepl_Show(t *testing.T) {
+	t.Parallel()
+
+	lines := replRun(t, `function f(n: int): int = n * 2
+:ir f(1)
+:asm f(1)
+`)
+
+	require.Contains(t, strings.Join(lines, "\n"), "Call")
+	require.Contains(t, strings.Join(lines, "\n"), "jalr")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+	"tiger/runtime"
+	"tiger/sim"
+)
+
+func runRiscvAsm(t *testing.T, asm string, stdin string) (string, int) {
+	return simulateRiscv(t, runtime.Riscv+"\n"+asm, stdin)
+}
+
+func simulateRiscv(t *testing.T, asm string, stdin string) (string, int) {
+	prog, err := sim.AssembleRiscv(asm)
+	require.NoError(t, err)
+
+	out := bytes.Buffer{}
+	sim := sim.NewRiscv(prog, strings.NewReader(stdin), &out)
+	sim.MaxSteps = 10000000
+	code, err := sim.Run()
+	require.NoError(t, err)
+	return out.String(), code
+}
+
+// runRiscv compiles src for riscv and runs it on the simulator.
+func runRiscv(t *testing.T, src string) string {
+	return runRiscvFile(t, "test.tig", src)
+}
+
+// runRiscvFile is runRiscv for the source of file.
+func runRiscvFile(t *testing.T, file string, src string) string {
+	out, _ := simulateRiscv(t, string(compileTest(t, compiler.Options{File: file, Arch: "riscv"}, []byte(src)).Output), "")
+	return out
+}
+
+func TestRiscvSimulator_Runtime(t *testing.T) {
+	t.Parallel()
+
+	out, code := runRiscvAsm(t, `
+	.data
+L1:	.asciz	"a \"tiger\"\n"
+
+	.text
+main:
+	addi sp, sp, -16
+	sw ra, 12(sp)
+	li a0, -2147483648
+	call printi
+	la a0, L1
+	li a1, 2
+	li a2, 7
+	call substring
+	call print
+	li t0, 7
+	div a0, t0, zero
+	call printi
+	call getchar
+	call ord
+	call chr
+	call print
+	lw ra, 12(sp)
+	addi sp, sp, 16
+	li a0, 3
+	call exit
+`, "xyz")
+
+	require.Equal(t, "-2147483648\"tiger\"-1x", out)
+	require.Equal(t, 3, code)
+}
+
+func TestRiscvSimulator_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := sim.AssembleRiscv("\t.text\n_start:\n\tfoo t0, t1\n")
+	require.Error(t, err)
+
+	_, err = sim.AssembleRiscv("\t.text\n_start:\n\tj missing\n")
+	require.Error(t, err)
+
+	prog, err := sim.AssembleRiscv("\t.text\n_start:\n\tj _start\n")
+	require.NoError(t, err)
+	sim := sim.NewRiscv(prog, strings.NewReader(""), &bytes.Buffer{})
+	sim.MaxSteps = 10
+	_, err = sim.Run()
+	require.Error(t, err)
+}
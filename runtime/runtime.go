@@ -0,0 +1,25 @@
+// Package runtime holds the runtimes of the targets, which are embedded so that the compiler works from any
+// directory: the assembly that the outputs of mips, amd64 and riscv start with, the C source that the output of c is
+// linked with and the JavaScript host of the wasm modules.
+package runtime
+
+import (
+	_ "embed"
+)
+
+var (
+	//go:embed src/runtime.s
+	Mips string
+
+	//go:embed src/runtime_x86.s
+	X86 string
+
+	//go:embed src/runtime_riscv.s
+	Riscv string
+
+	//go:embed src/runtime.c
+	C string
+
+	//go:embed src/runtime_wasm.js
+	Wasm string
+)
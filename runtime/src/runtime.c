@@ -0,0 +1,268 @@
+/*
+ * Runtime of the C backend. Every value is a word wide enough for a pointer, strings are null-terminated like in the
+ * assembly runtimes, and all the functions a Tiger program can call carry the tig_ prefix so that they cannot clash
+ * with the C library.
+ */
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+typedef int64_t word;
+
+#define PTR(w) ((void *)(intptr_t)(w))
+#define WORD(p) ((word)(intptr_t)(p))
+
+word tig_main(word static_link);
+
+/*
+ * The heap is collected by a Cheney-style copying collector. Every object starts with two header words: the
+ * descriptor of a record, a string with 'p' for each field that holds a heap pointer and 'n' for the others, or 0 for
+ * the other objects, and then the length and kind of the object. The address of an object is the one of its first
+ * field, so that compiled code does not know about headers. An object that has been copied has its new address in
+ * place of its length and kind, which the kinds are told apart from by the low bits since addresses are aligned.
+ *
+ * The roots are the frames of the Tiger functions, chained through their first word from tig_frames. The second word
+ * of a frame is the pointer map of the call the function is making: the number of words of the frame holding heap
+ * pointers followed by their indices, or 0 if there are none. The runtime functions that allocate register the heap
+ * pointers they were given in roots.
+ */
+enum { FORWARDED, RECORD, ARRAY, POINTER_ARRAY, STRING };
+
+#define HEADER 2
+#define KIND(h) ((h) & 7)
+#define LENGTH(h) ((h) >> 3)
+
+word tig_frames;
+
+static word *heap, *heap_next, *heap_limit;
+static size_t heap_words = 1 << 15;
+
+/* while collecting, the space being evacuated and the next free word of the one it is copied to */
+static word *from_space, *from_next, *to_next;
+
+static word *roots[2];
+static int nroots;
+
+static void *alloc(size_t n)
+{
+	void *p = malloc(n);
+	if (p == NULL) {
+		fputs("out of memory\n", stderr);
+		exit(1);
+	}
+
+	return p;
+}
+
+/* object_words is the size of the object at p without its header */
+static size_t object_words(word *p)
+{
+	word h = p[-1];
+	if (KIND(h) == STRING)
+		return LENGTH(h) / sizeof(word) + 1;
+
+	return LENGTH(h);
+}
+
+/* forward copies the object w points to, unless w is not an address on the heap or the object was copied already,
+ * and returns its new address */
+static word forward(word w)
+{
+	word *p = PTR(w);
+	if (p <= from_space || p > from_next)
+		return w;
+
+	if (KIND(p[-1]) == FORWARDED)
+		return p[-1];
+
+	size_t n = object_words(p) + HEADER;
+	memcpy(to_next, p - HEADER, n * sizeof(word));
+	p[-1] = WORD(to_next + HEADER);
+	to_next += n;
+	return p[-1];
+}
+
+static void scan(word *p)
+{
+	word h = p[-1];
+	const char *desc = PTR(p[-2]);
+	if (KIND(h) != RECORD && KIND(h) != POINTER_ARRAY)
+		return;
+
+	for (word i = 0; i < LENGTH(h); i++) {
+		if (KIND(h) == POINTER_ARRAY || desc[i] == 'p')
+			p[i] = forward(p[i]);
+	}
+}
+
+/* collect copies the live objects to a new space with room for need more words, growing the heap when the live
+ * objects fill more than half of it */
+static void collect(size_t need)
+{
+	word *to_space = alloc(heap_words * sizeof(word));
+	from_space = heap;
+	from_next = heap_next;
+	to_next = to_space;
+
+	for (word f = tig_frames; f != 0; f = ((word *)PTR(f))[0]) {
+		word *frame = PTR(f);
+		const word *map = PTR(frame[1]);
+		for (word i = 1; map != NULL && i <= map[0]; i++)
+			frame[map[i]] = forward(frame[map[i]]);
+	}
+
+	for (int i = 0; i < nroots; i++)
+		*roots[i] = forward(*roots[i]);
+
+	for (word *s = to_space; s < to_next; s += object_words(s + HEADER) + HEADER)
+		scan(s + HEADER);
+
+	free(heap);
+	heap = to_space;
+	heap_next = to_next;
+	heap_limit = to_space + heap_words;
+
+	size_t used = heap_next - heap + need;
+	if (used * 2 > heap_words) {
+		size_t words = heap_words;
+		while (used * 2 > heap_words)
+			heap_words *= 2;
+
+		if (used > words)
+			collect(need);
+	}
+}
+
+/* allocate returns a cleared object of n words of the given kind and length */
+static word *allocate(word kind, word length, size_t n)
+{
+	if ((size_t)(heap_limit - heap_next) < n + HEADER)
+		collect(n + HEADER);
+
+	word *p = heap_next + HEADER;
+	heap_next += n + HEADER;
+	memset(p - HEADER, 0, (n + HEADER) * sizeof(word));
+	p[-1] = length << 3 | kind;
+	return p;
+}
+
+static char *new_string(size_t n)
+{
+	return (char *)allocate(STRING, n, n / sizeof(word) + 1);
+}
+
+word tig_gcInitArray(word size, word init, word pointers)
+{
+	if (size < 0)
+		size = 0;
+
+	if (pointers)
+		roots[nroots++] = &init;
+
+	word *a = allocate(pointers ? POINTER_ARRAY : ARRAY, size, size);
+	nroots = 0;
+	for (word i = 0; i < size; i++)
+		a[i] = init;
+
+	return WORD(a);
+}
+
+word tig_gcAllocRecord(word desc)
+{
+	size_t n = strlen(PTR(desc));
+	word *r = allocate(RECORD, n, n);
+	r[-2] = desc;
+	return WORD(r);
+}
+
+word tig_printi(word i)
+{
+	printf("%lld", (long long)i);
+	return 0;
+}
+
+word tig_print(word s)
+{
+	fputs(PTR(s), stdout);
+	return 0;
+}
+
+word tig_flush(void)
+{
+	fflush(stdout);
+	return 0;
+}
+
+word tig_size(word s)
+{
+	return strlen(PTR(s));
+}
+
+word tig_stringCompare(word a, word b)
+{
+	return strcmp(PTR(a), PTR(b));
+}
+
+word tig_ord(word s)
+{
+	unsigned char c = *(unsigned char *)PTR(s);
+	return c == 0 ? -1 : c;
+}
+
+word tig_getchar(void)
+{
+	int c = getchar();
+	if (c == EOF)
+		return WORD(new_string(0));
+
+	char *s = new_string(1);
+	s[0] = c;
+	return WORD(s);
+}
+
+word tig_chr(word i)
+{
+	char *s = new_string(1);
+	s[0] = i;
+	return WORD(s);
+}
+
+word tig_not(word i)
+{
+	return !i;
+}
+
+word tig_exit(word code)
+{
+	fflush(stdout);
+	exit(code);
+}
+
+word tig_substring(word s, word first, word n)
+{
+	roots[nroots++] = &s;
+	char *t = new_string(n);
+	nroots = 0;
+	memcpy(t, (char *)PTR(s) + first, n);
+	return WORD(t);
+}
+
+word tig_concat(word a, word b)
+{
+	size_t n = strlen(PTR(a)), m = strlen(PTR(b));
+	roots[nroots++] = &a;
+	roots[nroots++] = &b;
+	char *t = new_string(n + m);
+	nroots = 0;
+	memcpy(t, PTR(a), n);
+	memcpy(t + n, PTR(b), m);
+	return WORD(t);
+}
+
+int main(void)
+{
+	tig_main(0);
+	fflush(stdout);
+	return 0;
+}
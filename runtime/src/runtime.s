@@ -0,0 +1,443 @@
+    .text
+# The heap is collected by a copying collector, like the one of runtime.c. Every object starts with two header words:
+# the descriptor of a record, a string with 'p' for each field that holds a heap pointer and 'n' for the others, or 0
+# for the other objects, and then length<<3|kind, where the kind is 1 for a record, 2 for an array, 3 for an array of
+# pointers and 4 for a string. An object that has been copied has -1 in place of its descriptor and its new address
+# in place of its length and kind. The objects are copied between two spaces taken from sbrk, a larger one replacing
+# the space to copy to when the live objects fill more than half of it.
+#
+# The roots are the frames of the Tiger functions, chained from $fp through the $fp that each one saves at 0($fp).
+# -4($fp) holds the pointer map of the function, the number of words of the frame that hold heap pointers followed by
+# their offsets from $fp, or 0. The runtime functions that allocate keep the heap pointers they were given in gc_root0
+# and gc_root1.
+    .data
+gc_space: .word 0
+gc_space_size: .word 0
+gc_next:  .word 0
+gc_limit: .word 0
+gc_other: .word 0
+gc_other_size: .word 0
+gc_size:  .word 131072
+gc_from:  .word 0
+gc_from_next: .word 0
+gc_root0: .word 0
+gc_root1: .word 0
+
+    .text
+# gc_alloc returns in $v0 a cleared object of $a0 bytes with the header $a1
+gc_alloc:
+    addiu $sp, $sp, -12
+    sw $ra, 0($sp)
+    sw $a0, 4($sp)
+    sw $a1, 8($sp)
+    la $t0, gc_next
+    lw $v0, 0($t0)
+    add $t2, $v0, $a0
+    addiu $t2, $t2, 8
+    la $t1, gc_limit
+    lw $t1, 0($t1)
+    ble $t2, $t1, gc_alloc_fits
+    addiu $a0, $a0, 8
+    jal gc_collect
+    lw $a0, 4($sp)
+    lw $a1, 8($sp)
+    la $t0, gc_next
+    lw $v0, 0($t0)
+    add $t2, $v0, $a0
+    addiu $t2, $t2, 8
+    gc_alloc_fits:
+    sw $t2, 0($t0)
+    move $t3, $v0
+    gc_alloc_clear:
+    sw $zero, 0($t3)
+    addiu $t3, $t3, 4
+    blt $t3, $t2, gc_alloc_clear
+    sw $a1, 4($v0)
+    addiu $v0, $v0, 8
+    lw $ra, 0($sp)
+    addiu $sp, $sp, 12
+    jr $ra
+
+# gc_string returns in $v0 a cleared string of $a0 characters
+gc_string:
+    sll $a1, $a0, 3
+    ori $a1, $a1, 4
+    srl $a0, $a0, 2
+    addiu $a0, $a0, 1
+    sll $a0, $a0, 2
+    j gc_alloc
+
+# gc_collect copies the live objects to the other space with room for $a0 more bytes, or to a new one when it is too
+# small. $t8 is the next free word of the space copied to.
+gc_collect:
+    addiu $sp, $sp, -8
+    sw $ra, 0($sp)
+    sw $a0, 4($sp)
+    gc_collect_again:
+    la $t0, gc_space
+    lw $t1, 0($t0)
+    la $t0, gc_from
+    sw $t1, 0($t0)
+    la $t0, gc_next
+    lw $t1, 0($t0)
+    la $t0, gc_from_next
+    sw $t1, 0($t0)
+    la $t0, gc_size
+    lw $t1, 0($t0)
+    la $t0, gc_other
+    lw $t8, 0($t0)
+    la $t0, gc_other_size
+    lw $t2, 0($t0)
+    bge $t2, $t1, gc_collect_spaces
+    move $a0, $t1
+    li $v0, 9
+    syscall
+    move $t8, $v0
+    move $t2, $t1
+    gc_collect_spaces:
+    la $t0, gc_space
+    lw $t1, 0($t0)
+    sw $t8, 0($t0)
+    la $t0, gc_other
+    sw $t1, 0($t0)
+    la $t0, gc_space_size
+    lw $t1, 0($t0)
+    sw $t2, 0($t0)
+    la $t0, gc_other_size
+    sw $t1, 0($t0)
+    add $t2, $t8, $t2
+    la $t0, gc_limit
+    sw $t2, 0($t0)
+
+    move $t7, $fp
+    gc_collect_frames:
+    beqz $t7, gc_collect_roots
+    lw $t6, -4($t7)
+    beqz $t6, gc_collect_next_frame
+    lw $t5, 0($t6)
+    gc_collect_frame_words:
+    beqz $t5, gc_collect_next_frame
+    addiu $t6, $t6, 4
+    lw $t4, 0($t6)
+    add $t4, $t4, $t7
+    lw $a0, 0($t4)
+    jal gc_forward
+    sw $v0, 0($t4)
+    addiu $t5, $t5, -1
+    j gc_collect_frame_words
+    gc_collect_next_frame:
+    lw $t7, 0($t7)
+    j gc_collect_frames
+
+    gc_collect_roots:
+    la $t4, gc_root0
+    lw $a0, 0($t4)
+    jal gc_forward
+    sw $v0, 0($t4)
+    la $t4, gc_root1
+    lw $a0, 0($t4)
+    jal gc_forward
+    sw $v0, 0($t4)
+
+    # the fields of the copied objects, which are copied in turn after them
+    la $t0, gc_space
+    lw $t9, 0($t0)
+    gc_collect_scan:
+    bge $t9, $t8, gc_collect_scanned
+    lw $t2, 0($t9)
+    lw $t3, 4($t9)
+    addiu $t9, $t9, 8
+    andi $t1, $t3, 7
+    srl $t3, $t3, 3
+    li $t0, 4
+    bne $t1, $t0, gc_collect_fields
+    srl $t3, $t3, 2
+    addiu $t3, $t3, 1
+    sll $t3, $t3, 2
+    add $t9, $t9, $t3
+    j gc_collect_scan
+    gc_collect_fields:
+    move $t5, $t3
+    gc_collect_field:
+    beqz $t5, gc_collect_scan
+    li $t0, 2
+    beq $t1, $t0, gc_collect_skip
+    li $t0, 1
+    bne $t1, $t0, gc_collect_pointer
+    lbu $t0, 0($t2)
+    addiu $t2, $t2, 1
+    li $t4, 112
+    bne $t0, $t4, gc_collect_skip
+    gc_collect_pointer:
+    lw $a0, 0($t9)
+    jal gc_forward
+    sw $v0, 0($t9)
+    gc_collect_skip:
+    addiu $t9, $t9, 4
+    addiu $t5, $t5, -1
+    j gc_collect_field
+
+    gc_collect_scanned:
+    la $t0, gc_next
+    sw $t8, 0($t0)
+    la $t0, gc_space
+    lw $t2, 0($t0)
+    sub $t3, $t8, $t2
+    lw $a0, 4($sp)
+    add $t3, $t3, $a0
+    sll $t4, $t3, 1
+    la $t0, gc_size
+    lw $t1, 0($t0)
+    ble $t4, $t1, gc_collect_done
+    gc_collect_grow:
+    sll $t1, $t1, 1
+    bgt $t4, $t1, gc_collect_grow
+    sw $t1, 0($t0)
+    la $t0, gc_space_size
+    lw $t5, 0($t0)
+    bgt $t3, $t5, gc_collect_again
+    gc_collect_done:
+    lw $ra, 0($sp)
+    addiu $sp, $sp, 8
+    jr $ra
+
+# gc_forward copies the object $a0 points to, unless it is not an address of the space being evacuated or the object
+# was copied already, and returns its new address in $v0
+gc_forward:
+    move $v0, $a0
+    la $a1, gc_from
+    lw $a1, 0($a1)
+    ble $a0, $a1, gc_forward_done
+    la $a1, gc_from_next
+    lw $a1, 0($a1)
+    bgt $a0, $a1, gc_forward_done
+    lw $a1, -8($a0)
+    lw $v0, -4($a0)
+    li $a2, -1
+    beq $a1, $a2, gc_forward_done
+    andi $a2, $v0, 7
+    srl $a3, $v0, 3
+    li $v1, 4
+    bne $a2, $v1, gc_forward_words
+    srl $a3, $a3, 2
+    addiu $a3, $a3, 1
+    gc_forward_words:
+    sll $a3, $a3, 2
+    addiu $a1, $a0, -8
+    add $a3, $a3, $a0
+    move $v0, $t8
+    gc_forward_copy:
+    lw $a2, 0($a1)
+    sw $a2, 0($t8)
+    addiu $a1, $a1, 4
+    addiu $t8, $t8, 4
+    blt $a1, $a3, gc_forward_copy
+    addiu $v0, $v0, 8
+    li $a2, -1
+    sw $a2, -8($a0)
+    sw $v0, -4($a0)
+    gc_forward_done:
+    jr $ra
+
+gcInitArray:
+    addiu $sp, $sp, -16
+    sw $ra, 0($sp)
+    bgez $a0, gcInitArray_size
+    move $a0, $zero
+    gcInitArray_size:
+    sw $a0, 4($sp)
+    sw $a1, 8($sp)
+    sw $a2, 12($sp)
+    beqz $a2, gcInitArray_alloc
+    la $t0, gc_root0
+    sw $a1, 0($t0)
+    gcInitArray_alloc:
+    sltu $t1, $zero, $a2
+    addiu $t1, $t1, 2
+    sll $a1, $a0, 3
+    or $a1, $a1, $t1
+    sll $a0, $a0, 2
+    jal gc_alloc
+    lw $a0, 4($sp)
+    lw $a1, 8($sp)
+    lw $a2, 12($sp)
+    beqz $a2, gcInitArray_fill
+    la $t0, gc_root0
+    lw $a1, 0($t0)
+    sw $zero, 0($t0)
+    gcInitArray_fill:
+    sll $a0, $a0, 2
+    add $a0, $a0, $v0
+    move $v1, $v0
+    gcInitArray_loop:
+    beq $v1, $a0, gcInitArray_done
+    sw $a1, 0($v1)
+    addiu $v1, $v1, 4
+    j gcInitArray_loop
+    gcInitArray_done:
+    lw $ra, 0($sp)
+    addiu $sp, $sp, 16
+    jr $ra
+
+gcAllocRecord:
+    addiu $sp, $sp, -8
+    sw $ra, 0($sp)
+    sw $a0, 4($sp)
+    jal size
+    sll $a0, $v0, 2
+    sll $a1, $v0, 3
+    ori $a1, $a1, 1
+    jal gc_alloc
+    lw $a0, 4($sp)
+    sw $a0, -8($v0)
+    lw $ra, 0($sp)
+    addiu $sp, $sp, 8
+    jr $ra
+
+printi:
+    li $v0, 1
+    syscall
+    jr $ra
+
+print:
+    li $v0, 4
+    syscall
+    jr $ra
+
+flush:
+    jr $ra
+
+stringCompare:
+    stringCompare_loop:
+    lbu $a2 ($a0)
+    lbu $a3 ($a1)
+    bne $a2, $a3, stringCompare_end
+    beq $a2, $zero, stringCompare_end
+    add $a0, $a0, 1
+    add $a1, $a1, 1
+    j stringCompare_loop
+    stringCompare_end:
+    sub $v0, $a2, $a3
+    jr $ra
+
+size:
+    move $v0, $zero
+    sizeloop:
+    lb $a1 ($a0)
+    beq $a1, $zero sizeexit
+    add $v0, $v0, 1
+    add $a0, $a0, 1
+    j sizeloop
+    sizeexit:
+    jr $ra
+
+ord:
+    lb $a1,($a0)
+    li $v0,-1
+    beqz $a1,Lrunt5
+    lb $v0,($a0)
+    Lrunt5:
+    jr $ra
+
+getchar:
+    addiu $sp, $sp, -4
+    sw $ra, 0($sp)
+    li $a0, 1
+    jal gc_string
+    move $a0, $v0
+    li $a1, 2
+    li $v0, 8
+    syscall
+    move $v0, $a0
+    lw $ra, 0($sp)
+    addiu $sp, $sp, 4
+    jr $ra
+
+chr:
+    addiu $sp, $sp, -8
+    sw $ra, 0($sp)
+    sw $a0, 4($sp)
+    li $a0, 1
+    jal gc_string
+    lw $a1, 4($sp)
+    sb $a1 ($v0)
+    lw $ra, 0($sp)
+    addiu $sp, $sp, 8
+    jr $ra
+
+exit:
+    li $v0, 10
+    syscall
+
+substring:
+    addiu $sp, $sp, -12
+    sw $ra, 0($sp)
+    sw $a1, 4($sp)
+    sw $a2, 8($sp)
+    la $t0, gc_root0
+    sw $a0, 0($t0)
+    move $a0, $a2
+    jal gc_string
+    la $t0, gc_root0
+    lw $a1, 0($t0)
+    sw $zero, 0($t0)
+    lw $a2, 4($sp)
+    add $a1, $a1, $a2
+    lw $a2, 8($sp)
+    add $a2, $a2, $a1
+    move $a0, $v0
+    substringcopy:
+    beq $a1 $a2 substringexit
+    lb $a3 ($a1)
+    sb $a3 ($a0)
+    add $a1, $a1, 1
+    add $a0, $a0, 1
+    j substringcopy
+    substringexit:
+    lw $ra, 0($sp)
+    addiu $sp, $sp, 12
+    jr $ra
+
+copy:
+    copyloop:
+    lb $a2, ($a1)
+    beq $zero, $a2 copyexit
+    sb $a2, ($a0)
+    add $a0,$a0,1
+    add $a1,$a1,1
+    j copyloop
+    copyexit:
+    sb $zero, ($a0)
+    move $v0, $a0
+    jr $ra
+
+concat:
+    addiu $sp, $sp, -12
+    sw $ra, 0($sp)
+    la $t0, gc_root0
+    sw $a0, 0($t0)
+    la $t0, gc_root1
+    sw $a1, 0($t0)
+    jal size
+    sw $v0, 4($sp)
+    la $t0, gc_root1
+    lw $a0, 0($t0)
+    jal size
+    lw $a0, 4($sp)
+    add $a0, $a0, $v0
+    jal gc_string
+    sw $v0, 8($sp)
+    move $a0, $v0
+    la $t0, gc_root0
+    lw $a1, 0($t0)
+    sw $zero, 0($t0)
+    jal copy
+    move $a0, $v0
+    la $t0, gc_root1
+    lw $a1, 0($t0)
+    sw $zero, 0($t0)
+    jal copy
+    lw $v0, 8($sp)
+    lw $ra, 0($sp)
+    addiu $sp, $sp, 12
+    jr $ra
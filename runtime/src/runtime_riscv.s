@@ -0,0 +1,511 @@
+    # RV32IM Linux runtime. It talks to the kernel with ecall, so a program links with a plain `ld` and no libc.
+    # Arguments come in a0-a2 and results go to a0, like compiled Tiger functions; only the registers that a call
+    # trashes are used. Labels starting with .L cannot clash with Tiger identifiers.
+    #
+    # The heap is collected by a copying collector, like the one of runtime.c. Every object starts with two header
+    # words: the descriptor of a record, a string with 'p' for each field that holds a heap pointer and 'n' for the
+    # others, or 0 for the other objects, and then length<<3|kind, where the kind is 1 for a record, 2 for an array, 3
+    # for an array of pointers and 4 for a string. An object that has been copied has -1 in place of its descriptor and
+    # its new address in place of its length and kind. The objects are copied between two spaces taken from brk, a
+    # larger one replacing the space to copy to when the live objects fill more than half of it.
+    #
+    # The roots are the frames of the Tiger functions, chained from s0 through the s0 that each one saves at -4(s0).
+    # -8(s0) holds the pointer map of the function, the number of words of the frame that hold heap pointers followed
+    # by their offsets from s0, or 0. The runtime functions that allocate keep the heap pointers they were given in
+    # .Lgc_root0 and .Lgc_root1.
+    .data
+.Lgc_space:
+    .word 0
+.Lgc_space_size:
+    .word 0
+.Lgc_next:
+    .word 0
+.Lgc_limit:
+    .word 0
+.Lgc_other:
+    .word 0
+.Lgc_other_size:
+    .word 0
+.Lgc_size:
+    .word 131072
+.Lgc_from:
+    .word 0
+.Lgc_from_next:
+    .word 0
+.Lgc_root0:
+    .word 0
+.Lgc_root1:
+    .word 0
+.Lout_of_memory:
+    .ascii "out of memory\n"
+
+    .text
+    .globl _start
+_start:
+    li s0, 0
+    call main
+    li a0, 0
+    li a7, 93
+    ecall
+
+    # .Lalloc returns a cleared object of a0 bytes with the header a1
+.Lalloc:
+    addi sp, sp, -16
+    sw ra, 12(sp)
+    sw a0, 8(sp)
+    sw a1, 4(sp)
+    la t0, .Lgc_next
+    lw t1, 0(t0)
+    add t2, t1, a0
+    addi t2, t2, 8
+    la t3, .Lgc_limit
+    lw t3, 0(t3)
+    bleu t2, t3, .Lalloc_fits
+    addi a0, a0, 8
+    call .Lcollect
+    lw a0, 8(sp)
+    lw a1, 4(sp)
+    la t0, .Lgc_next
+    lw t1, 0(t0)
+    add t2, t1, a0
+    addi t2, t2, 8
+.Lalloc_fits:
+    sw t2, 0(t0)
+    mv t3, t1
+.Lalloc_clear:
+    sw zero, 0(t3)
+    addi t3, t3, 4
+    bltu t3, t2, .Lalloc_clear
+    sw a1, 4(t1)
+    addi a0, t1, 8
+    lw ra, 12(sp)
+    addi sp, sp, 16
+    ret
+.Lalloc_fail:
+    li a0, 2
+    la a1, .Lout_of_memory
+    li a2, 14
+    li a7, 64
+    ecall
+    li a0, 1
+    li a7, 93
+    ecall
+
+    # .Lstring returns a cleared string of a0 characters
+.Lstring:
+    slli a1, a0, 3
+    ori a1, a1, 4
+    srli a0, a0, 2
+    addi a0, a0, 1
+    slli a0, a0, 2
+    j .Lalloc
+
+    # .Lcollect copies the live objects to the other space with room for a0 more bytes, or to a new one when it is too
+    # small. t6 is the next free word of the space copied to.
+.Lcollect:
+    addi sp, sp, -16
+    sw ra, 12(sp)
+    sw a0, 8(sp)
+.Lcollect_again:
+    la t0, .Lgc_space
+    lw t1, 0(t0)
+    la t2, .Lgc_from
+    sw t1, 0(t2)
+    la t0, .Lgc_next
+    lw t1, 0(t0)
+    la t2, .Lgc_from_next
+    sw t1, 0(t2)
+    la t0, .Lgc_size
+    lw t1, 0(t0)
+    la t0, .Lgc_other
+    lw t6, 0(t0)
+    la t0, .Lgc_other_size
+    lw t3, 0(t0)
+    bgeu t3, t1, .Lcollect_spaces
+    li a0, 0
+    li a7, 214
+    ecall
+    mv t6, a0
+    add t2, a0, t1
+    mv a0, t2
+    li a7, 214
+    ecall
+    bltu a0, t2, .Lalloc_fail
+    mv t3, t1
+.Lcollect_spaces:
+    la t0, .Lgc_space
+    lw t1, 0(t0)
+    sw t6, 0(t0)
+    la t0, .Lgc_other
+    sw t1, 0(t0)
+    la t0, .Lgc_space_size
+    lw t1, 0(t0)
+    sw t3, 0(t0)
+    la t0, .Lgc_other_size
+    sw t1, 0(t0)
+    add t3, t6, t3
+    la t0, .Lgc_limit
+    sw t3, 0(t0)
+
+    mv t5, s0
+.Lcollect_frames:
+    beqz t5, .Lcollect_roots
+    lw t4, -8(t5)
+    beqz t4, .Lcollect_next_frame
+    lw t3, 0(t4)
+.Lcollect_frame_words:
+    beqz t3, .Lcollect_next_frame
+    addi t4, t4, 4
+    lw t2, 0(t4)
+    add t2, t2, t5
+    lw a0, 0(t2)
+    call .Lforward
+    sw a0, 0(t2)
+    addi t3, t3, -1
+    j .Lcollect_frame_words
+.Lcollect_next_frame:
+    lw t5, -4(t5)
+    j .Lcollect_frames
+
+.Lcollect_roots:
+    la t2, .Lgc_root0
+    lw a0, 0(t2)
+    call .Lforward
+    sw a0, 0(t2)
+    la t2, .Lgc_root1
+    lw a0, 0(t2)
+    call .Lforward
+    sw a0, 0(t2)
+
+    # the fields of the copied objects, which are copied in turn after them
+    la t0, .Lgc_space
+    lw t5, 0(t0)
+.Lcollect_scan:
+    bgeu t5, t6, .Lcollect_scanned
+    lw t0, 0(t5)
+    lw t1, 4(t5)
+    addi t5, t5, 8
+    andi t2, t1, 7
+    srli t1, t1, 3
+    li t3, 4
+    bne t2, t3, .Lcollect_fields
+    srli t1, t1, 2
+    addi t1, t1, 1
+    slli t1, t1, 2
+    add t5, t5, t1
+    j .Lcollect_scan
+.Lcollect_fields:
+    beqz t1, .Lcollect_scan
+    li t3, 2
+    beq t2, t3, .Lcollect_skip
+    li t3, 1
+    bne t2, t3, .Lcollect_pointer
+    lbu t3, 0(t0)
+    addi t0, t0, 1
+    li t4, 112
+    bne t3, t4, .Lcollect_skip
+.Lcollect_pointer:
+    lw a0, 0(t5)
+    call .Lforward
+    sw a0, 0(t5)
+.Lcollect_skip:
+    addi t5, t5, 4
+    addi t1, t1, -1
+    j .Lcollect_fields
+
+.Lcollect_scanned:
+    la t0, .Lgc_next
+    sw t6, 0(t0)
+    la t0, .Lgc_space
+    lw t2, 0(t0)
+    sub t3, t6, t2
+    lw a0, 8(sp)
+    add t3, t3, a0
+    slli t4, t3, 1
+    la t0, .Lgc_size
+    lw t1, 0(t0)
+    bleu t4, t1, .Lcollect_done
+.Lcollect_grow:
+    slli t1, t1, 1
+    bgtu t4, t1, .Lcollect_grow
+    sw t1, 0(t0)
+    la t0, .Lgc_space_size
+    lw t5, 0(t0)
+    bgtu t3, t5, .Lcollect_again
+.Lcollect_done:
+    lw ra, 12(sp)
+    addi sp, sp, 16
+    ret
+
+    # .Lforward copies the object a0 points to, unless it is not an address of the space being evacuated or the
+    # object was copied already, and returns its new address in a0. It only uses a0-a4 and t6.
+.Lforward:
+    la a1, .Lgc_from
+    lw a1, 0(a1)
+    bleu a0, a1, .Lforward_done
+    la a1, .Lgc_from_next
+    lw a1, 0(a1)
+    bgtu a0, a1, .Lforward_done
+    lw a1, -8(a0)
+    lw a2, -4(a0)
+    li a3, -1
+    bne a1, a3, .Lforward_copy
+    mv a0, a2
+    ret
+.Lforward_copy:
+    andi a3, a2, 7
+    srli a2, a2, 3
+    li a4, 4
+    bne a3, a4, .Lforward_words
+    srli a2, a2, 2
+    addi a2, a2, 1
+.Lforward_words:
+    slli a2, a2, 2
+    add a2, a2, a0
+    addi a1, a0, -8
+    mv a4, t6
+.Lforward_loop:
+    lw a3, 0(a1)
+    sw a3, 0(t6)
+    addi a1, a1, 4
+    addi t6, t6, 4
+    bltu a1, a2, .Lforward_loop
+    addi a4, a4, 8
+    li a3, -1
+    sw a3, -8(a0)
+    sw a4, -4(a0)
+    mv a0, a4
+.Lforward_done:
+    ret
+
+    # .Lcopy copies a2 bytes from a1 to a0 and returns the end of the destination in a0
+.Lcopy:
+    blez a2, .Lcopy_done
+    lbu t0, 0(a1)
+    sb t0, 0(a0)
+    addi a0, a0, 1
+    addi a1, a1, 1
+    addi a2, a2, -1
+    j .Lcopy
+.Lcopy_done:
+    ret
+
+gcInitArray:
+    addi sp, sp, -16
+    sw ra, 12(sp)
+    bgez a0, .LgcInitArray_size
+    li a0, 0
+.LgcInitArray_size:
+    sw a0, 8(sp)
+    sw a1, 4(sp)
+    sw a2, 0(sp)
+    beqz a2, .LgcInitArray_alloc
+    la t0, .Lgc_root0
+    sw a1, 0(t0)
+.LgcInitArray_alloc:
+    snez t1, a2
+    addi t1, t1, 2
+    slli a1, a0, 3
+    or a1, a1, t1
+    slli a0, a0, 2
+    call .Lalloc
+    lw t0, 8(sp)
+    lw a1, 4(sp)
+    lw a2, 0(sp)
+    beqz a2, .LgcInitArray_fill
+    la t2, .Lgc_root0
+    lw a1, 0(t2)
+    sw zero, 0(t2)
+.LgcInitArray_fill:
+    mv t1, a0
+.LgcInitArray_loop:
+    blez t0, .LgcInitArray_done
+    sw a1, 0(t1)
+    addi t1, t1, 4
+    addi t0, t0, -1
+    j .LgcInitArray_loop
+.LgcInitArray_done:
+    lw ra, 12(sp)
+    addi sp, sp, 16
+    ret
+
+gcAllocRecord:
+    addi sp, sp, -16
+    sw ra, 12(sp)
+    sw a0, 8(sp)
+    call size
+    slli a1, a0, 3
+    ori a1, a1, 1
+    slli a0, a0, 2
+    call .Lalloc
+    lw t0, 8(sp)
+    sw t0, -8(a0)
+    lw ra, 12(sp)
+    addi sp, sp, 16
+    ret
+
+printi:
+    addi sp, sp, -16
+    mv t0, a0
+    addi t1, sp, 16
+    mv t2, t1
+    li t3, 10
+    bgez t0, .Lprinti_loop
+    neg t0, t0
+.Lprinti_loop:
+    remu t4, t0, t3
+    divu t0, t0, t3
+    addi t4, t4, 48
+    addi t1, t1, -1
+    sb t4, 0(t1)
+    bnez t0, .Lprinti_loop
+    bgez a0, .Lprinti_write
+    li t4, 45
+    addi t1, t1, -1
+    sb t4, 0(t1)
+.Lprinti_write:
+    li a0, 1
+    mv a1, t1
+    sub a2, t2, t1
+    li a7, 64
+    ecall
+    addi sp, sp, 16
+    ret
+
+print:
+    mv a1, a0
+    mv t0, a0
+.Lprint_loop:
+    lbu t1, 0(t0)
+    beqz t1, .Lprint_write
+    addi t0, t0, 1
+    j .Lprint_loop
+.Lprint_write:
+    li a0, 1
+    sub a2, t0, a1
+    li a7, 64
+    ecall
+    ret
+
+flush:
+    ret
+
+size:
+    mv t0, a0
+.Lsize_loop:
+    lbu t1, 0(t0)
+    beqz t1, .Lsize_done
+    addi t0, t0, 1
+    j .Lsize_loop
+.Lsize_done:
+    sub a0, t0, a0
+    ret
+
+stringCompare:
+    lbu t0, 0(a0)
+    lbu t1, 0(a1)
+    bne t0, t1, .LstringCompare_done
+    beqz t0, .LstringCompare_done
+    addi a0, a0, 1
+    addi a1, a1, 1
+    j stringCompare
+.LstringCompare_done:
+    sub a0, t0, t1
+    ret
+
+ord:
+    lbu a0, 0(a0)
+    bnez a0, .Lord_done
+    li a0, -1
+.Lord_done:
+    ret
+
+getchar:
+    addi sp, sp, -16
+    sw ra, 12(sp)
+    li a0, 1
+    call .Lstring
+    sw a0, 8(sp)
+    mv a1, a0
+    li a0, 0
+    li a2, 1
+    li a7, 63
+    ecall
+    lw a0, 8(sp)
+    lw ra, 12(sp)
+    addi sp, sp, 16
+    ret
+
+chr:
+    addi sp, sp, -16
+    sw ra, 12(sp)
+    sw a0, 8(sp)
+    li a0, 1
+    call .Lstring
+    lw t0, 8(sp)
+    sb t0, 0(a0)
+    lw ra, 12(sp)
+    addi sp, sp, 16
+    ret
+
+not:
+    seqz a0, a0
+    ret
+
+exit:
+    li a7, 93
+    ecall
+
+substring:
+    addi sp, sp, -16
+    sw ra, 12(sp)
+    la t0, .Lgc_root0
+    sw a0, 0(t0)
+    sw a1, 4(sp)
+    sw a2, 0(sp)
+    mv a0, a2
+    call .Lstring
+    la t0, .Lgc_root0
+    lw a1, 0(t0)
+    sw zero, 0(t0)
+    lw t1, 4(sp)
+    add a1, a1, t1
+    lw a2, 0(sp)
+    sw a0, 4(sp)
+    call .Lcopy
+    lw a0, 4(sp)
+    lw ra, 12(sp)
+    addi sp, sp, 16
+    ret
+
+concat:
+    addi sp, sp, -32
+    sw ra, 28(sp)
+    la t0, .Lgc_root0
+    sw a0, 0(t0)
+    la t0, .Lgc_root1
+    sw a1, 0(t0)
+    call size
+    sw a0, 16(sp)
+    la t0, .Lgc_root1
+    lw a0, 0(t0)
+    call size
+    sw a0, 12(sp)
+    lw t0, 16(sp)
+    add a0, a0, t0
+    call .Lstring
+    sw a0, 8(sp)
+    la t0, .Lgc_root0
+    lw a1, 0(t0)
+    sw zero, 0(t0)
+    lw a2, 16(sp)
+    call .Lcopy
+    la t0, .Lgc_root1
+    lw a1, 0(t0)
+    sw zero, 0(t0)
+    lw a2, 12(sp)
+    call .Lcopy
+    lw a0, 8(sp)
+    lw ra, 28(sp)
+    addi sp, sp, 32
+    ret
@@ -0,0 +1,270 @@
+// Host of the modules that tigerc -arch=wasm produces. The module imports the runtime functions from "tiger" and
+// exports its memory, the heap global that the runtime takes its memory from, the sp global of its shadow stack and
+// main.
+//
+// In node:    node runtime/src/runtime_wasm.js prog.tig.wasm
+// In browsers: runTiger(bytes, {write: s => ..., read: () => ...}) with the bytes of the module.
+
+"use strict";
+
+const WORD = 4;
+
+// the kinds of the objects of the heap, in the low bits of their headers
+const RECORD = 1, ARRAY = 2, POINTER_ARRAY = 3, STRING = 4;
+
+function runTiger(bytes, io) {
+    let instance;
+    const memory = () => new Uint8Array(instance.exports.memory.buffer);
+    const words = () => new Int32Array(instance.exports.memory.buffer);
+
+    // The heap is collected by a copying collector, the same as the one of the VM in backend/wasm/vm.go. Every object
+    // starts with two header words: the descriptor of a record or 0, and length<<3|kind. A copied object has -1 in
+    // place of its descriptor and its new address in place of its length and kind. The roots are the frames of the
+    // shadow stack, from sp to the top of the stack, each of which starts with its size and its pointer map.
+    const gc = {
+        stackTop: 0,
+        space: 0, spaceSize: 0, next: 0, limit: 0,
+        other: 0, otherSize: 0,
+        size: 131072,
+        from: 0, fromNext: 0,
+    };
+
+    // alloc takes n bytes from the memory and grows it when needed.
+    function alloc(n) {
+        const heap = instance.exports.heap;
+        const p = (heap.value + 3) & ~3;
+        const end = p + n;
+        const size = instance.exports.memory.buffer.byteLength;
+        if (end > size) {
+            instance.exports.memory.grow(Math.ceil((end - size) / 65536));
+        }
+
+        heap.value = end;
+        return p;
+    }
+
+    function str(p) {
+        const mem = memory();
+        let end = p;
+        while (mem[end] !== 0) {
+            end++;
+        }
+
+        return mem.subarray(p, end);
+    }
+
+    // gcAlloc returns a cleared object of n bytes with the header, collecting the heap when it is full. The
+    // collector updates the heap pointers in roots.
+    function gcAlloc(n, header, roots = []) {
+        if (gc.next + 8 + n > gc.limit) {
+            collect(8 + n, roots);
+        }
+
+        const p = gc.next;
+        gc.next += 8 + n;
+        const w = words();
+        w[p >> 2] = 0;
+        w[(p >> 2) + 1] = header;
+        memory().fill(0, p + 8, gc.next);
+        return p + 8;
+    }
+
+    // collect copies the live objects to the other space with room for need more bytes, or to a new one when it is
+    // too small.
+    function collect(need, roots) {
+        for (;;) {
+            gc.from = gc.space;
+            gc.fromNext = gc.next;
+            let to = gc.other, toSize = gc.otherSize;
+            if (toSize < gc.size) {
+                to = alloc(gc.size);
+                toSize = gc.size;
+            }
+
+            gc.other = gc.space;
+            gc.otherSize = gc.spaceSize;
+            gc.space = to;
+            gc.spaceSize = toSize;
+            gc.next = to;
+            gc.limit = to + toSize;
+
+            const w = words(), mem = memory();
+            const forwardAt = a => { w[a >> 2] = forward(w, mem, w[a >> 2]); };
+            for (let fp = instance.exports.sp.value; fp < gc.stackTop; fp += w[fp >> 2]) {
+                const map = w[(fp >> 2) + 1];
+                for (let i = 1; map !== 0 && i <= w[map >> 2]; i++) {
+                    forwardAt(fp + w[(map >> 2) + i]);
+                }
+            }
+
+            for (let i = 0; i < roots.length; i++) {
+                roots[i] = forward(w, mem, roots[i]);
+            }
+
+            // the fields of the copied objects, which are copied in turn after them
+            for (let scan = gc.space; scan < gc.next;) {
+                const desc = w[scan >> 2], header = w[(scan >> 2) + 1];
+                scan += 8;
+                let length = header >> 3;
+                switch (header & 7) {
+                case STRING:
+                    length = (length >> 2) + 1;
+                    break;
+                case RECORD:
+                    for (let i = 0; i < length; i++) {
+                        if (String.fromCharCode(mem[desc + i]) === "p") {
+                            forwardAt(scan + i * WORD);
+                        }
+                    }
+
+                    break;
+                case POINTER_ARRAY:
+                    for (let i = 0; i < length; i++) {
+                        forwardAt(scan + i * WORD);
+                    }
+
+                    break;
+                }
+
+                scan += length * WORD;
+            }
+
+            const used = gc.next - gc.space + need;
+            if (used * 2 <= gc.size) {
+                return;
+            }
+
+            while (used * 2 > gc.size) {
+                gc.size *= 2;
+            }
+
+            if (used <= gc.spaceSize) {
+                return;
+            }
+        }
+    }
+
+    // forward copies the object p points to, unless it is not an address of the space being evacuated or the object
+    // was copied already, and returns its new address.
+    function forward(w, mem, p) {
+        if (p <= gc.from || p > gc.fromNext) {
+            return p;
+        }
+
+        if (w[(p >> 2) - 2] === -1) {
+            return w[(p >> 2) - 1];
+        }
+
+        const header = w[(p >> 2) - 1];
+        let n = header >> 3;
+        if ((header & 7) === STRING) {
+            n = (n >> 2) + 1;
+        }
+
+        const size = 8 + n * WORD, to = gc.next;
+        mem.copyWithin(to, p - 8, p - 8 + size);
+        w[(p >> 2) - 2] = -1;
+        w[(p >> 2) - 1] = to + 8;
+        gc.next += size;
+        return to + 8;
+    }
+
+    // newStr allocates a null-terminated copy of b on the heap, b must not be a view of the memory.
+    function newStr(b) {
+        const p = gcAlloc(((b.length >> 2) + 1) * WORD, b.length << 3 | STRING);
+        memory().set(b, p);
+        return p;
+    }
+
+    const tiger = {
+        print: s => { io.write(str(s)); return 0; },
+        printi: i => { io.write(new TextEncoder().encode(String(i))); return 0; },
+        flush: () => 0,
+        getchar: () => {
+            const c = io.read();
+            return newStr(c < 0 ? [] : [c]);
+        },
+        ord: s => {
+            const b = str(s);
+            return b.length === 0 ? -1 : b[0];
+        },
+        chr: i => newStr([i & 0xff]),
+        size: s => str(s).length,
+        stringCompare: (a, b) => {
+            const x = str(a), y = str(b);
+            for (let i = 0; i < x.length && i < y.length; i++) {
+                if (x[i] !== y[i]) {
+                    return x[i] - y[i];
+                }
+            }
+
+            return x.length - y.length;
+        },
+        substring: (s, first, n) => {
+            const b = str(s);
+            if (first < 0 || n < 0 || first + n > b.length) {
+                throw new Error("substring out of range");
+            }
+
+            return newStr(b.slice(first, first + n));
+        },
+        concat: (a, b) => {
+            const x = str(a), y = str(b);
+            const c = new Uint8Array(x.length + y.length);
+            c.set(x);
+            c.set(y, x.length);
+            return newStr(c);
+        },
+        not: i => (i === 0 ? 1 : 0),
+        exit: code => { throw { tigerExit: code }; },
+        gcInitArray: (n, init, ptr) => {
+            if (n < 0) {
+                throw new Error("invalid array size");
+            }
+
+            // the collector has to update init only when it is a heap pointer
+            const roots = ptr ? [init] : [];
+            const p = gcAlloc(n * WORD, n << 3 | (ptr ? POINTER_ARRAY : ARRAY), roots);
+            words().fill(ptr ? roots[0] : init, p >> 2, (p >> 2) + n);
+            return p;
+        },
+        gcAllocRecord: desc => {
+            const n = str(desc).length;
+            const p = gcAlloc(n * WORD, n << 3 | RECORD);
+            words()[(p >> 2) - 2] = desc;
+            return p;
+        },
+    };
+
+    const mod = new WebAssembly.Module(bytes);
+    instance = new WebAssembly.Instance(mod, {tiger: tiger});
+    gc.stackTop = instance.exports.heap.value;
+    try {
+        instance.exports.main(0);
+    } catch (e) {
+        if (e && e.tigerExit !== undefined) {
+            return e.tigerExit;
+        }
+
+        throw e;
+    }
+
+    return 0;
+}
+
+if (typeof module !== "undefined" && require.main === module) {
+    const fs = require("fs");
+    const bytes = fs.readFileSync(process.argv[2]);
+    let input = null, pos = 0;
+    process.exitCode = runTiger(bytes, {
+        write: b => fs.writeSync(1, b),
+        read: () => {
+            // stdin is only read once the program asks for it
+            if (input === null) {
+                input = fs.readFileSync(0);
+            }
+
+            return pos < input.length ? input[pos++] : -1;
+        },
+    });
+}
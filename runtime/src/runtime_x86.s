@@ -0,0 +1,447 @@
+    # x86-64 Linux runtime. It talks to the kernel directly, so a program links with a plain `ld` and no libc.
+    # Arguments come in %rdi, %rsi, %rdx and results go to %rax, like compiled Tiger functions; only caller-saved
+    # registers are used. Labels starting with .L cannot clash with Tiger identifiers.
+    #
+    # The heap is collected by a copying collector, like the one of runtime.c. Every object starts with two header
+    # words: the descriptor of a record, a string with 'p' for each field that holds a heap pointer and 'n' for the
+    # others, or 0 for the other objects, and then length<<3|kind, where the kind is 1 for a record, 2 for an array, 3
+    # for an array of pointers and 4 for a string. An object that has been copied has its new address in place of its
+    # length and kind. The objects are copied between two spaces taken from brk, a larger one replacing the space to
+    # copy to when the live objects fill more than half of it.
+    #
+    # The roots are the frames of the Tiger functions, chained from %rbp through the %rbp that each one saves at
+    # 0(%rbp). -8(%rbp) holds the pointer map of the function, the number of words of the frame that hold heap pointers
+    # followed by their offsets from %rbp, or 0. The runtime functions that allocate keep the heap pointers they were
+    # given in .Lgc_root0 and .Lgc_root1.
+    .data
+.Lgc_space:
+    .quad 0
+.Lgc_space_size:
+    .quad 0
+.Lgc_next:
+    .quad 0
+.Lgc_limit:
+    .quad 0
+.Lgc_other:
+    .quad 0
+.Lgc_other_size:
+    .quad 0
+.Lgc_size:
+    .quad 262144
+.Lgc_from:
+    .quad 0
+.Lgc_from_next:
+    .quad 0
+.Lgc_root0:
+    .quad 0
+.Lgc_root1:
+    .quad 0
+.Lout_of_memory:
+    .ascii "out of memory\n"
+
+    .text
+    .globl _start
+_start:
+    xorq %rbp, %rbp
+    call main
+    xorq %rdi, %rdi
+    movq $60, %rax
+    syscall
+
+    # .Lalloc returns a cleared object of %rdi bytes with the header %rsi
+.Lalloc:
+    movq .Lgc_next(%rip), %rax
+    leaq 16(%rax,%rdi), %rcx
+    cmpq .Lgc_limit(%rip), %rcx
+    jbe .Lalloc_fits
+    pushq %rdi
+    pushq %rsi
+    addq $16, %rdi
+    call .Lcollect
+    popq %rsi
+    popq %rdi
+    movq .Lgc_next(%rip), %rax
+    leaq 16(%rax,%rdi), %rcx
+.Lalloc_fits:
+    movq %rcx, .Lgc_next(%rip)
+    movq $0, (%rax)
+    movq %rsi, 8(%rax)
+    addq $16, %rax
+    pushq %rax
+    movq %rdi, %rcx
+    shrq $3, %rcx
+    movq %rax, %rdi
+    xorq %rax, %rax
+    rep stosq
+    popq %rax
+    ret
+.Lalloc_fail:
+    movq $1, %rax
+    movq $2, %rdi
+    leaq .Lout_of_memory(%rip), %rsi
+    movq $14, %rdx
+    syscall
+    movq $1, %rdi
+    movq $60, %rax
+    syscall
+
+    # .Lstring returns a cleared string of %rdi characters
+.Lstring:
+    movq %rdi, %rsi
+    shlq $3, %rsi
+    orq $4, %rsi
+    shrq $3, %rdi
+    incq %rdi
+    shlq $3, %rdi
+    jmp .Lalloc
+
+    # .Lcollect copies the live objects to the other space with room for %rdi more bytes, or to a new one when it is
+    # too small. %r11 is the next free word of the space copied to.
+.Lcollect:
+    pushq %rdi
+.Lcollect_again:
+    movq .Lgc_space(%rip), %rax
+    movq %rax, .Lgc_from(%rip)
+    movq .Lgc_next(%rip), %rax
+    movq %rax, .Lgc_from_next(%rip)
+    movq .Lgc_other(%rip), %r11
+    movq .Lgc_other_size(%rip), %rax
+    cmpq .Lgc_size(%rip), %rax
+    jae .Lcollect_spaces
+    xorq %rdi, %rdi
+    movq $12, %rax
+    syscall
+    movq .Lgc_size(%rip), %rdi
+    addq %rax, %rdi
+    pushq %rdi
+    movq $12, %rax
+    syscall
+    popq %rdi
+    cmpq %rdi, %rax
+    jb .Lalloc_fail
+    movq .Lgc_size(%rip), %rax
+    # syscall trashes %rcx and %r11
+    movq %rdi, %r11
+    subq %rax, %r11
+.Lcollect_spaces:
+    movq .Lgc_space(%rip), %rcx
+    movq %rcx, .Lgc_other(%rip)
+    movq .Lgc_space_size(%rip), %rcx
+    movq %rcx, .Lgc_other_size(%rip)
+    movq %r11, .Lgc_space(%rip)
+    movq %rax, .Lgc_space_size(%rip)
+    addq %r11, %rax
+    movq %rax, .Lgc_limit(%rip)
+
+    movq %rbp, %r8
+.Lcollect_frames:
+    testq %r8, %r8
+    jz .Lcollect_roots
+    movq -8(%r8), %r9
+    testq %r9, %r9
+    jz .Lcollect_next_frame
+    movq (%r9), %r10
+.Lcollect_frame_words:
+    testq %r10, %r10
+    jz .Lcollect_next_frame
+    addq $8, %r9
+    movq (%r9), %rdx
+    movq (%r8,%rdx), %rdi
+    call .Lforward
+    movq %rax, (%r8,%rdx)
+    decq %r10
+    jmp .Lcollect_frame_words
+.Lcollect_next_frame:
+    movq (%r8), %r8
+    jmp .Lcollect_frames
+
+.Lcollect_roots:
+    movq .Lgc_root0(%rip), %rdi
+    call .Lforward
+    movq %rax, .Lgc_root0(%rip)
+    movq .Lgc_root1(%rip), %rdi
+    call .Lforward
+    movq %rax, .Lgc_root1(%rip)
+
+    # the fields of the copied objects, which are copied in turn after them
+    movq .Lgc_space(%rip), %r8
+.Lcollect_scan:
+    cmpq %r11, %r8
+    jae .Lcollect_scanned
+    movq (%r8), %r9
+    movq 8(%r8), %r10
+    addq $16, %r8
+    movq %r10, %rdx
+    andq $7, %rdx
+    shrq $3, %r10
+    cmpq $4, %rdx
+    jne .Lcollect_fields
+    shrq $3, %r10
+    incq %r10
+    leaq (%r8,%r10,8), %r8
+    jmp .Lcollect_scan
+.Lcollect_fields:
+    testq %r10, %r10
+    jz .Lcollect_scan
+    cmpq $2, %rdx
+    je .Lcollect_skip
+    cmpq $1, %rdx
+    jne .Lcollect_pointer
+    cmpb $112, (%r9)
+    leaq 1(%r9), %r9
+    jne .Lcollect_skip
+.Lcollect_pointer:
+    movq (%r8), %rdi
+    call .Lforward
+    movq %rax, (%r8)
+.Lcollect_skip:
+    addq $8, %r8
+    decq %r10
+    jmp .Lcollect_fields
+
+.Lcollect_scanned:
+    movq %r11, .Lgc_next(%rip)
+    movq %r11, %rax
+    subq .Lgc_space(%rip), %rax
+    addq (%rsp), %rax
+    leaq (%rax,%rax), %rcx
+    movq .Lgc_size(%rip), %rdx
+    cmpq %rdx, %rcx
+    jbe .Lcollect_done
+.Lcollect_grow:
+    shlq $1, %rdx
+    cmpq %rdx, %rcx
+    ja .Lcollect_grow
+    movq %rdx, .Lgc_size(%rip)
+    cmpq .Lgc_space_size(%rip), %rax
+    ja .Lcollect_again
+.Lcollect_done:
+    popq %rdi
+    ret
+
+    # .Lforward copies the object %rdi points to, unless it is not an address of the space being evacuated or the
+    # object was copied already, and returns its new address in %rax. It only uses %rcx, %rsi and %rdi besides.
+.Lforward:
+    movq %rdi, %rax
+    cmpq .Lgc_from(%rip), %rdi
+    jbe .Lforward_done
+    cmpq .Lgc_from_next(%rip), %rdi
+    ja .Lforward_done
+    movq -8(%rax), %rcx
+    testq $7, %rcx
+    jnz .Lforward_copy
+    movq %rcx, %rax
+    ret
+.Lforward_copy:
+    movq %rcx, %rsi
+    andq $7, %rsi
+    shrq $3, %rcx
+    cmpq $4, %rsi
+    jne .Lforward_words
+    shrq $3, %rcx
+    incq %rcx
+.Lforward_words:
+    addq $2, %rcx
+    leaq -16(%rax), %rsi
+    movq %r11, %rdi
+    rep movsq
+    leaq 16(%r11), %rcx
+    movq %rdi, %r11
+    movq %rcx, -8(%rax)
+    movq %rcx, %rax
+.Lforward_done:
+    ret
+
+gcInitArray:
+    testq %rdi, %rdi
+    jns .LgcInitArray_size
+    xorq %rdi, %rdi
+.LgcInitArray_size:
+    pushq %rdi
+    pushq %rsi
+    pushq %rdx
+    movq $2, %rsi
+    testq %rdx, %rdx
+    jz .LgcInitArray_alloc
+    movq 8(%rsp), %rax
+    movq %rax, .Lgc_root0(%rip)
+    movq $3, %rsi
+.LgcInitArray_alloc:
+    movq %rdi, %rax
+    shlq $3, %rax
+    orq %rax, %rsi
+    shlq $3, %rdi
+    call .Lalloc
+    popq %rdx
+    popq %rsi
+    popq %rcx
+    testq %rdx, %rdx
+    jz .LgcInitArray_fill
+    movq .Lgc_root0(%rip), %rsi
+    movq $0, .Lgc_root0(%rip)
+.LgcInitArray_fill:
+    movq %rax, %rdx
+.LgcInitArray_loop:
+    testq %rcx, %rcx
+    jle .LgcInitArray_done
+    movq %rsi, (%rdx)
+    addq $8, %rdx
+    decq %rcx
+    jmp .LgcInitArray_loop
+.LgcInitArray_done:
+    ret
+
+gcAllocRecord:
+    pushq %rdi
+    call size
+    movq %rax, %rsi
+    shlq $3, %rsi
+    orq $1, %rsi
+    leaq (,%rax,8), %rdi
+    call .Lalloc
+    popq %rdi
+    movq %rdi, -16(%rax)
+    ret
+
+printi:
+    subq $32, %rsp
+    movq %rdi, %rax
+    movq %rdi, %r8
+    leaq 32(%rsp), %rsi
+    movq $10, %rcx
+    testq %rax, %rax
+    jns .Lprinti_loop
+    negq %rax
+.Lprinti_loop:
+    xorq %rdx, %rdx
+    divq %rcx
+    addb $48, %dl
+    decq %rsi
+    movb %dl, (%rsi)
+    testq %rax, %rax
+    jnz .Lprinti_loop
+    testq %r8, %r8
+    jns .Lprinti_write
+    decq %rsi
+    movb $45, (%rsi)
+.Lprinti_write:
+    leaq 32(%rsp), %rdx
+    subq %rsi, %rdx
+    movq $1, %rdi
+    movq $1, %rax
+    syscall
+    addq $32, %rsp
+    ret
+
+print:
+    call size
+    movq %rdi, %rsi
+    movq %rax, %rdx
+    movq $1, %rdi
+    movq $1, %rax
+    syscall
+    ret
+
+flush:
+    ret
+
+size:
+    xorq %rax, %rax
+.Lsize_loop:
+    cmpb $0, (%rdi,%rax)
+    je .Lsize_done
+    incq %rax
+    jmp .Lsize_loop
+.Lsize_done:
+    ret
+
+stringCompare:
+    movzbq (%rdi), %rax
+    movzbq (%rsi), %rcx
+    cmpq %rcx, %rax
+    jne .LstringCompare_done
+    testq %rax, %rax
+    jz .LstringCompare_done
+    incq %rdi
+    incq %rsi
+    jmp stringCompare
+.LstringCompare_done:
+    subq %rcx, %rax
+    ret
+
+ord:
+    movzbq (%rdi), %rax
+    testq %rax, %rax
+    jnz .Lord_done
+    movq $-1, %rax
+.Lord_done:
+    ret
+
+getchar:
+    movq $1, %rdi
+    call .Lstring
+    pushq %rax
+    movq %rax, %rsi
+    xorq %rdi, %rdi
+    movq $1, %rdx
+    xorq %rax, %rax
+    syscall
+    popq %rax
+    ret
+
+chr:
+    pushq %rdi
+    movq $1, %rdi
+    call .Lstring
+    popq %rdi
+    movb %dil, (%rax)
+    ret
+
+not:
+    xorq %rax, %rax
+    testq %rdi, %rdi
+    sete %al
+    ret
+
+exit:
+    movq $60, %rax
+    syscall
+
+substring:
+    movq %rdi, .Lgc_root0(%rip)
+    pushq %rsi
+    pushq %rdx
+    movq %rdx, %rdi
+    call .Lstring
+    popq %rcx
+    popq %rsi
+    addq .Lgc_root0(%rip), %rsi
+    movq $0, .Lgc_root0(%rip)
+    movq %rax, %rdi
+    rep movsb
+    ret
+
+concat:
+    movq %rdi, .Lgc_root0(%rip)
+    movq %rsi, .Lgc_root1(%rip)
+    call size
+    pushq %rax
+    movq .Lgc_root1(%rip), %rdi
+    call size
+    pushq %rax
+    addq 8(%rsp), %rax
+    movq %rax, %rdi
+    call .Lstring
+    pushq %rax
+    movq %rax, %rdi
+    movq .Lgc_root0(%rip), %rsi
+    movq 16(%rsp), %rcx
+    rep movsb
+    movq .Lgc_root1(%rip), %rsi
+    movq 8(%rsp), %rcx
+    rep movsb
+    movq $0, .Lgc_root0(%rip)
+    movq $0, .Lgc_root1(%rip)
+    popq %rax
+    addq $16, %rsp
+    ret
@@ -0,0 +1,402 @@
+package semant
+
+import (
+	"fmt"
+
+	"tiger/ir"
+	"tiger/syntax"
+)
+
+// A class declaration is a type declaration, translated in three steps with the batch of types it is in.
+// declareClasses lays out the objects and declares the methods along with the types, so that the batch and the
+// declarations after it can use them. transClassAttrs translates the initializers of the attributes, then
+// transClassMethods the methods, which can use the declarations before the batch.
+//
+// Methods reach the variables of the let that declares their class through the static link stored in the object, like
+// nested functions do through their frame. An object of a class declared in a function must therefore not be used
+// once that function has returned.
+
+// classOf returns the class declared by decl and its body.
+func (s *Semant) classOf(decl *syntax.TypeDecl) (*ClassSemantTy, *syntax.ClassTy) {
+	ty, err := s.tenv.Look(decl.TyName)
+	if err != nil {
+		panic("class must be declared")
+	}
+
+	return ty.(*ClassSemantTy), decl.Ty.(*syntax.ClassTy)
+}
+
+// declareClasses resolves the super classes of the classes declared by a let and lays out their objects, super
+// classes first.
+func (s *Semant) declareClasses(level *Level, decls []*syntax.TypeDecl) error {
+	for _, decl := range decls {
+		class, ct := s.classOf(decl)
+		superTy, err := s.tenv.Look(ct.Super)
+		if err != nil {
+			if err == errSTNotFound {
+				return typeNotFoundErr(s.strs.Get(ct.Super), ct.SuperPos)
+			}
+
+			return err
+		}
+
+		superTy, err = s.actualTy(superTy, ct.SuperPos)
+		if err != nil {
+			return err
+		}
+
+		super, ok := superTy.(*ClassSemantTy)
+		if !ok {
+			return notAClassErr(s.strs.Get(ct.Super), ct.SuperPos)
+		}
+
+		class.super = super
+	}
+
+	laidOut := make(map[*ClassSemantTy]bool)
+	byClass := make(map[*ClassSemantTy]*syntax.TypeDecl)
+	for _, decl := range decls {
+		class, _ := s.classOf(decl)
+		byClass[class] = decl
+	}
+
+	var layout func(decl *syntax.TypeDecl, depth int) error
+	layout = func(decl *syntax.TypeDecl, depth int) error {
+		class, ct := s.classOf(decl)
+		if laidOut[class] {
+			return nil
+		}
+
+		if depth > len(decls) {
+			return cyclicInheritanceErr(class.name, ct.SuperPos)
+		}
+
+		if superDecl, ok := byClass[class.super]; ok {
+			if err := layout(superDecl, depth+1); err != nil {
+				return err
+			}
+		}
+
+		laidOut[class] = true
+		return s.layoutClass(level, class, ct)
+	}
+
+	for _, decl := range decls {
+		if err := layout(decl, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// layoutClass places the attributes of the class after those of its super class, and its methods in the vtable of
+// its super class, where they replace the methods they override. The attributes declared with a type get it here.
+func (s *Semant) layoutClass(level *Level, class *ClassSemantTy, ct *syntax.ClassTy) error {
+	super := class.super
+	class.link = super.size
+	class.size = super.size + 1 + int32(len(ct.Attrs))
+	class.Attrs = append([]*ClassAttr{}, super.Attrs...)
+	for i, decl := range ct.Attrs {
+		if class.Attr(decl.Name) != nil {
+			return duplicateAttrErr(s.strs.Get(decl.Name), class.name, decl.Pos)
+		}
+
+		attr := &ClassAttr{
+			Name:  decl.Name,
+			index: class.link + 1 + int32(i),
+		}
+
+		if decl.Typ != 0 {
+			ty, err := s.tenv.Look(decl.Typ)
+			if err != nil {
+				if err == errSTNotFound {
+					return typeNotFoundErr(s.strs.Get(decl.Typ), decl.Pos)
+				}
+
+				return err
+			}
+
+			attr.Ty, err = s.actualTy(ty, decl.Pos)
+			if err != nil {
+				return err
+			}
+		}
+
+		class.Attrs = append(class.Attrs, attr)
+	}
+
+	class.Methods = append([]*ClassMethod{}, super.Methods...)
+	for i, decl := range ct.Methods {
+		for _, other := range ct.Methods[:i] {
+			if other.Name == decl.Name {
+				return duplicateMethodErr(s.strs.Get(decl.Name), class.name, decl.Pos)
+			}
+		}
+
+		fun, err := s.methodEntry(level, class, decl)
+		if err != nil {
+			return err
+		}
+
+		method := &ClassMethod{Name: decl.Name, class: class, Fun: fun}
+		overridden, index := class.method(decl.Name)
+		if overridden == nil {
+			class.Methods = append(class.Methods, method)
+			continue
+		}
+
+		if !sameSignature(overridden.Fun, fun) {
+			return invalidOverrideErr(s.strs.Get(decl.Name), class.name, decl.Pos)
+		}
+
+		class.Methods[index] = method
+	}
+
+	if len(class.Methods) > 0 {
+		labels := make([]ir.Label, 0, len(class.Methods))
+		for _, method := range class.Methods {
+			labels = append(labels, method.Fun.label)
+		}
+
+		class.vtable = s.translate.vtable(labels)
+	}
+
+	// new is a keyword, so no method of the class has the label of its initializer
+	label := s.tm.FuncLabel(fmt.Sprintf("%s_new", class.name))
+	class.init = &FunEntry{
+		level:   s.translate.NewLevel(level, label, []bool{true, true}),
+		label:   label,
+		formals: []SemantTy{class},
+		result:  &UnitSemantTy{},
+	}
+
+	return nil
+}
+
+// methodEntry declares a method of class. Its object is passed as the first parameter.
+func (s *Semant) methodEntry(level *Level, class *ClassSemantTy, decl *syntax.FuncDecl) (*FunEntry, error) {
+	var result SemantTy = &UnitSemantTy{}
+	if decl.ResultTy != 0 {
+		ty, err := s.tenv.Look(decl.ResultTy)
+		if err != nil {
+			if err == errSTNotFound {
+				return nil, typeNotFoundErr(s.strs.Get(decl.ResultTy), decl.ResultTyPos)
+			}
+
+			return nil, err
+		}
+
+		result, err = s.actualTy(ty, decl.Pos)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	formals := make([]SemantTy, 0, len(decl.Params))
+	es := []bool{true, true}
+	for _, param := range decl.Params {
+		ty, err := s.tenv.Look(param.Typ)
+		if err != nil {
+			if err == errSTNotFound {
+				return nil, typeNotFoundErr(s.strs.Get(param.Typ), param.Pos)
+			}
+
+			return nil, err
+		}
+
+		ty, err = s.actualTy(ty, param.Pos)
+		if err != nil {
+			return nil, err
+		}
+
+		formals = append(formals, ty)
+		es = append(es, *param.Escape)
+	}
+
+	// the classes of the same name in different scopes have methods of different labels
+	label := s.tm.FuncLabel(fmt.Sprintf("%s_%s", class.name, s.strs.Get(decl.Name)))
+	return &FunEntry{
+		level:   s.translate.NewLevel(level, label, es),
+		label:   label,
+		formals: formals,
+		result:  result,
+	}, nil
+}
+
+// sameSignature tells whether a method can override another: both take and return the same types.
+func sameSignature(f1, f2 *FunEntry) bool {
+	if len(f1.formals) != len(f2.formals) {
+		return false
+	}
+
+	same := func(ty1, ty2 SemantTy) bool {
+		return isSameType(ty1, ty2) && isSameType(ty2, ty1)
+	}
+
+	for i := range f1.formals {
+		if !same(f1.formals[i], f2.formals[i]) {
+			return false
+		}
+	}
+
+	return same(f1.result, f2.result)
+}
+
+// transClassAttrs translates the initializers of the attributes that the class declares into its initializer. They
+// are evaluated in the scope of the class declaration, where self is not defined.
+func (s *Semant) transClassAttrs(decl *syntax.TypeDecl, breakLabel ir.Label) error {
+	class, ct := s.classOf(decl)
+	level := class.init.level
+	attrs := class.Attrs[len(class.Attrs)-len(ct.Attrs):]
+	inits := make([]TransExp, 0, len(attrs))
+	for i, attr := range ct.Attrs {
+		exp, ty, err := s.transExp(level, attr.Init, breakLabel)
+		if err != nil {
+			return err
+		}
+
+		ty, err = s.actualTy(ty, attr.Init.ExpPos())
+		if err != nil {
+			return err
+		}
+
+		if attrs[i].Ty == nil {
+			if _, ok := ty.(*NilSemantTy); ok {
+				return nilWithoutTypeErr(attr.Init.Span())
+			}
+
+			attrs[i].Ty = ty
+		} else if !isSameType(attrs[i].Ty, ty) {
+			return varTypeMismatchErr(s.strs.Get(attr.Name), attrs[i].Ty, ty, attr.Init.Span(), attr.Pos)
+		}
+
+		inits = append(inits, exp)
+	}
+
+	s.translate.pointerVar(s.translate.Formals(level)[0], class)
+	s.translate.ProcEntryExit(level, s.translate.classInit(level, class, attrs, inits))
+	return nil
+}
+
+// transClassMethods translates the methods that the class declares. In a method, self is its object and the
+// attributes of the object can be used like variables, which its parameters and locals hide.
+func (s *Semant) transClassMethods(decl *syntax.TypeDecl, breakLabel ir.Label) error {
+	class, ct := s.classOf(decl)
+	for _, md := range ct.Methods {
+		method, _ := class.method(md.Name)
+		fun := method.Fun
+		formals := s.translate.Formals(fun.level)
+
+		s.venv.BeginScope()
+		for _, attr := range class.Attrs {
+			s.venv.Enter(attr.Name, &AttrEntry{class: class, self: formals[0]})
+		}
+
+		s.venv.Enter(s.strs.Symbol("self"), &VarEntry{Ty: class, access: formals[0]})
+		s.translate.pointerVar(formals[0], class)
+		for i, param := range md.Params {
+			s.venv.Enter(param.Name, &VarEntry{Ty: fun.formals[i], access: formals[i+1]})
+			s.translate.pointerVar(formals[i+1], fun.formals[i])
+		}
+
+		s.translate.pointerResult(fun.level, fun.result)
+		body, bTy, err := s.transExp(fun.level, md.Body, breakLabel)
+		s.venv.EndScope()
+		if err != nil {
+			return err
+		}
+
+		if !isSameType(fun.result, bTy) {
+			return mismatchTypeErr(fun.result, bTy, md.Body.Span())
+		}
+
+		s.translate.ProcEntryExit(fun.level, s.translate.methodBody(fun.level, class, body))
+	}
+
+	return nil
+}
+
+// transAttr translates the access to an attribute of an object of class.
+func (s *Semant) transAttr(object TransExp, class *ClassSemantTy, name syntax.Symbol, pos syntax.Pos) (TransExp, SemantTy, error) {
+	attr := class.Attr(name)
+	if attr == nil {
+		return nil, nil, fieldNotFoundErr(s.strs.Get(name), class.name, pos)
+	}
+
+	if attr.Ty == nil {
+		return nil, nil, attrBeforeClassErr(s.strs.Get(name), class.name, pos)
+	}
+
+	return s.translate.pointerLoad(s.translate.fieldVar(object, attr.index), attr.Ty), attr.Ty, nil
+}
+
+func (s *Semant) transNew(level *Level, e *syntax.NewExp) (TransExp, SemantTy, error) {
+	ty, err := s.tenv.Look(e.Ty)
+	if err != nil {
+		if err == errSTNotFound {
+			return nil, nil, typeNotFoundErr(s.strs.Get(e.Ty), e.Pos)
+		}
+
+		return nil, nil, err
+	}
+
+	ty, err = s.actualTy(ty, e.Pos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	class, ok := ty.(*ClassSemantTy)
+	if !ok {
+		return nil, nil, notAClassErr(s.strs.Get(e.Ty), e.Pos)
+	}
+
+	pointers := make([]bool, class.size)
+	for _, attr := range class.Attrs {
+		if attr.Ty == nil {
+			return nil, nil, attrBeforeClassErr(s.strs.Get(attr.Name), class.name, e.Pos)
+		}
+
+		pointers[attr.index] = isPointer(attr.Ty)
+	}
+
+	return s.translate.newObject(level, class, pointers), class, nil
+}
+
+func (s *Semant) transMethodCall(level *Level, e *syntax.MethodCallExp, breakLabel ir.Label) (TransExp, SemantTy, error) {
+	object, ty, err := s.transVar(level, e.Variable, breakLabel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	class, ok := ty.(*ClassSemantTy)
+	if !ok {
+		return nil, nil, notAClassErr(ty.TypeName(), e.Pos)
+	}
+
+	method, index := class.method(e.Method)
+	if method == nil {
+		return nil, nil, methodNotFoundErr(s.strs.Get(e.Method), class.name, e.Pos)
+	}
+
+	fun := method.Fun
+	if len(e.Args) != len(fun.formals) {
+		return nil, nil, mismatchNumberOfParameters(s.strs.Get(e.Method), e.Pos)
+	}
+
+	args := make([]TransExp, 0, len(e.Args))
+	for i, arg := range e.Args {
+		exp, ty, err := s.transExp(level, arg, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !isSameType(fun.formals[i], ty) {
+			return nil, nil, mismatchTypeErr(fun.formals[i], ty, arg.Span())
+		}
+
+		args = append(args, exp)
+	}
+
+	return s.translate.methodCall(object, index, args, isUnit(fun.result), isPointer(fun.result)), fun.result, nil
+}
@@ -1,4 +1,11 @@
-package main
+package semant
+
+import (
+	"math/rand"
+
+	"tiger/ir"
+	"tiger/syntax"
+)
 
 type FuncInfo struct {
 	name  string
@@ -69,7 +76,7 @@ type EnvEntry interface {
 }
 
 type VarEntry struct {
-	ty     SemantTy
+	Ty     SemantTy
 	access *TranslateAccess
 }
 
@@ -77,24 +84,37 @@ func (v *VarEntry) IsEnvEntry() {}
 
 type FunEntry struct {
 	level   *Level
-	label   Label
+	label   ir.Label
 	formals []SemantTy
 	result  SemantTy
 }
 
 func (v *FunEntry) IsEnvEntry() {}
 
-func InitBaseTypeEnv() *TypeST {
+// AttrEntry is an attribute of the object of a method, which the method can use like a variable.
+type AttrEntry struct {
+	class *ClassSemantTy
+	self  *TranslateAccess
+}
+
+func (v *AttrEntry) IsEnvEntry() {}
+
+func InitBaseTypeEnv(strs *syntax.Strings) *TypeST {
 	symbols := NewTypeST()
 	symbols.Enter(strs.Symbol("int"), &IntSemantTy{})
 	symbols.Enter(strs.Symbol("string"), &StringSemantTy{})
+	symbols.Enter(strs.Symbol("Object"), &ClassSemantTy{
+		name: "Object",
+		size: 1,
+		u:    rand.Int63(),
+	})
 	return symbols
 }
 
-func InitBaseVarEnv() *VarST {
+func InitBaseVarEnv(tm *ir.TempManagement) *VarST {
 	symbols := NewVarST()
 	for _, finfo := range baseFuncs {
-		symbols.Enter(strs.Symbol(finfo.name), &FunEntry{
+		symbols.Enter(tm.Strings().Symbol(finfo.name), &FunEntry{
 			formals: finfo.args,
 			result:  finfo.resTy,
 			level:   OutermostLevel,
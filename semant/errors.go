@@ -0,0 +1,146 @@
+package semant
+
+import (
+	"fmt"
+
+	"tiger/syntax"
+)
+
+// Semantic errors
+func mismatchTypeErr(expected, actual SemantTy, span syntax.Span) error {
+	return syntax.NewDiagnosticAt("E0101", span, "expected %s, but found %s", expected.TypeName(), actual.TypeName())
+}
+
+func invalidNumberOfRecordFieldErr(pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0102", pos, "invalid number of record fields when constructing new record")
+}
+
+func fieldNotFoundErr(field string, recordTy string, pos syntax.Pos) error {
+	if len(recordTy) > 0 {
+		return syntax.NewDiagnostic("E0103", pos, "field %s not found in record %s", field, recordTy)
+	}
+
+	return syntax.NewDiagnostic("E0103", pos, "field %s not found", field)
+}
+
+func undefinedVarErr(v string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0104", pos, "undefined variable %s", v)
+}
+
+// TODO: change this to "variable not found"
+func expectedVarButFoundFunErr(v string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0105", pos, "expected %s is a variable but found function", v)
+}
+
+func functionNotFoundErr(f string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0106", pos, "function not found %s", f)
+}
+
+func mismatchNumberOfParameters(f string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0107", pos, "function %s has a different number of params", f)
+}
+
+func typeNotFoundErr(ty string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0108", pos, "type %s not found", ty)
+}
+
+func typeMismatchWhenDeclErr(expected, got SemantTy, span syntax.Span) error {
+	return syntax.NewDiagnosticAt("E0109", span, "expected type %s, but expression has type %s", expected.TypeName(),
+		got.TypeName())
+}
+
+func duplicateRecordDefinition(pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0110", pos, "duplicate record definition")
+}
+
+func baseTypeNotFoundErr(base string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0111", pos, "base type not found %s", base)
+}
+
+func primitiveNotFoundErr(name string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0112", pos, "primitive %s is not defined by the runtime", name)
+}
+
+func nilWithoutTypeErr(span syntax.Span) error {
+	return syntax.NewDiagnosticAt("E0113", span, "cannot use nil here").WithHint("declare the type of the variable")
+}
+
+func invalidOperandErr(ty SemantTy, span syntax.Span) error {
+	return syntax.NewDiagnosticAt("E0114", span, "cannot compare values of type %s", ty.TypeName())
+}
+
+func resultMismatchErr(f string, expected, actual SemantTy, span syntax.Span, declPos syntax.Pos) error {
+	return syntax.NewDiagnosticAt("E0115", span, "function %s returns %s, but its body has type %s", f, expected.TypeName(),
+		actual.TypeName()).WithNote(declPos, "%s is declared here", f)
+}
+
+func varTypeMismatchErr(v string, expected, actual SemantTy, span syntax.Span, declPos syntax.Pos) error {
+	return syntax.NewDiagnosticAt("E0116", span, "expected type %s, but expression has type %s", expected.TypeName(),
+		actual.TypeName()).WithNote(declPos, "%s is declared with type %s here", v, expected.TypeName())
+}
+
+func duplicateTypeErr(ty string, pos, declPos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0117", pos, "type %s is declared twice in the same batch of types", ty).
+		WithNote(declPos, "%s is first declared here", ty)
+}
+
+func duplicateFuncErr(f string, pos, declPos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0118", pos, "function %s is declared twice in the same batch of functions", f).
+		WithNote(declPos, "%s is first declared here", f)
+}
+
+// Class errors
+func notAClassErr(ty string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0200", pos, "%s is not a class", ty)
+}
+
+func cyclicInheritanceErr(class string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0201", pos, "class %s inherits from itself", class)
+}
+
+func duplicateAttrErr(attr, class string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0202", pos, "attribute %s is already defined in class %s", attr, class)
+}
+
+func duplicateMethodErr(method, class string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0203", pos, "method %s is already defined in class %s", method, class)
+}
+
+func invalidOverrideErr(method, class string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0204", pos, "method %s of class %s does not have the type of the method it overrides",
+		method, class)
+}
+
+func methodNotFoundErr(method, class string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0205", pos, "method %s not found in class %s", method, class)
+}
+
+func attrBeforeClassErr(attr, class string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0206", pos, "attribute %s of class %s is used before the class is declared", attr, class)
+}
+
+// Module errors
+func moduleDeclErr(kind string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0300", pos, "%s declarations are not allowed in a module", kind)
+}
+
+func importCycleErr(path string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0301", pos, "module %s imports itself", path)
+}
+
+func moduleNotFoundErr(path string, err error, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0302", pos, "cannot read module %s: %v", path, err)
+}
+
+func moduleNameClashErr(path, other string, pos syntax.Pos) error {
+	return syntax.NewDiagnostic("E0304", pos, "module %s has the same name as module %s, their labels would clash",
+		path, other)
+}
+
+func invalidModuleNameErr(file string) error {
+	return &syntax.Diagnostic{
+		Severity: syntax.SeverityError,
+		Code:     "E0303",
+		Msg:      fmt.Sprintf("the name of module file %s is not a valid name", file),
+	}
+}
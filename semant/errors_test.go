@@ -0,0 +1,29 @@
+package semant
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/syntax"
+)
+
+func TestErrors_Report(t *testing.T) {
+	diags := syntax.NewDiagnostics()
+	require.False(t, diags.HasErrors())
+
+	inner := syntax.NewDiagnostics()
+	inner.Report(undefinedVarErr("a", syntax.Pos{}))
+	inner.Report(typeNotFoundErr("b", syntax.Pos{}))
+	diags.Report(inner)
+	diags.Report(errors.New("cannot read file"))
+
+	require.True(t, diags.HasErrors())
+	codes := make([]string, 0, len(diags.List()))
+	for _, diag := range diags.List() {
+		codes = append(codes, diag.Code)
+	}
+
+	require.Equal(t, []string{"E0104", "E0108", "E0000"}, codes)
+}
@@ -0,0 +1,108 @@
+package semant
+
+import (
+	"tiger/syntax"
+)
+
+// FindEscape marks the variables and parameters that are used, so that they live in the frame, and those that are
+// not, so that they can live in temps. It is a visitor of the AST, at the depth of the functions it is in.
+type FindEscape struct {
+	escapeEnv *EscapeST
+	depth     int
+}
+
+func NewFindEscape() *FindEscape {
+	return &FindEscape{escapeEnv: NewEscapeST()}
+}
+
+// at is the visitor of the nodes at depth.
+func (t *FindEscape) at(depth int) *FindEscape {
+	return &FindEscape{escapeEnv: t.escapeEnv, depth: depth}
+}
+
+func (t *FindEscape) Visit(node syntax.Node) syntax.Visitor {
+	switch n := node.(type) {
+	case *syntax.SimpleVar:
+		entry, err := t.escapeEnv.Look(n.Symbol)
+		if err == errSTNotFound {
+			return nil
+		}
+
+		*entry.escape = true
+		if entry.depth < t.depth {
+			t.escapeEnv.Replace(n.Symbol, entry)
+		}
+
+		return nil
+	case *syntax.LetExp:
+		t.escapeEnv.BeginScope()
+		t.transDecls(n.Decls)
+		syntax.Walk(t, n.Body)
+		t.escapeEnv.EndScope()
+
+		return nil
+	}
+
+	return t
+}
+
+// transDecls goes through the declarations of a let or of a module batch by batch, like the semantic analysis does:
+// a variable is entered after its initializer, and the bodies of a batch of functions, or the attributes and methods
+// of a batch of classes, see the declarations before the batch.
+func (t *FindEscape) transDecls(decls []syntax.Declaration) {
+	for _, batch := range declBatches(decls) {
+		for _, decl := range batch {
+			switch dt := decl.(type) {
+			case *syntax.VarDecl:
+				*dt.Escape = false
+				syntax.Walk(t, dt.Init)
+				entry := EscapeEntry{
+					depth:  t.depth + 1,
+					escape: dt.Escape,
+				}
+				t.escapeEnv.Enter(dt.Name, &entry)
+			case *syntax.FuncDecl:
+				t.transFunc(dt)
+			case *syntax.TypeDecl:
+				if ct, ok := dt.Ty.(*syntax.ClassTy); ok {
+					for _, attr := range ct.Attrs {
+						syntax.Walk(t.at(t.depth+1), attr.Init)
+					}
+				}
+			}
+		}
+
+		for _, decl := range batch {
+			if dt, ok := decl.(*syntax.TypeDecl); ok {
+				if ct, ok := dt.Ty.(*syntax.ClassTy); ok {
+					for _, method := range ct.Methods {
+						t.transFunc(method)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (t *FindEscape) transFunc(decl *syntax.FuncDecl) {
+	t.escapeEnv.BeginScope()
+	for _, param := range decl.Params {
+		entry := EscapeEntry{
+			depth:  t.depth + 1,
+			escape: param.Escape,
+		}
+		t.escapeEnv.Enter(param.Name, &entry)
+	}
+
+	syntax.Walk(t.at(t.depth+1), decl.Body)
+	t.escapeEnv.EndScope()
+}
+
+func (t *FindEscape) FindEscape(prog syntax.Exp) {
+	syntax.Walk(t, prog)
+}
+
+// FindEscapeModule goes through the declarations of a module like through those of a let.
+func (t *FindEscape) FindEscapeModule(decls []syntax.Declaration) {
+	t.transDecls(decls)
+}
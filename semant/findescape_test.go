@@ -0,0 +1,48 @@
+package semant
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/syntax"
+)
+
+func TestFindEscape_Vars(t *testing.T) {
+	t.Parallel()
+
+	src := `let var a := 1 var b := 2 var c := 3 in for i := 0 to 3 do (b; c[i]) end`
+	exp, err := syntax.NewParser(syntax.NewLexer("test.tig", bufio.NewReader(strings.NewReader(src))),
+		syntax.NewStrings()).Parse()
+	require.NoError(t, err)
+	NewFindEscape().FindEscape(exp)
+
+	var escapes []bool
+	for _, decl := range exp.(*syntax.LetExp).Decls {
+		escapes = append(escapes, *decl.(*syntax.VarDecl).Escape)
+	}
+
+	require.Equal(t, []bool{false, true, true}, escapes)
+}
+
+func TestFindEscape_Batches(t *testing.T) {
+	t.Parallel()
+
+	// f uses the first a, the second one is declared after it
+	src := `let var a := 1 function f(): int = a var a := 2 in f() end`
+	exp, err := syntax.NewParser(syntax.NewLexer("test.tig", bufio.NewReader(strings.NewReader(src))),
+		syntax.NewStrings()).Parse()
+	require.NoError(t, err)
+	NewFindEscape().FindEscape(exp)
+
+	var escapes []bool
+	for _, decl := range exp.(*syntax.LetExp).Decls {
+		if v, ok := decl.(*syntax.VarDecl); ok {
+			escapes = append(escapes, *v.Escape)
+		}
+	}
+
+	require.Equal(t, []bool{true, false}, escapes)
+}
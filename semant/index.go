@@ -0,0 +1,147 @@
+package semant
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"tiger/syntax"
+)
+
+// DefKind is the kind of name a declaration gives.
+type DefKind int
+
+const (
+	DefVar DefKind = iota
+	DefFunc
+	DefType
+)
+
+// Definition is a declaration of a variable, a function or a type. The position is that of the declaration, which
+// starts with a keyword for all but parameters. The entry is the *VarEntry or the *FunEntry of the name, or its
+// SemantTy for a type.
+type Definition struct {
+	Name  string
+	Kind  DefKind
+	Pos   syntax.Pos
+	Entry interface{}
+}
+
+// Detail describes the definition the way it is declared, with the types of the semantic analysis.
+func (d *Definition) Detail() string {
+	switch e := d.Entry.(type) {
+	case *VarEntry:
+		return fmt.Sprintf("var %s: %s", d.Name, e.Ty.TypeName())
+	case *FunEntry:
+		formals := make([]string, 0, len(e.formals))
+		for _, formal := range e.formals {
+			formals = append(formals, formal.TypeName())
+		}
+
+		detail := fmt.Sprintf("function %s(%s)", d.Name, strings.Join(formals, ", "))
+		if !isUnit(e.result) {
+			detail += ": " + e.result.TypeName()
+		}
+
+		return detail
+	case SemantTy:
+		return fmt.Sprintf("type %s = %s", d.Name, indexTypeName(e))
+	default:
+		return d.Name
+	}
+}
+
+// indexTypeName is the name of the type an alias stands for.
+func indexTypeName(ty SemantTy) string {
+	if v, ok := ty.(*NameSemantTy); ok && v.BaseTy != nil {
+		return indexTypeName(v.BaseTy)
+	}
+
+	return ty.TypeName()
+}
+
+// Index records the definitions that the semantic analysis goes through and the uses of the variables and the
+// functions, by the position of their identifier. Editors use it to go to definitions and to find references.
+type Index struct {
+	Defs    []*Definition
+	entries map[interface{}]*Definition
+	uses    map[syntax.Pos]*Definition
+}
+
+func NewIndex() *Index {
+	return &Index{
+		entries: make(map[interface{}]*Definition),
+		uses:    make(map[syntax.Pos]*Definition),
+	}
+}
+
+// define records a definition. The index is optional, a nil one records nothing.
+func (x *Index) define(name string, kind DefKind, pos syntax.Pos, entry interface{}) {
+	if x == nil {
+		return
+	}
+
+	def := &Definition{Name: name, Kind: kind, Pos: pos, Entry: entry}
+	x.Defs = append(x.Defs, def)
+	if kind != DefType {
+		x.entries[entry] = def
+	}
+}
+
+// use records that the identifier at pos names the variable or the function of entry.
+func (x *Index) use(pos syntax.Pos, entry interface{}) {
+	if x == nil {
+		return
+	}
+
+	if def, ok := x.entries[entry]; ok {
+		x.uses[pos] = def
+	}
+}
+
+// UseAt returns the definition that the identifier at pos names.
+func (x *Index) UseAt(pos syntax.Pos) (*Definition, bool) {
+	def, ok := x.uses[pos]
+	return def, ok
+}
+
+// Uses returns the position of the identifiers that name the definition, in the order of the source.
+func (x *Index) Uses(def *Definition) []syntax.Pos {
+	var uses []syntax.Pos
+	for pos, d := range x.uses {
+		if d == def {
+			uses = append(uses, pos)
+		}
+	}
+
+	sort.Slice(uses, func(i, j int) bool {
+		if uses[i].FileName != uses[j].FileName {
+			return uses[i].FileName < uses[j].FileName
+		}
+
+		return PosBefore(uses[i], uses[j])
+	})
+	return uses
+}
+
+// TypeAt returns the definition of the type name as seen from pos: types are not recorded by their uses, so this is
+// the last one declared before pos, or the first one when none is.
+func (x *Index) TypeAt(name string, pos syntax.Pos) *Definition {
+	var found *Definition
+	for _, def := range x.Defs {
+		if def.Kind != DefType || !strings.EqualFold(def.Name, name) {
+			continue
+		}
+
+		if found == nil || def.Pos.FileName == pos.FileName && !PosBefore(pos, def.Pos) {
+			found = def
+		}
+	}
+
+	return found
+}
+
+// PosBefore tells whether a is before b in the same file.
+func PosBefore(a, b syntax.Pos) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+}
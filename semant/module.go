@@ -0,0 +1,229 @@
+package semant
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tiger/ir"
+	"tiger/syntax"
+)
+
+// A module is a file of declarations, which programs and other modules import with import "path". Only types,
+// functions and primitives can be declared in a module: it has no frame, so there is nowhere to keep its variables or the static
+// link of the methods of its classes. The labels of its functions start with the name of the module, which is the
+// name of its file, so the modules of a program can be compiled as units of their own and linked together.
+
+// Module holds the types, functions and primitives declared by a module, in the order of their declarations.
+type Module struct {
+	name  string
+	types []moduleType
+	funcs []moduleFunc
+}
+
+type moduleType struct {
+	name syntax.Symbol
+	ty   SemantTy
+}
+
+type moduleFunc struct {
+	name  syntax.Symbol
+	entry *FunEntry
+}
+
+// Modules loads the modules imported by a program, each once.
+type Modules struct {
+	translate *Translate
+
+	// link is set when the functions of the imported modules are part of the output of the program. Otherwise they
+	// are compiled as units of their own and only their declarations are used.
+	link bool
+
+	modules map[string]*Module
+	loading map[string]bool
+
+	// files maps the names of the modules to their files. The labels of a module only have its name, so two files of
+	// the same name cannot be part of the same program.
+	files map[string]string
+
+	// Index records the names of the modules when it is set, see Semant
+	Index *Index
+}
+
+func NewModules(translate *Translate, link bool) *Modules {
+	return &Modules{
+		translate: translate,
+		link:      link,
+		modules:   make(map[string]*Module),
+		loading:   make(map[string]bool),
+		files:     make(map[string]string),
+	}
+}
+
+// ModuleName is the name of the module in file: its base name without the extension, which has to be a Tiger name.
+func ModuleName(file string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	for i := 0; i < len(name); i++ {
+		if !syntax.IsAlphaNumeric(name[i]) && !syntax.IsUnderscore(name[i]) {
+			return "", invalidModuleNameErr(file)
+		}
+	}
+
+	if len(name) == 0 || !syntax.IsLower(name[0]) && !syntax.IsUpper(name[0]) {
+		return "", invalidModuleNameErr(file)
+	}
+
+	return name, nil
+}
+
+// Load returns the module in the file at path, which is parsed and checked the first time it is imported.
+func (m *Modules) Load(path string, pos syntax.Pos) (*Module, error) {
+	path = filepath.Clean(path)
+	if module, ok := m.modules[path]; ok {
+		return module, nil
+	}
+
+	if m.loading[path] {
+		return nil, importCycleErr(path, pos)
+	}
+
+	name, err := ModuleName(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if other, ok := m.files[name]; ok && other != path {
+		return nil, moduleNameClashErr(path, other, pos)
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, moduleNotFoundErr(path, err, pos)
+	}
+
+	decls, err := syntax.NewParser(syntax.NewLexer(path, bufio.NewReader(bytes.NewReader(src))), m.translate.tm.Strings()).ParseModule()
+	if err != nil {
+		return nil, err
+	}
+
+	m.loading[path] = true
+	defer delete(m.loading, path)
+
+	// the fragments of a module that is not linked with the program are dropped once it is checked, and it must not
+	// share its record descriptors
+	translate := m.translate
+	if !m.link {
+		translate = translate.fork()
+	}
+
+	module, err := m.Check(translate, name, path, decls)
+	if err != nil {
+		return nil, err
+	}
+
+	m.modules[path] = module
+	return module, nil
+}
+
+// Check checks the declarations of the module name, read from file, and translates its functions with translate.
+func (m *Modules) Check(translate *Translate, name, file string, decls []syntax.Declaration) (*Module, error) {
+	m.files[name] = filepath.Clean(file)
+	diags := syntax.NewDiagnostics()
+	for _, decl := range decls {
+		switch v := decl.(type) {
+		case *syntax.VarDecl:
+			diags.Report(moduleDeclErr("variable", v.Pos))
+		case *syntax.TypeDecl:
+			if _, ok := v.Ty.(*syntax.ClassTy); ok {
+				diags.Report(moduleDeclErr("class", v.Pos))
+			}
+		}
+	}
+
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	NewFindEscape().FindEscapeModule(decls)
+
+	s := NewSemant(translate, InitBaseVarEnv(translate.tm), InitBaseTypeEnv(translate.tm.Strings()))
+	s.Modules, s.File, s.module, s.Index = m, file, name, m.Index
+	if hasErrorDecl(decls) {
+		s.broken++
+	}
+
+	level := &Level{
+		parent: OutermostLevel,
+		u:      rand.Int63(),
+		module: true,
+	}
+
+	if _, err := s.transDecls(level, decls, translate.tm.NewLabel()); err != nil {
+		s.report(err)
+	}
+
+	if s.Diags.HasErrors() {
+		return nil, s.Diags
+	}
+
+	module := &Module{name: name}
+	for _, decl := range decls {
+		switch v := decl.(type) {
+		case *syntax.TypeDecl:
+			ty, _ := s.tenv.Look(v.TyName)
+			module.types = append(module.types, moduleType{name: v.TyName, ty: ty})
+		case *syntax.FuncDecl:
+			entry, _ := s.venv.Look(v.Name)
+			module.funcs = append(module.funcs, moduleFunc{name: v.Name, entry: entry.(*FunEntry)})
+		case *syntax.PrimitiveDecl:
+			entry, _ := s.venv.Look(v.Name)
+			module.funcs = append(module.funcs, moduleFunc{name: v.Name, entry: entry.(*FunEntry)})
+		}
+	}
+
+	return module, nil
+}
+
+// importModule enters the types and functions of the module imported by decl. The path of the module is relative to
+// the file being checked.
+func (s *Semant) importModule(decl *syntax.ImportDecl) error {
+	path := decl.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(s.File), path)
+	}
+
+	module, err := s.Modules.Load(path, decl.Pos)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range module.types {
+		s.tenv.Enter(t.name, t.ty)
+	}
+
+	for _, f := range module.funcs {
+		s.venv.Enter(f.name, f.entry)
+	}
+
+	return nil
+}
+
+// funcLabel is the label of the function of decl, declared at level. The functions at the top of a module are
+// qualified by the module, which the units that import it link against. The labels of the others are unique, and
+// decl keeps its label from the first pass to the second one.
+func (s *Semant) funcLabel(level *Level, decl *syntax.FuncDecl) ir.Label {
+	if label, ok := s.funcLabels[decl]; ok {
+		return label
+	}
+
+	label := s.tm.FuncLabel(s.strs.Get(decl.Name))
+	if level.module {
+		label = s.tm.ModuleLabel(s.module, s.strs.Get(decl.Name))
+	}
+
+	s.funcLabels[decl] = label
+	return label
+}
@@ -0,0 +1,1246 @@
+// Package semant checks the types of a program or of a module and translates it to the IR, along with the modules it
+// imports.
+package semant
+
+import (
+	"errors"
+	"math/rand"
+
+	"tiger/ir"
+	"tiger/syntax"
+)
+
+const (
+	IgnorePass = iota
+	FirstPass
+	SecondPass
+)
+
+// errReported is returned by the translation of an expression that uses a declaration with an error. The error of
+// the declaration has already been reported, so report ignores this one.
+var errReported = errors.New("error already reported")
+
+type Semant struct {
+	tm        *ir.TempManagement
+	strs      *syntax.Strings
+	venv      *VarST
+	tenv      *TypeST
+	translate *Translate
+
+	// Modules loads the modules imported by file, which is the name of the module being checked or empty for a
+	// program
+	Modules *Modules
+	File    string
+	module  string
+
+	// Diags collects the errors, so that a declaration or an expression of a sequence that has an error does not stop
+	// the analysis of the others
+	Diags *syntax.Diagnostics
+
+	// broken is the number of enclosing lets with a declaration that did not parse
+	broken int
+
+	// Index records the definitions and the uses of names when it is set, for editors
+	Index *Index
+
+	// funcLabels are the labels of the functions declared so far
+	funcLabels map[*syntax.FuncDecl]ir.Label
+}
+
+func NewSemant(trans *Translate, vent *VarST, tenv *TypeST) *Semant {
+	return &Semant{
+		tm:         trans.tm,
+		strs:       trans.tm.Strings(),
+		venv:       vent,
+		tenv:       tenv,
+		translate:  trans,
+		Modules:    NewModules(trans, true),
+		Diags:      syntax.NewDiagnostics(),
+		funcLabels: make(map[*syntax.FuncDecl]ir.Label),
+	}
+}
+
+// report adds err to the diagnostics. In the scope of a declaration that did not parse, a name that is not found is
+// assumed to be declared by it, so the error is not reported.
+func (s *Semant) report(err error) {
+	if err == errReported {
+		return
+	}
+
+	var diag *syntax.Diagnostic
+	if s.broken > 0 && errors.As(err, &diag) {
+		switch diag.Code {
+		case "E0104", "E0106", "E0108", "E0111":
+			return
+		}
+	}
+
+	s.Diags.Report(err)
+}
+
+// hasErrorDecl tells whether one of decls did not parse.
+func hasErrorDecl(decls []syntax.Declaration) bool {
+	for _, decl := range decls {
+		if _, ok := decl.(*syntax.ErrorDecl); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Semant) TransProg(exp syntax.Exp) ([]ir.Frag, error) {
+	mainLevel := Level{
+		parent: OutermostLevel,
+		frame:  s.translate.frameFactory(s.tm, s.tm.NamedLabel("main"), []bool{true}),
+		u:      rand.Int63(),
+	}
+
+	if s.translate.Debug {
+		mainLevel.debug = &ir.ProcDebug{Name: "main", Pos: exp.Span().Start}
+	}
+
+	progExp, _, err := s.transStm(&mainLevel, exp, s.tm.NewLabel())
+	if err != nil {
+		s.report(err)
+	}
+
+	if s.Diags.HasErrors() {
+		return nil, s.Diags
+	}
+
+	s.translate.ProcEntryExit(&mainLevel, progExp)
+
+	return s.translate.frags, nil
+}
+
+// transStm translates exp as a statement of the source, one that a debugger stops at.
+func (s *Semant) transStm(level *Level, exp syntax.Exp, breakLabel ir.Label) (TransExp, SemantTy, error) {
+	e, ty, err := s.transExp(level, exp, breakLabel)
+	if err != nil {
+		return e, ty, err
+	}
+
+	return s.translate.line(exp.Span().Start, e), ty, nil
+}
+
+// lookTy returns the actual type named name.
+func (s *Semant) lookTy(name syntax.Symbol, pos syntax.Pos) (SemantTy, error) {
+	ty, err := s.tenv.Look(name)
+	if err != nil {
+		if err == errSTNotFound {
+			return nil, typeNotFoundErr(s.strs.Get(name), pos)
+		}
+
+		return nil, err
+	}
+
+	return s.actualTy(ty, pos)
+}
+
+// TODO: refine this one
+func (s *Semant) actualTy(ty SemantTy, pos syntax.Pos) (SemantTy, error) {
+	switch v := ty.(type) {
+	case *NameSemantTy:
+		if v.BaseTy == nil {
+			return nil, typeNotFoundErr(v.name, pos)
+		}
+
+		return s.actualTy(v.BaseTy, pos)
+	case *ErrorSemantTy:
+		return nil, errReported
+	case *ArrSemantTy:
+		baseTy, err := s.actualTy(v.BaseTy, pos)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ArrSemantTy{BaseTy: baseTy, u: v.u}, nil
+	default:
+		return ty, nil
+	}
+}
+
+func (s *Semant) transVar(level *Level, variable syntax.Var, breakLabel ir.Label) (TransExp, SemantTy, error) {
+	switch v := variable.(type) {
+	case *syntax.SimpleVar:
+		entry, err := s.venv.Look(v.Symbol)
+		if err != nil {
+			if err == errSTNotFound {
+				return nil, nil, undefinedVarErr(s.strs.Get(v.Symbol), v.Pos)
+			}
+
+			return nil, nil, err
+		}
+
+		if attr, ok := entry.(*AttrEntry); ok {
+			self := s.translate.pointerLoad(s.translate.simpleVar(level, attr.self), attr.class)
+			return s.transAttr(self, attr.class, v.Symbol, v.Pos)
+		}
+
+		e, ok := entry.(*VarEntry)
+		if !ok {
+			return nil, nil, expectedVarButFoundFunErr(s.strs.Get(v.Symbol), v.Pos)
+		}
+
+		s.Index.use(v.Pos, e)
+
+		sTy, err := s.actualTy(e.Ty, v.Pos)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return s.translate.pointerLoad(s.translate.simpleVar(level, e.access), sTy), sTy, nil
+
+	case *syntax.FieldVar:
+		e1, ty1, err := s.transVar(level, v.Variable, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if class, ok := ty1.(*ClassSemantTy); ok {
+			return s.transAttr(e1, class, v.Field, v.Pos)
+		}
+
+		recordTy, ok := ty1.(*RecordSemantTy)
+		if !ok {
+			return nil, nil, mismatchTypeErr(&RecordSemantTy{}, ty1, v.Variable.Span())
+		}
+
+		for i, field := range recordTy.Symbols {
+			if field == v.Field {
+				semantTy, err := s.tenv.Look(recordTy.Types[i])
+				if err != nil {
+					if err == errSTNotFound {
+						return nil, nil, typeNotFoundErr(s.strs.Get(recordTy.Types[i]), syntax.Pos{})
+					}
+
+					return nil, nil, err
+				}
+
+				aTy, err := s.actualTy(semantTy, syntax.Pos{})
+				if err != nil {
+					return nil, nil, err
+				}
+
+				return s.translate.pointerLoad(s.translate.fieldVar(e1, int32(i)), aTy), aTy, nil
+			}
+		}
+
+		return nil, nil, fieldNotFoundErr(s.strs.Get(v.Field), "", v.Pos)
+
+	case *syntax.SubscriptionVar:
+		ve, ty, err := s.transVar(level, v.Variable, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		arrTy, ok := ty.(*ArrSemantTy)
+		if !ok {
+			return nil, nil, mismatchTypeErr(&ArrSemantTy{}, ty, v.Variable.Span())
+		}
+
+		// 2. Check the expr is int or not
+		se, eTy, err := s.transExp(level, v.Exp, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		_, ok = eTy.(*IntSemantTy)
+		if !ok {
+			return nil, nil, mismatchTypeErr(&IntSemantTy{}, eTy, v.Exp.Span())
+		}
+
+		return s.translate.pointerLoad(s.translate.SubscriptVar(ve, se), arrTy.BaseTy), arrTy.BaseTy, nil
+	}
+
+	panic("invalid type")
+}
+
+// transExp the output SemantTy must be a real type, not an alias type
+func (s *Semant) transExp(level *Level, exp syntax.Exp, breakLabel ir.Label) (TransExp, SemantTy, error) {
+	switch v := exp.(type) {
+	case *syntax.OperExp:
+		le, leftTy, err := s.transExp(level, v.Left, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		re, rightTy, err := s.transExp(level, v.Right, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if v.Op.IsArith() {
+			if !isInt(leftTy) {
+				return nil, nil, mismatchTypeErr(&IntSemantTy{}, leftTy, v.Left.Span())
+			}
+
+			if !isInt(rightTy) {
+				return nil, nil, mismatchTypeErr(&IntSemantTy{}, rightTy, v.Right.Span())
+			}
+
+			return s.translate.BinOp(v.Op, le, re), &IntSemantTy{}, nil
+		}
+
+		if v.Op.IsEq() {
+			switch v1 := leftTy.(type) {
+			case *IntSemantTy:
+				if !isInt(rightTy) {
+					return nil, nil, mismatchTypeErr(&IntSemantTy{}, rightTy, v.Span())
+				}
+
+				return s.translate.RelOp(v.Op, le, re), &IntSemantTy{}, nil
+			case *StringSemantTy:
+				if !isString(rightTy) {
+					return nil, nil, mismatchTypeErr(&StringSemantTy{}, rightTy, v.Span())
+				}
+
+				return s.translate.stringOp(v.Op, le, re), &IntSemantTy{}, nil
+			case *RecordSemantTy:
+				if !isRecord(rightTy) {
+					if _, ok := rightTy.(*NilSemantTy); !ok {
+						return nil, nil, mismatchTypeErr(&RecordSemantTy{}, rightTy, v.Span())
+					}
+				}
+
+				return s.translate.RelOp(v.Op, le, re), &IntSemantTy{}, nil
+			case *ClassSemantTy:
+				if !isSameType(v1, rightTy) && !isSameType(rightTy, v1) {
+					return nil, nil, mismatchTypeErr(leftTy, rightTy, v.Span())
+				}
+
+				return s.translate.RelOp(v.Op, le, re), &IntSemantTy{}, nil
+			case *ArrSemantTy:
+				switch v2 := rightTy.(type) {
+				case *ArrSemantTy:
+					if !isSameType(v1.BaseTy, v2.BaseTy) {
+						return nil, nil, mismatchTypeErr(leftTy, rightTy, v.Span())
+					}
+
+					return s.translate.RelOp(v.Op, le, re), &IntSemantTy{}, nil
+				default:
+					return s.translate.RelOp(v.Op, le, re), nil, mismatchTypeErr(leftTy, rightTy, v.Span())
+				}
+			default:
+				return s.translate.RelOp(v.Op, le, re), nil, invalidOperandErr(leftTy, v.Span())
+			}
+		}
+
+		if v.Op.IsComp() {
+			switch leftTy.(type) {
+			case *IntSemantTy:
+				if !isInt(rightTy) {
+					return nil, nil, mismatchTypeErr(&IntSemantTy{}, rightTy, v.Span())
+				}
+
+				return s.translate.RelOp(v.Op, le, re), &IntSemantTy{}, nil
+			case *StringSemantTy:
+				if !isString(rightTy) {
+					return nil, nil, mismatchTypeErr(&StringSemantTy{}, rightTy, v.Span())
+				}
+
+				return s.translate.stringOp(v.Op, le, re), &IntSemantTy{}, nil
+			default:
+				return nil, nil, invalidOperandErr(leftTy, v.Span())
+			}
+		}
+
+		if v.Op.IsLogic() {
+			if !isInt(leftTy) {
+				return nil, nil, mismatchTypeErr(&IntSemantTy{}, leftTy, v.Left.Span())
+			}
+
+			if !isInt(rightTy) {
+				return nil, nil, mismatchTypeErr(&IntSemantTy{}, rightTy, v.Right.Span())
+			}
+
+			return s.translate.logicOp(v.Op, le, re), &IntSemantTy{}, nil
+		}
+	case *syntax.StrExp:
+		return s.translate.strExp(v.Str), &StringSemantTy{}, nil
+
+	case *syntax.IntExp:
+		return s.translate.intExp(v.Val), &IntSemantTy{}, nil
+
+	case *syntax.UnitExp:
+		return s.translate.unitExp(), &UnitSemantTy{}, nil
+
+	case *syntax.NilExp:
+		return s.translate.nilExp(), &NilSemantTy{}, nil
+
+	case *syntax.ErrorExp:
+		return nil, nil, errReported
+
+	case *syntax.VarExp:
+		return s.transVar(level, v.Var, breakLabel)
+
+	case *syntax.ArrExp:
+		// the type can be a synonym of the array type
+		ty, err := s.lookTy(v.Typ, v.ExpPos())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		aty, ok := ty.(*ArrSemantTy)
+		if !ok {
+			return nil, nil, typeMismatchWhenDeclErr(&ArrSemantTy{}, ty, v.Span())
+		}
+
+		sEx, sTy, err := s.transExp(level, v.Size, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		_, ok = sTy.(*IntSemantTy)
+		if !ok {
+			return nil, nil, typeMismatchWhenDeclErr(&IntSemantTy{}, sTy, v.Size.Span())
+		}
+
+		iEx, iTy, err := s.transExp(level, v.Init, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !isSameType(aty.BaseTy, iTy) {
+			return nil, nil, typeMismatchWhenDeclErr(aty.BaseTy, iTy, v.Init.Span())
+		}
+
+		return s.translate.arrayExp(sEx, iEx, isPointer(aty.BaseTy)), &ArrSemantTy{
+			BaseTy: aty.BaseTy,
+			u:      aty.u,
+		}, nil
+
+	case *syntax.SequenceExp:
+		return s.transSeq(level, v.Exps, breakLabel)
+
+	case *syntax.LetExp:
+		if hasErrorDecl(v.Decls) {
+			s.broken++
+			defer func() { s.broken-- }()
+		}
+
+		vars := s.translate.debugVars(level)
+		vExps, err := s.transDecls(level, v.Decls, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		bodyExp, ty, err := s.transStm(level, v.Body, breakLabel)
+		if err != nil {
+			if s.broken > 0 {
+				// report the error in the scope of the declarations
+				s.report(err)
+				return nil, nil, errReported
+			}
+
+			return nil, nil, err
+		}
+
+		s.translate.endScope(level, vars, v.Span().End)
+		return s.translate.letExp(vExps, bodyExp), ty, nil
+
+	case *syntax.AssignExp:
+		vex, vTy, err := s.transVar(level, v.Variable, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		actualTy, err := s.actualTy(vTy, v.Variable.VarPos())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		eex, eTy, err := s.transExp(level, v.Exp, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !isSameType(actualTy, eTy) {
+			return nil, nil, mismatchTypeErr(vTy, eTy, v.Exp.Span())
+		}
+
+		return s.translate.assign(vex, eex), &UnitSemantTy{}, nil
+
+	case *syntax.ForExp:
+		fEx, fTy, err := s.transExp(level, v.From, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tEx, tTy, err := s.transExp(level, v.To, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !isSameType(fTy, &IntSemantTy{}) {
+			return nil, nil, mismatchTypeErr(&IntSemantTy{}, fTy, v.From.Span())
+		}
+
+		if !isSameType(tTy, &IntSemantTy{}) {
+			return nil, nil, mismatchTypeErr(&IntSemantTy{}, tTy, v.To.Span())
+		}
+
+		s.venv.BeginScope()
+
+		vars := s.translate.debugVars(level)
+		acc := s.translate.AllocLocal(level, true)
+		entry := &VarEntry{
+			Ty:     &IntSemantTy{},
+			access: acc,
+		}
+		s.venv.Enter(v.Sym, entry)
+		s.Index.define(s.strs.Get(v.Sym), DefVar, v.Pos, entry)
+		s.translate.debugVar(acc, s.strs.Get(v.Sym), entry.Ty, v.Pos)
+
+		doneLabel := s.tm.NewLabel()
+		bEx, bTy, err := s.transStm(level, v.Body, doneLabel)
+		if err != nil {
+			s.venv.EndScope()
+			return nil, nil, err
+		}
+
+		if !isSameType(bTy, &UnitSemantTy{}) {
+			s.venv.EndScope()
+			return nil, nil, mismatchTypeErr(&UnitSemantTy{}, bTy, v.Body.Span())
+		}
+
+		s.venv.EndScope()
+		s.translate.endScope(level, vars, v.Span().End)
+
+		// the test of each round is the comparison with the bound, where the variable is already set
+		return s.translate.forLoop(level, v.To.Span().Start, acc, fEx, tEx, bEx, doneLabel), &UnitSemantTy{}, nil
+
+	case *syntax.IfExp:
+		ifEx, pTy, err := s.transExp(level, v.Predicate, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		_, ok := pTy.(*IntSemantTy)
+		if !ok {
+			return nil, nil, mismatchTypeErr(&IntSemantTy{}, pTy, v.Predicate.Span())
+		}
+
+		thenEx, tTy, err := s.transStm(level, v.Then, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var elseEx TransExp
+		if v.Els != nil {
+			var eTy SemantTy
+			elseEx, eTy, err = s.transStm(level, v.Els, breakLabel)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if !isSameType(tTy, eTy) {
+				// the else branch can be of a super class of the then branch
+				if !isSameType(eTy, tTy) {
+					return nil, nil, mismatchTypeErr(tTy, eTy, v.Els.Span())
+				}
+
+				tTy = eTy
+			}
+		} else {
+			if _, ok := tTy.(*UnitSemantTy); !ok {
+				return nil, nil, mismatchTypeErr(tTy, &UnitSemantTy{}, v.Then.Span())
+			}
+		}
+
+		return s.translate.ifElse(ifEx, thenEx, elseEx), tTy, nil
+
+	case *syntax.WhileExp:
+		pex, tTy, err := s.transStm(level, v.Pred, breakLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		_, ok := tTy.(*IntSemantTy)
+		if !ok {
+			return nil, nil, mismatchTypeErr(&IntSemantTy{}, tTy, v.Pred.Span())
+		}
+
+		doneLabel := s.tm.NewLabel()
+		bex, bTy, err := s.transStm(level, v.Body, doneLabel)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		_, ok = bTy.(*UnitSemantTy)
+		if !ok {
+			return nil, nil, mismatchTypeErr(&UnitSemantTy{}, bTy, v.Body.Span())
+		}
+
+		return s.translate.whileLoop(pex, bex, doneLabel), &UnitSemantTy{}, nil
+
+	case *syntax.CallExp:
+		entry, err := s.venv.Look(v.Function)
+		if err != nil {
+			if err == errSTNotFound {
+				return nil, nil, functionNotFoundErr(s.strs.Get(v.Function), v.Pos)
+			}
+
+			return nil, nil, err
+		}
+
+		fEntry, ok := entry.(*FunEntry)
+		if !ok {
+			return nil, nil, functionNotFoundErr(s.strs.Get(v.Function), v.Pos)
+		}
+
+		if _, ok := fEntry.result.(*ErrorSemantTy); ok {
+			return nil, nil, errReported
+		}
+
+		s.Index.use(v.Pos, fEntry)
+
+		if len(v.Args) != len(fEntry.formals) {
+			return nil, nil, mismatchNumberOfParameters(s.strs.Get(v.Function), v.Pos)
+		}
+
+		args := make([]TransExp, 0, len(v.Args))
+		for i, arg := range v.Args {
+			exp, ty, err := s.transExp(level, arg, breakLabel)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			actualTy, err := s.actualTy(fEntry.formals[i], syntax.Pos{})
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if !isSameType(actualTy, ty) {
+				return nil, nil, mismatchTypeErr(actualTy, ty, arg.Span())
+			}
+
+			args = append(args, exp)
+		}
+
+		if _, ok := fEntry.result.(*NilSemantTy); ok {
+			return s.translate.call(level, fEntry.level, fEntry.label, args, true, false), fEntry.result, nil
+		}
+
+		return s.translate.call(level, fEntry.level, fEntry.label, args, false, isPointer(fEntry.result)), fEntry.result, nil
+
+	case *syntax.MethodCallExp:
+		return s.transMethodCall(level, v, breakLabel)
+
+	case *syntax.NewExp:
+		return s.transNew(level, v)
+
+	case *syntax.BreakExp:
+		return s.translate.breakStm(breakLabel), &UnitSemantTy{}, nil
+
+	case *syntax.RecordExp:
+		// the type can be a synonym of the record type
+		tTy, err := s.lookTy(v.Ty, v.Pos)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ty, ok := tTy.(*RecordSemantTy)
+		if !ok {
+			return nil, nil, mismatchTypeErr(&RecordSemantTy{}, tTy, v.Span())
+		}
+
+		if len(v.Fields) != len(ty.Types) {
+			return nil, nil, invalidNumberOfRecordFieldErr(v.Pos)
+		}
+
+		for i := range v.Fields {
+			for j := range v.Fields {
+				if i != j && v.Fields[i].Ident == v.Fields[j].Ident {
+					return nil, nil, duplicateRecordDefinition(v.Fields[i].Pos)
+				}
+			}
+		}
+
+		// fields are laid out in the order of the type, which is where field accesses look for them
+		exps := make([]TransExp, len(v.Fields))
+		pointers := make([]bool, len(v.Fields))
+		for _, field := range v.Fields {
+			idx := ty.HasField(field.Ident)
+			if idx == -1 {
+				return nil, nil, fieldNotFoundErr(s.strs.Get(field.Ident), s.strs.Get(v.Ty), field.Pos)
+			}
+
+			fe, eTy, err := s.transExp(level, field.Expr, breakLabel)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			semantTy, err := s.tenv.Look(ty.Types[idx])
+			if err != nil {
+				// Should not happen
+				return nil, nil, typeNotFoundErr(s.strs.Get(ty.Types[idx]), syntax.Pos{})
+			}
+
+			fTy, err := s.actualTy(semantTy, syntax.Pos{})
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if !isSameType(fTy, eTy) {
+				return nil, nil, mismatchTypeErr(fTy, eTy, field.Expr.Span())
+			}
+
+			exps[idx] = fe
+			pointers[idx] = isPointer(fTy)
+		}
+
+		return s.translate.record(exps, pointers), ty, nil
+	}
+
+	panic("unexpected expression type")
+}
+
+// transDecls translates the declarations of a let and returns the initializations of its variables. The consecutive
+// declarations of types, and those of functions, are batches that can refer to each other, like in this example
+// type intlist = {first: int, rest: intlist}. The other declarations only see those before them. The errors of the
+// declarations are reported and the variables whose declarations have one are entered by enterBrokenVar, so that the
+// others are still checked.
+func (s *Semant) transDecls(level *Level, decls []syntax.Declaration, breakLabel ir.Label) ([]TransExp, error) {
+	// Enter the declarations of the imported modules first, which the others can use and shadow
+	for _, decl := range decls {
+		if v, ok := decl.(*syntax.ImportDecl); ok {
+			if err := s.importModule(v); err != nil {
+				s.report(err)
+			}
+		}
+	}
+
+	vExps := make([]TransExp, 0)
+	for _, batch := range declBatches(decls) {
+		switch v1 := batch[0].(type) {
+		case *syntax.TypeDecl:
+			if err := s.transTypeDecls(level, batch, breakLabel); err != nil {
+				return nil, err
+			}
+		case *syntax.FuncDecl, *syntax.PrimitiveDecl:
+			s.transFuncDecls(level, batch, breakLabel)
+		case *syntax.VarDecl:
+			exp, err := s.transDec(level, v1, IgnorePass, breakLabel)
+			if err != nil {
+				s.report(err)
+				s.enterBrokenVar(level, v1)
+				continue
+			}
+
+			vExps = append(vExps, exp)
+		}
+	}
+
+	return vExps, nil
+}
+
+// enterBrokenVar enters the variable of decl, whose declaration has an error, so that its uses are still checked: with
+// its declared type when it has one that resolves, and with ErrorSemantTy, on which no use reports an error, otherwise.
+func (s *Semant) enterBrokenVar(level *Level, decl *syntax.VarDecl) {
+	if decl.Typ != 0 {
+		if entry, err := s.tenv.Look(decl.Typ); err == nil {
+			if ty, err := s.actualTy(entry.(SemantTy), decl.Pos); err == nil {
+				s.venv.Enter(decl.Name, &VarEntry{Ty: ty, access: s.translate.AllocLocal(level, *decl.Escape)})
+				return
+			}
+		}
+	}
+
+	s.venv.Enter(decl.Name, &VarEntry{Ty: &ErrorSemantTy{}})
+}
+
+// declBatches splits decls in the batches that are translated together: the consecutive declarations of types, the
+// consecutive declarations of functions and primitives, and each of the others alone.
+func declBatches(decls []syntax.Declaration) [][]syntax.Declaration {
+	kind := func(decl syntax.Declaration) int {
+		switch decl.(type) {
+		case *syntax.TypeDecl:
+			return 1
+		case *syntax.FuncDecl, *syntax.PrimitiveDecl:
+			return 2
+		default:
+			return 0
+		}
+	}
+
+	var batches [][]syntax.Declaration
+	for i := 0; i < len(decls); {
+		j := i + 1
+		for kind(decls[i]) != 0 && j < len(decls) && kind(decls[j]) == kind(decls[i]) {
+			j++
+		}
+
+		batches = append(batches, decls[i:j])
+		i = j
+	}
+
+	return batches
+}
+
+// transTypeDecls translates a batch of type declarations in two passes, then lays out the classes among them and
+// translates their attributes and methods, which can use the declarations before the batch. A name declared twice in
+// the batch is an error.
+func (s *Semant) transTypeDecls(level *Level, decls []syntax.Declaration, breakLabel ir.Label) error {
+	var classes []*syntax.TypeDecl
+	failed := make(map[syntax.Declaration]bool)
+	declared := make(map[syntax.Symbol]syntax.Pos)
+	for _, decl := range decls {
+		v1 := decl.(*syntax.TypeDecl)
+		if pos, ok := declared[v1.TyName]; ok {
+			s.report(duplicateTypeErr(s.strs.Get(v1.TyName), v1.Pos, pos))
+			failed[decl] = true
+			continue
+		}
+
+		declared[v1.TyName] = v1.Pos
+		if _, err := s.transDec(level, decl, FirstPass, breakLabel); err != nil {
+			s.report(err)
+			s.tenv.Enter(v1.TyName, &ErrorSemantTy{})
+			failed[decl] = true
+		}
+	}
+
+	for _, decl := range decls {
+		v1 := decl.(*syntax.TypeDecl)
+		if failed[decl] {
+			continue
+		}
+
+		if _, err := s.transDec(level, decl, SecondPass, breakLabel); err != nil {
+			s.report(err)
+			s.tenv.Replace(v1.TyName, &ErrorSemantTy{})
+			continue
+		}
+
+		if _, ok := v1.Ty.(*syntax.ClassTy); ok {
+			classes = append(classes, v1)
+		}
+	}
+
+	if err := s.declareClasses(level, classes); err != nil {
+		return err
+	}
+
+	for _, decl := range classes {
+		if err := s.transClassAttrs(decl, breakLabel); err != nil {
+			s.report(err)
+		}
+	}
+
+	for _, decl := range classes {
+		if err := s.transClassMethods(decl, breakLabel); err != nil {
+			s.report(err)
+		}
+	}
+
+	return nil
+}
+
+// funcDeclName is the name of a declaration of a function or a primitive and where it is.
+func funcDeclName(decl syntax.Declaration) (syntax.Symbol, syntax.Pos) {
+	if v, ok := decl.(*syntax.PrimitiveDecl); ok {
+		return v.Name, v.Pos
+	}
+
+	v := decl.(*syntax.FuncDecl)
+	return v.Name, v.Pos
+}
+
+// transFuncDecls translates a batch of function declarations: their types first, along with the primitives, then
+// their bodies, which can call each other. A name declared twice in the batch is an error.
+func (s *Semant) transFuncDecls(level *Level, decls []syntax.Declaration, breakLabel ir.Label) {
+	failed := make(map[syntax.Declaration]bool)
+	declared := make(map[syntax.Symbol]syntax.Pos)
+	for _, decl := range decls {
+		name, pos := funcDeclName(decl)
+		if first, ok := declared[name]; ok {
+			s.report(duplicateFuncErr(s.strs.Get(name), pos, first))
+			failed[decl] = true
+			continue
+		}
+
+		declared[name] = pos
+		switch v1 := decl.(type) {
+		case *syntax.FuncDecl:
+			if _, err := s.transDec(level, decl, FirstPass, breakLabel); err != nil {
+				s.report(err)
+				s.venv.Enter(v1.Name, &FunEntry{result: &ErrorSemantTy{}})
+				failed[decl] = true
+			}
+		case *syntax.PrimitiveDecl:
+			if _, err := s.transDec(level, decl, FirstPass, breakLabel); err != nil {
+				s.report(err)
+				s.venv.Enter(v1.Name, &FunEntry{result: &ErrorSemantTy{}})
+			}
+		}
+	}
+
+	for _, decl := range decls {
+		if _, ok := decl.(*syntax.FuncDecl); ok && !failed[decl] {
+			if _, err := s.transDec(level, decl, SecondPass, breakLabel); err != nil {
+				s.report(err)
+			}
+		}
+	}
+}
+
+func (s *Semant) transDec(level *Level, decl syntax.Declaration, pass int, breakLabel ir.Label) (TransExp, error) {
+	switch v := decl.(type) {
+	case *syntax.FuncDecl:
+		var (
+			resultTy SemantTy
+			err      error
+		)
+
+		if v.ResultTy == 0 {
+			resultTy = &UnitSemantTy{}
+		} else {
+			resultTy, err = s.tenv.Look(v.ResultTy)
+			if err != nil {
+				if err == errSTNotFound {
+					return nil, typeNotFoundErr(s.strs.Get(v.ResultTy), v.ResultTyPos)
+				}
+
+				return nil, err
+			}
+		}
+
+		resultTy, err = s.actualTy(resultTy, v.Pos)
+		if err != nil {
+			return nil, err
+		}
+
+		if pass == SecondPass {
+			// Only enter VarEntry at second pass. At first pass, we only gather information (type and params)
+			s.venv.BeginScope()
+			defer s.venv.EndScope()
+		}
+
+		paramsTy := make([]SemantTy, 0, len(v.Params))
+		for _, param := range v.Params {
+			ty, err := s.tenv.Look(param.Typ)
+			if err != nil {
+				if err == errSTNotFound {
+					return nil, typeNotFoundErr(s.strs.Get(param.Typ), param.Pos)
+				}
+
+				return nil, err
+			}
+
+			ty, err = s.actualTy(ty, param.Pos)
+			if err != nil {
+				return nil, err
+			}
+
+			paramsTy = append(paramsTy, ty)
+			if pass == SecondPass {
+				// Only enter VarEntry at second pass. At first pass, we only gather information (type and params)
+				entry := &VarEntry{
+					Ty: ty,
+				}
+				s.venv.Enter(param.Name, entry)
+				s.Index.define(s.strs.Get(param.Name), DefVar, param.Pos, entry)
+			}
+		}
+
+		es := make([]bool, 0, 1+len(v.Params))
+		es = append(es, true)
+		for _, p := range v.Params {
+			es = append(es, *p.Escape)
+		}
+
+		newLevel := s.translate.NewLevel(level, s.funcLabel(level, v), es)
+		if pass == FirstPass {
+			entry := &FunEntry{
+				formals: paramsTy,
+				result:  resultTy,
+				label:   newLevel.frame.Name(),
+				level:   newLevel,
+			}
+			s.venv.Enter(v.Name, entry)
+			s.Index.define(s.strs.Get(v.Name), DefFunc, v.Pos, entry)
+			return nil, nil
+		}
+
+		accesses := s.translate.Formals(newLevel)
+		for i, param := range v.Params {
+			tmp, _ := s.venv.Look(param.Name)
+			oldEntry := tmp.(*VarEntry)
+			oldEntry.access = accesses[i]
+			s.venv.Replace(param.Name, oldEntry)
+			s.translate.pointerVar(accesses[i], paramsTy[i])
+			s.translate.debugVar(accesses[i], s.strs.Get(param.Name), paramsTy[i], param.Pos)
+		}
+
+		s.translate.pointerResult(newLevel, resultTy)
+		if newLevel.debug != nil {
+			newLevel.debug.Name, newLevel.debug.Pos = s.strs.Get(v.Name), v.Pos
+		}
+
+		bodyExp, bTy, err := s.transStm(newLevel, v.Body, breakLabel)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isSameType(resultTy, bTy) {
+			return nil, resultMismatchErr(s.strs.Get(v.Name), resultTy, bTy, v.Body.Span(), v.Pos)
+		}
+
+		//s.venv.Replace(v.name, &FunEntry{
+		//	formals: paramsTy,
+		//	result:  resultTy,
+		//	label:   Label(v.name),
+		//	level:   newLevel,
+		//})
+		//
+		s.translate.endScope(newLevel, 0, v.Span().End)
+		s.translate.ProcEntryExit(newLevel, bodyExp)
+		return nil, nil
+
+	case *syntax.PrimitiveDecl:
+		// a primitive is called like the functions of baseFuncs, provided the runtime defines it
+		name := s.strs.Get(v.Name)
+		if s.translate.runtime != nil && !s.translate.runtime[name] {
+			return nil, primitiveNotFoundErr(name, v.Pos)
+		}
+
+		formals := make([]SemantTy, 0, len(v.Params))
+		for _, param := range v.Params {
+			ty, err := s.lookTy(param.Typ, param.Pos)
+			if err != nil {
+				return nil, err
+			}
+
+			formals = append(formals, ty)
+		}
+
+		var result SemantTy = &UnitSemantTy{}
+		if v.ResultTy != 0 {
+			ty, err := s.lookTy(v.ResultTy, v.ResultTyPos)
+			if err != nil {
+				return nil, err
+			}
+
+			result = ty
+		}
+
+		entry := &FunEntry{
+			formals: formals,
+			result:  result,
+			level:   OutermostLevel,
+			label:   s.tm.NamedLabel(name),
+		}
+		s.venv.Enter(v.Name, entry)
+		s.Index.define(s.strs.Get(v.Name), DefFunc, v.Pos, entry)
+		return nil, nil
+
+	case *syntax.VarDecl:
+		initExp, initTy, err := s.transExp(level, v.Init, breakLabel)
+		if err != nil {
+			return nil, err
+		}
+
+		initTy, err = s.actualTy(initTy, v.Init.ExpPos())
+		if err != nil {
+			return nil, err
+		}
+
+		if v.Typ == 0 {
+			// var id := expr
+			switch initTy.(type) {
+			case *NilSemantTy:
+				return nil, nilWithoutTypeErr(v.Init.Span())
+			default:
+				acc := s.translate.AllocLocal(level, *v.Escape)
+				s.translate.pointerVar(acc, initTy)
+				varExp := s.translate.simpleVar(level, acc)
+				entry := &VarEntry{
+					Ty:     initTy,
+					access: acc,
+				}
+				s.venv.Enter(v.Name, entry)
+				s.Index.define(s.strs.Get(v.Name), DefVar, v.Pos, entry)
+				s.translate.debugVar(acc, s.strs.Get(v.Name), initTy, v.Pos)
+				return s.translate.line(v.Pos, s.translate.assign(varExp, initExp)), nil
+			}
+		}
+
+		tentry, err := s.tenv.Look(v.Typ)
+		if err != nil {
+			if err == errSTNotFound {
+				return nil, typeNotFoundErr(s.strs.Get(v.Typ), v.Pos)
+			}
+
+			return nil, err
+		}
+
+		tentry, ok := tentry.(SemantTy)
+		if !ok {
+			panic("entry of type ST must be SemantTy")
+		}
+
+		actualTy, err := s.actualTy(tentry, v.Pos)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isSameType(actualTy, initTy) {
+			return nil, varTypeMismatchErr(s.strs.Get(v.Name), actualTy, initTy, v.Init.Span(), v.Pos)
+		}
+
+		acc := s.translate.AllocLocal(level, *v.Escape)
+		s.translate.pointerVar(acc, actualTy)
+		varExp := s.translate.simpleVar(level, acc)
+		entry := &VarEntry{
+			Ty:     actualTy,
+			access: acc,
+		}
+		s.venv.Enter(v.Name, entry)
+		s.Index.define(s.strs.Get(v.Name), DefVar, v.Pos, entry)
+		s.translate.debugVar(acc, s.strs.Get(v.Name), actualTy, v.Pos)
+		return s.translate.line(v.Pos, s.translate.assign(varExp, initExp)), nil
+
+	case *syntax.TypeDecl:
+		ty, err := s.transTypeDecl(v.Ty, pass)
+		if err != nil {
+			return nil, err
+		}
+
+		if class, ok := ty.(*ClassSemantTy); ok {
+			class.name = s.strs.Get(v.TyName)
+		}
+
+		if pass == FirstPass {
+			s.tenv.Enter(v.TyName, ty)
+		} else {
+			s.tenv.Replace(v.TyName, ty)
+			s.Index.define(s.strs.Get(v.TyName), DefType, v.Pos, ty)
+		}
+
+		return nil, nil
+	}
+
+	panic("unexpected declaration type")
+}
+
+// transTypeDecl translates type expressions as found in the abstract syntax to the digested type descriptions that we
+// will put into env
+func (s *Semant) transTypeDecl(ty syntax.Ty, pass int) (SemantTy, error) {
+	switch v := ty.(type) {
+	case *syntax.NameTy:
+		baseTy, err := s.tenv.Look(v.Ty)
+		if err != nil {
+			if err == errSTNotFound {
+				return nil, baseTypeNotFoundErr(s.strs.Get(v.Ty), v.TyPos())
+			}
+
+			return nil, err
+		}
+
+		return &NameSemantTy{
+			BaseTy:  baseTy,
+			nameSym: v.Ty,
+			name:    s.strs.Get(v.Ty),
+		}, nil
+
+	case *syntax.ArrayTy:
+		baseTy, err := s.tenv.Look(v.Ty)
+		if err != nil {
+			if err == errSTNotFound {
+				return nil, baseTypeNotFoundErr(s.strs.Get(v.Ty), v.TyPos())
+			}
+
+			return nil, err
+		}
+
+		u := rand.Int63()
+		return &ArrSemantTy{
+			BaseTy: baseTy,
+			u:      u,
+		}, nil
+
+	case *syntax.RecordTy:
+		if v.HasDuplicateField() {
+			return nil, duplicateRecordDefinition(v.TyPos())
+		}
+
+		if pass == FirstPass {
+			return &NameSemantTy{
+				BaseTy: nil,
+			}, nil
+		}
+
+		types := make([]syntax.Symbol, 0, len(v.Fields))
+		symbols := make([]syntax.Symbol, 0, len(v.Fields))
+		for _, field := range v.Fields {
+			_, err := s.tenv.Look(field.Typ)
+			if err != nil {
+				if err == errSTNotFound {
+					return nil, typeNotFoundErr(s.strs.Get(field.Typ), field.Pos)
+				}
+			}
+
+			symbols = append(symbols, field.Name)
+			types = append(types, field.Typ)
+		}
+
+		return &RecordSemantTy{
+			Symbols: symbols,
+			Types:   types,
+			u:       rand.Int63(),
+		}, nil
+
+	case *syntax.ClassTy:
+		if pass == FirstPass {
+			return &NameSemantTy{
+				BaseTy: nil,
+			}, nil
+		}
+
+		// the class is laid out once all the types are known, see declareClasses
+		return &ClassSemantTy{
+			u: rand.Int63(),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+func (s *Semant) transSeq(level *Level, exps []syntax.Exp, breakLabel ir.Label) (TransExp, SemantTy, error) {
+	if len(exps) == 0 {
+		return &Ex{&ir.ConstExpIr{Value: 0}}, &IntSemantTy{}, nil
+	}
+
+	if len(exps) == 1 {
+		return s.transExp(level, exps[0], breakLabel)
+	}
+
+	// the error of an expression does not keep the following ones from being checked
+	hex, _, err := s.transStm(level, exps[0], breakLabel)
+	if err != nil {
+		s.report(err)
+	}
+
+	var (
+		tex TransExp
+		tly SemantTy
+	)
+	if len(exps) == 2 {
+		// the last expression is a statement of the sequence as well
+		tex, tly, err = s.transStm(level, exps[1], breakLabel)
+	} else {
+		tex, tly, err = s.transSeq(level, exps[1:], breakLabel)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if hex == nil {
+		return nil, nil, errReported
+	}
+
+	return s.translate.seq(hex, tex), tly, nil
+}
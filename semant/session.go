@@ -0,0 +1,131 @@
+package semant
+
+import (
+	"tiger/ir"
+	"tiger/syntax"
+)
+
+// Session checks and translates the inputs of a REPL one after the other, each in the scope of the declarations of
+// those before. The inputs are translated at the level of the session, whose frame outlives them: the variables they
+// declare live in it, the functions they declare are nested in it, and their names stay in the symbol tables.
+type Session struct {
+	s     *Semant
+	level *Level
+}
+
+// NewSession starts a session whose inputs are checked by s, in its symbol tables.
+func NewSession(s *Semant) *Session {
+	return &Session{
+		s:     s,
+		level: s.translate.NewLevel(OutermostLevel, s.tm.NamedLabel("repl"), []bool{true}),
+	}
+}
+
+// Input is an input of a session translated to a procedure.
+type Input struct {
+	// Proc runs the input in the frame of the session and returns the value of its expression
+	Proc *ir.ProcFrag
+
+	// Frags are the functions and the strings of the input, which Proc uses
+	Frags []ir.Frag
+
+	// Ty is the type of an expression, unit for declarations
+	Ty SemantTy
+
+	// Defs are the declarations of an input of declarations, in order
+	Defs []*Definition
+}
+
+// Frame is the frame of the session, in which the procedures of the inputs run.
+func (ss *Session) Frame() ir.Frame {
+	return ss.level.frame
+}
+
+// Exp translates an expression.
+func (ss *Session) Exp(exp syntax.Exp) (*Input, error) {
+	NewFindEscape().FindEscape(exp)
+	return ss.input(func() (TransExp, SemantTy, error) {
+		return ss.s.transExp(ss.level, exp, ss.s.tm.NewLabel())
+	})
+}
+
+// Decls translates declarations, which the next inputs can use unless they have an error.
+func (ss *Session) Decls(decls []syntax.Declaration) (*Input, error) {
+	// the variables live in the frame of the session, so that the next inputs can use them
+	NewFindEscape().FindEscapeModule(decls)
+	for _, decl := range decls {
+		if v, ok := decl.(*syntax.VarDecl); ok {
+			*v.Escape = true
+		}
+	}
+
+	input, err := ss.input(func() (TransExp, SemantTy, error) {
+		exps, err := ss.s.transDecls(ss.level, decls, ss.s.tm.NewLabel())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return ss.s.translate.letExp(exps, ss.s.translate.unitExp()), &UnitSemantTy{}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the index has the parameters and the local declarations too
+	for _, decl := range decls {
+		for _, def := range ss.s.Index.Defs {
+			if def.Pos == decl.DeclPos() {
+				input.Defs = append(input.Defs, def)
+				break
+			}
+		}
+	}
+
+	return input, nil
+}
+
+// input translates an input with trans. The declarations that an input with an error makes are dropped.
+func (ss *Session) input(trans func() (TransExp, SemantTy, error)) (*Input, error) {
+	s := ss.s
+	s.Diags = syntax.NewDiagnostics()
+	s.Index = NewIndex()
+	frags := len(s.translate.frags)
+	s.venv.BeginScope()
+	s.tenv.BeginScope()
+	exp, ty, err := trans()
+	if err != nil {
+		s.report(err)
+	}
+
+	if s.Diags.HasErrors() {
+		s.venv.EndScope()
+		s.tenv.EndScope()
+		s.translate.frags = s.translate.frags[:frags]
+		return nil, s.Diags
+	}
+
+	s.translate.ProcEntryExit(ss.level, exp)
+	last := len(s.translate.frags) - 1
+	return &Input{
+		Proc:  s.translate.frags[last].(*ir.ProcFrag),
+		Frags: s.translate.frags[frags:last],
+		Ty:    ty,
+	}, nil
+}
+
+// Fields are the names and the types of the fields of a record type, to show its values.
+func (ss *Session) Fields(ty *RecordSemantTy) ([]string, []SemantTy) {
+	names := make([]string, 0, len(ty.Symbols))
+	types := make([]SemantTy, 0, len(ty.Types))
+	for i, sym := range ty.Symbols {
+		names = append(names, ss.s.strs.Get(sym))
+		fieldTy, err := ss.s.lookTy(ty.Types[i], syntax.Pos{})
+		if err != nil {
+			fieldTy = &ErrorSemantTy{}
+		}
+
+		types = append(types, fieldTy)
+	}
+
+	return names, types
+}
@@ -1,44 +1,15 @@
-package main
+package semant
 
 import (
 	"errors"
-	"strings"
+
+	"tiger/syntax"
 )
 
 var (
 	errSTNotFound = errors.New("symbol not found in ST")
 )
 
-type Symbol = uint64
-
-type Strings struct {
-	nextSymbol Symbol
-	strings    map[Symbol]string
-}
-
-func NewStrings() *Strings {
-	return &Strings{
-		strings: make(map[Symbol]string),
-	}
-}
-
-func (s *Strings) Get(sym Symbol) string {
-	return s.strings[sym]
-}
-
-func (s *Strings) Symbol(str string) Symbol {
-	// TODO: is this a right way to handle or we need to create new symbol every time
-	for v, s := range s.strings {
-		if strings.EqualFold(s, str) {
-			return v
-		}
-	}
-
-	s.nextSymbol++
-	s.strings[s.nextSymbol] = str
-	return s.nextSymbol
-}
-
 type EscapeST struct {
 	st *BaseST
 }
@@ -50,18 +21,18 @@ func NewEscapeST() *EscapeST {
 }
 
 func (est *EscapeST) BeginScope() {
-	panic("not implemented")
+	est.st.BeginScope()
 }
 
 func (est *EscapeST) EndScope() {
-	panic("not implemented")
+	est.st.EndScope()
 }
 
-func (est *EscapeST) Enter(sym Symbol, data *EscapeEntry) {
+func (est *EscapeST) Enter(sym syntax.Symbol, data *EscapeEntry) {
 	est.st.Enter(sym, data)
 }
 
-func (vst *EscapeST) Look(sym Symbol) (*EscapeEntry, error) {
+func (vst *EscapeST) Look(sym syntax.Symbol) (*EscapeEntry, error) {
 	v, err := vst.st.Look(sym)
 	if err != nil {
 		return nil, err
@@ -75,14 +46,10 @@ func (vst *EscapeST) Look(sym Symbol) (*EscapeEntry, error) {
 	return v1, nil
 }
 
-func (vst *EscapeST) Replace(sym Symbol, data *EscapeEntry) {
+func (vst *EscapeST) Replace(sym syntax.Symbol, data *EscapeEntry) {
 	vst.st.Replace(sym, data)
 }
 
-func (vst *EscapeST) Name(sym Symbol) string {
-	return vst.st.Name(sym)
-}
-
 type VarST struct {
 	st *BaseST
 }
@@ -99,11 +66,11 @@ func (vst *VarST) EndScope() {
 	vst.st.EndScope()
 }
 
-func (vst *VarST) Enter(sym Symbol, data EnvEntry) {
+func (vst *VarST) Enter(sym syntax.Symbol, data EnvEntry) {
 	vst.st.Enter(sym, data)
 }
 
-func (vst *VarST) Look(sym Symbol) (EnvEntry, error) {
+func (vst *VarST) Look(sym syntax.Symbol) (EnvEntry, error) {
 	v, err := vst.st.Look(sym)
 	if err != nil {
 		return nil, err
@@ -117,14 +84,10 @@ func (vst *VarST) Look(sym Symbol) (EnvEntry, error) {
 	return v1, nil
 }
 
-func (vst *VarST) Replace(sym Symbol, data EnvEntry) {
+func (vst *VarST) Replace(sym syntax.Symbol, data EnvEntry) {
 	vst.st.Replace(sym, data)
 }
 
-func (vst *VarST) Name(sym Symbol) string {
-	return vst.st.Name(sym)
-}
-
 type TypeST struct {
 	st *BaseST
 }
@@ -141,11 +104,11 @@ func (vst *TypeST) EndScope() {
 	vst.st.EndScope()
 }
 
-func (vst *TypeST) Enter(sym Symbol, data SemantTy) {
+func (vst *TypeST) Enter(sym syntax.Symbol, data SemantTy) {
 	vst.st.Enter(sym, data)
 }
 
-func (vst *TypeST) Look(sym Symbol) (SemantTy, error) {
+func (vst *TypeST) Look(sym syntax.Symbol) (SemantTy, error) {
 	v, err := vst.st.Look(sym)
 	if err != nil {
 		return nil, err
@@ -159,22 +122,18 @@ func (vst *TypeST) Look(sym Symbol) (SemantTy, error) {
 	return v1, nil
 }
 
-func (vst *TypeST) Replace(sym Symbol, data SemantTy) {
+func (vst *TypeST) Replace(sym syntax.Symbol, data SemantTy) {
 	vst.st.Replace(sym, data)
 }
 
-func (vst *TypeST) Name(sym Symbol) string {
-	return vst.st.Name(sym)
-}
-
 type BaseST struct {
-	stack   [][]Symbol
-	table   map[Symbol][]interface{}
+	stack [][]syntax.Symbol
+	table map[syntax.Symbol][]interface{}
 }
 
 func NewST() *BaseST {
 	st := BaseST{
-		table:   make(map[Symbol][]interface{}),
+		table: make(map[syntax.Symbol][]interface{}),
 	}
 
 	st.BeginScope()
@@ -182,7 +141,7 @@ func NewST() *BaseST {
 }
 
 func (s *BaseST) BeginScope() {
-	s.stack = append(s.stack, make([]Symbol, 0, 100))
+	s.stack = append(s.stack, make([]syntax.Symbol, 0, 100))
 }
 
 func (s *BaseST) EndScope() {
@@ -203,13 +162,15 @@ func (s *BaseST) EndScope() {
 		v = v[:len(v)-1]
 		if len(v) == 0 {
 			delete(s.table, sym)
+		} else {
+			s.table[sym] = v
 		}
 	}
 
 	s.stack = s.stack[:len(s.stack)-1]
 }
 
-func (s *BaseST) Enter(sym Symbol, data interface{}) {
+func (s *BaseST) Enter(sym syntax.Symbol, data interface{}) {
 	s.table[sym] = append(s.table[sym], data)
 	if len(s.stack) == 0 {
 		panic("call BeginScope() before Enter()")
@@ -218,7 +179,7 @@ func (s *BaseST) Enter(sym Symbol, data interface{}) {
 	s.stack[len(s.stack)-1] = append(s.stack[len(s.stack)-1], sym)
 }
 
-func (s *BaseST) Look(sym Symbol) (interface{}, error) {
+func (s *BaseST) Look(sym syntax.Symbol) (interface{}, error) {
 	if len(s.table[sym]) == 0 {
 		return nil, errSTNotFound
 	}
@@ -226,11 +187,7 @@ func (s *BaseST) Look(sym Symbol) (interface{}, error) {
 	return s.table[sym][len(s.table[sym])-1], nil
 }
 
-func (s *BaseST) Name(sym Symbol) string {
-	return strs.strings[sym]
-}
-
-func (s *BaseST) Replace(sym Symbol, data interface{}) {
+func (s *BaseST) Replace(sym syntax.Symbol, data interface{}) {
 	if _, ok := s.table[sym]; ok {
 		s.table[sym] = append(s.table[sym][:len(s.table[sym])-1], data)
 	}
@@ -0,0 +1,963 @@
+package semant
+
+import (
+	"math/rand"
+	"strings"
+
+	"tiger/ir"
+	"tiger/syntax"
+)
+
+type cxFunc func(tl, fl ir.Label) ir.StmIr
+
+type TransExp interface {
+	unEx() ir.ExpIr
+	unNx() ir.StmIr
+	unCx() cxFunc
+	print(sb *strings.Builder, strs *syntax.Strings, level int)
+}
+
+func isNop(e TransExp) bool {
+	if _, ok := e.(*Nx); ok {
+		return ir.IsNullStm(e.unNx())
+	}
+
+	return false
+}
+
+type Ex struct {
+	exp ir.ExpIr
+}
+
+func (e *Ex) print(sb *strings.Builder, strs *syntax.Strings, level int) {
+	e.exp.PrintExpIr(sb, strs, level)
+}
+
+func (e *Ex) unEx() ir.ExpIr {
+	return e.exp
+}
+
+func (e *Ex) unNx() ir.StmIr {
+	return &ir.ExpStmIr{Exp: e.exp}
+}
+
+func (e *Ex) unCx() cxFunc {
+	switch v := e.exp.(type) {
+	case *ir.ConstExpIr:
+		if v.Value == 0 {
+			return func(_, fl ir.Label) ir.StmIr {
+				return &ir.JumpStmIr{
+					Exp:    &ir.NameExpIr{Label: fl},
+					Labels: []ir.Label{fl},
+				}
+			}
+		} else if v.Value == 1 {
+			return func(tl, _ ir.Label) ir.StmIr {
+				return &ir.JumpStmIr{
+					Exp:    &ir.NameExpIr{Label: tl},
+					Labels: []ir.Label{tl},
+				}
+			}
+		}
+
+		return func(tl, fl ir.Label) ir.StmIr {
+			return &ir.CJumpStmIr{
+				Relop:      ir.EqIr,
+				Left:       v,
+				Right:      &ir.ConstExpIr{Value: 0},
+				TrueLabel:  fl,
+				FalseLabel: tl,
+			}
+		}
+	default:
+		return func(tl, fl ir.Label) ir.StmIr {
+			return &ir.CJumpStmIr{
+				Relop:      ir.EqIr,
+				Left:       v,
+				Right:      &ir.ConstExpIr{Value: 0},
+				TrueLabel:  fl,
+				FalseLabel: tl,
+			}
+		}
+	}
+}
+
+type Nx struct {
+	stm ir.StmIr
+}
+
+func (s *Nx) print(sb *strings.Builder, strs *syntax.Strings, level int) {
+	s.stm.PrintStm(sb, strs, level)
+}
+
+func (s *Nx) unEx() ir.ExpIr {
+	return &ir.EsEqExpIr{
+		Stm: s.stm,
+		Exp: &ir.ConstExpIr{Value: 0},
+	}
+}
+
+func (s *Nx) unNx() ir.StmIr {
+	return s.stm
+}
+
+func (s *Nx) unCx() cxFunc {
+	panic("not supported")
+}
+
+type Cx struct {
+	tm *ir.TempManagement
+	cx cxFunc
+}
+
+func (c *Cx) print(sb *strings.Builder, strs *syntax.Strings, level int) {
+	c.unEx().PrintExpIr(sb, strs, level)
+}
+
+func (c *Cx) unCx() cxFunc {
+	return c.cx
+}
+
+// TODO: simplify this using helper function
+func (c *Cx) unEx() ir.ExpIr {
+	r, t, f := c.tm.NewTemp(), c.tm.NewLabel(), c.tm.NewLabel()
+	return &ir.EsEqExpIr{
+		Stm: &ir.SeqStmIr{
+			First: &ir.MoveStmIr{
+				Dst: &ir.TempExpIr{Temp: r},
+				Src: &ir.ConstExpIr{Value: 1},
+			},
+			Second: &ir.SeqStmIr{
+				First: c.cx(t, f),
+				Second: &ir.SeqStmIr{
+					First: &ir.LabelStmIr{Label: f},
+					Second: &ir.SeqStmIr{
+						First: &ir.MoveStmIr{
+							Dst: &ir.TempExpIr{Temp: r},
+							Src: &ir.ConstExpIr{Value: 0},
+						},
+						Second: &ir.LabelStmIr{Label: t},
+					},
+				},
+			},
+		},
+		Exp: &ir.TempExpIr{Temp: r},
+	}
+}
+
+func (c *Cx) unNx() ir.StmIr {
+	l := c.tm.NewLabel()
+	return &ir.SeqStmIr{
+		First:  c.cx(l, l),
+		Second: &ir.LabelStmIr{Label: l},
+	}
+}
+
+type TranslateAccess struct {
+	level  *Level
+	access ir.FrameAccess
+}
+
+type Level struct {
+	// top level have nil as parent
+	parent *Level
+	frame  ir.Frame
+	u      int64
+
+	// module is set on the level of the declarations of a module. It has neither a frame nor variables, so the
+	// functions declared there are passed 0 as their static link.
+	module bool
+
+	// debug is what a debugger is told about the function of the level, when the debug information is emitted
+	debug *ir.ProcDebug
+}
+
+var OutermostLevel = &Level{
+	parent: nil,
+	u:      rand.Int63(),
+}
+
+func (l *Level) depth() int32 {
+	if l.parent == nil {
+		return 0
+	}
+
+	return 1 + l.parent.depth()
+}
+
+func (l *Level) staticLink(from *Level, base ir.ExpIr) ir.ExpIr {
+	if from.parent == nil || from.u == l.parent.u {
+		return base
+	}
+
+	return l.staticLink(from.parent, from.frame.Formals()[0].Exp(base))
+}
+
+// Translate turns the checked expressions into IR trees and collects the fragments of the program: the functions, the
+// strings and the vtables.
+type Translate struct {
+	tm           *ir.TempManagement
+	frags        []ir.Frag
+	frameFactory ir.FrameFactoryFunc
+	wordSize     int32
+
+	// descriptors maps record descriptors to the labels of their strings, so that each is emitted once
+	descriptors map[string]ir.Label
+
+	// runtime has the functions that the runtime defines, which primitives can be. It is nil when they are not
+	// checked.
+	runtime map[string]bool
+
+	// Debug marks the statements for the line table of a debugger and records the variables of the functions
+	Debug bool
+}
+
+func NewTranslate(tm *ir.TempManagement, frameFactory ir.FrameFactoryFunc, wordSize int32,
+	runtime map[string]bool) *Translate {
+	return &Translate{tm: tm, frameFactory: frameFactory, wordSize: wordSize, runtime: runtime}
+}
+
+// fork is a Translate for the same target with fragments and record descriptors of its own.
+func (t *Translate) fork() *Translate {
+	fork := NewTranslate(t.tm, t.frameFactory, t.wordSize, t.runtime)
+	fork.Debug = t.Debug
+	return fork
+}
+
+// Frags are the fragments translated so far.
+func (t *Translate) Frags() []ir.Frag {
+	return t.frags
+}
+
+// pointerVar tells the frame of acc that the variable holds a heap pointer when ty is a pointer type.
+func (t *Translate) pointerVar(acc *TranslateAccess, ty SemantTy) {
+	if acc == nil || !isPointer(ty) {
+		return
+	}
+
+	if frame, ok := acc.level.frame.(ir.GCFrame); ok {
+		frame.Pointer(acc.access)
+	}
+}
+
+// pointerResult tells the frame of level that the function returns a heap pointer when ty is a pointer type.
+func (t *Translate) pointerResult(level *Level, ty SemantTy) {
+	if !isPointer(ty) {
+		return
+	}
+
+	if frame, ok := level.frame.(ir.GCFrame); ok {
+		frame.PointerResult()
+	}
+}
+
+// pointerLoad marks the load of a variable of type ty as yielding a heap pointer. Variables kept in temps are known
+// to their frame already.
+func (t *Translate) pointerLoad(e TransExp, ty SemantTy) TransExp {
+	if !isPointer(ty) {
+		return e
+	}
+
+	if ex, ok := e.(*Ex); ok {
+		if mem, ok := ex.exp.(*ir.MemExpIr); ok {
+			mem.Ptr = true
+		}
+	}
+
+	return e
+}
+
+func (t *Translate) NewLevel(parent *Level, name ir.Label, formals []bool) *Level {
+	level := &Level{
+		parent: parent,
+		frame:  t.frameFactory(t.tm, name, formals),
+		u:      rand.Int63(),
+	}
+
+	if t.Debug {
+		level.debug = &ir.ProcDebug{Name: t.tm.LabelString(name)}
+		if parent != nil {
+			level.debug.Parent = parent.frame
+		}
+	}
+
+	return level
+}
+
+// line marks e as a statement of the source at pos, whose code starts an entry of the line table.
+func (t *Translate) line(pos syntax.Pos, e TransExp) TransExp {
+	if !t.Debug {
+		return e
+	}
+
+	mark := &ir.LineStmIr{Pos: pos}
+	switch v := e.(type) {
+	case *Nx:
+		return &Nx{&ir.SeqStmIr{First: mark, Second: v.stm}}
+	case *Cx:
+		return &Cx{t.tm, func(tl, fl ir.Label) ir.StmIr {
+			return &ir.SeqStmIr{First: mark, Second: v.cx(tl, fl)}
+		}}
+	default:
+		return &Ex{&ir.EsEqExpIr{Stm: mark, Exp: e.unEx()}}
+	}
+}
+
+// debugVar records the variable at acc, declared at pos, for a debugger. Its scope is ended by endScope.
+func (t *Translate) debugVar(acc *TranslateAccess, name string, ty SemantTy, pos syntax.Pos) {
+	if acc == nil || acc.level.debug == nil {
+		return
+	}
+
+	debug := acc.level.debug
+	debug.Vars = append(debug.Vars, &ir.DebugVar{Name: name, Type: ty.TypeName(), Access: acc.access, Pos: pos})
+}
+
+// debugVars is the number of variables recorded for the function of level, from which endScope ends those of a scope.
+func (t *Translate) debugVars(level *Level) int {
+	if level.debug == nil {
+		return 0
+	}
+
+	return len(level.debug.Vars)
+}
+
+// endScope ends at end the scope of the variables of level recorded from the from-th on, but for those of the inner
+// scopes that are already ended.
+func (t *Translate) endScope(level *Level, from int, end syntax.Pos) {
+	if level.debug == nil {
+		return
+	}
+
+	for _, v := range level.debug.Vars[from:] {
+		if v.End.Line == 0 {
+			v.End = end
+		}
+	}
+}
+
+func (t *Translate) Formals(level *Level) []*TranslateAccess {
+	if level.parent == nil {
+		// outtermost level
+		return nil
+	}
+
+	// first formal is static chain offset
+	frameAccesses := level.frame.Formals()[1:]
+
+	// frameAccesses := level.frame.Formals()
+	translateAccesses := make([]*TranslateAccess, 0, len(frameAccesses))
+	for _, acc := range frameAccesses {
+		translateAccesses = append(translateAccesses, &TranslateAccess{
+			level:  level,
+			access: acc,
+		})
+	}
+
+	return translateAccesses
+}
+
+func (t *Translate) AllocLocal(level *Level, escape bool) *TranslateAccess {
+	if level.parent == nil {
+		return nil
+	}
+
+	return &TranslateAccess{
+		level:  level,
+		access: level.frame.AllocLocal(escape),
+	}
+}
+
+func (t *Translate) simpleVar(level *Level, access *TranslateAccess) TransExp {
+	curLevel, defLevel := level, access.level
+
+	var acc ir.ExpIr
+	acc = &ir.TempExpIr{Temp: level.frame.FP()}
+
+	for curLevel.u != defLevel.u {
+		staticLink := curLevel.frame.Formals()[0]
+		curLevel = curLevel.parent
+		acc = staticLink.Exp(acc)
+	}
+
+	return &Ex{exp: access.access.Exp(acc)}
+}
+
+func (t *Translate) memPlus(e1, e2 ir.ExpIr) ir.ExpIr {
+	return &ir.MemExpIr{Mem: &ir.BinOpExpIr{
+		Binop: ir.PlusIr,
+		Left:  e1,
+		Right: e2,
+	}}
+}
+
+func (t *Translate) fieldVar(base TransExp, id int32) TransExp {
+	return &Ex{exp: t.memPlus(base.unEx(), &ir.ConstExpIr{Value: id * t.wordSize})}
+}
+
+func (t *Translate) SubscriptVar(base TransExp, id TransExp) TransExp {
+	return &Ex{exp: t.memPlus(base.unEx(), &ir.BinOpExpIr{
+		Binop: ir.MulIr,
+		Left:  id.unEx(),
+		Right: &ir.ConstExpIr{Value: t.wordSize},
+	})}
+}
+
+func (t *Translate) BinOp(op syntax.Operator, left TransExp, right TransExp) TransExp {
+	leftEx, rightEx := left.unEx(), right.unEx()
+	var opIr ir.BinOpIr
+	switch op {
+	case syntax.Plus:
+		opIr = ir.PlusIr
+	case syntax.Minus:
+		opIr = ir.MinusIr
+	case syntax.Mul:
+		opIr = ir.MulIr
+	case syntax.Div:
+		opIr = ir.DivIr
+	}
+
+	return &Ex{&ir.BinOpExpIr{
+		Binop: opIr,
+		Left:  leftEx,
+		Right: rightEx,
+	}}
+}
+
+func (t *Translate) RelOp(op syntax.Operator, left, right TransExp) TransExp {
+	leftEx, rightEx := left.unEx(), right.unEx()
+	var opIr ir.RelOpIr
+	switch op {
+	case syntax.Le:
+		opIr = ir.LeIr
+	case syntax.Lt:
+		opIr = ir.LtIr
+	case syntax.Ge:
+		opIr = ir.GeIr
+	case syntax.Gt:
+		opIr = ir.GtIr
+	case syntax.Eq:
+		opIr = ir.EqIr
+	case syntax.Neq:
+		opIr = ir.NeIr
+	}
+
+	return &Cx{
+		t.tm,
+		func(tl, fl ir.Label) ir.StmIr {
+			return &ir.CJumpStmIr{
+				Relop:      opIr,
+				Left:       leftEx,
+				Right:      rightEx,
+				TrueLabel:  tl,
+				FalseLabel: fl,
+			}
+		},
+	}
+}
+
+// stringOp compares two strings by their characters, with the stringCompare of the runtime which is negative, zero or
+// positive like strcmp.
+func (t *Translate) stringOp(op syntax.Operator, left, right TransExp) TransExp {
+	return t.RelOp(op, &Ex{t.externalCall("stringCompare", left.unEx(), right.unEx())}, t.intExp(0))
+}
+
+// logicOp evaluates the right operand only when the left one does not decide the result: a & b is if a then b else 0
+// and a | b is if a then 1 else b.
+func (t *Translate) logicOp(op syntax.Operator, left, right TransExp) TransExp {
+	if op == syntax.And {
+		return t.ifElse(left, right, t.intExp(0))
+	}
+
+	return t.ifElse(left, t.intExp(1), right)
+}
+
+func (t *Translate) strExp(s string) TransExp {
+	label := t.tm.NewLabel()
+	t.frags = append(t.frags, &ir.StrFrag{
+		Label: label,
+		Str:   s,
+	})
+
+	return &Ex{
+		&ir.NameExpIr{
+			Label: label,
+		},
+	}
+}
+
+func (t *Translate) intExp(i int32) TransExp {
+	return &Ex{
+		&ir.ConstExpIr{Value: i},
+	}
+}
+
+func (t *Translate) nilExp() TransExp {
+	return &Ex{
+		&ir.ConstExpIr{Value: 0},
+	}
+}
+
+func (t *Translate) unitExp() TransExp {
+	return &Nx{stm: &ir.ExpStmIr{Exp: &ir.ConstExpIr{Value: 0}}}
+}
+
+// arrayExp allocates an array of size words set to init. pointers tells the collector that the words hold heap
+// pointers.
+func (t *Translate) arrayExp(size, init TransExp, pointers bool) TransExp {
+	var flag int32
+	if pointers {
+		flag = 1
+	}
+
+	return &Ex{
+		t.externalCall("gcInitArray", size.unEx(), init.unEx(), &ir.ConstExpIr{Value: flag}),
+	}
+}
+
+func (t *Translate) externalCall(name string, args ...ir.ExpIr) *ir.CallExpIr {
+	return &ir.CallExpIr{
+		Exp: &ir.NameExpIr{
+			Label: t.tm.NamedLabel(name),
+		},
+		Args: args,
+	}
+}
+
+func (t *Translate) letExp(desc []TransExp, body TransExp) TransExp {
+	if len(desc) == 0 {
+		return body
+	}
+
+	stms := make([]ir.StmIr, 0, len(desc))
+	for _, d := range desc {
+		stms = append(stms, d.unNx())
+	}
+
+	return &Ex{
+		&ir.EsEqExpIr{
+			Stm: ir.SeqStm(stms...),
+			Exp: body.unEx(),
+		},
+	}
+}
+
+// call calls the function of label defined at defLevel. pointer tells that it returns a heap pointer, which the
+// collector has to know about even when the function is compiled in another unit.
+func (t *Translate) call(useLevel, defLevel *Level, label ir.Label, exps []TransExp, isProcedure, pointer bool) TransExp {
+	if defLevel == OutermostLevel {
+		args := make([]ir.ExpIr, 0, len(exps))
+		for _, exp := range exps {
+			args = append(args, exp.unEx())
+		}
+
+		call := t.externalCall(t.tm.LabelString(label), args...)
+		call.Ptr = pointer
+		if isProcedure {
+			return &Nx{
+				stm: &ir.ExpStmIr{Exp: call},
+			}
+		}
+
+		return &Ex{call}
+	}
+
+	args := make([]ir.ExpIr, 0, 1+len(exps))
+	if defLevel.parent.module {
+		args = append(args, &ir.ConstExpIr{Value: 0})
+	} else {
+		args = append(args, defLevel.staticLink(useLevel, &ir.TempExpIr{Temp: useLevel.frame.FP()}))
+	}
+
+	for _, e := range exps {
+		args = append(args, e.unEx())
+	}
+
+	if !isProcedure {
+		return &Ex{
+			&ir.CallExpIr{
+				Exp:  &ir.NameExpIr{Label: label},
+				Args: args,
+				Ptr:  pointer,
+			},
+		}
+	}
+
+	return &Nx{
+		&ir.ExpStmIr{
+			Exp: &ir.CallExpIr{
+				Exp:  &ir.NameExpIr{Label: label},
+				Args: args,
+				Ptr:  pointer,
+			},
+		},
+	}
+}
+
+func (t *Translate) seq(head, tail TransExp) TransExp {
+	if isNop(head) {
+		return tail
+	}
+
+	if isNop(tail) {
+		return head
+	}
+
+	if v, ok := tail.(*Nx); ok {
+		return &Nx{
+			&ir.SeqStmIr{
+				First:  head.unNx(),
+				Second: v.stm,
+			},
+		}
+	}
+
+	return &Ex{&ir.EsEqExpIr{
+		Stm: head.unNx(),
+		Exp: tail.unEx(),
+	}}
+}
+
+func (t *Translate) assign(left, right TransExp) TransExp {
+	return &Nx{&ir.MoveStmIr{
+		Dst: left.unEx(),
+		Src: right.unEx(),
+	}}
+}
+
+func (t *Translate) ifElse(ifEx, thenEx, elseEx TransExp) TransExp {
+	r := t.tm.NewTemp()
+	tl, fl, finish := t.tm.NewLabel(), t.tm.NewLabel(), t.tm.NewLabel()
+	testFunc := ifEx.unCx()
+	switch v := thenEx.(type) {
+	case *Ex:
+		if elseEx != nil {
+			return &Ex{
+				exp: &ir.EsEqExpIr{
+					Stm: ir.SeqStm(
+						testFunc(tl, fl),
+						&ir.LabelStmIr{
+							Label: tl,
+						},
+						&ir.MoveStmIr{
+							Dst: &ir.TempExpIr{Temp: r},
+							Src: v.exp,
+						},
+						&ir.JumpStmIr{
+							Exp:    &ir.NameExpIr{Label: finish},
+							Labels: []ir.Label{finish},
+						},
+						&ir.LabelStmIr{Label: fl},
+						&ir.MoveStmIr{
+							Dst: &ir.TempExpIr{Temp: r},
+							Src: elseEx.unEx(),
+						},
+						&ir.LabelStmIr{Label: finish},
+					),
+					Exp: &ir.TempExpIr{Temp: r},
+				},
+			}
+		}
+
+		// without else the value is discarded, e.g. a call that returns no value
+		return &Nx{
+			ir.SeqStm(
+				testFunc(tl, fl),
+				&ir.LabelStmIr{Label: tl},
+				v.unNx(),
+				&ir.LabelStmIr{Label: fl},
+			),
+		}
+
+	case *Nx:
+		if elseEx != nil {
+			return &Nx{
+				stm: ir.SeqStm(
+					testFunc(tl, fl),
+					&ir.LabelStmIr{
+						Label: tl,
+					},
+					v.stm,
+					&ir.JumpStmIr{
+						Exp:    &ir.NameExpIr{Label: finish},
+						Labels: []ir.Label{finish},
+					},
+					&ir.LabelStmIr{Label: fl},
+					elseEx.unNx(),
+					&ir.LabelStmIr{Label: finish},
+				),
+			}
+		}
+
+		return &Nx{
+			ir.SeqStm(
+				testFunc(tl, fl),
+				&ir.LabelStmIr{Label: tl},
+				v.stm,
+				&ir.LabelStmIr{Label: fl},
+			),
+		}
+
+	case *Cx:
+		if elseEx != nil {
+			return &Cx{
+				t.tm,
+				func(tl1, fl1 ir.Label) ir.StmIr {
+					return ir.SeqStm(
+						testFunc(tl, fl),
+						&ir.LabelStmIr{Label: tl},
+						v.cx(tl1, fl1),
+						&ir.LabelStmIr{Label: fl},
+						elseEx.unCx()(tl1, fl1),
+					)
+				},
+			}
+		}
+	}
+
+	panic("invalid case of if statement")
+}
+
+// forLoop assigns from to the variable once before the loop, and evaluates to once too, so that the body cannot change
+// the bound. The variable is only incremented while it is below the bound, so a loop up to the largest integer ends:
+//
+//	i := from; limit := to
+//	if i <= limit goto body else done
+//	body: body
+//	if i < limit goto inc else done
+//	inc: i := i + 1; goto body
+//	done:
+//
+// The tests are statements of the loop at pos.
+func (t *Translate) forLoop(level *Level, pos syntax.Pos, acc *TranslateAccess, from, to, body TransExp,
+	doneLabel ir.Label) TransExp {
+	itVar := t.simpleVar(level, acc)
+	limit := &Ex{&ir.TempExpIr{Temp: t.tm.NewTemp()}}
+	bl, il := t.tm.NewLabel(), t.tm.NewLabel()
+
+	return &Nx{ir.SeqStm(
+		t.assign(itVar, from).unNx(),
+		t.assign(limit, to).unNx(),
+		t.line(pos, t.RelOp(syntax.Le, itVar, limit)).unCx()(bl, doneLabel),
+		&ir.LabelStmIr{Label: bl},
+		body.unNx(),
+		t.line(pos, t.RelOp(syntax.Lt, itVar, limit)).unCx()(il, doneLabel),
+		&ir.LabelStmIr{Label: il},
+		&ir.MoveStmIr{
+			Dst: itVar.unEx(),
+			Src: &ir.BinOpExpIr{
+				Binop: ir.PlusIr,
+				Left:  itVar.unEx(),
+				Right: &ir.ConstExpIr{Value: 1},
+			},
+		},
+		&ir.JumpStmIr{
+			Exp:    &ir.NameExpIr{Label: bl},
+			Labels: []ir.Label{bl},
+		},
+		&ir.LabelStmIr{Label: doneLabel},
+	)}
+}
+
+func (t *Translate) whileLoop(pex, bex TransExp, doneLabel ir.Label) TransExp {
+	tl, bl := t.tm.NewLabel(), t.tm.NewLabel()
+	return &Nx{
+		ir.SeqStm(
+			&ir.LabelStmIr{Label: tl},
+			&ir.CJumpStmIr{
+				Relop:      ir.EqIr,
+				Left:       pex.unEx(),
+				Right:      &ir.ConstExpIr{Value: 0},
+				TrueLabel:  doneLabel,
+				FalseLabel: bl,
+			},
+			&ir.LabelStmIr{Label: bl},
+			bex.unNx(),
+			&ir.JumpStmIr{
+				Exp:    &ir.NameExpIr{Label: tl},
+				Labels: []ir.Label{tl},
+			},
+			&ir.LabelStmIr{Label: doneLabel},
+		),
+	}
+}
+
+func (t *Translate) breakStm(label ir.Label) TransExp {
+	return &Nx{
+		&ir.JumpStmIr{
+			Exp:    &ir.NameExpIr{Label: label},
+			Labels: []ir.Label{label},
+		},
+	}
+}
+
+// record allocates a record and initializes its fields. pointers tells which of the fields hold heap pointers.
+func (t *Translate) record(fields []TransExp, pointers []bool) TransExp {
+	r := t.tm.NewTemp()
+	stms := make([]ir.StmIr, len(fields)+1)
+	stms[0] = &ir.MoveStmIr{
+		Dst: &ir.TempExpIr{Temp: r},
+		Src: t.externalCall("gcAllocRecord", &ir.NameExpIr{Label: t.descriptor(pointers)}),
+	}
+
+	for i, field := range fields {
+		stms[i+1] = &ir.MoveStmIr{
+			Dst: t.memPlus(&ir.TempExpIr{Temp: r}, &ir.ConstExpIr{Value: int32(i) * t.wordSize}),
+			Src: field.unEx(),
+		}
+	}
+
+	return &Ex{
+		&ir.EsEqExpIr{
+			Stm: ir.SeqStm(stms...),
+			Exp: &ir.TempExpIr{Temp: r},
+		},
+	}
+}
+
+// descriptor returns the label of the string that describes the fields of a record to the collector: 'p' for a field
+// holding a heap pointer and 'n' for the others.
+func (t *Translate) descriptor(pointers []bool) ir.Label {
+	desc := make([]byte, len(pointers))
+	for i, pointer := range pointers {
+		desc[i] = 'n'
+		if pointer {
+			desc[i] = 'p'
+		}
+	}
+
+	if label, ok := t.descriptors[string(desc)]; ok {
+		return label
+	}
+
+	if t.descriptors == nil {
+		t.descriptors = make(map[string]ir.Label)
+	}
+
+	label := t.tm.NewLabel()
+	t.descriptors[string(desc)] = label
+	t.frags = append(t.frags, &ir.StrFrag{
+		Label: label,
+		Str:   string(desc),
+	})
+
+	return label
+}
+
+// vtable emits the vtable of a class, the labels of its methods in the order of their indices.
+func (t *Translate) vtable(methods []ir.Label) ir.Label {
+	label := t.tm.NewLabel()
+	t.frags = append(t.frags, &ir.TableFrag{
+		Label:  label,
+		Labels: methods,
+	})
+
+	return label
+}
+
+// newObject allocates an object of class, stores the address of its vtable and calls the function that initializes
+// its attributes. pointers tells which of the words of the object hold heap pointers.
+func (t *Translate) newObject(level *Level, class *ClassSemantTy, pointers []bool) TransExp {
+	r := t.tm.NewTemp()
+	alloc := t.externalCall("gcAllocRecord", &ir.NameExpIr{Label: t.descriptor(pointers)})
+
+	var vtable ir.ExpIr = &ir.ConstExpIr{Value: 0}
+	if class.vtable != 0 {
+		vtable = &ir.NameExpIr{Label: class.vtable}
+	}
+
+	stms := []ir.StmIr{
+		&ir.MoveStmIr{Dst: &ir.TempExpIr{Temp: r}, Src: alloc},
+		&ir.MoveStmIr{Dst: &ir.MemExpIr{Mem: &ir.TempExpIr{Temp: r}}, Src: vtable},
+	}
+
+	if class.init != nil {
+		stms = append(stms, t.call(level, class.init.level, class.init.label, []TransExp{&Ex{&ir.TempExpIr{Temp: r}}},
+			true, false).unNx())
+	}
+
+	return &Ex{
+		&ir.EsEqExpIr{
+			Stm: ir.SeqStm(stms...),
+			Exp: &ir.TempExpIr{Temp: r},
+		},
+	}
+}
+
+// classInit is the body of the function that initializes the attributes of an object of class, which is its first
+// parameter: it has the super class initialize its attributes, stores the static link of the methods of the class,
+// which is its own, and then initializes the attributes the class declares.
+func (t *Translate) classInit(level *Level, class *ClassSemantTy, attrs []*ClassAttr, inits []TransExp) TransExp {
+	self := t.pointerLoad(t.simpleVar(level, t.Formals(level)[0]), class)
+	stms := make([]ir.StmIr, 0, len(inits)+2)
+	if init := class.super.init; init != nil {
+		stms = append(stms, t.call(level, init.level, init.label, []TransExp{self}, true, false).unNx())
+	}
+
+	stms = append(stms, &ir.MoveStmIr{
+		Dst: t.fieldVar(self, class.link).unEx(),
+		Src: level.frame.Formals()[0].Exp(&ir.TempExpIr{Temp: level.frame.FP()}),
+	})
+
+	for i, attr := range attrs {
+		stms = append(stms, &ir.MoveStmIr{
+			Dst: t.fieldVar(self, attr.index).unEx(),
+			Src: inits[i].unEx(),
+		})
+	}
+
+	return &Nx{ir.SeqStm(stms...)}
+}
+
+// methodBody prepends to the body of a method of class the load of its static link from the object, which is its
+// first parameter: callers do not know which method they call, let alone where it is declared.
+func (t *Translate) methodBody(level *Level, class *ClassSemantTy, body TransExp) TransExp {
+	self := t.pointerLoad(t.simpleVar(level, t.Formals(level)[0]), class)
+	link := &ir.MoveStmIr{
+		Dst: level.frame.Formals()[0].Exp(&ir.TempExpIr{Temp: level.frame.FP()}),
+		Src: t.fieldVar(self, class.link).unEx(),
+	}
+
+	if _, ok := body.(*Nx); ok {
+		return &Nx{ir.SeqStm(link, body.unNx())}
+	}
+
+	return &Ex{&ir.EsEqExpIr{Stm: link, Exp: body.unEx()}}
+}
+
+// methodCall calls the method at index in the vtable of the object. The object is passed after a static link that
+// the callee ignores. pointer tells whether the method returns a heap pointer.
+func (t *Translate) methodCall(object TransExp, index int, exps []TransExp, isProcedure, pointer bool) TransExp {
+	r := t.tm.NewTemp()
+	args := make([]ir.ExpIr, 0, 2+len(exps))
+	args = append(args, &ir.ConstExpIr{Value: 0}, &ir.TempExpIr{Temp: r})
+	for _, e := range exps {
+		args = append(args, e.unEx())
+	}
+
+	call := &ir.CallExpIr{
+		Exp:  t.fieldVar(&Ex{&ir.MemExpIr{Mem: &ir.TempExpIr{Temp: r}}}, int32(index)).unEx(),
+		Args: args,
+		Ptr:  pointer,
+	}
+
+	obj := &ir.MoveStmIr{Dst: &ir.TempExpIr{Temp: r}, Src: object.unEx()}
+	if isProcedure {
+		return &Nx{ir.SeqStm(obj, &ir.ExpStmIr{Exp: call})}
+	}
+
+	return &Ex{&ir.EsEqExpIr{Stm: obj, Exp: call}}
+}
+
+func (t *Translate) ProcEntryExit(level *Level, body TransExp) {
+	body1 := level.frame.ProcEntryExit1(&ir.MoveStmIr{
+		Dst: &ir.TempExpIr{Temp: level.frame.RV()},
+		Src: body.unEx(),
+	})
+
+	t.frags = append(t.frags, &ir.ProcFrag{
+		Body:  body1,
+		Frame: level.frame,
+		Debug: level.debug,
+	})
+}
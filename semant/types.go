@@ -0,0 +1,285 @@
+package semant
+
+import (
+	"fmt"
+
+	"tiger/ir"
+	"tiger/syntax"
+)
+
+type SemantTy interface {
+	TypeName() string
+}
+
+type RecordSemantTy struct {
+	Symbols []syntax.Symbol
+	Types   []syntax.Symbol
+	u       int64
+}
+
+func (t *RecordSemantTy) TypeName() string {
+	return "record"
+}
+
+func (t *RecordSemantTy) HasField(field syntax.Symbol) int {
+	for i, sym := range t.Symbols {
+		if sym == field {
+			return i
+		}
+	}
+
+	return -1
+}
+
+type NilSemantTy struct{}
+
+func (t *NilSemantTy) TypeName() string {
+	return "nil"
+}
+
+type UnitSemantTy struct{}
+
+func (t *UnitSemantTy) TypeName() string {
+	return "unit"
+}
+
+type IntSemantTy struct{}
+
+func (t *IntSemantTy) TypeName() string {
+	return "int"
+}
+
+type StringSemantTy struct{}
+
+func (t *StringSemantTy) TypeName() string {
+	return "string"
+}
+
+// ErrorSemantTy is the type of a declaration that has an error. Using a value of this type fails with errReported,
+// so that the error is not reported again wherever the declaration is used.
+type ErrorSemantTy struct{}
+
+func (t *ErrorSemantTy) TypeName() string {
+	return "error"
+}
+
+type ArrSemantTy struct {
+	BaseTy SemantTy
+	u      int64
+}
+
+func (t *ArrSemantTy) TypeName() string {
+	if t.BaseTy != nil {
+		return fmt.Sprintf("array of %s", t.BaseTy.TypeName())
+	}
+
+	return "array"
+}
+
+type NameSemantTy struct {
+	BaseTy  SemantTy
+	nameSym syntax.Symbol
+	name    string
+}
+
+func (t *NameSemantTy) TypeName() string {
+	return t.name
+}
+
+// ClassSemantTy is a class. An object starts with the address of the vtable of its class, followed by a part for
+// every class from Object down to its class: the static link of the methods of that class and then the attributes it
+// declares. The parts of a subclass follow those of its super class, so an object of a subclass is laid out like an
+// object of its super class where they overlap, and its vtable starts with the methods of the super class.
+type ClassSemantTy struct {
+	name  string
+	super *ClassSemantTy
+
+	// Attrs and methods include the inherited ones, methods in vtable order
+	Attrs   []*ClassAttr
+	Methods []*ClassMethod
+
+	// link is the word that holds the static link of the methods, size the number of words of an object
+	link int32
+	size int32
+
+	// the vtable and the function that initializes the attributes of an object
+	vtable ir.Label
+	init   *FunEntry
+	u      int64
+}
+
+type ClassAttr struct {
+	Name syntax.Symbol
+
+	// Ty is nil until the initializer of the attribute is translated
+	Ty    SemantTy
+	index int32
+}
+
+type ClassMethod struct {
+	Name  syntax.Symbol
+	class *ClassSemantTy
+	Fun   *FunEntry
+}
+
+func (t *ClassSemantTy) TypeName() string {
+	return t.name
+}
+
+func (t *ClassSemantTy) Attr(name syntax.Symbol) *ClassAttr {
+	for _, attr := range t.Attrs {
+		if attr.Name == name {
+			return attr
+		}
+	}
+
+	return nil
+}
+
+// method returns the method and its index in the vtable, -1 when the class has no such method.
+func (t *ClassSemantTy) method(name syntax.Symbol) (*ClassMethod, int) {
+	for i, method := range t.Methods {
+		if method.Name == name {
+			return method, i
+		}
+	}
+
+	return nil, -1
+}
+
+// isSubclass tells whether t is super or inherits from it.
+func (t *ClassSemantTy) isSubclass(super *ClassSemantTy) bool {
+	for c := t; c != nil; c = c.super {
+		if c.u == super.u {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSameType tells whether a value of type ty2 can be used where ty1 is expected. For classes, ty2 can be a subclass
+// of ty1.
+func isSameType(ty1, ty2 SemantTy) bool {
+	switch v1 := ty1.(type) {
+	case *UnitSemantTy:
+		return isUnit(ty2)
+
+	case *NilSemantTy:
+		// TODO: is this correct?
+		return isRecord(ty2) || isClass(ty2)
+
+	case *IntSemantTy:
+		return isInt(ty2)
+
+	case *StringSemantTy:
+		return isString(ty2)
+
+	case *RecordSemantTy:
+		switch v2 := ty2.(type) {
+		case *NilSemantTy:
+			return true
+		case *RecordSemantTy:
+			return v1.u == v2.u
+		default:
+			return false
+		}
+
+	case *ArrSemantTy:
+		switch v2 := ty2.(type) {
+		case *ArrSemantTy:
+			return v2.u == v1.u && isSameType(v1.BaseTy, v2.BaseTy)
+		default:
+			return false
+		}
+
+	case *ClassSemantTy:
+		switch v2 := ty2.(type) {
+		case *NilSemantTy:
+			return true
+		case *ClassSemantTy:
+			return v2.isSubclass(v1)
+		case *NameSemantTy:
+			return isSameType(v1, v2.BaseTy)
+		default:
+			return false
+		}
+
+	case *NameSemantTy:
+		switch v2 := ty2.(type) {
+		case *NameSemantTy:
+			return isSameType(v1.BaseTy, v2.BaseTy)
+		default:
+			return isSameType(v1.BaseTy, v2)
+		}
+	}
+
+	return false
+}
+
+func isInt(ty SemantTy) bool {
+	switch v := ty.(type) {
+	case *IntSemantTy:
+		return true
+	case *NameSemantTy:
+		return isInt(v.BaseTy)
+	default:
+		return false
+	}
+}
+
+func isString(ty SemantTy) bool {
+	switch v := ty.(type) {
+	case *StringSemantTy:
+		return true
+	case *NameSemantTy:
+		return isString(v.BaseTy)
+	default:
+		return false
+	}
+}
+
+func isRecord(ty SemantTy) bool {
+	switch v := ty.(type) {
+	case *RecordSemantTy:
+		return true
+	case *NilSemantTy:
+		return true
+	case *NameSemantTy:
+		return isRecord(v.BaseTy)
+	default:
+		return false
+	}
+}
+
+func isClass(ty SemantTy) bool {
+	switch v := ty.(type) {
+	case *ClassSemantTy:
+		return true
+	case *NameSemantTy:
+		return isClass(v.BaseTy)
+	default:
+		return false
+	}
+}
+
+func isUnit(ty SemantTy) bool {
+	switch ty.(type) {
+	case *UnitSemantTy:
+		return true
+	default:
+		return false
+	}
+}
+
+// isPointer tells whether the values of ty are addresses of heap objects, which a collector has to find and update
+func isPointer(ty SemantTy) bool {
+	switch v := ty.(type) {
+	case *RecordSemantTy, *ArrSemantTy, *StringSemantTy, *ClassSemantTy:
+		return true
+	case *NameSemantTy:
+		return isPointer(v.BaseTy)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,551 @@
+package sim
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+
+	"tiger/syntax"
+)
+
+// asmSyntax is what the shared assembler needs to know about an instruction set: where the segments start, how
+// registers are spelled and how many operands every instruction takes.
+type asmSyntax struct {
+	textBase uint32
+	dataBase uint32
+
+	// regPrefix is the prefix all register names share, e.g. "$" on MIPS. An operand that starts with it but is not a
+	// register is rejected instead of being taken for a label.
+	regPrefix string
+	reg       func(name string) (int, bool)
+
+	// altArity lists the instructions that also have a second form, e.g. "jalr $rd, $rs" next to "jalr $rs".
+	arity    map[string]int
+	altArity map[string]int
+}
+
+type asmOperandKind int
+
+const (
+	asmRegOperand asmOperandKind = iota
+	asmImmOperand
+	asmLabelOperand
+	asmMemOperand
+)
+
+// asmOperand is one operand of an assembly instruction. Memory operands such as -4($fp) use both imm (the offset)
+// and reg (the base register).
+type asmOperand struct {
+	kind  asmOperandKind
+	reg   int
+	imm   int32
+	label string
+}
+
+type asmInstr struct {
+	op   string
+	args []*asmOperand
+	line int
+
+	// pos is the statement of the source that the instruction is part of, stmt tells that it is the first
+	// instruction of the statement and start that it is also the first of its line in the function, where the
+	// breakpoints are. fn is the function of the source that the instruction is in. They are only known from the
+	// debug directives.
+	pos   syntax.Pos
+	stmt  bool
+	start bool
+	fn    *asmFunc
+}
+
+// asmFunc is a function of the source, as told by its .func and .var directives.
+type asmFunc struct {
+	label  string
+	name   string
+	pos    syntax.Pos
+	parent string
+
+	// link is where the static link of the function is, nil when it has none
+	link *asmOperand
+	vars []*asmVar
+}
+
+// asmVar is a variable of a function of the source. It can be seen from pos to end.
+type asmVar struct {
+	name string
+	ty   string
+	loc  *asmOperand
+	pos  syntax.Pos
+	end  syntax.Pos
+}
+
+// AsmProgram is an assembled program: one asmInstr per text slot and the initial content of the data segment.
+type AsmProgram struct {
+	text   []*asmInstr
+	data   []byte
+	labels map[string]uint32
+
+	// fixups are the words of the data segment that hold the address of a label
+	fixups []asmFixup
+
+	// funcs are the functions of the debug directives by label. file, loc, stmt and fn are the debug information
+	// that the next instruction gets, and line is that of the last statement of fn.
+	funcs map[string]*asmFunc
+	file  string
+	loc   syntax.Pos
+	stmt  bool
+	fn    *asmFunc
+	line  int
+}
+
+type asmFixup struct {
+	offset int
+	label  string
+	line   int
+}
+
+// assemble parses the assembly emitted by the compiler (the runtime followed by the output of emit) and resolves
+// every label. Each source instruction occupies one word in the text segment, pseudo instructions included.
+func assemble(src string, syntax *asmSyntax) (*AsmProgram, error) {
+	prog := &AsmProgram{labels: make(map[string]uint32), funcs: make(map[string]*asmFunc)}
+	inText := true
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripAsmComment(scanner.Text()))
+		for {
+			label, rest, ok := splitAsmLabel(line)
+			if !ok {
+				break
+			}
+
+			if _, ok := prog.labels[label]; ok {
+				return nil, duplicateAsmLabelErr(label, lineNo)
+			}
+
+			if inText {
+				prog.labels[label] = syntax.textBase + uint32(len(prog.text))*wordSize
+			} else {
+				prog.labels[label] = syntax.dataBase + uint32(len(prog.data))
+			}
+
+			line = rest
+		}
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] == '.' {
+			var err error
+			inText, err = prog.directive(line, inText, lineNo)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if !inText {
+			return nil, invalidAsmErr("instruction in data segment", lineNo)
+		}
+
+		instr, err := parseAsmInstr(line, lineNo, syntax)
+		if err != nil {
+			return nil, err
+		}
+
+		instr.pos, instr.stmt, instr.fn = prog.loc, prog.stmt, prog.fn
+		if prog.stmt {
+			instr.start = prog.loc.Line != prog.line
+			prog.stmt, prog.line = false, prog.loc.Line
+		}
+
+		prog.text = append(prog.text, instr)
+	}
+
+	for _, instr := range prog.text {
+		for _, arg := range instr.args {
+			if arg.kind != asmLabelOperand {
+				continue
+			}
+
+			addr, ok := prog.labels[arg.label]
+			if !ok {
+				return nil, undefinedAsmLabelErr(arg.label, instr.line)
+			}
+
+			arg.imm = int32(addr)
+		}
+	}
+
+	for _, fixup := range prog.fixups {
+		addr, ok := prog.labels[fixup.label]
+		if !ok {
+			return nil, undefinedAsmLabelErr(fixup.label, fixup.line)
+		}
+
+		prog.data[fixup.offset] = byte(addr)
+		prog.data[fixup.offset+1] = byte(addr >> 8)
+		prog.data[fixup.offset+2] = byte(addr >> 16)
+		prog.data[fixup.offset+3] = byte(addr >> 24)
+	}
+
+	return prog, nil
+}
+
+func (p *AsmProgram) directive(line string, inText bool, lineNo int) (bool, error) {
+	name, rest := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, rest = line[:i], strings.TrimSpace(line[i:])
+	}
+
+	switch name {
+	case ".text":
+		return true, nil
+	case ".data":
+		return false, nil
+	case ".globl":
+		return inText, nil
+	case ".align":
+		// the text segment only holds words
+		if inText {
+			return inText, nil
+		}
+
+		n, err := strconv.ParseInt(rest, 0, 32)
+		if err != nil || n < 0 || n > 16 {
+			return inText, invalidAsmErr("invalid alignment "+rest, lineNo)
+		}
+
+		for len(p.data)%(1<<n) != 0 {
+			p.data = append(p.data, 0)
+		}
+
+		return inText, nil
+	case ".asciiz", ".asciz", ".ascii":
+		if inText {
+			return inText, invalidAsmErr(name+" in text segment", lineNo)
+		}
+
+		s, ok := unquoteAsmString(rest)
+		if !ok {
+			return inText, invalidAsmErr("invalid string literal "+rest, lineNo)
+		}
+
+		p.data = append(p.data, s...)
+		if name != ".ascii" {
+			p.data = append(p.data, 0)
+		}
+
+		return inText, nil
+	case ".word", ".space":
+		if inText {
+			return inText, invalidAsmErr(name+" in text segment", lineNo)
+		}
+
+		for _, f := range splitAsmOperands(rest) {
+			n, err := strconv.ParseInt(f, 0, 32)
+			if err != nil && name == ".word" && isAsmLabel(f) {
+				// the address of the label is only known at the end
+				for len(p.data)%wordSize != 0 {
+					p.data = append(p.data, 0)
+				}
+
+				p.fixups = append(p.fixups, asmFixup{offset: len(p.data), label: f, line: lineNo})
+				p.data = append(p.data, 0, 0, 0, 0)
+				continue
+			}
+
+			if err != nil {
+				return inText, invalidAsmErr("invalid number "+f, lineNo)
+			}
+
+			if name == ".space" {
+				p.data = append(p.data, make([]byte, n)...)
+				continue
+			}
+
+			for len(p.data)%wordSize != 0 {
+				p.data = append(p.data, 0)
+			}
+
+			p.data = append(p.data, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+		}
+
+		return inText, nil
+	}
+
+	if name == ".file" || name == ".func" || name == ".var" || name == ".loc" {
+		return inText, p.debugDirective(name, rest, lineNo)
+	}
+
+	return inText, invalidAsmErr("unknown directive "+name, lineNo)
+}
+
+// debugDirective reads a directive of the debug information that the compiler emits with the option Debug:
+//
+//	.file	"prog.tig"
+//	.func	label	name	parent	link	line	col
+//	.var	name	"type"	location	line	col	endLine	endCol
+//	.loc	line	col
+//
+// .file names the source of the positions that follow. .func starts a function of the source: its label, its name,
+// the label of the function it is nested in and the location of its static link, - for none, and where it is
+// declared. .var gives a variable of the function, its location and the positions where its scope starts and ends.
+// .loc tells that the instructions that follow are those of the statement at line and col. Locations are operands, a
+// register or a word of the frame of mips, the only target that emits them.
+func (p *AsmProgram) debugDirective(name, rest string, lineNo int) error {
+	if name == ".file" {
+		file, ok := unquoteAsmString(rest)
+		if !ok {
+			return invalidAsmErr("invalid file name "+rest, lineNo)
+		}
+
+		p.file = file
+		return nil
+	}
+
+	// the type of a variable is quoted, as in "array of int"
+	var ty string
+	if name == ".var" {
+		i, j := strings.IndexByte(rest, '"'), strings.LastIndexByte(rest, '"')
+		if i < 0 || j <= i {
+			return invalidAsmErr("missing type of variable", lineNo)
+		}
+
+		var ok bool
+		if ty, ok = unquoteAsmString(rest[i : j+1]); !ok {
+			return invalidAsmErr("invalid type "+rest[i:j+1], lineNo)
+		}
+
+		rest = rest[:i] + " " + rest[j+1:]
+	}
+
+	fields := splitAsmOperands(rest)
+	// the operands end with the line and the column of the positions
+	arity := map[string]int{".func": 6, ".var": 6, ".loc": 2}[name]
+	numbers := map[string]int{".func": 2, ".var": 4, ".loc": 2}[name]
+	if len(fields) != arity {
+		return invalidAsmErr("wrong number of operands for "+name, lineNo)
+	}
+
+	nums := make([]int, 0, numbers)
+	for _, f := range fields[arity-numbers:] {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return invalidAsmErr("invalid number "+f, lineNo)
+		}
+
+		nums = append(nums, n)
+	}
+
+	switch name {
+	case ".func":
+		fn := &asmFunc{label: fields[0], name: fields[1], parent: fields[2], pos: p.pos(nums[0], nums[1])}
+		if fields[3] != "-" {
+			link, err := parseAsmOperand(fields[3], lineNo, mipsSyntax)
+			if err != nil {
+				return err
+			}
+
+			fn.link = link
+		}
+
+		// the prologue is in none of the statements
+		p.funcs[fn.label], p.fn, p.loc, p.stmt, p.line = fn, fn, syntax.Pos{}, false, 0
+	case ".var":
+		if p.fn == nil {
+			return invalidAsmErr(".var outside of a function", lineNo)
+		}
+
+		loc, err := parseAsmOperand(fields[1], lineNo, mipsSyntax)
+		if err != nil {
+			return err
+		}
+
+		p.fn.vars = append(p.fn.vars, &asmVar{
+			name: fields[0],
+			ty:   ty,
+			loc:  loc,
+			pos:  p.pos(nums[0], nums[1]),
+			end:  p.pos(nums[2], nums[3]),
+		})
+	case ".loc":
+		p.loc, p.stmt = p.pos(nums[0], nums[1]), true
+	}
+
+	return nil
+}
+
+func (p *AsmProgram) pos(line, col int) syntax.Pos {
+	return syntax.Pos{FileName: p.file, Line: line, Col: col}
+}
+
+func parseAsmInstr(line string, lineNo int, syntax *asmSyntax) (*asmInstr, error) {
+	op, rest := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		op, rest = line[:i], line[i:]
+	}
+
+	instr := &asmInstr{op: op, line: lineNo}
+	for _, f := range splitAsmOperands(rest) {
+		arg, err := parseAsmOperand(f, lineNo, syntax)
+		if err != nil {
+			return nil, err
+		}
+
+		instr.args = append(instr.args, arg)
+	}
+
+	n, ok := syntax.arity[op]
+	if !ok {
+		return nil, invalidAsmErr("unknown instruction "+op, lineNo)
+	}
+
+	if alt, ok := syntax.altArity[op]; n != len(instr.args) && (!ok || alt != len(instr.args)) {
+		return nil, invalidAsmErr("wrong number of operands for "+op, lineNo)
+	}
+
+	return instr, nil
+}
+
+func parseAsmOperand(f string, lineNo int, syntax *asmSyntax) (*asmOperand, error) {
+	if reg, ok := syntax.reg(f); ok {
+		return &asmOperand{kind: asmRegOperand, reg: reg}, nil
+	}
+
+	if syntax.regPrefix != "" && strings.HasPrefix(f, syntax.regPrefix) {
+		return nil, invalidAsmErr("unknown register "+f, lineNo)
+	}
+
+	if i := strings.IndexByte(f, '('); i >= 0 {
+		if f[len(f)-1] != ')' {
+			return nil, invalidAsmErr("invalid memory operand "+f, lineNo)
+		}
+
+		var offset int64
+		if i > 0 {
+			var err error
+			offset, err = strconv.ParseInt(f[:i], 0, 32)
+			if err != nil {
+				return nil, invalidAsmErr("invalid memory offset "+f, lineNo)
+			}
+		}
+
+		reg, ok := syntax.reg(f[i+1 : len(f)-1])
+		if !ok {
+			return nil, invalidAsmErr("unknown register "+f, lineNo)
+		}
+
+		return &asmOperand{kind: asmMemOperand, reg: reg, imm: int32(offset)}, nil
+	}
+
+	if n, err := strconv.ParseInt(f, 0, 64); err == nil {
+		return &asmOperand{kind: asmImmOperand, imm: int32(n)}, nil
+	}
+
+	return &asmOperand{kind: asmLabelOperand, label: f}, nil
+}
+
+// splitAsmOperands splits "a, b c" style operand lists. Both the commas of the runtimes and the tabs of the prologues
+// generated by ProcEntryExit3 are accepted as separators.
+func splitAsmOperands(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+func splitAsmLabel(line string) (string, string, bool) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return "", "", false
+	}
+
+	if !isAsmLabel(line[:i]) {
+		return "", "", false
+	}
+
+	return line[:i], strings.TrimSpace(line[i+1:]), true
+}
+
+func isAsmLabel(s string) bool {
+	for j := 0; j < len(s); j++ {
+		if !syntax.IsAlphaNumeric(s[j]) && !syntax.IsUnderscore(s[j]) && s[j] != '.' && s[j] != '$' {
+			return false
+		}
+	}
+
+	return len(s) > 0
+}
+
+func stripAsmComment(line string) string {
+	inStr := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inStr {
+				i++
+			}
+		case '"':
+			inStr = !inStr
+		case '#':
+			if !inStr {
+				return line[:i]
+			}
+		}
+	}
+
+	return line
+}
+
+// unquoteAsmString decodes the escapes that the string fragments use: \n, \t, \0, \", \', \\ and three digit octal
+// codes.
+func unquoteAsmString(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false
+	}
+
+	s = s[1 : len(s)-1]
+	sb := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		if i >= len(s) {
+			return "", false
+		}
+
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case '"', '\'', '\\':
+			sb.WriteByte(s[i])
+		default:
+			if !syntax.IsNumeric(s[i]) {
+				return "", false
+			}
+
+			j := i
+			for j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+
+			n, err := strconv.ParseUint(s[i:j], 8, 8)
+			if err != nil {
+				return "", false
+			}
+
+			sb.WriteByte(byte(n))
+			i = j - 1
+		}
+	}
+
+	return sb.String(), true
+}
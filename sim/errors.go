@@ -0,0 +1,75 @@
+package sim
+
+import (
+	"fmt"
+)
+
+// Simulator errors
+func invalidAsmErr(msg string, line int) error {
+	return fmt.Errorf("%s at assembly line %d", msg, line)
+}
+
+func duplicateAsmLabelErr(label string, line int) error {
+	return fmt.Errorf("duplicate label %s at assembly line %d", label, line)
+}
+
+func undefinedAsmLabelErr(label string, line int) error {
+	return fmt.Errorf("undefined label %s at assembly line %d", label, line)
+}
+
+func unknownInstrErr(op string) error {
+	return fmt.Errorf("unknown instruction %s", op)
+}
+
+func unknownSyscallErr(code int32) error {
+	return fmt.Errorf("unknown syscall %d", code)
+}
+
+func invalidPcErr(pc uint32) error {
+	return fmt.Errorf("jump to invalid address 0x%08x", pc)
+}
+
+func unalignedAccessErr(addr uint32) error {
+	return fmt.Errorf("unaligned memory access at 0x%08x", addr)
+}
+
+func divisionByZeroErr() error {
+	return fmt.Errorf("division by zero")
+}
+
+func stepLimitErr(steps int) error {
+	return fmt.Errorf("program did not terminate after %d instructions", steps)
+}
+
+// Debugger errors
+func noDebugInfoErr() error {
+	return fmt.Errorf("the program is not compiled with the debug information")
+}
+
+func runtimeDebugErr(err error) error {
+	return fmt.Errorf("runtime error %v", err)
+}
+
+func noStatementErr(file string, line int) error {
+	return fmt.Errorf("no statement at %s:%d", file, line)
+}
+
+func notRunningErr() error {
+	return fmt.Errorf("the program is not running")
+}
+
+func notStoppedErr() error {
+	return fmt.Errorf("the program is not stopped at a statement")
+}
+
+func undefinedDebugVarErr(name string) error {
+	return fmt.Errorf("no variable %s here", name)
+}
+
+func unavailableDebugVarErr() error {
+	return fmt.Errorf("the variable is in a register of a frame that is not the innermost one")
+}
+
+func outermostFrameErr() error {
+	return fmt.Errorf("finish is meaningless in the outermost frame")
+}
@@ -0,0 +1,52 @@
+package sim
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	mipsTextBase  = 0x00400000
+	mipsDataBase  = 0x10010000
+	mipsStackBase = 0x7ffffffc
+)
+
+var mipsRegNames = map[string]int{
+	"zero": 0, "at": 1, "v0": 2, "v1": 3,
+	"a0": 4, "a1": 5, "a2": 6, "a3": 7,
+	"t0": 8, "t1": 9, "t2": 10, "t3": 11, "t4": 12, "t5": 13, "t6": 14, "t7": 15,
+	"s0": 16, "s1": 17, "s2": 18, "s3": 19, "s4": 20, "s5": 21, "s6": 22, "s7": 23,
+	"t8": 24, "t9": 25, "k0": 26, "k1": 27,
+	"gp": 28, "sp": 29, "fp": 30, "s8": 30, "ra": 31,
+}
+
+var mipsSyntax = &asmSyntax{
+	textBase:  mipsTextBase,
+	dataBase:  mipsDataBase,
+	regPrefix: "$",
+	reg:       parseMipsReg,
+	arity:     mipsArity,
+	altArity:  mipsAltArity,
+}
+
+// AssembleMips assembles the SPIM flavoured output of the MIPS backend.
+func AssembleMips(src string) (*AsmProgram, error) {
+	return assemble(src, mipsSyntax)
+}
+
+func parseMipsReg(f string) (int, bool) {
+	if !strings.HasPrefix(f, "$") {
+		return 0, false
+	}
+
+	name := f[1:]
+	if reg, ok := mipsRegNames[name]; ok {
+		return reg, true
+	}
+
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n < 32 {
+		return n, true
+	}
+
+	return 0, false
+}
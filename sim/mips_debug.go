@@ -0,0 +1,364 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"tiger/syntax"
+)
+
+// Debugger runs a program compiled with the debug information on the MIPS simulator and stops it at the
+// statements of the source. It follows the calls and the returns of the program to know how deep it is, which is what
+// stepping over and out of the functions needs, and reads the variables from the frames that .var locates them in.
+type Debugger struct {
+	prog   *AsmProgram
+	stdin  io.Reader
+	stdout io.Writer
+	sim    *Mips
+
+	// MaxSteps stops runaway programs. Zero means no limit.
+	MaxSteps int
+
+	// calls are the addresses of the calls that the program is in, the innermost last
+	calls  []uint32
+	breaks map[debugLine]bool
+
+	// last is the statement that the program passed last and depth how deep it was
+	last  syntax.Pos
+	depth int
+}
+
+// debugLine is a line of a source, where a breakpoint is.
+type debugLine struct {
+	file string
+	line int
+}
+
+// DebugFrame is a function that the program is in and the statement it is at, or that it called from.
+type DebugFrame struct {
+	Func string
+	Pos  syntax.Pos
+}
+
+func NewDebugger(prog *AsmProgram, stdin io.Reader, stdout io.Writer) (*Debugger, error) {
+	if _, ok := prog.funcs["main"]; !ok {
+		return nil, noDebugInfoErr()
+	}
+
+	d := &Debugger{prog: prog, stdin: stdin, stdout: stdout, breaks: make(map[debugLine]bool)}
+	return d, d.Restart()
+}
+
+// File is the source of the main program, that of the breakpoints given without one.
+func (d *Debugger) File() string {
+	return d.prog.funcs["main"].pos.FileName
+}
+
+// Restart starts the program again, stopped before its first statement. The breakpoints are kept.
+func (d *Debugger) Restart() error {
+	d.sim = NewMips(d.prog, d.stdin, d.stdout)
+	d.calls, d.last, d.depth = nil, syntax.Pos{}, 0
+	return d.sim.start()
+}
+
+// Break sets a breakpoint at a line of file, the source of the main program when it is empty, and returns the file.
+func (d *Debugger) Break(file string, line int) (string, error) {
+	if file == "" {
+		file = d.File()
+	}
+
+	for _, instr := range d.prog.text {
+		if instr.start && instr.pos.FileName == file && instr.pos.Line == line {
+			d.breaks[debugLine{file: file, line: line}] = true
+			return file, nil
+		}
+	}
+
+	return "", noStatementErr(file, line)
+}
+
+// Delete removes the breakpoint at a line of file and tells whether there was one.
+func (d *Debugger) Delete(file string, line int) bool {
+	if file == "" {
+		file = d.File()
+	}
+
+	key := debugLine{file: file, line: line}
+	ok := d.breaks[key]
+	delete(d.breaks, key)
+	return ok
+}
+
+// Exited tells whether the program has ended, and its exit code.
+func (d *Debugger) Exited() (int, bool) {
+	return d.sim.exitCode, d.sim.halted
+}
+
+// Pos is the statement that the program is stopped at.
+func (d *Debugger) Pos() syntax.Pos {
+	return d.last
+}
+
+// Continue runs the program up to a breakpoint, at the first statement of its line, or to its end.
+func (d *Debugger) Continue() error {
+	return d.run(0, func(instr *asmInstr, depth int) bool {
+		return instr.start && d.breaks[debugLine{file: instr.pos.FileName, line: instr.pos.Line}]
+	})
+}
+
+// Step runs the program up to the next line, in the function that it calls if any, or right after the return of the
+// function it is in.
+func (d *Debugger) Step() error {
+	return d.run(d.depth, func(instr *asmInstr, depth int) bool {
+		return instr.pos.Line != d.last.Line || instr.pos.FileName != d.last.FileName || depth != d.depth
+	})
+}
+
+// Next runs the program up to the next line of the function it is in, or right after its return in its caller.
+func (d *Debugger) Next() error {
+	line, depth := d.last, d.depth
+	return d.run(depth, func(instr *asmInstr, at int) bool {
+		return at == depth && (instr.pos.Line != line.Line || instr.pos.FileName != line.FileName)
+	})
+}
+
+// Finish runs the program until the function it is in returns, and stops right after the return, in the statement of
+// its caller that made the call.
+func (d *Debugger) Finish() error {
+	if _, err := d.stopped(); err != nil {
+		return err
+	}
+
+	depth := d.depth
+	if depth == 0 {
+		return outermostFrameErr()
+	}
+
+	return d.run(depth, func(instr *asmInstr, at int) bool {
+		return false
+	})
+}
+
+// run executes the program up to a statement where stop is true, or to its end. It also stops at the first
+// instruction after a return below depth, before the caller goes on to another call. The statements passed along the
+// way are remembered, for the stepping to tell whether the program has come to a line from another one.
+func (d *Debugger) run(depth int, stop func(instr *asmInstr, depth int) bool) error {
+	if d.sim.halted {
+		return notRunningErr()
+	}
+
+	d.sim.MaxSteps = d.MaxSteps
+	for {
+		if err := d.step(); err != nil {
+			return runtimeDebugErr(err)
+		}
+
+		if d.sim.halted {
+			return nil
+		}
+
+		instr := d.current()
+		if instr == nil || instr.fn == nil {
+			continue
+		}
+
+		if len(d.calls) < depth {
+			d.last, d.depth = instr.pos, len(d.calls)
+			return nil
+		}
+
+		if !instr.stmt {
+			continue
+		}
+
+		halt := stop(instr, len(d.calls))
+		d.last, d.depth = instr.pos, len(d.calls)
+		if halt {
+			return nil
+		}
+	}
+}
+
+// step executes an instruction and follows the calls: a jump and link enters a call, and a jump to the return address
+// of a call leaves it along with those it made.
+func (d *Debugger) step() error {
+	instr, pc := d.current(), d.sim.pc
+	if err := d.sim.Step(); err != nil {
+		return err
+	}
+
+	if instr == nil {
+		return nil
+	}
+
+	switch instr.op {
+	case "jal", "jalr":
+		d.calls = append(d.calls, pc)
+	case "jr":
+		for i := len(d.calls) - 1; i >= 0; i-- {
+			if d.calls[i]+wordSize == d.sim.pc {
+				d.calls = d.calls[:i]
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// current is the instruction at the pc, nil when the pc is out of the text.
+func (d *Debugger) current() *asmInstr {
+	pc := d.sim.pc
+	idx := (pc - mipsTextBase) / wordSize
+	if pc < mipsTextBase || pc%wordSize != 0 || int(idx) >= len(d.prog.text) {
+		return nil
+	}
+
+	return d.prog.text[idx]
+}
+
+// stopped is the instruction that the program is stopped at, in a statement: its first instruction, or the one
+// after a return.
+func (d *Debugger) stopped() (*asmInstr, error) {
+	if d.sim.halted {
+		return nil, notRunningErr()
+	}
+
+	instr := d.current()
+	if instr == nil || instr.fn == nil || instr.pos.Line == 0 {
+		return nil, notStoppedErr()
+	}
+
+	return instr, nil
+}
+
+// Backtrace is the functions that the program is in, the innermost first. The functions of the runtime are left out.
+func (d *Debugger) Backtrace() ([]DebugFrame, error) {
+	instr, err := d.stopped()
+	if err != nil {
+		return nil, err
+	}
+
+	frames := []DebugFrame{{Func: instr.fn.name, Pos: instr.pos}}
+	for i := len(d.calls) - 1; i >= 0; i-- {
+		call := d.prog.text[(d.calls[i]-mipsTextBase)/wordSize]
+		if call.fn != nil {
+			frames = append(frames, DebugFrame{Func: call.fn.name, Pos: call.pos})
+		}
+	}
+
+	return frames, nil
+}
+
+// Print is the value of the variable name that the statement where the program is stopped sees. The variables of
+// the functions it is nested in are found through the static links, in their frames since they escape.
+func (d *Debugger) Print(name string) (string, error) {
+	instr, err := d.stopped()
+	if err != nil {
+		return "", err
+	}
+
+	fn, pos, fp, top := instr.fn, instr.pos, uint32(d.sim.regs[regFp]), true
+	for fn != nil {
+		if v := fn.lookup(name, pos); v != nil {
+			return d.value(v, fp, top)
+		}
+
+		if fn.link == nil {
+			break
+		}
+
+		link, err := d.read(fn.link, fp, top)
+		if err != nil {
+			return "", err
+		}
+
+		// the function sees the variables of its parent that are declared before it
+		fn, pos, fp, top = d.prog.funcs[fn.parent], fn.pos, uint32(link), false
+	}
+
+	return "", undefinedDebugVarErr(name)
+}
+
+// Locals are the variables that the statement where the program is stopped sees in its function, as name: type =
+// value, in the order of their declarations.
+func (d *Debugger) Locals() ([]string, error) {
+	instr, err := d.stopped()
+	if err != nil {
+		return nil, err
+	}
+
+	fp := uint32(d.sim.regs[regFp])
+	var locals []string
+	for _, v := range instr.fn.vars {
+		if instr.fn.lookup(v.name, instr.pos) != v {
+			continue
+		}
+
+		value, err := d.value(v, fp, true)
+		if err != nil {
+			value = err.Error()
+		}
+
+		locals = append(locals, v.name+": "+v.ty+" = "+value)
+	}
+
+	return locals, nil
+}
+
+// lookup is the variable name seen at pos, the one of the innermost scope.
+func (f *asmFunc) lookup(name string, pos syntax.Pos) *asmVar {
+	var found *asmVar
+	for _, v := range f.vars {
+		if v.name != name || v.pos.FileName != pos.FileName || !posBefore(v.pos, pos) || posBefore(v.end, pos) {
+			continue
+		}
+
+		if found == nil || posBefore(found.pos, v.pos) {
+			found = v
+		}
+	}
+
+	return found
+}
+
+// read is the word at loc, in the frame at fp. Registers are only those of the innermost frame, top.
+func (d *Debugger) read(loc *asmOperand, fp uint32, top bool) (int32, error) {
+	if loc.kind == asmRegOperand {
+		if !top {
+			return 0, unavailableDebugVarErr()
+		}
+
+		return d.sim.regs[loc.reg], nil
+	}
+
+	if loc.reg != regFp {
+		return 0, unavailableDebugVarErr()
+	}
+
+	return d.sim.loadWord(fp + uint32(loc.imm))
+}
+
+// value shows the value of v: integers in decimal, strings quoted and the other values as addresses.
+func (d *Debugger) value(v *asmVar, fp uint32, top bool) (string, error) {
+	w, err := d.read(v.loc, fp, top)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case v.ty == "int":
+		return strconv.Itoa(int(w)), nil
+	case v.ty == "string":
+		return strconv.Quote(string(d.sim.loadBytes(uint32(w), -1))), nil
+	case w == 0:
+		return "nil", nil
+	default:
+		return fmt.Sprintf("0x%08x", uint32(w)), nil
+	}
+}
+
+func posBefore(a, b syntax.Pos) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+}
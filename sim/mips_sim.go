@@ -0,0 +1,383 @@
+package sim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+const (
+	// mipsExitAddress is the return address main is started with. Returning to it halts the simulator.
+	mipsExitAddress = 0
+
+	regZero = 0
+	regV0   = 2
+	regA0   = 4
+	regA1   = 5
+	regGp   = 28
+	regSp   = 29
+	regFp   = 30
+	regRa   = 31
+)
+
+// Mips executes an assembled MIPS program. It implements the instructions used by mips_gen.go and
+// runtime/src/runtime.s, along with the SPIM syscalls the runtime relies on.
+type Mips struct {
+	simMemory
+
+	prog   *AsmProgram
+	regs   [32]int32
+	hi, lo int32
+	pc     uint32
+	brk    uint32
+
+	stdin  *bufio.Reader
+	stdout io.Writer
+
+	// MaxSteps stops runaway programs. Zero means no limit.
+	MaxSteps int
+	steps    int
+	halted   bool
+	exitCode int
+}
+
+func NewMips(prog *AsmProgram, stdin io.Reader, stdout io.Writer) *Mips {
+	sim := &Mips{
+		simMemory: newSimMemory(),
+		prog:      prog,
+		stdin:     bufio.NewReader(stdin),
+		stdout:    stdout,
+	}
+
+	for i, b := range prog.data {
+		sim.storeByte(mipsDataBase+uint32(i), b)
+	}
+
+	sim.brk = (mipsDataBase + uint32(len(prog.data)) + wordSize - 1) &^ (wordSize - 1)
+	sim.regs[regSp] = mipsStackBase
+	sim.regs[regGp] = 0x10008000
+	sim.regs[regRa] = mipsExitAddress
+	return sim
+}
+
+// Run starts executing at the main label and returns the exit code of the program.
+func (s *Mips) Run() (int, error) {
+	if err := s.start(); err != nil {
+		return 0, err
+	}
+
+	for !s.halted {
+		if err := s.Step(); err != nil {
+			return 0, err
+		}
+	}
+
+	return s.exitCode, nil
+}
+
+// start points the pc at the main label.
+func (s *Mips) start() error {
+	entry, ok := s.prog.labels["main"]
+	if !ok {
+		return undefinedAsmLabelErr("main", 0)
+	}
+
+	s.pc = entry
+	return nil
+}
+
+// Step executes one instruction.
+func (s *Mips) Step() error {
+	if s.pc == mipsExitAddress {
+		s.halted = true
+		return nil
+	}
+
+	idx := (s.pc - mipsTextBase) / wordSize
+	if s.pc < mipsTextBase || s.pc%wordSize != 0 || int(idx) >= len(s.prog.text) {
+		return invalidPcErr(s.pc)
+	}
+
+	s.steps++
+	if s.MaxSteps > 0 && s.steps > s.MaxSteps {
+		return stepLimitErr(s.MaxSteps)
+	}
+
+	instr := s.prog.text[idx]
+	next := s.pc + wordSize
+	if err := s.exec(instr, &next); err != nil {
+		return fmt.Errorf("%v (line %d: %s)", err, instr.line, instr.op)
+	}
+
+	s.regs[regZero] = 0
+	s.pc = next
+	return nil
+}
+
+func (s *Mips) reg(op *asmOperand) int32 {
+	return s.regs[op.reg]
+}
+
+// val is the value of a register or an immediate operand, SPIM accepts both for most arithmetic pseudo instructions.
+func (s *Mips) val(op *asmOperand) int32 {
+	if op.kind == asmRegOperand {
+		return s.regs[op.reg]
+	}
+
+	return op.imm
+}
+
+func (s *Mips) addr(op *asmOperand) uint32 {
+	switch op.kind {
+	case asmMemOperand:
+		return uint32(s.regs[op.reg] + op.imm)
+	default:
+		return uint32(op.imm)
+	}
+}
+
+func (s *Mips) exec(instr *asmInstr, next *uint32) error {
+	args := instr.args
+	set := func(v int32) {
+		s.regs[args[0].reg] = v
+	}
+
+	branch := func(cond bool) {
+		if cond {
+			*next = uint32(args[len(args)-1].imm)
+		}
+	}
+
+	switch instr.op {
+	case "nop":
+	case "li", "la":
+		set(args[1].imm)
+	case "lui":
+		set(args[1].imm << 16)
+	case "move":
+		set(s.reg(args[1]))
+	case "add", "addu", "addi", "addiu":
+		set(s.reg(args[1]) + s.val(args[2]))
+	case "sub", "subu":
+		set(s.reg(args[1]) - s.val(args[2]))
+	case "mul":
+		set(s.reg(args[1]) * s.val(args[2]))
+	case "mult":
+		p := int64(s.reg(args[0])) * int64(s.reg(args[1]))
+		s.hi, s.lo = int32(p>>32), int32(p)
+	case "div", "divu", "rem", "remu":
+		if len(args) == 2 {
+			d := s.reg(args[1])
+			if d == 0 {
+				return divisionByZeroErr()
+			}
+
+			s.lo, s.hi = divRem(instr.op, s.reg(args[0]), d)
+			return nil
+		}
+
+		d := s.val(args[2])
+		if d == 0 {
+			return divisionByZeroErr()
+		}
+
+		q, r := divRem(instr.op, s.reg(args[1]), d)
+		if instr.op == "div" || instr.op == "divu" {
+			set(q)
+		} else {
+			set(r)
+		}
+	case "mflo":
+		set(s.lo)
+	case "mfhi":
+		set(s.hi)
+	case "neg", "negu":
+		set(-s.reg(args[1]))
+	case "not":
+		set(^s.reg(args[1]))
+	case "and", "andi":
+		set(s.reg(args[1]) & s.val(args[2]))
+	case "or", "ori":
+		set(s.reg(args[1]) | s.val(args[2]))
+	case "xor", "xori":
+		set(s.reg(args[1]) ^ s.val(args[2]))
+	case "nor":
+		set(^(s.reg(args[1]) | s.val(args[2])))
+	case "sll", "sllv":
+		set(s.reg(args[1]) << (uint32(s.val(args[2])) & 31))
+	case "srl", "srlv":
+		set(int32(uint32(s.reg(args[1])) >> (uint32(s.val(args[2])) & 31)))
+	case "sra", "srav":
+		set(s.reg(args[1]) >> (uint32(s.val(args[2])) & 31))
+	case "slt", "slti":
+		set(boolToInt32(s.reg(args[1]) < s.val(args[2])))
+	case "sltu", "sltiu":
+		set(boolToInt32(uint32(s.reg(args[1])) < uint32(s.val(args[2]))))
+	case "seq":
+		set(boolToInt32(s.reg(args[1]) == s.val(args[2])))
+	case "sne":
+		set(boolToInt32(s.reg(args[1]) != s.val(args[2])))
+	case "lw":
+		v, err := s.loadWord(s.addr(args[1]))
+		if err != nil {
+			return err
+		}
+
+		set(v)
+	case "lb":
+		set(int32(int8(s.loadByte(s.addr(args[1])))))
+	case "lbu":
+		set(int32(s.loadByte(s.addr(args[1]))))
+	case "sw":
+		return s.storeWord(s.addr(args[1]), s.reg(args[0]))
+	case "sb":
+		s.storeByte(s.addr(args[1]), byte(s.reg(args[0])))
+	case "b", "j":
+		branch(true)
+	case "jal":
+		s.regs[regRa] = int32(s.pc + wordSize)
+		branch(true)
+	case "jr":
+		*next = uint32(s.reg(args[0]))
+	case "jalr":
+		target := uint32(s.reg(args[len(args)-1]))
+		if len(args) == 2 {
+			s.regs[args[0].reg] = int32(s.pc + wordSize)
+		} else {
+			s.regs[regRa] = int32(s.pc + wordSize)
+		}
+
+		*next = target
+	case "beq":
+		branch(s.reg(args[0]) == s.val(args[1]))
+	case "bne":
+		branch(s.reg(args[0]) != s.val(args[1]))
+	case "blt":
+		branch(s.reg(args[0]) < s.val(args[1]))
+	case "ble":
+		branch(s.reg(args[0]) <= s.val(args[1]))
+	case "bgt":
+		branch(s.reg(args[0]) > s.val(args[1]))
+	case "bge":
+		branch(s.reg(args[0]) >= s.val(args[1]))
+	case "beqz":
+		branch(s.reg(args[0]) == 0)
+	case "bnez":
+		branch(s.reg(args[0]) != 0)
+	case "bltz":
+		branch(s.reg(args[0]) < 0)
+	case "blez":
+		branch(s.reg(args[0]) <= 0)
+	case "bgtz":
+		branch(s.reg(args[0]) > 0)
+	case "bgez":
+		branch(s.reg(args[0]) >= 0)
+	case "syscall":
+		return s.syscall()
+	default:
+		return unknownInstrErr(instr.op)
+	}
+
+	return nil
+}
+
+// mipsArity is the number of operands of every supported instruction. mipsAltArity lists the instructions that
+// also have a second form, e.g. "jalr $rd, $rs" and the two operand "div $rs, $rt" that writes hi and lo.
+var mipsArity = map[string]int{
+	"nop": 0, "syscall": 0,
+	"li": 2, "la": 2, "lui": 2, "move": 2, "neg": 2, "negu": 2, "not": 2, "mflo": 1, "mfhi": 1, "mult": 2,
+	"add": 3, "addu": 3, "addi": 3, "addiu": 3, "sub": 3, "subu": 3, "mul": 3, "div": 3, "divu": 3, "rem": 3,
+	"remu": 3, "and": 3, "andi": 3, "or": 3, "ori": 3, "xor": 3, "xori": 3, "nor": 3, "sll": 3, "sllv": 3,
+	"srl": 3, "srlv": 3, "sra": 3, "srav": 3, "slt": 3, "slti": 3, "sltu": 3, "sltiu": 3, "seq": 3, "sne": 3,
+	"lw": 2, "lb": 2, "lbu": 2, "sw": 2, "sb": 2,
+	"b": 1, "j": 1, "jal": 1, "jr": 1, "jalr": 1,
+	"beq": 3, "bne": 3, "blt": 3, "ble": 3, "bgt": 3, "bge": 3,
+	"beqz": 2, "bnez": 2, "bltz": 2, "blez": 2, "bgtz": 2, "bgez": 2,
+}
+
+var mipsAltArity = map[string]int{
+	"jalr": 2, "div": 2, "divu": 2,
+}
+
+const (
+	syscallPrintInt    = 1
+	syscallPrintString = 4
+	syscallReadInt     = 5
+	syscallReadString  = 8
+	syscallSbrk        = 9
+	syscallExit        = 10
+	syscallPrintChar   = 11
+	syscallReadChar    = 12
+	syscallExit2       = 17
+)
+
+func (s *Mips) syscall() error {
+	a0 := s.regs[regA0]
+	switch s.regs[regV0] {
+	case syscallPrintInt:
+		fmt.Fprintf(s.stdout, "%d", a0)
+
+	case syscallPrintString:
+		s.stdout.Write(s.loadBytes(uint32(a0), -1))
+
+	case syscallReadInt:
+		var n int32
+		fmt.Fscan(s.stdin, &n)
+		s.regs[regV0] = n
+
+	case syscallReadString:
+		// Like SPIM: read at most a1-1 characters, stop after a newline and always terminate with a null byte.
+		addr, n := uint32(a0), s.regs[regA1]
+		for ; n > 1; n-- {
+			c, err := s.stdin.ReadByte()
+			if err != nil {
+				break
+			}
+
+			s.storeByte(addr, c)
+			addr++
+			if c == '\n' {
+				break
+			}
+		}
+
+		if n > 0 {
+			s.storeByte(addr, 0)
+		}
+
+	case syscallSbrk:
+		s.regs[regV0] = int32(s.brk)
+		s.brk = (s.brk + uint32(a0) + wordSize - 1) &^ (wordSize - 1)
+
+	case syscallExit:
+		s.halted = true
+
+	case syscallPrintChar:
+		s.stdout.Write([]byte{byte(a0)})
+
+	case syscallReadChar:
+		c, err := s.stdin.ReadByte()
+		if err != nil {
+			s.regs[regV0] = -1
+		} else {
+			s.regs[regV0] = int32(c)
+		}
+
+	case syscallExit2:
+		s.halted = true
+		s.exitCode = int(a0)
+
+	default:
+		return unknownSyscallErr(s.regs[regV0])
+	}
+
+	return nil
+}
+
+func divRem(op string, a, b int32) (int32, int32) {
+	if op == "divu" || op == "remu" {
+		return int32(uint32(a) / uint32(b)), int32(uint32(a) % uint32(b))
+	}
+
+	return a / b, a % b
+}
@@ -0,0 +1,397 @@
+package sim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	riscvTextBase  = 0x00010000
+	riscvDataBase  = 0x10000000
+	riscvStackBase = 0x7ffffff0
+
+	// riscvExitAddress is the return address _start is entered with. Returning to it halts the simulator.
+	riscvExitAddress = 0
+
+	riscvRegRa = 1
+	riscvRegSp = 2
+	riscvRegA0 = 10
+	riscvRegA1 = 11
+	riscvRegA2 = 12
+	riscvRegA7 = 17
+)
+
+var riscvRegNames = map[string]int{
+	"zero": 0, "ra": 1, "sp": 2, "gp": 3, "tp": 4, "t0": 5, "t1": 6, "t2": 7,
+	"s0": 8, "fp": 8, "s1": 9, "a0": 10, "a1": 11, "a2": 12, "a3": 13, "a4": 14, "a5": 15, "a6": 16, "a7": 17,
+	"s2": 18, "s3": 19, "s4": 20, "s5": 21, "s6": 22, "s7": 23, "s8": 24, "s9": 25, "s10": 26, "s11": 27,
+	"t3": 28, "t4": 29, "t5": 30, "t6": 31,
+}
+
+var riscvSyntax = &asmSyntax{
+	textBase: riscvTextBase,
+	dataBase: riscvDataBase,
+	reg:      parseRiscvReg,
+	arity:    riscvArity,
+	altArity: riscvAltArity,
+}
+
+// AssembleRiscv assembles the GNU flavoured output of the RISC-V backend.
+func AssembleRiscv(src string) (*AsmProgram, error) {
+	return assemble(src, riscvSyntax)
+}
+
+func parseRiscvReg(f string) (int, bool) {
+	if reg, ok := riscvRegNames[f]; ok {
+		return reg, true
+	}
+
+	if !strings.HasPrefix(f, "x") {
+		return 0, false
+	}
+
+	if n, err := strconv.Atoi(f[1:]); err == nil && n >= 0 && n < 32 {
+		return n, true
+	}
+
+	return 0, false
+}
+
+// Riscv executes an assembled RV32IM program. It implements the instructions and pseudo instructions used by
+// riscv_gen.go and runtime/src/runtime_riscv.s, along with the Linux system calls the runtime relies on.
+type Riscv struct {
+	simMemory
+
+	prog *AsmProgram
+	regs [32]int32
+	pc   uint32
+	brk  uint32
+
+	stdin  *bufio.Reader
+	stdout io.Writer
+
+	// MaxSteps stops runaway programs. Zero means no limit.
+	MaxSteps int
+	steps    int
+	halted   bool
+	exitCode int
+}
+
+func NewRiscv(prog *AsmProgram, stdin io.Reader, stdout io.Writer) *Riscv {
+	sim := &Riscv{
+		simMemory: newSimMemory(),
+		prog:      prog,
+		stdin:     bufio.NewReader(stdin),
+		stdout:    stdout,
+	}
+
+	for i, b := range prog.data {
+		sim.storeByte(riscvDataBase+uint32(i), b)
+	}
+
+	// the initial program break starts on a fresh page after the data, like the kernel does
+	sim.brk = (riscvDataBase + uint32(len(prog.data)) + simPageSize - 1) &^ (simPageSize - 1)
+	sim.regs[riscvRegSp] = riscvStackBase
+	sim.regs[riscvRegRa] = riscvExitAddress
+	return sim
+}
+
+// Run starts executing at the _start label of the runtime and returns the exit code of the program.
+func (s *Riscv) Run() (int, error) {
+	entry, ok := s.prog.labels["_start"]
+	if !ok {
+		return 0, undefinedAsmLabelErr("_start", 0)
+	}
+
+	s.pc = entry
+	for !s.halted {
+		if err := s.Step(); err != nil {
+			return 0, err
+		}
+	}
+
+	return s.exitCode, nil
+}
+
+// Step executes one instruction.
+func (s *Riscv) Step() error {
+	if s.pc == riscvExitAddress {
+		s.halted = true
+		return nil
+	}
+
+	idx := (s.pc - riscvTextBase) / wordSize
+	if s.pc < riscvTextBase || s.pc%wordSize != 0 || int(idx) >= len(s.prog.text) {
+		return invalidPcErr(s.pc)
+	}
+
+	s.steps++
+	if s.MaxSteps > 0 && s.steps > s.MaxSteps {
+		return stepLimitErr(s.MaxSteps)
+	}
+
+	instr := s.prog.text[idx]
+	next := s.pc + wordSize
+	if err := s.exec(instr, &next); err != nil {
+		return fmt.Errorf("%v (line %d: %s)", err, instr.line, instr.op)
+	}
+
+	s.regs[0] = 0
+	s.pc = next
+	return nil
+}
+
+func (s *Riscv) reg(op *asmOperand) int32 {
+	return s.regs[op.reg]
+}
+
+// val is the value of a register or an immediate operand, so that e.g. add and addi share their implementation.
+func (s *Riscv) val(op *asmOperand) int32 {
+	if op.kind == asmRegOperand {
+		return s.regs[op.reg]
+	}
+
+	return op.imm
+}
+
+func (s *Riscv) addr(op *asmOperand) uint32 {
+	switch op.kind {
+	case asmMemOperand:
+		return uint32(s.regs[op.reg] + op.imm)
+	default:
+		return uint32(op.imm)
+	}
+}
+
+func (s *Riscv) exec(instr *asmInstr, next *uint32) error {
+	args := instr.args
+	set := func(v int32) {
+		s.regs[args[0].reg] = v
+	}
+
+	branch := func(cond bool) {
+		if cond {
+			*next = uint32(args[len(args)-1].imm)
+		}
+	}
+
+	link := func(reg int, target uint32) {
+		s.regs[reg] = int32(s.pc + wordSize)
+		*next = target
+	}
+
+	switch instr.op {
+	case "nop":
+	case "li", "la":
+		set(args[1].imm)
+	case "lui":
+		set(args[1].imm << 12)
+	case "mv":
+		set(s.reg(args[1]))
+	case "add", "addi":
+		set(s.reg(args[1]) + s.val(args[2]))
+	case "sub":
+		set(s.reg(args[1]) - s.val(args[2]))
+	case "mul":
+		set(s.reg(args[1]) * s.reg(args[2]))
+	case "div", "divu", "rem", "remu":
+		set(riscvDivRem(instr.op, s.reg(args[1]), s.reg(args[2])))
+	case "neg":
+		set(-s.reg(args[1]))
+	case "not":
+		set(^s.reg(args[1]))
+	case "and", "andi":
+		set(s.reg(args[1]) & s.val(args[2]))
+	case "or", "ori":
+		set(s.reg(args[1]) | s.val(args[2]))
+	case "xor", "xori":
+		set(s.reg(args[1]) ^ s.val(args[2]))
+	case "sll", "slli":
+		set(s.reg(args[1]) << (uint32(s.val(args[2])) & 31))
+	case "srl", "srli":
+		set(int32(uint32(s.reg(args[1])) >> (uint32(s.val(args[2])) & 31)))
+	case "sra", "srai":
+		set(s.reg(args[1]) >> (uint32(s.val(args[2])) & 31))
+	case "slt", "slti":
+		set(boolToInt32(s.reg(args[1]) < s.val(args[2])))
+	case "sltu", "sltiu":
+		set(boolToInt32(uint32(s.reg(args[1])) < uint32(s.val(args[2]))))
+	case "seqz":
+		set(boolToInt32(s.reg(args[1]) == 0))
+	case "snez":
+		set(boolToInt32(s.reg(args[1]) != 0))
+	case "lw":
+		v, err := s.loadWord(s.addr(args[1]))
+		if err != nil {
+			return err
+		}
+
+		set(v)
+	case "lb":
+		set(int32(int8(s.loadByte(s.addr(args[1])))))
+	case "lbu":
+		set(int32(s.loadByte(s.addr(args[1]))))
+	case "sw":
+		return s.storeWord(s.addr(args[1]), s.reg(args[0]))
+	case "sb":
+		s.storeByte(s.addr(args[1]), byte(s.reg(args[0])))
+	case "j":
+		branch(true)
+	case "jal", "call":
+		if len(args) == 2 {
+			link(args[0].reg, uint32(args[1].imm))
+		} else {
+			link(riscvRegRa, uint32(args[0].imm))
+		}
+	case "jr":
+		*next = uint32(s.reg(args[0]))
+	case "jalr":
+		if len(args) == 2 {
+			link(args[0].reg, uint32(s.reg(args[1])))
+		} else {
+			link(riscvRegRa, uint32(s.reg(args[0])))
+		}
+	case "ret":
+		*next = uint32(s.regs[riscvRegRa])
+	case "beq":
+		branch(s.reg(args[0]) == s.reg(args[1]))
+	case "bne":
+		branch(s.reg(args[0]) != s.reg(args[1]))
+	case "blt":
+		branch(s.reg(args[0]) < s.reg(args[1]))
+	case "ble":
+		branch(s.reg(args[0]) <= s.reg(args[1]))
+	case "bgt":
+		branch(s.reg(args[0]) > s.reg(args[1]))
+	case "bge":
+		branch(s.reg(args[0]) >= s.reg(args[1]))
+	case "bltu":
+		branch(uint32(s.reg(args[0])) < uint32(s.reg(args[1])))
+	case "bleu":
+		branch(uint32(s.reg(args[0])) <= uint32(s.reg(args[1])))
+	case "bgtu":
+		branch(uint32(s.reg(args[0])) > uint32(s.reg(args[1])))
+	case "bgeu":
+		branch(uint32(s.reg(args[0])) >= uint32(s.reg(args[1])))
+	case "beqz":
+		branch(s.reg(args[0]) == 0)
+	case "bnez":
+		branch(s.reg(args[0]) != 0)
+	case "bltz":
+		branch(s.reg(args[0]) < 0)
+	case "blez":
+		branch(s.reg(args[0]) <= 0)
+	case "bgtz":
+		branch(s.reg(args[0]) > 0)
+	case "bgez":
+		branch(s.reg(args[0]) >= 0)
+	case "ecall":
+		return s.ecall()
+	default:
+		return unknownInstrErr(instr.op)
+	}
+
+	return nil
+}
+
+// riscvArity is the number of operands of every supported instruction. riscvAltArity lists the instructions that
+// also have a second form, e.g. "jalr rd, rs" next to "jalr rs".
+var riscvArity = map[string]int{
+	"nop": 0, "ret": 0, "ecall": 0,
+	"li": 2, "la": 2, "lui": 2, "mv": 2, "neg": 2, "not": 2, "seqz": 2, "snez": 2,
+	"add": 3, "addi": 3, "sub": 3, "mul": 3, "div": 3, "divu": 3, "rem": 3, "remu": 3,
+	"and": 3, "andi": 3, "or": 3, "ori": 3, "xor": 3, "xori": 3, "sll": 3, "slli": 3, "srl": 3, "srli": 3,
+	"sra": 3, "srai": 3, "slt": 3, "slti": 3, "sltu": 3, "sltiu": 3,
+	"lw": 2, "lb": 2, "lbu": 2, "sw": 2, "sb": 2,
+	"j": 1, "jal": 1, "call": 1, "jr": 1, "jalr": 1,
+	"beq": 3, "bne": 3, "blt": 3, "ble": 3, "bgt": 3, "bge": 3, "bltu": 3, "bleu": 3, "bgtu": 3, "bgeu": 3,
+	"beqz": 2, "bnez": 2, "bltz": 2, "blez": 2, "bgtz": 2, "bgez": 2,
+}
+
+var riscvAltArity = map[string]int{
+	"jal": 2, "jalr": 2,
+}
+
+const (
+	riscvSyscallRead      = 63
+	riscvSyscallWrite     = 64
+	riscvSyscallExit      = 93
+	riscvSyscallExitGroup = 94
+	riscvSyscallBrk       = 214
+)
+
+// ecall implements the Linux system calls of the runtime. Only stdin and stdout/stderr are available, and brk never
+// fails since the memory of the simulator grows on demand.
+func (s *Riscv) ecall() error {
+	a0, a1, a2 := s.regs[riscvRegA0], s.regs[riscvRegA1], s.regs[riscvRegA2]
+	switch s.regs[riscvRegA7] {
+	case riscvSyscallRead:
+		if a0 != 0 {
+			s.regs[riscvRegA0] = -9 // EBADF
+			return nil
+		}
+
+		var n int32
+		for ; n < a2; n++ {
+			c, err := s.stdin.ReadByte()
+			if err != nil {
+				break
+			}
+
+			s.storeByte(uint32(a1+n), c)
+			if c == '\n' {
+				n++
+				break
+			}
+		}
+
+		s.regs[riscvRegA0] = n
+
+	case riscvSyscallWrite:
+		if a0 != 1 && a0 != 2 {
+			s.regs[riscvRegA0] = -9 // EBADF
+			return nil
+		}
+
+		s.stdout.Write(s.loadBytes(uint32(a1), int(a2)))
+		s.regs[riscvRegA0] = a2
+
+	case riscvSyscallExit, riscvSyscallExitGroup:
+		s.halted = true
+		s.exitCode = int(a0)
+
+	case riscvSyscallBrk:
+		if a0 != 0 {
+			s.brk = uint32(a0)
+		}
+
+		s.regs[riscvRegA0] = int32(s.brk)
+
+	default:
+		return unknownSyscallErr(s.regs[riscvRegA7])
+	}
+
+	return nil
+}
+
+// riscvDivRem follows the M extension, which does not trap: dividing by zero gives -1 (all ones when unsigned) and
+// leaves the dividend as the remainder.
+func riscvDivRem(op string, a, b int32) int32 {
+	if b == 0 {
+		if op == "div" || op == "divu" {
+			return -1
+		}
+
+		return a
+	}
+
+	q, r := divRem(op, a, b)
+	if op == "div" || op == "divu" {
+		return q
+	}
+
+	return r
+}
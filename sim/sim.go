@@ -0,0 +1,81 @@
+// Package sim assembles and runs the MIPS and RISC-V outputs, so that they can be run and tested without the
+// hardware.
+package sim
+
+const (
+	wordSize = 4
+
+	simPageBits = 12
+	simPageSize = 1 << simPageBits
+)
+
+// simMemory is the sparse little-endian memory shared by the simulators. Pages are allocated on first touch and start
+// zeroed, so the heap and the stack need no setup.
+type simMemory struct {
+	pages map[uint32][]byte
+}
+
+func newSimMemory() simMemory {
+	return simMemory{pages: make(map[uint32][]byte)}
+}
+
+func (m *simMemory) page(addr uint32) []byte {
+	p, ok := m.pages[addr>>simPageBits]
+	if !ok {
+		p = make([]byte, simPageSize)
+		m.pages[addr>>simPageBits] = p
+	}
+
+	return p
+}
+
+func (m *simMemory) loadByte(addr uint32) byte {
+	return m.page(addr)[addr&(simPageSize-1)]
+}
+
+func (m *simMemory) storeByte(addr uint32, b byte) {
+	m.page(addr)[addr&(simPageSize-1)] = b
+}
+
+func (m *simMemory) loadWord(addr uint32) (int32, error) {
+	if addr%wordSize != 0 {
+		return 0, unalignedAccessErr(addr)
+	}
+
+	p, off := m.page(addr), addr&(simPageSize-1)
+	return int32(uint32(p[off]) | uint32(p[off+1])<<8 | uint32(p[off+2])<<16 | uint32(p[off+3])<<24), nil
+}
+
+func (m *simMemory) storeWord(addr uint32, v int32) error {
+	if addr%wordSize != 0 {
+		return unalignedAccessErr(addr)
+	}
+
+	p, off := m.page(addr), addr&(simPageSize-1)
+	p[off], p[off+1], p[off+2], p[off+3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+	return nil
+}
+
+// loadBytes reads n bytes starting at addr, or up to the first null byte when n is negative.
+func (m *simMemory) loadBytes(addr uint32, n int) []byte {
+	var b []byte
+	for ; n != 0; n-- {
+		c := m.loadByte(addr)
+		if n < 0 && c == 0 {
+			break
+		}
+
+		b = append(b, c)
+		addr++
+	}
+
+	return b
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,1020 @@
+// Package syntax reads Tiger sources: the lexer, the parser and the AST with its printers, its JSON encoding and the
+// formatter, and the diagnostics reported about a source along with how they are rendered.
+package syntax
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type String interface {
+	String(strs *Strings, strBuilder *strings.Builder, level int)
+}
+
+const (
+	And Operator = iota + 1
+	Div
+	Eq
+	Ge
+	Gt
+	Le
+	Lt
+	Neq
+	Minus
+	Or
+	Plus
+	Mul
+)
+
+type Operator int
+
+func (op Operator) IsArith() bool {
+	return op == Plus || op == Minus || op == Mul || op == Div
+}
+
+func (op Operator) IsComp() bool {
+	return op == Lt || op == Le || op == Gt || op == Ge
+}
+
+func (op Operator) IsEq() bool {
+	return op == Eq || op == Neq
+}
+
+func (op Operator) IsLogic() bool {
+	return op == And || op == Or
+}
+
+func (op Operator) Repr() string {
+	switch op {
+	case And:
+		return "&"
+	case Div:
+		return "/"
+	case Eq:
+		return "="
+	case Ge:
+		return ">="
+	case Gt:
+		return ">"
+	case Le:
+		return "<="
+	case Lt:
+		return "<"
+	case Neq:
+		return "!="
+	case Minus:
+		return "-"
+	case Or:
+		return "|"
+	case Plus:
+		return "+"
+	case Mul:
+		return "*"
+	default:
+		// Must not occur
+		panic(fmt.Sprintf("operator %d", op))
+	}
+}
+
+func (op Operator) String(_ *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString(op.Repr() + "\n")
+}
+
+type RecordField struct {
+	Expr  Exp
+	Ident Symbol
+	Pos   Pos
+	span  Span
+}
+
+func (rec *RecordField) Span() Span {
+	return rec.span
+}
+
+func (rec *RecordField) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("RecordField\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Ident\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(rec.Ident) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Exp\n")
+	rec.Expr.String(strs, strBuilder, level+2)
+}
+
+type Declaration interface {
+	String
+	DeclPos() Pos
+	Span() Span
+}
+
+type Field struct {
+	Name   Symbol
+	Escape *bool
+	Typ    Symbol
+	Pos    Pos
+	span   Span
+}
+
+func (f *Field) Span() Span {
+	return f.span
+}
+
+func (f *Field) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("Field\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Name\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(f.Name) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Type\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(f.Typ) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Escape\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strconv.FormatBool(*f.Escape) + "\n")
+}
+
+type FuncDecl struct {
+	Name        Symbol
+	Params      []*Field
+	ResultTy    Symbol
+	ResultTyPos Pos
+	Body        Exp
+	Pos         Pos
+	span        Span
+}
+
+func (f *FuncDecl) DeclPos() Pos {
+	return f.Pos
+}
+
+func (f *FuncDecl) Span() Span {
+	return f.span
+}
+
+func (f *FuncDecl) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("FuncDecl\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Name\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(f.Name) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("ResultTy\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(f.ResultTy) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Params\n")
+	for _, field := range f.Params {
+		field.String(strs, strBuilder, level+2)
+	}
+
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Body\n")
+	f.Body.String(strs, strBuilder, level+2)
+}
+
+// PrimitiveDecl declares a function of the runtime, which is called without a static link.
+type PrimitiveDecl struct {
+	Name        Symbol
+	Params      []*Field
+	ResultTy    Symbol
+	ResultTyPos Pos
+	Pos         Pos
+	span        Span
+}
+
+func (f *PrimitiveDecl) DeclPos() Pos {
+	return f.Pos
+}
+
+func (f *PrimitiveDecl) Span() Span {
+	return f.span
+}
+
+func (f *PrimitiveDecl) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("PrimitiveDecl\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Name\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(f.Name) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("ResultTy\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(f.ResultTy) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Params\n")
+	for _, field := range f.Params {
+		field.String(strs, strBuilder, level+2)
+	}
+}
+
+type VarDecl struct {
+	Name   Symbol
+	Escape *bool
+	Typ    Symbol
+	Init   Exp
+	Pos    Pos
+	span   Span
+}
+
+func (f *VarDecl) DeclPos() Pos {
+	return f.Pos
+}
+
+func (f *VarDecl) Span() Span {
+	return f.span
+}
+
+func (f *VarDecl) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("VarDecl\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Name\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(f.Name) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Type\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(f.Typ) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Escape\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strconv.FormatBool(*f.Escape) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Init\n")
+	f.Init.String(strs, strBuilder, level+2)
+}
+
+type TypeDecl struct {
+	TyName Symbol
+	Ty     Ty
+	Pos    Pos
+	span   Span
+}
+
+func (f *TypeDecl) DeclPos() Pos {
+	return f.Pos
+}
+
+func (f *TypeDecl) Span() Span {
+	return f.span
+}
+
+func (f *TypeDecl) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("TypeDecl\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("T.Get\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(f.TyName) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Type\n")
+	f.Ty.String(strs, strBuilder, level+2)
+}
+
+// ImportDecl brings the types and functions declared by a module into the scope of a let. The path is relative to
+// the file that imports the module.
+type ImportDecl struct {
+	Path string
+	Pos  Pos
+	span Span
+}
+
+func (f *ImportDecl) DeclPos() Pos {
+	return f.Pos
+}
+
+func (f *ImportDecl) Span() Span {
+	return f.span
+}
+
+func (f *ImportDecl) String(_ *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("ImportDecl\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Path\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(f.Path + "\n")
+}
+
+// ErrorDecl stands for a declaration that did not parse. The parser has reported the syntax error, the semantic
+// analysis skips the declaration.
+type ErrorDecl struct {
+	pos  Pos
+	span Span
+}
+
+func (f *ErrorDecl) DeclPos() Pos {
+	return f.pos
+}
+
+func (f *ErrorDecl) Span() Span {
+	return f.span
+}
+
+func (f *ErrorDecl) String(_ *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("ErrorDecl\n")
+}
+
+type Ty interface {
+	String
+	TyPos() Pos
+	Span() Span
+}
+
+type NameTy struct {
+	Ty   Symbol
+	pos  Pos
+	span Span
+}
+
+func (t *NameTy) TyPos() Pos {
+	return t.pos
+}
+
+func (t *NameTy) Span() Span {
+	return t.span
+}
+
+func (t *NameTy) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("NameTy\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Ty\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(t.Ty) + "\n")
+}
+
+type RecordTy struct {
+	Fields []*Field
+	pos    Pos
+	span   Span
+}
+
+func (t *RecordTy) HasDuplicateField() bool {
+	for i := range t.Fields {
+		for j := range t.Fields {
+			if i != j && t.Fields[i].Name == t.Fields[j].Name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (t *RecordTy) TyPos() Pos {
+	return t.pos
+}
+
+func (t *RecordTy) Span() Span {
+	return t.span
+}
+
+func (t *RecordTy) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("RecordType\n")
+	for _, field := range t.Fields {
+		field.String(strs, strBuilder, level+1)
+	}
+}
+
+type ArrayTy struct {
+	Ty   Symbol
+	pos  Pos
+	span Span
+}
+
+func (t *ArrayTy) TyPos() Pos {
+	return t.pos
+}
+
+func (t *ArrayTy) Span() Span {
+	return t.span
+}
+
+func (t *ArrayTy) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("ArrayType\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString(strs.Get(t.Ty) + "\n")
+}
+
+// ClassTy is the body of a class, declared either as "class C extends B { ... }" or as "type C = class extends B
+// { ... }". A class that extends nothing extends Object.
+type ClassTy struct {
+	Super    Symbol
+	SuperPos Pos
+	Attrs    []*VarDecl
+	Methods  []*FuncDecl
+	pos      Pos
+	span     Span
+}
+
+func (t *ClassTy) TyPos() Pos {
+	return t.pos
+}
+
+func (t *ClassTy) Span() Span {
+	return t.span
+}
+
+func (t *ClassTy) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("ClassType\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Super\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(t.Super) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Attributes\n")
+	for _, attr := range t.Attrs {
+		attr.String(strs, strBuilder, level+2)
+	}
+
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Methods\n")
+	for _, method := range t.Methods {
+		method.String(strs, strBuilder, level+2)
+	}
+}
+
+// Exp is an expression. ExpPos is the position that the diagnostics about the expression point at, like the name of
+// a function for a call, while Span is all of its source. The declarations, types and variables are the same.
+type Exp interface {
+	String
+	ExpPos() Pos
+	Span() Span
+}
+
+type ArrExp struct {
+	Init Exp
+	Size Exp
+	Typ  Symbol
+	pos  Pos
+	span Span
+}
+
+func (e *ArrExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *ArrExp) Span() Span {
+	return e.span
+}
+
+func (e *ArrExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("ArrExp\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Type\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(e.Typ) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Size\n")
+	e.Size.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Init\n")
+	e.Init.String(strs, strBuilder, level+2)
+}
+
+type AssignExp struct {
+	Exp      Exp
+	Variable Var
+	span     Span
+}
+
+func (e *AssignExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("AssignExp\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Variable\n")
+	e.Variable.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Exp\n")
+	e.Exp.String(strs, strBuilder, level+2)
+}
+
+func (e *AssignExp) ExpPos() Pos {
+	return e.Variable.VarPos()
+}
+
+func (e *AssignExp) Span() Span {
+	return e.span
+}
+
+type BreakExp struct {
+	pos  Pos
+	span Span
+}
+
+func (e *BreakExp) String(_ *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("Break\n")
+}
+
+func (e *BreakExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *BreakExp) Span() Span {
+	return e.span
+}
+
+type CallExp struct {
+	Function Symbol
+	Args     []Exp
+	Pos      Pos
+	span     Span
+}
+
+func (e *CallExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("CallExp\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Function\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(e.Function) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Args\n")
+	for _, exp := range e.Args {
+		exp.String(strs, strBuilder, level+2)
+	}
+}
+
+func (e *CallExp) ExpPos() Pos {
+	return e.Pos
+}
+
+func (e *CallExp) Span() Span {
+	return e.span
+}
+
+type MethodCallExp struct {
+	Variable Var
+	Method   Symbol
+	Args     []Exp
+	Pos      Pos
+	span     Span
+}
+
+func (e *MethodCallExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("MethodCallExp\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Var\n")
+	e.Variable.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Method\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(e.Method) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Args\n")
+	for _, exp := range e.Args {
+		exp.String(strs, strBuilder, level+2)
+	}
+}
+
+func (e *MethodCallExp) ExpPos() Pos {
+	return e.Pos
+}
+
+func (e *MethodCallExp) Span() Span {
+	return e.span
+}
+
+type IfExp struct {
+	Predicate Exp
+	Then      Exp
+	Els       Exp
+	pos       Pos
+	span      Span
+}
+
+func (e *IfExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("IfExp\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Predicate\n")
+	e.Predicate.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Then\n")
+	e.Then.String(strs, strBuilder, level+2)
+	if e.Els != nil {
+		indent(strBuilder, level+1)
+		strBuilder.WriteString("Else\n")
+		e.Els.String(strs, strBuilder, level+2)
+	}
+}
+
+func (e *IfExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *IfExp) Span() Span {
+	return e.span
+}
+
+type IntExp struct {
+	Val  int32
+	pos  Pos
+	span Span
+}
+
+func (e *IntExp) String(_ *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("Int\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString(strconv.FormatInt(int64(e.Val), 10) + "\n")
+}
+
+func (e *IntExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *IntExp) Span() Span {
+	return e.span
+}
+
+type LetExp struct {
+	Body  Exp
+	Decls []Declaration
+	pos   Pos
+	span  Span
+}
+
+func (e *LetExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("Let\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Decls\n")
+	for _, decl := range e.Decls {
+		decl.String(strs, strBuilder, level+2)
+	}
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Body\n")
+	e.Body.String(strs, strBuilder, level+2)
+}
+
+func (e *LetExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *LetExp) Span() Span {
+	return e.span
+}
+
+type UnitExp struct {
+	pos  Pos
+	span Span
+}
+
+func (e *UnitExp) String(_ *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("Unit\n")
+}
+
+func (e *UnitExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *UnitExp) Span() Span {
+	return e.span
+}
+
+// ErrorExp stands for an expression that did not parse. The parser has reported the syntax error, the semantic
+// analysis does not report the errors of the expressions that use it.
+type ErrorExp struct {
+	pos  Pos
+	span Span
+}
+
+func (e *ErrorExp) String(_ *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("ErrorExp\n")
+}
+
+func (e *ErrorExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *ErrorExp) Span() Span {
+	return e.span
+}
+
+type NewExp struct {
+	Ty   Symbol
+	Pos  Pos
+	span Span
+}
+
+func (e *NewExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("NewExp\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Type\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(e.Ty) + "\n")
+}
+
+func (e *NewExp) ExpPos() Pos {
+	return e.Pos
+}
+
+func (e *NewExp) Span() Span {
+	return e.span
+}
+
+type NilExp struct {
+	pos  Pos
+	span Span
+}
+
+func (e *NilExp) String(_ *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("Nil\n")
+}
+
+func (e *NilExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *NilExp) Span() Span {
+	return e.span
+}
+
+type OperExp struct {
+	Left  Exp
+	Op    Operator
+	Right Exp
+	span  Span
+}
+
+func (e *OperExp) ExpPos() Pos {
+	return e.Left.ExpPos()
+}
+
+func (e *OperExp) Span() Span {
+	return e.span
+}
+
+func (e *OperExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("OperExp\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Op\n")
+	e.Op.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Left\n")
+	e.Left.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Right\n")
+	e.Right.String(strs, strBuilder, level+2)
+}
+
+type RecordExp struct {
+	Fields []*RecordField
+	Ty     Symbol
+	Pos    Pos
+	span   Span
+}
+
+func (e *RecordExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("RecordExp\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Type\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(e.Ty) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Fields\n")
+	for _, field := range e.Fields {
+		field.String(strs, strBuilder, level+2)
+	}
+}
+
+func (e *RecordExp) ExpPos() Pos {
+	return e.Pos
+}
+
+func (e *RecordExp) Span() Span {
+	return e.span
+}
+
+type SequenceExp struct {
+	Exps []Exp
+	pos  Pos
+	span Span
+}
+
+func (e *SequenceExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("SequenceExp\n")
+	for _, field := range e.Exps {
+		field.String(strs, strBuilder, level+1)
+	}
+}
+
+func (e *SequenceExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *SequenceExp) Span() Span {
+	return e.span
+}
+
+type StrExp struct {
+	Str  string
+	pos  Pos
+	span Span
+}
+
+func (e *StrExp) String(_ *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("String\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString(fmt.Sprintf("\"%s\"\n", e.Str))
+}
+
+func (e *StrExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *StrExp) Span() Span {
+	return e.span
+}
+
+type VarExp struct {
+	Var  Var
+	span Span
+}
+
+func (e *VarExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("VarExp\n")
+	e.Var.String(strs, strBuilder, level+1)
+}
+
+func (e *VarExp) ExpPos() Pos {
+	return e.Var.VarPos()
+}
+
+func (e *VarExp) Span() Span {
+	return e.span
+}
+
+type ForExp struct {
+	From Exp
+	To   Exp
+	Body Exp
+	Pos  Pos
+	Sym  Symbol
+	span Span
+}
+
+func (e *ForExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("ForExp\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("ItVar\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(strs.Get(e.Sym) + "\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("From\n")
+	e.From.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("To\n")
+	e.To.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Body\n")
+	e.Body.String(strs, strBuilder, level+2)
+}
+
+func (e *ForExp) ExpPos() Pos {
+	return e.Pos
+}
+
+func (e *ForExp) Span() Span {
+	return e.span
+}
+
+type WhileExp struct {
+	Pred Exp
+	Body Exp
+	pos  Pos
+	span Span
+}
+
+func (e *WhileExp) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("WhileExp\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Predicate\n")
+	e.Pred.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Body\n")
+	e.Body.String(strs, strBuilder, level+2)
+}
+
+func (e *WhileExp) ExpPos() Pos {
+	return e.pos
+}
+
+func (e *WhileExp) Span() Span {
+	return e.span
+}
+
+func indent(builder *strings.Builder, level int) {
+	for i := 0; i < level; i++ {
+		builder.WriteString("  ")
+	}
+}
+
+type Var interface {
+	String
+	VarPos() Pos
+	Span() Span
+}
+
+type SimpleVar struct {
+	Symbol Symbol
+	Pos    Pos
+	span   Span
+}
+
+func (v *SimpleVar) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("SimpleVar\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString(fmt.Sprintf("%s\n", strs.Get(v.Symbol)))
+}
+
+func (v *SimpleVar) VarPos() Pos {
+	return v.Pos
+}
+
+func (v *SimpleVar) Span() Span {
+	return v.span
+}
+
+type FieldVar struct {
+	Variable Var
+	Field    Symbol
+	Pos      Pos
+	span     Span
+}
+
+func (v *FieldVar) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("FieldVar\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Var\n")
+	v.Variable.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Field\n")
+	indent(strBuilder, level+2)
+	strBuilder.WriteString(fmt.Sprintf("%s\n", strs.Get(v.Field)))
+}
+
+func (v *FieldVar) VarPos() Pos {
+	return v.Pos
+}
+
+func (v *FieldVar) Span() Span {
+	return v.span
+}
+
+type SubscriptionVar struct {
+	Variable Var
+	Exp      Exp
+	pos      Pos
+	span     Span
+}
+
+func (v *SubscriptionVar) String(strs *Strings, strBuilder *strings.Builder, level int) {
+	indent(strBuilder, level)
+	strBuilder.WriteString("SubscriptionVar\n")
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Var\n")
+	v.Variable.String(strs, strBuilder, level+2)
+	indent(strBuilder, level+1)
+	strBuilder.WriteString("Subscript\n")
+	v.Exp.String(strs, strBuilder, level+2)
+}
+
+func (v *SubscriptionVar) VarPos() Pos {
+	return v.pos
+}
+
+func (v *SubscriptionVar) Span() Span {
+	return v.span
+}
@@ -0,0 +1,726 @@
+package syntax
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// The AST is written as JSON for the scripts that analyze programs and for the tests of the parser. Every node is an
+// object with its kind, the name of its type in ast.go, its position and its span, then its attributes and its
+// children under fixed keys. Symbols are written as their names, and the file of the positions is written once, at
+// the top. Reading the JSON back gives the same AST, with the symbols interned in the Strings it is read with.
+
+// astJsonVersion is the version of the encoding, it changes when a node or a key changes.
+const astJsonVersion = 1
+
+type jsonAst struct {
+	Version int         `json:"version"`
+	File    string      `json:"file"`
+	Program *jsonNode   `json:"program,omitempty"`
+	Decls   []*jsonNode `json:"decls,omitempty"`
+}
+
+type jsonNode struct {
+	Kind string        `json:"kind"`
+	Pos  *jsonPosition `json:"pos,omitempty"`
+	Span *jsonSpan     `json:"span,omitempty"`
+
+	Name          string        `json:"name,omitempty"`
+	Type          string        `json:"type,omitempty"`
+	ResultType    string        `json:"resultType,omitempty"`
+	ResultTypePos *jsonPosition `json:"resultTypePos,omitempty"`
+	Super         string        `json:"super,omitempty"`
+	SuperPos      *jsonPosition `json:"superPos,omitempty"`
+	Escape        *bool         `json:"escape,omitempty"`
+	Op            string        `json:"op,omitempty"`
+	Int           *int32        `json:"int,omitempty"`
+	Str           *string       `json:"str,omitempty"`
+	Path          string        `json:"path,omitempty"`
+
+	Var       *jsonNode   `json:"var,omitempty"`
+	Exp       *jsonNode   `json:"exp,omitempty"`
+	Ty        *jsonNode   `json:"ty,omitempty"`
+	Init      *jsonNode   `json:"init,omitempty"`
+	Size      *jsonNode   `json:"size,omitempty"`
+	Left      *jsonNode   `json:"left,omitempty"`
+	Right     *jsonNode   `json:"right,omitempty"`
+	Predicate *jsonNode   `json:"predicate,omitempty"`
+	Then      *jsonNode   `json:"then,omitempty"`
+	Else      *jsonNode   `json:"else,omitempty"`
+	From      *jsonNode   `json:"from,omitempty"`
+	To        *jsonNode   `json:"to,omitempty"`
+	Body      *jsonNode   `json:"body,omitempty"`
+	Args      []*jsonNode `json:"args,omitempty"`
+	Exps      []*jsonNode `json:"exps,omitempty"`
+	Decls     []*jsonNode `json:"decls,omitempty"`
+	Fields    []*jsonNode `json:"fields,omitempty"`
+	Params    []*jsonNode `json:"params,omitempty"`
+	Attrs     []*jsonNode `json:"attrs,omitempty"`
+	Methods   []*jsonNode `json:"methods,omitempty"`
+}
+
+// WriteJsonProgram writes the AST of a program.
+func WriteJsonProgram(w io.Writer, strs *Strings, exp Exp) error {
+	e := astEncoder{strs: strs}
+	return writeJsonAst(w, jsonAst{Version: astJsonVersion, File: exp.Span().Start.FileName, Program: e.exp(exp)})
+}
+
+// WriteJsonModule writes the declarations of a module.
+func WriteJsonModule(w io.Writer, strs *Strings, file string, decls []Declaration) error {
+	e := astEncoder{strs: strs}
+	return writeJsonAst(w, jsonAst{Version: astJsonVersion, File: file, Decls: e.decls(decls)})
+}
+
+func writeJsonAst(w io.Writer, ast jsonAst) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(ast)
+}
+
+type astEncoder struct {
+	strs *Strings
+}
+
+func (e *astEncoder) name(sym Symbol) string {
+	if sym == 0 {
+		return ""
+	}
+
+	return e.strs.Get(sym)
+}
+
+func (e *astEncoder) pos(pos Pos) *jsonPosition {
+	return &jsonPosition{Line: pos.Line, Column: pos.Col, Offset: pos.Offset}
+}
+
+func (e *astEncoder) node(kind string, pos *Pos, span Span) *jsonNode {
+	_, s := newJsonSpan(&span)
+	n := &jsonNode{Kind: kind, Span: s}
+	if pos != nil {
+		n.Pos = e.pos(*pos)
+	}
+
+	return n
+}
+
+func (e *astEncoder) exps(exps []Exp) []*jsonNode {
+	nodes := make([]*jsonNode, 0, len(exps))
+	for _, exp := range exps {
+		nodes = append(nodes, e.exp(exp))
+	}
+
+	return nodes
+}
+
+func (e *astEncoder) exp(exp Exp) *jsonNode {
+	var n *jsonNode
+	switch v := exp.(type) {
+	case nil:
+		return nil
+	case *ArrExp:
+		n = e.node("ArrExp", &v.pos, v.span)
+		n.Type, n.Size, n.Init = e.name(v.Typ), e.exp(v.Size), e.exp(v.Init)
+	case *AssignExp:
+		n = e.node("AssignExp", nil, v.span)
+		n.Var, n.Exp = e.variable(v.Variable), e.exp(v.Exp)
+	case *BreakExp:
+		n = e.node("BreakExp", &v.pos, v.span)
+	case *CallExp:
+		n = e.node("CallExp", &v.Pos, v.span)
+		n.Name, n.Args = e.name(v.Function), e.exps(v.Args)
+	case *MethodCallExp:
+		n = e.node("MethodCallExp", &v.Pos, v.span)
+		n.Var, n.Name, n.Args = e.variable(v.Variable), e.name(v.Method), e.exps(v.Args)
+	case *IfExp:
+		n = e.node("IfExp", &v.pos, v.span)
+		n.Predicate, n.Then, n.Else = e.exp(v.Predicate), e.exp(v.Then), e.exp(v.Els)
+	case *IntExp:
+		n = e.node("IntExp", &v.pos, v.span)
+		n.Int = &v.Val
+	case *LetExp:
+		n = e.node("LetExp", &v.pos, v.span)
+		n.Decls, n.Body = e.decls(v.Decls), e.exp(v.Body)
+	case *UnitExp:
+		n = e.node("UnitExp", &v.pos, v.span)
+	case *ErrorExp:
+		n = e.node("ErrorExp", &v.pos, v.span)
+	case *NewExp:
+		n = e.node("NewExp", &v.Pos, v.span)
+		n.Type = e.name(v.Ty)
+	case *NilExp:
+		n = e.node("NilExp", &v.pos, v.span)
+	case *OperExp:
+		n = e.node("OperExp", nil, v.span)
+		n.Left, n.Op, n.Right = e.exp(v.Left), v.Op.Repr(), e.exp(v.Right)
+	case *RecordExp:
+		n = e.node("RecordExp", &v.Pos, v.span)
+		n.Type = e.name(v.Ty)
+		for _, field := range v.Fields {
+			f := e.node("RecordField", &field.Pos, field.span)
+			f.Name, f.Exp = e.name(field.Ident), e.exp(field.Expr)
+			n.Fields = append(n.Fields, f)
+		}
+	case *SequenceExp:
+		n = e.node("SequenceExp", &v.pos, v.span)
+		n.Exps = e.exps(v.Exps)
+	case *StrExp:
+		n = e.node("StrExp", &v.pos, v.span)
+		n.Str = &v.Str
+	case *VarExp:
+		n = e.node("VarExp", nil, v.span)
+		n.Var = e.variable(v.Var)
+	case *ForExp:
+		n = e.node("ForExp", &v.Pos, v.span)
+		n.Name, n.From, n.To, n.Body = e.name(v.Sym), e.exp(v.From), e.exp(v.To), e.exp(v.Body)
+	case *WhileExp:
+		n = e.node("WhileExp", &v.pos, v.span)
+		n.Predicate, n.Body = e.exp(v.Pred), e.exp(v.Body)
+	}
+
+	return n
+}
+
+func (e *astEncoder) variable(v Var) *jsonNode {
+	var n *jsonNode
+	switch v := v.(type) {
+	case *SimpleVar:
+		n = e.node("SimpleVar", &v.Pos, v.span)
+		n.Name = e.name(v.Symbol)
+	case *FieldVar:
+		n = e.node("FieldVar", &v.Pos, v.span)
+		n.Var, n.Name = e.variable(v.Variable), e.name(v.Field)
+	case *SubscriptionVar:
+		n = e.node("SubscriptionVar", &v.pos, v.span)
+		n.Var, n.Exp = e.variable(v.Variable), e.exp(v.Exp)
+	}
+
+	return n
+}
+
+func (e *astEncoder) decls(decls []Declaration) []*jsonNode {
+	nodes := make([]*jsonNode, 0, len(decls))
+	for _, decl := range decls {
+		nodes = append(nodes, e.decl(decl))
+	}
+
+	return nodes
+}
+
+func (e *astEncoder) fields(fields []*Field) []*jsonNode {
+	nodes := make([]*jsonNode, 0, len(fields))
+	for _, field := range fields {
+		n := e.node("Field", &field.Pos, field.span)
+		n.Name, n.Type, n.Escape = e.name(field.Name), e.name(field.Typ), field.Escape
+		nodes = append(nodes, n)
+	}
+
+	return nodes
+}
+
+func (e *astEncoder) decl(decl Declaration) *jsonNode {
+	var n *jsonNode
+	switch v := decl.(type) {
+	case *FuncDecl:
+		n = e.node("FuncDecl", &v.Pos, v.span)
+		n.Name, n.Params, n.Body = e.name(v.Name), e.fields(v.Params), e.exp(v.Body)
+		n.ResultType, n.ResultTypePos = e.name(v.ResultTy), e.pos(v.ResultTyPos)
+	case *PrimitiveDecl:
+		n = e.node("PrimitiveDecl", &v.Pos, v.span)
+		n.Name, n.Params = e.name(v.Name), e.fields(v.Params)
+		n.ResultType, n.ResultTypePos = e.name(v.ResultTy), e.pos(v.ResultTyPos)
+	case *VarDecl:
+		n = e.node("VarDecl", &v.Pos, v.span)
+		n.Name, n.Type, n.Escape, n.Init = e.name(v.Name), e.name(v.Typ), v.Escape, e.exp(v.Init)
+	case *TypeDecl:
+		n = e.node("TypeDecl", &v.Pos, v.span)
+		n.Name, n.Ty = e.name(v.TyName), e.ty(v.Ty)
+	case *ImportDecl:
+		n = e.node("ImportDecl", &v.Pos, v.span)
+		n.Path = v.Path
+	case *ErrorDecl:
+		n = e.node("ErrorDecl", &v.pos, v.span)
+	}
+
+	return n
+}
+
+func (e *astEncoder) ty(ty Ty) *jsonNode {
+	var n *jsonNode
+	switch v := ty.(type) {
+	case *NameTy:
+		n = e.node("NameTy", &v.pos, v.span)
+		n.Type = e.name(v.Ty)
+	case *RecordTy:
+		n = e.node("RecordTy", &v.pos, v.span)
+		n.Fields = e.fields(v.Fields)
+	case *ArrayTy:
+		n = e.node("ArrayTy", &v.pos, v.span)
+		n.Type = e.name(v.Ty)
+	case *ClassTy:
+		n = e.node("ClassTy", &v.pos, v.span)
+		n.Super, n.SuperPos = e.name(v.Super), e.pos(v.SuperPos)
+		for _, attr := range v.Attrs {
+			n.Attrs = append(n.Attrs, e.decl(attr))
+		}
+
+		for _, method := range v.Methods {
+			n.Methods = append(n.Methods, e.decl(method))
+		}
+	}
+
+	return n
+}
+
+// ReadJsonProgram reads the AST of a program written by WriteJsonProgram.
+func ReadJsonProgram(r io.Reader, strs *Strings) (Exp, error) {
+	ast, err := readJsonAst(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if ast.Program == nil {
+		return nil, missingAstNodeErr("program", "the AST")
+	}
+
+	d := astDecoder{strs: strs, file: ast.File}
+	return d.exp(ast.Program, "program")
+}
+
+// ReadJsonModule reads the declarations of a module written by WriteJsonModule.
+func ReadJsonModule(r io.Reader, strs *Strings) ([]Declaration, error) {
+	ast, err := readJsonAst(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := astDecoder{strs: strs, file: ast.File}
+	return d.decls(ast.Decls)
+}
+
+func readJsonAst(r io.Reader) (*jsonAst, error) {
+	ast := &jsonAst{}
+	if err := json.NewDecoder(r).Decode(ast); err != nil {
+		return nil, err
+	}
+
+	if ast.Version != astJsonVersion {
+		return nil, unsupportedAstVersionErr(ast.Version)
+	}
+
+	return ast, nil
+}
+
+type astDecoder struct {
+	strs *Strings
+	file string
+}
+
+func (d *astDecoder) sym(name string) Symbol {
+	if name == "" {
+		return 0
+	}
+
+	return d.strs.Symbol(name)
+}
+
+func (d *astDecoder) pos(p *jsonPosition) Pos {
+	if p == nil {
+		return Pos{}
+	}
+
+	return Pos{FileName: d.file, Line: p.Line, Col: p.Column, Offset: p.Offset}
+}
+
+func (d *astDecoder) span(s *jsonSpan) Span {
+	if s == nil {
+		return Span{}
+	}
+
+	return Span{Start: d.pos(&s.Start), End: d.pos(&s.End)}
+}
+
+func (d *astDecoder) op(repr string) (Operator, error) {
+	for op := And; op <= Mul; op++ {
+		if op.Repr() == repr {
+			return op, nil
+		}
+	}
+
+	return 0, unknownAstOperatorErr(repr)
+}
+
+func (d *astDecoder) exps(nodes []*jsonNode, key string) ([]Exp, error) {
+	exps := make([]Exp, 0, len(nodes))
+	for _, n := range nodes {
+		exp, err := d.exp(n, key)
+		if err != nil {
+			return nil, err
+		}
+
+		exps = append(exps, exp)
+	}
+
+	return exps, nil
+}
+
+// children decodes the expressions that a node must have, key is where they are for the errors.
+func (d *astDecoder) children(key string, nodes ...**jsonNode) ([]Exp, error) {
+	exps := make([]Exp, 0, len(nodes))
+	for _, n := range nodes {
+		exp, err := d.exp(*n, key)
+		if err != nil {
+			return nil, err
+		}
+
+		exps = append(exps, exp)
+	}
+
+	return exps, nil
+}
+
+// exp decodes an expression, key is where it is in its parent.
+func (d *astDecoder) exp(n *jsonNode, key string) (Exp, error) {
+	if n == nil {
+		return nil, missingAstNodeErr(key, "an expression")
+	}
+
+	pos, span := d.pos(n.Pos), d.span(n.Span)
+	switch n.Kind {
+	case "ArrExp":
+		exps, err := d.children(n.Kind, &n.Size, &n.Init)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ArrExp{Typ: d.sym(n.Type), Size: exps[0], Init: exps[1], pos: pos, span: span}, nil
+	case "AssignExp":
+		v, err := d.variable(n.Var, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		exp, err := d.exp(n.Exp, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &AssignExp{Variable: v, Exp: exp, span: span}, nil
+	case "BreakExp":
+		return &BreakExp{pos: pos, span: span}, nil
+	case "CallExp":
+		args, err := d.exps(n.Args, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &CallExp{Function: d.sym(n.Name), Args: args, Pos: pos, span: span}, nil
+	case "MethodCallExp":
+		v, err := d.variable(n.Var, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		args, err := d.exps(n.Args, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &MethodCallExp{Variable: v, Method: d.sym(n.Name), Args: args, Pos: pos, span: span}, nil
+	case "IfExp":
+		exps, err := d.children(n.Kind, &n.Predicate, &n.Then)
+		if err != nil {
+			return nil, err
+		}
+
+		var els Exp
+		if n.Else != nil {
+			if els, err = d.exp(n.Else, n.Kind); err != nil {
+				return nil, err
+			}
+		}
+
+		return &IfExp{Predicate: exps[0], Then: exps[1], Els: els, pos: pos, span: span}, nil
+	case "IntExp":
+		if n.Int == nil {
+			return nil, missingAstNodeErr(n.Kind, "an int")
+		}
+
+		return &IntExp{Val: *n.Int, pos: pos, span: span}, nil
+	case "LetExp":
+		decls, err := d.decls(n.Decls)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := d.exp(n.Body, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &LetExp{Decls: decls, Body: body, pos: pos, span: span}, nil
+	case "UnitExp":
+		return &UnitExp{pos: pos, span: span}, nil
+	case "ErrorExp":
+		return &ErrorExp{pos: pos, span: span}, nil
+	case "NewExp":
+		return &NewExp{Ty: d.sym(n.Type), Pos: pos, span: span}, nil
+	case "NilExp":
+		return &NilExp{pos: pos, span: span}, nil
+	case "OperExp":
+		op, err := d.op(n.Op)
+		if err != nil {
+			return nil, err
+		}
+
+		exps, err := d.children(n.Kind, &n.Left, &n.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		return &OperExp{Left: exps[0], Op: op, Right: exps[1], span: span}, nil
+	case "RecordExp":
+		fields := make([]*RecordField, 0, len(n.Fields))
+		for _, f := range n.Fields {
+			if f == nil || f.Kind != "RecordField" {
+				return nil, unknownAstKindErr(kindOf(f), "record field")
+			}
+
+			exp, err := d.exp(f.Exp, f.Kind)
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(fields, &RecordField{Ident: d.sym(f.Name), Expr: exp, Pos: d.pos(f.Pos), span: d.span(f.Span)})
+		}
+
+		return &RecordExp{Ty: d.sym(n.Type), Fields: fields, Pos: pos, span: span}, nil
+	case "SequenceExp":
+		exps, err := d.exps(n.Exps, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SequenceExp{Exps: exps, pos: pos, span: span}, nil
+	case "StrExp":
+		if n.Str == nil {
+			return nil, missingAstNodeErr(n.Kind, "a string")
+		}
+
+		return &StrExp{Str: *n.Str, pos: pos, span: span}, nil
+	case "VarExp":
+		v, err := d.variable(n.Var, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &VarExp{Var: v, span: span}, nil
+	case "ForExp":
+		exps, err := d.children(n.Kind, &n.From, &n.To, &n.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ForExp{Sym: d.sym(n.Name), From: exps[0], To: exps[1], Body: exps[2], Pos: pos, span: span}, nil
+	case "WhileExp":
+		exps, err := d.children(n.Kind, &n.Predicate, &n.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		return &WhileExp{Pred: exps[0], Body: exps[1], pos: pos, span: span}, nil
+	}
+
+	return nil, unknownAstKindErr(n.Kind, "expression")
+}
+
+func (d *astDecoder) variable(n *jsonNode, key string) (Var, error) {
+	if n == nil {
+		return nil, missingAstNodeErr(key, "a variable")
+	}
+
+	pos, span := d.pos(n.Pos), d.span(n.Span)
+	switch n.Kind {
+	case "SimpleVar":
+		return &SimpleVar{Symbol: d.sym(n.Name), Pos: pos, span: span}, nil
+	case "FieldVar":
+		v, err := d.variable(n.Var, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &FieldVar{Variable: v, Field: d.sym(n.Name), Pos: pos, span: span}, nil
+	case "SubscriptionVar":
+		v, err := d.variable(n.Var, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		exp, err := d.exp(n.Exp, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SubscriptionVar{Variable: v, Exp: exp, pos: pos, span: span}, nil
+	}
+
+	return nil, unknownAstKindErr(n.Kind, "variable")
+}
+
+func (d *astDecoder) decls(nodes []*jsonNode) ([]Declaration, error) {
+	decls := make([]Declaration, 0, len(nodes))
+	for _, n := range nodes {
+		decl, err := d.decl(n)
+		if err != nil {
+			return nil, err
+		}
+
+		decls = append(decls, decl)
+	}
+
+	return decls, nil
+}
+
+func (d *astDecoder) fields(nodes []*jsonNode) ([]*Field, error) {
+	fields := make([]*Field, 0, len(nodes))
+	for _, n := range nodes {
+		if n == nil || n.Kind != "Field" {
+			return nil, unknownAstKindErr(kindOf(n), "field")
+		}
+
+		fields = append(fields, &Field{
+			Name:   d.sym(n.Name),
+			Typ:    d.sym(n.Type),
+			Escape: n.Escape,
+			Pos:    d.pos(n.Pos),
+			span:   d.span(n.Span),
+		})
+	}
+
+	return fields, nil
+}
+
+func (d *astDecoder) decl(n *jsonNode) (Declaration, error) {
+	if n == nil {
+		return nil, missingAstNodeErr("decls", "a declaration")
+	}
+
+	pos, span := d.pos(n.Pos), d.span(n.Span)
+	switch n.Kind {
+	case "FuncDecl":
+		params, err := d.fields(n.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := d.exp(n.Body, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &FuncDecl{
+			Name:        d.sym(n.Name),
+			Params:      params,
+			ResultTy:    d.sym(n.ResultType),
+			ResultTyPos: d.pos(n.ResultTypePos),
+			Body:        body,
+			Pos:         pos,
+			span:        span,
+		}, nil
+	case "PrimitiveDecl":
+		params, err := d.fields(n.Params)
+		if err != nil {
+			return nil, err
+		}
+
+		return &PrimitiveDecl{
+			Name:        d.sym(n.Name),
+			Params:      params,
+			ResultTy:    d.sym(n.ResultType),
+			ResultTyPos: d.pos(n.ResultTypePos),
+			Pos:         pos,
+			span:        span,
+		}, nil
+	case "VarDecl":
+		init, err := d.exp(n.Init, n.Kind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &VarDecl{Name: d.sym(n.Name), Typ: d.sym(n.Type), Escape: n.Escape, Init: init, Pos: pos, span: span}, nil
+	case "TypeDecl":
+		ty, err := d.ty(n.Ty)
+		if err != nil {
+			return nil, err
+		}
+
+		return &TypeDecl{TyName: d.sym(n.Name), Ty: ty, Pos: pos, span: span}, nil
+	case "ImportDecl":
+		return &ImportDecl{Path: n.Path, Pos: pos, span: span}, nil
+	case "ErrorDecl":
+		return &ErrorDecl{pos: pos, span: span}, nil
+	}
+
+	return nil, unknownAstKindErr(n.Kind, "declaration")
+}
+
+func (d *astDecoder) ty(n *jsonNode) (Ty, error) {
+	if n == nil {
+		return nil, missingAstNodeErr("TypeDecl", "a type")
+	}
+
+	pos, span := d.pos(n.Pos), d.span(n.Span)
+	switch n.Kind {
+	case "NameTy":
+		return &NameTy{Ty: d.sym(n.Type), pos: pos, span: span}, nil
+	case "RecordTy":
+		fields, err := d.fields(n.Fields)
+		if err != nil {
+			return nil, err
+		}
+
+		return &RecordTy{Fields: fields, pos: pos, span: span}, nil
+	case "ArrayTy":
+		return &ArrayTy{Ty: d.sym(n.Type), pos: pos, span: span}, nil
+	case "ClassTy":
+		class := &ClassTy{Super: d.sym(n.Super), SuperPos: d.pos(n.SuperPos), pos: pos, span: span}
+		for _, a := range n.Attrs {
+			attr, err := d.decl(a)
+			if err != nil {
+				return nil, err
+			}
+
+			v, ok := attr.(*VarDecl)
+			if !ok {
+				return nil, unknownAstKindErr(a.Kind, "attribute")
+			}
+
+			class.Attrs = append(class.Attrs, v)
+		}
+
+		for _, m := range n.Methods {
+			method, err := d.decl(m)
+			if err != nil {
+				return nil, err
+			}
+
+			f, ok := method.(*FuncDecl)
+			if !ok {
+				return nil, unknownAstKindErr(m.Kind, "method")
+			}
+
+			class.Methods = append(class.Methods, f)
+		}
+
+		return class, nil
+	}
+
+	return nil, unknownAstKindErr(n.Kind, "type")
+}
+
+func kindOf(n *jsonNode) string {
+	if n == nil {
+		return "null"
+	}
+
+	return n.Kind
+}
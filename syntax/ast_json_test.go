@@ -0,0 +1,125 @@
+package syntax
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAstJson_Program(t *testing.T) {
+	t.Parallel()
+
+	strs := NewStrings()
+	parser := NewParser(NewLexer("test.tig", bufio.NewReader(strings.NewReader(`a[i].x := -1`))), strs)
+	exp, err := parser.Parse()
+	require.NoError(t, err)
+
+	out := bytes.Buffer{}
+	require.NoError(t, WriteJsonProgram(&out, strs, exp))
+	require.JSONEq(t, `{"version": 1, "file": "test.tig", "program": {
+  "kind": "AssignExp",
+  "span": {"start": {"line": 1, "column": 1, "offset": 0}, "end": {"line": 1, "column": 13, "offset": 12}},
+  "var": {
+    "kind": "FieldVar",
+    "pos": {"line": 1, "column": 3, "offset": 2},
+    "span": {"start": {"line": 1, "column": 1, "offset": 0}, "end": {"line": 1, "column": 7, "offset": 6}},
+    "name": "x",
+    "var": {
+      "kind": "SubscriptionVar",
+      "pos": {"line": 1, "column": 3, "offset": 2},
+      "span": {"start": {"line": 1, "column": 1, "offset": 0}, "end": {"line": 1, "column": 5, "offset": 4}},
+      "var": {
+        "kind": "SimpleVar",
+        "pos": {"line": 1, "column": 1, "offset": 0},
+        "span": {"start": {"line": 1, "column": 1, "offset": 0}, "end": {"line": 1, "column": 2, "offset": 1}},
+        "name": "a"
+      },
+      "exp": {
+        "kind": "VarExp",
+        "span": {"start": {"line": 1, "column": 3, "offset": 2}, "end": {"line": 1, "column": 4, "offset": 3}},
+        "var": {
+          "kind": "SimpleVar",
+          "pos": {"line": 1, "column": 3, "offset": 2},
+          "span": {"start": {"line": 1, "column": 3, "offset": 2}, "end": {"line": 1, "column": 4, "offset": 3}},
+          "name": "i"
+        }
+      }
+    }
+  },
+  "exp": {
+    "kind": "OperExp",
+    "span": {"start": {"line": 1, "column": 11, "offset": 10}, "end": {"line": 1, "column": 13, "offset": 12}},
+    "op": "-",
+    "left": {
+      "kind": "IntExp",
+      "pos": {"line": 1, "column": 11, "offset": 10},
+      "span": {"start": {"line": 1, "column": 11, "offset": 10}, "end": {"line": 1, "column": 11, "offset": 10}},
+      "int": 0
+    },
+    "right": {
+      "kind": "IntExp",
+      "pos": {"line": 1, "column": 12, "offset": 11},
+      "span": {"start": {"line": 1, "column": 12, "offset": 11}, "end": {"line": 1, "column": 13, "offset": 12}},
+      "int": 1
+    }
+  }
+}}`, out.String())
+}
+
+func TestAstJson_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	files, err := filepath.Glob("../test_files/*.tig")
+	require.NoError(t, err)
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		strs := NewStrings()
+		parser := NewParser(NewLexer(file, bufio.NewReader(bytes.NewReader(src))), strs)
+		if IsModule(file, src) {
+			decls, err := parser.ParseModule()
+			if err != nil {
+				continue
+			}
+
+			out := bytes.Buffer{}
+			require.NoError(t, WriteJsonModule(&out, strs, file, decls))
+			decoded, err := ReadJsonModule(&out, strs)
+			require.NoError(t, err, file)
+			require.Equal(t, decls, decoded, file)
+		} else {
+			exp, err := parser.Parse()
+			if err != nil {
+				continue
+			}
+
+			out := bytes.Buffer{}
+			require.NoError(t, WriteJsonProgram(&out, strs, exp))
+			decoded, err := ReadJsonProgram(&out, strs)
+			require.NoError(t, err, file)
+			require.Equal(t, exp, decoded, file)
+		}
+	}
+}
+
+func TestAstJson_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := ReadJsonProgram(strings.NewReader(`{"version": 2, "file": "test.tig"}`), NewStrings())
+	require.EqualError(t, err, "unsupported version 2 of the JSON of the AST, expected 1")
+
+	_, err = ReadJsonProgram(strings.NewReader(`{"version": 1, "file": "test.tig", "program": {"kind": "GotoExp"}}`),
+		NewStrings())
+	require.EqualError(t, err, "unknown expression node GotoExp in the JSON of the AST")
+
+	_, err = ReadJsonProgram(strings.NewReader(`{"version": 1, "file": "test.tig", "program": {"kind": "IfExp"}}`),
+		NewStrings())
+	require.EqualError(t, err, "IfExp is missing an expression in the JSON of the AST")
+}
@@ -0,0 +1,147 @@
+package syntax
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity tells whether a diagnostic stops the compilation.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityNote
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// Note tells more about a diagnostic, at another place of the source when it has a span.
+type Note struct {
+	Msg  string
+	Span *Span
+}
+
+// Diagnostic is a problem found in the source. The code identifies the kind of problem and does not change, unlike
+// the message, so that tools and tests can rely on it. Diagnostics without a span are about the compilation as a
+// whole.
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Span     *Span
+	Msg      string
+	Notes    []Note
+}
+
+func NewDiagnostic(code string, pos Pos, format string, args ...interface{}) *Diagnostic {
+	return NewDiagnosticAt(code, pointSpan(pos), format, args...)
+}
+
+// NewDiagnosticAt is a diagnostic about the source of span, like an expression of the wrong type.
+func NewDiagnosticAt(code string, span Span, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Span:     &span,
+		Msg:      fmt.Sprintf(format, args...),
+	}
+}
+
+// WithNote adds a note about the source at pos.
+func (d *Diagnostic) WithNote(pos Pos, format string, args ...interface{}) *Diagnostic {
+	span := pointSpan(pos)
+	d.Notes = append(d.Notes, Note{Msg: fmt.Sprintf(format, args...), Span: &span})
+	return d
+}
+
+// WithHint adds a note about the diagnostic as a whole, like how to fix it.
+func (d *Diagnostic) WithHint(format string, args ...interface{}) *Diagnostic {
+	d.Notes = append(d.Notes, Note{Msg: fmt.Sprintf(format, args...)})
+	return d
+}
+
+// String prints the diagnostic as "file:line:col: error[code]: message", followed by a line for each note.
+func (d *Diagnostic) String() string {
+	sb := strings.Builder{}
+	if d.Span != nil {
+		sb.WriteString(d.Span.String() + ": ")
+	}
+
+	fmt.Fprintf(&sb, "%s[%s]: %s", d.Severity, d.Code, d.Msg)
+	for _, note := range d.Notes {
+		sb.WriteString("\n\t")
+		if note.Span != nil {
+			sb.WriteString(note.Span.String() + ": ")
+		}
+
+		sb.WriteString("note: " + note.Msg)
+	}
+
+	return sb.String()
+}
+
+func (d *Diagnostic) Error() string {
+	return d.String()
+}
+
+// Diagnostics is the sink that the phases of a compilation report their diagnostics to.
+type Diagnostics struct {
+	list   []*Diagnostic
+	errors int
+}
+
+func NewDiagnostics() *Diagnostics {
+	return &Diagnostics{}
+}
+
+// Report adds the diagnostics of err. Errors that are not diagnostics, like those of reading a file, get the code
+// E0000 and no span.
+func (d *Diagnostics) Report(err error) {
+	var diag *Diagnostic
+	var diags *Diagnostics
+	switch {
+	case errors.As(err, &diags):
+		for _, diag := range diags.list {
+			d.add(diag)
+		}
+	case errors.As(err, &diag):
+		d.add(diag)
+	default:
+		d.add(&Diagnostic{Severity: SeverityError, Code: "E0000", Msg: err.Error()})
+	}
+}
+
+func (d *Diagnostics) add(diag *Diagnostic) {
+	d.list = append(d.list, diag)
+	if diag.Severity == SeverityError {
+		d.errors++
+	}
+}
+
+func (d *Diagnostics) List() []*Diagnostic {
+	return d.list
+}
+
+func (d *Diagnostics) HasErrors() bool {
+	return d.errors > 0
+}
+
+// Error lists the diagnostics, one per line, so that the sink can be returned as the error of a phase.
+func (d *Diagnostics) Error() string {
+	lines := make([]string, 0, len(d.list))
+	for _, diag := range d.list {
+		lines = append(lines, diag.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
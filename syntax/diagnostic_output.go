@@ -0,0 +1,202 @@
+package syntax
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// The diagnostics are written as JSON or SARIF for the tools that read them, like code scanning of pull requests.
+// Lines and columns count from 1, offsets from 0, and the end of a span is the position after its last character.
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+type jsonSpan struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonNote struct {
+	Message string    `json:"message"`
+	File    string    `json:"file,omitempty"`
+	Span    *jsonSpan `json:"span,omitempty"`
+}
+
+type jsonDiagnostic struct {
+	Severity string     `json:"severity"`
+	Code     string     `json:"code"`
+	Message  string     `json:"message"`
+	File     string     `json:"file,omitempty"`
+	Span     *jsonSpan  `json:"span,omitempty"`
+	Notes    []jsonNote `json:"notes,omitempty"`
+}
+
+func newJsonSpan(span *Span) (string, *jsonSpan) {
+	if span == nil {
+		return "", nil
+	}
+
+	return span.Start.FileName, &jsonSpan{
+		Start: jsonPosition{Line: span.Start.Line, Column: span.Start.Col, Offset: span.Start.Offset},
+		End:   jsonPosition{Line: span.End.Line, Column: span.End.Col, Offset: span.End.Offset},
+	}
+}
+
+// WriteJsonDiagnostics writes the diagnostics as {"diagnostics": [...]}, an empty list when there are none.
+func WriteJsonDiagnostics(w io.Writer, diags *Diagnostics) error {
+	list := make([]jsonDiagnostic, 0, len(diags.List()))
+	for _, diag := range diags.List() {
+		d := jsonDiagnostic{Severity: diag.Severity.String(), Code: diag.Code, Message: diag.Msg}
+		d.File, d.Span = newJsonSpan(diag.Span)
+		for _, note := range diag.Notes {
+			n := jsonNote{Message: note.Msg}
+			n.File, n.Span = newJsonSpan(note.Span)
+			d.Notes = append(d.Notes, n)
+		}
+
+		list = append(list, d)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Diagnostics []jsonDiagnostic `json:"diagnostics"`
+	}{list})
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	RuleIndex        int             `json:"ruleIndex"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation struct {
+		URI string `json:"uri"`
+	} `json:"artifactLocation"`
+	Region sarifRegion `json:"region"`
+}
+
+// sarifRegion is a span, whose end column is the column after its last character like in SARIF.
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+	CharOffset  int `json:"charOffset"`
+	CharLength  int `json:"charLength"`
+}
+
+func newSarifLocation(span Span) sarifLocation {
+	loc := sarifLocation{}
+	loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(span.Start.FileName)
+	loc.PhysicalLocation.Region = sarifRegion{
+		StartLine:   span.Start.Line,
+		StartColumn: span.Start.Col,
+		EndLine:     span.End.Line,
+		EndColumn:   span.End.Col,
+		CharOffset:  span.Start.Offset,
+		CharLength:  span.End.Offset - span.Start.Offset,
+	}
+
+	return loc
+}
+
+// WriteSarif writes the diagnostics as a SARIF 2.1.0 log of a single run, whose rules are the codes of the
+// diagnostics. The notes with a span are the related locations of their results.
+func WriteSarif(w io.Writer, diags *Diagnostics) error {
+	var codes []string
+	rules := make(map[string]int)
+	for _, diag := range diags.List() {
+		if _, ok := rules[diag.Code]; !ok {
+			rules[diag.Code] = 0
+			codes = append(codes, diag.Code)
+		}
+	}
+
+	sort.Strings(codes)
+	driver := sarifDriver{Name: "tiger", Rules: make([]sarifRule, 0, len(codes))}
+	for i, code := range codes {
+		rules[code] = i
+		driver.Rules = append(driver.Rules, sarifRule{ID: code})
+	}
+
+	results := make([]sarifResult, 0, len(diags.List()))
+	for _, diag := range diags.List() {
+		result := sarifResult{
+			RuleID:    diag.Code,
+			RuleIndex: rules[diag.Code],
+			Level:     diag.Severity.String(),
+			Message:   sarifMessage{Text: diag.Msg},
+		}
+
+		if diag.Span != nil {
+			result.Locations = append(result.Locations, newSarifLocation(*diag.Span))
+		}
+
+		for _, note := range diag.Notes {
+			if note.Span == nil {
+				result.Message.Text += "\nnote: " + note.Msg
+				continue
+			}
+
+			loc := newSarifLocation(*note.Span)
+			id := len(result.RelatedLocations)
+			loc.ID = &id
+			loc.Message = &sarifMessage{Text: note.Msg}
+			result.RelatedLocations = append(result.RelatedLocations, loc)
+		}
+
+		results = append(results, result)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
@@ -0,0 +1,49 @@
+package syntax
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostic_Formats(t *testing.T) {
+	// the error of let var x: int := "s" in x end
+	span := Span{Start: Pos{FileName: "test.tig", Line: 1, Col: 19, Offset: 18},
+		End: Pos{FileName: "test.tig", Line: 1, Col: 22, Offset: 21}}
+	diags := NewDiagnostics()
+	diags.Report(NewDiagnosticAt("E0116", span, "expected type int, but expression has type string").
+		WithNote(Pos{FileName: "test.tig", Line: 1, Col: 9, Offset: 8}, "x is declared with type int here"))
+	diags.Report(unclosedStringErr(Pos{FileName: "test.tig", Line: 2, Col: 3, Offset: 12}))
+
+	out := bytes.Buffer{}
+	require.NoError(t, WriteJsonDiagnostics(&out, diags))
+	var report struct {
+		Diagnostics []jsonDiagnostic
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	require.Len(t, report.Diagnostics, 2)
+	require.Equal(t, "E0116", report.Diagnostics[0].Code)
+	require.Equal(t, "test.tig", report.Diagnostics[0].File)
+	require.Equal(t, jsonPosition{Line: 1, Column: 19, Offset: 18}, report.Diagnostics[0].Span.Start)
+	require.Equal(t, jsonPosition{Line: 1, Column: 22, Offset: 21}, report.Diagnostics[0].Span.End)
+	require.Len(t, report.Diagnostics[0].Notes, 1)
+	require.Equal(t, "unclosed string", report.Diagnostics[1].Message)
+
+	out.Reset()
+	require.NoError(t, WriteSarif(&out, diags))
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(out.Bytes(), &log))
+	require.Equal(t, "2.1.0", log.Version)
+	require.Equal(t, []sarifRule{{ID: "E0002"}, {ID: "E0116"}}, log.Runs[0].Tool.Driver.Rules)
+
+	results := log.Runs[0].Results
+	require.Len(t, results, 2)
+	require.Equal(t, 1, results[0].RuleIndex)
+	require.Equal(t, "error", results[0].Level)
+	require.Equal(t, sarifRegion{StartLine: 1, StartColumn: 19, EndLine: 1, EndColumn: 22, CharOffset: 18,
+		CharLength: 3}, results[0].Locations[0].PhysicalLocation.Region)
+	require.Len(t, results[0].RelatedLocations, 1)
+	require.Equal(t, "E0002", results[1].RuleID)
+}
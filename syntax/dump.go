@@ -0,0 +1,30 @@
+package syntax
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DumpTokens prints the tokens of the source, one per line with its position, until the end or a lexical error.
+func DumpTokens(sb *strings.Builder, file string, src []byte) {
+	lexer := NewLexer(file, bufio.NewReader(bytes.NewReader(src)))
+	for {
+		tok, err := lexer.Token()
+		if err != nil {
+			fmt.Fprintf(sb, "%v\n", err)
+			return
+		}
+
+		fmt.Fprintf(sb, "%d:%d\t%s", tok.Pos.Line, tok.Pos.Col, tok.Tok)
+		if tok.value != nil {
+			fmt.Fprintf(sb, "\t%v", tok.value)
+		}
+
+		sb.WriteString("\n")
+		if tok.IsEof() {
+			return
+		}
+	}
+}
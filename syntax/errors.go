@@ -0,0 +1,64 @@
+package syntax
+
+import (
+	"fmt"
+)
+
+// The errors of the compiler are diagnostics, identified by their codes: E00xx for the lexer and the parser, E01xx
+// for the semantic analysis, E02xx for classes and E03xx for modules. A code is never reused for another kind of
+// error.
+
+// Lexer errors
+func unclosedCommentErr(pos Pos) error {
+	return NewDiagnostic("E0001", pos, "unclosed comment")
+}
+
+func unclosedStringErr(pos Pos) error {
+	return NewDiagnostic("E0002", pos, "unclosed string")
+}
+
+func invalidEscapeErr(pos Pos) error {
+	return NewDiagnostic("E0003", pos, "invalid escape sequence")
+}
+
+func invalidAsciiCodeErr(pos Pos) error {
+	return NewDiagnostic("E0004", pos, "invalid ASCII code")
+}
+
+func invalidCharErr(pos Pos) error {
+	return NewDiagnostic("E0005", pos, "invalid character")
+}
+
+// Parser errors
+func unexpectedTokErr(pos Pos) error {
+	return NewDiagnostic("E0010", pos, "unexpected token")
+}
+
+func unexpectedEofErr(pos Pos) error {
+	return NewDiagnostic("E0011", pos, "unexpected end of file")
+}
+
+func invalidRecordFieldErr(pos Pos) error {
+	return NewDiagnostic("E0012", pos, "invalid record field")
+}
+
+func breakOutOfScopeErr(pos Pos) error {
+	return NewDiagnostic("E0100", pos, "break expression can only be in while loop or for loop")
+}
+
+// AST errors
+func unsupportedAstVersionErr(version int) error {
+	return fmt.Errorf("unsupported version %d of the JSON of the AST, expected %d", version, astJsonVersion)
+}
+
+func unknownAstKindErr(kind, expected string) error {
+	return fmt.Errorf("unknown %s node %s in the JSON of the AST", expected, kind)
+}
+
+func unknownAstOperatorErr(op string) error {
+	return fmt.Errorf("unknown operator %q in the JSON of the AST", op)
+}
+
+func missingAstNodeErr(key, expected string) error {
+	return fmt.Errorf("%s is missing %s in the JSON of the AST", key, expected)
+}
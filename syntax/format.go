@@ -0,0 +1,675 @@
+package syntax
+
+import (
+	"bufio"
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The formatter of "tiger fmt" prints the AST back as Tiger source in a canonical layout. An expression stays on one
+// line when it fits in fmtWidth columns and has no comment, otherwise it is broken into blocks: the declarations of
+// a let and the expressions of a sequence go one per line, the arguments of a call and the fields of a record too.
+// The comments are kept where they are, either at the end of a line or on their own lines, and a blank line between
+// two declarations or expressions is kept as well.
+
+const (
+	fmtWidth  = 80
+	fmtIndent = "    "
+)
+
+// Formatter prints the AST of src. The identifiers are spelled like their first occurrence in the source, since the
+// symbols of the parser do not tell apart names that differ only by case.
+type Formatter struct {
+	src      []byte
+	strings  *Strings
+	comments []Span
+	next     int
+
+	sb     strings.Builder
+	indent int
+
+	// lastLine is the line in the source of the last node printed, to tell the comments that end it
+	lastLine int
+	// open tells that a block has just been opened, nothing goes between its first line and the opening
+	open bool
+}
+
+// Format returns the source of the file formatted, or the diagnostics when it does not parse. The source is not
+// checked, only parsed.
+func Format(file string, src []byte) (string, error) {
+	lexer := NewLexer(file, bufio.NewReader(bytes.NewReader(src)))
+	lexer.trivia = true
+	f := &Formatter{src: src, strings: NewStrings()}
+	parser := NewParser(lexer, f.strings)
+
+	if IsModule(file, src) {
+		decls, err := parser.ParseModule()
+		if err != nil {
+			return "", err
+		}
+
+		f.comments = lexer.comments
+		f.decls(decls)
+	} else {
+		exp, err := parser.Parse()
+		if err != nil {
+			return "", err
+		}
+
+		f.comments = lexer.comments
+		f.item(exp.Span())
+		f.exp(exp)
+	}
+
+	f.flush(len(src))
+	return strings.TrimRight(f.sb.String(), " \n") + "\n", nil
+}
+
+func (f *Formatter) write(s string) {
+	f.sb.WriteString(s)
+	f.open = false
+}
+
+// newline starts a line at the indentation of the block, unless the current line is empty.
+func (f *Formatter) newline() {
+	f.trim()
+	if s := f.sb.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		f.sb.WriteString("\n")
+	}
+
+	f.sb.WriteString(strings.Repeat(fmtIndent, f.indent))
+}
+
+// blankLine starts a line after an empty one.
+func (f *Formatter) blankLine() {
+	f.trim()
+	if s := f.sb.String(); !strings.HasSuffix(s, "\n") {
+		f.sb.WriteString("\n")
+	}
+
+	if s := f.sb.String(); !strings.HasSuffix(s, "\n\n") {
+		f.sb.WriteString("\n")
+	}
+
+	f.sb.WriteString(strings.Repeat(fmtIndent, f.indent))
+}
+
+// trim removes the spaces at the end of the output.
+func (f *Formatter) trim() {
+	s := f.sb.String()
+	if trimmed := strings.TrimRight(s, " "); len(trimmed) != len(s) {
+		f.sb.Reset()
+		f.sb.WriteString(trimmed)
+	}
+}
+
+// col is the column of the end of the output, from 0.
+func (f *Formatter) col() int {
+	s := f.sb.String()
+	return len(s) - strings.LastIndexByte(s, '\n') - 1
+}
+
+// atLineStart tells whether the current line of the output is only indentation.
+func (f *Formatter) atLineStart() bool {
+	s := f.sb.String()
+	return strings.TrimSpace(s[strings.LastIndexByte(s, '\n')+1:]) == ""
+}
+
+func (f *Formatter) text(span Span) string {
+	return string(f.src[span.Start.Offset:span.End.Offset])
+}
+
+func (f *Formatter) name(sym Symbol) string {
+	return f.strings.Get(sym)
+}
+
+// hasComment tells whether a comment starts inside span, so that it cannot be printed on one line.
+func (f *Formatter) hasComment(span Span) bool {
+	for _, c := range f.comments[f.next:] {
+		if c.Start.Offset >= span.End.Offset {
+			return false
+		}
+
+		if c.Start.Offset >= span.Start.Offset {
+			return true
+		}
+	}
+
+	return false
+}
+
+// blankBefore tells whether the source has an empty line before offset, in the blank before it.
+func (f *Formatter) blankBefore(offset int) bool {
+	lines := 0
+	for i := offset - 1; i >= 0; i-- {
+		switch f.src[i] {
+		case '\n':
+			lines++
+		case ' ', '\t', '\r':
+		default:
+			return lines > 1
+		}
+	}
+
+	return false
+}
+
+// skip returns the offset of the first token from offset on, after the blanks and the comments.
+func (f *Formatter) skip(offset int) int {
+	c := f.next
+	for offset < len(f.src) {
+		for c < len(f.comments) && f.comments[c].End.Offset <= offset {
+			c++
+		}
+
+		switch {
+		case c < len(f.comments) && f.comments[c].Start.Offset == offset:
+			offset = f.comments[c].End.Offset
+		case isSpace(f.src[offset]):
+			offset++
+		default:
+			return offset
+		}
+	}
+
+	return offset
+}
+
+// commentBefore tells whether a comment left to print starts before offset.
+func (f *Formatter) commentBefore(offset int) bool {
+	return f.next < len(f.comments) && f.comments[f.next].Start.Offset < offset
+}
+
+// flush prints the comments that start before offset. A comment on the line of the node printed before it stays at
+// the end of that line, the others go on their own lines.
+func (f *Formatter) flush(offset int) {
+	for ; f.next < len(f.comments) && f.comments[f.next].Start.Offset < offset; f.next++ {
+		c := f.comments[f.next]
+		if c.Start.Line == f.lastLine && !f.atLineStart() {
+			f.write(" " + f.text(c))
+		} else {
+			f.startLine(c.Start.Offset)
+			f.write(f.text(c))
+			f.newline()
+		}
+
+		f.lastLine = c.End.Line
+	}
+}
+
+// startLine starts the line of what is at offset in the source, after an empty line when there is one before it
+// in the source. The first line of a block or of the file is never empty.
+func (f *Formatter) startLine(offset int) {
+	if f.blankBefore(offset) && !f.open && f.sb.Len() > 0 {
+		f.blankLine()
+	} else {
+		f.newline()
+	}
+}
+
+// item starts an element of a block on a new line, after the comments before it.
+func (f *Formatter) item(span Span) {
+	f.flush(span.Start.Offset)
+	f.startLine(span.Start.Offset)
+}
+
+// close ends a block at its last line, with the comments left in the span of the node it belongs to.
+func (f *Formatter) close(span Span, closing string) {
+	f.flush(span.End.Offset)
+	f.indent--
+	f.newline()
+	f.write(closing)
+	f.lastLine = span.End.Line
+}
+
+func (f *Formatter) fits(s string) bool {
+	return f.col()+len(s) <= fmtWidth
+}
+
+// exp prints e on one line when it fits, otherwise as a block.
+func (f *Formatter) exp(e Exp) {
+	f.flush(e.Span().Start.Offset)
+	if s, ok := f.flatExp(e); ok && f.fits(s) {
+		f.write(s)
+		f.lastLine = e.Span().End.Line
+		return
+	}
+
+	switch v := e.(type) {
+	case *LetExp:
+		f.write("let")
+		f.indent++
+		f.open = true
+		f.decls(v.Decls)
+		// the comments after the last declaration stay before "in"
+		end := v.Span().Start.Offset + len("let")
+		if len(v.Decls) > 0 {
+			end = v.Decls[len(v.Decls)-1].Span().End.Offset
+		}
+
+		f.flush(f.skip(end))
+		f.indent--
+		f.newline()
+		f.write("in")
+		f.indent++
+		f.open = true
+		f.sequence(v.Body)
+		f.close(v.Span(), "end")
+	case *SequenceExp:
+		f.write("(")
+		f.indent++
+		f.open = true
+		f.sequence(v)
+		f.close(v.Span(), ")")
+	case *IfExp:
+		f.write("if ")
+		f.exp(v.Predicate)
+		f.newline()
+		f.write("then")
+		f.hang(v.Then)
+		if v.Els != nil {
+			f.newline()
+			f.write("else")
+			if _, ok := v.Els.(*IfExp); ok {
+				f.write(" ")
+				f.exp(v.Els)
+			} else {
+				f.hang(v.Els)
+			}
+		}
+	case *WhileExp:
+		f.write("while ")
+		f.exp(v.Pred)
+		f.write(" do")
+		f.hang(v.Body)
+	case *ForExp:
+		f.write("for " + f.name(v.Sym) + " := ")
+		f.exp(v.From)
+		f.write(" to ")
+		f.exp(v.To)
+		f.write(" do")
+		f.hang(v.Body)
+	case *AssignExp:
+		f.write(f.flatVar(v.Variable) + " :=")
+		f.hang(v.Exp)
+	case *ArrExp:
+		f.write(f.name(v.Typ) + "[")
+		f.exp(v.Size)
+		f.write("] of")
+		f.hang(v.Init)
+	case *OperExp:
+		if isNegation(v) {
+			f.write("-")
+			f.exp(v.Right)
+			break
+		}
+
+		f.exp(v.Left)
+		f.write(" " + v.Op.Repr())
+		f.indent++
+		f.newline()
+		f.exp(v.Right)
+		f.indent--
+	case *CallExp:
+		f.write(f.name(v.Function))
+		f.args(v.Args, v.Span())
+	case *MethodCallExp:
+		f.write(f.flatVar(v.Variable) + "." + f.name(v.Method))
+		f.args(v.Args, v.Span())
+	case *RecordExp:
+		f.write(f.name(v.Ty) + "{")
+		f.indent++
+		f.open = true
+		width := 0
+		for _, field := range v.Fields {
+			if n := len(f.name(field.Ident)); n > width {
+				width = n
+			}
+		}
+
+		for i, field := range v.Fields {
+			f.item(field.Span())
+			f.write(padRight(f.name(field.Ident), width) + " = ")
+			f.exp(field.Expr)
+			if i < len(v.Fields)-1 {
+				f.write(",")
+			}
+		}
+
+		f.close(v.Span(), "}")
+	default:
+		// the other expressions are always flat
+		s, _ := f.flatExp(e)
+		f.write(s)
+	}
+
+	f.lastLine = e.Span().End.Line
+}
+
+// hang prints e after a keyword that ends the line: on the same line when it fits or when it is in parentheses,
+// otherwise on the next line and indented. The comments before e go on lines of their own, whatever the line of the
+// keyword in the source, so that formatting again gives the same, and e goes after them, indented like them.
+func (f *Formatter) hang(e Exp) {
+	if f.commentBefore(e.Span().Start.Offset) {
+		f.indent++
+		f.lastLine = 0
+		f.flush(e.Span().Start.Offset)
+		f.newline()
+		f.exp(e)
+		f.indent--
+		return
+	}
+
+	if s, ok := f.flatExp(e); ok && f.fits(" "+s) {
+		f.write(" " + s)
+		f.lastLine = e.Span().End.Line
+		return
+	}
+
+	switch e.(type) {
+	case *SequenceExp:
+		f.write(" ")
+		f.exp(e)
+	default:
+		f.indent++
+		f.newline()
+		f.exp(e)
+		f.indent--
+	}
+}
+
+// sequence prints the expressions of the body of a let or of parentheses, one per line.
+func (f *Formatter) sequence(e Exp) {
+	exps := []Exp{e}
+	if seq, ok := e.(*SequenceExp); ok {
+		exps = seq.Exps
+	}
+
+	for i, exp := range exps {
+		f.item(exp.Span())
+		f.exp(exp)
+		if i < len(exps)-1 {
+			f.write(";")
+		}
+	}
+}
+
+func (f *Formatter) args(args []Exp, span Span) {
+	f.write("(")
+	f.indent++
+	f.open = true
+	for i, arg := range args {
+		f.item(arg.Span())
+		f.exp(arg)
+		if i < len(args)-1 {
+			f.write(",")
+		}
+	}
+
+	f.close(span, ")")
+}
+
+// decls prints declarations one per line, the members of a class in the order of the source.
+func (f *Formatter) decls(decls []Declaration) {
+	for _, decl := range decls {
+		f.item(decl.Span())
+		f.decl(decl)
+		f.lastLine = decl.Span().End.Line
+	}
+}
+
+func (f *Formatter) decl(decl Declaration) {
+	switch v := decl.(type) {
+	case *VarDecl:
+		f.write("var " + f.name(v.Name))
+		if v.Typ != 0 {
+			f.write(": " + f.name(v.Typ))
+		}
+
+		f.write(" :=")
+		f.hang(v.Init)
+	case *FuncDecl:
+		f.write("function ")
+		f.funcDecl(v)
+	case *PrimitiveDecl:
+		f.write("primitive " + f.name(v.Name) + "(" + f.fields(v.Params) + ")")
+		if v.ResultTy != 0 {
+			f.write(": " + f.name(v.ResultTy))
+		}
+	case *TypeDecl:
+		if class, ok := v.Ty.(*ClassTy); ok && class.pos == v.Pos {
+			f.write("class " + f.name(v.TyName))
+			f.class(class)
+			break
+		}
+
+		f.write("type " + f.name(v.TyName) + " = ")
+		f.ty(v.Ty)
+	case *ImportDecl:
+		f.write("import " + strings.TrimSpace(f.text(v.Span())[len("import"):]))
+	}
+}
+
+func (f *Formatter) funcDecl(v *FuncDecl) {
+	f.write(f.name(v.Name) + "(" + f.fields(v.Params) + ")")
+	if v.ResultTy != 0 {
+		f.write(": " + f.name(v.ResultTy))
+	}
+
+	f.write(" =")
+	f.hang(v.Body)
+}
+
+func (f *Formatter) fields(fields []*Field) string {
+	params := make([]string, 0, len(fields))
+	for _, field := range fields {
+		params = append(params, f.name(field.Name)+": "+f.name(field.Typ))
+	}
+
+	return strings.Join(params, ", ")
+}
+
+func (f *Formatter) ty(t Ty) {
+	switch v := t.(type) {
+	case *NameTy:
+		f.write(f.name(v.Ty))
+	case *ArrayTy:
+		f.write("array of " + f.name(v.Ty))
+	case *RecordTy:
+		flat := "{" + f.fields(v.Fields) + "}"
+		if !f.hasComment(v.Span()) && f.fits(flat) {
+			f.write(flat)
+			break
+		}
+
+		// the types of the fields are aligned
+		width := 0
+		for _, field := range v.Fields {
+			if n := len(f.name(field.Name)); n > width {
+				width = n
+			}
+		}
+
+		f.write("{")
+		f.indent++
+		f.open = true
+		for i, field := range v.Fields {
+			f.item(field.Span())
+			f.write(padRight(f.name(field.Name)+":", width+1) + " " + f.name(field.Typ))
+			if i < len(v.Fields)-1 {
+				f.write(",")
+			}
+
+			f.lastLine = field.Span().End.Line
+		}
+
+		f.close(v.Span(), "}")
+	case *ClassTy:
+		f.write("class")
+		f.class(v)
+	}
+}
+
+// class prints the rest of a class after its name, the attributes and the methods in the order of the source.
+func (f *Formatter) class(v *ClassTy) {
+	if v.SuperPos != v.pos {
+		f.write(" extends " + f.name(v.Super))
+	}
+
+	members := make([]Declaration, 0, len(v.Attrs)+len(v.Methods))
+	for _, attr := range v.Attrs {
+		members = append(members, attr)
+	}
+
+	for _, method := range v.Methods {
+		members = append(members, method)
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].Span().Start.Offset < members[j].Span().Start.Offset
+	})
+
+	if len(members) == 0 && !f.hasComment(v.Span()) {
+		f.write(" {}")
+		return
+	}
+
+	f.write(" {")
+	f.indent++
+	f.open = true
+	for _, member := range members {
+		f.item(member.Span())
+		if method, ok := member.(*FuncDecl); ok {
+			f.write("method ")
+			f.funcDecl(method)
+		} else {
+			f.decl(member)
+		}
+
+		f.lastLine = member.Span().End.Line
+	}
+
+	f.close(v.Span(), "}")
+}
+
+// flatExp returns e on one line, false when it cannot be: a let, or an expression with a comment.
+func (f *Formatter) flatExp(e Exp) (string, bool) {
+	if f.hasComment(e.Span()) {
+		return "", false
+	}
+
+	switch v := e.(type) {
+	case *IntExp:
+		return strconv.Itoa(int(v.Val)), true
+	case *StrExp:
+		// the literal is printed as it is written, with its escapes
+		return f.text(v.Span()), true
+	case *NilExp:
+		return "nil", true
+	case *UnitExp:
+		return "()", true
+	case *BreakExp:
+		return "break", true
+	case *NewExp:
+		return "new " + f.name(v.Ty), true
+	case *VarExp:
+		return f.flatVar(v.Var), true
+	case *OperExp:
+		if isNegation(v) {
+			right, ok := f.flatExp(v.Right)
+			return "-" + right, ok
+		}
+
+		left, ok := f.flatExp(v.Left)
+		right, ok2 := f.flatExp(v.Right)
+		return left + " " + v.Op.Repr() + " " + right, ok && ok2
+	case *CallExp:
+		args, ok := f.flatExps(v.Args, ", ")
+		return f.name(v.Function) + "(" + args + ")", ok
+	case *MethodCallExp:
+		args, ok := f.flatExps(v.Args, ", ")
+		return f.flatVar(v.Variable) + "." + f.name(v.Method) + "(" + args + ")", ok
+	case *RecordExp:
+		fields := make([]string, 0, len(v.Fields))
+		for _, field := range v.Fields {
+			s, ok := f.flatExp(field.Expr)
+			if !ok {
+				return "", false
+			}
+
+			fields = append(fields, f.name(field.Ident)+" = "+s)
+		}
+
+		return f.name(v.Ty) + "{" + strings.Join(fields, ", ") + "}", true
+	case *ArrExp:
+		size, ok := f.flatExp(v.Size)
+		init, ok2 := f.flatExp(v.Init)
+		return f.name(v.Typ) + "[" + size + "] of " + init, ok && ok2
+	case *AssignExp:
+		s, ok := f.flatExp(v.Exp)
+		return f.flatVar(v.Variable) + " := " + s, ok
+	case *SequenceExp:
+		exps, ok := f.flatExps(v.Exps, "; ")
+		return "(" + exps + ")", ok
+	case *IfExp:
+		pred, ok := f.flatExp(v.Predicate)
+		then, ok2 := f.flatExp(v.Then)
+		s := "if " + pred + " then " + then
+		if v.Els != nil {
+			els, ok3 := f.flatExp(v.Els)
+			s += " else " + els
+			ok2 = ok2 && ok3
+		}
+
+		return s, ok && ok2
+	case *WhileExp:
+		pred, ok := f.flatExp(v.Pred)
+		body, ok2 := f.flatExp(v.Body)
+		return "while " + pred + " do " + body, ok && ok2
+	case *ForExp:
+		from, ok := f.flatExp(v.From)
+		to, ok2 := f.flatExp(v.To)
+		body, ok3 := f.flatExp(v.Body)
+		return "for " + f.name(v.Sym) + " := " + from + " to " + to + " do " + body, ok && ok2 && ok3
+	default:
+		return "", false
+	}
+}
+
+func (f *Formatter) flatExps(exps []Exp, sep string) (string, bool) {
+	list := make([]string, 0, len(exps))
+	for _, e := range exps {
+		s, ok := f.flatExp(e)
+		if !ok {
+			return "", false
+		}
+
+		list = append(list, s)
+	}
+
+	return strings.Join(list, sep), true
+}
+
+func (f *Formatter) flatVar(v Var) string {
+	switch v := v.(type) {
+	case *FieldVar:
+		return f.flatVar(v.Variable) + "." + f.name(v.Field)
+	case *SubscriptionVar:
+		s, _ := f.flatExp(v.Exp)
+		return f.flatVar(v.Variable) + "[" + s + "]"
+	default:
+		return f.name(v.(*SimpleVar).Symbol)
+	}
+}
+
+// isNegation tells whether e is -x, which the parser reads as 0 - x with a 0 that is not in the source.
+func isNegation(e *OperExp) bool {
+	left, ok := e.Left.(*IntExp)
+	return ok && e.Op == Minus && left.Val == 0 && left.Span().IsEmpty()
+}
+
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", width-len(s))
+}
@@ -0,0 +1,77 @@
+package syntax
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat_Layout(t *testing.T) {
+	src := `/* points */
+let type point = {x: int, y: int} /* a record */
+var p := point{x=1,y=2}
+
+
+function dist(a: point, b: point): int = (a.x-b.x)*(a.x-b.x)+(a.y-b.y)*(a.y-b.y)+(a.x-b.x)*(a.y-b.y)
+/* the last one */
+in if p.x=1 & p.y=2 & -p.x<0 then (print("a"); /* b */ print("b")) end`
+
+	out, err := Format("test.tig", []byte(src))
+	require.NoError(t, err)
+	require.Equal(t, `/* points */
+let
+    type point = {x: int, y: int} /* a record */
+    var p := point{x = 1, y = 2}
+
+    function dist(a: point, b: point): int =
+        (a.x - b.x) * (a.x - b.x) + (a.y - b.y) * (a.y - b.y) +
+            (a.x - b.x) * (a.y - b.y)
+    /* the last one */
+in
+    if p.x = 1 & p.y = 2 & -p.x < 0
+    then (
+        print("a"); /* b */
+        print("b")
+    )
+end
+`, out)
+}
+
+func TestFormat_Idempotent(t *testing.T) {
+	files, err := filepath.Glob("../test_files/*.tig")
+	require.NoError(t, err)
+
+	srcs := map[string][]byte{
+		// comments inside expressions, which test_files has none of
+		"init.tig":   []byte(`let var x := /* c */ 1 in x end`),
+		"assign.tig": []byte(`let var x /* c */ := 1 in x end`),
+		"then.tig":   []byte("let var x := 1 in if x then /* e */ x else\n /* f */ 3 end"),
+	}
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		require.NoError(t, err)
+		srcs[file] = src
+	}
+
+	for file, src := range srcs {
+		out, err := Format(file, src)
+		if err != nil {
+			// the files with syntax errors are not formatted
+			continue
+		}
+
+		again, err := Format(file, []byte(out))
+		require.NoError(t, err, file)
+		require.Equal(t, out, again, file)
+		require.Equal(t, strings.Count(string(src), "/*"), strings.Count(out, "/*"), file)
+	}
+
+	// the expression after the comments is indented like them
+	out, err := Format("init.tig", srcs["init.tig"])
+	require.NoError(t, err)
+	require.Equal(t, "let\n    var x :=\n        /* c */\n        1\nin\n    x\nend\n", out)
+}
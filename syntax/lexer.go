@@ -1,8 +1,7 @@
-package main
+package syntax
 
 import (
 	"bufio"
-	"fmt"
 	"io"
 	"strconv"
 	"strings"
@@ -12,6 +11,10 @@ type Lexer struct {
 	buf     *bufio.Reader
 	pos     *Pos
 	stopped bool
+
+	// trivia keeps the spans of the comments in comments, for the tools that print the source back like "tiger fmt"
+	trivia   bool
+	comments []Span
 }
 
 func NewLexer(fn string, buf *bufio.Reader) *Lexer {
@@ -19,9 +22,9 @@ func NewLexer(fn string, buf *bufio.Reader) *Lexer {
 		buf:     buf,
 		stopped: false,
 		pos: &Pos{
-			fileName: fn,
-			line:     1,
-			col:      1,
+			FileName: fn,
+			Line:     1,
+			Col:      1,
 		},
 	}
 }
@@ -45,13 +48,14 @@ func (lex *Lexer) advance() error {
 		return err
 	}
 
+	lex.pos.Offset++
 	if c == '\n' {
-		lex.pos.col = 1
-		lex.pos.line++
+		lex.pos.Col = 1
+		lex.pos.Line++
 		return nil
 	}
 
-	lex.pos.col++
+	lex.pos.Col++
 	return nil
 }
 
@@ -115,7 +119,7 @@ func (lex *Lexer) takeWhile(cond func(c byte) bool) (string, error) {
 
 func (lex *Lexer) integer() (*Token, error) {
 	pos := *lex.pos
-	numRepr, err := lex.takeWhile(isNumeric)
+	numRepr, err := lex.takeWhile(IsNumeric)
 	if err != nil {
 		if err == io.EOF {
 			lex.stopped = true
@@ -135,7 +139,7 @@ func (lex *Lexer) integer() (*Token, error) {
 func (lex *Lexer) identifier() (*Token, error) {
 	pos := *lex.pos
 	id, err := lex.takeWhile(func(c byte) bool {
-		return isAlphaNumeric(c) || isUnderscore(c)
+		return IsAlphaNumeric(c) || IsUnderscore(c)
 	})
 	if err != nil {
 		if err == io.EOF {
@@ -166,16 +170,22 @@ func (lex *Lexer) identifier() (*Token, error) {
 		return NewFunction(pos), nil
 	case "if":
 		return NewIf(pos), nil
+	case "import":
+		return NewImport(pos), nil
 	case "in":
 		return NewIn(pos), nil
 	case "let":
 		return NewLet(pos), nil
 	case "method":
 		return NewMethod(pos), nil
+	case "new":
+		return NewNew(pos), nil
 	case "nil":
 		return NewNil(pos), nil
 	case "of":
 		return NewOf(pos), nil
+	case "primitive":
+		return NewPrimitive(pos), nil
 	case "then":
 		return NewThen(pos), nil
 	case "to":
@@ -230,17 +240,38 @@ func (lex *Lexer) greaterOrGreaterEq() (*Token, error) {
 	return lex.twoCharsToken('=', NewGreaterOrEqual, NewGreater)
 }
 
+// lesserOrLesserEq reads <, <= or <>, which is the != of Appel's Tiger.
 func (lex *Lexer) lesserOrLesserEq() (*Token, error) {
-	return lex.twoCharsToken('=', NewLesserOrEqual, NewLesser)
+	pos := *lex.pos
+	tok, err := lex.twoCharsToken('=', NewLesserOrEqual, NewLesser)
+	if err != nil || tok.Tok != "<" {
+		return tok, err
+	}
+
+	curChar, err := lex.currentChar()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if err == nil && curChar == '>' {
+		if err := lex.advance(); err != nil {
+			return nil, err
+		}
+
+		return NewNotEqual(pos), nil
+	}
+
+	return tok, nil
 }
 
-func (lex *Lexer) comment() error {
+// comment skips a comment that starts at start, comments can be nested. An unclosed comment is reported where it
+// starts, since its end is the end of the file.
+func (lex *Lexer) comment(start Pos) error {
 	depth := 1
 	for depth > 0 {
 		if err := lex.advance(); err != nil {
 			if err == io.EOF {
-				// TODO: move error to a new file
-				return fmt.Errorf("unclosed comment %+v", lex.pos)
+				return unclosedCommentErr(start)
 			}
 
 			return err
@@ -248,13 +279,17 @@ func (lex *Lexer) comment() error {
 
 		curChar, err := lex.currentChar()
 		if err != nil {
+			if err == io.EOF {
+				return unclosedCommentErr(start)
+			}
+
 			return err
 		}
 
 		if curChar == '/' {
 			if err := lex.advance(); err != nil {
 				if err == io.EOF {
-					return fmt.Errorf("unclosed comment %+v", lex.pos)
+					return unclosedCommentErr(start)
 				}
 
 				return err
@@ -262,6 +297,10 @@ func (lex *Lexer) comment() error {
 
 			curChar, err := lex.currentChar()
 			if err != nil {
+				if err == io.EOF {
+					return unclosedCommentErr(start)
+				}
+
 				return err
 			}
 
@@ -271,7 +310,7 @@ func (lex *Lexer) comment() error {
 		} else if curChar == '*' {
 			if err := lex.advance(); err != nil {
 				if err == io.EOF {
-					return fmt.Errorf("unclosed comment %+v", lex.pos)
+					return unclosedCommentErr(start)
 				}
 
 				return err
@@ -280,7 +319,7 @@ func (lex *Lexer) comment() error {
 			curChar, err := lex.currentChar()
 			if err != nil {
 				if err == io.EOF {
-					return fmt.Errorf("unclosed comment %+v", lex.pos)
+					return unclosedCommentErr(start)
 				}
 
 				return err
@@ -307,7 +346,7 @@ func (lex *Lexer) divOrCmt() (*Token, error) {
 	}
 
 	if curChar == '*' {
-		if err := lex.comment(); err != nil {
+		if err := lex.comment(pos); err != nil {
 			return nil, err
 		}
 
@@ -315,6 +354,10 @@ func (lex *Lexer) divOrCmt() (*Token, error) {
 			return nil, err
 		}
 
+		if lex.trivia {
+			lex.comments = append(lex.comments, Span{Start: pos, End: *lex.pos})
+		}
+
 		return nil, nil
 	}
 
@@ -338,7 +381,7 @@ func (lex *Lexer) string() (*Token, error) {
 			pos := *lex.pos
 			if err := lex.advance(); err != nil {
 				if err == io.EOF {
-					return nil, fmt.Errorf("unclosed string %+v", pos)
+					return nil, unclosedStringErr(pos)
 				}
 
 				return nil, err
@@ -364,23 +407,23 @@ func (lex *Lexer) string() (*Token, error) {
 
 				if err := lex.advance(); err != nil {
 					if err == io.EOF {
-						return nil, fmt.Errorf("unclosed string %+v", pos)
+						return nil, unclosedStringErr(pos)
 					}
 
 					return nil, err
 				}
-			} else if isNumeric(curChar) {
-				num, err := lex.takeWhile(isNumeric)
+			} else if IsNumeric(curChar) {
+				num, err := lex.takeWhile(IsNumeric)
 				if err != nil {
 					if err == io.EOF {
-						return nil, fmt.Errorf("unclosed string %+v", pos)
+						return nil, unclosedStringErr(pos)
 					}
 
 					return nil, err
 				}
 
 				if len(num) != 3 {
-					return nil, fmt.Errorf("invalid escape %+v", pos)
+					return nil, lex.skipString(invalidEscapeErr(pos))
 				} else {
 					num, err := strconv.Atoi(num)
 					if err != nil {
@@ -388,13 +431,13 @@ func (lex *Lexer) string() (*Token, error) {
 					}
 
 					if num > 255 {
-						return nil, fmt.Errorf("invalid ASCII code %+v", pos)
+						return nil, lex.skipString(invalidAsciiCodeErr(pos))
 					}
 
 					buf.Write([]byte(strconv.Itoa(num)))
 				}
 			} else {
-				return nil, fmt.Errorf("invalid escape %+v", pos)
+				return nil, lex.skipString(invalidEscapeErr(pos))
 			}
 		} else {
 			if err := buf.WriteByte(curChar); err != nil {
@@ -403,7 +446,7 @@ func (lex *Lexer) string() (*Token, error) {
 
 			if err := lex.advance(); err != nil {
 				if err == io.EOF {
-					return nil, fmt.Errorf("unclosed string %+v", pos)
+					return nil, unclosedStringErr(pos)
 				}
 
 				return nil, err
@@ -413,7 +456,7 @@ func (lex *Lexer) string() (*Token, error) {
 		curChar, err = lex.currentChar()
 		if err != nil {
 			if err == io.EOF {
-				return nil, fmt.Errorf("unclosed string %+v", pos)
+				return nil, unclosedStringErr(pos)
 			}
 
 			return nil, err
@@ -427,7 +470,48 @@ func (lex *Lexer) string() (*Token, error) {
 	return NewStr(buf.String(), pos), nil
 }
 
+// skipString skips the rest of a string after an error in it, up to and past the closing quote, so that the lexer
+// goes on after the string rather than in it. It returns err, or the error of the reader.
+func (lex *Lexer) skipString(err error) error {
+	for {
+		c, rerr := lex.currentChar()
+		if rerr != nil {
+			if rerr == io.EOF {
+				return err
+			}
+
+			return rerr
+		}
+
+		if rerr := lex.advance(); rerr != nil && rerr != io.EOF {
+			return rerr
+		}
+
+		if c == '"' {
+			return err
+		}
+
+		// an escaped quote does not close the string
+		if c == '\\' {
+			if rerr := lex.advance(); rerr != nil && rerr != io.EOF {
+				return rerr
+			}
+		}
+	}
+}
+
+// Token returns the next token, whose span ends where the lexer stops after it.
 func (lex *Lexer) Token() (*Token, error) {
+	tok, err := lex.token()
+	if err != nil {
+		return nil, err
+	}
+
+	tok.End = *lex.pos
+	return tok, nil
+}
+
+func (lex *Lexer) token() (*Token, error) {
 	pos := *lex.pos
 	if lex.stopped {
 		return NewEndOfFile(pos), nil
@@ -447,16 +531,16 @@ func (lex *Lexer) Token() (*Token, error) {
 			return nil, err
 		}
 
-		return lex.Token()
+		return lex.token()
 	}
 
 	// Numbers
-	if isNumeric(curChar) {
+	if IsNumeric(curChar) {
 		return lex.integer()
 	}
 
 	// Identifiers
-	if isLower(curChar) || isUpper(curChar) || isUnderscore(curChar) {
+	if IsLower(curChar) || IsUpper(curChar) || IsUnderscore(curChar) {
 		return lex.identifier()
 	}
 
@@ -509,7 +593,7 @@ func (lex *Lexer) Token() (*Token, error) {
 		}
 
 		if curChar != '=' {
-			return nil, fmt.Errorf("invalid character %+v", pos)
+			return nil, invalidCharErr(pos)
 		}
 
 		if err := lex.advance(); err != nil {
@@ -527,10 +611,15 @@ func (lex *Lexer) Token() (*Token, error) {
 			return tok, nil
 		}
 
-		return lex.Token()
+		return lex.token()
 	case '"':
 		return lex.string()
 	}
 
-	return nil, fmt.Errorf("invalid character %+v", pos)
+	// skip the character, so that the parser can go on after the error
+	if err := lex.advance(); err != nil {
+		return nil, err
+	}
+
+	return nil, invalidCharErr(pos)
 }
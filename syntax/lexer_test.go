@@ -1,4 +1,4 @@
-package main
+package syntax
 
 import (
 	"bufio"
@@ -12,28 +12,28 @@ import (
 func TestLexer_HappyCase(t *testing.T) {
 	t.Parallel()
 
-	fn := "./test_files/hello.tig"
+	fn := "../test_files/hello.tig"
 	f, err := os.ReadFile(fn)
 	require.NoError(t, err)
 	buf := bufio.NewReader(bytes.NewReader(f))
 	lexer := NewLexer(fn, buf)
 	token, err := lexer.Token()
 	require.NoError(t, err)
-	require.Equal(t, "ident", token.tok)
+	require.Equal(t, "ident", token.Tok)
 	require.EqualValues(t, "print", token.value)
 
 	token, err = lexer.Token()
 	require.NoError(t, err)
-	require.Equal(t, "(", token.tok)
+	require.Equal(t, "(", token.Tok)
 
 	token, err = lexer.Token()
 	require.NoError(t, err)
-	require.Equal(t, "str", token.tok)
+	require.Equal(t, "str", token.Tok)
 	require.EqualValues(t, "Hello, World!\n", token.value)
 
 	token, err = lexer.Token()
 	require.NoError(t, err)
-	require.Equal(t, ")", token.tok)
+	require.Equal(t, ")", token.Tok)
 }
 
 func TestLexer_Comment(t *testing.T) {
@@ -67,10 +67,30 @@ func TestLexer_Comment(t *testing.T) {
 		lexer := NewLexer("", buf)
 		tok, err := lexer.Token()
 		require.NoError(t, err)
-		require.EqualValues(t, "eof", tok.tok)
+		require.EqualValues(t, "eof", tok.Tok)
 	})
 }
 
+func TestLexer_NotEqual(t *testing.T) {
+	t.Parallel()
+
+	str := `a <> b != c < d <= e <`
+	buf := bufio.NewReader(bytes.NewReader([]byte(str)))
+	lexer := NewLexer("", buf)
+	var toks []string
+	for {
+		tok, err := lexer.Token()
+		require.NoError(t, err)
+		if tok.Tok == "eof" {
+			break
+		}
+
+		toks = append(toks, tok.Tok)
+	}
+
+	require.Equal(t, []string{"ident", "!=", "ident", "!=", "ident", "<", "ident", "<=", "ident", "<"}, toks)
+}
+
 func TestLexer_String(t *testing.T) {
 	t.Parallel()
 
@@ -82,7 +102,7 @@ func TestLexer_String(t *testing.T) {
 		lexer := NewLexer("", buf)
 		token, err := lexer.Token()
 		require.NoError(t, err)
-		require.Equal(t, "str", token.tok)
+		require.Equal(t, "str", token.Tok)
 		require.EqualValues(t, " string \" \n \t 123 ", token.value)
 	})
 
@@ -99,45 +119,45 @@ func TestLexer_String(t *testing.T) {
 	t.Run("unclosed_string", func(t *testing.T) {
 		t.Parallel()
 
-		fn := "./test_files/unclosed_string.tig"
+		fn := "../test_files/unclosed_string.tig"
 		f, err := os.ReadFile(fn)
 		require.NoError(t, err)
 		buf := bufio.NewReader(bytes.NewReader(f))
 		lexer := NewLexer(fn, buf)
 		token, err := lexer.Token()
 		require.NoError(t, err)
-		require.Equal(t, "let", token.tok)
+		require.Equal(t, "let", token.Tok)
 
 		token, err = lexer.Token()
 		require.NoError(t, err)
-		require.Equal(t, "var", token.tok)
+		require.Equal(t, "var", token.Tok)
 
 		token, err = lexer.Token()
 		require.NoError(t, err)
-		require.Equal(t, "ident", token.tok)
+		require.Equal(t, "ident", token.Tok)
 		require.Equal(t, "N", token.value)
 
 		token, err = lexer.Token()
 		require.NoError(t, err)
-		require.Equal(t, ":=", token.tok)
+		require.Equal(t, ":=", token.Tok)
 
 		token, err = lexer.Token()
 		require.NoError(t, err)
-		require.Equal(t, "int", token.tok)
+		require.Equal(t, "int", token.Tok)
 		require.EqualValues(t, 8, token.value)
 
 		token, err = lexer.Token()
 		require.NoError(t, err)
-		require.Equal(t, "in", token.tok)
+		require.Equal(t, "in", token.Tok)
 
 		token, err = lexer.Token()
 		require.NoError(t, err)
-		require.Equal(t, "ident", token.tok)
+		require.Equal(t, "ident", token.Tok)
 		require.Equal(t, "print", token.value)
 
 		token, err = lexer.Token()
 		require.NoError(t, err)
-		require.Equal(t, "(", token.tok)
+		require.Equal(t, "(", token.Tok)
 
 		token, err = lexer.Token()
 		require.Error(t, err)
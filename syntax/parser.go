@@ -0,0 +1,1870 @@
+package syntax
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+)
+
+type Parser struct {
+	lexer     *Lexer
+	lookahead *Token
+	strings   *Strings
+
+	// prevEnd is the end of the token before the lookahead, which is where the node being parsed ends
+	prevEnd Pos
+
+	// Diags collects the syntax errors that the parser recovers from, reported is the positions of those errors
+	Diags    *Diagnostics
+	reported map[Pos]bool
+
+	// closers counts the tokens that the enclosing sequences and lets wait for, see recover
+	closers map[string]int
+}
+
+func NewParser(lexer *Lexer, strs *Strings) *Parser {
+	return &Parser{
+		lexer:     lexer,
+		lookahead: nil,
+		strings:   strs,
+		Diags:     NewDiagnostics(),
+		reported:  make(map[Pos]bool),
+		closers:   make(map[string]int),
+	}
+}
+
+// syncToks are the tokens that the parser skips to after a syntax error. They end an expression or start a
+// declaration, so the parser can go on from them. A closing token is skipped too when no enclosing sequence or let
+// waits for it.
+var syncToks = map[string]bool{
+	";":         true,
+	")":         true,
+	"in":        true,
+	"end":       true,
+	"function":  true,
+	"type":      true,
+	"var":       true,
+	"class":     true,
+	"import":    true,
+	"primitive": true,
+	"eof":       true,
+}
+
+// recover reports the syntax error err and skips the tokens up to the next sync token. When the parser is still at
+// start, that token is skipped first so that the parser goes on. Errors that are not diagnostics, like those of
+// reading the source, cannot be recovered from and are returned.
+func (p *Parser) recover(err error, start *Token) error {
+	if err := p.report(err); err != nil {
+		return err
+	}
+
+	if p.lookahead == start {
+		if err := p.nextToken(); err != nil {
+			if err := p.report(err); err != nil {
+				return err
+			}
+		}
+	}
+
+	for !p.isSync(p.lookahead.Tok) {
+		if err := p.nextToken(); err != nil {
+			if err := p.report(err); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Parser) isSync(tok string) bool {
+	switch tok {
+	case ")", "in", "end":
+		return p.closers[tok] > 0
+	default:
+		return syncToks[tok]
+	}
+}
+
+// report adds the syntax error err to the diagnostics, unless an error has already been reported at its position: a
+// sync token that does not fit where the parser recovered gives another error there. The end of the file is not
+// reported after another error either, since the parser may have skipped to it.
+func (p *Parser) report(err error) error {
+	var diag *Diagnostic
+	if !errors.As(err, &diag) {
+		return err
+	}
+
+	if diag.Code == "E0011" && p.Diags.HasErrors() {
+		return nil
+	}
+
+	if diag.Span != nil {
+		if p.reported[diag.Span.Start] {
+			return nil
+		}
+
+		p.reported[diag.Span.Start] = true
+	}
+
+	p.Diags.Report(diag)
+	return nil
+}
+
+// unexpected is the error for the token the parser is at.
+func (p *Parser) unexpected() error {
+	if p.lookahead.IsEof() {
+		return unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	return unexpectedTokErr(p.lookahead.Pos)
+}
+
+func (p *Parser) peekNext() (*Token, error) {
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	return p.peekToken(), nil
+}
+
+func (p *Parser) peekToken() *Token {
+	return p.lookahead
+}
+
+func (p *Parser) nextToken() error {
+	// the lookahead stays the previous token after an error of the lexer, which is skipped
+	tok, err := p.lexer.Token()
+	if err != nil {
+		return err
+	}
+
+	if p.lookahead != nil {
+		p.prevEnd = p.lookahead.End
+	}
+
+	p.lookahead = tok
+	return nil
+}
+
+// spanFrom is the span of a node that starts at start and ends with the token before the lookahead. It is empty when
+// no token has been consumed since start, like for an expression that did not parse.
+func (p *Parser) spanFrom(start Pos) Span {
+	if p.prevEnd.Offset < start.Offset {
+		return pointSpan(start)
+	}
+
+	return Span{Start: start, End: p.prevEnd}
+}
+
+func (p *Parser) breakExp() (Exp, error) {
+	pos := p.peekToken().Pos
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	return &BreakExp{
+		pos:  pos,
+		span: p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) forExp() (Exp, error) {
+	pos := p.peekToken().Pos
+	// Pass "for"
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	tok := p.peekToken()
+	if tok.Tok != "ident" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	varName, ok := tok.value.(string)
+	if !ok {
+		panic("type of identity must be a string")
+	}
+
+	sym := p.strings.Symbol(varName)
+
+	tok, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != ":=" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	start, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	tok = p.peekToken()
+	if tok.Tok != "to" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	end, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	tok = p.peekToken()
+	if tok.Tok != "do" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	body, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ForExp{
+		Sym:  sym,
+		From: start,
+		To:   end,
+		Body: body,
+		Pos:  pos,
+		span: p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) ifExp() (Exp, error) {
+	pos := p.peekToken().Pos
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	test, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peekToken()
+	if tok.Tok != "then" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	then, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	var elseExp Exp
+	tok = p.peekToken()
+	if tok.Tok == "else" {
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		if p.lookahead.IsEof() {
+			return nil, unexpectedEofErr(p.lookahead.Pos)
+		}
+
+		elseExp, err = p.Exp()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &IfExp{
+		Predicate: test,
+		Then:      then,
+		Els:       elseExp,
+		pos:       pos,
+		span:      p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) funcArgs() ([]Exp, error) {
+	args := make([]Exp, 0)
+	for true {
+		tok := p.peekToken()
+		if tok.Tok == ")" {
+			break
+		}
+
+		arg, err := p.Exp()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+		tok = p.peekToken()
+		if tok.Tok != "," && tok.Tok != ")" {
+			return nil, unexpectedTokErr(tok.Pos)
+		}
+
+		if tok.Tok == "," {
+			if err := p.nextToken(); err != nil {
+				return nil, err
+			}
+
+			if p.lookahead.IsEof() {
+				return nil, unexpectedEofErr(p.lookahead.Pos)
+			}
+		}
+	}
+
+	return args, nil
+}
+
+func (p *Parser) oneField() (*RecordField, error) {
+	tok := p.peekToken()
+	name, ok := tok.value.(string)
+	if !ok {
+		// TODO: test 33
+		return nil, invalidRecordFieldErr(tok.Pos)
+	}
+
+	sym := p.strings.Symbol(name)
+	pos := tok.Pos
+
+	tok, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != "=" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	exp, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecordField{
+		Expr:  exp,
+		Ident: sym,
+		Pos:   pos,
+		span:  p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) createRecord(ty Symbol, pos Pos) (Exp, error) {
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	// a record of no fields
+	if p.lookahead.Tok == "}" {
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		return &RecordExp{Fields: []*RecordField{}, Ty: ty, Pos: pos, span: p.spanFrom(pos)}, nil
+	}
+
+	field, err := p.oneField()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []*RecordField{field}
+	for true {
+		tok := p.peekToken()
+		if tok.Tok != "," {
+			if tok.Tok == "}" {
+				if err := p.nextToken(); err != nil {
+					return nil, err
+				}
+
+				// the record can end the program, like the input of a REPL
+				break
+			} else {
+				return nil, unexpectedTokErr(tok.Pos)
+			}
+		}
+
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		if p.lookahead.IsEof() {
+			return nil, unexpectedEofErr(p.lookahead.Pos)
+		}
+
+		field, err := p.oneField()
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, field)
+	}
+
+	return &RecordExp{
+		Fields: fields,
+		Ty:     ty,
+		Pos:    pos,
+		span:   p.spanFrom(pos),
+	}, err
+}
+
+func (p *Parser) funcOrIdent() (Exp, error) {
+	tok := p.peekToken()
+	oriPos := tok.Pos
+	name := tok.value.(string)
+
+	sym := p.strings.Symbol(name)
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		v := &SimpleVar{
+			Symbol: sym,
+			Pos:    tok.Pos,
+			span:   tok.Span(),
+		}
+		return &VarExp{Var: v, span: v.span}, nil
+	}
+
+	tok = p.peekToken()
+	switch tok.Tok {
+	case "(":
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		if p.lookahead.IsEof() {
+			return nil, unexpectedEofErr(p.lookahead.Pos)
+		}
+
+		args, err := p.funcArgs()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.peekToken().Tok == ")" {
+			if err := p.nextToken(); err != nil {
+				return nil, err
+			}
+		}
+
+		return &CallExp{
+			Function: sym,
+			Args:     args,
+			Pos:      oriPos,
+			span:     p.spanFrom(oriPos),
+		}, nil
+	case "{":
+		return p.createRecord(sym, oriPos)
+	default:
+		return p.lvalueOrAssign(&SimpleVar{Symbol: sym, Pos: oriPos, span: p.spanFrom(oriPos)})
+	}
+}
+
+func (p *Parser) lvalueSubscript(v Var) (Var, error) {
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	exp, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peekToken()
+	if tok.Tok != "]" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return &SubscriptionVar{
+		Variable: v,
+		Exp:      exp,
+		pos:      exp.ExpPos(),
+		span:     p.spanFrom(v.Span().Start),
+	}, nil
+}
+
+func (p *Parser) lvalueField(v Var) (Var, error) {
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	tok := p.peekToken()
+	if tok.Tok != "ident" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return &FieldVar{
+		Variable: v,
+		Field:    p.strings.Symbol(tok.value.(string)),
+		Pos:      v.VarPos(),
+		span:     p.spanFrom(v.Span().Start),
+	}, nil
+}
+
+// lvalue parses the fields and subscripts that follow v. It stops at a field followed by "(", which is the method
+// of a method call.
+func (p *Parser) lvalue(v Var) (Var, error) {
+	for {
+		var err error
+		switch p.peekToken().Tok {
+		case "[":
+			v, err = p.lvalueSubscript(v)
+		case ".":
+			v, err = p.lvalueField(v)
+		default:
+			return v, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := v.(*FieldVar); ok && !p.lookahead.IsEof() && p.peekToken().Tok == "(" {
+			return v, nil
+		}
+	}
+}
+
+func (p *Parser) array(t Symbol, size Exp, pos Pos) (Exp, error) {
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	init, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArrExp{
+		Init: init,
+		Size: size,
+		Typ:  t,
+		pos:  pos,
+		span: p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) lvalueOrAssign(v Var) (Exp, error) {
+	v1, err := p.lvalue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return &VarExp{Var: v1, span: v1.Span()}, nil
+	}
+
+	tok := p.peekToken()
+	switch tok.Tok {
+	case "(":
+		v2, ok := v1.(*FieldVar)
+		if !ok {
+			return nil, unexpectedTokErr(tok.Pos)
+		}
+
+		return p.methodCall(v2)
+	case "of":
+		v2, ok := v1.(*SubscriptionVar)
+		if !ok {
+			return nil, unexpectedTokErr(tok.Pos)
+		}
+
+		v3, ok := v2.Variable.(*SimpleVar)
+		if !ok {
+			return nil, unexpectedTokErr(tok.Pos)
+		}
+
+		return p.array(v3.Symbol, v2.Exp, v.VarPos())
+	case ":=":
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		if p.lookahead.IsEof() {
+			return nil, unexpectedEofErr(p.lookahead.Pos)
+		}
+
+		exp, err := p.Exp()
+		if err != nil {
+			return nil, err
+		}
+
+		return &AssignExp{
+			Exp:      exp,
+			Variable: v1,
+			span:     p.spanFrom(v1.Span().Start),
+		}, nil
+	default:
+		return &VarExp{Var: v1, span: v1.Span()}, nil
+	}
+}
+
+func (p *Parser) methodCall(v *FieldVar) (Exp, error) {
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	args, err := p.funcArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return &MethodCallExp{
+		Variable: v.Variable,
+		Method:   v.Field,
+		Args:     args,
+		Pos:      v.Pos,
+		span:     p.spanFrom(v.Variable.Span().Start),
+	}, nil
+}
+
+func (p *Parser) newExp() (Exp, error) {
+	pos := p.peekToken().Pos
+	tok, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != "ident" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return &NewExp{
+		Ty:   p.strings.Symbol(tok.value.(string)),
+		Pos:  pos,
+		span: p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) intConst() (Exp, error) {
+	tok := p.peekToken()
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return &IntExp{
+		Val:  tok.value.(int32),
+		pos:  tok.Pos,
+		span: tok.Span(),
+	}, nil
+}
+
+func (p *Parser) optionalType() (Symbol, *Pos, error) {
+	tok := p.peekToken()
+	if tok.Tok != ":" {
+		return 0, nil, nil
+	}
+
+	tok, err := p.peekNext()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if tok.Tok != "ident" {
+		return 0, nil, unexpectedTokErr(tok.Pos)
+	}
+
+	return p.strings.Symbol(tok.value.(string)), &tok.Pos, p.nextToken()
+}
+
+func (p *Parser) fieldDecl() (*Field, error) {
+	tok := p.peekToken()
+	if tok.Tok != "ident" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	pos := tok.Pos
+
+	varSym := p.strings.Symbol(tok.value.(string))
+	tok, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != ":" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	tok, err = p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != "ident" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	typSym := p.strings.Symbol(tok.value.(string))
+	escape := true
+	return &Field{
+		Name:   varSym,
+		Escape: &escape,
+		Typ:    typSym,
+		Pos:    pos,
+		span:   Span{Start: pos, End: tok.End},
+	}, p.nextToken()
+}
+
+func (p *Parser) fields(endTok string) ([]*Field, error) {
+	fields := make([]*Field, 0)
+	for true {
+		tok := p.peekToken()
+		if tok.Tok == endTok {
+			return fields, nil
+		}
+
+		if tok.Tok == "," {
+			if err := p.nextToken(); err != nil {
+				return nil, err
+			}
+
+			if p.lookahead.IsEof() {
+				return nil, unexpectedEofErr(p.lookahead.Pos)
+			}
+			continue
+		}
+
+		field, err := p.fieldDecl()
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func (p *Parser) funcDecl() (*FuncDecl, error) {
+	tok := p.peekToken()
+	funcPos := tok.Pos
+
+	ident, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if ident.Tok != "ident" {
+		return nil, unexpectedTokErr(ident.Pos)
+	}
+
+	functionNameSym := p.strings.Symbol(ident.value.(string))
+
+	openCur, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if openCur.Tok != "(" {
+		return nil, unexpectedTokErr(openCur.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	params, err := p.fields(")")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	ty, _, err := p.optionalType()
+	if err != nil {
+		return nil, err
+	}
+
+	tok = p.peekToken()
+	if tok.Tok != "=" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	body, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	return &FuncDecl{
+		Name:        functionNameSym,
+		Params:      params,
+		ResultTy:    ty,
+		ResultTyPos: funcPos,
+		Body:        body,
+		Pos:         funcPos,
+		span:        p.spanFrom(funcPos),
+	}, nil
+}
+
+// primitiveDecl parses the declaration of a function of the runtime, which has no body.
+func (p *Parser) primitiveDecl() (Declaration, error) {
+	pos := p.peekToken().Pos
+	ident, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if ident.Tok != "ident" {
+		return nil, unexpectedTokErr(ident.Pos)
+	}
+
+	openParen, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if openParen.Tok != "(" {
+		return nil, unexpectedTokErr(openParen.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	params, err := p.fields(")")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	ty, _, err := p.optionalType()
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrimitiveDecl{
+		Name:        p.strings.Symbol(ident.value.(string)),
+		Params:      params,
+		ResultTy:    ty,
+		ResultTyPos: pos,
+		Pos:         pos,
+		span:        p.spanFrom(pos),
+	}, nil
+}
+
+//	func (p *Parser) funcDecls() (Declaration, error) {
+//		functions := make(FuncDecls, 0)
+//		for true {
+//			if tok := p.peekToken(); tok.tok != "funcDecl" {
+//				break
+//			}
+//
+//			fun, err := p.funcDecl()
+//			if err != nil {
+//				return nil, err
+//			}
+//
+//			functions = append(functions, fun)
+//		}
+//
+//		return functions, nil
+//	}
+func (p *Parser) arrayTy() (Ty, error) {
+	pos := p.peekToken().Pos
+	tok, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != "of" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	tok, err = p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != "ident" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	return &ArrayTy{
+		Ty:   p.strings.Symbol(tok.value.(string)),
+		pos:  pos,
+		span: Span{Start: pos, End: tok.End},
+	}, nil
+}
+
+func (p *Parser) recordTy() (Ty, error) {
+	pos := p.peekToken().Pos
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	fields, err := p.fields("}")
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecordTy{
+		Fields: fields,
+		pos:    pos,
+		span:   Span{Start: pos, End: p.peekToken().End},
+	}, nil
+}
+
+// classTy parses a class from its optional "extends" to its closing brace, the current token being the one before.
+func (p *Parser) classTy(pos Pos) (*ClassTy, error) {
+	tok, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	class := &ClassTy{
+		Super:    p.strings.Symbol("Object"),
+		SuperPos: pos,
+		pos:      pos,
+	}
+
+	if tok.Tok == "extends" {
+		tok, err = p.peekNext()
+		if err != nil {
+			return nil, err
+		}
+
+		if tok.Tok != "ident" {
+			return nil, unexpectedTokErr(tok.Pos)
+		}
+
+		class.Super = p.strings.Symbol(tok.value.(string))
+		class.SuperPos = tok.Pos
+		tok, err = p.peekNext()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tok.Tok != "{" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	for {
+		if p.lookahead.IsEof() {
+			return nil, unexpectedEofErr(p.lookahead.Pos)
+		}
+
+		tok = p.peekToken()
+		switch tok.Tok {
+		case "var":
+			attr, err := p.varDecl()
+			if err != nil {
+				return nil, err
+			}
+
+			class.Attrs = append(class.Attrs, attr.(*VarDecl))
+		case "method":
+			method, err := p.funcDecl()
+			if err != nil {
+				return nil, err
+			}
+
+			class.Methods = append(class.Methods, method)
+		case "}":
+			class.span = Span{Start: pos, End: tok.End}
+			return class, nil
+		default:
+			return nil, unexpectedTokErr(tok.Pos)
+		}
+	}
+}
+
+// classDecl parses "class C extends B { ... }", which declares the type C.
+func (p *Parser) classDecl() (Declaration, error) {
+	pos := p.peekToken().Pos
+	tok, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != "ident" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	class, err := p.classTy(pos)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return &TypeDecl{
+		TyName: p.strings.Symbol(tok.value.(string)),
+		Ty:     class,
+		Pos:    pos,
+		span:   p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) nameTy() (Ty, error) {
+	tyName := p.peekToken().value.(string)
+	pos := p.peekToken().Pos
+	tySym := p.strings.Symbol(tyName)
+	return &NameTy{
+		Ty:   tySym,
+		pos:  pos,
+		span: p.peekToken().Span(),
+	}, nil
+}
+
+func (p *Parser) tyDecl() (Declaration, error) {
+	pos := p.peekToken().Pos
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	tok := p.peekToken()
+	if tok.Tok != "ident" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	tyName := p.strings.Symbol(tok.value.(string))
+
+	tok, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != "=" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	tok, err = p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	var ty Ty
+
+	switch tok.Tok {
+	case "array":
+		ty, err = p.arrayTy()
+	case "{":
+		ty, err = p.recordTy()
+	case "ident":
+		ty, err = p.nameTy()
+	case "class":
+		ty, err = p.classTy(tok.Pos)
+	default:
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return &TypeDecl{
+		TyName: tyName,
+		Ty:     ty,
+		Pos:    pos,
+		span:   p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) varDecl() (Declaration, error) {
+	pos := p.peekToken().Pos
+	tok, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != "ident" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	varName := p.strings.Symbol(tok.value.(string))
+	ty, _, err := p.optionalType()
+	if err != nil {
+		return nil, err
+	}
+
+	tok = p.peekToken()
+	if tok.Tok != ":=" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	init, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	escape := true
+	return &VarDecl{
+		Name:   varName,
+		Escape: &escape,
+		Typ:    ty,
+		Init:   init,
+		Pos:    pos,
+		span:   p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) declarations() (Declaration, error) {
+	tok := p.peekToken()
+	switch tok.Tok {
+	case "function":
+		return p.funcDecl()
+	case "type":
+		return p.tyDecl()
+	case "var":
+		return p.varDecl()
+	case "class":
+		return p.classDecl()
+	case "import":
+		return p.importDecl()
+	case "primitive":
+		return p.primitiveDecl()
+	default:
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+}
+
+func (p *Parser) importDecl() (Declaration, error) {
+	pos := p.peekToken().Pos
+	tok, err := p.peekNext()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.Tok != "str" {
+		return nil, unexpectedTokErr(tok.Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return &ImportDecl{
+		Path: tok.value.(string),
+		Pos:  pos,
+		span: p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) letExp() (Exp, error) {
+	pos := p.peekToken().Pos
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	// a declaration that does not parse is replaced by an ErrorDecl, the following ones are still parsed
+	var decls []Declaration
+	p.closers["in"]++
+	for {
+		start := p.peekToken()
+		decl, err := p.declarations()
+		if err != nil {
+			if err := p.recover(err, nil); err != nil {
+				return nil, err
+			}
+
+			decl = &ErrorDecl{pos: start.Pos, span: p.spanFrom(start.Pos)}
+		}
+
+		decls = append(decls, decl)
+		if tok := p.peekToken().Tok; !isDeclKeyword(tok) && tok != "in" {
+			// the declaration ended before the unexpected token, it is likely missing part of its source
+			if err := p.recover(p.unexpected(), nil); err != nil {
+				return nil, err
+			}
+
+			decls[len(decls)-1] = &ErrorDecl{pos: start.Pos, span: p.spanFrom(start.Pos)}
+		}
+
+		if !isDeclKeyword(p.peekToken().Tok) {
+			break
+		}
+	}
+
+	p.closers["in"]--
+	if p.peekToken().Tok != "in" {
+		// without "in", the body is lost, up to the end of the let if it is there
+		if p.peekToken().Tok == "end" {
+			if err := p.nextToken(); err != nil {
+				return nil, err
+			}
+		}
+
+		return &LetExp{
+			Body:  &ErrorExp{pos: p.peekToken().Pos, span: pointSpan(p.prevEnd)},
+			Decls: decls,
+			pos:   pos,
+			span:  p.spanFrom(pos),
+		}, nil
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	firstExpPos := p.peekToken().Pos
+	exps, err := p.sequence("end")
+	if err != nil {
+		return nil, err
+	}
+
+	var seqExp *SequenceExp
+	if len(exps) == 1 {
+		if e, ok := exps[0].(*SequenceExp); ok {
+			seqExp = e
+		} else {
+			seqExp = &SequenceExp{
+				Exps: exps,
+				pos:  firstExpPos,
+				span: exps[0].Span(),
+			}
+		}
+	} else {
+		seqExp = &SequenceExp{
+			Exps: exps,
+			pos:  firstExpPos,
+			span: exps[0].Span().to(exps[len(exps)-1].Span()),
+		}
+	}
+
+	return &LetExp{
+		Body:  seqExp,
+		Decls: decls,
+		pos:   pos,
+		span:  p.spanFrom(pos),
+	}, nil
+}
+
+func isDeclKeyword(tok string) bool {
+	switch tok {
+	case "function", "type", "class", "import", "primitive", "var":
+		return true
+	default:
+		return false
+	}
+}
+
+// sequence parses expressions separated by ";" up to the token end, which it consumes. An expression that does not
+// parse is replaced by an ErrorExp and the parser goes on after the next ";". When end is missing, the parser stops at
+// the sync token it recovered to and the sequence ends with an ErrorExp, since its type is unknown.
+func (p *Parser) sequence(end string) ([]Exp, error) {
+	p.closers[end]++
+	defer func() { p.closers[end]-- }()
+
+	var exps []Exp
+	for {
+		pos := p.peekToken().Pos
+		exp, err := p.Exp()
+		if err != nil {
+			if err := p.recover(err, nil); err != nil {
+				return nil, err
+			}
+
+			exp = &ErrorExp{pos: pos, span: p.spanFrom(pos)}
+		}
+
+		exps = append(exps, exp)
+		tok := p.peekToken().Tok
+		if tok != ";" && tok != end {
+			if err := p.recover(p.unexpected(), nil); err != nil {
+				return nil, err
+			}
+
+			tok = p.peekToken().Tok
+		}
+
+		switch tok {
+		case ";":
+			if err := p.nextToken(); err != nil {
+				return nil, err
+			}
+		case end:
+			if err := p.nextToken(); err != nil {
+				return nil, err
+			}
+
+			return exps, nil
+		default:
+			return append(exps, &ErrorExp{pos: p.peekToken().Pos, span: pointSpan(p.prevEnd)}), nil
+		}
+	}
+}
+
+func (p *Parser) nilExp() (Exp, error) {
+	pos := p.peekToken().Pos
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return &NilExp{pos: pos, span: p.spanFrom(pos)}, nil
+}
+
+func (p *Parser) seqExp() (Exp, error) {
+	pos := p.peekToken().Pos
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+
+	if p.peekToken().Tok == ")" {
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		return &UnitExp{pos: p.peekToken().Pos, span: p.spanFrom(pos)}, nil
+	}
+
+	seqExp, err := p.sequence(")")
+	if err != nil {
+		return nil, err
+	}
+
+	return &SequenceExp{
+		Exps: seqExp,
+		pos:  pos,
+		span: p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) strExp() (Exp, error) {
+	tok := p.peekToken()
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return &StrExp{
+		Str:  tok.value.(string),
+		pos:  tok.Pos,
+		span: tok.Span(),
+	}, nil
+}
+
+func (p *Parser) whileExp() (Exp, error) {
+	pos := p.peekToken().Pos
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+	test, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peekToken().Tok != "do" {
+		return nil, unexpectedTokErr(p.peekToken().Pos)
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+	body, err := p.Exp()
+	if err != nil {
+		return nil, err
+	}
+
+	return &WhileExp{
+		Pred: test,
+		Body: body,
+		pos:  pos,
+		span: p.spanFrom(pos),
+	}, nil
+}
+
+func (p *Parser) primaryExp() (Exp, error) {
+	tok := p.peekToken()
+	switch tok.Tok {
+	case "break":
+		return p.breakExp()
+	case "for":
+		return p.forExp()
+	case "if":
+		return p.ifExp()
+	case "ident":
+		return p.funcOrIdent()
+	case "int":
+		return p.intConst()
+	case "let":
+		return p.letExp()
+	case "new":
+		return p.newExp()
+	case "nil":
+		return p.nilExp()
+	case "(":
+		return p.seqExp()
+	case "str":
+		return p.strExp()
+	case "while":
+		return p.whileExp()
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+func (p *Parser) unaryExp() (Exp, error) {
+	tok := p.peekToken()
+	if tok.Tok == "-" {
+		pos := tok.Pos
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		if p.lookahead.IsEof() {
+			return nil, unexpectedEofErr(p.lookahead.Pos)
+		}
+
+		exp, err := p.unaryExp()
+		if err != nil {
+			return nil, err
+		}
+
+		return &OperExp{
+			Left: &IntExp{
+				Val:  0,
+				pos:  pos,
+				span: pointSpan(pos),
+			},
+			Op:    Minus,
+			Right: exp,
+			span:  p.spanFrom(pos),
+		}, nil
+	}
+
+	return p.primaryExp()
+}
+
+func (p *Parser) mulExp() (Exp, error) {
+	exp, err := p.unaryExp()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peekToken()
+		if op.Tok != "*" && op.Tok != "/" {
+			break
+		}
+
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		if p.lookahead.IsEof() {
+			return nil, unexpectedEofErr(p.lookahead.Pos)
+		}
+
+		nextExp, err := p.unaryExp()
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Tok == "*" {
+			exp = &OperExp{
+				Left:  exp,
+				Op:    Mul,
+				Right: nextExp,
+				span:  p.spanFrom(exp.Span().Start),
+			}
+		} else {
+			exp = &OperExp{
+				Left:  exp,
+				Op:    Div,
+				Right: nextExp,
+				span:  p.spanFrom(exp.Span().Start),
+			}
+		}
+	}
+
+	return exp, nil
+}
+
+func (p *Parser) addExp() (Exp, error) {
+	// Can be in the format 2+3-4
+	exp, err := p.mulExp()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peekToken()
+		if op.Tok != "+" && op.Tok != "-" {
+			break
+		}
+
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		if p.lookahead.IsEof() {
+			return nil, unexpectedEofErr(p.lookahead.Pos)
+		}
+		nextExp, err := p.mulExp()
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Tok == "+" {
+			exp = &OperExp{
+				Left:  exp,
+				Op:    Plus,
+				Right: nextExp,
+				span:  p.spanFrom(exp.Span().Start),
+			}
+		} else {
+			exp = &OperExp{
+				Left:  exp,
+				Op:    Minus,
+				Right: nextExp,
+				span:  p.spanFrom(exp.Span().Start),
+			}
+		}
+	}
+
+	return exp, nil
+}
+
+func (p *Parser) relationalExp() (Exp, error) {
+	left, err := p.addExp()
+	if err != nil {
+		return nil, err
+	}
+
+	nextToken := p.peekToken()
+	if nextToken.Tok != "=" && nextToken.Tok != ">" && nextToken.Tok != "<" && nextToken.Tok != ">=" && nextToken.Tok != "<=" && nextToken.Tok != "!=" {
+		return left, nil
+	}
+
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	if p.lookahead.IsEof() {
+		return nil, unexpectedEofErr(p.lookahead.Pos)
+	}
+	right, err := p.addExp()
+	if err != nil {
+		return nil, err
+	}
+
+	var op Operator
+	switch nextToken.Tok {
+	case "=":
+		op = Eq
+	case ">":
+		op = Gt
+	case ">=":
+		op = Ge
+	case "<":
+		op = Lt
+	case "<=":
+		op = Le
+	case "!=":
+		op = Neq
+	}
+	return &OperExp{
+		Left:  left,
+		Op:    op,
+		Right: right,
+		span:  p.spanFrom(left.Span().Start),
+	}, nil
+}
+
+func (p *Parser) andExp() (Exp, error) {
+	// Can be in the format a&b&c
+	exp, err := p.relationalExp()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekToken().Tok == "&" {
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		if p.lookahead.IsEof() {
+			return nil, unexpectedEofErr(p.lookahead.Pos)
+		}
+
+		right, err := p.relationalExp()
+		if err != nil {
+			return nil, err
+		}
+
+		exp = &OperExp{
+			Left:  exp,
+			Op:    And,
+			Right: right,
+			span:  p.spanFrom(exp.Span().Start),
+		}
+	}
+
+	return exp, nil
+}
+
+func (p *Parser) orExp() (Exp, error) {
+	// Can be in the format a|b|c
+	exp, err := p.andExp()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekToken().Tok == "|" {
+		if err := p.nextToken(); err != nil {
+			return nil, err
+		}
+
+		if p.lookahead.IsEof() {
+			return nil, unexpectedEofErr(p.lookahead.Pos)
+		}
+
+		right, err := p.andExp()
+		if err != nil {
+			return nil, err
+		}
+
+		exp = &OperExp{
+			Left:  exp,
+			Op:    Or,
+			Right: right,
+			span:  p.spanFrom(exp.Span().Start),
+		}
+	}
+
+	return exp, nil
+}
+
+// Parse parses a program. After a syntax error, the parser goes on with the rest of the program: it returns the
+// partial AST, where ErrorExp and ErrorDecl stand for what did not parse, along with the diagnostics.
+func (p *Parser) Parse() (Exp, error) {
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	exp, err := p.Exp()
+	if err != nil {
+		pos := p.peekToken().Pos
+		if err := p.recover(err, nil); err != nil {
+			return nil, err
+		}
+
+		exp = &ErrorExp{pos: pos, span: p.spanFrom(pos)}
+	}
+
+	if !p.lookahead.IsEof() {
+		if err := p.report(unexpectedTokErr(p.lookahead.Pos)); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Diags.HasErrors() {
+		return exp, p.Diags
+	}
+
+	return exp, nil
+}
+
+// IsModule tells whether src is a module rather than a program, which cannot start with a declaration.
+func IsModule(file string, src []byte) bool {
+	tok, err := NewLexer(file, bufio.NewReader(bytes.NewReader(src))).Token()
+	if err != nil {
+		return false
+	}
+
+	switch tok.Tok {
+	case "function", "type", "var", "class", "import", "primitive":
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseModule parses a module, which is a list of declarations up to the end of the file. Like Parse, it returns the
+// declarations that parse along with the diagnostics.
+func (p *Parser) ParseModule() ([]Declaration, error) {
+	if err := p.nextToken(); err != nil {
+		return nil, err
+	}
+
+	var decls []Declaration
+	for !p.lookahead.IsEof() {
+		start := p.peekToken()
+		decl, err := p.declarations()
+		if err != nil {
+			if err := p.recover(err, start); err != nil {
+				return nil, err
+			}
+
+			decl = &ErrorDecl{pos: start.Pos, span: p.spanFrom(start.Pos)}
+		}
+
+		decls = append(decls, decl)
+	}
+
+	if p.Diags.HasErrors() {
+		return decls, p.Diags
+	}
+
+	return decls, nil
+}
+
+func (p *Parser) Exp() (Exp, error) {
+	return p.orExp()
+}
@@ -0,0 +1,155 @@
+package syntax
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the JSON of the ASTs in testdata with what the parser builds")
+
+func diagnosticCodes(t *testing.T, err error) []string {
+	var diags *Diagnostics
+	require.True(t, errors.As(err, &diags), "%v", err)
+
+	codes := make([]string, 0, len(diags.List()))
+	for _, diag := range diags.List() {
+		codes = append(codes, diag.Code)
+	}
+
+	return codes
+}
+
+func TestParser_Hello2(t *testing.T) {
+	t.Parallel()
+
+	testFile(t, "../test_files/hello2.tig")
+}
+
+func TestParser_Integers(t *testing.T) {
+	t.Parallel()
+
+	testFile(t, "../test_files/integers.tig")
+}
+
+func TestParser_Loops(t *testing.T) {
+	t.Parallel()
+
+	testFile(t, "../test_files/loops.tig")
+}
+
+func TestParser_Cycle(t *testing.T) {
+	t.Parallel()
+
+	testFile(t, "../test_files/cycle.tig")
+}
+
+func TestParser_Functions(t *testing.T) {
+	t.Parallel()
+
+	testFile(t, "../test_files/functions.tig")
+}
+
+// TestParser_EmptyRecord parses the creation of a record with no fields, rectype {}.
+func TestParser_EmptyRecord(t *testing.T) {
+	t.Parallel()
+
+	testFile(t, "../test_files/test33.tig")
+}
+
+func testFile(t *testing.T, fileName string) {
+	f, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	buf := bufio.NewReader(bytes.NewReader(f))
+	lexer := NewLexer(fileName, buf)
+
+	strs := NewStrings()
+	parser := NewParser(lexer, strs)
+	exp, err := parser.Parse()
+	require.NoError(t, err)
+
+	// the JSON of the tree is the one checked in testdata, and it reads back as the tree the parser built
+	out := bytes.Buffer{}
+	require.NoError(t, WriteJsonProgram(&out, strs, exp))
+	golden := filepath.Join("testdata", strings.TrimSuffix(filepath.Base(fileName), ".tig")+".json")
+	if *update {
+		require.NoError(t, os.WriteFile(golden, out.Bytes(), 0644))
+	}
+
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), out.String())
+
+	decoded, err := ReadJsonProgram(&out, strs)
+	require.NoError(t, err)
+	require.Equal(t, exp, decoded)
+}
+
+func TestParser_Recovery(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		src   string
+		codes []string
+		nodes []string
+	}{
+		{`let var a := 1 + function f(n: int): int = n in f(a); f(2 3); (1; +; 2) end`,
+			[]string{"E0010", "E0010", "E0010"}, []string{"ErrorDecl", "FuncDecl", "ErrorExp"}},
+		{`let var x := 1 ) var y := 2 in x + y end`, []string{"E0010"}, []string{"ErrorDecl", "VarDecl"}},
+		{`let in 1 end`, []string{"E0010"}, []string{"ErrorDecl"}},
+		{`(1; 2`, []string{"E0011"}, []string{"ErrorExp"}},
+		{`let var s := "abc in s end`, []string{"E0002"}, nil},
+		{`let var x := 1 $ in x end`, []string{"E0005"}, []string{"ErrorDecl"}},
+		{`print("abc\q")`, []string{"E0003"}, nil},
+		{`print("\999 \" \q")`, []string{"E0004"}, nil},
+		{`let var x := 1 in x of 7; x.y[1] of 7 end`, []string{"E0010", "E0010"}, []string{"ErrorExp"}},
+	} {
+		strs := NewStrings()
+		parser := NewParser(NewLexer("test.tig", bufio.NewReader(strings.NewReader(tc.src))), strs)
+		exp, err := parser.Parse()
+		require.Equal(t, tc.codes, diagnosticCodes(t, err), tc.src)
+		require.NotNil(t, exp, tc.src)
+
+		strBuilder := strings.Builder{}
+		exp.String(strs, &strBuilder, 0)
+		for _, node := range tc.nodes {
+			require.Contains(t, strBuilder.String(), node, tc.src)
+		}
+	}
+}
+
+func TestParser_Spans(t *testing.T) {
+	t.Parallel()
+
+	src := "let\n  var p := point{x = 1}\nin\n  f(p.x + 2, a[i]) = -1;\n  p.x := 3\nend"
+	parser := NewParser(NewLexer("test.tig", bufio.NewReader(strings.NewReader(src))), NewStrings())
+	exp, err := parser.Parse()
+	require.NoError(t, err)
+
+	text := func(span Span) string {
+		return src[span.Start.Offset:span.End.Offset]
+	}
+
+	let := exp.(*LetExp)
+	require.Equal(t, src, text(let.Span()))
+	require.Equal(t, "var p := point{x = 1}", text(let.Decls[0].Span()))
+	require.Equal(t, "f(p.x + 2, a[i]) = -1;\n  p.x := 3", text(let.Body.Span()))
+
+	cmp := let.Body.(*SequenceExp).Exps[0].(*OperExp)
+	require.Equal(t, "f(p.x + 2, a[i]) = -1", text(cmp.Span()))
+	require.Equal(t, "-1", text(cmp.Right.Span()))
+
+	call := cmp.Left.(*CallExp)
+	require.Equal(t, "p.x + 2", text(call.Args[0].Span()))
+	require.Equal(t, "a[i]", text(call.Args[1].Span()))
+
+	assign := let.Body.(*SequenceExp).Exps[1].(*AssignExp)
+	require.Equal(t, "p.x := 3", text(assign.Span()))
+	require.Equal(t, Pos{FileName: "test.tig", Line: 5, Col: 11, Offset: len(src) - 4}, assign.Span().End)
+}
@@ -0,0 +1,41 @@
+package syntax
+
+import "fmt"
+
+// Pos is a position in a source file. The offset counts the bytes before the position, line and col count from 1.
+type Pos struct {
+	FileName string
+	Line     int
+	Col      int
+	Offset   int
+}
+
+func (p *Pos) String() string {
+	return fmt.Sprintf("file: %s, line: %d, col: %d", p.FileName, p.Line, p.Col)
+}
+
+// Span is a part of a source file, from the position of its first character to the position that follows its last
+// one, so that src[start.offset:end.offset] is its text. A span whose start is its end is empty, it points between
+// two characters.
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+func pointSpan(pos Pos) Span {
+	return Span{Start: pos, End: pos}
+}
+
+// to is the span from the start of s to the end of other.
+func (s Span) to(other Span) Span {
+	return Span{Start: s.Start, End: other.End}
+}
+
+func (s Span) IsEmpty() bool {
+	return s.Start.Offset == s.End.Offset
+}
+
+// String prints the span as file:line:col, the way compilers and editors point at the source.
+func (s Span) String() string {
+	return fmt.Sprintf("%s:%d:%d", s.Start.FileName, s.Start.Line, s.Start.Col)
+}
@@ -0,0 +1,248 @@
+package syntax
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ANSI escapes of the renderer
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiBlue   = "\x1b[1;34m"
+	ansiCyan   = "\x1b[1;36m"
+)
+
+// maxSpanLines is the number of lines of a span that the renderer prints, the lines in the middle of a longer span
+// are elided.
+const maxSpanLines = 4
+
+// Renderer prints diagnostics for a terminal: the message, then the lines of the source under the span, with carets
+// under the span and dashes under the spans of the notes, which are labeled with their messages.
+type Renderer struct {
+	out     io.Writer
+	color   bool
+	sources map[string][]string
+}
+
+func NewRenderer(out io.Writer, color bool) *Renderer {
+	return &Renderer{
+		out:     out,
+		color:   color,
+		sources: make(map[string][]string),
+	}
+}
+
+// AddSource gives the source of a file, so that it is not read again. The sources of the other files, like the
+// modules a program imports, are read when a diagnostic is about them.
+func (r *Renderer) AddSource(file string, src []byte) {
+	r.sources[file] = strings.Split(string(src), "\n")
+}
+
+// lines returns the lines of the file, nil when it cannot be read.
+func (r *Renderer) lines(file string) []string {
+	if lines, ok := r.sources[file]; ok {
+		return lines
+	}
+
+	r.sources[file] = nil
+	if src, err := os.ReadFile(file); err == nil {
+		r.AddSource(file, src)
+	}
+
+	return r.sources[file]
+}
+
+// renderLabel is a span to underline, the span of a diagnostic or of one of its notes.
+type renderLabel struct {
+	span    Span
+	msg     string
+	primary bool
+}
+
+func (r *Renderer) paint(s, escape string) string {
+	if !r.color {
+		return s
+	}
+
+	return escape + s + ansiReset
+}
+
+func (r *Renderer) severityColor(s Severity) string {
+	switch s {
+	case SeverityError:
+		return ansiRed
+	case SeverityWarning:
+		return ansiYellow
+	default:
+		return ansiCyan
+	}
+}
+
+// Render prints the diagnostic followed by an empty line.
+func (r *Renderer) Render(diag *Diagnostic) {
+	color := r.severityColor(diag.Severity)
+	fmt.Fprintf(r.out, "%s%s\n", r.paint(fmt.Sprintf("%s[%s]", diag.Severity, diag.Code), color),
+		r.paint(": "+diag.Msg, ansiBold))
+
+	// the labels are grouped by file, the file of the diagnostic first
+	var files []string
+	labels := make(map[string][]renderLabel)
+	var notes []string
+	add := func(label renderLabel) {
+		file := label.span.Start.FileName
+		if _, ok := labels[file]; !ok {
+			files = append(files, file)
+		}
+
+		labels[file] = append(labels[file], label)
+	}
+
+	if diag.Span != nil {
+		add(renderLabel{span: *diag.Span, primary: true})
+	}
+
+	for _, note := range diag.Notes {
+		if note.Span == nil {
+			notes = append(notes, note.Msg)
+			continue
+		}
+
+		add(renderLabel{span: *note.Span, msg: note.Msg})
+	}
+
+	width := 1
+	for _, file := range files {
+		for _, label := range labels[file] {
+			if w := len(strconv.Itoa(label.span.End.Line)); w > width {
+				width = w
+			}
+		}
+	}
+
+	gutter := strings.Repeat(" ", width)
+	for i, file := range files {
+		arrow := "-->"
+		if i > 0 {
+			arrow = ":::"
+		}
+
+		fmt.Fprintf(r.out, "%s%s %s\n", gutter, r.paint(arrow, ansiBlue), labels[file][0].span)
+		r.snippet(file, labels[file], width, color)
+	}
+
+	for _, note := range notes {
+		fmt.Fprintf(r.out, "%s %s note: %s\n", gutter, r.paint("=", ansiBlue), note)
+	}
+
+	fmt.Fprintln(r.out)
+}
+
+// snippet prints the lines of the file that the labels are on, each followed by the underlines of the labels.
+func (r *Renderer) snippet(file string, labels []renderLabel, width int, color string) {
+	src := r.lines(file)
+	if src == nil {
+		return
+	}
+
+	shown := make(map[int]bool)
+	for _, label := range labels {
+		start, end := label.span.Start.Line, label.span.End.Line
+		for line := start; line <= end; line++ {
+			if line-start < maxSpanLines-1 || line == end {
+				shown[line] = true
+			}
+		}
+	}
+
+	lines := make([]int, 0, len(shown))
+	for line := range shown {
+		if line >= 1 && line <= len(src) {
+			lines = append(lines, line)
+		}
+	}
+
+	// the marks of the diagnostic come before those of its notes on the same line
+	sort.Ints(lines)
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].primary && !labels[j].primary
+	})
+
+	bar := r.paint("|", ansiBlue)
+	fmt.Fprintf(r.out, "%s %s\n", strings.Repeat(" ", width), bar)
+	for i, line := range lines {
+		if i > 0 && line > lines[i-1]+1 {
+			fmt.Fprintln(r.out, r.paint("...", ansiBlue))
+		}
+
+		text := strings.TrimRight(src[line-1], "\r")
+		fmt.Fprintf(r.out, "%s %s %s\n", r.paint(fmt.Sprintf("%*d", width, line), ansiBlue), bar, text)
+		for _, label := range labels {
+			if line < label.span.Start.Line || line > label.span.End.Line {
+				continue
+			}
+
+			from, to := underline(label.span, line, text)
+			mark, markColor := "-", ansiBlue
+			if label.primary {
+				mark, markColor = "^", color
+			}
+
+			marks := strings.Repeat(mark, to-from)
+			if line == label.span.End.Line && label.msg != "" {
+				marks += " " + label.msg
+			}
+
+			fmt.Fprintf(r.out, "%s %s %s%s\n", strings.Repeat(" ", width), bar, padding(text, from),
+				r.paint(marks, markColor))
+		}
+	}
+}
+
+// underline returns the columns, from 0, that the span covers on the line of text. The lines inside a span are
+// underlined from their first character that is not a space. An empty span is the position of a token, like the
+// name of an undefined variable, so it gets the word there or a single mark.
+func underline(span Span, line int, text string) (int, int) {
+	from := len(text) - len(strings.TrimLeft(text, " \t"))
+	if line == span.Start.Line {
+		from = span.Start.Col - 1
+	}
+
+	to := len(text)
+	if line == span.End.Line {
+		to = span.End.Col - 1
+	}
+
+	if from > len(text) {
+		from = len(text)
+	}
+
+	if to <= from {
+		to = from + 1
+		for to < len(text) && IsIdentChar(text[from]) && IsIdentChar(text[to]) {
+			to++
+		}
+	}
+
+	return from, to
+}
+
+// padding is the blank before the column col of text, which keeps its tabs so that the marks line up with it.
+func padding(text string, col int) string {
+	sb := strings.Builder{}
+	for i := 0; i < col; i++ {
+		if i < len(text) && text[i] == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,33 @@
+package syntax
+
+import "strings"
+
+type Symbol = uint64
+
+type Strings struct {
+	nextSymbol Symbol
+	strings    map[Symbol]string
+}
+
+func NewStrings() *Strings {
+	return &Strings{
+		strings: make(map[Symbol]string),
+	}
+}
+
+func (s *Strings) Get(sym Symbol) string {
+	return s.strings[sym]
+}
+
+func (s *Strings) Symbol(str string) Symbol {
+	// TODO: is this a right way to handle or we need to create new symbol every time
+	for v, s := range s.strings {
+		if strings.EqualFold(s, str) {
+			return v
+		}
+	}
+
+	s.nextSymbol++
+	s.strings[s.nextSymbol] = str
+	return s.nextSymbol
+}
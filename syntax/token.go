@@ -1,15 +1,21 @@
-package main
+package syntax
 
 import "fmt"
 
+// Token is a token of the source, from pos up to end.
 type Token struct {
-	pos   Pos
-	tok   string
+	Pos   Pos
+	End   Pos
+	Tok   string
 	value interface{}
 }
 
+func (t *Token) Span() Span {
+	return Span{Start: t.Pos, End: t.End}
+}
+
 func (t *Token) IsEof() bool {
-	return t.tok == "eof"
+	return t.Tok == "eof"
 }
 
 func (t *Token) String() string {
@@ -22,333 +28,355 @@ func (t *Token) String() string {
 		}
 	}
 
-	return t.tok
+	return t.Tok
 }
 
 func NewIdent(s string, pos Pos) *Token {
 	return &Token{
-		tok:   "ident",
+		Tok:   "ident",
 		value: s,
-		pos:   pos,
+		Pos:   pos,
 	}
 }
 
 func NewStr(s string, pos Pos) *Token {
 	return &Token{
-		tok:   "str",
+		Tok:   "str",
 		value: s,
-		pos: pos,
+		Pos:   pos,
 	}
 }
 
 func NewInt(i int32, pos Pos) *Token {
 	return &Token{
-		tok:   "int",
+		Tok:   "int",
 		value: i,
-		pos:   pos,
+		Pos:   pos,
 	}
 }
 
 func NewAnd(pos Pos) *Token {
 	return &Token{
-		tok: "&",
-		pos: pos,
+		Tok: "&",
+		Pos: pos,
 	}
 }
 func NewAssign(pos Pos) *Token {
 	return &Token{
-		tok: ":=",
-		pos: pos,
+		Tok: ":=",
+		Pos: pos,
 	}
 }
 
 func NewArray(pos Pos) *Token {
 	return &Token{
-		tok: "array",
-		pos: pos,
+		Tok: "array",
+		Pos: pos,
 	}
 }
 
 func NewBreak(pos Pos) *Token {
 	return &Token{
-		tok: "break",
-		pos: pos,
+		Tok: "break",
+		Pos: pos,
 	}
 }
 
 func NewClass(pos Pos) *Token {
 	return &Token{
-		tok: "class",
-		pos: pos,
+		Tok: "class",
+		Pos: pos,
 	}
 }
 
 func NewCloseCurly(pos Pos) *Token {
 	return &Token{
-		tok: "}",
-		pos: pos,
+		Tok: "}",
+		Pos: pos,
 	}
 }
 
 func NewCloseParen(pos Pos) *Token {
 	return &Token{
-		tok: ")",
-		pos: pos,
+		Tok: ")",
+		Pos: pos,
 	}
 }
 
 func NewCloseBrac(pos Pos) *Token {
 	return &Token{
-		tok: "]",
-		pos: pos,
+		Tok: "]",
+		Pos: pos,
 	}
 }
 
 func NewColon(pos Pos) *Token {
 	return &Token{
-		tok: ":",
-		pos: pos,
+		Tok: ":",
+		Pos: pos,
 	}
 }
 
 func NewComma(pos Pos) *Token {
 	return &Token{
-		tok: ",",
-		pos: pos,
+		Tok: ",",
+		Pos: pos,
 	}
 }
 func NewDo(pos Pos) *Token {
 	return &Token{
-		tok: "do",
-		pos: pos,
+		Tok: "do",
+		Pos: pos,
 	}
 }
 
 func NewDot(pos Pos) *Token {
 	return &Token{
-		tok: ".",
-		pos: pos,
+		Tok: ".",
+		Pos: pos,
 	}
 }
 
 func NewElse(pos Pos) *Token {
 	return &Token{
-		tok: "else",
-		pos: pos,
+		Tok: "else",
+		Pos: pos,
 	}
 }
 
 func NewEnd(pos Pos) *Token {
 	return &Token{
-		tok: "end",
-		pos: pos,
+		Tok: "end",
+		Pos: pos,
 	}
 }
 
 func NewEndOfFile(pos Pos) *Token {
 	return &Token{
-		tok: "eof",
-		pos: pos,
+		Tok: "eof",
+		Pos: pos,
 	}
 }
 
 func NewEqual(pos Pos) *Token {
 	return &Token{
-		tok: "=",
-		pos: pos,
+		Tok: "=",
+		Pos: pos,
 	}
 }
 
 func NewExtends(pos Pos) *Token {
 	return &Token{
-		tok: "extends",
-		pos: pos,
+		Tok: "extends",
+		Pos: pos,
 	}
 }
 
 func NewFor(pos Pos) *Token {
 	return &Token{
-		tok: "for",
-		pos: pos,
+		Tok: "for",
+		Pos: pos,
 	}
 }
 
 func NewFunction(pos Pos) *Token {
 	return &Token{
-		tok: "function",
-		pos: pos,
+		Tok: "function",
+		Pos: pos,
 	}
 }
 
 func NewGreater(pos Pos) *Token {
 	return &Token{
-		tok: ">",
-		pos: pos,
+		Tok: ">",
+		Pos: pos,
 	}
 }
 
 func NewGreaterOrEqual(pos Pos) *Token {
 	return &Token{
-		tok: ">=",
-		pos: pos,
+		Tok: ">=",
+		Pos: pos,
 	}
 }
 
 func NewIf(pos Pos) *Token {
 	return &Token{
-		tok: "if",
-		pos: pos,
+		Tok: "if",
+		Pos: pos,
+	}
+}
+
+func NewImport(pos Pos) *Token {
+	return &Token{
+		Tok: "import",
+		Pos: pos,
 	}
 }
+
 func NewIn(pos Pos) *Token {
 	return &Token{
-		tok: "in",
-		pos: pos,
+		Tok: "in",
+		Pos: pos,
 	}
 }
 
 func NewLesser(pos Pos) *Token {
 	return &Token{
-		tok: "<",
-		pos: pos,
+		Tok: "<",
+		Pos: pos,
 	}
 }
 
 func NewLesserOrEqual(pos Pos) *Token {
 	return &Token{
-		tok: "<=",
-		pos: pos,
+		Tok: "<=",
+		Pos: pos,
 	}
 }
 func NewLet(pos Pos) *Token {
 	return &Token{
-		tok: "let",
-		pos: pos,
+		Tok: "let",
+		Pos: pos,
 	}
 }
 
 func NewMethod(pos Pos) *Token {
 	return &Token{
-		tok: "method",
-		pos: pos,
+		Tok: "method",
+		Pos: pos,
 	}
 }
 
 func NewMinus(pos Pos) *Token {
 	return &Token{
-		tok: "-",
-		pos: pos,
+		Tok: "-",
+		Pos: pos,
+	}
+}
+
+func NewNew(pos Pos) *Token {
+	return &Token{
+		Tok: "new",
+		Pos: pos,
 	}
 }
 
 func NewNil(pos Pos) *Token {
 	return &Token{
-		tok: "nil",
-		pos: pos,
+		Tok: "nil",
+		Pos: pos,
 	}
 }
 
 func NewNotEqual(pos Pos) *Token {
 	return &Token{
-		tok: "!=",
-		pos: pos,
+		Tok: "!=",
+		Pos: pos,
+	}
+}
+
+func NewPrimitive(pos Pos) *Token {
+	return &Token{
+		Tok: "primitive",
+		Pos: pos,
 	}
 }
 
 func NewOf(pos Pos) *Token {
 	return &Token{
-		tok: "of",
-		pos: pos,
+		Tok: "of",
+		Pos: pos,
 	}
 }
 
 func NewOpenCurly(pos Pos) *Token {
 	return &Token{
-		tok: "{",
-		pos: pos,
+		Tok: "{",
+		Pos: pos,
 	}
 }
 
 func NewOpenParen(pos Pos) *Token {
 	return &Token{
-		tok: "(",
-		pos: pos,
+		Tok: "(",
+		Pos: pos,
 	}
 }
 
 func NewOpenBrac(pos Pos) *Token {
 	return &Token{
-		tok: "[",
-		pos: pos,
+		Tok: "[",
+		Pos: pos,
 	}
 }
 
 func NewOr(pos Pos) *Token {
 	return &Token{
-		tok: "|",
-		pos: pos,
+		Tok: "|",
+		Pos: pos,
 	}
 }
 
 func NewPlus(pos Pos) *Token {
 	return &Token{
-		tok: "+",
-		pos: pos,
+		Tok: "+",
+		Pos: pos,
 	}
 }
 
 func NewSemicolon(pos Pos) *Token {
 	return &Token{
-		tok: ";",
-		pos: pos,
+		Tok: ";",
+		Pos: pos,
 	}
 }
 
 func NewDiv(pos Pos) *Token {
 	return &Token{
-		tok: "/",
-		pos: pos,
+		Tok: "/",
+		Pos: pos,
 	}
 }
 
 func NewTimes(pos Pos) *Token {
 	return &Token{
-		tok: "*",
-		pos: pos,
+		Tok: "*",
+		Pos: pos,
 	}
 }
 
 func NewThen(pos Pos) *Token {
 	return &Token{
-		tok: "then",
-		pos: pos,
+		Tok: "then",
+		Pos: pos,
 	}
 }
 
 func NewTo(pos Pos) *Token {
 	return &Token{
-		tok: "to",
-		pos: pos,
+		Tok: "to",
+		Pos: pos,
 	}
 }
 
 func NewType(pos Pos) *Token {
 	return &Token{
-		tok: "type",
-		pos: pos,
+		Tok: "type",
+		Pos: pos,
 	}
 }
 
 func NewVar(pos Pos) *Token {
 	return &Token{
-		tok: "var",
-		pos: pos,
+		Tok: "var",
+		Pos: pos,
 	}
 }
 
 func NewWhile(pos Pos) *Token {
 	return &Token{
-		tok: "while",
-		pos: pos,
+		Tok: "while",
+		Pos: pos,
 	}
 }
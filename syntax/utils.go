@@ -0,0 +1,29 @@
+package syntax
+
+func IsLower(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func IsUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+func IsUnderscore(c byte) bool {
+	return c == '_'
+}
+
+func IsNumeric(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func IsAlphaNumeric(c byte) bool {
+	return IsLower(c) || IsUpper(c) || IsNumeric(c)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func IsIdentChar(c byte) bool {
+	return IsAlphaNumeric(c) || IsUnderscore(c)
+}
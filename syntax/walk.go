@@ -0,0 +1,156 @@
+package syntax
+
+// The passes over the AST are written against Walk and Rewrite, which know the children of every node, instead of
+// repeating a type switch over all the node kinds. A node is an expression, a variable, a declaration, a type, or
+// one of the fields of a record or of a function.
+
+type Node interface {
+	Span() Span
+}
+
+// Visitor is called by Walk for each node. The children of the node are walked with the visitor that Visit returns,
+// and they are skipped when it returns nil. After the children, that visitor is called with a nil node.
+type Visitor interface {
+	Visit(node Node) Visitor
+}
+
+// Walk goes through the AST from node: a node, then its children.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	rewriteChildren(node, func(child Node) Node {
+		Walk(v, child)
+		return child
+	})
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if node != nil && f(node) {
+		return f
+	}
+
+	return nil
+}
+
+// Inspect calls f for each node of the AST from node, and for its children when f returns true.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite replaces each node of the AST from node by the result of f, which is called on a node after its children
+// have been rewritten. f returns the node itself to keep it, and it must replace a node by one that can take its place:
+// an expression by an expression, a variable by a variable, a field by a field. The root is returned rewritten.
+func Rewrite(node Node, f func(Node) Node) Node {
+	rewriteChildren(node, func(child Node) Node {
+		return Rewrite(child, f)
+	})
+
+	return f(node)
+}
+
+// rewriteChildren replaces each child of node by the result of f, in the order of the source but for classes, whose
+// attributes come before their methods.
+func rewriteChildren(node Node, f func(Node) Node) {
+	exp := func(e *Exp) {
+		if *e != nil {
+			*e = f(*e).(Exp)
+		}
+	}
+
+	exps := func(es []Exp) {
+		for i := range es {
+			exp(&es[i])
+		}
+	}
+
+	variable := func(v *Var) {
+		*v = f(*v).(Var)
+	}
+
+	fields := func(fs []*Field) {
+		for i := range fs {
+			fs[i] = f(fs[i]).(*Field)
+		}
+	}
+
+	switch n := node.(type) {
+	// expressions
+	case *ArrExp:
+		exp(&n.Size)
+		exp(&n.Init)
+	case *AssignExp:
+		variable(&n.Variable)
+		exp(&n.Exp)
+	case *CallExp:
+		exps(n.Args)
+	case *MethodCallExp:
+		variable(&n.Variable)
+		exps(n.Args)
+	case *IfExp:
+		exp(&n.Predicate)
+		exp(&n.Then)
+		exp(&n.Els)
+	case *LetExp:
+		for i := range n.Decls {
+			n.Decls[i] = f(n.Decls[i]).(Declaration)
+		}
+
+		exp(&n.Body)
+	case *OperExp:
+		exp(&n.Left)
+		exp(&n.Right)
+	case *RecordExp:
+		for i := range n.Fields {
+			n.Fields[i] = f(n.Fields[i]).(*RecordField)
+		}
+	case *RecordField:
+		exp(&n.Expr)
+	case *SequenceExp:
+		exps(n.Exps)
+	case *VarExp:
+		variable(&n.Var)
+	case *ForExp:
+		exp(&n.From)
+		exp(&n.To)
+		exp(&n.Body)
+	case *WhileExp:
+		exp(&n.Pred)
+		exp(&n.Body)
+
+	// variables
+	case *FieldVar:
+		variable(&n.Variable)
+	case *SubscriptionVar:
+		variable(&n.Variable)
+		exp(&n.Exp)
+
+	// declarations
+	case *FuncDecl:
+		fields(n.Params)
+		exp(&n.Body)
+	case *PrimitiveDecl:
+		fields(n.Params)
+	case *VarDecl:
+		exp(&n.Init)
+	case *TypeDecl:
+		n.Ty = f(n.Ty).(Ty)
+
+	// types
+	case *RecordTy:
+		fields(n.Fields)
+	case *ClassTy:
+		for i := range n.Attrs {
+			n.Attrs[i] = f(n.Attrs[i]).(*VarDecl)
+		}
+
+		for i := range n.Methods {
+			n.Methods[i] = f(n.Methods[i]).(*FuncDecl)
+		}
+	}
+}
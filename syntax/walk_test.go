@@ -0,0 +1,57 @@
+package syntax
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parseTest(t *testing.T, src string) Exp {
+	exp, err := NewParser(NewLexer("test.tig", bufio.NewReader(strings.NewReader(src))), NewStrings()).Parse()
+	require.NoError(t, err)
+
+	return exp
+}
+
+func TestWalk_Inspect(t *testing.T) {
+	t.Parallel()
+
+	src := `let var a := 1 function f(n: int): int = n + a in f(2); a[3] end`
+	exp := parseTest(t, src)
+
+	var nodes []string
+	Inspect(exp, func(node Node) bool {
+		span := node.Span()
+		nodes = append(nodes, src[span.Start.Offset:span.End.Offset])
+
+		// the body of f is skipped
+		_, ok := node.(*FuncDecl)
+		return !ok
+	})
+
+	require.Equal(t, []string{src, "var a := 1", "1", "function f(n: int): int = n + a", "f(2); a[3]", "f(2)", "2",
+		"a[3]", "a[3]", "a", "3"}, nodes)
+}
+
+func TestWalk_Rewrite(t *testing.T) {
+	t.Parallel()
+
+	// folds the additions of constants
+	exp := Rewrite(parseTest(t, `f(1 + 2 + x, 3 + 4)`), func(node Node) Node {
+		if e, ok := node.(*OperExp); ok && e.Op == Plus {
+			left, ok1 := e.Left.(*IntExp)
+			right, ok2 := e.Right.(*IntExp)
+			if ok1 && ok2 {
+				return &IntExp{Val: left.Val + right.Val, pos: left.pos, span: e.span}
+			}
+		}
+
+		return node
+	})
+
+	call := exp.(*CallExp)
+	require.Equal(t, int32(3), call.Args[0].(*OperExp).Left.(*IntExp).Val)
+	require.Equal(t, int32(7), call.Args[1].(*IntExp).Val)
+}
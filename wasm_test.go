@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/backend/wasm"
+	"tiger/compiler"
+	"tiger/runtime"
+)
+
+// compileWasm compiles src to a module.
+func compileWasm(t *testing.T, src string) []byte {
+	return compileTest(t, compiler.Options{File: "test.tig", Arch: "wasm"}, []byte(src)).Output
+}
+
+// runWasm compiles src to a module and runs it on the interpreter.
+func runWasm(t *testing.T, src string) string {
+	return runWasmFile(t, "test.tig", src)
+}
+
+// runWasmFile is runWasm for the source of file.
+func runWasmFile(t *testing.T, file string, src string) string {
+	mod, err := wasm.Decode(compileTest(t, compiler.Options{File: file, Arch: "wasm"}, []byte(src)).Output)
+	require.NoError(t, err)
+
+	out := bytes.Buffer{}
+	vm, err := wasm.NewVM(mod, strings.NewReader(""), &out)
+	require.NoError(t, err)
+	vm.MaxSteps = 20000000
+	_, err = vm.Run()
+	require.NoError(t, err)
+	return out.String()
+}
+
+const wasmLoopsSrc = `
+let var i := 0
+    var s := 0
+in (
+    while i < 10 do (
+        i := i + 1;
+        if i = 3 then s := s + 100 else if i > 7 then break else s := s + i
+    );
+    printi(s);
+    print(" ");
+    printi(i);
+    print("\n")
+)
+end
+`
+
+// TestWasm_Loops breaks out of a loop from a branch, which the relooper turns into a block around the loop.
+func TestWasm_Loops(t *testing.T) {
+	require.Equal(t, "125 8\n", runWasm(t, wasmLoopsSrc))
+}
+
+// TestWasm_MatchesRiscv uses the riscv backend as an oracle for the relooper.
+func TestWasm_MatchesRiscv(t *testing.T) {
+	for _, name := range []string{"conditions", "functions", "record", "spill", "nested", "for_bounds", "strings"} {
+		src, err := os.ReadFile("./test_files/" + name + ".tig")
+		require.NoError(t, err)
+		require.Equal(t, runRiscv(t, string(src)), runWasm(t, string(src)), name)
+	}
+}
+
+// TestWasm_Node runs a module on a real engine with the JavaScript host.
+func TestWasm_Node(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node is not available")
+	}
+
+	dir := t.TempDir()
+	file, host := filepath.Join(dir, "prog.wasm"), filepath.Join(dir, "runtime_wasm.js")
+	require.NoError(t, os.WriteFile(file, compileWasm(t, wasmLoopsSrc), 0644))
+	require.NoError(t, os.WriteFile(host, []byte(runtime.Wasm), 0644))
+	b, err := exec.Command("node", host, file).CombinedOutput()
+	require.NoError(t, err, string(b))
+	require.Equal(t, "125 8\n", string(b))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"tiger/compiler"
+)
+
+// runX86 compiles src for amd64, links it with the system assembler and linker and returns what the program prints.
+func runX86(t *testing.T, src string) string {
+	for _, tool := range []string{"as", "ld"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not available", tool)
+		}
+	}
+
+	asm := compileTest(t, compiler.Options{File: "test.tig", Arch: "amd64"}, []byte(src)).Output
+
+	dir := t.TempDir()
+	asmFile, obj, bin := filepath.Join(dir, "prog.s"), filepath.Join(dir, "prog.o"), filepath.Join(dir, "prog")
+	require.NoError(t, os.WriteFile(asmFile, asm, 0644))
+
+	out, err := exec.Command("as", "-o", obj, asmFile).CombinedOutput()
+	require.NoError(t, err, string(out))
+	out, err = exec.Command("ld", "-o", bin, obj).CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	out, err = exec.Command(bin).Output()
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestX86_NestedFunctions(t *testing.T) {
+	out := runX86(t, `
+let type point = {x: int, y: int}
+    type row = array of int
+    var p := point {x = 3, y = 4}
+    var r := row [5] of 2
+in
+    let function outer(a: int): int =
+            let function inner(b: int): int = a * b + p.y
+            in inner(a + 1) end
+    in (
+        r[3] := 10;
+        printi(outer(p.x) + r[3] + r[4])
+    )
+    end
+end
+`)
+
+	require.Equal(t, "28", out)
+}